@@ -18,6 +18,7 @@ Options:
   --redact-paths         Redact NDJSON paths (default: on when --ndjson)
   --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
   --redact-all           Redact all sensitive text (implies --redact-paths)
+  --no-redact            Redact nothing, overriding redact in config.yaml
   --no-color             Disable ANSI colors in terminal output
   -h, --help             Show this help and exit
 EOF
@@ -26,6 +27,9 @@ EOF
 config_set_defaults_if_unset() {
     source "$(dirname "${BASH_SOURCE[0]}")/lib/init.sh"
     audit_set_defaults_if_unset "config-audit"
+
+    CERT_EXPIRY_DAYS="${CERT_EXPIRY_DAYS:-30}"
+    TIME_OFFSET_MAX_MS="${TIME_OFFSET_MAX_MS:-1000}"
 }
 
 config_parse_args() {
@@ -112,14 +116,20 @@ run_config_audit() {
     fi
     report_append "- Disk encryption (LUKS): **$luks_encrypted**"
 
-    # Secure Boot
-    if command -v mokutil >/dev/null 2>&1; then
-        sb_out="$(soft_out_probe "config.mokutil_sb" mokutil --sb-state 2>/dev/null)"
-        if echo "$sb_out" | grep -qi "SecureBoot enabled"; then
-            secure_boot=true
-        fi
+    # Firmware and Secure Boot
+    local firmware_type firmware_vendor firmware_version firmware_date sb_state secure_boot_setup_mode
+    IFS=$'\t' read -r firmware_type firmware_vendor firmware_version firmware_date sb_state secure_boot_setup_mode < <(linux_firmware config) || true
+    [ "$sb_state" = true ] && secure_boot=true
+    report_append "- Firmware: **${firmware_type:-unknown}** (vendor: \`${firmware_vendor:--}\`, version: \`${firmware_version:--}\`, date: ${firmware_date:--})"
+    if [ "$secure_boot_setup_mode" = true ]; then
+        report_append "- Secure Boot: **$secure_boot** (setup mode: no platform key enrolled)"
+    else
+        report_append "- Secure Boot: **$secure_boot**"
     fi
-    report_append "- Secure Boot: **$secure_boot**"
+    [ "${firmware_vendor:--}" != "-" ] || firmware_vendor=""
+    [ "${firmware_version:--}" != "-" ] || firmware_version=""
+    [ "${firmware_date:--}" != "-" ] || firmware_date=""
+    [ "${secure_boot_setup_mode:--}" != "-" ] || secure_boot_setup_mode=null
 
     # Firewall (separate service state from active rules)
     firewall_backend="unknown"
@@ -133,6 +143,9 @@ run_config_audit() {
     report_append "- Firewall service active: **$firewall_service_active**"
     report_append "- Firewall rules active: **$firewall_rules_active**"
     report_append "- Firewall backend: **$firewall_backend**"
+    report_append "- Firewall default incoming policy: **$FIREWALL_DEFAULT_INPUT_POLICY**"
+    [ -n "$FIREWALL_ZONE" ] && report_append "- Firewall zone: **$FIREWALL_ZONE**"
+    report_append "- Firewall open ports: **${FIREWALL_OPEN_PORTS:-none}**"
 
     # SSH daemon
     sshd_active="unknown"
@@ -175,10 +188,76 @@ run_config_audit() {
     fi
     report_append "- Auto updates: **$auto_updates**"
 
-    append_ndjson_line "{\"type\":\"security_config\",\"run_id\":$(json_escape "$RUN_ID"),\"luks_encrypted\":$luks_encrypted,\"secure_boot\":$secure_boot,\"firewall\":$firewall,\"firewall_service_enabled\":$firewall_service_enabled,\"firewall_service_active\":$firewall_service_active,\"firewall_rules_active\":$firewall_rules_active,\"firewall_backend\":$(json_escape "$firewall_backend"),\"firewall_backends\":$(json_escape "${FIREWALL_BACKENDS:-}"),\"mac_framework\":$(json_escape "$mac_framework")}"
+    # Time synchronization: a drifting clock breaks log correlation across
+    # hosts and certificate validity checks.
+    [[ "${TIME_OFFSET_MAX_MS:-}" =~ ^[0-9]+$ ]] || TIME_OFFSET_MAX_MS=1000
+    local time_service time_sync time_synchronized time_offset time_servers time_offset_exceeded=false
+    IFS=$'\t' read -r time_service time_sync time_synchronized time_offset time_servers < <(linux_time_sync config) || true
+    if [ "${time_offset:--}" != "-" ] && awk -v o="$time_offset" -v m="$TIME_OFFSET_MAX_MS" 'BEGIN { exit ((o < 0 ? -o : o) > m) ? 0 : 1 }'; then
+        time_offset_exceeded=true
+    fi
+    local time_offset_text="unknown"
+    [ "${time_offset:--}" = "-" ] || time_offset_text="$time_offset ms"
+    report_append "- Time sync: **${time_sync/#-/unknown}** (\`${time_service:-none}\`, synchronized: **${time_synchronized/#-/unknown}**, offset: \`$time_offset_text\`, servers: \`${time_servers:--}\`)"
+    [ "${time_sync:--}" != "-" ] || time_sync=null
+    [ "${time_synchronized:--}" != "-" ] || time_synchronized=null
+    [ "${time_offset:--}" != "-" ] || time_offset=null
+    [ "${time_servers:--}" != "-" ] || time_servers=""
+    if [ "$time_sync" = false ]; then
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"time_sync_disabled\",\"service\":$(json_escape "$time_service")}"
+    fi
+    if $time_offset_exceeded; then
+        report_append "- ⚠️ Clock offset exceeds $TIME_OFFSET_MAX_MS ms (\`TIME_OFFSET_MAX_MS\`)"
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"time_offset_exceeded\",\"offset_ms\":$time_offset,\"max_ms\":$TIME_OFFSET_MAX_MS}"
+    fi
+
+    append_ndjson_line "{\"type\":\"security_config\",\"run_id\":$(json_escape "$RUN_ID"),\"luks_encrypted\":$luks_encrypted,\"secure_boot\":$secure_boot,\"secure_boot_setup_mode\":$secure_boot_setup_mode,\"firmware_type\":$(json_escape "$firmware_type"),\"firmware_vendor\":$(json_escape "$firmware_vendor"),\"firmware_version\":$(json_escape "$firmware_version"),\"firmware_date\":$(json_escape "$firmware_date"),\"firewall\":$firewall,\"firewall_service_enabled\":$firewall_service_enabled,\"firewall_service_active\":$firewall_service_active,\"firewall_rules_active\":$firewall_rules_active,\"firewall_backend\":$(json_escape "$firewall_backend"),\"firewall_backends\":$(json_escape "${FIREWALL_BACKENDS:-}"),\"firewall_default_deny_incoming\":$FIREWALL_DEFAULT_DENY_INCOMING,\"firewall_default_input_policy\":$(json_escape "$FIREWALL_DEFAULT_INPUT_POLICY"),\"firewall_zone\":$(json_escape "$FIREWALL_ZONE"),\"firewall_open_ports\":$(json_escape "$FIREWALL_OPEN_PORTS"),\"mac_framework\":$(json_escape "$mac_framework"),\"time_sync\":$time_sync,\"time_sync_service\":$(json_escape "$time_service"),\"time_synchronized\":$time_synchronized,\"time_offset_ms\":$time_offset,\"time_offset_exceeded\":$time_offset_exceeded,\"time_servers\":$(json_escape "$time_servers")}"
     section_end_ms=$(now_ms)
     emit_timing "security_defaults" "$section_start_ms" "$section_end_ms"
 
+    # Per-volume encryption: the luks_encrypted flag above is true as soon as
+    # one volume is encrypted, while data leaks from the secondary and
+    # external volumes that are not.
+    section_start_ms=$(now_ms)
+    section_header "💽 Disk Volumes"
+    local volume_items="" volume_count=0 unencrypted_count=0 unencrypted_mounts="" vol_mount vol_device vol_fstype vol_enc vol_removable vol_encrypted
+    while IFS=$'\t' read -r vol_mount vol_device vol_fstype vol_enc vol_removable; do
+        [ -n "$vol_mount" ] || continue
+        if [ -z "$volume_items" ]; then
+            report_append "| Mount | Device | Filesystem | Encryption | Removable |"
+            report_append "|-------|--------|------------|------------|-----------|"
+        fi
+        report_append "| \`$(md_path "$vol_mount")\` | \`$vol_device\` | $vol_fstype | ${vol_enc/#-/unknown} | $vol_removable |"
+        case "$vol_enc" in
+            none) vol_encrypted=false ;;
+            -) vol_encrypted=null ;;
+            *) vol_encrypted=true ;;
+        esac
+        # The boot partitions hold the kernel and loader that unlock the rest.
+        if [ "$vol_encrypted" = false ]; then
+            case "$vol_mount" in
+                /boot|/boot/efi|/efi) ;;
+                *)
+                    unencrypted_count=$((unencrypted_count + 1))
+                    unencrypted_mounts="${unencrypted_mounts:+$unencrypted_mounts,}$(json_escape "$vol_mount")"
+                    ;;
+            esac
+        fi
+        volume_count=$((volume_count + 1))
+        [ -n "$volume_items" ] && volume_items+=","
+        volume_items+="{\"mount\":$(json_escape "$vol_mount"),\"device\":$(json_escape "$vol_device"),\"fstype\":$(json_escape "$vol_fstype"),\"encryption\":$(json_escape "${vol_enc/#-/}"),\"encrypted\":$vol_encrypted,\"removable\":$vol_removable}"
+    done < <(linux_disk_volumes config || true)
+    if (( volume_count == 0 )); then
+        report_append "_No mounted volumes discovered._"
+    elif (( unencrypted_count > 0 )); then
+        report_append ""
+        report_append "- ⚠️ Unencrypted volumes (outside /boot): **$unencrypted_count**"
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"unencrypted_volume\",\"count\":$unencrypted_count,\"mounts\":[${unencrypted_mounts}]}"
+    fi
+    append_ndjson_line "{\"type\":\"disk_volumes\",\"run_id\":$(json_escape "$RUN_ID"),\"volumes\":$volume_count,\"unencrypted\":$unencrypted_count,\"items\":[${volume_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "disk_volumes" "$section_start_ms" "$section_end_ms"
+
     section_start_ms=$(now_ms)
     section_header "🌍 Environment Overview"
     path_value="${PATH:-}"
@@ -235,6 +314,229 @@ run_config_audit() {
     section_end_ms=$(now_ms)
     emit_timing "package_manager_summary" "$section_start_ms" "$section_end_ms"
 
+    section_start_ms=$(now_ms)
+    section_header "🗃️ System Packages"
+    local pkg_lines pkg_items pkg_managers dpkg_total rpm_total pacman_total
+    pkg_lines="$(linux_system_packages "config" | LC_ALL=C sort -u)"
+    dpkg_total="$(printf '%s\n' "$pkg_lines" | awk -F'\t' '$1 == "dpkg" {c++} END {print c+0}')"
+    rpm_total="$(printf '%s\n' "$pkg_lines" | awk -F'\t' '$1 == "rpm" {c++} END {print c+0}')"
+    pacman_total="$(printf '%s\n' "$pkg_lines" | awk -F'\t' '$1 == "pacman" {c++} END {print c+0}')"
+    pkg_managers="$(printf '%s\n' "$pkg_lines" | awk -F'\t' 'NF && !seen[$1]++ {printf "%s%s", (n++ ? "," : ""), $1}')"
+    pkg_items="$(printf '%s\n' "$pkg_lines" | awk -F'\t' "$_AWK_JSON_ESC"'
+        NF >= 3 {
+            printf "%s{\"manager\":\"%s\",\"name\":\"%s\",\"version\":\"%s\",\"arch\":\"%s\"}", (n++ ? "," : ""), json_esc($1), json_esc($2), json_esc($3), json_esc($4)
+        }')"
+    if [ -n "$pkg_managers" ]; then
+        report_append "- Package managers: **${pkg_managers//,/, }**"
+        [ "$dpkg_total" -gt 0 ] && report_append "- dpkg packages installed: **$dpkg_total**"
+        [ "$rpm_total" -gt 0 ] && report_append "- rpm packages installed: **$rpm_total**"
+        [ "$pacman_total" -gt 0 ] && report_append "- pacman packages installed: **$pacman_total**"
+    else
+        report_append "_No dpkg, rpm, or pacman package database found._"
+    fi
+    append_ndjson_line "{\"type\":\"system_packages\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$((dpkg_total + rpm_total + pacman_total)),\"items\":[${pkg_items}]}"
+    append_ndjson_line "{\"type\":\"system_packages_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"managers\":$(json_escape "$pkg_managers"),\"dpkg\":$dpkg_total,\"rpm\":$rpm_total,\"pacman\":$pacman_total,\"total\":$((dpkg_total + rpm_total + pacman_total))}"
+    section_end_ms=$(now_ms)
+    emit_timing "system_packages" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🧰 Developer Toolchains"
+    local tool_items="" tool_count=0 tool_name tool_version tool_path tool_source tool_major tool_minor safe_tool_path
+    while IFS=$'\t' read -r tool_name tool_version tool_path tool_source; do
+        [ -n "$tool_name" ] || continue
+        # Java 8 and older report 1.<major>.
+        IFS=. read -r tool_major tool_minor _ <<< "${tool_version//_/.}"
+        if [ "$tool_name" = "java" ] && [ "$tool_major" = "1" ]; then
+            IFS=. read -r _ tool_major tool_minor _ <<< "${tool_version//_/.}"
+        fi
+        safe_tool_path="$(redact_path_for_ndjson "$tool_path")"
+        if (( tool_count == 0 )); then
+            report_append "| Tool | Version | Source | Path |"
+            report_append "|------|---------|--------|------|"
+        fi
+        report_append "| $tool_name | $tool_version | $tool_source | \`$safe_tool_path\` |"
+        item="{\"tool\":$(json_escape "$tool_name"),\"version\":$(json_escape "$tool_version"),\"major\":$((10#${tool_major:-0})),\"minor\":$((10#${tool_minor:-0})),\"path\":$(json_escape "$safe_tool_path"),\"source\":$(json_escape "$tool_source")}"
+        if [ -z "$tool_items" ]; then
+            tool_items="$item"
+        else
+            tool_items="${tool_items},${item}"
+        fi
+        tool_count=$((tool_count + 1))
+    done < <(linux_dev_toolchains "config")
+    if (( tool_count == 0 )); then
+        report_append "_No developer toolchains found on PATH._"
+    fi
+    append_ndjson_line "{\"type\":\"dev_toolchains\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${tool_count},\"items\":[${tool_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "dev_toolchains" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "📦 Language Packages"
+    local lang_items="" lang_count=0 lang_managers="" lang_manager lang_name lang_version
+    while IFS=$'\t' read -r lang_manager lang_name lang_version; do
+        [ -n "$lang_name" ] || continue
+        if (( lang_count == 0 )); then
+            report_append "| Manager | Package | Version |"
+            report_append "|---------|---------|---------|"
+        fi
+        report_append "| $lang_manager | \`$(md_path "$lang_name")\` | ${lang_version//,/, } |"
+        case ",$lang_managers," in
+            *",$lang_manager,"*) ;;
+            *) lang_managers="${lang_managers:+$lang_managers,}$lang_manager" ;;
+        esac
+        [ -n "$lang_items" ] && lang_items+=","
+        lang_items+="{\"manager\":$(json_escape "$lang_manager"),\"name\":$(json_escape "$lang_name"),\"version\":$(json_escape "$lang_version")}"
+        lang_count=$((lang_count + 1))
+    done < <(language_packages "config" || true)
+    if (( lang_count == 0 )); then
+        report_append "_No global npm, pipx, pip --user, gem, or cargo packages found._"
+    fi
+    append_ndjson_line "{\"type\":\"language_packages\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${lang_count},\"managers\":$(json_escape "$lang_managers"),\"items\":[${lang_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "language_packages" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🧬 Language Runtimes"
+    local rt_items="" rt_count=0 rt_name rt_version rt_path rt_manager rt_active rt_major rt_minor safe_rt_path
+    while IFS=$'\t' read -r rt_name rt_version rt_path rt_manager rt_active; do
+        [ -n "$rt_name" ] || continue
+        [ "$rt_version" != "-" ] || rt_version=""
+        # Java 8 and older report 1.<major>.
+        IFS=. read -r rt_major rt_minor _ <<< "${rt_version//_/.}"
+        if [ "$rt_name" = "java" ] && [ "$rt_major" = "1" ]; then
+            IFS=. read -r _ rt_major rt_minor _ <<< "${rt_version//_/.}"
+        fi
+        safe_rt_path="$(redact_path_for_ndjson "$rt_path")"
+        if (( rt_count == 0 )); then
+            report_append "| Runtime | Version | Manager | Active | Path |"
+            report_append "|---------|---------|---------|--------|------|"
+        fi
+        report_append "| $rt_name | ${rt_version:-?} | $rt_manager | $rt_active | \`$safe_rt_path\` |"
+        [ -n "$rt_items" ] && rt_items+=","
+        rt_items+="{\"runtime\":$(json_escape "$rt_name"),\"version\":$(json_escape "$rt_version"),\"major\":$((10#${rt_major:-0})),\"minor\":$((10#${rt_minor:-0})),\"path\":$(json_escape "$safe_rt_path"),\"manager\":$(json_escape "$rt_manager"),\"active\":$rt_active}"
+        rt_count=$((rt_count + 1))
+    done < <(runtime_installs "config" || true)
+    if (( rt_count == 0 )); then
+        report_append "_No python3, node, go, java, or ruby installs found._"
+    fi
+    append_ndjson_line "{\"type\":\"runtimes\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${rt_count},\"items\":[${rt_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "runtimes" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🧩 IDE Extensions"
+    local ext_items="" ext_count=0 ext_sideloaded=0 ext_users="" ext_user ext_ide ext_id ext_version ext_publisher ext_publisher_id ext_source ext_marketplace
+    while IFS=$'\t' read -r ext_user ext_ide ext_id ext_version ext_publisher ext_publisher_id ext_source ext_marketplace; do
+        [ -n "$ext_id" ] || continue
+        [ "$ext_version" != "-" ] || ext_version=""
+        [ "$ext_publisher" != "-" ] || ext_publisher=""
+        [ "$ext_publisher_id" != "-" ] || ext_publisher_id=""
+        [ "$ext_marketplace" != "-" ] || ext_marketplace=""
+        if (( ext_count == 0 )); then
+            report_append "| User | IDE | Extension | Version | Publisher | Source |"
+            report_append "|------|-----|-----------|---------|-----------|--------|"
+        fi
+        case "$ext_source" in
+            vsix|unlisted)
+                ext_sideloaded=$((ext_sideloaded + 1))
+                report_append "| \`$ext_user\` | $ext_ide | \`$ext_id\` | $ext_version | $ext_publisher | **$ext_source** ⚠️ |"
+                ;;
+            *) report_append "| \`$ext_user\` | $ext_ide | \`$ext_id\` | $ext_version | $ext_publisher | $ext_source |" ;;
+        esac
+        item="{\"user\":$(json_escape "$ext_user"),\"ide\":$(json_escape "$ext_ide"),\"id\":$(json_escape "$ext_id"),\"version\":$(json_escape "$ext_version"),\"publisher\":$(json_escape "$ext_publisher"),\"publisher_id\":$(json_escape "$ext_publisher_id"),\"source\":$(json_escape "$ext_source"),\"marketplace\":$(json_escape "$ext_marketplace")}"
+        if [ -z "$ext_items" ]; then
+            ext_items="$item"
+        else
+            ext_items="${ext_items},${item}"
+        fi
+        case " $ext_users " in
+            *" $ext_user "*) ;;
+            *) ext_users="$ext_users $ext_user" ;;
+        esac
+        ext_count=$((ext_count + 1))
+    done < <({ user_homes config; printf '%s\t%s\n' "$CURRENT_USER" "$HOME_DIR"; } | awk -F'\t' '!seen[$2]++' | while IFS=$'\t' read -r ext_user ext_home; do ide_extensions "$ext_user" "$ext_home"; done)
+    if (( ext_count == 0 )); then
+        report_append "_No IDE extensions found._"
+    elif (( ext_sideloaded > 0 )); then
+        report_append ""
+        report_append "- ⚠️ **$ext_sideloaded** extension(s) were not installed from a marketplace."
+    fi
+    append_ndjson_line "{\"type\":\"ide_extensions\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${ext_count},\"users\":$(echo "$ext_users" | awk '{print NF}'),\"sideloaded\":${ext_sideloaded},\"items\":[${ext_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "ide_extensions" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    local k8s_lines
+    k8s_lines="$(linux_kubelet_node "config")"
+    if [ -n "$k8s_lines" ]; then
+        section_header "☸️ Kubernetes Node"
+        local k8s_fields k8s_items k8s_pods k8s_privileged
+        k8s_fields="$(printf '%s\n' "$k8s_lines" | awk -F'\t' "$_AWK_JSON_ESC"'
+            function bool(s) { return tolower(s) == "true" ? "true" : "false" }
+            $1 == "setting" { v[$2] = $3 }
+            END {
+                printf "\"kubelet_running\":%s,\"kubelet_version\":\"%s\",\"config_path\":\"%s\",", bool(v["running"]), json_esc(v["version"]), json_esc(v["config_path"])
+                printf "\"anonymous_auth\":%s,\"read_only_port\":%d,", bool(v["anonymous_auth"]), v["read_only_port"] + 0
+                printf "\"authorization_mode\":\"%s\",\"static_pod_path\":\"%s\"", json_esc(v["authorization_mode"]), json_esc(v["static_pod_path"])
+            }')"
+        k8s_pods="$(printf '%s\n' "$k8s_lines" | awk -F'\t' '$1 == "pod" {c++} END {print c+0}')"
+        k8s_privileged="$(printf '%s\n' "$k8s_lines" | awk -F'\t' '$1 == "pod" && ($5 == "true" || $6 == "true") {c++} END {print c+0}')"
+        k8s_items="$(printf '%s\n' "$k8s_lines" | awk -F'\t' "$_AWK_JSON_ESC"'
+            $1 == "pod" {
+                n_img = split($4, img, ",")
+                images = ""
+                for (i = 1; i <= n_img; i++) images = images (i > 1 ? "," : "") "\"" json_esc(img[i]) "\""
+                printf "%s{\"file\":\"%s\",\"sha256\":\"%s\",\"images\":[%s],\"host_network\":%s,\"privileged\":%s}", (n++ ? "," : ""), json_esc($2), json_esc($3), images, $5, $6
+            }')"
+        while IFS= read -r line; do report_append "$line"; done < <(printf '%s\n' "$k8s_lines" | awk -F'\t' '
+            $1 == "setting" && $2 == "running" { print "- kubelet running: **" $3 "**" }
+            $1 == "setting" && $2 == "version" && $3 != "" { print "- kubelet version: `" $3 "`" }
+            $1 == "setting" && $2 == "config_path" { print "- Config file: " ($3 == "" ? "_none (flags only)_" : "`" $3 "`") }
+            $1 == "setting" && $2 == "anonymous_auth" { print "- Anonymous auth: **" $3 "**" ($3 == "true" ? " ⚠️" : "") }
+            $1 == "setting" && $2 == "read_only_port" { print "- Read-only port: **" $3 "**" ($3 != "0" ? " ⚠️" : "") }
+            $1 == "setting" && $2 == "authorization_mode" { print "- Authorization mode: **" $3 "**" ($3 == "AlwaysAllow" ? " ⚠️" : "") }
+            $1 == "setting" && $2 == "static_pod_path" && $3 != "" { print "- Static pod path: `" $3 "`" }
+            $1 == "pod" { print "  - `" $2 "`: " ($4 == "" ? "_no image_" : $4) ($5 == "true" ? " (host network)" : "") ($6 == "true" ? " ⚠️ privileged" : "") }')
+        append_ndjson_line "{\"type\":\"k8s_node\",\"run_id\":$(json_escape "$RUN_ID"),${k8s_fields},\"static_pod_count\":$k8s_pods,\"privileged_pod_count\":$k8s_privileged,\"items\":[${k8s_items}]}"
+    fi
+    section_end_ms=$(now_ms)
+    emit_timing "k8s_node" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "📜 Certificates"
+    local cert_lines cert_fields cert_items
+    [[ "${CERT_EXPIRY_DAYS:-}" =~ ^[0-9]+$ ]] || CERT_EXPIRY_DAYS=30
+    cert_lines="$(linux_certificates config)"
+    if [ -n "$cert_lines" ]; then
+        cert_fields="$(printf '%s\n' "$cert_lines" | awk -F'\t' -v n="$CERT_EXPIRY_DAYS" '
+            { count++ }
+            $8 == "true" { added++; if ($7 == "true") added_roots++ }
+            $5 < 0 { expired++ }
+            $5 >= 0 && $5 <= n { expiring++ }
+            END { printf "\"count\":%d,\"user_added\":%d,\"user_added_roots\":%d,\"expiring\":%d,\"expired\":%d", count, added, added_roots, expiring, expired }')"
+        cert_items="$(printf '%s\n' "$cert_lines" | certificate_items)"
+        while IFS= read -r line; do report_append "$line"; done < <(printf '%s\n' "$cert_lines" | awk -F'\t' -v n="$CERT_EXPIRY_DAYS" '
+            { count++ }
+            $8 == "true" || $5 <= n {
+                flags = ($8 == "true") ? ($7 == "true" ? "⚠️ user-added root CA" : "user-added") : ""
+                if ($5 <= n) flags = flags (flags == "" ? "" : ", ") ($5 < 0 ? "expired" : "expiring")
+                flagged[++f] = "| " $1 " | " $2 " | " $4 " (" $5 " days) | " flags " |"
+            }
+            END {
+                print "- Certificates: **" count "**, expiry window: **" n " days**"
+                print ""
+                if (!f) { print "_No user-added, expiring, or expired certificates._"; exit }
+                print "| Store | Subject | Expires | Flags |"
+                print "|-------|---------|---------|-------|"
+                for (i = 1; i <= f; i++) print flagged[i]
+            }')
+        append_ndjson_line "{\"type\":\"certificates\",\"run_id\":$(json_escape "$RUN_ID"),${cert_fields},\"expiry_days\":$CERT_EXPIRY_DAYS,\"items\":[${cert_items}]}"
+    else
+        report_append "_No trust store readable (openssl not installed?)._"
+    fi
+    section_end_ms=$(now_ms)
+    emit_timing "certificates" "$section_start_ms" "$section_end_ms"
+
     section_start_ms=$(now_ms)
     section_header "📄 Shell Profile Files"
     report_append "Existing shell profile files:"
@@ -252,6 +554,38 @@ run_config_audit() {
     section_end_ms=$(now_ms)
     emit_timing "shell_profile_files" "$section_start_ms" "$section_end_ms"
 
+    section_start_ms=$(now_ms)
+    section_header "📌 Watched Dotfiles"
+    local dotfiles_count=0 dotfiles_missing=0 dotfile_items="" dotfile_path dotfile_mode dotfile_sha safe_dotfile
+    while IFS=$'\t' read -r dotfile_path dotfile_mode dotfile_sha; do
+        [ -n "$dotfile_path" ] || continue
+        if (( dotfiles_count + dotfiles_missing == 0 )); then
+            report_append "| File | Mode | SHA-256 |"
+            report_append "|------|------|---------|"
+        fi
+        safe_dotfile="$(redact_path_for_ndjson "$dotfile_path")"
+        if [ "$dotfile_mode" = "-" ]; then
+            report_append "| \`$safe_dotfile\` | - | _missing_ |"
+            dotfiles_missing=$((dotfiles_missing + 1))
+            continue
+        fi
+        [ "$dotfile_sha" != "-" ] || dotfile_sha=""
+        report_append "| \`$safe_dotfile\` | $dotfile_mode | \`${dotfile_sha:0:12}\` |"
+        item="{\"path\":$(json_escape "$safe_dotfile"),\"mode\":$(json_escape "$dotfile_mode"),\"sha256\":$(json_escape "$dotfile_sha")}"
+        if [ -z "$dotfile_items" ]; then
+            dotfile_items="$item"
+        else
+            dotfile_items="${dotfile_items},${item}"
+        fi
+        dotfiles_count=$((dotfiles_count + 1))
+    done < <(watched_dotfiles)
+    if (( dotfiles_count + dotfiles_missing == 0 )); then
+        report_append "_No watched dotfiles. List them one per line in \`~/.osaudit/dotfiles\`._"
+    fi
+    append_ndjson_line "{\"type\":\"watched_dotfiles\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${dotfiles_count},\"missing\":${dotfiles_missing},\"items\":[${dotfile_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "watched_dotfiles" "$section_start_ms" "$section_end_ms"
+
     append_ndjson_line "{\"type\":\"config_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"shell\":$(json_escape "${SHELL:-unknown}"),\"profile_files\":${profile_files_count:-0},\"luks_encrypted\":$luks_encrypted,\"secure_boot\":$secure_boot}"
 }
 
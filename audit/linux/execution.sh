@@ -18,6 +18,7 @@ Options:
   --redact-paths         Redact NDJSON paths (default: on when --ndjson)
   --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
   --redact-all           Redact all sensitive text (implies --redact-paths)
+  --no-redact            Redact nothing, overriding redact in config.yaml
   --no-color             Disable ANSI colors in terminal output
   -h, --help             Show this help and exit
 EOF
@@ -85,6 +86,7 @@ execution_init_ndjson_if_needed() {
 
 run_execution_audit() {
     local total_processes=0
+    local containers_count=0
     local running_services=0
     local cron_jobs_count=0
     local user_services_count=0
@@ -217,6 +219,162 @@ run_execution_audit() {
     section_end_ms=$(now_ms)
     emit_timing "systemd_timers" "$section_start_ms" "$section_end_ms"
 
+    section_start_ms=$(now_ms)
+    section_header "🐳 Containers"
+    local container_lines container_items container_runtimes privileged_containers root_containers
+    container_lines="$(container_inventory "execution")"
+    containers_count="$(printf '%s\n' "$container_lines" | awk -F'\t' 'NF {c++} END {print c+0}')"
+    privileged_containers="$(printf '%s\n' "$container_lines" | awk -F'\t' 'NF && $7 == "true" {c++} END {print c+0}')"
+    root_containers="$(printf '%s\n' "$container_lines" | awk -F'\t' 'NF && ($6 == "" || $6 ~ /^(root|0)(:|$)/) {c++} END {print c+0}')"
+    container_runtimes="$(printf '%s\n' "$container_lines" | awk -F'\t' 'NF && !seen[$1]++ {printf "%s%s", (n++ ? "," : ""), $1}')"
+    container_items="$(printf '%s\n' "$container_lines" | awk -F'\t' "$_AWK_JSON_ESC"'
+        NF {
+            root = ($6 == "" || $6 ~ /^(root|0)(:|$)/) ? "true" : "false"
+            privileged = ($7 == "true") ? "true" : "false"
+            rootless = ($8 == "true") ? "true" : "false"
+            ports = ""
+            k = split($9, p, ",")
+            for (i = 1; i <= k; i++) if (p[i] != "") ports = ports (ports == "" ? "" : ",") "\"" json_esc(p[i]) "\""
+            printf "%s{\"runtime\":\"%s\",\"id\":\"%s\",\"name\":\"%s\",\"image\":\"%s\",\"status\":\"%s\",\"user\":\"%s\",\"root\":%s,\"privileged\":%s,\"rootless\":%s,\"ports\":[%s]}", (n++ ? "," : ""), json_esc($1), json_esc($2), json_esc($3), json_esc($4), json_esc($5), json_esc($6), root, privileged, rootless, ports
+        }')"
+    if (( containers_count == 0 )); then
+        report_append "_No running Docker or Podman containers found._"
+    else
+        report_append "- Running containers: **${containers_count}** (privileged: **${privileged_containers}**, running as root: **${root_containers}**)"
+        report_append ""
+        report_append "| Runtime | Name | Image | User | Privileged | Ports |"
+        report_append "|---------|------|-------|------|------------|-------|"
+        while IFS= read -r line; do
+            report_append "$line"
+        done < <(printf '%s\n' "$container_lines" | awk -F'\t' 'NF {
+            printf "| %s | `%s` | `%s` | %s | %s | %s |\n", $1, $3, $4, ($6 == "" ? "root" : $6), $7, ($9 == "" ? "-" : $9)
+        }')
+    fi
+    append_ndjson_line "{\"type\":\"containers\",\"run_id\":$(json_escape "$RUN_ID"),\"runtimes\":$(json_escape "$container_runtimes"),\"count\":${containers_count:-0},\"privileged_count\":${privileged_containers:-0},\"root_count\":${root_containers:-0},\"items\":[${container_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "containers" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🤖 AI Agents and MCP Servers"
+    local agent_items="" agent_count=0 agent_name agent_label agent_user agent_pid agent_processes agent_command
+    while IFS=$'\t' read -r agent_name agent_label agent_user agent_pid agent_processes agent_command; do
+        [ -n "$agent_name" ] || continue
+        agent_command="$(redact_path_for_ndjson "$agent_command")"
+        if [[ "${REDACT_ALL:-false}" == "true" ]]; then
+            agent_command="$(redact_command "$agent_command")"
+        fi
+        if (( agent_count == 0 )); then
+            report_append "| Agent | Name | User | PID | Processes | Command |"
+            report_append "|-------|------|------|-----|-----------|---------|"
+        fi
+        report_append "| $agent_name | \`$agent_label\` | $agent_user | $agent_pid | $agent_processes | \`${agent_command//|/\\|}\` |"
+        item="{\"agent\":$(json_escape "$agent_name"),\"name\":$(json_escape "$agent_label"),\"user\":$(json_escape "$agent_user"),\"pid\":${agent_pid:-0},\"processes\":${agent_processes:-0},\"command\":$(json_escape "$agent_command")}"
+        if [ -z "$agent_items" ]; then
+            agent_items="$item"
+        else
+            agent_items="${agent_items},${item}"
+        fi
+        agent_count=$((agent_count + 1))
+    done < <(ai_agent_processes "execution")
+    if (( agent_count == 0 )); then
+        report_append "_No AI agent, model server, or MCP server processes running._"
+    fi
+    append_ndjson_line "{\"type\":\"ai_agents\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${agent_count},\"items\":[${agent_items}]}"
+
+    local mcp_items="" mcp_count=0 mcp_enabled_count=0 mcp_users="" mcp_user mcp_client mcp_config mcp_name mcp_transport mcp_enabled mcp_command mcp_args mcp_url mcp_paths mcp_env
+    local mcp_paths_json mcp_paths_shown mcp_env_json mcp_path mcp_var
+    report_append ""
+    while IFS=$'\t' read -r mcp_user mcp_client mcp_config mcp_name mcp_transport mcp_enabled mcp_command mcp_args mcp_url mcp_paths mcp_env; do
+        [ -n "$mcp_name" ] || continue
+        [ "$mcp_command" != "-" ] || mcp_command=""
+        [ "$mcp_args" != "-" ] || mcp_args=""
+        [ "$mcp_url" != "-" ] || mcp_url=""
+        [ "$mcp_paths" != "-" ] || mcp_paths=""
+        [ "$mcp_env" != "-" ] || mcp_env=""
+        mcp_config="$(redact_path_for_ndjson "$mcp_config")"
+        mcp_args="$(redact_path_for_ndjson "$mcp_args")"
+        if [[ "${REDACT_ALL:-false}" == "true" ]]; then
+            [ -z "$mcp_args" ] || mcp_args="<args>"
+            mcp_url="${mcp_url%%\?*}"
+        fi
+        mcp_paths_json="" mcp_paths_shown=""
+        while IFS= read -r -d , mcp_path; do
+            [ -n "$mcp_path" ] || continue
+            mcp_path="$(redact_path_for_ndjson "$mcp_path")"
+            mcp_paths_json="${mcp_paths_json:+$mcp_paths_json,}$(json_escape "$mcp_path")"
+            mcp_paths_shown="${mcp_paths_shown:+$mcp_paths_shown, }\`$mcp_path\`"
+        done < <(printf '%s,' "$mcp_paths")
+        mcp_env_json=""
+        while IFS= read -r -d , mcp_var; do
+            [ -n "$mcp_var" ] || continue
+            mcp_env_json="${mcp_env_json:+$mcp_env_json,}$(json_escape "$mcp_var")"
+        done < <(printf '%s,' "$mcp_env")
+        if (( mcp_count == 0 )); then
+            report_append "| User | Client | Server | Transport | Command / URL | Paths |"
+            report_append "|------|--------|--------|-----------|---------------|-------|"
+        fi
+        report_append "| \`$mcp_user\` | $mcp_client | \`$mcp_name\`$([ "$mcp_enabled" = true ] || printf ' (disabled)') | $mcp_transport | \`$(printf '%s' "${mcp_command:-$mcp_url} $mcp_args" | sed 's/ *$//; s/|/\\|/g')\` | ${mcp_paths_shown:--} |"
+        item="{\"user\":$(json_escape "$mcp_user"),\"client\":$(json_escape "$mcp_client"),\"config\":$(json_escape "$mcp_config"),\"name\":$(json_escape "$mcp_name"),\"transport\":$(json_escape "$mcp_transport"),\"enabled\":$mcp_enabled,\"command\":$(json_escape "$mcp_command"),\"args\":$(json_escape "$mcp_args"),\"url\":$(json_escape "$mcp_url"),\"paths\":[${mcp_paths_json}],\"env\":[${mcp_env_json}]}"
+        if [ -z "$mcp_items" ]; then
+            mcp_items="$item"
+        else
+            mcp_items="${mcp_items},${item}"
+        fi
+        case " $mcp_users " in
+            *" $mcp_user "*) ;;
+            *) mcp_users="$mcp_users $mcp_user" ;;
+        esac
+        mcp_count=$((mcp_count + 1))
+        [ "$mcp_enabled" != true ] || mcp_enabled_count=$((mcp_enabled_count + 1))
+    done < <({ user_homes execution; printf '%s\t%s\n' "$CURRENT_USER" "$HOME_DIR"; } | awk -F'\t' '!seen[$2]++' | while IFS=$'\t' read -r mcp_user mcp_home; do mcp_servers "$mcp_user" "$mcp_home"; done)
+    if (( mcp_count == 0 )); then
+        report_append "_No MCP servers configured._"
+    fi
+    append_ndjson_line "{\"type\":\"mcp_servers\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${mcp_count},\"enabled\":${mcp_enabled_count},\"users\":$(echo "$mcp_users" | awk '{print NF}'),\"items\":[${mcp_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "ai_agents" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "📂 Open File Descriptors"
+    # A process past its descriptor limit fails to open files and sockets
+    # with EMFILE, usually far from the cause; warn while there is headroom.
+    local fd_warn_pct=80 fd_sys_open fd_sys_max fd_sys_pct=null fd_lines fd_processes_count fd_near
+    local fd_items="" fd_near_commands="" fd_pid fd_user fd_command fd_open fd_soft fd_hard fd_pct
+    IFS=$'\t' read -r fd_sys_open fd_sys_max < <(fd_system_usage)
+    if [ "$fd_sys_open" != "-" ] && [ "$fd_sys_max" != "-" ] && (( fd_sys_max > 0 )); then
+        fd_sys_pct=$((fd_sys_open * 100 / fd_sys_max))
+    fi
+    fd_lines="$(fd_processes execution | awk -F'\t' -v OFS='\t' '{
+        limit = ($5 != "-") ? $5 : $6
+        print $0, (limit == "-" || limit + 0 == 0) ? -1 : int($4 * 100 / limit)
+    }' | sort -t$'\t' -k7,7nr -k4,4nr)"
+    fd_processes_count=$(printf '%s' "$fd_lines" | awk 'END { print NR }')
+    fd_near=$(printf '%s\n' "$fd_lines" | awk -F'\t' -v t="$fd_warn_pct" 'NF && $7 >= t { n++ } END { print n + 0 }')
+    report_append "- Open files system-wide: **${fd_sys_open/#-/unknown}** of ${fd_sys_max/#-/unknown}"
+    report_append "- Processes with readable descriptors: **$fd_processes_count**"
+    report_append ""
+    if (( fd_processes_count > 0 )); then
+        report_append "| PID | User | Command | Open | Soft limit | Hard limit | Used |"
+        report_append "|-----|------|---------|------|------------|------------|------|"
+    fi
+    while IFS=$'\t' read -r fd_pid fd_user fd_command fd_open fd_soft fd_hard fd_pct; do
+        [ -n "$fd_pid" ] || continue
+        report_append "| $fd_pid | \`$fd_user\` | \`$fd_command\` | $fd_open | $fd_soft | $fd_hard | $([ "$fd_pct" -ge 0 ] && echo "$fd_pct%" || echo "-") |"
+        if (( fd_pct >= fd_warn_pct )); then
+            fd_near_commands="${fd_near_commands:+$fd_near_commands,}$(json_escape "$fd_command")"
+        fi
+        [ -n "$fd_items" ] && fd_items+=","
+        fd_items+="{\"pid\":$fd_pid,\"user\":$(json_escape "$fd_user"),\"command\":$(json_escape "$fd_command"),\"open\":$fd_open,\"soft_limit\":${fd_soft/#-/null},\"hard_limit\":${fd_hard/#-/null},\"used_pct\":${fd_pct/#-1/null}}"
+    done < <(printf '%s\n' "$fd_lines" | sed -n '1,15p')
+    if (( fd_near > 0 )); then
+        report_append ""
+        report_append "- ⚠️ Processes at ${fd_warn_pct}% or more of their descriptor limit: **$fd_near** — raise the limit (\`ulimit -n\`, \`LimitNOFILE=\`) or look for a descriptor leak"
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"fd_near_limit\",\"count\":$fd_near,\"threshold_pct\":$fd_warn_pct,\"commands\":[${fd_near_commands}]}"
+    fi
+    append_ndjson_line "{\"type\":\"fd_pressure\",\"run_id\":$(json_escape "$RUN_ID"),\"system_open\":${fd_sys_open/#-/null},\"system_max\":${fd_sys_max/#-/null},\"system_used_pct\":$fd_sys_pct,\"processes\":$fd_processes_count,\"near_limit\":$fd_near,\"items\":[${fd_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "fd_pressure" "$section_start_ms" "$section_end_ms"
+
     section_start_ms=$(now_ms)
     section_header "🧾 Process/Daemon Summary"
     total_processes="$(soft_out_probe "execution.ps_aux" ps aux | awk 'NR>1 {c++} END{print c+0}')"
@@ -227,7 +385,7 @@ run_execution_audit() {
     running_services="${running_services:-0}"
     report_append "- Total running processes: **$total_processes**"
     report_append "- Running systemd services: **$running_services**"
-    append_ndjson_line "{\"type\":\"execution_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"total_processes\":${total_processes:-0},\"running_services\":${running_services:-0},\"cron_jobs\":${cron_jobs_count:-0},\"user_services\":${user_services_count:-0}}"
+    append_ndjson_line "{\"type\":\"execution_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"total_processes\":${total_processes:-0},\"running_services\":${running_services:-0},\"cron_jobs\":${cron_jobs_count:-0},\"containers\":${containers_count:-0},\"ai_agents\":${agent_count:-0},\"mcp_servers\":${mcp_count:-0},\"user_services\":${user_services_count:-0}}"
     section_end_ms=$(now_ms)
     emit_timing "execution_summary" "$section_start_ms" "$section_end_ms"
 }
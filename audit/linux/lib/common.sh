@@ -61,8 +61,27 @@ _common_cleanup_tmps() {
     _COMMON_TMPFILES=()
 }
 
+# INT/TERM (Ctrl-C, a service stop, or osaudit passing either on): mark the
+# snapshot so far as interrupted with a run_summary row and exit 128+signo.
+# The EXIT trap then writes run meta and removes temp files.
+AUDIT_INTERRUPTED=""
+_common_interrupted() {
+    AUDIT_INTERRUPTED="$1"
+    trap - INT TERM
+    if [ -n "${NDJSON_FILE:-}" ] && [ -f "$NDJSON_FILE" ]; then
+        printf '{"type":"run_summary","run_id":%s,"status":"interrupted","signal":"%s"}\n' \
+            "$(json_escape "$RUN_ID")" "$1" >> "$NDJSON_FILE" 2>/dev/null || true
+    fi
+    case "$1" in
+        TERM) exit 143 ;;
+        *) exit 130 ;;
+    esac
+}
+
 if [[ "${_COMMON_CLEANUP_TRAP_SET:-0}" != "1" ]]; then
-    trap _common_cleanup_tmps EXIT INT TERM
+    trap _common_cleanup_tmps EXIT
+    trap '_common_interrupted INT' INT
+    trap '_common_interrupted TERM' TERM
     _COMMON_CLEANUP_TRAP_SET=1
 fi
 
@@ -73,6 +92,216 @@ _common_is_true() {
     esac
 }
 
+# collector_enabled <id>: false when osaudit disabled the collector
+# (OSAUDIT_DISABLED_COLLECTORS, comma-separated IDs; see `osaudit collectors`).
+collector_enabled() {
+    case ",${OSAUDIT_DISABLED_COLLECTORS:-}," in
+        *",$1,"*) return 1 ;;
+    esac
+    return 0
+}
+
+# scope_matches <text>...: true when the run is not scoped, or when one of the
+# arguments names a path under a scope path (OSAUDIT_SCOPE, ":"-separated; see
+# `osaudit run --scope`). With no arguments it is true only for unscoped runs.
+scope_matches() {
+    [ -n "${OSAUDIT_SCOPE:-}" ] || return 0
+    local roots=() root arg
+    IFS=: read -r -a roots <<< "$OSAUDIT_SCOPE"
+    for root in "${roots[@]+"${roots[@]}"}"; do
+        root="${root%/}"
+        [ -n "$root" ] || continue
+        for arg in "$@"; do
+            case "$arg" in
+                *"$root"|*"$root"/*|*"$root"[[:space:]\"\'\;:]*) return 0 ;;
+            esac
+        done
+    done
+    return 1
+}
+
+# scope_matches_file <file>...: like scope_matches, but also looks for the
+# scope paths in the files' contents, e.g. a unit's ExecStart. The files are
+# only read in scoped runs.
+scope_matches_file() {
+    [ -n "${OSAUDIT_SCOPE:-}" ] || return 0
+    scope_matches "$@" && return 0
+    local roots=() root patterns=()
+    IFS=: read -r -a roots <<< "$OSAUDIT_SCOPE"
+    for root in "${roots[@]+"${roots[@]}"}"; do
+        [ -n "${root%/}" ] || continue
+        patterns+=(-e "${root%/}")
+    done
+    (( ${#patterns[@]} > 0 )) || return 1
+    grep -aqF "${patterns[@]}" -- "$@" 2>/dev/null
+}
+
+# scope_display prints the scope paths for reports, comma-separated and
+# redacted like NDJSON paths.
+scope_display() {
+    local roots=() root out=""
+    IFS=: read -r -a roots <<< "${OSAUDIT_SCOPE:-}"
+    for root in "${roots[@]+"${roots[@]}"}"; do
+        [ -n "$root" ] || continue
+        out="${out:+$out, }$(redact_path_for_ndjson "$root")"
+    done
+    printf '%s\n' "$out"
+}
+
+# emit_scope writes the scope row of a scoped run: the paths its collectors
+# were limited to, so diff can tell scoped snapshots apart.
+emit_scope() {
+    [ -n "$NDJSON_FILE" ] && [ -n "${OSAUDIT_SCOPE:-}" ] || return 0
+    local roots=() root paths=""
+    IFS=: read -r -a roots <<< "$OSAUDIT_SCOPE"
+    for root in "${roots[@]+"${roots[@]}"}"; do
+        [ -n "$root" ] || continue
+        paths="${paths:+$paths,}$(json_escape "$(redact_path_for_ndjson "$root")")"
+    done
+    append_ndjson_line "{\"type\":\"scope\",\"run_id\":$(json_escape "$RUN_ID"),\"paths\":[${paths}]}"
+}
+
+# mount_points prints "mount_point\tfstype" for each mounted filesystem, from
+# /proc/self/mountinfo.
+mount_points() {
+    [ -r /proc/self/mountinfo ] || return 0
+    awk '{
+        for (i = 7; i <= NF && $i != "-"; i++) ;
+        mp = $5
+        gsub(/\\040/, " ", mp)
+        print mp "\t" $(i + 1)
+    }' /proc/self/mountinfo
+}
+
+# exclude_fstypes prints the filesystem types osaudit skips, one per line: the
+# fstype:<type> lines of OSAUDIT_EXCLUDE.
+exclude_fstypes() {
+    printf '%s\n' "${OSAUDIT_EXCLUDE:-}" | sed -n 's/^[[:space:]]*fstype:[[:space:]]*\([^[:space:]]*\).*$/\1/p'
+}
+
+# skipped_subtrees <root> prints "path\treason\tfstype" for each mount at or
+# under root that find_excluding does not descend into: "fstype" for a type
+# excluded with fstype:<type>, and, when ONE_FILESYSTEM is true,
+# "other_filesystem" for every mount below root, bind mounts included. When
+# root itself is on an excluded type, root is the only subtree printed.
+skipped_subtrees() {
+    local root="${1%/}" types mp fstype prev="" owner_len=-1 owner_type=""
+    types=",$(exclude_fstypes | tr '\n' ','),"
+    while IFS=$'\t' read -r mp fstype; do
+        case "$root/" in
+            "${mp%/}/"*)
+                if (( ${#mp} > owner_len )); then
+                    owner_len=${#mp}
+                    owner_type="$fstype"
+                fi
+                ;;
+        esac
+    done < <(mount_points)
+    case "$types" in
+        *",$owner_type,"*)
+            if [ -n "$owner_type" ]; then
+                printf '%s\t%s\t%s\n' "${root:-/}" fstype "$owner_type"
+                return 0
+            fi
+            ;;
+    esac
+    while IFS=$'\t' read -r mp fstype; do
+        case "$mp" in
+            "${root:-/}"|"$root"/*) ;;
+            *) continue ;;
+        esac
+        if [ -n "$prev" ]; then
+            case "$mp" in
+                "$prev"/*) continue ;;
+            esac
+        fi
+        case "$types" in
+            *",$fstype,"*)
+                printf '%s\t%s\t%s\n' "$mp" fstype "$fstype"
+                prev="${mp%/}"
+                continue
+                ;;
+        esac
+        if [ "${ONE_FILESYSTEM:-false}" = true ] && [ "$mp" != "${root:-/}" ]; then
+            printf '%s\t%s\t%s\n' "$mp" other_filesystem "$fstype"
+            prev="${mp%/}"
+        fi
+    done < <(mount_points | LC_ALL=C sort)
+}
+
+# find_excluding <root> [-maxdepth|-mindepth <n>]... <expr>...: find(1) under
+# root that first prunes the paths osaudit excludes (OSAUDIT_EXCLUDE, one
+# .gitignore-style pattern per line, from ~/.osaudit/exclude) and the
+# subtrees skipped_subtrees lists. expr needs its own -print; without one find
+# would print the pruned directories too. find's * crosses /, so "a/*/b" here
+# also matches deeper paths than in osaudit hash.
+find_excluding() {
+    local root="$1" opts=() prune=() test=() line dir_only prefix collapsed
+    shift
+    while [ $# -ge 2 ]; do
+        case "$1" in
+            -maxdepth|-mindepth) opts+=("$1" "$2"); shift 2 ;;
+            *) break ;;
+        esac
+    done
+    while IFS= read -r line; do
+        line="${line#"${line%%[![:space:]]*}"}"
+        line="${line%"${line##*[![:space:]]}"}"
+        case "$line" in
+            ''|'#'*|'!'*) continue ;;
+            fstype:*)
+                line="${line#fstype:}"
+                line="${line#"${line%%[![:space:]]*}"}"
+                [ -n "$line" ] || continue
+                (( ${#prune[@]} == 0 )) || prune+=(-o)
+                prune+=(-fstype "$line")
+                continue
+                ;;
+        esac
+        dir_only=false
+        while [ "${line%/}" != "$line" ]; do
+            dir_only=true
+            line="${line%/}"
+        done
+        case "$line" in
+            '~') line="$HOME_DIR" ;;
+            '~/'*) line="$HOME_DIR/${line#\~/}" ;;
+        esac
+        line="${line#\*\*/}"
+        [ -n "$line" ] || continue
+        case "$line" in
+            */*)
+                prefix="*/"
+                [ "${line#/}" = "$line" ] || prefix=""
+                test=(-path "$prefix${line//\*\*/*}")
+                collapsed="${line//\/\*\*\//\/}"
+                if [ "$collapsed" != "$line" ]; then
+                    test=(\( "${test[@]}" -o -path "$prefix${collapsed//\*\*/*}" \))
+                fi
+                ;;
+            *) test=(-name "${line//\*\*/*}") ;;
+        esac
+        if $dir_only; then
+            test=(\( -type d "${test[@]}" \))
+        fi
+        (( ${#prune[@]} == 0 )) || prune+=(-o)
+        prune+=("${test[@]}")
+    done <<< "${OSAUDIT_EXCLUDE:-}"
+    if [ "${ONE_FILESYSTEM:-false}" = true ]; then
+        opts+=(-xdev)
+        while IFS=$'\t' read -r line _; do
+            [ "$line" != "$root" ] || continue
+            (( ${#prune[@]} == 0 )) || prune+=(-o)
+            prune+=(-path "$line")
+        done < <(skipped_subtrees "$root")
+    fi
+    if (( ${#prune[@]} == 0 )); then
+        find "$root" "${opts[@]+"${opts[@]}"}" "$@"
+        return
+    fi
+    find "$root" "${opts[@]+"${opts[@]}"}" \( "${prune[@]}" \) -prune -o \( "$@" \)
+}
+
 # Colors
 if _common_is_true "$NO_COLOR"; then
     RED=''
@@ -94,11 +323,1285 @@ json_escape() {
     python3 -c 'import json,sys; print(json.dumps(sys.argv[1]))' "${1-}"
 }
 
+# _AWK_JSON_ESC defines json_esc(s), s escaped for the inside of a JSON
+# string: backslashes, double quotes, and control characters. Awk programs
+# that build JSON put it ahead of their own code, awk "$_AWK_JSON_ESC"'...'.
+# gsub with "&&" doubles each backslash in every awk; "\\\\" would not.
+# shellcheck disable=SC2016
+_AWK_JSON_ESC='
+    function json_esc(s,   i, n, c, out) {
+        gsub(/\\/, "&&", s)
+        if (s !~ /["\001-\037]/) return s
+        if (!_json_esc_ready) {
+            for (i = 1; i < 32; i++) _json_esc_ctl[sprintf("%c", i)] = sprintf("\\u%04x", i)
+            _json_esc_ctl["\n"] = "\\n"; _json_esc_ctl["\r"] = "\\r"; _json_esc_ctl["\t"] = "\\t"
+            _json_esc_ready = 1
+        }
+        n = length(s)
+        for (i = 1; i <= n; i++) {
+            c = substr(s, i, 1)
+            if (c == "\"") out = out "\\\""
+            else if (c in _json_esc_ctl) out = out _json_esc_ctl[c]
+            else out = out c
+        }
+        return out
+    }'
+
+# path_line_escape <path> prints path on one line, for tab-separated
+# pipelines that file names could otherwise break: backslash, newline, tab,
+# and carriage return become \\, \n, \t, and \r. path_line_unescape undoes it.
+path_line_escape() {
+    local s="$1" bs='\'
+    # Unquoted on purpose: bash 3.2 keeps the quotes of a quoted replacement
+    # inside "${...}", and bash 5.2 drops unquoted backslashes from one.
+    s=${s//"$bs"/"$bs$bs"}
+    s=${s//$'\n'/"${bs}n"}
+    s=${s//$'\t'/"${bs}t"}
+    s=${s//$'\r'/"${bs}r"}
+    printf '%s\n' "$s"
+}
+
+# path_line_unescape <var> <line> sets var to the path path_line_escape wrote
+# as line. Every backslash in line starts one of its escapes, so %b decodes
+# exactly those.
+path_line_unescape() {
+    printf -v "$1" '%b' "$2"
+}
+
+# emit_event <kind> [<key> <value>]... writes one event line to the runner's
+# event pipe, fd $OSAUDIT_EVENT_FD, and does nothing when it is unset. Values
+# of keys ending in _code or _ms are written as numbers, others as strings.
+# Pure bash: probes emit events often enough that a python3 per call shows.
+emit_event() {
+    [ -n "${OSAUDIT_EVENT_FD:-}" ] || return 0
+    local line="{\"event\":\"$1\"" key val bs='\' q='"'
+    shift
+    [ -n "${RUN_ID:-}" ] && set -- run_id "$RUN_ID" "$@"
+    while [ $# -ge 2 ]; do
+        key="$1" val="$2"
+        shift 2
+        case "$key" in
+            *_code|*_ms)
+                case "$val" in ''|*[!0-9]*) val=0 ;; esac
+                line="$line,\"$key\":$val"
+                ;;
+            *)
+                val=${val//"$bs"/"$bs$bs"}
+                val=${val//"$q"/"$bs$q"}
+                val=${val//$'\n'/"${bs}n"}
+                val=${val//$'\t'/"${bs}t"}
+                val=${val//$'\r'/"${bs}r"}
+                line="$line,\"$key\":\"$val\""
+                ;;
+        esac
+    done
+    printf '%s}\n' "$line" 2>/dev/null >&"$OSAUDIT_EVENT_FD" || true
+}
+
+# collector_started and collector_finished <id> tell the runner which
+# collector the full audit is in, for its progress display.
+collector_started() {
+    COLLECTOR_START_MS=$(now_ms)
+    emit_event collector_started collector "$1"
+}
+
+collector_finished() {
+    emit_event collector_finished collector "$1" elapsed_ms "$(( $(now_ms) - ${COLLECTOR_START_MS:-0} ))"
+}
+
+# md_path <path> prints path for the report: on one line as path_line_escape
+# writes it, other control characters as ?, and | escaped for table cells.
+md_path() {
+    path_line_escape "$1" | LC_ALL=C sed 's/[[:cntrl:]]/?/g; s/|/\\|/g'
+}
+
 stat_bytes() {
     local path="$1"
     stat -f%z "$path" 2>/dev/null || stat -c%s "$path" 2>/dev/null || echo 0
 }
 
+# Prints the SHA-256 of a file, or nothing when it cannot be read.
+sha256_file() {
+    local path="$1"
+    [ -r "$path" ] || return 0
+    if command -v sha256sum >/dev/null 2>&1; then
+        sha256sum -- "$path" 2>/dev/null | awk '{print $1}'
+    elif command -v shasum >/dev/null 2>&1; then
+        shasum -a 256 -- "$path" 2>/dev/null | awk '{print $1}'
+    fi
+}
+
+# sha256_text <text> prints the SHA-256 of text.
+sha256_text() {
+    if command -v sha256sum >/dev/null 2>&1; then
+        printf '%s' "$1" | sha256sum | awk '{print $1}'
+    elif command -v shasum >/dev/null 2>&1; then
+        printf '%s' "$1" | shasum -a 256 | awk '{print $1}'
+    fi
+}
+
+# Prints one "manager<TAB>name<TAB>version<TAB>arch" line per installed system
+# package, from whichever of dpkg, rpm, and pacman are present. Probe names
+# are prefixed with $1.
+linux_system_packages() {
+    local probe_prefix="${1:-config}"
+    if command -v dpkg-query >/dev/null 2>&1; then
+        soft_out_probe "${probe_prefix}.dpkg_query" dpkg-query -W -f='${db:Status-Abbrev}\t${Package}\t${Version}\t${Architecture}\n' \
+            | awk -F'\t' '$1 ~ /^ii/ { print "dpkg\t" $2 "\t" $3 "\t" $4 }'
+    fi
+    # On Arch, rpm is only a tool for building packages; pacman owns the system.
+    if command -v rpm >/dev/null 2>&1 && ! command -v pacman >/dev/null 2>&1; then
+        soft_out_probe "${probe_prefix}.rpm_qa" rpm -qa --qf '%{NAME}\t%|EPOCH?{%{EPOCH}:}:{}|%{VERSION}-%{RELEASE}\t%{ARCH}\n' \
+            | awk -F'\t' '$1 != "" { print "rpm\t" $1 "\t" $2 "\t" $3 }'
+    fi
+    if command -v pacman >/dev/null 2>&1; then
+        soft_out_probe "${probe_prefix}.pacman_qi" pacman -Qi \
+            | awk -F' *: ' '
+                $1 == "Name" { name = $2 }
+                $1 == "Version" { version = $2 }
+                $1 == "Architecture" { print "pacman\t" name "\t" version "\t" $2 }
+            '
+    fi
+}
+
+# Prints one "module<TAB>size<TAB>used_by<TAB>version<TAB>signer<TAB>taint"
+# line per loaded kernel module. Signer and version come from one modinfo
+# call over every module; taint is /sys/module/<module>/taint (O out-of-tree,
+# E unsigned, P proprietary). Probe names are prefixed with $1.
+linux_kernel_modules() {
+    local probe_prefix="${1:-persistence}"
+    local lsmod_out modinfo_out=""
+    lsmod_out="$(soft_out_probe "${probe_prefix}.lsmod" lsmod 2>/dev/null | awk 'NR>1 && NF {print $1 "\t" $2 "\t" $3}')"
+    [ -n "$lsmod_out" ] || return 0
+    if command -v modinfo >/dev/null 2>&1; then
+        # shellcheck disable=SC2046
+        modinfo_out="$(soft_out_probe "${probe_prefix}.modinfo" modinfo $(printf '%s\n' "$lsmod_out" | cut -f1) 2>/dev/null)"
+    fi
+    awk -F'\t' '
+        function flush() { if (name != "") { version[name] = ver; signer[name] = sig } name = ""; ver = ""; sig = "" }
+        FNR == NR {
+            if ($0 ~ /^[ \t]/) next
+            key = $0; sub(/:.*/, "", key)
+            val = $0; sub(/^[^:]*:[ \t]*/, "", val)
+            if (key == "filename") {
+                flush()
+                name = val; sub(/.*\//, "", name); sub(/\.ko.*$/, "", name); gsub(/-/, "_", name)
+            } else if (key == "name") {
+                name = val
+            } else if (key == "version") {
+                ver = val
+            } else if (key == "signer") {
+                sig = val
+            }
+            next
+        }
+        {
+            if (!flushed++) flush()
+            taint = ""
+            file = "/sys/module/" $1 "/taint"
+            if ((getline taint < file) <= 0) taint = ""
+            close(file)
+            print $1 "\t" $2 "\t" $3 "\t" version[$1] "\t" signer[$1] "\t" taint
+        }
+    ' <(printf '%s\n' "$modinfo_out") <(printf '%s\n' "$lsmod_out")
+}
+
+# Prints the kubelet settings an audit cares about as "setting<TAB>key<TAB>value"
+# lines (running, version, config_path, anonymous_auth, read_only_port,
+# authorization_mode, static_pod_path), then one
+# "pod<TAB>file<TAB>sha256<TAB>images<TAB>host_network<TAB>privileged" line per
+# static pod manifest, where images is comma-separated. Prints nothing when
+# there is no kubelet. Settings come from the kubelet's --config file, with its
+# command-line flags taking precedence, and fall back to the kubelet's own
+# defaults: a config file defaults to anonymous auth off, Webhook
+# authorization, and no read-only port, flags alone to the opposite.
+# Probe names are prefixed with $1.
+linux_kubelet_node() {
+    local probe_prefix="${1:-config}"
+    local pid="" comm args="" config="" version="" settings="" running=false
+    local anonymous=true read_only_port=10255 authorization_mode=AlwaysAllow pod_path=""
+    for comm in /proc/[0-9]*/comm; do
+        if [ "$(cat "$comm" 2>/dev/null)" = "kubelet" ]; then
+            pid="${comm#/proc/}"; pid="${pid%/comm}"
+            break
+        fi
+    done
+    if [ -n "$pid" ]; then
+        running=true
+        args="$(tr '\0' '\n' < "/proc/$pid/cmdline" 2>/dev/null)"
+        config="$(printf '%s\n' "$args" | awk '/^--config=/ {sub(/^--config=/, ""); print; exit} prev == "--config" {print; exit} {prev = $0}')"
+    fi
+    if [ -z "$config" ] && [ -f /var/lib/kubelet/config.yaml ]; then
+        config=/var/lib/kubelet/config.yaml
+    fi
+    if [ "$running" = false ] && [ -z "$config" ] && ! command -v kubelet >/dev/null 2>&1; then
+        return 0
+    fi
+    if command -v kubelet >/dev/null 2>&1; then
+        version="$(soft_out_probe "${probe_prefix}.kubelet_version" kubelet --version 2>/dev/null | awk '{print $NF; exit}')"
+    fi
+    if [ -n "$config" ]; then
+        anonymous=false read_only_port=0 authorization_mode=Webhook
+        # Flattens the YAML (or indented JSON) config to "dotted.path<TAB>value".
+        settings="$(soft_out_probe "${probe_prefix}.kubelet_config" cat "$config" 2>/dev/null | awk '
+            /^[ \t]*(#|$)/ || /^[ \t]*-/ { next }
+            {
+                match($0, /^ */); ind = RLENGTH; line = substr($0, ind + 1)
+                if (index(line, ":") == 0) next
+                key = line; sub(/:.*/, "", key); gsub(/["\047]/, "", key)
+                val = line; sub(/^[^:]*:[ \t]*/, "", val); sub(/[ \t]+#.*$/, "", val); sub(/,$/, "", val); gsub(/^["\047]|["\047]$/, "", val)
+                while (depth > 0 && indent[depth] >= ind) depth--
+                indent[++depth] = ind; keys[depth] = key
+                path = keys[1]; for (i = 2; i <= depth; i++) path = path "." keys[i]
+                if (val != "" && val != "{") print path "\t" val
+            }')"
+    fi
+    anonymous="$(kubelet_setting "$settings" authentication.anonymous.enabled "$anonymous")"
+    read_only_port="$(kubelet_setting "$settings" readOnlyPort "$read_only_port")"
+    authorization_mode="$(kubelet_setting "$settings" authorization.mode "$authorization_mode")"
+    pod_path="$(kubelet_setting "$settings" staticPodPath "$pod_path")"
+    anonymous="$(kubelet_flag "$args" anonymous-auth "$anonymous" true)"
+    read_only_port="$(kubelet_flag "$args" read-only-port "$read_only_port")"
+    authorization_mode="$(kubelet_flag "$args" authorization-mode "$authorization_mode")"
+    pod_path="$(kubelet_flag "$args" pod-manifest-path "$pod_path")"
+
+    printf 'setting\t%s\t%s\n' running "$running" version "$version" config_path "$config" \
+        anonymous_auth "$anonymous" read_only_port "$read_only_port" \
+        authorization_mode "$authorization_mode" static_pod_path "$pod_path"
+    [ -n "$pod_path" ] && [ -d "$pod_path" ] || return 0
+    local manifest sha images host_network privileged
+    for manifest in "$pod_path"/*.yaml "$pod_path"/*.yml "$pod_path"/*.json; do
+        [ -f "$manifest" ] || continue
+        sha="$(sha256sum "$manifest" 2>/dev/null | awk '{print $1}')"
+        images="$(awk '/^[ \t-]*"?image"?[ \t]*:/ {sub(/^[^:]*:[ \t]*/, ""); gsub(/["\047,]/, ""); printf "%s%s", (n++ ? "," : ""), $0}' "$manifest" 2>/dev/null)"
+        host_network=false privileged=false
+        grep -Eq '"?hostNetwork"?[[:space:]]*:[[:space:]]*true' "$manifest" 2>/dev/null && host_network=true
+        grep -Eq '"?privileged"?[[:space:]]*:[[:space:]]*true' "$manifest" 2>/dev/null && privileged=true
+        printf 'pod\t%s\t%s\t%s\t%s\t%s\n' "${manifest##*/}" "$sha" "$images" "$host_network" "$privileged"
+    done
+}
+
+# kubelet_setting <settings> <path> <default> prints the value of a flattened
+# config path, or the default.
+kubelet_setting() {
+    local value
+    value="$(printf '%s\n' "$1" | awk -F'\t' -v k="$2" '$1 == k {v = $2} END {print v}')"
+    echo "${value:-$3}"
+}
+
+# kubelet_flag <args> <flag> <default> [<bare value>] prints the value of a
+# kubelet command-line flag (--flag=value or --flag value), or the default.
+# A boolean flag given without a value takes <bare value>.
+kubelet_flag() {
+    local value
+    value="$(printf '%s\n' "$1" | awk -v f="--$2" -v bare="$4" '
+        index($0, f "=") == 1 { v = substr($0, length(f) + 2); pending = 0; next }
+        pending { v = $0; pending = 0; next }
+        $0 == f { if (bare != "") v = bare; else pending = 1 }
+        END { print v }')"
+    echo "${value:-$3}"
+}
+
+# Prints the sshd settings an audit cares about as "keyword<TAB>value" lines
+# (lowercase keywords; port and hostcertificate may repeat), after a
+# "source<TAB>..." line. The
+# source is "sshd -T", the effective configuration, when running as root;
+# otherwise "sshd_config", parsed from /etc/ssh/sshd_config and its Include
+# files the way sshd reads them (the first value of a keyword wins; Match
+# blocks are skipped), with OpenSSH's defaults for keywords left unset.
+# Prints nothing when there is no sshd. Probe names are prefixed with $1.
+sshd_settings() {
+    local probe_prefix="${1:-identity}"
+    local config=/etc/ssh/sshd_config out=""
+    if [ ! -f "$config" ] && ! command -v sshd >/dev/null 2>&1; then
+        return 0
+    fi
+    if [ "$(id -u)" = "0" ] && command -v sshd >/dev/null 2>&1; then
+        out="$(soft_out_probe "${probe_prefix}.sshd_t" sshd -T 2>/dev/null)"
+    fi
+    if [ -n "$out" ]; then
+        printf 'source\tsshd -T\n'
+    elif [ -r "$config" ]; then
+        printf 'source\tsshd_config\n'
+        out="$(_sshd_config_lines "$config" | awk '
+            { sub(/^[ \t]+/, "") }
+            /^#/ || $0 == "" { next }
+            $0 == "@end-include" { in_match = 0; next }
+            {
+                k = tolower($0); sub(/[ \t=].*/, "", k)
+                v = $0; sub(/^[^ \t=]+[ \t]*=?[ \t]*/, "", v); sub(/[ \t]+#.*$/, "", v)
+            }
+            k == "match" { in_match = 1; next }
+            in_match { next }
+            k == "challengeresponseauthentication" { k = "kbdinteractiveauthentication" }
+            k == "port" || k == "hostcertificate" { print k " " v; next }
+            !(k in seen) { seen[k] = 1; print k " " v }
+        ')"
+    else
+        return 0
+    fi
+    printf '%s\n' "$out" | awk '
+        BEGIN {
+            def["permitrootlogin"] = "prohibit-password"; def["passwordauthentication"] = "yes"
+            def["pubkeyauthentication"] = "yes"; def["kbdinteractiveauthentication"] = "yes"
+            def["permitemptypasswords"] = "no"; def["x11forwarding"] = "no"; def["maxauthtries"] = "6"
+            def["trustedusercakeys"] = "none"; def["authorizedprincipalsfile"] = "none"
+            def["authorizedkeysfile"] = ".ssh/authorized_keys .ssh/authorized_keys2"
+            paths["trustedusercakeys"]; paths["authorizedprincipalsfile"]; paths["authorizedkeysfile"]
+        }
+        {
+            k = tolower($1); v = $0; sub(/^[^ \t]+[ \t]+/, "", v)
+            if (k == "port") { print "port\t" v; ports++; next }
+            if (k == "hostcertificate") { print k "\t" v; next }
+            if (!(k in def) || (k in got)) next
+            if (k == "permitrootlogin" && v == "without-password") v = "prohibit-password"
+            got[k] = v
+        }
+        END {
+            if (!ports) print "port\t22"
+            for (k in def) print k "\t" (!(k in got) ? def[k] : (k in paths) ? got[k] : tolower(got[k]))
+        }
+    '
+}
+
+# _sshd_config_lines <file> prints an sshd_config with its Include directives
+# replaced by the files they name, each followed by an "@end-include" line,
+# since a Match block in an included file ends with the file.
+_sshd_config_lines() {
+    local line pattern file
+    while IFS= read -r line || [ -n "$line" ]; do
+        if [[ "$line" =~ ^[[:space:]]*[Ii][Nn][Cc][Ll][Uu][Dd][Ee][[:space:]=]+(.*)$ ]]; then
+            for pattern in ${BASH_REMATCH[1]}; do
+                [[ "$pattern" == /* ]] || pattern="/etc/ssh/$pattern"
+                # shellcheck disable=SC2086
+                for file in $pattern; do
+                    [ -r "$file" ] || continue
+                    cat "$file"
+                    printf '\n@end-include\n'
+                done
+            done
+        else
+            printf '%s\n' "$line"
+        fi
+    done < "$1"
+}
+
+# Prints one "alias<TAB>hostname<TAB>user<TAB>port<TAB>identity_file<TAB>proxy_jump"
+# line per Host alias in an ssh client config ($1), skipping wildcard
+# patterns. Values are the ones set inside the alias's own Host block; unset
+# ones are printed as "-".
+ssh_client_hosts() {
+    [ -r "$1" ] || return 0
+    awk '
+        function get(k) { return (k in v) ? v[k] : "-" }
+        function flush(   i) {
+            for (i = 1; i <= n; i++) print alias[i] "\t" get("hostname") "\t" get("user") "\t" get("port") "\t" get("identityfile") "\t" get("proxyjump")
+            n = 0; split("", v)
+        }
+        { sub(/^[ \t]+/, "") }
+        /^#/ || $0 == "" { next }
+        {
+            k = tolower($0); sub(/[ \t=].*/, "", k)
+            val = $0; sub(/^[^ \t=]+[ \t]*=?[ \t]*/, "", val); gsub(/"/, "", val)
+        }
+        k == "host" || k == "match" {
+            flush()
+            if (k == "host") {
+                m = split(val, pats, /[ \t]+/)
+                for (i = 1; i <= m; i++) if (pats[i] !~ /[*?!]/) alias[++n] = pats[i]
+            }
+            next
+        }
+        n && !(k in v) { v[k] = val }
+        END { flush() }
+    ' "$1" 2>/dev/null
+}
+
+# ssh_authorized_keys <user> <home> prints one
+# "user<TAB>file<TAB>type<TAB>fingerprint<TAB>options<TAB>age_days<TAB>cert_authority"
+# line per key in the user's authorized_keys files. options is true when the
+# entry restricts the key (from=, command=, ...); cert_authority when it trusts
+# the key as a CA for user certificates. age_days is the age of the file,
+# since authorized_keys records no date per key.
+ssh_authorized_keys() {
+    local user="$1" home="$2" file mtime age now key_type blob options cert_authority fingerprint
+    now="$(date +%s)"
+    for file in "$home/.ssh/authorized_keys" "$home/.ssh/authorized_keys2"; do
+        [ -r "$file" ] || continue
+        mtime="$(stat -c %Y "$file" 2>/dev/null || echo "$now")"
+        age=$(( (now - mtime) / 86400 ))
+        while IFS=$'\t' read -r key_type blob options cert_authority; do
+            fingerprint="$(printf '%s %s\n' "$key_type" "$blob" | ssh-keygen -lf - 2>/dev/null | awk '{print $2; exit}')"
+            printf '%s\t%s\t%s\t%s\t%s\t%s\t%s\n' "$user" "$file" "$key_type" "${fingerprint:-unknown}" "$options" "$age" "$cert_authority"
+        done < <(awk '
+            /^[ \t]*(#|$)/ { next }
+            {
+                for (i = 1; i < NF; i++) if ($i ~ /^(ssh-|ecdsa-|sk-)/) {
+                    print $i "\t" $(i + 1) "\t" (i > 1 ? "true" : "false") "\t" (i > 1 && $1 ~ /(^|,)cert-authority(,|$)/ ? "true" : "false")
+                    break
+                }
+            }
+        ' "$file" 2>/dev/null)
+    done
+}
+
+# ssh_certificate_details <file> prints
+# "kind<TAB>key_id<TAB>principals<TAB>valid_to<TAB>days_left<TAB>ca_fingerprint"
+# for an OpenSSH certificate, from `ssh-keygen -L`. kind is user or host;
+# principals is comma-separated, or "-" when the certificate is valid for any.
+# valid_to is "forever" (days_left "-") or the expiry in the signer's local
+# time, which days_left reads as UTC.
+ssh_certificate_details() {
+    [ -r "$1" ] || return 0
+    ssh-keygen -L -f "$1" 2>/dev/null | awk -v now="$(date -u +%s)" "$_AWK_EPOCH"'
+        $1 == "Type:" { kind = ($(NF - 1) == "host") ? "host" : "user" }
+        $1 == "Signing" && $2 == "CA:" { ca = $4 }
+        $1 == "Key" && $2 == "ID:" { id = $0; sub(/^[^:]*: */, "", id); gsub(/"/, "", id) }
+        $1 == "Valid:" {
+            to = "forever"
+            if (match($0, /(to|before) [0-9][0-9T:-]*/)) { to = substr($0, RSTART, RLENGTH); sub(/^[a-z]+ /, "", to) }
+        }
+        in_principals && index($0, ":") { in_principals = 0 }
+        in_principals { principals = principals (principals == "" ? "" : ",") $1; next }
+        $1 == "Principals:" { in_principals = ($2 != "(none)") }
+        END {
+            if (kind == "") exit
+            days = "-"
+            if (to != "forever") {
+                split(to, t, /[-T:]/)
+                d = (epoch(t[1], t[2], t[3], t[4], t[5], t[6]) - now) / 86400
+                days = (d < 0 && d != int(d)) ? int(d) - 1 : int(d)
+            }
+            print kind "\t" id "\t" (principals == "" ? "-" : principals) "\t" to "\t" days "\t" ca
+        }'
+}
+
+# secrets_agents prints "agent\tsocket\tkeys\tlifetime\tconfirm" for each
+# secrets agent the current user runs or has a live SSH agent socket for:
+# ssh-agent, gpg-agent, 1Password, Bitwarden, gnome-keyring, KWallet, and
+# KeePassXC. keys is how many identities the agent's SSH socket lists. The
+# agent protocol does not expose per-key constraints, so lifetime and confirm
+# are what keys get by default: ssh-agent's -t, gpg-agent's SSH cache TTL and
+# sshcontrol confirm flags, and AddKeysToAgent in ~/.ssh/config for keys ssh
+# adds itself. Fields that do not apply, such as constraints for agents that
+# prompt from their own app, are "-".
+secrets_agents() {
+    local uid procs add_keys add_time add_confirm auth_agent agent pattern args sock cand keys lifetime confirm out rc
+    uid="$(id -u)"
+    procs="$(soft_out_probe "identity.ps_agents" ps -A -o uid=,args= 2>/dev/null | awk -v uid="$uid" '
+        $1 == uid { n = split($2, p, "/"); args = $0; sub(/^ *[0-9]+ +/, "", args); print tolower(p[n]) "\t" args }')"
+    add_keys="$(awk '{ l = tolower($0) } match(l, /^[ \t]*addkeystoagent[ \t=]+/) { print substr(l, RLENGTH + 1); exit }' "$HOME_DIR/.ssh/config" 2>/dev/null || true)"
+    add_time="$(printf '%s\n' "$add_keys" | awk '{ for (i = 1; i <= NF; i++) if ($i ~ /^[0-9]+[smhdw]?$/) { print $i; exit } }')"
+    add_confirm=false
+    case " $add_keys " in *" confirm "*) add_confirm=true ;; esac
+    case "$(printf '%s' "${SSH_AUTH_SOCK:-}" | tr '[:upper:]' '[:lower:]')" in
+        "") auth_agent="" ;;
+        *1password*) auth_agent=1password ;;
+        *bitwarden*) auth_agent=bitwarden ;;
+        *gpg-agent*|*gnupg*) auth_agent=gpg-agent ;;
+        *keyring*) auth_agent=gnome-keyring ;;
+        *keepassxc*) auth_agent=keepassxc ;;
+        *) auth_agent=ssh-agent ;;
+    esac
+    for agent in ssh-agent gpg-agent 1password bitwarden gnome-keyring kwallet keepassxc; do
+        case "$agent" in
+            gnome-keyring) pattern='gnome-keyring-daemon' ;;
+            kwallet) pattern='kwalletd[0-9]*' ;;
+            1password) pattern='1password|1password-ssh-agent' ;;
+            *) pattern="$agent" ;;
+        esac
+        args="$(printf '%s\n' "$procs" | awk -F'\t' -v p="^($pattern)$" '$1 ~ p { print $2; exit }')"
+        sock=""
+        if [ "$auth_agent" = "$agent" ]; then
+            sock="$SSH_AUTH_SOCK"
+        else
+            while IFS= read -r cand; do
+                if [ -S "$cand" ]; then sock="$cand"; break; fi
+            done < <(case "$agent" in
+                gpg-agent) printf '%s\n' "${GNUPGHOME:-$HOME_DIR/.gnupg}/S.gpg-agent.ssh" "/run/user/$uid/gnupg/S.gpg-agent.ssh" ;;
+                1password) printf '%s\n' "$HOME_DIR/.1password/agent.sock" "$HOME_DIR/Library/Group Containers/2BUA8C4S2C.com.1password/t/agent.sock" ;;
+                bitwarden) printf '%s\n' "$HOME_DIR/.bitwarden-ssh-agent.sock" "$HOME_DIR/Library/Containers/com.bitwarden.desktop/Data/.bitwarden-ssh-agent.sock" ;;
+                gnome-keyring) printf '%s\n' "${XDG_RUNTIME_DIR:-/run/user/$uid}/keyring/ssh" ;;
+            esac)
+        fi
+        keys="-"
+        if [ -n "$sock" ] && [ -S "$sock" ] && command -v ssh-add >/dev/null 2>&1; then
+            rc=0
+            out="$(SSH_AUTH_SOCK="$sock" ssh-add -l 2>/dev/null)" || rc=$?
+            case "$rc" in
+                0) keys="$(printf '%s\n' "$out" | grep -c . || true)" ;;
+                1) keys=0 ;;
+                *) sock="" ;;
+            esac
+        else
+            sock=""
+        fi
+        [ -n "$args" ] || [ -n "$sock" ] || continue
+        lifetime="-"
+        confirm="-"
+        case "$agent" in
+            ssh-agent|gnome-keyring|keepassxc)
+                lifetime="$(printf '%s\n' "$args" | awk '{ for (i = 1; i < NF; i++) if ($i == "-t") { print $(i + 1); exit } }')"
+                lifetime="${lifetime:-${add_time:--}}"
+                confirm="$add_confirm"
+                ;;
+            gpg-agent)
+                lifetime="$(awk '$1 == "default-cache-ttl-ssh" { print $2 "s"; exit }' "${GNUPGHOME:-$HOME_DIR/.gnupg}/gpg-agent.conf" 2>/dev/null || true)"
+                lifetime="${lifetime:-1800s}"
+                confirm="$(awk '!/^[ \t]*(#|$)/ && !/^!/ { n++; if ($0 ~ /confirm/) c++ } END { print (n > 0 && c == n) ? "true" : "false" }' "${GNUPGHOME:-$HOME_DIR/.gnupg}/sshcontrol" 2>/dev/null || true)"
+                confirm="${confirm:-false}"
+                ;;
+        esac
+        [ "$keys" != "-" ] || { lifetime="-"; confirm="-"; }
+        printf '%s\t%s\t%s\t%s\t%s\n' "$agent" "${sock:--}" "$keys" "$lifetime" "$confirm"
+    done
+}
+
+# env_secret_patterns <source> reads NAME=value lines, with or without
+# `export` and quotes, and prints "source\tname\tpattern" for each variable
+# whose value looks like a credential: a private key, a cloud or SaaS token
+# format, a JWT, a URL with a password, or any long value of a variable named
+# like a token, secret, password, or key. Values are never printed. Values
+# that point elsewhere (paths, $VARS, 1Password op:// and vault: references)
+# are not credentials themselves.
+env_secret_patterns() {
+    awk -F= -v src="$1" '
+        function pattern(name, v,   n) {
+            if (v ~ /-----BEGIN [A-Z ]*PRIVATE KEY-----/) return "private_key"
+            if (match(v, /(AKIA|ASIA)[A-Z0-9]+/) && RLENGTH >= 20) return "aws_access_key"
+            if ((match(v, /gh[pousr]_[A-Za-z0-9]+/) && RLENGTH >= 40) || v ~ /github_pat_[A-Za-z0-9_]/) return "github_token"
+            if (match(v, /glpat-[A-Za-z0-9_-]+/) && RLENGTH >= 26) return "gitlab_token"
+            if (match(v, /xox[abprs]-[A-Za-z0-9-]+/) && RLENGTH >= 15) return "slack_token"
+            if (match(v, /AIza[0-9A-Za-z_-]+/) && RLENGTH >= 39) return "google_api_key"
+            if (match(v, /[rs]k_live_[0-9A-Za-z]+/) && RLENGTH >= 24) return "stripe_key"
+            if (match(v, /sk-[A-Za-z0-9_-]+/) && RLENGTH >= 23) return "api_key"
+            if (match(v, /npm_[A-Za-z0-9]+/) && RLENGTH >= 40) return "npm_token"
+            if (match(v, /eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+/) && RLENGTH >= 40) return "jwt"
+            if (v ~ /[A-Za-z][A-Za-z0-9+.-]*:\/\/[^\/:@ ]+:[^\/@ ]+@/) return "url_credentials"
+            n = toupper(name)
+            if (n !~ /(TOKEN|SECRET|PASSWORD|PASSWD|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIALS?)/) return ""
+            if (n ~ /_(FILE|PATH|DIR|SOCK|SOCKET|URL|CMD|COMMAND|HELPER|ID|NAME|USER|TYPE)$/) return ""
+            if (length(v) < 8 || v ~ /[ \t]/ || v ~ /^(\/|~|\$|op:\/\/|vault:|keychain:)/) return ""
+            return "secret_name"
+        }
+        {
+            line = $0
+            sub(/^[ \t]*(export[ \t]+)?/, "", line)
+            if (line !~ /^[A-Za-z_][A-Za-z0-9_]*=/) next
+            name = substr(line, 1, index(line, "=") - 1)
+            v = substr(line, index(line, "=") + 1)
+            sub(/;[ \t]*export[ \t]+[A-Za-z_][A-Za-z0-9_]*;?[ \t]*$/, "", v)
+            if (v ~ /^".*"$/ || v ~ /^\047.*\047$/) v = substr(v, 2, length(v) - 2)
+            p = pattern(name, v)
+            if (p != "" && !seen[name]++) print src "\t" name "\t" p
+        }'
+}
+
+# environment_variables prints the audit's environment as NAME=value lines,
+# with newlines in values escaped so each variable is one line.
+environment_variables() {
+    awk 'BEGIN { for (k in ENVIRON) { v = ENVIRON[k]; gsub(/\n/, "\\n", v); print k "=" v } }'
+}
+
+# env_secret_exposures prints "source\tname\tpattern" for each variable that
+# looks like it holds a credential (see env_secret_patterns), in the exported
+# environment (source "environment") and in the env files every session of
+# the user reads: ~/.env, ~/.pam_environment, ~/.config/environment.d, and
+# /etc/environment (source is the file).
+env_secret_exposures() {
+    local f
+    environment_variables | env_secret_patterns environment
+    for f in "$HOME_DIR/.env" "$HOME_DIR/.pam_environment" "$HOME_DIR"/.config/environment.d/*.conf /etc/environment; do
+        [ -f "$f" ] && [ -r "$f" ] || continue
+        # pam_environment also takes "NAME DEFAULT=value"; read that as the value.
+        sed -E 's/^([A-Za-z_][A-Za-z0-9_]*)[[:space:]]+(DEFAULT|OVERRIDE)=/\1=/' "$f" | env_secret_patterns "$f"
+    done
+}
+
+# Prints certificate_lines output for the system trust store: certificates
+# added locally as anchors (update-ca-certificates, update-ca-trust, and
+# trust sources; PEM or DER), which are user_added, then the distribution's
+# CA bundle. Prints nothing without openssl. Probe names are prefixed with $1.
+linux_certificates() {
+    local probe_prefix="${1:-config}" dir file bundle
+    command -v openssl >/dev/null 2>&1 || return 0
+    {
+        for dir in /usr/local/share/ca-certificates /etc/pki/ca-trust/source/anchors /etc/ca-certificates/trust-source/anchors /usr/share/pki/trust/anchors; do
+            [ -d "$dir" ] || continue
+            while IFS= read -r file; do
+                if grep -q -- '-----BEGIN CERTIFICATE-----' "$file" 2>/dev/null; then
+                    cat "$file"
+                else
+                    openssl x509 -inform DER -in "$file" 2>/dev/null
+                fi | _pem_certificate_details anchors true
+            done < <(find "$dir" -type f 2>/dev/null | LC_ALL=C sort)
+        done
+        for bundle in /etc/ssl/certs/ca-certificates.crt /etc/pki/tls/certs/ca-bundle.crt /etc/ca-certificates/extracted/tls-ca-bundle.pem /etc/ssl/ca-bundle.pem /etc/ssl/cert.pem; do
+            if [ -r "$bundle" ]; then
+                soft_out_probe "${probe_prefix}.ca_bundle" cat "$bundle" | _pem_certificate_details bundle false
+                break
+            fi
+        done
+    } | certificate_lines
+}
+
+# _AWK_EPOCH defines epoch(y, m, d, hh, mi, ss), the seconds since 1970 of
+# a UTC date, for awk programs; date(1) parses dates differently on GNU and BSD.
+# shellcheck disable=SC2016
+_AWK_EPOCH='
+    function epoch(y, m, d, hh, mi, ss,   a, yy, mm) {
+        a = int((14 - m) / 12); yy = y + 4800 - a; mm = m + 12 * a - 3
+        return (d + int((153 * mm + 2) / 5) + 365 * yy + int(yy / 4) - int(yy / 100) + int(yy / 400) - 32045 - 2440588) * 86400 + hh * 3600 + mi * 60 + ss
+    }'
+
+# _pem_certificate_details <store> <user_added> reads PEM certificates on
+# stdin and prints, for each, a "@cert<TAB>store<TAB>user_added" line followed
+# by openssl's subject, issuer, notAfter, and SHA-256 fingerprint lines, which
+# certificate_lines parses.
+_pem_certificate_details() {
+    local store="$1" user_added="$2" line pem=""
+    while IFS= read -r line; do
+        pem="${pem}${line}"$'\n'
+        if [[ "$line" == "-----END CERTIFICATE-----" ]]; then
+            printf '@cert\t%s\t%s\n' "$store" "$user_added"
+            printf '%s' "$pem" | openssl x509 -noout -subject -issuer -enddate -fingerprint -sha256 -nameopt RFC2253,-esc_msb 2>/dev/null
+            pem=""
+        fi
+    done
+}
+
+# Turns _pem_certificate_details output into one
+# "store<TAB>subject<TAB>issuer<TAB>not_after<TAB>days_left<TAB>sha256<TAB>root<TAB>user_added"
+# line per certificate; the first store listing a fingerprint wins. not_after
+# is ISO-8601 UTC, days_left is negative once a certificate has expired, and
+# root is true for self-issued certificates.
+certificate_lines() {
+    awk -F'\t' -v now="$(date -u +%s)" "$_AWK_EPOCH"'
+        function flush() {
+            if (fp != "" && !(fp in seen)) {
+                seen[fp] = 1
+                print store "\t" subject "\t" issuer "\t" not_after "\t" days "\t" fp "\t" (subject == issuer ? "true" : "false") "\t" user_added
+            }
+            fp = ""; subject = ""; issuer = ""; not_after = ""; days = 0
+        }
+        $1 == "@cert" { flush(); store = $2; user_added = $3; next }
+        /^subject=/ { subject = substr($0, 9); sub(/^ +/, "", subject); next }
+        /^issuer=/ { issuer = substr($0, 8); sub(/^ +/, "", issuer); next }
+        /^notAfter=/ {
+            # e.g. "notAfter=Jan  1 00:00:00 2030 GMT"
+            split(substr($0, 10), t, /[ :]+/)
+            m = (index("JanFebMarAprMayJunJulAugSepOctNovDec", t[1]) + 2) / 3
+            not_after = sprintf("%04d-%02d-%02dT%02d:%02d:%02dZ", t[6], m, t[2], t[3], t[4], t[5])
+            d = (epoch(t[6], m, t[2], t[3], t[4], t[5]) - now) / 86400
+            days = (d < 0 && d != int(d)) ? int(d) - 1 : int(d)
+            next
+        }
+        /[Ff]ingerprint=/ { fp = tolower(substr($0, index($0, "=") + 1)); gsub(/:/, "", fp); next }
+        END { flush() }
+    '
+}
+
+# certificate_items reads certificate_lines output and prints the JSON items
+# of the certificates row, comma-separated.
+certificate_items() {
+    awk -F'\t' "$_AWK_JSON_ESC"'
+        {
+            printf "%s{\"store\":\"%s\",\"subject\":\"%s\",\"issuer\":\"%s\",\"not_after\":\"%s\",\"days_left\":%d,\"sha256\":\"%s\",\"root\":%s,\"user_added\":%s}", \
+                (n++ ? "," : ""), json_esc($1), json_esc($2), json_esc($3), $4, $5, $6, $7, $8
+        }'
+}
+
+# user_homes <probe prefix> prints "user<TAB>home" for root and each human
+# account (uid 1000-59999) with a home directory.
+user_homes() {
+    soft_out_probe "${1:-identity}.getent_passwd" getent passwd | awk -F: '($3 == 0 || ($3 >= 1000 && $3 < 60000)) && $6 != "" {print $1 "\t" $6}'
+}
+
+# ide_extensions <user> <home> prints
+# "user\tide\tid\tversion\tpublisher\tpublisher_id\tsource\tmarketplace" for
+# each extension the user has installed in VS Code, VS Code Insiders,
+# VSCodium, Cursor, Windsurf, or VS Code Server, and each plugin in the newest
+# version of each JetBrains IDE. For the VS Code family, source is what the
+# editor recorded when installing: "gallery" (from the marketplace), "vsix"
+# (sideloaded from a file), or "unlisted" for an extension directory the
+# editor has no record of; publisher_id is the marketplace's ID for the
+# publisher, which stays the same when a publisher is renamed. JetBrains does
+# not record where a plugin came from, so its source is "unknown" and
+# marketplace lists JetBrains Marketplace plus any custom plugin repositories.
+# Empty fields are "-".
+ide_extensions() {
+    local user="$1" home="$2" ide dir marketplace ext product pname plugin jar xml hosts
+    local jb_plugins_root="$home/.local/share/JetBrains" jb_plugins_sub="" jb_config_root="$home/.config/JetBrains"
+    for ide in vscode vscode-insiders vscodium cursor windsurf vscode-server; do
+        case "$ide" in
+            vscode) dir="$home/.vscode/extensions"; marketplace=marketplace.visualstudio.com ;;
+            vscode-insiders) dir="$home/.vscode-insiders/extensions"; marketplace=marketplace.visualstudio.com ;;
+            vscodium) dir="$home/.vscode-oss/extensions"; marketplace=open-vsx.org ;;
+            cursor) dir="$home/.cursor/extensions"; marketplace=marketplace.cursorapi.com ;;
+            windsurf) dir="$home/.windsurf/extensions"; marketplace=open-vsx.org ;;
+            vscode-server) dir="$home/.vscode-server/extensions"; marketplace=marketplace.visualstudio.com ;;
+        esac
+        [ -d "$dir" ] && [ -r "$dir" ] || continue
+        {
+            if [ -r "$dir/extensions.json" ]; then
+                echo "@listed"
+                json_flatten < "$dir/extensions.json"
+            fi
+            if [ -r "$dir/.obsolete" ]; then
+                echo "@obsolete"
+                json_flatten < "$dir/.obsolete"
+            fi
+            for ext in "$dir"/*/; do
+                ext="${ext%/}"
+                [ -r "$ext/package.json" ] || continue
+                printf '@ext\t%s\n' "${ext##*/}"
+                json_flatten < "$ext/package.json"
+            done
+        } | awk -F'\t' -v user="$user" -v ide="$ide" -v mp="$marketplace" '
+            function dash(s) { return s == "" ? "-" : s }
+            function index_listed(   i, l) {
+                for (i in rel) {
+                    l = rel[i]
+                    lsrc[l] = (i in msrc) ? msrc[i] : ((i in mgid) ? "gallery" : "unknown")
+                    lpid[l] = mpid[i]
+                    lpub[l] = mpub[i]
+                }
+                indexed = 1
+            }
+            function flush(   id, src, pid, publisher) {
+                if (loc == "" || pub == "" || name == "" || (loc in obsolete)) return
+                id = tolower(pub "." name)
+                publisher = (epub != "") ? epub : pub
+                pid = epid
+                src = (egid != "") ? "gallery" : "unknown"
+                if (esrc != "") src = esrc
+                if (listed) src = "unlisted"
+                if (loc in lsrc) {
+                    src = lsrc[loc]
+                    if (lpid[loc] != "") pid = lpid[loc]
+                    if (lpub[loc] != "") publisher = lpub[loc]
+                }
+                print user "\t" ide "\t" id "\t" dash(ver) "\t" publisher "\t" dash(pid) "\t" src "\t" (src == "gallery" ? mp : "-")
+            }
+            $1 == "@listed" { mode = "listed"; listed = 1; next }
+            $1 == "@obsolete" { mode = "obsolete"; next }
+            $1 == "@ext" {
+                if (!indexed) index_listed()
+                flush()
+                mode = "ext"; loc = $2; pub = name = ver = epub = epid = egid = esrc = ""
+                next
+            }
+            mode == "obsolete" { if ($2 == "true") obsolete[$1] = 1; next }
+            mode == "listed" {
+                i = $1; sub(/\..*/, "", i); key = substr($1, length(i) + 2)
+                if (key == "relativeLocation") rel[i] = $2
+                else if (key == "location.path" && !(i in rel)) { n = split($2, q, "/"); rel[i] = q[n] }
+                else if (key == "metadata.source") msrc[i] = $2
+                else if (key == "metadata.id") mgid[i] = $2
+                else if (key == "metadata.publisherId") mpid[i] = $2
+                else if (key == "metadata.publisherDisplayName") mpub[i] = $2
+                next
+            }
+            $1 == "publisher" { pub = $2 }
+            $1 == "name" { name = $2 }
+            $1 == "version" { ver = $2 }
+            $1 == "__metadata.id" { egid = $2 }
+            $1 == "__metadata.source" { esrc = $2 }
+            $1 == "__metadata.publisherId" { epid = $2 }
+            $1 == "__metadata.publisherDisplayName" { epub = $2 }
+            END { flush() }'
+    done
+    [ -d "$jb_plugins_root" ] && [ -r "$jb_plugins_root" ] || return 0
+    # Only the newest version of each IDE (e.g. PyCharm2024.2 over
+    # PyCharm2024.1) has plugins that load.
+    while IFS=$'\t' read -r pname product; do
+        hosts="$(awk '/name="pluginHosts"/ { f = 1 } f && match($0, /value="[^"]*"/) { v = substr($0, RSTART + 7, RLENGTH - 8); sub(/^https?:\/\//, "", v); sub(/\/.*/, "", v); printf "%s%s", (n++ ? "," : ""), v } f && /<\/list>/ { exit }' "$jb_config_root/$product/options/updates.xml" 2>/dev/null || true)"
+        marketplace="plugins.jetbrains.com${hosts:+,$hosts}"
+        for plugin in "$jb_plugins_root/$product$jb_plugins_sub"/*; do
+            [ -d "$plugin/lib" ] || continue
+            xml=""
+            if command -v unzip >/dev/null 2>&1; then
+                for jar in "$plugin/lib/${plugin##*/}.jar" "$plugin"/lib/*.jar; do
+                    [ -f "$jar" ] || continue
+                    xml="$(unzip -p "$jar" META-INF/plugin.xml 2>/dev/null || true)"
+                    [ -z "$xml" ] || break
+                done
+            fi
+            printf '%s\n' "$xml" | awk -v user="$user" -v ide="$pname" -v dir="${plugin##*/}" -v mp="$marketplace" '
+                id == "" && match($0, /<id>[^<]*<\/id>/) { id = substr($0, RSTART + 4, RLENGTH - 9) }
+                ver == "" && match($0, /<version>[^<]*<\/version>/) { ver = substr($0, RSTART + 9, RLENGTH - 19) }
+                vendor == "" && match($0, /<vendor[^>]*>[^<]*<\/vendor>/) {
+                    vendor = substr($0, RSTART, RLENGTH); sub(/^<vendor[^>]*>/, "", vendor); sub(/<\/vendor>$/, "", vendor)
+                }
+                END {
+                    print user "\t" ide "\t" (id == "" ? dir : id) "\t" (ver == "" ? "-" : ver) "\t" (vendor == "" ? "-" : vendor) "\t-\tunknown\t" mp
+                }'
+        done
+    done < <(for product in "$jb_plugins_root"/*/; do product="${product%/}"; printf '%s\n' "${product##*/}"; done | awk '
+        match($0, /[0-9][0-9][0-9][0-9]\.[0-9]+$/) { latest[substr($0, 1, RSTART - 1)] = $0 }
+        END { for (p in latest) print p "\t" latest[p] }' | LC_ALL=C sort)
+}
+
+# json_flatten reads JSON on stdin and prints one "path<TAB>value" line per
+# scalar, with object keys and array indexes joined by dots, e.g.
+# "0.identifier.id<TAB>ms-python.python". \" \\ and \/ are unescaped; other
+# escapes are kept as written. It is meant for small, well-formed files such
+# as editor metadata, not as a validating parser.
+json_flatten() {
+    awk '
+        function path(   j, p) {
+            p = ""
+            for (j = 1; j <= d; j++) p = p (j > 1 ? "." : "") (t[j] == "o" ? k[j] : n[j])
+            return p
+        }
+        { s = s $0 "\n" }
+        END {
+            len = length(s)
+            for (i = 1; i <= len; i++) {
+                c = substr(s, i, 1)
+                if (c == "{" || c == "[") {
+                    d++; t[d] = (c == "{") ? "o" : "a"; n[d] = 0; k[d] = ""; want[d] = (c == "{")
+                } else if (c == "}" || c == "]") {
+                    d--
+                } else if (c == ",") {
+                    if (t[d] == "a") n[d]++; else want[d] = 1
+                } else if (c == ":") {
+                    want[d] = 0
+                } else if (c == "\"") {
+                    v = ""
+                    for (i++; i <= len; i++) {
+                        c = substr(s, i, 1)
+                        if (c == "\\") {
+                            i++; c = substr(s, i, 1)
+                            if (c != "\"" && c != "\\" && c != "/") v = v "\\"
+                        } else if (c == "\"") {
+                            break
+                        }
+                        v = v c
+                    }
+                    if (t[d] == "o" && want[d]) k[d] = v; else print path() "\t" v
+                } else if (c !~ /[ \t\r\n]/) {
+                    v = c
+                    while (i < len && substr(s, i + 1, 1) !~ /[],} \t\r\n]/) { i++; v = v substr(s, i, 1) }
+                    print path() "\t" v
+                }
+            }
+        }'
+}
+
+# fd_system_usage prints "open\tmax" for the whole system: the file handles
+# the kernel has allocated and fs.file-max, from /proc/sys/fs/file-nr. Either
+# is "-" when unknown.
+fd_system_usage() {
+    local allocated unused max
+    read -r allocated unused max < /proc/sys/fs/file-nr 2>/dev/null || true
+    printf '%s\t%s\n' "${allocated:--}" "${max:--}"
+}
+
+# fd_processes <probe prefix> prints "pid\tuser\tcommand\topen\tsoft\thard"
+# for each process with open descriptors whose fd directory can be read, which
+# without root is only the caller's own. soft and hard are its RLIMIT_NOFILE,
+# "-" when unlimited. Descriptors are counted with globs rather than ls, since
+# a busy host runs hundreds of processes.
+fd_processes() {
+    local probe_prefix="${1:-execution}"
+    local dir fd open comm line soft hard
+    local -a f
+    {
+        soft_out_probe "${probe_prefix}.ps_users" ps -eo pid=,user= | awk '{ print "user\t" $1 "\t" $2 }'
+        for dir in /proc/[0-9]*; do
+            [ -x "$dir/fd" ] || continue
+            open=0
+            for fd in "$dir"/fd/*; do
+                [ -L "$fd" ] && open=$((open + 1))
+            done
+            (( open > 0 )) || continue
+            comm="" soft="-" hard="-"
+            { read -r comm < "$dir/comm"; } 2>/dev/null || continue
+            {
+                while IFS= read -r line; do
+                    case "$line" in
+                        "Max open files"*)
+                            read -r -a f <<< "$line"
+                            [ "${f[3]}" = unlimited ] || soft="${f[3]}"
+                            [ "${f[4]}" = unlimited ] || hard="${f[4]}"
+                            ;;
+                    esac
+                done < "$dir/limits"
+            } 2>/dev/null || true
+            printf 'fd\t%s\t%s\t%s\t%s\t%s\n' "${dir#/proc/}" "$comm" "$open" "$soft" "$hard"
+        done
+    } | awk -F'\t' '
+        $1 == "user" { user[$2] = $3; next }
+        $1 == "fd" { print $2 "\t" (($2 in user) ? user[$2] : "-") "\t" $3 "\t" $4 "\t" $5 "\t" $6 }'
+}
+
+# ai_agent_processes <probe prefix> prints
+# "agent\tname\tuser\tpid\tprocesses\tcommand" for each local AI agent, model
+# server, or MCP server process: Claude Code and Claude Desktop, Codex, Gemini
+# CLI, Aider, Goose, Ollama, llama.cpp, LM Studio, LocalAI, vLLM, GPT4All, and
+# anything run as an MCP server package ("@modelcontextprotocol/server-*",
+# "mcp-server-*", "*-mcp"). Processes of the same agent and user are counted
+# once, under the first pid; name is the MCP package, or the agent.
+ai_agent_processes() {
+    local prefix="${1:-execution}" agent name uid pid count command user
+    soft_out_probe "$prefix.ps_ai_agents" ps -A -o pid=,uid=,args= | awk '
+        {
+            pid = $1; uid = $2
+            cmd = $0
+            sub(/^[ \t]*[0-9]+[ \t]+[0-9]+[ \t]+/, "", cmd)
+            exe = $3; sub(/.*\//, "", exe)
+            if (exe ~ /^([gmn]?awk|grep|ps)$/) next
+            a = tolower(cmd); agent = ""; name = ""
+            if (match(a, /@modelcontextprotocol\/server-[a-z0-9_-]+|mcp-server-[a-z0-9_-]+|[a-z0-9_]+(-[a-z0-9_]+)*-mcp(-server)?( |$)/)) {
+                agent = "mcp-server"; name = substr(a, RSTART, RLENGTH); sub(/ $/, "", name)
+            } else if (a ~ /@anthropic-ai\/claude-code|(^|\/)claude( |$)/) agent = "claude-code"
+            else if (a ~ /\/contents\/macos\/claude( |$)|(^|\/)claude-desktop( |$)/) agent = "claude-desktop"
+            else if (a ~ /@openai\/codex|(^|\/)codex( |$)/) agent = "codex"
+            else if (a ~ /@google\/gemini-cli|(^|\/)gemini( |$)/) agent = "gemini-cli"
+            else if (a ~ /(^|\/)aider( |$)|aider-chat/) agent = "aider"
+            else if (a ~ /(^|\/)goose( |$)/) agent = "goose"
+            else if (a ~ /(^|\/)ollama( |$)/) agent = "ollama"
+            else if (a ~ /llama-server|llama\.cpp/) agent = "llama.cpp"
+            else if (a ~ /lm studio|lmstudio|(^|\/)lms( |$)/) agent = "lm-studio"
+            else if (a ~ /local-ai|localai/) agent = "localai"
+            else if (a ~ /(^|[ \/])vllm([ .]|$)/) agent = "vllm"
+            else if (a ~ /gpt4all/) agent = "gpt4all"
+            if (agent == "") next
+            if (name == "") name = agent
+            k = agent SUBSEP name SUBSEP uid
+            if (!(k in first)) { first[k] = pid; line[k] = cmd; order[++n] = k }
+            count[k]++
+        }
+        END {
+            for (i = 1; i <= n; i++) {
+                split(order[i], f, SUBSEP)
+                gsub(/\t/, " ", line[order[i]])
+                print f[1] "\t" f[2] "\t" f[3] "\t" first[order[i]] "\t" count[order[i]] "\t" substr(line[order[i]], 1, 200)
+            }
+        }' | while IFS=$'\t' read -r agent name uid pid count command; do
+        user="$(id -nu "$uid" 2>/dev/null || echo "$uid")"
+        printf '%s\t%s\t%s\t%s\t%s\t%s\n' "$agent" "$name" "$user" "$pid" "$count" "$command"
+    done
+}
+
+# mcp_servers <user> <home> prints
+# "user\tclient\tconfig\tname\ttransport\tenabled\tcommand\targs\turl\tpaths\tenv"
+# for each MCP server configured in the user's Claude Desktop, Claude Code,
+# Cursor, Windsurf, VS Code, Cline, Gemini CLI, Zed, and Codex settings.
+# transport is the configured type, or "stdio" for a command and "http" for a
+# URL. paths lists the absolute and home-relative arguments (and cwd), which
+# is where servers such as server-filesystem are allowed to reach; env lists
+# the names of the variables the server is given, never their values. args is
+# space-joined and lists are comma-joined; empty fields are "-".
+mcp_servers() {
+    local user="$1" home="$2" client config prefix
+    local app_support="$home/.config"
+    while IFS='|' read -r client config prefix; do
+        [ -r "$config" ] || continue
+        case "$config" in
+            *.toml)
+                awk '
+                    /^[ \t]*\[/ { sec = $0; gsub(/^[ \t]*\[+|\]+[ \t]*$|"/, "", sec); next }
+                    sec ~ /^mcp_servers\./ && /=/ {
+                        key = $0; sub(/[ \t]*=.*/, "", key); gsub(/^[ \t]+|"/, "", key)
+                        val = $0; sub(/^[^=]*=[ \t]*/, "", val); sub(/[ \t]+$/, "", val)
+                        if (val ~ /^[[{]/) {
+                            table = (val ~ /^\{/)
+                            gsub(/^[[{][ \t]*|[ \t]*[]}]$/, "", val)
+                            n = split(val, a, /[ \t]*,[ \t]*/)
+                            for (i = 1; i <= n; i++) {
+                                v = a[i]
+                                if (table) {
+                                    k = v; sub(/[ \t]*=.*/, "", k); gsub(/"/, "", k)
+                                    sub(/^[^=]*=[ \t]*/, "", v); gsub(/^"|"$/, "", v)
+                                    if (k != "") print sec "." key "." k "\t" v
+                                } else {
+                                    gsub(/^"|"$/, "", v)
+                                    if (v != "") print sec "." key "." (i - 1) "\t" v
+                                }
+                            }
+                        } else {
+                            gsub(/^"|"$/, "", val)
+                            print sec "." key "\t" val
+                        }
+                    }' "$config" 2>/dev/null || true
+                ;;
+            *)
+                sed 's#^[[:space:]]*//.*##' "$config" 2>/dev/null | json_flatten || true
+                ;;
+        esac | awk -F'\t' -v user="$user" -v client="$client" -v config="$config" -v pre="$prefix" '
+            function dash(s) { return s == "" ? "-" : s }
+            function add(list, v) { return list (list == "" ? "" : ",") v }
+            index($1, pre) == 1 {
+                rest = substr($1, length(pre) + 1)
+                dot = index(rest, ".")
+                name = dot ? substr(rest, 1, dot - 1) : rest
+                field = dot ? substr(rest, dot + 1) : ""
+                if (name == "") next
+                if (!(name in seen)) { seen[name] = 1; order[++n] = name }
+                v = $2
+                if (field == "command" || field == "command.path") {
+                    cmd[name] = v
+                } else if (field ~ /^(command\.)?args\.[0-9]+$/) {
+                    args[name] = args[name] (args[name] == "" ? "" : " ") v
+                    if (v ~ /^[^=]*=[\/~]/) sub(/^[^=]*=/, "", v)
+                    if (v ~ /^(\/|~)/) paths[name] = add(paths[name], v)
+                } else if (field == "cwd") {
+                    paths[name] = add(paths[name], v)
+                } else if (field == "url" || field == "serverUrl" || field == "httpUrl") {
+                    url[name] = v
+                } else if (field == "type" || field == "transport") {
+                    type[name] = v
+                } else if (field ~ /^(command\.)?env\.[^.]+$/) {
+                    sub(/^(command\.)?env\./, "", field)
+                    env[name] = add(env[name], field)
+                } else if ((field == "disabled" && v == "true") || (field == "enabled" && v == "false")) {
+                    off[name] = 1
+                }
+            }
+            END {
+                for (i = 1; i <= n; i++) {
+                    s = order[i]
+                    if (cmd[s] == "" && url[s] == "") continue
+                    t = type[s] != "" ? type[s] : (cmd[s] != "" ? "stdio" : "http")
+                    print user "\t" client "\t" config "\t" s "\t" t "\t" (s in off ? "false" : "true") "\t" dash(cmd[s]) "\t" dash(args[s]) "\t" dash(url[s]) "\t" dash(paths[s]) "\t" dash(env[s])
+                }
+            }'
+    done << EOF
+claude-desktop|$app_support/Claude/claude_desktop_config.json|mcpServers.
+claude-code|$home/.claude.json|mcpServers.
+cursor|$home/.cursor/mcp.json|mcpServers.
+windsurf|$home/.codeium/windsurf/mcp_config.json|mcpServers.
+vscode|$app_support/Code/User/mcp.json|servers.
+vscode|$app_support/Code/User/settings.json|mcp.servers.
+cline|$app_support/Code/User/globalStorage/saoudrizwan.claude-dev/settings/cline_mcp_settings.json|mcpServers.
+gemini-cli|$home/.gemini/settings.json|mcpServers.
+zed|$home/.config/zed/settings.json|context_servers.
+codex|$home/.codex/config.toml|mcp_servers.
+EOF
+}
+
+# linux_dev_toolchains <probe prefix> prints "tool\tversion\tpath\tsource"
+# for each developer toolchain on PATH: git, node, python3, docker, java, and
+# go. path is the resolved binary. source is how the tool was installed: a
+# version manager (nvm, pyenv, asdf, mise, volta, sdkman), homebrew, nix,
+# snap, "user" for other paths under the home directory, the owning package
+# ("dpkg:git", "rpm:git", "pacman:git"), or "manual".
+linux_dev_toolchains() {
+    local prefix="${1:-config}" tool bin path version origin pkg
+    for tool in git node python3 docker java go; do
+        bin="$(command -v "$tool" 2>/dev/null)" || continue
+        path="$(readlink -f "$bin" 2>/dev/null || printf '%s' "$bin")"
+        version="$(_toolchain_version "$prefix" "$tool" "$bin")"
+        [ -n "$version" ] || continue
+        case "$path" in
+            "$HOME_DIR"/.nvm/*) origin=nvm ;;
+            "$HOME_DIR"/.pyenv/*) origin=pyenv ;;
+            "$HOME_DIR"/.asdf/*) origin=asdf ;;
+            "$HOME_DIR"/.local/share/mise/*) origin=mise ;;
+            "$HOME_DIR"/.volta/*) origin=volta ;;
+            "$HOME_DIR"/.sdkman/*) origin=sdkman ;;
+            "$HOME_DIR"/*) origin=user ;;
+            /home/linuxbrew/*) origin=homebrew ;;
+            /nix/*) origin=nix ;;
+            /snap/*) origin=snap ;;
+            /usr/local/*|/opt/*) origin=manual ;;
+            *)
+                if pkg="$(dpkg -S "$path" 2>/dev/null || dpkg -S "$bin" 2>/dev/null)"; then
+                    origin="dpkg:${pkg%%:*}"
+                elif pkg="$(rpm -qf --qf '%{NAME}' "$path" 2>/dev/null)"; then
+                    origin="rpm:$pkg"
+                elif pkg="$(pacman -Qqo "$path" 2>/dev/null)"; then
+                    origin="pacman:$pkg"
+                else
+                    origin=manual
+                fi
+                ;;
+        esac
+        printf '%s\t%s\t%s\t%s\n' "$tool" "$version" "$path" "$origin"
+    done
+}
+
+# language_packages <probe prefix> prints "manager\tname\tversion" for each
+# package installed globally with the language tools on PATH: npm -g,
+# pipx, pip --user, gem (default gems that ship with Ruby aside), and cargo
+# install. A gem installed in several versions lists them comma-separated.
+# These land outside the system package manager, so nothing else lists them.
+language_packages() {
+    local prefix="${1:-config}"
+    if command -v npm >/dev/null 2>&1; then
+        # "/usr/lib/node_modules/@scope/name:@scope/name@1.2.3"; the first
+        # line is the prefix itself.
+        soft_out_probe "${prefix}.npm_ls_global" npm ls -g --depth=0 --parseable --long | awk -F: '
+            $1 ~ /\/node_modules\// {
+                spec = $NF
+                at = 0
+                for (i = length(spec); i > 1; i--) if (substr(spec, i, 1) == "@") { at = i; break }
+                if (at) print "npm\t" substr(spec, 1, at - 1) "\t" substr(spec, at + 1)
+            }'
+    fi
+    if command -v pipx >/dev/null 2>&1; then
+        soft_out_probe "${prefix}.pipx_list" pipx list --short | awk 'NF >= 2 { print "pipx\t" $1 "\t" $2 }'
+    fi
+    if command -v python3 >/dev/null 2>&1 && python3 -c 'import pip' >/dev/null 2>&1; then
+        soft_out_probe "${prefix}.pip_list_user" python3 -m pip list --user --format=freeze --disable-pip-version-check \
+            | awk -F'==' 'NF == 2 { print "pip\t" $1 "\t" $2 }'
+    fi
+    if command -v gem >/dev/null 2>&1; then
+        # "json (default: 2.7.1)", "rake (13.1.0, 13.0.6)"
+        soft_out_probe "${prefix}.gem_list" gem list --local | awk '
+            match($0, /\(.*\)$/) {
+                name = substr($0, 1, RSTART - 2)
+                n = split(substr($0, RSTART + 1, RLENGTH - 2), vs, /, */)
+                out = ""
+                for (i = 1; i <= n; i++) if (vs[i] !~ /^default: /) out = out (out == "" ? "" : ",") vs[i]
+                if (name != "" && out != "") print "gem\t" name "\t" out
+            }'
+    fi
+    if command -v cargo >/dev/null 2>&1; then
+        # "ripgrep v14.1.0:" then the binaries, indented.
+        soft_out_probe "${prefix}.cargo_install_list" cargo install --list | awk '
+            /^[^ \t]/ && NF >= 2 { v = $2; sub(/^v/, "", v); sub(/:$/, "", v); print "cargo\t" $1 "\t" v }'
+    fi
+}
+
+# runtime_installs <probe prefix> prints
+# "runtime\tversion\tpath\tmanager\tactive" for every installed python3, node,
+# go, java, and ruby, not only the one on PATH. manager is "system" for the
+# distribution's runtimes, "manual" under /usr/local and /opt, "homebrew",
+# or the version manager that installed it (nvm, volta, pyenv, rbenv, asdf,
+# mise, sdkman). path is the runtime's binary. Installs of a version manager
+# or Homebrew are versioned by their directory, so only the system's
+# binaries are run. active is true for the one PATH resolves to, through a
+# version manager's shims.
+runtime_installs() {
+    local prefix="${1:-config}" home="$HOME_DIR" brew=/home/linuxbrew/.linuxbrew
+    local found on_path="" runtime bin resolved version manager path active entry seen=$'\n'
+    found="$(
+        for bin in /usr/bin/python3 /usr/bin/python3.[0-9] /usr/bin/python3.[0-9][0-9] /usr/local/bin/python3 \
+            /usr/bin/node /usr/local/bin/node /usr/bin/go /usr/lib/go*/bin/go /usr/local/go/bin/go \
+            /usr/lib/jvm/*/bin/java /opt/*/bin/java /usr/bin/ruby /usr/local/bin/ruby; do
+            [ -x "$bin" ] || continue
+            resolved="$(readlink -f "$bin" 2>/dev/null || printf '%s' "$bin")"
+            case "$seen" in *$'\n'"$resolved"$'\n'*) continue ;; esac
+            seen+="$resolved"$'\n'
+            runtime="${bin##*/}"
+            case "$runtime" in python3*) runtime=python3 ;; esac
+            version="$(_toolchain_version "$prefix" "$runtime" "$bin")"
+            [ -n "$version" ] || continue
+            case "$bin" in /usr/local/*|/opt/*) manager=manual ;; *) manager=system ;; esac
+            printf '%s\t%s\t%s\t%s\n' "$runtime" "$version" "$resolved" "$manager"
+        done
+        _runtime_dirs homebrew python3 bin/python3 "$brew"/Cellar/python@3*/*
+        _runtime_dirs homebrew node bin/node "$brew"/Cellar/node/* "$brew"/Cellar/node@*/*
+        _runtime_dirs homebrew go bin/go "$brew"/Cellar/go/* "$brew"/Cellar/go@*/*
+        _runtime_dirs homebrew java bin/java "$brew"/Cellar/openjdk/* "$brew"/Cellar/openjdk@*/*
+        _runtime_dirs homebrew ruby bin/ruby "$brew"/Cellar/ruby/* "$brew"/Cellar/ruby@*/*
+        _runtime_version_managers "$home"
+    )"
+    for runtime in python3 node go java ruby; do
+        bin="$(command -v "$runtime" 2>/dev/null)" || continue
+        resolved="$(readlink -f "$bin" 2>/dev/null || printf '%s' "$bin")"
+        case "$resolved" in
+            */shims/*)
+                case "$resolved" in
+                    "$home"/.pyenv/*) manager=pyenv ;;
+                    "$home"/.rbenv/*) manager=rbenv ;;
+                    "$home"/.asdf/*) manager=asdf ;;
+                    *) manager=mise ;;
+                esac
+                on_path+="$runtime"$'\t'"$manager"$'\t'"$(_toolchain_version "$prefix" "$runtime" "$bin")"$'\n'
+                ;;
+            *) on_path+="$runtime"$'\t'"$resolved"$'\n' ;;
+        esac
+    done
+    while IFS=$'\t' read -r runtime version path manager; do
+        [ -n "$runtime" ] || continue
+        active=false
+        case "$on_path" in
+            *"$runtime"$'\t'"$(readlink -f "$path" 2>/dev/null || printf '%s' "$path")"$'\n'*) active=true ;;
+            *"$runtime"$'\t'"$manager"$'\t'"$version"$'\n'*) active=true ;;
+        esac
+        printf '%s\t%s\t%s\t%s\t%s\n' "$runtime" "${version:--}" "$path" "$manager" "$active"
+    done <<< "$found"
+}
+
+# _runtime_version_managers <home> lists the runtimes the version managers
+# under home installed, for runtime_installs.
+_runtime_version_managers() {
+    local home="$1" asdf="$1/.asdf/installs" mise="$1/.local/share/mise/installs"
+    _runtime_dirs nvm node bin/node "$home"/.nvm/versions/node/*
+    _runtime_dirs volta node bin/node "$home"/.volta/tools/image/node/*
+    _runtime_dirs pyenv python3 bin/python3 "$home"/.pyenv/versions/*
+    _runtime_dirs rbenv ruby bin/ruby "$home"/.rbenv/versions/*
+    _runtime_dirs asdf python3 bin/python3 "$asdf"/python/*
+    _runtime_dirs asdf node bin/node "$asdf"/nodejs/*
+    _runtime_dirs asdf go go/bin/go "$asdf"/golang/*
+    _runtime_dirs asdf java bin/java "$asdf"/java/*
+    _runtime_dirs asdf ruby bin/ruby "$asdf"/ruby/*
+    _runtime_dirs mise python3 bin/python3 "$mise"/python/*
+    _runtime_dirs mise node bin/node "$mise"/node/*
+    _runtime_dirs mise go bin/go "$mise"/go/*
+    _runtime_dirs mise java bin/java "$mise"/java/*
+    _runtime_dirs mise ruby bin/ruby "$mise"/ruby/*
+    _runtime_dirs sdkman java bin/java "$home"/.sdkman/candidates/java/*
+}
+
+# _runtime_dirs <manager> <runtime> <binary under dir> <dir>... prints a
+# runtime_installs line for each install directory holding the binary,
+# versioned by the directory's name: v20.11.1, 3.12.2_1, temurin-21.0.2+13.
+# Symlinked aliases such as mise's "20" or sdkman's "current" are skipped.
+_runtime_dirs() {
+    local manager="$1" runtime="$2" rel="$3" dir
+    shift 3
+    for dir in "$@"; do
+        [ -d "$dir" ] && [ ! -L "$dir" ] && [ -x "$dir/$rel" ] || continue
+        printf '%s\t%s\t%s\t%s\n' "$runtime" "$(printf '%s\n' "${dir##*/}" | awk 'match($0, /[0-9]+(\.[0-9]+)*/) { print substr($0, RSTART, RLENGTH) }')" "$dir/$rel" "$manager"
+    done
+}
+
+# _toolchain_version <probe prefix> <tool> <binary> prints the version a
+# developer tool reports, e.g. 20.11.1 for node or 1.8.0_402 for Java 8.
+_toolchain_version() {
+    local out
+    case "$2" in
+        go) out="$(soft_out_probe "$1.toolchain_version" "$3" version)" ;;
+        java) out="$(soft_out_probe "$1.toolchain_version" sh -c '"$0" -version 2>&1' "$3")" ;;
+        *) out="$(soft_out_probe "$1.toolchain_version" "$3" --version)" ;;
+    esac
+    printf '%s\n' "$out" | awk 'match($0, /[0-9]+(\.[0-9]+)+(_[0-9]+)?/) { print substr($0, RSTART, RLENGTH); exit }'
+}
+
+# Prints one "runtime<TAB>id<TAB>name<TAB>image<TAB>status<TAB>user<TAB>privileged<TAB>rootless<TAB>ports"
+# line per running Docker or Podman container. ports is a comma-separated list
+# of host_ip:host_port->port/proto bindings; rootless is true when the runtime
+# runs without root, so a container's root user is not root on the host.
+# Probe names are prefixed with $1.
+container_inventory() {
+    local probe_prefix="${1:-execution}"
+    local runtime ids rootless fmt
+    fmt='{{.Id}}'$'\t''{{.Name}}'$'\t''{{.Config.Image}}'$'\t''{{.State.Status}}'$'\t''{{.Config.User}}'$'\t''{{.HostConfig.Privileged}}'$'\t''{{range $p, $b := .NetworkSettings.Ports}}{{range $b}}{{.HostIp}}:{{.HostPort}}->{{$p}},{{end}}{{end}}'
+    for runtime in docker podman; do
+        command -v "$runtime" >/dev/null 2>&1 || continue
+        ids="$(soft_out_probe "${probe_prefix}.${runtime}_ps" "$runtime" ps -q 2>/dev/null)"
+        [ -n "$ids" ] || continue
+        if [ "$runtime" = "podman" ]; then
+            rootless="$("$runtime" info --format '{{.Host.Security.Rootless}}' 2>/dev/null || true)"
+        else
+            rootless=false
+            "$runtime" info --format '{{.SecurityOptions}}' 2>/dev/null | grep -q 'rootless' && rootless=true
+        fi
+        # shellcheck disable=SC2086
+        soft_out_probe "${probe_prefix}.${runtime}_inspect" "$runtime" inspect --format "$fmt" $ids 2>/dev/null \
+            | awk -F'\t' -v rt="$runtime" -v rootless="${rootless:-false}" 'NF >= 6 {
+                name = $2; sub(/^\//, "", name)
+                ports = $7; sub(/,$/, "", ports)
+                print rt "\t" substr($1, 1, 12) "\t" name "\t" $3 "\t" $4 "\t" $5 "\t" $6 "\t" rootless "\t" ports
+            }'
+    done
+}
+
 dir_bytes() {
     local path="$1"
     local kib=0
@@ -128,7 +1631,7 @@ get_audit_path_for_output() {
 redact_path_for_ndjson() {
     local input_path="$1"
     if ! _common_is_true "$REDACT_PATHS"; then
-        echo "$input_path"
+        printf '%s\n' "$input_path"
         return
     fi
 
@@ -137,13 +1640,13 @@ redact_path_for_ndjson() {
             echo "~"
             ;;
         "$HOME_DIR"/*)
-            echo "~/${input_path#$HOME_DIR/}"
+            printf '~/%s\n' "${input_path#$HOME_DIR/}"
             ;;
         *)
             if [ -n "$CURRENT_USER" ]; then
-                echo "$input_path" | sed "s#/${CURRENT_USER}/#/<user>/#g; s#/${CURRENT_USER}\$#/<user>#"
+                printf '%s\n' "$input_path" | LC_ALL=C sed "s#/${CURRENT_USER}/#/<user>/#g; s#/${CURRENT_USER}\$#/<user>#"
             else
-                echo "$input_path"
+                printf '%s\n' "$input_path"
             fi
             ;;
     esac
@@ -236,11 +1739,12 @@ now_ms() {
 }
 
 emit_timing() {
-    [ -n "$NDJSON_FILE" ] || return 0
     local section="$1"
     local start_ms="$2"
     local end_ms="$3"
     local elapsed_ms=$((end_ms - start_ms))
+    emit_event section_finished section "$section" elapsed_ms "$elapsed_ms"
+    [ -n "$NDJSON_FILE" ] || return 0
     append_ndjson_line "{\"type\":\"timing\",\"run_id\":$(json_escape "$RUN_ID"),\"section\":$(json_escape "$section"),\"elapsed_ms\":$elapsed_ms}"
 }
 
@@ -260,10 +1764,12 @@ filter_dns_server_tokens() {
         function clean_token(t) {
             gsub(/^[\[,]+/, "", t)
             gsub(/[\],]+$/, "", t)
+            sub(/#.*$/, "", t)
             return t
         }
+        # No {n,m} intervals: mawk does not support them.
         function is_ipv4(t) {
-            return t ~ /^([0-9]{1,3}\.){3}[0-9]{1,3}$/
+            return t ~ /^[0-9]+\.[0-9]+\.[0-9]+\.[0-9]+$/
         }
         function is_ipv6(t) {
             return t ~ /^[0-9A-Fa-f:.]+(%[A-Za-z0-9_.-]+)?$/ && t ~ /:/ && t !~ /:$/
@@ -279,6 +1785,665 @@ filter_dns_server_tokens() {
     '
 }
 
+# linux_dns_resolvers <probe prefix> prints "scope\tinterface\tdomain\tservers\tsearch"
+# for each configured resolver: /etc/resolv.conf (scope resolv.conf), and when
+# systemd-resolved runs, its global settings (global) and per-link overrides
+# (interface). servers and search are space-separated; empty fields are "-".
+# Search domains starting with ~ are systemd-resolved routing-only domains.
+linux_dns_resolvers() {
+    local probe_prefix="${1:-network}"
+    if [ -r /etc/resolv.conf ]; then
+        awk '
+            $1 == "nameserver" && $2 != "" { servers = servers (servers == "" ? "" : " ") $2 }
+            $1 == "search" || $1 == "domain" { for (i = 2; i <= NF; i++) search = search (search == "" ? "" : " ") $i }
+            END {
+                if (servers != "" || search != "") {
+                    printf "resolv.conf\t-\t-\t%s\t%s\n", (servers == "" ? "-" : servers), (search == "" ? "-" : search)
+                }
+            }
+        ' /etc/resolv.conf
+    fi
+    command -v resolvectl >/dev/null 2>&1 || return 0
+    local dns domains label values servers search scope iface
+    dns="$(soft_out_probe "${probe_prefix}.resolvectl_dns" resolvectl dns)"
+    domains="$(soft_out_probe "${probe_prefix}.resolvectl_domain" resolvectl domain)"
+    # Both commands print "Global: ..." and "Link N (iface): ..." lines.
+    while IFS= read -r label; do
+        [ -n "$label" ] || continue
+        values="$(printf '%s\n' "$dns" | awk -v l="$label" 'index($0, l ":") == 1 { print substr($0, length(l) + 2) }')"
+        servers="$(printf '%s\n' "$values" | filter_dns_server_tokens | tr '\n' ' ')"
+        search="$(printf '%s\n' "$domains" | awk -v l="$label" 'index($0, l ":") == 1 { $0 = substr($0, length(l) + 2); $1 = $1; print }')"
+        servers="${servers% }"
+        [ -n "$servers$search" ] || continue
+        if [ "$label" = "Global" ]; then
+            scope="global"
+            iface="-"
+        else
+            scope="interface"
+            iface="${label#*(}"
+            iface="${iface%)}"
+        fi
+        printf '%s\t%s\t-\t%s\t%s\n' "$scope" "$iface" "${servers:--}" "${search:--}"
+    done < <(printf '%s\n%s\n' "$dns" "$domains" | awk '{ i = index($0, ":") } i > 1 && !seen[substr($0, 1, i - 1)]++ { print substr($0, 1, i - 1) }')
+}
+
+# WELL_KNOWN_DOMAINS are domains whose resolution an attacker gains from
+# redirecting (vendor update and software distribution servers, identity
+# providers); hosts_entries flags entries for them and their subdomains.
+WELL_KNOWN_DOMAINS="apple.com icloud.com mzstatic.com apple-cloudkit.com microsoft.com microsoftonline.com windowsupdate.com live.com office.com google.com googleapis.com gstatic.com gvt1.com github.com githubusercontent.com okta.com auth0.com onelogin.com duosecurity.com ubuntu.com canonical.com snapcraft.io debian.org fedoraproject.org redhat.com centos.org archlinux.org docker.com docker.io npmjs.org pypi.org mozilla.org"
+
+# hosts_entries <file> prints "ip\thostname\toverride" for each hostname the
+# hosts file maps, one line per address and name, lowercased. override is true
+# when the name is one of WELL_KNOWN_DOMAINS or under one, whatever address it
+# maps to: pointing an update server at 0.0.0.0 blocks updates as surely as
+# pointing it elsewhere hijacks them. Comments are ignored.
+hosts_entries() {
+    local file="${1:-/etc/hosts}"
+    [ -r "$file" ] || return 0
+    awk -v domains="$WELL_KNOWN_DOMAINS" '
+        BEGIN { n = split(domains, d, " "); for (i = 1; i <= n; i++) known[d[i]] = 1 }
+        function is_known(h,    s) {
+            for (s = h; s != ""; ) {
+                if (s in known) return 1
+                if (index(s, ".") == 0) return 0
+                s = substr(s, index(s, ".") + 1)
+            }
+            return 0
+        }
+        {
+            sub(/#.*/, "")
+            if (NF < 2) next
+            for (i = 2; i <= NF; i++) {
+                h = tolower($i); sub(/\.$/, "", h)
+                printf "%s\t%s\t%s\n", tolower($1), h, (is_known(h) ? "true" : "false")
+            }
+        }' "$file"
+}
+
+# shell_startup_files prints "scope\tpath" for each shell startup file that
+# exists: the system-wide profiles and rc files, the scripts in
+# /etc/profile.d (scope system), and the audited user's dotfiles (scope user).
+shell_startup_files() {
+    local f
+    for f in /etc/profile /etc/bashrc /etc/bash.bashrc /etc/zshenv /etc/zprofile /etc/zshrc /etc/zlogin /etc/zsh/zshenv /etc/zsh/zprofile /etc/zsh/zshrc /etc/zsh/zlogin /etc/profile.d/*.sh /etc/profile.d/*.zsh; do
+        [ -f "$f" ] && printf 'system\t%s\n' "$f"
+    done
+    for f in .profile .bash_profile .bash_login .bashrc .bash_logout .zshenv .zprofile .zshrc .zlogin .zlogout; do
+        [ -f "$HOME_DIR/$f" ] && printf 'user\t%s\n' "$HOME_DIR/$f"
+    done
+    return 0
+}
+
+# shell_startup_findings <file> prints "line\tpattern" for each line of a
+# shell startup file that matches a pattern malware uses to persist:
+#   download_exec        a download piped into a shell or interpreter (curl ... | bash)
+#   remote_eval          downloaded content run through eval, source, ., or sh -c
+#   path_prepend_unsafe  PATH prepended with a temporary or relative directory
+#   path_prepend         PATH prepended with any other directory
+# Only path_prepend is routine (~/.local/bin, Homebrew, pyenv); the others are
+# suspicious. Comment lines are ignored.
+shell_startup_findings() {
+    local file="$1"
+    [ -r "$file" ] || return 0
+    awk '
+        /^[ \t]*#/ { next }
+        /(curl|wget|fetch)[^|]*\|[ \t]*(sudo[ \t]+)?(env[ \t]+)?((ba|da|k|z)?sh|python[0-9.]*|perl|ruby|node)([ \t;&|)]|$)/ {
+            print NR "\tdownload_exec"; next
+        }
+        /(^|[ \t;&|({])(eval|source|\.|(ba|da|k|z)?sh[ \t]+-c)[ \t]+["'"'"']?(\$\(|`|<\()[^)`]*(curl|wget|fetch)/ {
+            print NR "\tremote_eval"; next
+        }
+        /(^|[ \t;&])(export[ \t]+)?PATH=/ {
+            v = $0
+            sub(/.*PATH=/, "", v)
+            gsub(/["'"'"']/, "", v)
+            if (v ~ /^\$(PATH|\{PATH\})/ || v !~ /\$(PATH|\{PATH\})/) next
+            dir = v
+            sub(/:.*/, "", dir)
+            if (dir ~ /^(\/tmp|\/var\/tmp|\/dev\/shm|\/private\/tmp|\/private\/var\/tmp)(\/|$)/ || dir !~ /^(\/|~|\$)/)
+                print NR "\tpath_prepend_unsafe"
+            else
+                print NR "\tpath_prepend"
+        }' "$file"
+}
+
+# watched_dotfiles prints "path\tmode\tsha256" for each file the
+# OSAUDIT_DOTFILES lines name (see internal/dotfiles), with ~/ under $HOME_DIR
+# and globs expanded. mode is octal and includes the setuid, setgid, and
+# sticky bits. A line that names no file prints with mode "-", and an
+# unreadable file with sha256 "-".
+watched_dotfiles() {
+    local entry pattern f found mode sha
+    while IFS= read -r entry; do
+        case "$entry" in ""|\#*) continue ;; esac
+        case "$entry" in "~/"*) pattern="$HOME_DIR/${entry#\~/}" ;; *) pattern="$entry" ;; esac
+        found=false
+        while IFS= read -r f; do
+            [ -f "$f" ] || continue
+            found=true
+            mode="$(stat -L -c %a "$f" 2>/dev/null || echo -)"
+            sha="$(sha256_file "$f")"
+            printf '%s\t%s\t%s\n' "$f" "$mode" "${sha:--}"
+        done < <(compgen -G "$pattern" | sort || true)
+        $found || printf '%s\t-\t-\n' "$pattern"
+    done <<< "${OSAUDIT_DOTFILES:-}" | awk -F'\t' '!seen[$1]++'
+}
+
+# recent_logins <probe prefix> [count] prints "user\ttty\thost\tlogin\tactive"
+# for the most recent logins in wtmp, newest first (default 25). login is
+# ISO-8601 and active is true for a session still logged in. Reboots and
+# shutdowns are left out. An empty host is "-".
+recent_logins() {
+    local probe_prefix="${1:-identity}" count="${2:-25}"
+    command -v last >/dev/null 2>&1 || return 0
+    soft_out_probe "$probe_prefix.last" last -w -n "$count" --time-format iso | awk '
+        NF < 4 || $1 == "reboot" || $1 == "shutdown" || $1 == "wtmp" || $1 == "wtmpdb" { next }
+        {
+            t = 0
+            for (i = 3; i <= NF; i++) if ($i ~ /^[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]T/) { t = i; break }
+            if (!t) next
+            host = (t > 3 ? $3 : "-")
+            printf "%s\t%s\t%s\t%s\t%s\n", $1, $2, host, $t, ($0 ~ /still logged in/ ? "true" : "false")
+        }'
+}
+
+# recent_changes <hours> prints "epoch\tmodified\tpath" for each file modified
+# in the last <hours> hours where intruders drop and persist things: /etc,
+# /usr/local, the temporary directories, cron spools, and each user's SSH,
+# autostart, systemd, and local bin directories and shell startup files. It
+# stays on each directory's filesystem, three levels deep, and skips the
+# output directory. modified is ISO-8601; newest first.
+recent_changes() {
+    local minutes=$(( ${1:-24} * 60 )) user home f
+    local -a roots=(/etc /usr/local/bin /usr/local/sbin /usr/local/lib /tmp /var/tmp /dev/shm /var/spool/cron)
+    while IFS=$'\t' read -r user home; do
+        for f in .ssh .config/autostart .config/systemd .local/bin .profile .bash_profile .bash_login .bashrc .zshenv .zprofile .zshrc .zlogin; do
+            [ -e "$home/$f" ] && roots+=("$home/$f")
+        done
+    done < <(user_homes persistence)
+    find "${roots[@]}" -xdev -maxdepth 3 -type f -mmin "-$minutes" -printf '%T@\t%TY-%Tm-%TdT%TH:%TM:%TS%Tz\t%p\n' 2>/dev/null \
+        | awk -F'\t' -v skip="${AUDIT_OUTPUT_ROOT:-}" '
+            skip != "" && index($3, skip) == 1 { next }
+            { sub(/\..*/, "", $1); sub(/\.[0-9]+/, "", $2); print $1 "\t" $2 "\t" $3 }' \
+        | sort -t "$(printf '\t')" -k1,1nr || true
+}
+
+# linux_vpn_connections <probe prefix> prints
+# "kind\tname\ttype\tinterface\tstate\troutes" for each VPN: NetworkManager
+# VPN and WireGuard profiles and the configs in /etc/wireguard and
+# /etc/openvpn (kind service), then tunnel interfaces, WireGuard, tun/tap,
+# PPP, and IPsec (kind tunnel). state is connected or disconnected. routes
+# lists the destinations a tunnel carries in any routing table, so
+# wg-quick's default route in its own table counts. Empty fields are "-".
+linux_vpn_connections() {
+    local probe_prefix="${1:-network}"
+    local seen="" type device state name iface f unit
+    if command -v nmcli >/dev/null 2>&1; then
+        # NAME last: read leaves any escaped colons in it to the last field.
+        while IFS=: read -r type device state name; do
+            case "$type" in vpn|wireguard) ;; *) continue ;; esac
+            name="${name//\\:/:}"
+            if [ "$type" = "vpn" ]; then
+                type="$(soft_out_probe "${probe_prefix}.nmcli_vpn_type" nmcli -g vpn.service-type connection show "$name" | head -1)"
+                type="${type##*.}"
+            fi
+            [ "$state" = "activated" ] && state="connected" || state="disconnected"
+            seen="$seen|$name|"
+            printf 'service\t%s\t%s\t%s\t%s\t-\n' "$name" "${type:-vpn}" "${device:--}" "$state"
+        done < <(soft_out_probe "${probe_prefix}.nmcli_connections" nmcli -t -f TYPE,DEVICE,STATE,NAME connection show || true)
+    fi
+    for f in /etc/wireguard/*.conf; do
+        [ -f "$f" ] || continue
+        name="$(basename "$f" .conf)"
+        case "$seen" in *"|$name|"*) continue ;; esac
+        seen="$seen|$name|"
+        state="disconnected"
+        [ -e "/sys/class/net/$name" ] && state="connected"
+        printf 'service\t%s\twireguard\t%s\t%s\t-\n' "$name" "$name" "$state"
+    done
+    for f in /etc/openvpn/*.conf /etc/openvpn/client/*.conf /etc/openvpn/server/*.conf; do
+        [ -f "$f" ] || continue
+        name="$(basename "$f" .conf)"
+        case "$seen" in *"|$name|"*) continue ;; esac
+        seen="$seen|$name|"
+        case "$f" in
+            /etc/openvpn/client/*) unit="openvpn-client@$name" ;;
+            /etc/openvpn/server/*) unit="openvpn-server@$name" ;;
+            *) unit="openvpn@$name" ;;
+        esac
+        state="disconnected"
+        command -v systemctl >/dev/null 2>&1 && systemctl is-active --quiet "$unit" 2>/dev/null && state="connected"
+        printf 'service\t%s\topenvpn\t-\t%s\t-\n' "$name" "$state"
+    done
+    command -v ip >/dev/null 2>&1 || return 0
+    local routes
+    while IFS=$'\t' read -r iface type state; do
+        [ -n "$iface" ] || continue
+        routes="$( { ip -o route show table all dev "$iface" 2>/dev/null || true; ip -o -6 route show table all dev "$iface" 2>/dev/null || true; } | awk '
+            $1 ~ /^(local|broadcast|multicast|anycast|unreachable|prohibit|blackhole|throw)$/ { next }
+            $1 ~ /^(fe80:|ff[0-9a-f][0-9a-f]:)/ { next }
+            { print $1 }
+        ' | sort -u | tr '\n' ' ')"
+        routes="${routes% }"
+        printf 'tunnel\t%s\t%s\t%s\t%s\t%s\n' "$iface" "$type" "$iface" "$state" "${routes:--}"
+    done < <(soft_out_probe "${probe_prefix}.ip_link_details" ip -d -o link show | awk '
+        {
+            name = $2; sub(/:$/, "", name); sub(/@.*/, "", name); kind = ""
+            for (i = 3; i <= NF; i++) {
+                if ($i == "wireguard") kind = "wireguard"
+                else if ($i == "tun" && $(i + 1) == "type") kind = $(i + 2)
+                else if ($i == "link/ppp") kind = "ppp"
+                else if ($i == "xfrm" || $i == "vti" || $i == "vti6") kind = "ipsec"
+            }
+            if (kind == "") next
+            printf "%s\t%s\t%s\n", name, kind, ($3 ~ /[<,]UP[,>]/ ? "connected" : "disconnected")
+        }
+    ' || true)
+}
+
+# linux_neighbors <probe prefix> prints "family\tip\tmac\tinterface\tstate\tgateway"
+# for each resolved entry of the ARP (ipv4) and neighbor discovery (ipv6)
+# caches, from ip neigh or, without iproute2, /proc/net/arp. state is the
+# kernel's, lowercased (reachable, stale, permanent, ...), or complete for a
+# dynamic entry of /proc/net/arp, which has no finer state. gateway is true for
+# the next hop of a default route on that interface.
+linux_neighbors() {
+    local probe_prefix="${1:-network}" gateways="" iface dest gw flags _
+    if command -v ip >/dev/null 2>&1; then
+        gateways="$({
+            soft_out_probe "${probe_prefix}.ip_route_default" ip -4 route show default
+            soft_out_probe "${probe_prefix}.ip6_route_default" ip -6 route show default
+        } | awk '{
+            via = ""; dev = ""
+            for (i = 1; i < NF; i++) { if ($i == "via") via = $(i + 1); if ($i == "dev") dev = $(i + 1) }
+            if (via != "" && dev != "") printf "%s%%%s ", via, dev
+        }')"
+        soft_out_probe "${probe_prefix}.ip_neigh" ip neigh show | awk -v gws="$gateways" '
+            BEGIN { n = split(gws, g, " "); for (i = 1; i <= n; i++) isgw[g[i]] = 1 }
+            {
+                ip = $1; dev = ""; mac = ""; state = tolower($NF)
+                for (i = 2; i < NF; i++) { if ($i == "dev") dev = $(i + 1); if ($i == "lladdr") mac = tolower($(i + 1)) }
+                if (mac == "" || state == "failed" || state == "incomplete") next
+                print ((ip ~ /:/) ? "ipv6" : "ipv4") "\t" ip "\t" mac "\t" dev "\t" state "\t" (((ip "%" dev) in isgw) ? "true" : "false")
+            }'
+        return
+    fi
+    [ -r /proc/net/arp ] || return 0
+    # /proc/net/route holds addresses as little-endian hex.
+    while read -r iface dest gw flags _; do
+        [ "$dest" = 00000000 ] && [ "$gw" != 00000000 ] || continue
+        gateways+="$(printf '%d.%d.%d.%d' "0x${gw:6:2}" "0x${gw:4:2}" "0x${gw:2:2}" "0x${gw:0:2}")%$iface "
+    done < <(tail -n +2 /proc/net/route 2>/dev/null || true)
+    # /proc/net/arp: IP address, HW type, Flags (0x2 complete, 0x4 permanent), HW address, Mask, Device.
+    awk -v gws="$gateways" '
+        BEGIN { n = split(gws, g, " "); for (i = 1; i <= n; i++) isgw[g[i]] = 1 }
+        NR > 1 && $3 != "0x0" && $4 != "00:00:00:00:00:00" {
+            print "ipv4\t" $1 "\t" tolower($4) "\t" $6 "\t" (($3 == "0x6") ? "permanent" : "complete") "\t" ((($1 "%" $6) in isgw) ? "true" : "false")
+        }' /proc/net/arp
+}
+
+# linux_routes <probe prefix> prints "family\tdestination\tgateway\tinterface\tmetric\tprotocol\ttype"
+# for each route of the main routing table, from ip route or, without
+# iproute2, the IPv4 routes of /proc/net/route. Destinations are prefixes:
+# default is 0.0.0.0/0 or ::/0 and host routes get /32 or /128. type is
+# unicast or the kernel's route type (blackhole, unreachable, local, ...);
+# protocol is what installed the route (kernel, dhcp, static, ra, boot, ...).
+# Multipath routes list their next hops comma-separated. Empty fields are "-".
+linux_routes() {
+    local probe_prefix="${1:-network}" iface dest gw flags _ metric mask bits type i
+    if command -v ip >/dev/null 2>&1; then
+        {
+            soft_out_probe "${probe_prefix}.ip_route" ip -o -4 route show table main | sed 's/^/ipv4 /'
+            soft_out_probe "${probe_prefix}.ip6_route" ip -o -6 route show table main | sed 's/^/ipv6 /'
+        } | awk '
+            BEGIN { split("unicast blackhole unreachable prohibit throw local broadcast multicast anycast nat", t, " "); for (i in t) types[t[i]] = 1 }
+            {
+                family = $1; i = 2; type = "unicast"
+                if ($i in types) { type = $i; i++ }
+                dest = $i
+                if (dest == "default") dest = (family == "ipv4") ? "0.0.0.0/0" : "::/0"
+                else if (dest !~ /\//) dest = dest ((family == "ipv4") ? "/32" : "/128")
+                via = ""; dev = ""; metric = 0; proto = "boot"
+                for (i++; i < NF; i++) {
+                    if ($i == "via") { v = $(i + 1); if (v == "inet" || v == "inet6") v = $(i + 2); via = via (via == "" ? "" : ",") v }
+                    else if ($i == "dev" && index("," dev ",", "," $(i + 1) ",") == 0) dev = dev (dev == "" ? "" : ",") $(i + 1)
+                    else if ($i == "metric") metric = $(i + 1)
+                    else if ($i == "proto") proto = $(i + 1)
+                }
+                printf "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", family, dest, (via == "" ? "-" : via), (dev == "" ? "-" : dev), metric + 0, proto, type
+            }'
+        return
+    fi
+    [ -r /proc/net/route ] || return 0
+    # /proc/net/route: Iface, Destination, Gateway, Flags, RefCnt, Use, Metric,
+    # Mask, ... with addresses as little-endian hex. Flag 0x0200 is RTF_REJECT.
+    while read -r iface dest gw flags _ _ metric mask _; do
+        bits=0
+        for (( i = 0; i < 8; i++ )); do
+            case "${mask:$i:1}" in
+                F) bits=$((bits + 4)) ;; E) bits=$((bits + 3)) ;; C) bits=$((bits + 2)) ;; 8) bits=$((bits + 1)) ;;
+            esac
+        done
+        type=unicast
+        (( 0x$flags & 0x0200 )) && type=unreachable
+        if [ "$gw" = 00000000 ]; then
+            gw="-"
+        else
+            gw="$(printf '%d.%d.%d.%d' "0x${gw:6:2}" "0x${gw:4:2}" "0x${gw:2:2}" "0x${gw:0:2}")"
+        fi
+        printf 'ipv4\t%d.%d.%d.%d/%d\t%s\t%s\t%d\t-\t%s\n' \
+            "0x${dest:6:2}" "0x${dest:4:2}" "0x${dest:2:2}" "0x${dest:0:2}" "$bits" "$gw" "$iface" "$metric" "$type"
+    done < <(tail -n +2 /proc/net/route 2>/dev/null || true)
+}
+
+# linux_firmware <probe prefix> prints "type\tvendor\tversion\tdate\tsecure_boot\tsetup_mode"
+# for the platform firmware. type is uefi or bios; vendor, version, and date
+# are the DMI BIOS strings. secure_boot and setup_mode are read from the UEFI
+# SecureBoot and SetupMode variables, falling back to mokutil; setup mode
+# means no platform key is enrolled, so Secure Boot enforces nothing. Fields
+# that cannot be read are "-".
+linux_firmware() {
+    local probe_prefix="${1:-config}"
+    local type=bios vendor version date secure_boot="-" setup_mode="-" out
+    local efivars=/sys/firmware/efi/efivars guid=8be4df61-93ca-11d2-aa0d-00e098032b8c
+    [ -d /sys/firmware/efi ] && type=uefi
+    vendor="$(cat /sys/class/dmi/id/bios_vendor 2>/dev/null || true)"
+    version="$(cat /sys/class/dmi/id/bios_version 2>/dev/null || true)"
+    date="$(cat /sys/class/dmi/id/bios_date 2>/dev/null || true)"
+    # An efivar is 4 bytes of attributes, then the value.
+    if [ -r "$efivars/SecureBoot-$guid" ]; then
+        case "$(od -An -tu1 -j4 -N1 "$efivars/SecureBoot-$guid" 2>/dev/null | tr -d ' ')" in
+            1) secure_boot=true ;;
+            0) secure_boot=false ;;
+        esac
+    fi
+    if [ -r "$efivars/SetupMode-$guid" ]; then
+        case "$(od -An -tu1 -j4 -N1 "$efivars/SetupMode-$guid" 2>/dev/null | tr -d ' ')" in
+            1) setup_mode=true ;;
+            0) setup_mode=false ;;
+        esac
+    fi
+    if [ "$secure_boot" = "-" ] && command -v mokutil >/dev/null 2>&1; then
+        out="$(soft_out_probe "${probe_prefix}.mokutil_sb" mokutil --sb-state)"
+        case "$out" in
+            *"SecureBoot enabled"*) secure_boot=true ;;
+            *"SecureBoot disabled"*) secure_boot=false ;;
+        esac
+        case "$out" in *"Setup Mode"*) setup_mode=true ;; esac
+    fi
+    [ "$type" = uefi ] || secure_boot=false
+    printf '%s\t%s\t%s\t%s\t%s\t%s\n' "$type" "${vendor:--}" "${version:--}" "${date:--}" "$secure_boot" "$setup_mode"
+}
+
+# linux_time_sync <probe prefix> prints "service\tenabled\tsynchronized\toffset_ms\tservers"
+# for the clock's time synchronization. service is the NTP daemon that runs
+# (chronyd, systemd-timesyncd, ntpd, openntpd) or "none"; enabled is
+# timedatectl's NTP switch, or whether a daemon runs where there is no
+# timedatectl; synchronized is whether the kernel clock is in sync, "-" when
+# unknown. offset_ms is the last measured offset from the daemon's source
+# in milliseconds, by NTP convention positive when the local clock is behind,
+# "-" when the daemon does not report one. servers are the configured servers and pools,
+# space-separated, "-" when none.
+linux_time_sync() {
+    local probe_prefix="${1:-config}"
+    local service="none" enabled=false synchronized="-" offset="-" servers="" out unit
+    for unit in chronyd chrony systemd-timesyncd ntpd ntp ntpsec openntpd; do
+        if _systemctl_any_active "$unit"; then
+            service="$unit"
+            break
+        fi
+    done
+    if [ "$service" = "none" ] && ! command -v systemctl >/dev/null 2>&1; then
+        for unit in chronyd systemd-timesyncd ntpd openntpd; do
+            if pgrep -x "$unit" >/dev/null 2>&1; then
+                service="$unit"
+                break
+            fi
+        done
+    fi
+    case "$service" in
+        chrony) service="chronyd" ;;
+        ntp|ntpsec) service="ntpd" ;;
+    esac
+    if command -v timedatectl >/dev/null 2>&1; then
+        out="$(soft_out_probe "${probe_prefix}.timedatectl_show" timedatectl show)"
+        printf '%s\n' "$out" | grep -qx 'NTP=yes' && enabled=true
+        case "$(printf '%s\n' "$out" | awk -F= '$1 == "NTPSynchronized" { print $2 }')" in
+            yes) synchronized=true ;;
+            no) synchronized=false ;;
+        esac
+    elif [ "$service" != "none" ]; then
+        enabled=true
+    fi
+    case "$service" in
+        chronyd)
+            if command -v chronyc >/dev/null 2>&1; then
+                # "System time     : 0.000012345 seconds fast of NTP time"
+                offset="$(soft_out_probe "${probe_prefix}.chronyc_tracking" chronyc -n tracking | awk -F': *' '
+                    $1 ~ /^System time/ { split($2, f, " "); ms = f[1] * 1000; if (f[3] == "fast") ms = -ms; printf "%.3f\n", ms }')"
+            fi
+            servers="$(awk '$1 == "server" || $1 == "pool" { print $2 }' /etc/chrony.conf /etc/chrony/chrony.conf /etc/chrony/sources.d/*.sources 2>/dev/null | tr '\n' ' ')"
+            ;;
+        systemd-timesyncd)
+            # "Offset: +1.234ms"; timesyncd prints us, ms, s, or min.
+            offset="$(soft_out_probe "${probe_prefix}.timesync_status" timedatectl timesync-status | awk '
+                $1 == "Offset:" {
+                    v = $2; unit = v; sub(/^[-+]?[0-9.]+/, "", unit); v = substr(v, 1, length(v) - length(unit)) + 0
+                    if (unit == "us") v /= 1000; else if (unit == "s") v *= 1000; else if (unit == "min") v *= 60000
+                    printf "%.3f\n", v
+                }')"
+            servers="$(soft_out_probe "${probe_prefix}.timesync_servers" timedatectl show-timesync -p SystemNTPServers -p LinkNTPServers -p FallbackNTPServers --value | tr '\n' ' ')"
+            ;;
+        ntpd)
+            if command -v ntpq >/dev/null 2>&1; then
+                # "offset=0.123456" in milliseconds.
+                offset="$(soft_out_probe "${probe_prefix}.ntpq_offset" ntpq -c "rv 0 offset" | awk -F= '$1 == "offset" { printf "%.3f\n", $2 }')"
+            fi
+            servers="$(awk '$1 == "server" || $1 == "pool" { print $2 }' /etc/ntp.conf /etc/ntpsec/ntp.conf 2>/dev/null | tr '\n' ' ')"
+            ;;
+        openntpd)
+            servers="$(awk '$1 == "server" || $1 == "servers" { print $2 }' /etc/openntpd/ntpd.conf /etc/ntpd.conf 2>/dev/null | tr '\n' ' ')"
+            ;;
+    esac
+    servers="$(printf '%s\n' "$servers" | tr ' ' '\n' | awk 'NF && !seen[$0]++' | tr '\n' ' ')"
+    servers="${servers% }"
+    printf '%s\t%s\t%s\t%s\t%s\n' "$service" "$enabled" "$synchronized" "${offset:--}" "${servers:--}"
+}
+
+# linux_disk_volumes <probe prefix> prints "mount\tdevice\tfstype\tencryption\tremovable"
+# for every mounted block-device volume and ZFS dataset, once per device (bind
+# mounts repeat it). encryption is luks when a LUKS container sits under the
+# device, dm-crypt for plain dm-crypt, zfs for a natively encrypted dataset,
+# none otherwise, and "-" when lsblk cannot tell. removable is true for
+# hot-pluggable devices (USB and other external drives).
+linux_disk_volumes() {
+    local probe_prefix="${1:-config}"
+    local target source fstype seen="" enc removable chain
+    while IFS=' ' read -r target source fstype; do
+        [ -n "$target" ] || continue
+        target="$(printf '%b' "${target//\\040/\\x20}")"
+        source="${source%%\[*}"
+        case "$fstype" in squashfs|iso9660|udf|swap) continue ;; esac
+        case "$source" in
+            /dev/*) ;;
+            *) [ "$fstype" = zfs ] || continue ;;
+        esac
+        case "$seen" in *"|$source|"*) continue ;; esac
+        seen="$seen|$source|"
+        enc="-"
+        removable=false
+        if [ "$fstype" = zfs ]; then
+            if command -v zfs >/dev/null 2>&1; then
+                case "$(soft_out_probe "${probe_prefix}.zfs_encryption" zfs get -H -o value encryption "$source")" in
+                    off) enc=none ;;
+                    "") ;;
+                    *) enc=zfs ;;
+                esac
+            fi
+        elif command -v lsblk >/dev/null 2>&1; then
+            # -s lists the device and everything it is built on, so a
+            # filesystem on LVM on LUKS still shows the crypt layer.
+            chain="$(lsblk -rpnso NAME,TYPE,FSTYPE,HOTPLUG "$source" 2>/dev/null || true)"
+            if [ -n "$chain" ]; then
+                enc="$(printf '%s\n' "$chain" | awk '
+                    $3 == "crypto_LUKS" { luks = 1 }
+                    $2 == "crypt" { crypt = 1 }
+                    END { print luks ? "luks" : (crypt ? "dm-crypt" : "none") }')"
+                printf '%s\n' "$chain" | awk '$NF == "1" { found = 1 } END { exit found ? 0 : 1 }' && removable=true
+            fi
+        fi
+        printf '%s\t%s\t%s\t%s\t%s\n' "$target" "$source" "$fstype" "$enc" "$removable"
+    done < <(if command -v findmnt >/dev/null 2>&1; then
+        soft_out_probe "${probe_prefix}.findmnt" findmnt -rno TARGET,SOURCE,FSTYPE | sed 's/\\x20/\\040/g'
+    else
+        awk '{ print $2, $1, $3 }' /proc/mounts 2>/dev/null
+    fi)
+}
+
+# usb_class_names reads "vendor_id\tproduct_id\tvendor\tproduct\tserial\tcodes"
+# lines, codes being the space-separated hex USB class codes of a device and
+# its interfaces, and prints them with codes replaced by the comma-separated,
+# sorted class names, or "-" when none is known. Class 00 means "see the
+# interfaces" and is dropped.
+usb_class_names() {
+    awk -F'\t' -v OFS='\t' '
+        BEGIN {
+            split("01 audio 02 communications 03 hid 05 physical 06 image 07 printer 08 mass_storage 09 hub 0a cdc_data 0b smart_card 0d content_security 0e video 0f healthcare 10 audio_video 11 billboard 12 type_c_bridge dc diagnostic e0 wireless ef miscellaneous fe application_specific ff vendor_specific", t, " ")
+            for (i = 1; i in t; i += 2) name[t[i]] = t[i + 1]
+        }
+        {
+            n = split(tolower($6), codes, " ")
+            delete seen
+            list = ""
+            for (i = 1; i <= n; i++) {
+                c = codes[i]
+                if (c == "00" || c == "" || (c in seen)) continue
+                seen[c] = 1
+                cls = (c in name) ? name[c] : "class_" c
+                # Insertion sort keeps the list stable between runs.
+                m = split(list, cur, ",")
+                list = ""
+                placed = 0
+                for (j = 1; j <= m; j++) {
+                    if (!placed && cls < cur[j]) { list = list (list == "" ? "" : ",") cls; placed = 1 }
+                    list = list (list == "" ? "" : ",") cur[j]
+                }
+                if (!placed) list = list (list == "" ? "" : ",") cls
+            }
+            $6 = (list == "" ? "-" : list)
+            print
+        }'
+}
+
+# usb_attr <file> prints a sysfs USB attribute on one line, or "-".
+usb_attr() {
+    local value
+    value="$(tr -d '\t\r\n' 2>/dev/null < "$1" || true)"
+    printf '%s' "${value:--}"
+}
+
+# usb_devices prints "vendor_id\tproduct_id\tvendor\tproduct\tserial\tclasses"
+# for each attached USB device except the root hubs, sorted, from sysfs.
+# vendor_id and product_id are 4-digit hex; classes are usb_class_names'.
+# Unknown fields are "-".
+usb_devices() {
+    local root=/sys/bus/usb/devices dev name
+    [ -d "$root" ] || return 0
+    for dev in "$root"/*; do
+        name="${dev##*/}"
+        case "$name" in usb*|*:*) continue ;; esac
+        [ -r "$dev/idVendor" ] || continue
+        printf '%s\t%s\t%s\t%s\t%s\t%s\n' "$(usb_attr "$dev/idVendor")" "$(usb_attr "$dev/idProduct")" \
+            "$(usb_attr "$dev/manufacturer")" "$(usb_attr "$dev/product")" "$(usb_attr "$dev/serial")" \
+            "$(cat "$dev/bDeviceClass" "$dev/$name":*/bInterfaceClass 2>/dev/null | tr '\n' ' ' || true)"
+    done | usb_class_names | sort
+}
+
+# proxy_settings <probe prefix> prints "source\tscheme\tvalue" for each proxy
+# the audited session uses: the *_proxy environment variables (source
+# environment; no_proxy is scheme "no") and GNOME's system proxy (source
+# gnome; scheme pac for an autoconfig URL). Credentials in proxy URLs are
+# dropped.
+proxy_settings() {
+    local prefix="${1:-network}" name value mode scheme host port
+    {
+        for name in http_proxy https_proxy ftp_proxy all_proxy no_proxy; do
+            value="$(printenv "$name" 2>/dev/null || printenv "$(printf '%s' "$name" | tr '[:lower:]' '[:upper:]')" 2>/dev/null || true)"
+            [ -n "$value" ] || continue
+            printf 'environment\t%s\t%s\n' "${name%_proxy}" "$value"
+        done
+        # Without GNOME's schemas installed there is nothing to read.
+        if command -v gsettings >/dev/null 2>&1 && gsettings list-schemas 2>/dev/null | grep -x org.gnome.system.proxy >/dev/null; then
+            mode="$(soft_out_probe "${prefix}.gsettings_proxy" gsettings get org.gnome.system.proxy mode | tr -d "'")"
+            case "$mode" in
+                manual)
+                    for scheme in http https ftp socks; do
+                        host="$(gsettings get "org.gnome.system.proxy.$scheme" host 2>/dev/null | tr -d "'" || true)"
+                        port="$(gsettings get "org.gnome.system.proxy.$scheme" port 2>/dev/null || true)"
+                        if [ -n "$host" ]; then
+                            printf 'gnome\t%s\t%s:%s\n' "$scheme" "$host" "${port:-0}"
+                        fi
+                    done
+                    ;;
+                auto)
+                    printf 'gnome\tpac\t%s\n' "$(gsettings get org.gnome.system.proxy autoconfig-url 2>/dev/null | tr -d "'" || true)"
+                    ;;
+            esac
+        fi
+    } | sed 's#://[^/@]*@#://#'
+}
+
+# smartctl_summary reads `smartctl -i -H -A` output on stdin and prints
+# "model\thealth\treallocated\tpending\twear\tfailing". health is passed or
+# failed; reallocated and pending are the raw sector counts (ATA attributes 5
+# and 197, or SCSI's grown defect list); wear is the percentage of rated life
+# used; failing lists the attributes at or past their failure threshold now,
+# comma-separated. Unknown fields are "-".
+smartctl_summary() {
+    awk -F': *' '
+        function add(v) { failing = failing (failing == "" ? "" : ",") v }
+        function set_wear(used) { if (used < 0) used = 0; if (used > 100) used = 100; wear = used }
+        $1 ~ /^(Device Model|Model Number|Product)$/ && model == "" { model = $2 }
+        $1 ~ /overall-health self-assessment test result$/ { health = ($2 ~ /^PASSED/) ? "passed" : "failed" }
+        $1 == "SMART Health Status" { health = ($2 ~ /^OK/) ? "passed" : "failed" }
+        $1 == "Percentage Used" { v = $2; sub(/%.*/, "", v); set_wear(v + 0) }
+        $1 == "Critical Warning" && $2 !~ /^0x00/ { add("critical_warning") }
+        $1 == "Elements in grown defect list" { reallocated = $2 + 0 }
+        {
+            split($0, f, " ")
+            if (f[1] !~ /^[0-9]+$/ || f[10] == "") next
+            raw = f[10]; sub(/[^0-9].*/, "", raw)
+            if (f[1] == 5) reallocated = raw + 0
+            if (f[1] == 197) pending = raw + 0
+            if (f[1] == 177 || f[1] == 202 || f[1] == 231 || f[1] == 233) set_wear(100 - f[4])
+            if (f[9] == "FAILING_NOW") add(f[2])
+        }
+        END {
+            printf "%s\t%s\t%s\t%s\t%s\t%s\n", (model == "" ? "-" : model), (health == "" ? "-" : health), (reallocated == "" ? "-" : reallocated), (pending == "" ? "-" : pending), (wear == "" ? "-" : wear), (failing == "" ? "-" : failing)
+        }'
+}
+
+# linux_disk_health <probe prefix> prints "device\tprotocol\tmodel\thealth\treallocated\tpending\twear\tfailing"
+# for every physical disk smartctl finds, with the fields of smartctl_summary.
+# Reading SMART data needs root; callers check for it and for smartctl first.
+linux_disk_health() {
+    local probe_prefix="${1:-storage}"
+    local device protocol out code
+    while read -r device _ protocol _; do
+        case "$device" in /dev/*) ;; *) continue ;; esac
+        # smartctl's exit status is a bit mask: bits 0 and 1 mean the device
+        # could not be read, the others report what the disk itself says.
+        code=0
+        out="$(smartctl -i -H -A -d "$protocol" "$device" 2>/dev/null)" || code=$?
+        if (( code & 3 )); then
+            record_soft_failure "soft_out_probe:${probe_prefix}.smartctl:smartctl $device"
+            emit_probe_failed "${probe_prefix}.smartctl" "$code" smartctl
+            continue
+        fi
+        printf '%s\t%s\t%s\n' "$device" "$protocol" "$(printf '%s\n' "$out" | smartctl_summary)"
+    done < <(soft_out_probe "${probe_prefix}.smartctl_scan" smartctl --scan)
+}
+
 parse_ss_listening_tcp() {
     awk '
         function port_from_local(local, p) {
@@ -340,6 +2505,9 @@ _linux_firewall_record_backend() {
     local service_enabled="$2"
     local service_active="$3"
     local rules_active="$4"
+    local input_policy="${5:-unknown}"
+    local zone="${6:-}"
+    local open_ports="${7:-}"
 
     if [ -z "${FIREWALL_BACKENDS:-}" ]; then
         FIREWALL_BACKENDS="$backend"
@@ -365,9 +2533,105 @@ _linux_firewall_record_backend() {
         FIREWALL_PRIMARY_SERVICE_ENABLED="$service_enabled"
         FIREWALL_PRIMARY_SERVICE_ACTIVE="$service_active"
         FIREWALL_PRIMARY_RULES_ACTIVE="$rules_active"
+        FIREWALL_DEFAULT_INPUT_POLICY="$input_policy"
+        FIREWALL_ZONE="$zone"
+        FIREWALL_OPEN_PORTS="$open_ports"
     fi
 }
 
+# _nft_input_summary reads `nft list ruleset` on stdin and prints the input
+# hook's default policy on the first line, then one "port/proto" per line for
+# each port an input chain accepts.
+_nft_input_summary() {
+    awk '
+        /^[[:space:]]*chain / { in_chain = 1; is_input = 0; next }
+        in_chain && /hook input/ {
+            is_input = 1
+            if (match($0, /policy [a-z]+/)) {
+                p = substr($0, RSTART + 7, RLENGTH - 7)
+                if (policy == "" || p != "accept") policy = p
+            }
+            next
+        }
+        in_chain && /^[[:space:]]*}[[:space:]]*$/ { in_chain = 0; is_input = 0; next }
+        is_input && / dport / && / accept/ {
+            proto = "any"
+            if (match($0, /(tcp|udp) dport/)) proto = substr($0, RSTART, 3)
+            rest = $0
+            sub(/.* dport /, "", rest)
+            if (substr(rest, 1, 1) == "{") {
+                sub(/^{ */, "", rest); sub(/ *}.*/, "", rest)
+            } else {
+                sub(/ .*/, "", rest)
+            }
+            n = split(rest, ports, /, */)
+            for (i = 1; i <= n; i++) if (ports[i] != "") out[ports[i] "/" proto] = 1
+        }
+        END {
+            print (policy == "" ? "unknown" : policy)
+            for (k in out) print k
+        }
+    '
+}
+
+# _iptables_input_summary reads `iptables -S` on stdin and prints the INPUT
+# chain policy on the first line, then one "port/proto" per line for each
+# port an INPUT rule accepts.
+_iptables_input_summary() {
+    awk '
+        $1 == "-P" && $2 == "INPUT" { policy = tolower($3) }
+        $1 == "-A" && $2 == "INPUT" && / -j ACCEPT/ && /--dports? / {
+            proto = "any"
+            ports = ""
+            for (i = 3; i < NF; i++) {
+                if ($i == "-p") proto = $(i + 1)
+                if ($i == "--dport" || $i == "--dports") ports = $(i + 1)
+            }
+            n = split(ports, list, ",")
+            for (j = 1; j <= n; j++) out[list[j] "/" proto] = 1
+        }
+        END {
+            print (policy == "" ? "unknown" : policy)
+            for (k in out) print k
+        }
+    '
+}
+
+# _ufw_input_summary reads `ufw status verbose` on stdin and prints the
+# incoming default policy on the first line, then one "port/proto" per line
+# for each port or application profile ("OpenSSH/app") allowed in.
+_ufw_input_summary() {
+    awk '
+        /^Default:/ && match($0, /[a-z]+ \(incoming\)/) {
+            policy = substr($0, RSTART, RLENGTH)
+            sub(/ .*/, "", policy)
+        }
+        /ALLOW IN|ALLOW[[:space:]]/ && $1 != "Default:" {
+            to = $0
+            sub(/[[:space:]]+ALLOW.*/, "", to)
+            sub(/[[:space:]]*\(v6\)$/, "", to)
+            gsub(/^[[:space:]]+|[[:space:]]+$/, "", to)
+            if (to == "" || to == "Anywhere") next
+            if (to ~ /^[0-9][0-9:,]*\/(tcp|udp)$/) { out[to] = 1 }
+            else if (to ~ /^[0-9][0-9:,]*$/) { out[to "/any"] = 1 }
+            else { out[to "/app"] = 1 }
+        }
+        END {
+            print (policy == "" ? "unknown" : policy)
+            for (k in out) print k
+        }
+    '
+}
+
+# _firewall_summary_lines turns a summary printed by one of the helpers above
+# into one "policy<TAB>ports" line, ports sorted and space-separated.
+_firewall_summary_lines() {
+    local policy ports
+    IFS= read -r policy || policy=""
+    ports="$(sed '/^$/d' | sort -u | tr '\n' ' ' | sed 's/ $//')"
+    printf '%s\t%s\n' "${policy:-unknown}" "$ports"
+}
+
 detect_linux_firewall_status() {
     local probe_prefix="${1:-linux}"
     FIREWALL_BACKEND="unknown"
@@ -378,14 +2642,20 @@ detect_linux_firewall_status() {
     FIREWALL_PRIMARY_SERVICE_ENABLED=false
     FIREWALL_PRIMARY_SERVICE_ACTIVE=false
     FIREWALL_PRIMARY_RULES_ACTIVE=false
+    FIREWALL_DEFAULT_INPUT_POLICY="unknown"
+    FIREWALL_ZONE=""
+    FIREWALL_OPEN_PORTS=""
+    FIREWALL_DEFAULT_DENY_INCOMING=false
+    local fw_policy fw_ports fw_zone
 
     if command -v ufw >/dev/null 2>&1; then
         local ufw_out ufw_service_enabled=false ufw_service_active=false ufw_rules_active=false
-        ufw_out="$(soft_out_probe "${probe_prefix}.ufw_status" ufw status 2>/dev/null)"
+        ufw_out="$(soft_out_probe "${probe_prefix}.ufw_status" ufw status verbose 2>/dev/null)"
         _systemctl_any_enabled ufw ufw.service && ufw_service_enabled=true
         _systemctl_any_active ufw ufw.service && ufw_service_active=true
         echo "$ufw_out" | grep -Eqi '^Status:[[:space:]]+active' && ufw_rules_active=true
-        _linux_firewall_record_backend "ufw" "$ufw_service_enabled" "$ufw_service_active" "$ufw_rules_active"
+        IFS=$'\t' read -r fw_policy fw_ports <<< "$(printf '%s\n' "$ufw_out" | _ufw_input_summary | _firewall_summary_lines)"
+        _linux_firewall_record_backend "ufw" "$ufw_service_enabled" "$ufw_service_active" "$ufw_rules_active" "$fw_policy" "" "$fw_ports"
     fi
 
     if command -v firewall-cmd >/dev/null 2>&1; then
@@ -398,7 +2668,25 @@ detect_linux_firewall_status() {
         elif _systemctl_any_active firewalld firewalld.service; then
             firewalld_service_active=true
         fi
-        _linux_firewall_record_backend "firewalld" "$firewalld_service_enabled" "$firewalld_service_active" "$firewalld_rules_active"
+        fw_policy="unknown" fw_ports="" fw_zone=""
+        if [ "$firewalld_rules_active" = true ]; then
+            local fw_target fw_services
+            fw_zone="$(soft_out_probe "${probe_prefix}.firewalld_zone" firewall-cmd --get-default-zone 2>/dev/null | head -n 1)"
+            if [ -n "$fw_zone" ]; then
+                # firewalld's "default" target rejects unmatched packets.
+                fw_target="$(soft_out_probe "${probe_prefix}.firewalld_target" firewall-cmd --permanent --zone="$fw_zone" --get-target 2>/dev/null | head -n 1)"
+                case "$fw_target" in
+                    default|REJECT|%%REJECT%%) fw_policy="reject" ;;
+                    DROP) fw_policy="drop" ;;
+                    ACCEPT) fw_policy="accept" ;;
+                esac
+                fw_ports="$(soft_out_probe "${probe_prefix}.firewalld_ports" firewall-cmd --zone="$fw_zone" --list-ports 2>/dev/null)"
+                fw_services="$(soft_out_probe "${probe_prefix}.firewalld_services" firewall-cmd --zone="$fw_zone" --list-services 2>/dev/null)"
+                fw_ports="$(printf '%s %s\n' "$fw_ports" "$(printf '%s\n' "$fw_services" | tr ' ' '\n' | sed '/^$/d; s|$|/service|' | tr '\n' ' ')" \
+                    | tr ' ' '\n' | sed '/^$/d' | sort -u | tr '\n' ' ' | sed 's/ $//')"
+            fi
+        fi
+        _linux_firewall_record_backend "firewalld" "$firewalld_service_enabled" "$firewalld_service_active" "$firewalld_rules_active" "$fw_policy" "$fw_zone" "$fw_ports"
     fi
 
     if command -v nft >/dev/null 2>&1; then
@@ -407,12 +2695,15 @@ detect_linux_firewall_status() {
         _systemctl_any_enabled nftables nftables.service && nft_service_enabled=true
         _systemctl_any_active nftables nftables.service && nft_service_active=true
         [ -n "$nft_out" ] && nft_rules_active=true
-        _linux_firewall_record_backend "nftables" "$nft_service_enabled" "$nft_service_active" "$nft_rules_active"
+        IFS=$'\t' read -r fw_policy fw_ports <<< "$(printf '%s\n' "$nft_out" | _nft_input_summary | _firewall_summary_lines)"
+        _linux_firewall_record_backend "nftables" "$nft_service_enabled" "$nft_service_active" "$nft_rules_active" "$fw_policy" "" "$fw_ports"
     fi
 
     if command -v iptables >/dev/null 2>&1; then
         local ipt_rules ip6t_rules iptables_service_enabled=false iptables_service_active=false iptables_rules_active=false
-        ipt_rules="$(soft_out_probe "${probe_prefix}.iptables_rules" iptables -S 2>/dev/null | awk '$1 == "-A" {c++} END {print c+0}')"
+        local ipt_out
+        ipt_out="$(soft_out_probe "${probe_prefix}.iptables_rules" iptables -S 2>/dev/null)"
+        ipt_rules="$(printf '%s\n' "$ipt_out" | awk '$1 == "-A" {c++} END {print c+0}')"
         ip6t_rules=0
         if command -v ip6tables >/dev/null 2>&1; then
             ip6t_rules="$(soft_out_probe "${probe_prefix}.ip6tables_rules" ip6tables -S 2>/dev/null | awk '$1 == "-A" {c++} END {print c+0}')"
@@ -422,8 +2713,14 @@ detect_linux_firewall_status() {
         if [ "${ipt_rules:-0}" -gt 0 ] 2>/dev/null || [ "${ip6t_rules:-0}" -gt 0 ] 2>/dev/null; then
             iptables_rules_active=true
         fi
-        _linux_firewall_record_backend "iptables" "$iptables_service_enabled" "$iptables_service_active" "$iptables_rules_active"
+        IFS=$'\t' read -r fw_policy fw_ports <<< "$(printf '%s\n' "$ipt_out" | _iptables_input_summary | _firewall_summary_lines)"
+        _linux_firewall_record_backend "iptables" "$iptables_service_enabled" "$iptables_service_active" "$iptables_rules_active" "$fw_policy" "" "$fw_ports"
     fi
+
+    FIREWALL_DEFAULT_DENY_INCOMING=false
+    case "$FIREWALL_DEFAULT_INPUT_POLICY" in
+        drop|reject|deny) [ "$FIREWALL_PRIMARY_RULES_ACTIVE" = true ] && FIREWALL_DEFAULT_DENY_INCOMING=true ;;
+    esac
 }
 
 emit_run_context() {
@@ -550,11 +2847,12 @@ record_soft_failure() {
 # message: optional 5th arg; first line of stderr (when AUDIT_CAPTURE_STDERR). Truncated to 200 chars.
 # For soft/soft_out pass argv0 (basename) to avoid cardinality explosion from variable args.
 emit_probe_failed() {
-    [ -n "$NDJSON_FILE" ] || return 0
     local probe="$1"
     local code="${2:-1}"
     # Never record success as failure; exit 0 means probe succeeded.
     [ "$code" -ne 0 ] 2>/dev/null || return 0
+    emit_event probe_finished probe "$probe" exit_code "$code"
+    [ -n "$NDJSON_FILE" ] || return 0
     local argv0="${3:-}"
     local count_key="${4:-$probe}"
     local message="${5:-}"
@@ -664,6 +2962,7 @@ soft_probe() {
         _common_register_tmp "$stderr_tmp"
         if "$@" 2>"${stderr_tmp:-/dev/null}"; then
             rm -f "$stderr_tmp" 2>/dev/null
+            emit_event probe_finished probe "$probe" exit_code 0
             return 0
         fi
         local code=$?
@@ -680,6 +2979,7 @@ soft_probe() {
         emit_probe_failed "$probe" "$code" "${1:-}"
         return 0
     }
+    emit_event probe_finished probe "$probe" exit_code 0
 }
 
 soft_out_probe() {
@@ -693,6 +2993,7 @@ soft_out_probe() {
         local code=$?
         if [ $code -eq 0 ]; then
             rm -f "$stderr_tmp" 2>/dev/null
+            emit_event probe_finished probe "$probe" exit_code 0
             echo "$out"
             return 0
         fi
@@ -709,6 +3010,7 @@ soft_out_probe() {
         emit_probe_failed "$probe" "$code" "${1:-}"
         return 0
     }
+    emit_event probe_finished probe "$probe" exit_code 0
 }
 
 # Like soft_probe but returns the actual exit code (doesn't swallow on failure).
@@ -721,6 +3023,7 @@ soft_probe_check() {
         _common_register_tmp "$stderr_tmp"
         if "$@" 2>"${stderr_tmp:-/dev/null}"; then
             rm -f "$stderr_tmp" 2>/dev/null
+            emit_event probe_finished probe "$probe" exit_code 0
             return 0
         fi
         local code=$?
@@ -732,6 +3035,7 @@ soft_probe_check() {
         return $code
     fi
     if "$@" 2>/dev/null; then
+        emit_event probe_finished probe "$probe" exit_code 0
         return 0
     else
         local code=$?
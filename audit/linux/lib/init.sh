@@ -17,10 +17,14 @@ audit_set_defaults_if_unset() {
     [[ -n "$_default_report_dir" ]] || _default_report_dir="audit"
 
     HOME_DIR="${HOME_DIR:-$HOME}"
+    # The PATH the audit was started with, before the prefix below; the
+    # security collector checks this one for hijackable entries.
+    INHERITED_PATH="${INHERITED_PATH-${PATH:-}}"
     # Ensure core Linux admin binaries exist even in non-login / GUI contexts
     export PATH="/usr/local/bin:/usr/local/sbin:/usr/bin:/usr/sbin:/sbin:${PATH:-}"
     AUDIT_PATH="${AUDIT_PATH:-$PATH}"
-    DEFAULT_REPORT_DIR="${DEFAULT_REPORT_DIR:-$(pwd)/output/$_default_report_dir}"
+    # osaudit passes output_dir from config.yaml as AUDIT_OUTPUT_ROOT.
+    DEFAULT_REPORT_DIR="${DEFAULT_REPORT_DIR:-${AUDIT_OUTPUT_ROOT:-$(pwd)/output}/$_default_report_dir}"
     REPORT_DIR="${REPORT_DIR:-$DEFAULT_REPORT_DIR}"
     NO_COLOR="${NO_COLOR:-false}"
     OUTPUT_FILE="${OUTPUT_FILE:-}"
@@ -105,6 +109,11 @@ audit_parse_args() {
                 REDACT_ALL=true
                 shift
                 ;;
+            --no-redact)
+                REDACT_ALL=false
+                REDACT_PATHS_MODE="off"
+                shift
+                ;;
             --no-color)
                 NO_COLOR=true
                 shift
@@ -177,7 +186,7 @@ audit_resolve_output_paths() {
     fi
 }
 
-# Sets EXIT trap to write run meta only on success. Chains with existing EXIT trap (e.g. _common_cleanup_tmps).
+# Sets EXIT trap to write run meta on success or interruption (see _common_interrupted). Chains with existing EXIT trap (e.g. _common_cleanup_tmps).
 # audit_write_run_meta must already be defined (init.sh already sourced).
 # Usage: audit_set_run_meta_trap <audit_id>
 audit_set_run_meta_trap() {
@@ -187,11 +196,11 @@ audit_set_run_meta_trap() {
     if [[ -n "$prev_trap" ]]; then
         prev_cmd=$(echo "$prev_trap" | sed -n "s/^trap -- '\(.*\)' EXIT\$/\1/p" | sed "s/\\\\'/'/g") || true
     fi
-    # Run our meta write first (if success), then chain to prev trap (e.g. cleanup). No explicit exit.
-    trap "ec=\$?; if [[ \$ec -eq 0 && -n \"\${RUN_META_OUT:-}\" ]]; then audit_write_run_meta \"$id\"; fi; ${prev_cmd:+$prev_cmd; }" EXIT
+    # Run our meta write first (if success or interrupted), then chain to prev trap (e.g. cleanup). No explicit exit.
+    trap "ec=\$?; if [[ (\$ec -eq 0 || -n \"\${AUDIT_INTERRUPTED:-}\") && -n \"\${RUN_META_OUT:-}\" ]]; then audit_write_run_meta \"$id\"; fi; ${prev_cmd:+$prev_cmd; }" EXIT
 }
 
-# Writes run metadata JSON to RUN_META_OUT if set. Only call on successful exit.
+# Writes run metadata JSON to RUN_META_OUT if set. Only call on successful or interrupted exit.
 # Usage: audit_write_run_meta <audit_id>
 audit_write_run_meta() {
     [[ -n "${RUN_META_OUT:-}" ]] || return 0
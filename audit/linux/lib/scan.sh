@@ -10,29 +10,76 @@ if [[ "${_SCAN_SH_LOADED:-0}" == "1" ]]; then
 fi
 _SCAN_SH_LOADED=1
 
+# scoped_find_pruned <expr>... walks the scan roots; end expr with -print or
+# -print0.
 scoped_find_pruned() {
     if [ -z "$ROOTS_OVERRIDE_RAW" ] && $DEEP_SCAN; then
-        find "$HOME_DIR" \
+        find_excluding "$HOME_DIR" \
             -type d \( -path "$HOME_DIR/.local/share/Trash" -o -path "$HOME_DIR/snap" -o -name "node_modules" -o -name ".git" \) -prune -o \
-            "$@" -print 2>/dev/null || true
+            "$@" 2>/dev/null || true
         return
     fi
 
     local root
     for root in "${SCAN_ROOTS[@]+"${SCAN_ROOTS[@]}"}"; do
         [ -e "$root" ] || continue
-        find "$root" \
+        find_excluding "$root" \
             -type d \( -name "node_modules" -o -name ".git" \) -prune -o \
-            "$@" -print 2>/dev/null || true
+            "$@" 2>/dev/null || true
     done
 }
 
 home_find_excluding() {
-    find "$HOME_DIR" \
+    find_excluding "$HOME_DIR" \
         -type d \( -path "$HOME_DIR/.local/share/Trash" -o -path "$HOME_DIR/snap" -o -name "node_modules" -o -name ".git" \) -prune -o \
         "$@" -print 2>/dev/null || true
 }
 
+# du_sk <path>...: du -sk, kept on one filesystem under --one-filesystem.
+du_sk() {
+    if [ "${ONE_FILESYSTEM:-false}" = true ]; then
+        du -skx "$@"
+    else
+        du -sk "$@"
+    fi
+}
+
+# du_h <path> prints the human-readable size of one file or directory. Only
+# du's first line is read: a newline in the name would continue the path.
+du_h() {
+    du -sh "$1" 2>/dev/null | awk -F'\t' 'NR == 1 { print $1 }'
+}
+
+# emit_skipped_subtrees lists the mounts under the home directory and scan
+# roots that the scan does not descend into (see skipped_subtrees), as a
+# report section and one capability row each, so sizes that come up short
+# can be traced to an unscanned filesystem.
+emit_skipped_subtrees() {
+    local root mp reason fstype safe_mp lines="" seen=","
+    for root in "$HOME_DIR" "${SCAN_ROOTS[@]+"${SCAN_ROOTS[@]}"}"; do
+        while IFS=$'\t' read -r mp reason fstype; do
+            case "$seen" in
+                *",$mp,"*) continue ;;
+            esac
+            seen="$seen$mp,"
+            lines="$lines$mp"$'\t'"$reason"$'\t'"$fstype"$'\n'
+        done < <(skipped_subtrees "$root")
+    done
+    [ -n "$lines" ] || return 0
+    # A root under a skipped mount is covered by it.
+    lines="$(printf '%s' "$lines" | LC_ALL=C sort | awk -F'\t' '{ for (p in kept) if (index($1, p "/") == 1) next; kept[$1]; print }')"
+    section_header "🚧 Skipped Filesystems"
+    report_append "| Path | Filesystem | Reason |"
+    report_append "|------|------------|--------|"
+    while IFS=$'\t' read -r mp reason fstype; do
+        [ -n "$mp" ] || continue
+        safe_mp="$(redact_path_for_ndjson "$mp")"
+        report_append "| \`$safe_mp\` | $fstype | $reason |"
+        append_ndjson_line "{\"type\":\"capability\",\"run_id\":$(json_escape "$RUN_ID"),\"capability\":\"filesystem_walk\",\"status\":\"skipped\",\"path\":$(json_escape "$safe_mp"),\"reason\":$(json_escape "$reason"),\"fstype\":$(json_escape "$fstype")}"
+    done <<< "$lines"
+    report_append ""
+}
+
 human_size_kb() {
     local kb=${1:-0}
     if (( kb < 0 )); then
@@ -60,10 +107,11 @@ human_size_kb() {
     fi
 }
 
+# emit_large_files_bytes prints "bytes\tpath" for each large file, largest
+# first, with the path as path_line_escape writes it.
 emit_large_files_bytes() {
-    scoped_find_pruned -type f -size "+${LARGE_FILE_THRESHOLD_MB}M" | while IFS= read -r f; do
-        [ -n "$f" ] || continue
-        printf '%s\t%s\n' "$(stat_bytes "$f")" "$f"
+    scoped_find_pruned -type f -size "+${LARGE_FILE_THRESHOLD_MB}M" -print0 | while IFS= read -r -d '' f; do
+        printf '%s\t%s\n' "$(stat_bytes "$f")" "$(path_line_escape "$f")"
     done | sort -nr -k1,1 -k2,2
 }
 
@@ -122,6 +170,8 @@ run_storage_audit() {
     trash_count=0
     home_bytes=0
 
+    emit_skipped_subtrees
+
     # =============================================================================
     # 1. DISK USAGE OVERVIEW
     # =============================================================================
@@ -160,7 +210,7 @@ run_storage_audit() {
         echo -e "  ${CYAN}$folder_name${NC}: $size"
         report_append "| \`$folder_name\` | $size |"
         folder_index=$((folder_index + 1))
-    done < <(du -sk "$HOME_DIR"/*/ 2>/dev/null | sort -nr || true)
+    done < <(du_sk "$HOME_DIR"/*/ 2>/dev/null | sort -nr || true)
 
     # Capture dotdirs (e.g. .cursor, .vscode, .npm, .nvm) for overview and NDJSON reuse; .Trash done separately
     dotdir_kb=0
@@ -172,12 +222,12 @@ run_storage_audit() {
         folder_bytes=$((kb * 1024))
         folder_ndjson_path=$(redact_path_for_ndjson "$folder")
         printf '%s\t%s\n' "$folder_bytes" "$folder_ndjson_path" >> "$TOP_PATHS_FILE"
-    done < <(du -sk "$HOME_DIR"/.??* 2>/dev/null | awk -v home="$HOME_DIR" -F'\t' '$2 != home "/.local/share/Trash" {print}' | sort -nr || true)
+    done < <(du_sk "$HOME_DIR"/.??* 2>/dev/null | awk -v home="$HOME_DIR" -F'\t' '$2 != home "/.local/share/Trash" {print}' | sort -nr || true)
     total_kb=$((total_kb + dotdir_kb))
 
     # Add Trash to total and stash for NDJSON reuse (single du -sk)
     if [ -d "$HOME_DIR/.local/share/Trash" ]; then
-        trash_kb=$(du -sk "$HOME_DIR/.local/share/Trash" 2>/dev/null | awk '{print $1}') || true
+        trash_kb=$(du_sk "$HOME_DIR/.local/share/Trash" 2>/dev/null | awk '{print $1}') || true
         trash_kb=${trash_kb:-0}
         OVERVIEW_KB_TRASH=$trash_kb
         total_kb=$((total_kb + trash_kb))
@@ -197,6 +247,56 @@ run_storage_audit() {
     section_end_ms=$(now_ms)
     emit_timing "disk_usage_overview" "$section_start_ms" "$section_end_ms"
 
+    # =============================================================================
+    # DISK HEALTH (SMART)
+    # =============================================================================
+    section_start_ms=$(now_ms)
+    section_header "🩺 Disk Health"
+    local health_items="" health_count=0 failing_count=0 failing_devices="" health_skip="" health_source=""
+    local hd_device hd_protocol hd_model hd_health hd_realloc hd_pending hd_wear hd_failing hd_failing_json
+    if ! command -v smartctl >/dev/null 2>&1; then
+        health_skip="smartctl_missing"
+        report_append "_smartctl is not installed (smartmontools); disk health not checked._"
+    elif [ "${EUID:-$(id -u)}" != "0" ]; then
+        health_skip="not_root"
+        report_append "_Reading SMART data needs root; disk health not checked._"
+    fi
+    if [ -n "$health_skip" ]; then
+        append_ndjson_line "{\"type\":\"capability\",\"run_id\":$(json_escape "$RUN_ID"),\"capability\":\"smart\",\"status\":\"skipped\",\"reason\":$(json_escape "$health_skip")}"
+    else
+        health_source=smartctl
+        while IFS=$'\t' read -r hd_device hd_protocol hd_model hd_health hd_realloc hd_pending hd_wear hd_failing; do
+            [ -n "$hd_device" ] || continue
+            if [ -z "$health_items" ]; then
+                report_append "| Disk | Model | Health | Reallocated | Pending | Wear | Failing attributes |"
+                report_append "|------|-------|--------|-------------|---------|------|--------------------|"
+            fi
+            report_append "| \`$hd_device\` | $hd_model | ${hd_health/#-/unknown} | $hd_realloc | $hd_pending | ${hd_wear}$([ "$hd_wear" != "-" ] && echo "%") | ${hd_failing//,/, } |"
+            hd_failing_json=""
+            if [ "$hd_failing" != "-" ]; then
+                hd_failing_json="$(printf '%s\n' "$hd_failing" | tr ',' '\n' | while IFS= read -r attr; do printf '%s,' "$(json_escape "$attr")"; done)"
+                hd_failing_json="${hd_failing_json%,}"
+            fi
+            if [ "$hd_health" = failed ] || [ -n "$hd_failing_json" ]; then
+                failing_count=$((failing_count + 1))
+                failing_devices="${failing_devices:+$failing_devices,}$(json_escape "$hd_device")"
+            fi
+            health_count=$((health_count + 1))
+            [ -n "$health_items" ] && health_items+=","
+            health_items+="{\"device\":$(json_escape "$hd_device"),\"protocol\":$(json_escape "$hd_protocol"),\"model\":$(json_escape "${hd_model/#-/}"),\"health\":$(json_escape "${hd_health/#-/}"),\"reallocated\":${hd_realloc/#-/null},\"pending\":${hd_pending/#-/null},\"wear_pct\":${hd_wear/#-/null},\"failing\":[${hd_failing_json}]}"
+        done < <(linux_disk_health storage || true)
+        if (( health_count == 0 )); then
+            report_append "_No disks reported SMART data._"
+        elif (( failing_count > 0 )); then
+            report_append ""
+            report_append "- ⚠️ Disks failing their SMART checks: **$failing_count** — back them up and replace them"
+            append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"disk_failing\",\"count\":$failing_count,\"devices\":[${failing_devices}]}"
+        fi
+    fi
+    append_ndjson_line "{\"type\":\"disk_health\",\"run_id\":$(json_escape "$RUN_ID"),\"source\":$(json_escape "$health_source"),\"disks\":$health_count,\"failing\":$failing_count,\"items\":[${health_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "disk_health" "$section_start_ms" "$section_end_ms"
+
     # =============================================================================
     # DOWNLOADS COMBINED SCAN (single find pass for Junk zip + Downloads section)
     # =============================================================================
@@ -228,7 +328,7 @@ run_storage_audit() {
                     ;;
             esac
         done < <(
-            find "$HOME_DIR/Downloads" -type f 2>/dev/null | while IFS= read -r f; do
+            find_excluding "$HOME_DIR/Downloads" -type f -print 2>/dev/null | while IFS= read -r f; do
                 [ -n "$f" ] || continue
                 bytes=$(stat -c%s "$f" 2>/dev/null || stat -f%z "$f" 2>/dev/null || echo 0)
                 atime=$(stat -c%X "$f" 2>/dev/null || stat -f%a "$f" 2>/dev/null || echo 0)
@@ -278,13 +378,16 @@ run_storage_audit() {
 
     while IFS=$'\t' read -r bytes file; do
         [ -n "$file" ] || continue
-        size=$(du -sh "$file" 2>/dev/null | cut -f1)
-        rel_path="${file#$HOME_DIR/}"
-        echo -e "  ${RED}$size${NC}  $rel_path"
+        path_line_unescape file "$file"
+        size=$(du_h "$file")
+        rel_path="$(md_path "${file#$HOME_DIR/}")"
+        printf '  %b%s%b  %s\n' "$RED" "$size" "$NC" "$rel_path"
         report_append "| $size | \`$rel_path\` |"
         ((large_count += 1))
         if [ -n "$NDJSON_FILE" ] && (( large_ndjson_count < 10 )); then
-            ndjson_path=$(redact_path_for_ndjson "$file")
+            # The trailing x keeps $(...) from dropping newlines that end a name.
+            ndjson_path="$(redact_path_for_ndjson "$file"; printf x)"
+            ndjson_path="${ndjson_path%$'\n'x}"
             append_ndjson_line "{\"type\":\"large_file\",\"run_id\":$(json_escape "$RUN_ID"),\"path\":$(json_escape "$ndjson_path"),\"bytes\":${bytes:-0}}"
             ((large_ndjson_count += 1))
         fi
@@ -319,7 +422,7 @@ run_storage_audit() {
             broken_links=$((broken_links + 1))
         fi
     done < <(
-        find "$HOME_DIR" \( -path "*/.local/share/Trash" \) -prune -o \( \
+        find_excluding "$HOME_DIR" \( -path "*/.local/share/Trash" \) -prune -o \( \
             -name "Thumbs.db" -o \
             -name "desktop.ini" -o \
             -type l ! -exec test -e {} \; \
@@ -341,7 +444,7 @@ run_storage_audit() {
                 *.rpm) rpm_files+=("$f") ;;
                 *.appimage) appimage_files+=("$f") ;;
             esac
-        done < <(find "$HOME_DIR/Downloads" -type f \( -name "*.deb" -o -name "*.rpm" -o -iname "*.appimage" \) 2>/dev/null)
+        done < <(find_excluding "$HOME_DIR/Downloads" -type f \( -name "*.deb" -o -name "*.rpm" -o -iname "*.appimage" \) -print 2>/dev/null)
     fi
     deb_count=${#deb_files[@]}
     rpm_count=${#rpm_files[@]}
@@ -360,7 +463,7 @@ run_storage_audit() {
         for f in "${deb_files[@]}" "${rpm_files[@]}" "${appimage_files[@]}"; do
             [ -n "$f" ] || continue
             rel="${f#$HOME_DIR/}"
-            fsize=$(du -sh "$f" 2>/dev/null | cut -f1)
+            fsize=$(du_h "$f")
             echo -e "    ${CYAN}$fsize${NC}  $rel"
             report_append "  - \`$rel\` ($fsize)"
         done
@@ -452,7 +555,7 @@ run_storage_audit() {
                 [ -z "$item" ] && continue
                 name=$(basename "$item")
                 [ "$name" = "cleanup-audit" ] || [ "$name" = "storage-audit" ] && continue
-                isize=$(du -sh "$item" 2>/dev/null | cut -f1)
+                isize=$(du_h "$item")
                 if [ -d "$item" ]; then
                     echo -e "    📁 $name ($isize)"
                     report_append "- 📁 \`$name/\` ($isize)"
@@ -498,7 +601,7 @@ run_storage_audit() {
             printf '%s\t%s\n' "$folder_bytes" "$folder" >> "$TOP_DOCUMENTS_FOLDERS_FILE"
             echo -e "    📁 $fname: $size"
             report_append "| \`$fname\` | $size |"
-        done < <(du -sk "$HOME_DIR/Documents"/*/ 2>/dev/null | sort -nr -k1,1 | sed -n '1,15p')
+        done < <(du_sk "$HOME_DIR/Documents"/*/ 2>/dev/null | sort -nr -k1,1 | sed -n '1,15p')
 
         if (( docs_file_count > 0 )); then
             echo -e "\n  ${CYAN}Loose files in Documents root:${NC}"
@@ -506,7 +609,7 @@ run_storage_audit() {
             while IFS= read -r f; do
                 [ -z "$f" ] && continue
                 fname=$(basename "$f")
-                fsize=$(du -sh "$f" 2>/dev/null | cut -f1)
+                fsize=$(du_h "$f")
                 echo -e "    📄 $fname ($fsize)"
                 report_append "- \`$fname\` ($fsize)"
             done < <(find "$HOME_DIR/Documents" -maxdepth 1 -type f 2>/dev/null | sort | sed -n '1,30p')
@@ -530,7 +633,7 @@ run_storage_audit() {
     while IFS= read -r d; do
         [ -n "$d" ] || continue
         nm_dirs+=("$d")
-    done < <(find "$HOME_DIR" -maxdepth 6 -type d -name "node_modules" -not -path "*/Library/*" 2>/dev/null | sort)
+    done < <(find_excluding "$HOME_DIR" -maxdepth 6 -type d -name "node_modules" -not -path "*/Library/*" -print 2>/dev/null | sort)
     nm_count=${#nm_dirs[@]}
     echo -e "  node_modules directories: ${YELLOW}$nm_count${NC}"
     report_append "### node_modules Directories: $nm_count"
@@ -542,7 +645,7 @@ run_storage_audit() {
         report_append "| Location | Size |"
         report_append "|----------|------|"
         for nm in "${nm_dirs[@]}"; do
-            nm_kb=$(du -sk "$nm" 2>/dev/null | awk '{print $1}') || true
+            nm_kb=$(du_sk "$nm" 2>/dev/null | awk '{print $1}') || true
             nm_kb=${nm_kb:-0}
             nm_bytes=$((nm_kb * 1024))
             nm_size=$(human_size_kb "$nm_kb")
@@ -554,9 +657,9 @@ run_storage_audit() {
         report_append ""
     fi
 
-    venv_dirs_count=$({ find "$HOME_DIR" -maxdepth 5 -type d \( -name ".venv" -o -name "venv" \) -not -path "*/Library/*" 2>/dev/null || true; } | count_lines)
+    venv_dirs_count=$({ find_excluding "$HOME_DIR" -maxdepth 5 -type d \( -name ".venv" -o -name "venv" \) -not -path "*/Library/*" -print 2>/dev/null || true; } | count_lines)
     venv_dirs_count=${venv_dirs_count:-0}
-    pycache_dirs_count=$({ find "$HOME_DIR" -maxdepth 5 -type d -name "__pycache__" -not -path "*/Library/*" 2>/dev/null || true; } | count_lines)
+    pycache_dirs_count=$({ find_excluding "$HOME_DIR" -maxdepth 5 -type d -name "__pycache__" -not -path "*/Library/*" -print 2>/dev/null || true; } | count_lines)
     pycache_dirs_count=${pycache_dirs_count:-0}
     venv_count=$((venv_dirs_count + pycache_dirs_count))
     venv_count=${venv_count:-0}
@@ -564,7 +667,7 @@ run_storage_audit() {
     report_append "### Python Virtual Envs / Cache: $venv_count"
 
     git_start_ms=$(now_ms)
-    git_count=$({ find "$HOME_DIR" -maxdepth 5 -type d -name ".git" -not -path "*/Library/*" 2>/dev/null || true; } | count_lines)
+    git_count=$({ find_excluding "$HOME_DIR" -maxdepth 5 -type d -name ".git" -not -path "*/Library/*" -print 2>/dev/null || true; } | count_lines)
     git_count=${git_count:-0}
     echo -e "  Git repositories: ${YELLOW}$git_count${NC}"
     report_append "### Git Repositories: $git_count"
@@ -587,19 +690,19 @@ run_storage_audit() {
         dir_name=$(basename "$dir")
 
         declare -a copies=()
-        while IFS= read -r c; do
+        while IFS= read -r -d '' c; do
             [ -n "$c" ] || continue
             copies+=("$c")
-        done < <(find "$dir" -type f \( -name "* ([0-9])*" -o -name "* copy*" -o -name "*-1.*" -o -name "*-2.*" \) 2>/dev/null | sort)
+        done < <(find_excluding "$dir" -type f \( -name "* ([0-9])*" -o -name "* copy*" -o -name "*-1.*" -o -name "*-2.*" \) -print0 2>/dev/null | sort -z)
         copy_count=${#copies[@]}
 
         if (( copy_count > 0 )); then
             echo -e "\n  ${CYAN}$dir_name — possible copies:${NC}"
             report_append -e "### $dir_name\n"
             for c in "${copies[@]}"; do
-                cname=$(basename "$c")
-                csize=$(du -sh "$c" 2>/dev/null | cut -f1)
-                echo -e "    ${YELLOW}$cname${NC} ($csize)"
+                cname="$(md_path "${c##*/}")"
+                csize=$(du_h "$c")
+                printf '    %b%s%b (%s)\n' "$YELLOW" "$cname" "$NC" "$csize"
                 report_append "- \`$cname\` ($csize)"
                 ((dup_found += 1))
             done
@@ -18,6 +18,7 @@ Options:
   --redact-paths         Redact NDJSON paths (default: on when --ndjson)
   --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
   --redact-all           Redact all sensitive text (implies --redact-paths)
+  --no-redact            Redact nothing, overriding redact in config.yaml
   --no-color             Disable ANSI colors in terminal output
   -h, --help             Show this help and exit
 EOF
@@ -80,19 +81,176 @@ persistence_init_ndjson_if_needed() {
     : > "$NDJSON_FILE"
     append_ndjson_line "{\"type\":\"meta\",\"run_id\":$(json_escape "$RUN_ID"),\"schema_version\":\"0.1\",\"tool_name\":\"operating-system-audit\",\"tool_component\":\"persistence-audit\",\"timestamp\":$(json_escape "$ISO_TIMESTAMP"),\"hostname\":$(json_escape "$HOSTNAME_VAL"),\"user\":$(json_escape "$CURRENT_USER"),\"os_version\":$(json_escape "$OS_VERSION"),\"kernel\":$(json_escape "$KERNEL_INFO"),\"path\":$(json_escape "$(get_audit_path_for_output)")}"
     emit_run_context
+    emit_scope
     PERSISTENCE_NDJSON_INITIALIZED=true
 }
 
+# Prints "<unit file>\t<drop-ins>" for a system unit from the unit search
+# path, the way systemd resolves it: the first directory with the unit file
+# wins, and drop-ins from every directory are merged by file name (earlier
+# directories override) and applied in file-name order.
+systemd_unit_files() {
+    local unit="$1" dir conf name path=""
+    local -A seen=()
+    local dropins=()
+    for dir in /etc/systemd/system /run/systemd/system /usr/local/lib/systemd/system /usr/lib/systemd/system /lib/systemd/system; do
+        if [ -z "$path" ] && [ -f "$dir/$unit" ]; then
+            path="$dir/$unit"
+        fi
+        for conf in "$dir/$unit.d"/*.conf; do
+            [ -f "$conf" ] || continue
+            name="${conf##*/}"
+            [ -z "${seen[$name]:-}" ] || continue
+            seen[$name]=1
+            dropins+=("$conf")
+        done
+    done
+    printf '%s\t' "$path"
+    if [ -n "$path" ] && (( ${#dropins[@]} > 0 )); then
+        printf '%s\n' "${dropins[@]}" | awk -F/ '{print $NF "\t" $0}' | sort | cut -f2 | paste -sd' ' -
+    else
+        echo
+    fi
+}
+
+# cron_entries <source> <path> [user] reads a crontab on stdin and prints
+# "source\tpath\tuser\tschedule\tcommand" for each job. System crontabs
+# (no user argument) name the user in the field after the schedule; per-user
+# crontabs belong to the given user. Variable assignments are skipped.
+cron_entries() {
+    awk -v src="$1" -v file="$2" -v owner="${3:-}" '
+        /^[ \t]*(#|$)/ { next }
+        /^[ \t]*[A-Za-z_][A-Za-z0-9_]*[ \t]*=/ { next }
+        {
+            n = ($1 ~ /^@/) ? 1 : 5
+            sched = $1
+            for (i = 2; i <= n; i++) sched = sched " " $i
+            user = owner
+            if (user == "") { n++; user = $n }
+            cmd = $0
+            for (i = 1; i <= n; i++) sub(/^[ \t]*[^ \t]+/, "", cmd)
+            gsub(/\t/, " ", cmd)
+            sub(/^ +/, "", cmd)
+            sub(/ +$/, "", cmd)
+            if (cmd == "" || user == "") next
+            print src "\t" file "\t" user "\t" sched "\t" cmd
+        }'
+}
+
+# at_entries prints "at\t-\tuser\tschedule\tcommand" for each job in the at
+# queue that `at -c` can show; root sees every user's jobs, others their own.
+# The command is the job's script body, joined with "; ".
+at_entries() {
+    command -v atq >/dev/null 2>&1 || return 0
+    local id when queue user job_cmd
+    while IFS=$'\t' read -r id when queue user; do
+        [ -n "$id" ] || continue
+        job_cmd="$(soft_out_probe "persistence.at_c" at -c "$id" 2>/dev/null | awk '
+            /<< .?marcinDELIMITER/ { body = 1; heredoc = 1; next }
+            heredoc && /^marcinDELIMITER/ { exit }
+            !heredoc && /^}$/ { body = 1; next }
+            body && NF { cmd = (cmd == "" ? "" : cmd "; ") $0 }
+            END { gsub(/\t/, " ", cmd); print cmd }')"
+        [ -n "$job_cmd" ] || continue
+        printf 'at\t-\t%s\t%s (queue %s)\t%s\n' "$user" "$when" "$queue" "$job_cmd"
+    done < <(soft_out_probe "persistence.atq" atq 2>/dev/null | awk '{
+        when = $2
+        for (i = 3; i <= NF - 2; i++) when = when " " $i
+        print $1 "\t" when "\t" $(NF - 1) "\t" $NF
+    }')
+}
+
+# cron_jobs prints every cron and at job on the host, one per line, in the
+# cron_entries format: /etc/crontab, /etc/cron.d, the scripts run-parts runs
+# from /etc/cron.{hourly,daily,weekly,monthly}, the per-user crontabs in the
+# cron spool (root only; otherwise the current user's `crontab -l`), and the
+# at queue.
+cron_jobs() {
+    local f period dir spool_read=false
+    [ -r /etc/crontab ] && cron_entries crontab /etc/crontab < /etc/crontab
+    for f in /etc/cron.d/*; do
+        [ -f "$f" ] && [ -r "$f" ] || continue
+        case "${f##*/}" in .placeholder|*.dpkg-*|*.rpm*|*~) continue ;; esac
+        cron_entries cron.d "$f" < "$f"
+    done
+    for period in hourly daily weekly monthly; do
+        for f in /etc/cron."$period"/*; do
+            [ -f "$f" ] && [ -x "$f" ] || continue
+            case "${f##*/}" in .placeholder|*.dpkg-*|*.rpm*|*~) continue ;; esac
+            printf 'cron.%s\t%s\troot\t@%s\t%s\n' "$period" "$f" "$period" "$f"
+        done
+    done
+    # Debian, RHEL, and SUSE keep per-user crontabs in different spools.
+    for dir in /var/spool/cron/crontabs /var/spool/cron/tabs /var/spool/cron; do
+        [ -d "$dir" ] && [ -r "$dir" ] && [ -x "$dir" ] || continue
+        for f in "$dir"/*; do
+            [ -f "$f" ] && [ -r "$f" ] || continue
+            spool_read=true
+            cron_entries user "$f" "${f##*/}" < "$f"
+        done
+        [ "$spool_read" = true ] && break
+    done
+    if [ "$spool_read" = false ] && command -v crontab >/dev/null 2>&1; then
+        soft_out_probe "persistence.crontab_l" crontab -l 2>/dev/null | cron_entries user "crontab -l" "$CURRENT_USER"
+    fi
+    at_entries
+}
+
+# Prints "kind\tname\tuser\tstatus\tpath" for each background task that
+# comes from a tool rather than a unit the package manager installed:
+# services Homebrew on Linux manages (`brew services`, with their state:
+# started, stopped, scheduled, none, or error), and the logrotate configs,
+# named by their path, with status "scripts" when they run shell commands
+# around a rotation. The daily, weekly, and monthly scripts are cron jobs.
+# Empty fields are "-".
+background_tasks() {
+    local brew="" f state
+    brew="$(command -v brew 2>/dev/null || true)"
+    for f in /home/linuxbrew/.linuxbrew/bin/brew "$HOME_DIR/.linuxbrew/bin/brew"; do
+        [ -z "$brew" ] && [ -x "$f" ] && brew="$f"
+    done
+    if [ -n "$brew" ]; then
+        soft_out_probe "persistence.brew_services_list" "$brew" services list | awk -v home="$HOME" '
+            NR == 1 || NF < 2 { next }
+            {
+                i = 3
+                if ($2 == "error" && $3 ~ /^[0-9]+$/) i = 4
+                user = (NF >= i) ? $i : "-"
+                file = ""
+                for (j = i + 1; j <= NF; j++) file = file (file == "" ? "" : " ") $j
+                sub(/^~/, home, file)
+                print "brew_service\t" $1 "\t" user "\t" $2 "\t" (file == "" ? "-" : file)
+            }'
+    fi
+    for f in /etc/logrotate.conf /etc/logrotate.d/*; do
+        [ -f "$f" ] || continue
+        case "${f##*/}" in *.dpkg-*|*.rpm*|*~) continue ;; esac
+        state=-
+        grep -qE '^[[:space:]]*(prerotate|postrotate|firstaction|lastaction|preremove)' "$f" 2>/dev/null && state=scripts
+        printf 'log_rotation\t%s\troot\t%s\t%s\n' "$f" "$state" "$f"
+    done
+}
+
 run_persistence_audit() {
     local enabled_services_count=0
     local user_services_count=0
     local kernel_modules_count=0
     local xdg_autostart_count=0
+    local cron_jobs_count=0
+    local at_jobs_count=0
     local pam_non_default_count=0
     local init_d_count=0
     local rc_local_exists=false
     local rc_local_executable=false
     local dkms_count=0
+    local shell_startup_count=0
+    local systemd_units_count=0
+    local enabled_units=()
+
+    if [ -n "${OSAUDIT_SCOPE:-}" ]; then
+        report_append "_Scoped to \`$(scope_display)\`: only persistence items that reference these paths are listed._"
+        report_append ""
+    fi
 
     # -------------------------------------------------------------------------
     # Enabled System Services
@@ -106,6 +264,8 @@ run_persistence_audit() {
         local line_count=0
         while IFS=$'\t' read -r unit state; do
             [ -n "$unit" ] || continue
+            enabled_units+=("$unit")
+            scope_matches || continue
             if (( line_count < 30 )); then
                 report_append "| \`$unit\` | $state |"
                 item="{\"unit\":$(json_escape "$unit"),\"state\":$(json_escape "$state")}"
@@ -126,6 +286,75 @@ run_persistence_audit() {
     section_end_ms=$(now_ms)
     emit_timing "enabled_services" "$section_start_ms" "$section_end_ms"
 
+    # -------------------------------------------------------------------------
+    # Systemd Unit Files (every enabled service, with file hashes)
+    # -------------------------------------------------------------------------
+    section_start_ms=$(now_ms)
+    section_header "🗂️ Systemd Unit Files"
+    report_append "| Unit | Unit file | SHA-256 | Drop-ins |"
+    report_append "|------|-----------|---------|----------|"
+    local unit_items=""
+    if (( ${#enabled_units[@]} > 0 )); then
+        # Ask the running systemd where each unit's files are; it knows about
+        # generators and SYSTEMD_UNIT_PATH. It rejects template units
+        # (foo@.service), and without a running systemd (containers, chroots)
+        # there is nobody to ask, so those units are looked up on disk.
+        local -A unit_fragment=() unit_dropins=()
+        local instance_units=() id frag drop
+        for unit in "${enabled_units[@]}"; do
+            [[ "$unit" == *@.* ]] || instance_units+=("$unit")
+        done
+        if [ -d /run/systemd/system ] && (( ${#instance_units[@]} > 0 )); then
+            while IFS=$'\t' read -r id frag drop; do
+                [ -n "$id" ] || continue
+                unit_fragment[$id]="$frag"
+                unit_dropins[$id]="$drop"
+            done < <(soft_out_probe "persistence.systemctl_show_units" systemctl show --no-pager --property=Id,FragmentPath,DropInPaths -- "${instance_units[@]}" 2>/dev/null | awk '
+                /^Id=/ { id = substr($0, 4) }
+                /^FragmentPath=/ { frag = substr($0, 14) }
+                /^DropInPaths=/ { drop = substr($0, 13) }
+                /^$/ { if (id != "") print id "\t" frag "\t" drop; id = frag = drop = "" }
+                END { if (id != "") print id "\t" frag "\t" drop }')
+        fi
+        local unit_rows=""
+        for unit in "${enabled_units[@]}"; do
+            if [ -n "${unit_fragment[$unit]:-}" ]; then
+                unit_rows="${unit_rows}${unit}"$'\t'"${unit_fragment[$unit]}"$'\t'"${unit_dropins[$unit]:-}"$'\n'
+            else
+                unit_rows="${unit_rows}${unit}"$'\t'"$(systemd_unit_files "$unit")"$'\n'
+            fi
+        done
+        local unit_path dropins unit_sha dropin_sha dropin_count safe_unit_path dropin
+        while IFS=$'\t' read -r unit unit_path dropins; do
+            [ -n "$unit" ] || continue
+            # shellcheck disable=SC2086
+            scope_matches_file "$unit_path" $dropins || continue
+            unit_sha="$(sha256_file "$unit_path")"
+            dropin_sha=""
+            dropin_count=0
+            if [ -n "$dropins" ]; then
+                # One hash over every drop-in, in systemd's load order.
+                dropin_sha="$(for dropin in $dropins; do cat -- "$dropin" 2>/dev/null; done | { sha256sum 2>/dev/null || shasum -a 256; } | awk '{print $1}')"
+                dropin_count=$(wc -w <<< "$dropins" | tr -d ' ')
+            fi
+            safe_unit_path="$(redact_path_for_ndjson "$unit_path")"
+            report_append "| \`$unit\` | \`${safe_unit_path:-?}\` | \`${unit_sha:0:12}\` | $dropin_count |"
+            item="{\"unit\":$(json_escape "$unit"),\"path\":$(json_escape "$safe_unit_path"),\"sha256\":$(json_escape "$unit_sha"),\"dropins\":${dropin_count},\"dropins_sha256\":$(json_escape "$dropin_sha")}"
+            if [ -z "$unit_items" ]; then
+                unit_items="$item"
+            else
+                unit_items="${unit_items},${item}"
+            fi
+            systemd_units_count=$((systemd_units_count + 1))
+        done <<< "$unit_rows"
+    fi
+    if (( systemd_units_count == 0 )); then
+        report_append "_No enabled systemd unit files found (systemctl unavailable or no enabled units)._"
+    fi
+    append_ndjson_line "{\"type\":\"systemd_units\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${systemd_units_count:-0},\"items\":[${unit_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "systemd_units" "$section_start_ms" "$section_end_ms"
+
     # -------------------------------------------------------------------------
     # User Systemd Services
     # -------------------------------------------------------------------------
@@ -137,6 +366,7 @@ run_persistence_audit() {
     if command -v systemctl >/dev/null 2>&1; then
         while IFS=$'\t' read -r unit state; do
             [ -n "$unit" ] || continue
+            scope_matches || continue
             report_append "| \`$unit\` | $state |"
             item="{\"unit\":$(json_escape "$unit"),\"state\":$(json_escape "$state")}"
             if [ -z "$user_service_items" ]; then
@@ -159,24 +389,32 @@ run_persistence_audit() {
     # -------------------------------------------------------------------------
     section_start_ms=$(now_ms)
     section_header "🧩 Loaded Kernel Modules"
-    report_append "| Module | Size | Used by |"
-    report_append "|--------|------|---------|"
-    local module_items=""
-    while IFS=$'\t' read -r mod size used; do
-        [ -n "$mod" ] || continue
-        report_append "| \`$mod\` | $size | $used |"
-        item="{\"module\":$(json_escape "$mod"),\"size\":$(json_escape "$size"),\"used_by\":$(json_escape "$used")}"
-        if [ -z "$module_items" ]; then
-            module_items="$item"
-        else
-            module_items="${module_items},${item}"
-        fi
-        kernel_modules_count=$((kernel_modules_count + 1))
-    done < <(soft_out_probe "persistence.lsmod" lsmod 2>/dev/null | awk 'NR>1 {print $1 "\t" $2 "\t" $3}')
+    local module_lines module_items unsigned_modules_count out_of_tree_modules_count
+    module_lines=""
+    if scope_matches; then
+        module_lines="$(linux_kernel_modules "persistence")"
+    fi
+    kernel_modules_count="$(printf '%s\n' "$module_lines" | awk -F'\t' 'NF {c++} END {print c+0}')"
+    unsigned_modules_count="$(printf '%s\n' "$module_lines" | awk -F'\t' 'NF && $6 ~ /E/ {c++} END {print c+0}')"
+    out_of_tree_modules_count="$(printf '%s\n' "$module_lines" | awk -F'\t' 'NF && $6 ~ /O/ {c++} END {print c+0}')"
+    module_items="$(printf '%s\n' "$module_lines" | awk -F'\t' "$_AWK_JSON_ESC"'
+        NF {
+            printf "%s{\"module\":\"%s\",\"size\":\"%s\",\"used_by\":\"%s\",\"version\":\"%s\",\"signer\":\"%s\",\"taint\":\"%s\"}", (n++ ? "," : ""), json_esc($1), json_esc($2), json_esc($3), json_esc($4), json_esc($5), json_esc($6)
+        }')"
     if (( kernel_modules_count == 0 )); then
         report_append "_No loaded kernel modules found._"
+    else
+        report_append "- Out-of-tree modules: **${out_of_tree_modules_count}**, unsigned: **${unsigned_modules_count}**"
+        report_append ""
+        report_append "| Module | Size | Used by | Signer | Taint |"
+        report_append "|--------|------|---------|--------|-------|"
+        while IFS= read -r line; do
+            report_append "$line"
+        done < <(printf '%s\n' "$module_lines" | awk -F'\t' 'NF {
+            printf "| `%s` | %s | %s | %s | %s |\n", $1, $2, $3, ($5 == "" ? "-" : $5), ($6 == "" ? "-" : $6)
+        }')
     fi
-    append_ndjson_line "{\"type\":\"kernel_modules\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${kernel_modules_count:-0},\"items\":[${module_items}]}"
+    append_ndjson_line "{\"type\":\"kernel_modules\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${kernel_modules_count:-0},\"unsigned_count\":${unsigned_modules_count:-0},\"out_of_tree_count\":${out_of_tree_modules_count:-0},\"items\":[${module_items}]}"
     section_end_ms=$(now_ms)
     emit_timing "kernel_modules" "$section_start_ms" "$section_end_ms"
 
@@ -189,6 +427,7 @@ run_persistence_audit() {
     shopt -s nullglob
     for desktop in "$HOME_DIR"/.config/autostart/*.desktop /etc/xdg/autostart/*.desktop; do
         [ -f "$desktop" ] || continue
+        scope_matches_file "$desktop" || continue
         name=""
         if [ -r "$desktop" ]; then
             name="$(soft_out_probe "persistence.desktop_name" grep -E '^Name=' "$desktop" 2>/dev/null | head -1 | cut -d= -f2- | xargs)"
@@ -212,6 +451,78 @@ run_persistence_audit() {
     section_end_ms=$(now_ms)
     emit_timing "xdg_autostart" "$section_start_ms" "$section_end_ms"
 
+    # -------------------------------------------------------------------------
+    # Cron and At Jobs
+    # -------------------------------------------------------------------------
+    section_start_ms=$(now_ms)
+    section_header "⏰ Cron and At Jobs"
+    local cron_items="" cron_source cron_path cron_user cron_schedule cron_command safe_cron_path
+    while IFS=$'\t' read -r cron_source cron_path cron_user cron_schedule cron_command; do
+        [ -n "$cron_source" ] || continue
+        [ "$cron_path" != "-" ] || cron_path=""
+        scope_matches "$cron_path" "$cron_command" || continue
+        if [[ "${REDACT_ALL:-false}" == "true" ]]; then
+            cron_command="$(redact_command "$cron_command")"
+        fi
+        safe_cron_path="$(redact_path_for_ndjson "$cron_path")"
+        if (( cron_jobs_count == 0 )); then
+            report_append "| Source | User | Schedule | Command |"
+            report_append "|--------|------|----------|---------|"
+        fi
+        report_append "| ${safe_cron_path:-at queue} | $cron_user | \`$cron_schedule\` | \`${cron_command//|/\\|}\` |"
+        item="{\"source\":$(json_escape "$cron_source"),\"path\":$(json_escape "$safe_cron_path"),\"user\":$(json_escape "$cron_user"),\"schedule\":$(json_escape "$cron_schedule"),\"command\":$(json_escape "$cron_command")}"
+        if [ -z "$cron_items" ]; then
+            cron_items="$item"
+        else
+            cron_items="${cron_items},${item}"
+        fi
+        cron_jobs_count=$((cron_jobs_count + 1))
+        [ "$cron_source" != "at" ] || at_jobs_count=$((at_jobs_count + 1))
+    done < <(cron_jobs)
+    if (( cron_jobs_count == 0 )); then
+        report_append "_No cron or at jobs found._"
+    fi
+    append_ndjson_line "{\"type\":\"cron_jobs\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${cron_jobs_count:-0},\"at_jobs\":${at_jobs_count:-0},\"items\":[${cron_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "cron_jobs" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🍺 Homebrew Services and Log Rotation"
+    local task_items="" task_kind task_name task_user task_status task_path task_sha safe_task_path background_tasks_count=0 brew_services_started=0
+    while IFS=$'\t' read -r task_kind task_name task_user task_status task_path; do
+        [ -n "$task_kind" ] || continue
+        [ "$task_user" != "-" ] || task_user=""
+        [ "$task_status" != "-" ] || task_status=""
+        [ "$task_path" != "-" ] || task_path=""
+        if [ -n "$task_path" ]; then
+            scope_matches_file "$task_path" || continue
+        else
+            scope_matches "$task_name" || continue
+        fi
+        task_sha="$(sha256_file "$task_path")"
+        safe_task_path="$(redact_path_for_ndjson "$task_path")"
+        [ "$task_kind" = "brew_service" ] || task_name="$safe_task_path"
+        if (( background_tasks_count == 0 )); then
+            report_append "| Kind | Name | User | Status | File |"
+            report_append "|------|------|------|--------|------|"
+        fi
+        report_append "| $task_kind | \`$task_name\` | ${task_user:--} | ${task_status:--} | \`${safe_task_path:--}\` |"
+        item="{\"kind\":\"$task_kind\",\"name\":$(json_escape "$task_name"),\"user\":$(json_escape "$task_user"),\"status\":$(json_escape "$task_status"),\"path\":$(json_escape "$safe_task_path"),\"sha256\":$(json_escape "$task_sha")}"
+        if [ -z "$task_items" ]; then
+            task_items="$item"
+        else
+            task_items="${task_items},${item}"
+        fi
+        background_tasks_count=$((background_tasks_count + 1))
+        [ "$task_kind:$task_status" != "brew_service:started" ] || brew_services_started=$((brew_services_started + 1))
+    done < <(background_tasks)
+    if (( background_tasks_count == 0 )); then
+        report_append "_No Homebrew services or log rotation configs found._"
+    fi
+    append_ndjson_line "{\"type\":\"background_tasks\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${background_tasks_count},\"brew_services_started\":${brew_services_started},\"items\":[${task_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "background_tasks" "$section_start_ms" "$section_end_ms"
+
     # -------------------------------------------------------------------------
     # PAM Configuration
     # -------------------------------------------------------------------------
@@ -285,10 +596,97 @@ run_persistence_audit() {
     section_end_ms=$(now_ms)
     emit_timing "dkms_modules" "$section_start_ms" "$section_end_ms"
 
+    # -------------------------------------------------------------------------
+    # Shell Startup Files
+    # -------------------------------------------------------------------------
+    section_start_ms=$(now_ms)
+    section_header "🐚 Shell Startup Files"
+    local rc_scope rc_path rc_sha rc_line rc_pattern rc_patterns rc_findings rc_flagged safe_rc_path
+    local shell_rc_items="" shell_rc_flagged_json="" shell_rc_flagged_lines=() shell_rc_suspicious=0
+    while IFS=$'\t' read -r rc_scope rc_path; do
+        [ -n "$rc_path" ] || continue
+        scope_matches_file "$rc_path" || continue
+        rc_sha="$(sha256_file "$rc_path")"
+        safe_rc_path="$(redact_path_for_ndjson "$rc_path")"
+        rc_patterns=""
+        rc_findings=0
+        while IFS=$'\t' read -r rc_line rc_pattern; do
+            [ -n "$rc_pattern" ] || continue
+            rc_findings=$((rc_findings + 1))
+            case ",$rc_patterns," in *",$rc_pattern,"*) ;; *) rc_patterns="${rc_patterns:+$rc_patterns,}$rc_pattern" ;; esac
+            [ "$rc_pattern" != "path_prepend" ] || continue
+            shell_rc_suspicious=$((shell_rc_suspicious + 1))
+            shell_rc_flagged_lines+=("- ⚠️ \`$safe_rc_path\` line $rc_line: $rc_pattern")
+            if (( shell_rc_suspicious <= 50 )); then
+                shell_rc_flagged_json="${shell_rc_flagged_json:+$shell_rc_flagged_json,}{\"path\":$(json_escape "$safe_rc_path"),\"line\":$rc_line,\"pattern\":\"$rc_pattern\"}"
+            fi
+        done < <(shell_startup_findings "$rc_path" || true)
+        if (( shell_startup_count == 0 )); then
+            report_append "| Scope | File | SHA-256 | Patterns |"
+            report_append "|-------|------|---------|----------|"
+        fi
+        report_append "| $rc_scope | \`$safe_rc_path\` | \`${rc_sha:0:12}\` | ${rc_patterns:--} |"
+        [ -n "$shell_rc_items" ] && shell_rc_items+=","
+        shell_rc_items+="{\"scope\":\"$rc_scope\",\"path\":$(json_escape "$safe_rc_path"),\"sha256\":$(json_escape "$rc_sha"),\"findings\":$rc_findings,\"patterns\":\"$rc_patterns\"}"
+        shell_startup_count=$((shell_startup_count + 1))
+    done < <(shell_startup_files)
+    if (( shell_startup_count == 0 )); then
+        report_append "_No shell startup files found._"
+    fi
+    if (( shell_rc_suspicious > 0 )); then
+        report_append ""
+        for rc_flagged in "${shell_rc_flagged_lines[@]}"; do
+            report_append "$rc_flagged"
+        done
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"shell_startup_suspicious\",\"count\":$shell_rc_suspicious,\"items\":[${shell_rc_flagged_json}]}"
+    fi
+    append_ndjson_line "{\"type\":\"shell_startup_files\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${shell_startup_count:-0},\"suspicious\":$shell_rc_suspicious,\"items\":[${shell_rc_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "shell_startup_files" "$section_start_ms" "$section_end_ms"
+
+    # `osaudit triage` also lists recently modified files (see
+    # collector.TriageEnv).
+    if [ -n "${OSAUDIT_TRIAGE_HOURS:-}" ]; then
+        section_start_ms=$(now_ms)
+        section_header "🕵️ Recently Modified Files"
+        local recent_hours="$OSAUDIT_TRIAGE_HOURS" recent_count=0 recent_items="" recent_epoch recent_modified recent_path safe_recent_path
+        [[ "$recent_hours" =~ ^[0-9]+$ ]] || recent_hours=24
+        local recent_out recent_total
+        recent_out="$(recent_changes "$recent_hours")"
+        recent_total=$(printf '%s' "$recent_out" | awk 'END{print NR}')
+        report_append "Files modified in the last **${recent_hours} hour(s)** in system configuration, temporary, and startup locations (newest first, at most 200)."
+        report_append ""
+        while IFS=$'\t' read -r recent_epoch recent_modified recent_path; do
+            [ -n "$recent_path" ] || continue
+            if (( recent_count == 0 )); then
+                report_append "| Modified | Path |"
+                report_append "|----------|------|"
+            fi
+            safe_recent_path="$(redact_path_for_ndjson "$recent_path")"
+            report_append "| $recent_modified | \`$safe_recent_path\` |"
+            item="{\"path\":$(json_escape "$safe_recent_path"),\"modified\":$(json_escape "$recent_modified")}"
+            if [ -z "$recent_items" ]; then
+                recent_items="$item"
+            else
+                recent_items="${recent_items},${item}"
+            fi
+            recent_count=$((recent_count + 1))
+        done < <(printf '%s\n' "$recent_out" | head -n 200)
+        if (( recent_count == 0 )); then
+            report_append "_No recently modified files._"
+        elif (( recent_total > recent_count )); then
+            report_append ""
+            report_append "- $((recent_total - recent_count)) older change(s) not listed."
+        fi
+        append_ndjson_line "{\"type\":\"recent_changes\",\"run_id\":$(json_escape "$RUN_ID"),\"hours\":${recent_hours},\"count\":${recent_total:-0},\"truncated\":$( (( recent_total > recent_count )) && echo true || echo false),\"items\":[${recent_items}]}"
+        section_end_ms=$(now_ms)
+        emit_timing "recent_changes" "$section_start_ms" "$section_end_ms"
+    fi
+
     # -------------------------------------------------------------------------
     # Persistence Summary
     # -------------------------------------------------------------------------
-    append_ndjson_line "{\"type\":\"persistence_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"enabled_services\":${enabled_services_count:-0},\"user_services\":${user_services_count:-0},\"loaded_modules\":${kernel_modules_count:-0},\"xdg_autostart_count\":${xdg_autostart_count:-0},\"pam_non_default_count\":${pam_non_default_count:-0},\"init_d_count\":${init_d_count:-0},\"rc_local_exists\":$rc_local_exists,\"rc_local_executable\":$rc_local_executable}"
+    append_ndjson_line "{\"type\":\"persistence_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"enabled_services\":${enabled_services_count:-0},\"systemd_units\":${systemd_units_count:-0},\"user_services\":${user_services_count:-0},\"loaded_modules\":${kernel_modules_count:-0},\"xdg_autostart_count\":${xdg_autostart_count:-0},\"cron_jobs\":${cron_jobs_count:-0},\"background_tasks\":${background_tasks_count:-0},\"pam_non_default_count\":${pam_non_default_count:-0},\"init_d_count\":${init_d_count:-0},\"rc_local_exists\":$rc_local_exists,\"rc_local_executable\":$rc_local_executable,\"shell_startup_files\":${shell_startup_count:-0}}"
 }
 
 persistence_main() {
@@ -0,0 +1,342 @@
+#!/usr/bin/env bash
+# =============================================================================
+# Linux Security Audit
+# Conservative mode — reports only, modifies NOTHING
+# =============================================================================
+
+set -euo pipefail
+export LC_ALL=C
+
+security_usage() {
+    cat << EOF
+Usage: $(basename "${BASH_SOURCE[0]}") [options]
+
+Options:
+  --report-dir <path>    Output directory for Markdown report
+  --output <path>        Exact Markdown output file path
+  --ndjson               Also write a compact NDJSON summary file
+  --redact-paths         Redact NDJSON paths (default: on when --ndjson)
+  --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
+  --redact-all           Redact all sensitive text (implies --redact-paths)
+  --no-redact            Redact nothing, overriding redact in config.yaml
+  --no-color             Disable ANSI colors in terminal output
+  -h, --help             Show this help and exit
+EOF
+}
+
+security_set_defaults_if_unset() {
+    source "$(dirname "${BASH_SOURCE[0]}")/lib/init.sh"
+    audit_set_defaults_if_unset "security-audit"
+}
+
+security_parse_args() {
+    source "$(dirname "${BASH_SOURCE[0]}")/lib/init.sh"
+    audit_parse_args "security" security_usage "$@"
+}
+
+security_validate_and_resolve_paths() {
+    source "$(dirname "${BASH_SOURCE[0]}")/lib/init.sh"
+    audit_resolve_output_paths "security-audit"
+}
+
+security_prepare_files_and_common() {
+    mkdir -p "$REPORT_DIR"
+    SOFT_FAILURE_LOG="${SOFT_FAILURE_LOG:-$REPORT_DIR/.security-audit-soft-failures-$TIMESTAMP_FOR_FILENAME.log}"
+    : > "$SOFT_FAILURE_LOG"
+
+    source "$(dirname "${BASH_SOURCE[0]}")/lib/common.sh"
+}
+
+security_write_report_header_if_needed() {
+    if [[ "${SECURITY_HEADER_READY:-false}" == "true" ]]; then
+        return 0
+    fi
+    cat << EOF | report_write
+# 🛡️ Linux Security Audit
+**Generated:** $(date "+%B %d, %Y at %I:%M %p")
+**Home Directory:** $HOME_DIR
+**Mode:** Conservative (report only — no system changes)
+
+## Metadata
+- **Timestamp (ISO-8601):** $ISO_TIMESTAMP
+- **Run ID:** $RUN_ID
+- **Hostname:** $HOSTNAME_VAL
+- **Current user:** $CURRENT_USER
+- **Linux Distribution:** $OS_VERSION
+- **Kernel:** \`$KERNEL_INFO\`
+
+---
+
+EOF
+    SECURITY_HEADER_READY=true
+}
+
+security_init_ndjson_if_needed() {
+    if [ -z "$NDJSON_FILE" ]; then
+        return 0
+    fi
+    if [[ "${SECURITY_NDJSON_INITIALIZED:-false}" == "true" ]]; then
+        return 0
+    fi
+    : > "$NDJSON_FILE"
+    append_ndjson_line "{\"type\":\"meta\",\"run_id\":$(json_escape "$RUN_ID"),\"schema_version\":\"0.1\",\"tool_name\":\"operating-system-audit\",\"tool_component\":\"security-audit\",\"timestamp\":$(json_escape "$ISO_TIMESTAMP"),\"hostname\":$(json_escape "$HOSTNAME_VAL"),\"user\":$(json_escape "$CURRENT_USER"),\"os_version\":$(json_escape "$OS_VERSION"),\"kernel\":$(json_escape "$KERNEL_INFO"),\"path\":$(json_escape "$(get_audit_path_for_output)")}"
+    emit_run_context
+    SECURITY_NDJSON_INITIALIZED=true
+}
+
+# Prints "uid\tgid\tmode\towner" for a directory, following symlinks. mode is
+# octal and includes the setuid, setgid, and sticky bits.
+path_dir_stat() {
+    stat -L -c $'%u\t%g\t%a\t%U' "$1" 2>/dev/null
+}
+
+# Prints "index\tpath\texists\towner\tmode\tissues" for each entry of the PATH
+# the audit was started with, in search order. issues is a comma-separated
+# list of the ways someone other than root can plant a command there, or "-":
+#   relative                 empty or not absolute, so it follows the cwd
+#   world_writable           anyone can add files
+#   group_writable           a group other than gid 0 can add files
+#   writable_parent          a parent without the sticky bit lets someone
+#                            other than root or the owner rename the entry
+#   untrusted_before_system  not owned by root and searched before /usr/bin,
+#                            /bin, /usr/sbin, and /sbin
+path_entries() {
+    [ -n "${INHERITED_PATH:-}" ] || return 0
+    local entry index=0 system_seen=false exists owner mode issues uid gid
+    local parent p_uid p_gid p_mode p_owner
+    while IFS= read -r -d : entry; do
+        index=$((index + 1))
+        issues="" exists=false owner="-" mode="-"
+        case "$entry" in
+            /*)
+                if IFS=$'\t' read -r uid gid mode owner < <(path_dir_stat "$entry"); then
+                    exists=true
+                    mode="$(printf '%o' "$((8#$mode))")"
+                    if (( 8#$mode & 8#002 )); then
+                        issues="${issues:+$issues,}world_writable"
+                    fi
+                    if (( 8#$mode & 8#020 )) && [ "$gid" != 0 ]; then
+                        issues="${issues:+$issues,}group_writable"
+                    fi
+                    parent="$entry"
+                    while [ "$parent" != / ]; do
+                        parent="$(dirname "$parent")"
+                        IFS=$'\t' read -r p_uid p_gid p_mode p_owner < <(path_dir_stat "$parent") || break
+                        if (( 8#$p_mode & 8#1000 )); then
+                            continue
+                        fi
+                        if { [ "$p_uid" != 0 ] && [ "$p_uid" != "$uid" ]; } || (( 8#$p_mode & 8#002 )) || { (( 8#$p_mode & 8#020 )) && [ "$p_gid" != 0 ]; }; then
+                            issues="${issues:+$issues,}writable_parent"
+                            break
+                        fi
+                    done
+                    if [ "$system_seen" = false ] && [ "$uid" != 0 ]; then
+                        issues="${issues:+$issues,}untrusted_before_system"
+                    fi
+                else
+                    owner="-" mode="-"
+                fi
+                ;;
+            *)
+                issues="relative"
+                ;;
+        esac
+        case "${entry%/}" in
+            /usr/bin|/bin|/usr/sbin|/sbin) system_seen=true ;;
+        esac
+        printf '%s\t%s\t%s\t%s\t%s\t%s\n' "$index" "${entry:-.}" "$exists" "$owner" "$mode" "${issues:--}"
+    done < <(printf '%s:' "$INHERITED_PATH")
+}
+
+# Prints "path\towner\tgroup\tmode" for each sensitive directory that exists:
+# where SSH and GnuPG keys, sudo rules, cron jobs, systemd units, autostart
+# entries, and locally installed commands live, per user and system-wide.
+# Loosening one lets someone else read keys or plant code there, whatever the
+# files inside. mode is octal and includes the setuid, setgid, and sticky bits.
+sensitive_dirs() {
+    local user home d
+    local -a dirs=(/etc/sudoers.d /etc/ssh /etc/cron.d /etc/systemd/system /usr/local/bin /usr/local/sbin)
+    while IFS=$'\t' read -r user home; do
+        for d in .ssh .gnupg .config/autostart .config/systemd/user .local/bin; do
+            dirs+=("$home/$d")
+        done
+    done < <(user_homes security)
+    for d in "${dirs[@]}"; do
+        [ -d "$d" ] || continue
+        stat -L -c $'%n\t%U\t%G\t%a' "$d" 2>/dev/null
+    done | awk -F'\t' '!seen[$1]++'
+}
+
+run_security_audit() {
+    local path_entries_count=0
+    local path_hijack_count=0
+    local path_items="" item issue issues_json issues_cell
+    local path_index path_dir path_exists path_owner path_mode path_issues safe_path_dir
+
+    # -------------------------------------------------------------------------
+    # PATH Directories
+    # -------------------------------------------------------------------------
+    section_start_ms=$(now_ms)
+    section_header "🛤️ PATH Directories"
+    while IFS=$'\t' read -r path_index path_dir path_exists path_owner path_mode path_issues; do
+        [ -n "$path_index" ] || continue
+        safe_path_dir="$(redact_path_for_ndjson "$path_dir")"
+        if (( path_entries_count == 0 )); then
+            report_append "| # | Directory | Owner | Mode | Issues |"
+            report_append "|---|-----------|-------|------|--------|"
+        fi
+        path_entries_count=$((path_entries_count + 1))
+        issues_cell="-"
+        if [ "$path_issues" != "-" ]; then
+            issues_cell="**${path_issues//,/, }** ⚠️"
+        elif [ "$path_exists" != true ]; then
+            issues_cell="_missing_"
+        fi
+        report_append "| $path_index | \`$safe_path_dir\` | $path_owner | $path_mode | $issues_cell |"
+        [ "$path_issues" != "-" ] || continue
+
+        issues_json=""
+        for issue in ${path_issues//,/ }; do
+            issues_json="${issues_json:+$issues_json,}$(json_escape "$issue")"
+            append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"path_$issue\",\"path\":$(json_escape "$safe_path_dir")}"
+        done
+        [ "$path_owner" != "-" ] || path_owner=""
+        [ "$path_mode" != "-" ] || path_mode=""
+        item="{\"index\":$path_index,\"path\":$(json_escape "$safe_path_dir"),\"owner\":$(json_escape "$path_owner"),\"mode\":$(json_escape "$path_mode"),\"issues\":[${issues_json}]}"
+        if [ -z "$path_items" ]; then
+            path_items="$item"
+        else
+            path_items="${path_items},${item}"
+        fi
+        path_hijack_count=$((path_hijack_count + 1))
+    done < <(path_entries)
+    if (( path_entries_count == 0 )); then
+        report_append "_PATH is empty._"
+    elif (( path_hijack_count == 0 )); then
+        report_append ""
+        report_append "_No PATH entries can be hijacked._"
+    fi
+    append_ndjson_line "{\"type\":\"path_hijack\",\"run_id\":$(json_escape "$RUN_ID"),\"path_entries\":${path_entries_count:-0},\"count\":${path_hijack_count:-0},\"items\":[${path_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "path_hijack" "$section_start_ms" "$section_end_ms"
+
+    # -------------------------------------------------------------------------
+    # Sensitive Directories
+    # -------------------------------------------------------------------------
+    # Ownership and mode only; file contents are watched_dotfiles' and
+    # file_hash's job. diff reports loosening as a high-severity finding.
+    section_start_ms=$(now_ms)
+    section_header "🔐 Sensitive Directories"
+    local sensitive_count=0 sensitive_items="" dir_path dir_owner dir_group dir_mode safe_dir_path
+    while IFS=$'\t' read -r dir_path dir_owner dir_group dir_mode; do
+        [ -n "$dir_path" ] || continue
+        if (( sensitive_count == 0 )); then
+            report_append "| Directory | Owner | Group | Mode |"
+            report_append "|-----------|-------|-------|------|"
+        fi
+        sensitive_count=$((sensitive_count + 1))
+        safe_dir_path="$(redact_path_for_ndjson "$dir_path")"
+        report_append "| \`$safe_dir_path\` | $dir_owner | $dir_group | $dir_mode |"
+        [ -n "$sensitive_items" ] && sensitive_items+=","
+        sensitive_items+="{\"path\":$(json_escape "$safe_dir_path"),\"owner\":$(json_escape "$dir_owner"),\"group\":$(json_escape "$dir_group"),\"mode\":$(json_escape "$dir_mode")}"
+    done < <(sensitive_dirs || true)
+    if (( sensitive_count == 0 )); then
+        report_append "_No sensitive directories found._"
+    fi
+    append_ndjson_line "{\"type\":\"sensitive_dirs\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$sensitive_count,\"items\":[${sensitive_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "sensitive_dirs" "$section_start_ms" "$section_end_ms"
+
+    # -------------------------------------------------------------------------
+    # USB Devices
+    # -------------------------------------------------------------------------
+    # A HID device can type commands and a mass-storage one carries files in
+    # and out; one that is both is how keystroke-injection sticks show up.
+    section_start_ms=$(now_ms)
+    section_header "🔌 USB Devices"
+    local usb_count=0 usb_items="" usb_vid usb_pid usb_vendor usb_product usb_serial usb_classes usb_class classes_json field usb_hid_storage=""
+    while IFS=$'\t' read -r usb_vid usb_pid usb_vendor usb_product usb_serial usb_classes; do
+        [ -n "$usb_vid" ] || continue
+        if (( usb_count == 0 )); then
+            report_append "| ID | Vendor | Product | Classes |"
+            report_append "|----|--------|---------|---------|"
+        fi
+        usb_count=$((usb_count + 1))
+        report_append "| \`$usb_vid:$usb_pid\` | $(md_path "$usb_vendor") | $(md_path "$usb_product") | ${usb_classes//,/, } |"
+        for field in usb_vendor usb_product usb_serial; do
+            [ "${!field}" = "-" ] && printf -v "$field" '%s' ""
+        done
+        if [[ "${REDACT_ALL:-false}" == "true" && -n "$usb_serial" ]]; then
+            usb_serial="<serial>"
+        fi
+        classes_json=""
+        if [ "$usb_classes" != "-" ]; then
+            for usb_class in ${usb_classes//,/ }; do
+                classes_json="${classes_json:+$classes_json,}$(json_escape "$usb_class")"
+            done
+        fi
+        case ",$usb_classes," in
+            *,hid,*mass_storage,*)
+                usb_hid_storage="${usb_hid_storage:+$usb_hid_storage, }\`$usb_vid:$usb_pid\`"
+                append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"usb_hid_with_storage\",\"vendor_id\":$(json_escape "$usb_vid"),\"product_id\":$(json_escape "$usb_pid"),\"product\":$(json_escape "$usb_product")}"
+                ;;
+        esac
+        [ -n "$usb_items" ] && usb_items+=","
+        usb_items+="{\"vendor_id\":$(json_escape "$usb_vid"),\"product_id\":$(json_escape "$usb_pid"),\"vendor\":$(json_escape "$usb_vendor"),\"product\":$(json_escape "$usb_product"),\"serial\":$(json_escape "$usb_serial"),\"classes\":[${classes_json}]}"
+    done < <(usb_devices || true)
+    if (( usb_count == 0 )); then
+        report_append "_No USB devices attached._"
+    elif [ -n "$usb_hid_storage" ]; then
+        report_append ""
+        report_append "- ⚠️ Both HID and mass storage: $usb_hid_storage"
+    fi
+    append_ndjson_line "{\"type\":\"usb_devices\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$usb_count,\"items\":[${usb_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "usb_devices" "$section_start_ms" "$section_end_ms"
+
+    # -------------------------------------------------------------------------
+    # Removable Volumes
+    # -------------------------------------------------------------------------
+    section_start_ms=$(now_ms)
+    section_header "💾 Removable Volumes"
+    local removable_count=0 removable_items="" vol_mount vol_device vol_fstype vol_enc vol_removable vol_encrypted
+    while IFS=$'\t' read -r vol_mount vol_device vol_fstype vol_enc vol_removable; do
+        [ "$vol_removable" = true ] || continue
+        if (( removable_count == 0 )); then
+            report_append "| Mount | Device | Filesystem | Encryption |"
+            report_append "|-------|--------|------------|------------|"
+        fi
+        removable_count=$((removable_count + 1))
+        report_append "| \`$(md_path "$vol_mount")\` | \`$vol_device\` | $vol_fstype | ${vol_enc/#-/unknown} |"
+        case "$vol_enc" in
+            none) vol_encrypted=false ;;
+            -) vol_encrypted=null ;;
+            *) vol_encrypted=true ;;
+        esac
+        [ -n "$removable_items" ] && removable_items+=","
+        removable_items+="{\"mount\":$(json_escape "$vol_mount"),\"device\":$(json_escape "$vol_device"),\"fstype\":$(json_escape "$vol_fstype"),\"encryption\":$(json_escape "${vol_enc/#-/}"),\"encrypted\":$vol_encrypted}"
+    done < <(linux_disk_volumes security || true)
+    if (( removable_count == 0 )); then
+        report_append "_No removable volumes mounted._"
+    fi
+    append_ndjson_line "{\"type\":\"removable_volumes\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$removable_count,\"items\":[${removable_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "removable_volumes" "$section_start_ms" "$section_end_ms"
+}
+
+security_main() {
+    security_set_defaults_if_unset
+    security_parse_args "$@"
+    security_validate_and_resolve_paths
+    security_prepare_files_and_common
+    source "$(dirname "${BASH_SOURCE[0]}")/lib/init.sh"
+    audit_set_run_meta_trap "security"
+    security_write_report_header_if_needed
+    security_init_ndjson_if_needed
+    run_security_audit
+    emit_probe_failures_summary
+}
+
+if [[ "${BASH_SOURCE[0]}" == "$0" ]]; then
+    security_main "$@"
+fi
@@ -18,6 +18,7 @@ Options:
   --redact-paths         Redact NDJSON paths (default: on when --ndjson)
   --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
   --redact-all           Redact all sensitive text (implies --redact-paths)
+  --no-redact            Redact nothing, overriding redact in config.yaml
   --no-color             Disable ANSI colors in terminal output
   -h, --help             Show this help and exit
 EOF
@@ -26,6 +27,10 @@ EOF
 config_set_defaults_if_unset() {
     source "$(dirname "${BASH_SOURCE[0]}")/lib/init.sh"
     audit_set_defaults_if_unset "config-audit"
+
+    CERT_EXPIRY_DAYS="${CERT_EXPIRY_DAYS:-30}"
+    MALWARE_DATA_MAX_AGE_DAYS="${MALWARE_DATA_MAX_AGE_DAYS:-30}"
+    TIME_OFFSET_MAX_MS="${TIME_OFFSET_MAX_MS:-1000}"
 }
 
 config_parse_args() {
@@ -87,6 +92,9 @@ run_config_audit() {
     local sip=false
     local gatekeeper=false
     local firewall=false
+    local mdm_enrolled=false
+    local dep_enrolled=false
+    local mdm_user_approved=false
     local homebrew_installed=false
     local profile_files_count=0
 
@@ -114,17 +122,192 @@ run_config_audit() {
     screen_lock_delay="${screen_lock_delay:-unset}"
     auto_updates="$(soft_out_probe "config.softwareupdate_schedule" softwareupdate --schedule)"
     auto_updates="${auto_updates:-unknown}"
+    IFS=$'\t' read -r mdm_enrolled dep_enrolled mdm_user_approved < <(mac_mdm_enrollment config) || true
+    local profile_lines profile_count mdm_server
+    profile_lines="$(mac_config_profiles config)"
+    profile_count="$(printf '%s\n' "$profile_lines" | awk -F'\t' 'NF {c++} END {print c+0}')"
+    mdm_server="$(printf '%s\n' "$profile_lines" | awk -F'\t' '$7 != "" && $7 != "-" {print $7; exit}')"
+    # Apple pushes XProtect and Gatekeeper data updates about weekly; data
+    # older than MALWARE_DATA_MAX_AGE_DAYS means updates are not arriving.
+    # MRT stopped receiving updates once XProtect Remediator replaced it, so
+    # its age only counts on Macs without the remediator.
+    [[ "${MALWARE_DATA_MAX_AGE_DAYS:-}" =~ ^[0-9]+$ ]] || MALWARE_DATA_MAX_AGE_DAYS=30
+    local malware_lines xprotect_version malware_data_stale=false now_s
+    now_s="$(date +%s)"
+    malware_lines="$(mac_malware_protection config | awk -F'\t' -v now="$now_s" -v max="$MALWARE_DATA_MAX_AGE_DAYS" '
+        NF { line[++n] = $0; comp[n] = $1; age[n] = ($3 > 0) ? int((now - $3) / 86400) : -1; if ($1 == "xprotect_remediator") remediator = 1 }
+        END {
+            for (i = 1; i <= n; i++) {
+                stale = (age[i] < 0 || age[i] > max) && !(comp[i] == "mrt" && remediator)
+                print line[i] "\t" age[i] "\t" (stale ? "true" : "false")
+            }
+        }')"
+    xprotect_version="$(printf '%s\n' "$malware_lines" | awk -F'\t' '$1 == "xprotect" && $2 != "-" {print $2}')"
+    if printf '%s\n' "$malware_lines" | awk -F'\t' '$5 == "true" {found=1} END{exit found ? 0 : 1}'; then
+        malware_data_stale=true
+    fi
     report_append "- FileVault enabled: **$filevault**"
     report_append "- SIP enabled: **$sip**"
     report_append "- Gatekeeper enabled: **$gatekeeper**"
+    local boot_chip firmware_version os_loader_version boot_security third_party_kexts secure_boot=null
+    IFS=$'\t' read -r boot_chip firmware_version os_loader_version boot_security third_party_kexts < <(mac_firmware config) || true
+    case "${boot_security:--}" in
+        full) secure_boot=true ;;
+        -) ;;
+        *) secure_boot=false ;;
+    esac
+    report_append "- Firmware: \`${firmware_version:--}\` (OS loader: \`${os_loader_version:--}\`, chip: ${boot_chip:-unknown})"
+    report_append "- Startup Security: **${boot_security/#-/unknown}** (third-party kernel extensions: **${third_party_kexts/#-/unknown}**)"
+    [ "${firmware_version:--}" != "-" ] || firmware_version=""
+    [ "${os_loader_version:--}" != "-" ] || os_loader_version=""
+    [ "${boot_security:--}" != "-" ] || boot_security=""
+    [ "${third_party_kexts:--}" != "-" ] || third_party_kexts=null
+    report_append "- XProtect version: \`${xprotect_version:-unknown}\` (malware data stale: **$malware_data_stale**)"
     report_append "- Firewall enabled: **$firewall**"
     report_append "- Remote Login (SSH): \`$remote_login\`"
     report_append "- Screen lock delay: \`$screen_lock_delay\`"
     report_append "- Auto updates: \`$auto_updates\`"
-    append_ndjson_line "{\"type\":\"security_config\",\"run_id\":$(json_escape "$RUN_ID"),\"filevault\":$filevault,\"sip\":$sip,\"gatekeeper\":$gatekeeper,\"firewall\":$firewall}"
+    report_append "- MDM enrolled: **${mdm_enrolled:-false}** (DEP: **${dep_enrolled:-false}**, user approved: **${mdm_user_approved:-false}**)"
+    report_append "- MDM server: \`${mdm_server:-none}\`"
+    report_append "- Configuration profiles: **$profile_count**"
+
+    # Time synchronization: a drifting clock breaks log correlation across
+    # hosts and certificate validity checks.
+    [[ "${TIME_OFFSET_MAX_MS:-}" =~ ^[0-9]+$ ]] || TIME_OFFSET_MAX_MS=1000
+    local time_service time_sync time_synchronized time_offset time_servers time_offset_exceeded=false
+    IFS=$'\t' read -r time_service time_sync time_synchronized time_offset time_servers < <(mac_time_sync config) || true
+    if [ "${time_offset:--}" != "-" ] && awk -v o="$time_offset" -v m="$TIME_OFFSET_MAX_MS" 'BEGIN { exit ((o < 0 ? -o : o) > m) ? 0 : 1 }'; then
+        time_offset_exceeded=true
+    fi
+    local time_offset_text="unknown"
+    [ "${time_offset:--}" = "-" ] || time_offset_text="$time_offset ms"
+    report_append "- Time sync: **${time_sync/#-/unknown}** (\`${time_service:-none}\`, synchronized: **${time_synchronized/#-/unknown}**, offset: \`$time_offset_text\`, servers: \`${time_servers:--}\`)"
+    [ "${time_sync:--}" != "-" ] || time_sync=null
+    [ "${time_synchronized:--}" != "-" ] || time_synchronized=null
+    [ "${time_offset:--}" != "-" ] || time_offset=null
+    [ "${time_servers:--}" != "-" ] || time_servers=""
+    if [ "$time_sync" = false ]; then
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"time_sync_disabled\",\"service\":$(json_escape "$time_service")}"
+    fi
+    if $time_offset_exceeded; then
+        report_append "- ⚠️ Clock offset exceeds $TIME_OFFSET_MAX_MS ms (\`TIME_OFFSET_MAX_MS\`)"
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"time_offset_exceeded\",\"offset_ms\":$time_offset,\"max_ms\":$TIME_OFFSET_MAX_MS}"
+    fi
+    append_ndjson_line "{\"type\":\"security_config\",\"run_id\":$(json_escape "$RUN_ID"),\"filevault\":$filevault,\"sip\":$sip,\"gatekeeper\":$gatekeeper,\"secure_boot\":$secure_boot,\"boot_security\":$(json_escape "$boot_security"),\"third_party_kexts\":$third_party_kexts,\"boot_chip\":$(json_escape "$boot_chip"),\"firmware_version\":$(json_escape "$firmware_version"),\"os_loader_version\":$(json_escape "$os_loader_version"),\"firewall\":$firewall,\"mdm_enrolled\":${mdm_enrolled:-false},\"dep_enrolled\":${dep_enrolled:-false},\"mdm_user_approved\":${mdm_user_approved:-false},\"mdm_server\":$(json_escape "$mdm_server"),\"config_profiles\":$profile_count,\"xprotect_version\":$(json_escape "$xprotect_version"),\"malware_data_stale\":$malware_data_stale,\"time_sync\":$time_sync,\"time_sync_service\":$(json_escape "$time_service"),\"time_synchronized\":$time_synchronized,\"time_offset_ms\":$time_offset,\"time_offset_exceeded\":$time_offset_exceeded,\"time_servers\":$(json_escape "$time_servers")}"
     section_end_ms=$(now_ms)
     emit_timing "security_defaults" "$section_start_ms" "$section_end_ms"
 
+    # Per-volume encryption: FileVault covers only the boot volumes, while
+    # data leaks from the secondary and external volumes.
+    section_start_ms=$(now_ms)
+    section_header "💽 Disk Volumes"
+    local volume_items="" volume_count=0 unencrypted_count=0 unencrypted_mounts="" vol_mount vol_device vol_fstype vol_enc vol_removable vol_encrypted
+    while IFS=$'\t' read -r vol_mount vol_device vol_fstype vol_enc vol_removable; do
+        [ -n "$vol_mount" ] || continue
+        if [ -z "$volume_items" ]; then
+            report_append "| Mount | Device | Filesystem | Encryption | Removable |"
+            report_append "|-------|--------|------------|------------|-----------|"
+        fi
+        report_append "| \`$(md_path "$vol_mount")\` | \`$vol_device\` | $vol_fstype | ${vol_enc/#-/unknown} | $vol_removable |"
+        case "$vol_enc" in
+            none) vol_encrypted=false ;;
+            -) vol_encrypted=null ;;
+            *) vol_encrypted=true ;;
+        esac
+        # The sealed system volume holds no user data; FileVault protects
+        # the Data volume mounted beside it.
+        if [ "$vol_encrypted" = false ]; then
+            case "$vol_mount" in
+                /) ;;
+                *)
+                    unencrypted_count=$((unencrypted_count + 1))
+                    unencrypted_mounts="${unencrypted_mounts:+$unencrypted_mounts,}$(json_escape "$vol_mount")"
+                    ;;
+            esac
+        fi
+        volume_count=$((volume_count + 1))
+        [ -n "$volume_items" ] && volume_items+=","
+        volume_items+="{\"mount\":$(json_escape "$vol_mount"),\"device\":$(json_escape "$vol_device"),\"fstype\":$(json_escape "$vol_fstype"),\"encryption\":$(json_escape "${vol_enc/#-/}"),\"encrypted\":$vol_encrypted,\"removable\":$vol_removable}"
+    done < <(mac_disk_volumes config || true)
+    if (( volume_count == 0 )); then
+        report_append "_No mounted volumes discovered._"
+    elif (( unencrypted_count > 0 )); then
+        report_append ""
+        report_append "- ⚠️ Unencrypted volumes (besides the system volume): **$unencrypted_count**"
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"unencrypted_volume\",\"count\":$unencrypted_count,\"mounts\":[${unencrypted_mounts}]}"
+    fi
+    append_ndjson_line "{\"type\":\"disk_volumes\",\"run_id\":$(json_escape "$RUN_ID"),\"volumes\":$volume_count,\"unencrypted\":$unencrypted_count,\"items\":[${volume_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "disk_volumes" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🗂️ Configuration Profiles"
+    local profile_items="" profile_scope profile_id profile_name profile_org profile_verified profile_types profile_server types_json ptype
+    while IFS=$'\t' read -r profile_scope profile_id profile_name profile_org profile_verified profile_types profile_server; do
+        [ -n "$profile_id" ] || continue
+        [ "$profile_name" != "-" ] || profile_name=""
+        [ "$profile_org" != "-" ] || profile_org=""
+        [ "$profile_types" != "-" ] || profile_types=""
+        if [ -z "$profile_items" ]; then
+            report_append "| Scope | Identifier | Name | Organization | Payload types | Verified |"
+            report_append "|-------|------------|------|--------------|---------------|----------|"
+        fi
+        report_append "| \`$profile_scope\` | \`$profile_id\` | $profile_name | $profile_org | ${profile_types//,/, } | $profile_verified |"
+        types_json=""
+        for ptype in ${profile_types//,/ }; do
+            types_json="${types_json:+$types_json,}$(json_escape "$ptype")"
+        done
+        item="{\"scope\":$(json_escape "$profile_scope"),\"identifier\":$(json_escape "$profile_id"),\"name\":$(json_escape "$profile_name"),\"organization\":$(json_escape "$profile_org"),\"payload_types\":[${types_json}],\"verified\":$profile_verified}"
+        if [ -z "$profile_items" ]; then
+            profile_items="$item"
+        else
+            profile_items="${profile_items},${item}"
+        fi
+    done <<< "$profile_lines"
+    if [ -z "$profile_items" ]; then
+        report_append "_No configuration profiles installed._"
+    fi
+    append_ndjson_line "{\"type\":\"config_profiles\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$profile_count,\"mdm_enrolled\":${mdm_enrolled:-false},\"items\":[${profile_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "config_profiles" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🦠 Malware Protection Data"
+    local malware_items="" malware_component malware_version malware_updated malware_age malware_stale malware_date age_cell
+    while IFS=$'\t' read -r malware_component malware_version malware_updated malware_age malware_stale; do
+        [ -n "$malware_component" ] || continue
+        [ "$malware_version" != "-" ] || malware_version=""
+        malware_date=""
+        if (( malware_updated > 0 )); then
+            malware_date="$(date -u -r "$malware_updated" +"%Y-%m-%dT%H:%M:%SZ" 2>/dev/null || true)"
+        fi
+        if [ -z "$malware_items" ]; then
+            report_append "| Component | Version | Updated | Age (days) |"
+            report_append "|-----------|---------|---------|------------|"
+        fi
+        age_cell="$malware_age"
+        if [ "$malware_stale" = true ]; then
+            age_cell="**$malware_age** ⚠️"
+            append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"malware_data_stale\",\"component\":$(json_escape "$malware_component"),\"age_days\":$malware_age}"
+        fi
+        report_append "| $malware_component | ${malware_version:-unknown} | ${malware_date:-unknown} | $age_cell |"
+        item="{\"component\":$(json_escape "$malware_component"),\"version\":$(json_escape "$malware_version"),\"updated\":$(json_escape "$malware_date"),\"age_days\":$malware_age,\"stale\":$malware_stale}"
+        if [ -z "$malware_items" ]; then
+            malware_items="$item"
+        else
+            malware_items="${malware_items},${item}"
+        fi
+    done <<< "$malware_lines"
+    if [ -z "$malware_items" ]; then
+        report_append "_No XProtect, MRT, or Gatekeeper data bundles found._"
+    else
+        report_append ""
+        report_append "_Data older than $MALWARE_DATA_MAX_AGE_DAYS days (\`MALWARE_DATA_MAX_AGE_DAYS\`) is flagged as stale._"
+    fi
+    append_ndjson_line "{\"type\":\"malware_protection\",\"run_id\":$(json_escape "$RUN_ID"),\"max_age_days\":$MALWARE_DATA_MAX_AGE_DAYS,\"stale\":$malware_data_stale,\"items\":[${malware_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "malware_protection" "$section_start_ms" "$section_end_ms"
+
     section_start_ms=$(now_ms)
     section_header "🌍 Environment Overview"
     path_value="${PATH:-}"
@@ -160,6 +343,218 @@ run_config_audit() {
     section_end_ms=$(now_ms)
     emit_timing "homebrew_summary" "$section_start_ms" "$section_end_ms"
 
+    section_start_ms=$(now_ms)
+    section_header "📱 Applications"
+    local app_lines app_items app_count unsigned_apps unsigned_count unsigned_names unsigned_app
+    app_lines="$(mac_applications "config")"
+    app_count="$(printf '%s\n' "$app_lines" | awk -F'\t' 'NF {c++} END {print c+0}')"
+    app_items="$(printf '%s\n' "$app_lines" | awk -F'\t' "$_AWK_JSON_ESC"'
+        function flag(s) { return (s == "-" || s == "") ? "null" : s }
+        NF {
+            printf "%s{\"name\":\"%s\",\"version\":\"%s\",\"bundle_id\":\"%s\",\"path\":\"%s\",\"scope\":\"%s\",\"team_id\":\"%s\",\"signed\":%s,\"notarized\":%s,\"gatekeeper\":\"%s\"}", (n++ ? "," : ""), json_esc($1), json_esc($2), json_esc($3), json_esc($4), json_esc($5), json_esc($6), flag($7), flag($8), ($9 == "-" ? "" : json_esc($9))
+        }')"
+    unsigned_apps="$(printf '%s\n' "$app_lines" | awk -F'\t' 'NF && $7 == "false" { print $1 }')"
+    unsigned_count="$(printf '%s\n' "$unsigned_apps" | awk 'NF {c++} END {print c+0}')"
+    report_append "- Applications in /Applications and ~/Applications: **$app_count**"
+    report_append "- Unsigned or ad-hoc signed: **$unsigned_count**"
+    if (( unsigned_count > 0 )); then
+        while IFS= read -r unsigned_app; do
+            [ -n "$unsigned_app" ] || continue
+            report_append "  - ⚠️ \`$unsigned_app\`"
+        done <<< "$unsigned_apps"
+        unsigned_names="$(printf '%s\n' "$unsigned_apps" | awk "$_AWK_JSON_ESC"'
+            NF { printf "%s\"%s\"", (n++ ? "," : ""), json_esc($0) }')"
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"unsigned_application\",\"count\":$unsigned_count,\"names\":[${unsigned_names}]}"
+    fi
+    append_ndjson_line "{\"type\":\"applications\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$app_count,\"unsigned\":$unsigned_count,\"items\":[${app_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "applications" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🛍️ App Store Applications"
+    local mas_items="" mas_count=0 mas_name mas_version mas_bundle_id mas_adam_id mas_path
+    while IFS=$'\t' read -r mas_name mas_version mas_bundle_id mas_adam_id mas_path; do
+        [ -n "$mas_name" ] || continue
+        [ "$mas_version" != "-" ] || mas_version=""
+        [ "$mas_bundle_id" != "-" ] || mas_bundle_id=""
+        [ "$mas_adam_id" != "-" ] || mas_adam_id=""
+        if (( mas_count == 0 )); then
+            report_append "| Application | Version | Bundle ID | App Store ID |"
+            report_append "|-------------|---------|-----------|--------------|"
+        fi
+        report_append "| $mas_name | ${mas_version:-?} | \`${mas_bundle_id:-?}\` | ${mas_adam_id:-?} |"
+        [ -n "$mas_items" ] && mas_items+=","
+        mas_items+="{\"name\":$(json_escape "$mas_name"),\"version\":$(json_escape "$mas_version"),\"bundle_id\":$(json_escape "$mas_bundle_id"),\"adam_id\":$(json_escape "$mas_adam_id"),\"path\":$(json_escape "$mas_path")}"
+        mas_count=$((mas_count + 1))
+    done < <(mac_app_store_apps "config" || true)
+    if (( mas_count == 0 )); then
+        report_append "_No App Store applications found._"
+    fi
+    append_ndjson_line "{\"type\":\"app_store_apps\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${mas_count},\"items\":[${mas_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "app_store_apps" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🧰 Developer Toolchains"
+    local tool_items="" tool_count=0 tool_name tool_version tool_path tool_source tool_major tool_minor safe_tool_path
+    while IFS=$'\t' read -r tool_name tool_version tool_path tool_source; do
+        [ -n "$tool_name" ] || continue
+        # Java 8 and older report 1.<major>.
+        IFS=. read -r tool_major tool_minor _ <<< "${tool_version//_/.}"
+        if [ "$tool_name" = "java" ] && [ "$tool_major" = "1" ]; then
+            IFS=. read -r _ tool_major tool_minor _ <<< "${tool_version//_/.}"
+        fi
+        safe_tool_path="$(redact_path_for_ndjson "$tool_path")"
+        if (( tool_count == 0 )); then
+            report_append "| Tool | Version | Source | Path |"
+            report_append "|------|---------|--------|------|"
+        fi
+        report_append "| $tool_name | $tool_version | $tool_source | \`$safe_tool_path\` |"
+        item="{\"tool\":$(json_escape "$tool_name"),\"version\":$(json_escape "$tool_version"),\"major\":$((10#${tool_major:-0})),\"minor\":$((10#${tool_minor:-0})),\"path\":$(json_escape "$safe_tool_path"),\"source\":$(json_escape "$tool_source")}"
+        if [ -z "$tool_items" ]; then
+            tool_items="$item"
+        else
+            tool_items="${tool_items},${item}"
+        fi
+        tool_count=$((tool_count + 1))
+    done < <(mac_dev_toolchains "config")
+    if (( tool_count == 0 )); then
+        report_append "_No developer toolchains found on PATH._"
+    fi
+    append_ndjson_line "{\"type\":\"dev_toolchains\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${tool_count},\"items\":[${tool_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "dev_toolchains" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "📦 Language Packages"
+    local lang_items="" lang_count=0 lang_managers="" lang_manager lang_name lang_version
+    while IFS=$'\t' read -r lang_manager lang_name lang_version; do
+        [ -n "$lang_name" ] || continue
+        if (( lang_count == 0 )); then
+            report_append "| Manager | Package | Version |"
+            report_append "|---------|---------|---------|"
+        fi
+        report_append "| $lang_manager | \`$(md_path "$lang_name")\` | ${lang_version//,/, } |"
+        case ",$lang_managers," in
+            *",$lang_manager,"*) ;;
+            *) lang_managers="${lang_managers:+$lang_managers,}$lang_manager" ;;
+        esac
+        [ -n "$lang_items" ] && lang_items+=","
+        lang_items+="{\"manager\":$(json_escape "$lang_manager"),\"name\":$(json_escape "$lang_name"),\"version\":$(json_escape "$lang_version")}"
+        lang_count=$((lang_count + 1))
+    done < <(language_packages "config" || true)
+    if (( lang_count == 0 )); then
+        report_append "_No global npm, pipx, pip --user, gem, or cargo packages found._"
+    fi
+    append_ndjson_line "{\"type\":\"language_packages\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${lang_count},\"managers\":$(json_escape "$lang_managers"),\"items\":[${lang_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "language_packages" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🧬 Language Runtimes"
+    local rt_items="" rt_count=0 rt_name rt_version rt_path rt_manager rt_active rt_major rt_minor safe_rt_path
+    while IFS=$'\t' read -r rt_name rt_version rt_path rt_manager rt_active; do
+        [ -n "$rt_name" ] || continue
+        [ "$rt_version" != "-" ] || rt_version=""
+        # Java 8 and older report 1.<major>.
+        IFS=. read -r rt_major rt_minor _ <<< "${rt_version//_/.}"
+        if [ "$rt_name" = "java" ] && [ "$rt_major" = "1" ]; then
+            IFS=. read -r _ rt_major rt_minor _ <<< "${rt_version//_/.}"
+        fi
+        safe_rt_path="$(redact_path_for_ndjson "$rt_path")"
+        if (( rt_count == 0 )); then
+            report_append "| Runtime | Version | Manager | Active | Path |"
+            report_append "|---------|---------|---------|--------|------|"
+        fi
+        report_append "| $rt_name | ${rt_version:-?} | $rt_manager | $rt_active | \`$safe_rt_path\` |"
+        [ -n "$rt_items" ] && rt_items+=","
+        rt_items+="{\"runtime\":$(json_escape "$rt_name"),\"version\":$(json_escape "$rt_version"),\"major\":$((10#${rt_major:-0})),\"minor\":$((10#${rt_minor:-0})),\"path\":$(json_escape "$safe_rt_path"),\"manager\":$(json_escape "$rt_manager"),\"active\":$rt_active}"
+        rt_count=$((rt_count + 1))
+    done < <(runtime_installs "config" || true)
+    if (( rt_count == 0 )); then
+        report_append "_No python3, node, go, java, or ruby installs found._"
+    fi
+    append_ndjson_line "{\"type\":\"runtimes\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${rt_count},\"items\":[${rt_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "runtimes" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🧩 IDE Extensions"
+    local ext_items="" ext_count=0 ext_sideloaded=0 ext_users="" ext_user ext_ide ext_id ext_version ext_publisher ext_publisher_id ext_source ext_marketplace
+    while IFS=$'\t' read -r ext_user ext_ide ext_id ext_version ext_publisher ext_publisher_id ext_source ext_marketplace; do
+        [ -n "$ext_id" ] || continue
+        [ "$ext_version" != "-" ] || ext_version=""
+        [ "$ext_publisher" != "-" ] || ext_publisher=""
+        [ "$ext_publisher_id" != "-" ] || ext_publisher_id=""
+        [ "$ext_marketplace" != "-" ] || ext_marketplace=""
+        if (( ext_count == 0 )); then
+            report_append "| User | IDE | Extension | Version | Publisher | Source |"
+            report_append "|------|-----|-----------|---------|-----------|--------|"
+        fi
+        case "$ext_source" in
+            vsix|unlisted)
+                ext_sideloaded=$((ext_sideloaded + 1))
+                report_append "| \`$ext_user\` | $ext_ide | \`$ext_id\` | $ext_version | $ext_publisher | **$ext_source** ⚠️ |"
+                ;;
+            *) report_append "| \`$ext_user\` | $ext_ide | \`$ext_id\` | $ext_version | $ext_publisher | $ext_source |" ;;
+        esac
+        item="{\"user\":$(json_escape "$ext_user"),\"ide\":$(json_escape "$ext_ide"),\"id\":$(json_escape "$ext_id"),\"version\":$(json_escape "$ext_version"),\"publisher\":$(json_escape "$ext_publisher"),\"publisher_id\":$(json_escape "$ext_publisher_id"),\"source\":$(json_escape "$ext_source"),\"marketplace\":$(json_escape "$ext_marketplace")}"
+        if [ -z "$ext_items" ]; then
+            ext_items="$item"
+        else
+            ext_items="${ext_items},${item}"
+        fi
+        case " $ext_users " in
+            *" $ext_user "*) ;;
+            *) ext_users="$ext_users $ext_user" ;;
+        esac
+        ext_count=$((ext_count + 1))
+    done < <({ user_homes config; printf '%s\t%s\n' "$CURRENT_USER" "$HOME_DIR"; } | awk -F'\t' '!seen[$2]++' | while IFS=$'\t' read -r ext_user ext_home; do ide_extensions "$ext_user" "$ext_home"; done)
+    if (( ext_count == 0 )); then
+        report_append "_No IDE extensions found._"
+    elif (( ext_sideloaded > 0 )); then
+        report_append ""
+        report_append "- ⚠️ **$ext_sideloaded** extension(s) were not installed from a marketplace."
+    fi
+    append_ndjson_line "{\"type\":\"ide_extensions\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${ext_count},\"users\":$(echo "$ext_users" | awk '{print NF}'),\"sideloaded\":${ext_sideloaded},\"items\":[${ext_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "ide_extensions" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "📜 Certificates"
+    local cert_lines cert_fields cert_items
+    [[ "${CERT_EXPIRY_DAYS:-}" =~ ^[0-9]+$ ]] || CERT_EXPIRY_DAYS=30
+    cert_lines="$(mac_certificates config)"
+    if [ -n "$cert_lines" ]; then
+        cert_fields="$(printf '%s\n' "$cert_lines" | awk -F'\t' -v n="$CERT_EXPIRY_DAYS" '
+            { count++ }
+            $8 == "true" { added++; if ($7 == "true") added_roots++ }
+            $5 < 0 { expired++ }
+            $5 >= 0 && $5 <= n { expiring++ }
+            END { printf "\"count\":%d,\"user_added\":%d,\"user_added_roots\":%d,\"expiring\":%d,\"expired\":%d", count, added, added_roots, expiring, expired }')"
+        cert_items="$(printf '%s\n' "$cert_lines" | certificate_items)"
+        while IFS= read -r line; do report_append "$line"; done < <(printf '%s\n' "$cert_lines" | awk -F'\t' -v n="$CERT_EXPIRY_DAYS" '
+            { count++ }
+            $8 == "true" || $5 <= n {
+                flags = ($8 == "true") ? ($7 == "true" ? "⚠️ user-added root CA" : "user-added") : ""
+                if ($5 <= n) flags = flags (flags == "" ? "" : ", ") ($5 < 0 ? "expired" : "expiring")
+                flagged[++f] = "| " $1 " | " $2 " | " $4 " (" $5 " days) | " flags " |"
+            }
+            END {
+                print "- Certificates: **" count "**, expiry window: **" n " days**"
+                print ""
+                if (!f) { print "_No user-added, expiring, or expired certificates._"; exit }
+                print "| Store | Subject | Expires | Flags |"
+                print "|-------|---------|---------|-------|"
+                for (i = 1; i <= f; i++) print flagged[i]
+            }')
+        append_ndjson_line "{\"type\":\"certificates\",\"run_id\":$(json_escape "$RUN_ID"),${cert_fields},\"expiry_days\":$CERT_EXPIRY_DAYS,\"items\":[${cert_items}]}"
+    else
+        report_append "_No trust store readable (openssl not installed?)._"
+    fi
+    section_end_ms=$(now_ms)
+    emit_timing "certificates" "$section_start_ms" "$section_end_ms"
+
     section_start_ms=$(now_ms)
     section_header "📄 Shell Profile Files"
     report_append "Existing shell profile files:"
@@ -177,6 +572,38 @@ run_config_audit() {
     section_end_ms=$(now_ms)
     emit_timing "shell_profile_files" "$section_start_ms" "$section_end_ms"
 
+    section_start_ms=$(now_ms)
+    section_header "📌 Watched Dotfiles"
+    local dotfiles_count=0 dotfiles_missing=0 dotfile_items="" dotfile_path dotfile_mode dotfile_sha safe_dotfile
+    while IFS=$'\t' read -r dotfile_path dotfile_mode dotfile_sha; do
+        [ -n "$dotfile_path" ] || continue
+        if (( dotfiles_count + dotfiles_missing == 0 )); then
+            report_append "| File | Mode | SHA-256 |"
+            report_append "|------|------|---------|"
+        fi
+        safe_dotfile="$(redact_path_for_ndjson "$dotfile_path")"
+        if [ "$dotfile_mode" = "-" ]; then
+            report_append "| \`$safe_dotfile\` | - | _missing_ |"
+            dotfiles_missing=$((dotfiles_missing + 1))
+            continue
+        fi
+        [ "$dotfile_sha" != "-" ] || dotfile_sha=""
+        report_append "| \`$safe_dotfile\` | $dotfile_mode | \`${dotfile_sha:0:12}\` |"
+        item="{\"path\":$(json_escape "$safe_dotfile"),\"mode\":$(json_escape "$dotfile_mode"),\"sha256\":$(json_escape "$dotfile_sha")}"
+        if [ -z "$dotfile_items" ]; then
+            dotfile_items="$item"
+        else
+            dotfile_items="${dotfile_items},${item}"
+        fi
+        dotfiles_count=$((dotfiles_count + 1))
+    done < <(watched_dotfiles)
+    if (( dotfiles_count + dotfiles_missing == 0 )); then
+        report_append "_No watched dotfiles. List them one per line in \`~/.osaudit/dotfiles\`._"
+    fi
+    append_ndjson_line "{\"type\":\"watched_dotfiles\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${dotfiles_count},\"missing\":${dotfiles_missing},\"items\":[${dotfile_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "watched_dotfiles" "$section_start_ms" "$section_end_ms"
+
     append_ndjson_line "{\"type\":\"config_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"shell\":$(json_escape "${SHELL:-unknown}"),\"profile_files\":${profile_files_count:-0},\"homebrew_installed\":$homebrew_installed}"
 }
 
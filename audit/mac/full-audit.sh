@@ -13,7 +13,11 @@ audit_set_defaults_if_unset "full-audit"
 
 LARGE_FILE_THRESHOLD_MB="${LARGE_FILE_THRESHOLD_MB:-100}"
 OLD_FILE_DAYS="${OLD_FILE_DAYS:-180}"
+CERT_EXPIRY_DAYS="${CERT_EXPIRY_DAYS:-30}"
+MALWARE_DATA_MAX_AGE_DAYS="${MALWARE_DATA_MAX_AGE_DAYS:-30}"
+TIME_OFFSET_MAX_MS="${TIME_OFFSET_MAX_MS:-1000}"
 DEEP_SCAN="${DEEP_SCAN:-false}"
+ONE_FILESYSTEM="${ONE_FILESYSTEM:-false}"
 ROOTS_OVERRIDE_RAW="${ROOTS_OVERRIDE_RAW:-}"
 HEATMAP_EMIT_TOPN="${HEATMAP_EMIT_TOPN:-100}"
 declare -a METADATA_NOTES=()
@@ -30,10 +34,12 @@ Options:
   --threshold-mb <int>   Large file threshold in MB (default: 100)
   --old-days <int>       Stale file threshold in days (default: 180)
   --deep                 Scan full home dir (pruned for Library/.Trash/.git/node_modules)
+  --one-filesystem       Do not descend into other filesystems or bind mounts
   --ndjson               Also write a compact NDJSON summary file
   --redact-paths         Redact NDJSON paths (default: on when --ndjson)
   --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
   --redact-all           Redact all sensitive data (user, hostname, tokens, commands)
+  --no-redact            Redact nothing, overriding redact in config.yaml
   --no-color             Disable ANSI colors in terminal output
   -h, --help             Show this help and exit
 EOF
@@ -85,6 +91,10 @@ while (($# > 0)); do
             DEEP_SCAN=true
             shift
             ;;
+        --one-filesystem)
+            ONE_FILESYSTEM=true
+            shift
+            ;;
         --ndjson)
             WRITE_NDJSON=true
             shift
@@ -101,6 +111,11 @@ while (($# > 0)); do
             REDACT_ALL=true
             shift
             ;;
+        --no-redact)
+            REDACT_ALL=false
+            REDACT_PATHS_MODE="off"
+            shift
+            ;;
         --no-color)
             NO_COLOR=true
             shift
@@ -159,6 +174,17 @@ TOP_PATHS_FILE="${TOP_PATHS_FILE:-$REPORT_DIR/.full-audit-top-paths-$TIMESTAMP_F
 
 source "$(dirname "$0")/lib/common.sh"
 audit_set_run_meta_trap "full"
+for collector in storage network identity config execution persistence security; do
+    if ! collector_enabled "$collector"; then
+        # osaudit run --scope disables the collectors it cannot scope, and
+        # osaudit triage those outside its subset.
+        disabled_by="configuration"
+        [ -z "${OSAUDIT_SCOPE:-}" ] || disabled_by="configuration or --scope"
+        [ -z "${OSAUDIT_TRIAGE_HOURS:-}" ] || disabled_by="configuration or osaudit triage"
+        METADATA_NOTES+=("Collector $collector disabled by $disabled_by")
+        NDJSON_PENDING_NOTES+=("Collector $collector disabled by $disabled_by")
+    fi
+done
 
 echo -e "${BOLD}${CYAN}"
 echo "╔══════════════════════════════════════════════════╗"
@@ -195,11 +221,12 @@ STORAGE_HEADER_READY=true
 if [ -n "$NDJSON_FILE" ]; then
     : > "$NDJSON_FILE"
     scan_mode="scoped"
-    if $DEEP_SCAN && [ -z "$ROOTS_OVERRIDE_RAW" ]; then
+    if $DEEP_SCAN && [ -z "$ROOTS_OVERRIDE_RAW" ] && [ -z "${OSAUDIT_SCOPE:-}" ]; then
         scan_mode="deep"
     fi
     append_ndjson_line "{\"type\":\"meta\",\"run_id\":$(json_escape "$RUN_ID"),\"schema_version\":\"0.1\",\"tool_name\":\"operating-system-audit\",\"tool_component\":\"full-audit\",\"timestamp\":$(json_escape "$ISO_TIMESTAMP"),\"hostname\":$(json_escape "$HOSTNAME_VAL"),\"user\":$(json_escape "$CURRENT_USER"),\"os_version\":$(json_escape "$OS_VERSION"),\"kernel\":$(json_escape "$KERNEL_INFO"),\"path\":$(json_escape "$(get_audit_path_for_output)")}"
     append_ndjson_line "{\"type\":\"scan\",\"run_id\":$(json_escape "$RUN_ID"),\"mode\":$(json_escape "$scan_mode"),\"threshold_mb\":$LARGE_FILE_THRESHOLD_MB,\"old_days\":$OLD_FILE_DAYS,\"redact_paths\":$([ "$REDACT_PATHS" = true ] && echo true || echo false)}"
+    emit_scope
     STORAGE_NDJSON_INITIALIZED=true
 fi
 
@@ -209,6 +236,7 @@ source "$(dirname "$0")/identity.sh"
 source "$(dirname "$0")/config.sh"
 source "$(dirname "$0")/execution.sh"
 source "$(dirname "$0")/persistence.sh"
+source "$(dirname "$0")/security.sh"
 storage_build_scan_roots
 storage_prepare_files_and_common
 for note in "${NDJSON_PENDING_NOTES[@]+"${NDJSON_PENDING_NOTES[@]}"}"; do
@@ -216,24 +244,45 @@ for note in "${NDJSON_PENDING_NOTES[@]+"${NDJSON_PENDING_NOTES[@]}"}"; do
         append_ndjson_line "{\"type\":\"note\",\"run_id\":$(json_escape "$RUN_ID"),\"message\":$(json_escape "$note")}"
     fi
 done
-run_storage_audit
-if ! run_network_audit; then
-    append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"network_audit_failed\"}"
+if collector_enabled storage; then
+    collector_started storage
+    run_storage_audit
+    collector_finished storage
+fi
+if collector_enabled network; then
+    collector_started network
+    run_network_audit || append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"network_audit_failed\"}"
+    collector_finished network
 fi
-if ! run_identity_audit; then
-    append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"identity_audit_failed\"}"
+if collector_enabled identity; then
+    collector_started identity
+    run_identity_audit || append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"identity_audit_failed\"}"
+    collector_finished identity
 fi
-if ! run_config_audit; then
-    append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"config_audit_failed\"}"
+if collector_enabled config; then
+    collector_started config
+    run_config_audit || append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"config_audit_failed\"}"
+    collector_finished config
 fi
-if ! run_execution_audit; then
-    append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"execution_audit_failed\"}"
+if collector_enabled execution; then
+    collector_started execution
+    run_execution_audit || append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"execution_audit_failed\"}"
+    collector_finished execution
 fi
-if ! run_persistence_audit; then
-    append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"persistence_audit_failed\"}"
+if collector_enabled persistence; then
+    collector_started persistence
+    run_persistence_audit || append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"persistence_audit_failed\"}"
+    collector_finished persistence
+fi
+if collector_enabled security; then
+    collector_started security
+    run_security_audit || append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"security_audit_failed\"}"
+    collector_finished security
 fi
 
-emit_recommendations
+if collector_enabled storage; then
+    emit_recommendations
+fi
 
 echo -e "\n${BOLD}${GREEN}"
 echo "╔══════════════════════════════════════════════════╗"
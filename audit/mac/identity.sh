@@ -18,6 +18,7 @@ Options:
   --redact-paths         Redact NDJSON paths (default: on when --ndjson)
   --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
   --redact-all           Redact all sensitive text (implies --redact-paths)
+  --no-redact            Redact nothing, overriding redact in config.yaml
   --no-color             Disable ANSI colors in terminal output
   -h, --help             Show this help and exit
 EOF
@@ -167,19 +168,230 @@ run_identity_audit() {
     fi
     append_ndjson_line "{\"type\":\"ssh_keys\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${ssh_keys_count:-0},\"items\":[${ssh_key_items}]}"
 
-    local auth_keys_count=0
-    if [ -f "$ssh_dir/authorized_keys" ]; then
-        auth_keys_count=$(awk 'NF && $1 !~ /^#/ {c++} END{print c+0}' "$ssh_dir/authorized_keys" 2>/dev/null || true)
-    fi
-    local ssh_hosts_count=0
-    if [ -f "$ssh_dir/config" ]; then
-        ssh_hosts_count=$(awk 'tolower($1)=="host" && $2 !~ /^\*/ {c++} END{print c+0}' "$ssh_dir/config" 2>/dev/null || true)
+    local ssh_hosts_count=0 ssh_host_items=""
+    report_append ""
+    report_append "| \`~/.ssh/config\` Host | HostName | User | Port | ProxyJump |"
+    report_append "|----------------------|----------|------|------|-----------|"
+    while IFS=$'\t' read -r host_alias host_name host_user host_port identity_file proxy_jump; do
+        [ -n "$host_alias" ] || continue
+        for field in host_name host_user host_port identity_file proxy_jump; do
+            [ "${!field}" = "-" ] && printf -v "$field" '%s' ""
+        done
+        if [[ "${REDACT_ALL:-false}" == "true" && -n "$host_name" ]]; then
+            host_name="<host>"
+        fi
+        report_append "| \`$host_alias\` | ${host_name:--} | ${host_user:--} | ${host_port:--} | ${proxy_jump:--} |"
+        item="{\"host\":$(json_escape "$host_alias"),\"hostname\":$(json_escape "$host_name"),\"user\":$(json_escape "$host_user"),\"port\":$(json_escape "$host_port"),\"identity_file\":$(json_escape "$identity_file"),\"proxy_jump\":$(json_escape "$proxy_jump")}"
+        if [ -z "$ssh_host_items" ]; then
+            ssh_host_items="$item"
+        else
+            ssh_host_items="${ssh_host_items},${item}"
+        fi
+        ssh_hosts_count=$((ssh_hosts_count + 1))
+    done < <(ssh_client_hosts "$ssh_dir/config")
+    if (( ssh_hosts_count == 0 )); then
+        report_append "_No host entries in \`~/.ssh/config\`._"
     fi
-    report_append "- \`authorized_keys\` entries: **${auth_keys_count:-0}**"
-    report_append "- \`~/.ssh/config\` host entries: **${ssh_hosts_count:-0}**"
+    append_ndjson_line "{\"type\":\"ssh_config_hosts\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${ssh_hosts_count:-0},\"items\":[${ssh_host_items}]}"
     section_end_ms=$(now_ms)
     emit_timing "ssh_inventory" "$section_start_ms" "$section_end_ms"
 
+    section_start_ms=$(now_ms)
+    section_header "🗝️ Authorized SSH Keys"
+    local auth_keys_count=0 auth_key_items="" auth_key_files="" oldest_days=0 cert_authority_keys=0
+    report_append "| User | File | Type | Fingerprint | Options | Age (days) |"
+    report_append "|------|------|------|-------------|---------|------------|"
+    while IFS=$'\t' read -r key_user key_file key_type fingerprint key_options age_days cert_authority; do
+        [ -n "$key_user" ] || continue
+        key_type="${key_type#ssh-}"
+        if [[ "${REDACT_ALL:-false}" == "true" && "$fingerprint" != "unknown" ]]; then
+            fingerprint="<fingerprint>"
+        fi
+        safe_file="$(redact_path_for_ndjson "$key_file")"
+        report_append "| \`$key_user\` | \`$safe_file\` | $key_type | \`$fingerprint\` | $key_options | $age_days |"
+        item="{\"user\":$(json_escape "$key_user"),\"file\":$(json_escape "$safe_file"),\"type\":$(json_escape "$key_type"),\"fingerprint\":$(json_escape "$fingerprint"),\"options\":$key_options,\"cert_authority\":${cert_authority:-false},\"age_days\":$age_days}"
+        if [ -z "$auth_key_items" ]; then
+            auth_key_items="$item"
+        else
+            auth_key_items="${auth_key_items},${item}"
+        fi
+        case " $auth_key_files " in
+            *" $key_file "*) ;;
+            *) auth_key_files="$auth_key_files $key_file" ;;
+        esac
+        (( age_days > oldest_days )) && oldest_days=$age_days
+        [ "$cert_authority" = "true" ] && cert_authority_keys=$((cert_authority_keys + 1))
+        auth_keys_count=$((auth_keys_count + 1))
+    done < <(user_homes identity | while IFS=$'\t' read -r key_user key_home; do ssh_authorized_keys "$key_user" "$key_home"; done)
+    if (( auth_keys_count == 0 )); then
+        report_append "_No readable authorized_keys entries._"
+    fi
+    local auth_key_files_count
+    auth_key_files_count=$(echo "$auth_key_files" | awk '{print NF}')
+    report_append ""
+    report_append "- Files: **${auth_key_files_count:-0}**, oldest file: **${oldest_days} days**"
+    append_ndjson_line "{\"type\":\"authorized_keys\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${auth_keys_count:-0},\"files\":${auth_key_files_count:-0},\"oldest_days\":${oldest_days:-0},\"cert_authority\":${cert_authority_keys},\"items\":[${auth_key_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "authorized_keys" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🛡️ SSH Server"
+    local sshd_out sshd_row sshd_posture sshd_source sshd_ports ca_keys_file ca_keys_count=0 host_certs=""
+    sshd_out="$(sshd_settings identity)"
+    if [ -n "$sshd_out" ]; then
+        sshd_source="$(printf '%s\n' "$sshd_out" | awk -F'\t' '$1 == "source" {print $2; exit}')"
+        sshd_ports="$(printf '%s\n' "$sshd_out" | awk -F'\t' '$1 == "port" && $2 ~ /^[0-9]+$/ {printf "%s%s", (n++ ? "," : ""), $2}')"
+        ca_keys_file="$(printf '%s\n' "$sshd_out" | awk -F'\t' '$1 == "trustedusercakeys" && $2 != "none" {print $2; exit}')"
+        if [ -n "$ca_keys_file" ] && [ -r "$ca_keys_file" ]; then
+            ca_keys_count=$(awk 'NF && $1 !~ /^#/ {c++} END{print c+0}' "$ca_keys_file" 2>/dev/null || echo 0)
+        fi
+        host_certs="$(printf '%s\n' "$sshd_out" | awk -F'\t' '$1 == "hostcertificate" {print $2}')"
+        report_append "- Settings from: \`$sshd_source\`"
+        report_append "- Port(s): **${sshd_ports:-22}**"
+        report_append ""
+        report_append "| Setting | Value |"
+        report_append "|---------|-------|"
+        while IFS= read -r line; do report_append "$line"; done < <(printf '%s\n' "$sshd_out" | awk -F'\t' '$1 != "source" && $1 != "port" {print "| " $1 " | `" $2 "` |"}' | sort)
+        sshd_row="$(printf '%s\n' "$sshd_out" | awk -F'\t' -v run_id="$(json_escape "$RUN_ID")" -v source="$(json_escape "$sshd_source")" -v ports="${sshd_ports:-22}" \
+            -v ca_count="$ca_keys_count" -v cert_authority_keys="$cert_authority_keys" "$_AWK_JSON_ESC"'
+            function yes(v) { return v == "yes" ? "true" : "false" }
+            $1 == "hostcertificate" { host_certs++; next }
+            { val[$1] = $2 }
+            END {
+                cert_auth = (val["trustedusercakeys"] != "none" || cert_authority_keys > 0)
+                plain = (val["pubkeyauthentication"] == "yes" && val["authorizedkeysfile"] != "none")
+                password = (val["passwordauthentication"] == "yes" || val["kbdinteractiveauthentication"] == "yes")
+                posture = cert_auth ? ((plain || password) ? "mixed" : "certificate_only") : (password ? "password" : "public_key")
+                printf "{\"type\":\"sshd_config\",\"run_id\":%s,\"source\":%s,\"ports\":[%s]", run_id, source, ports
+                printf ",\"permit_root_login\":\"%s\"", val["permitrootlogin"]
+                printf ",\"password_authentication\":%s,\"pubkey_authentication\":%s", yes(val["passwordauthentication"]), yes(val["pubkeyauthentication"])
+                printf ",\"kbd_interactive_authentication\":%s,\"permit_empty_passwords\":%s", yes(val["kbdinteractiveauthentication"]), yes(val["permitemptypasswords"])
+                printf ",\"x11_forwarding\":%s,\"max_auth_tries\":%d", yes(val["x11forwarding"]), val["maxauthtries"] + 0
+                printf ",\"trusted_user_ca_keys\":\"%s\",\"trusted_user_ca_count\":%d", (val["trustedusercakeys"] == "none" ? "" : json_esc(val["trustedusercakeys"])), ca_count
+                printf ",\"authorized_principals_file\":\"%s\",\"host_certificates\":%d", (val["authorizedprincipalsfile"] == "none" ? "" : json_esc(val["authorizedprincipalsfile"])), host_certs
+                printf ",\"cert_auth\":%s,\"plain_pubkey_auth\":%s,\"password_auth\":%s,\"auth_posture\":\"%s\"}\n", \
+                    (cert_auth ? "true" : "false"), (plain ? "true" : "false"), (password ? "true" : "false"), posture
+            }')"
+        append_ndjson_line "$sshd_row"
+        sshd_posture="$(printf '%s' "$sshd_row" | sed -n 's/.*"auth_posture":"\([a-z_]*\)".*/\1/p')"
+        case "$sshd_posture" in
+            certificate_only) report_append "- Authentication posture: **certificate_only** (user CA keys: $ca_keys_count)" ;;
+            mixed) report_append "- Authentication posture: **mixed** ⚠️ certificates accepted, but so are plain keys or passwords" ;;
+            *) report_append "- Authentication posture: **$sshd_posture** (no SSH CA trusted)" ;;
+        esac
+    else
+        report_append "_No SSH server (sshd) installed._"
+    fi
+    section_end_ms=$(now_ms)
+    emit_timing "sshd_config" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🪪 SSH Certificates"
+    local ssh_cert_count=0 ssh_cert_expired=0 ssh_cert_items="" cert_file
+    report_append "| Kind | File | Key ID | Principals | Valid To | Signing CA |"
+    report_append "|------|------|--------|------------|----------|------------|"
+    while IFS=$'\t' read -r cert_file cert_kind key_id principals valid_to days_left ca_fingerprint; do
+        [ -n "$cert_kind" ] || continue
+        [ "$principals" = "-" ] && principals=""
+        [ "$days_left" = "-" ] && days_left="null"
+        if [[ "${REDACT_ALL:-false}" == "true" ]]; then
+            ca_fingerprint="<fingerprint>"
+        fi
+        safe_file="$(redact_path_for_ndjson "$cert_file")"
+        report_append "| $cert_kind | \`$safe_file\` | $key_id | ${principals:-_any_} | $valid_to | \`$ca_fingerprint\` |"
+        item="{\"kind\":$(json_escape "$cert_kind"),\"file\":$(json_escape "$safe_file"),\"key_id\":$(json_escape "$key_id"),\"principals\":[$(printf '%s' "$principals" | awk -F, '{for (i = 1; i <= NF; i++) printf "%s\"%s\"", (i > 1 ? "," : ""), $i}')],\"valid_to\":$(json_escape "$valid_to"),\"days_left\":$days_left,\"ca_fingerprint\":$(json_escape "$ca_fingerprint")}"
+        if [ -z "$ssh_cert_items" ]; then
+            ssh_cert_items="$item"
+        else
+            ssh_cert_items="${ssh_cert_items},${item}"
+        fi
+        [ "$days_left" != "null" ] && (( days_left < 0 )) && ssh_cert_expired=$((ssh_cert_expired + 1))
+        ssh_cert_count=$((ssh_cert_count + 1))
+    done < <(
+        for cert_file in $host_certs "$ssh_dir"/*-cert.pub; do
+            [ -f "$cert_file" ] || continue
+            ssh_certificate_details "$cert_file" | awk -v f="$cert_file" '{print f "\t" $0}'
+        done
+    )
+    if (( ssh_cert_count == 0 )); then
+        report_append "_No SSH host or user certificates found._"
+    fi
+    append_ndjson_line "{\"type\":\"ssh_certificates\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${ssh_cert_count},\"expired\":${ssh_cert_expired},\"items\":[${ssh_cert_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "ssh_certificates" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🔑 Secrets Agents"
+    local agents_count=0 agent_ssh_keys=0 unconstrained_keys=0 agent_items="" agent_name agent_sock agent_keys agent_lifetime agent_confirm unconstrained safe_sock
+    while IFS=$'\t' read -r agent_name agent_sock agent_keys agent_lifetime agent_confirm; do
+        [ -n "$agent_name" ] || continue
+        [ "$agent_sock" != "-" ] || agent_sock=""
+        [ "$agent_lifetime" != "-" ] || agent_lifetime=""
+        [ "$agent_confirm" != "-" ] || agent_confirm="null"
+        unconstrained=false
+        if [ "$agent_keys" = "-" ]; then
+            agent_keys="null"
+        else
+            agent_ssh_keys=$((agent_ssh_keys + agent_keys))
+            if (( agent_keys > 0 )) && [ -z "$agent_lifetime" ] && [ "$agent_confirm" = "false" ]; then
+                unconstrained=true
+                unconstrained_keys=$((unconstrained_keys + agent_keys))
+            fi
+        fi
+        if (( agents_count == 0 )); then
+            report_append "| Agent | SSH keys | Lifetime | Confirm | Socket |"
+            report_append "|-------|----------|----------|---------|--------|"
+        fi
+        safe_sock="$(redact_path_for_ndjson "$agent_sock")"
+        report_append "| $agent_name | ${agent_keys/null/-}$([ "$unconstrained" = true ] && printf ' ⚠️') | ${agent_lifetime:-none} | ${agent_confirm/null/-} | \`${safe_sock:--}\` |"
+        item="{\"agent\":$(json_escape "$agent_name"),\"socket\":$(json_escape "$safe_sock"),\"keys\":$agent_keys,\"lifetime\":$(json_escape "$agent_lifetime"),\"confirm\":$agent_confirm,\"unconstrained\":$unconstrained}"
+        if [ -z "$agent_items" ]; then
+            agent_items="$item"
+        else
+            agent_items="${agent_items},${item}"
+        fi
+        agents_count=$((agents_count + 1))
+    done < <(secrets_agents)
+    if (( agents_count == 0 )); then
+        report_append "_No secrets agents running._"
+    elif (( unconstrained_keys > 0 )); then
+        report_append ""
+        report_append "- ⚠️ **$unconstrained_keys** SSH key(s) loaded with no lifetime and no confirmation; any process running as this user can use them."
+    fi
+    append_ndjson_line "{\"type\":\"secrets_agents\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${agents_count},\"ssh_keys\":${agent_ssh_keys},\"unconstrained_keys\":${unconstrained_keys},\"items\":[${agent_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "secrets_agents" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🔓 Secrets in Environment Variables"
+    local env_secrets_count=0 env_secret_items="" env_source env_name env_pattern safe_env_source
+    while IFS=$'\t' read -r env_source env_name env_pattern; do
+        [ -n "$env_pattern" ] || continue
+        if (( env_secrets_count == 0 )); then
+            report_append "| Source | Variable | Looks like |"
+            report_append "|--------|----------|------------|"
+        fi
+        safe_env_source="$(redact_path_for_ndjson "$env_source")"
+        report_append "| \`$safe_env_source\` | \`$env_name\` | $env_pattern |"
+        item="{\"source\":$(json_escape "$safe_env_source"),\"name\":$(json_escape "$env_name"),\"pattern\":\"$env_pattern\"}"
+        if [ -z "$env_secret_items" ]; then
+            env_secret_items="$item"
+        else
+            env_secret_items="${env_secret_items},${item}"
+        fi
+        env_secrets_count=$((env_secrets_count + 1))
+    done < <(env_secret_exposures)
+    if (( env_secrets_count == 0 )); then
+        report_append "_No credentials found in environment variables or env files._"
+    else
+        report_append ""
+        report_append "- ⚠️ **$env_secrets_count** variable(s) look like credentials; every process started from them inherits the value. Values are not recorded."
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"env_secret_exposed\",\"count\":$env_secrets_count,\"items\":[${env_secret_items}]}"
+    fi
+    append_ndjson_line "{\"type\":\"env_secrets\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${env_secrets_count},\"items\":[${env_secret_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "env_secrets" "$section_start_ms" "$section_end_ms"
+
     section_start_ms=$(now_ms)
     section_header "🐚 Login Shell"
     shell_path="${SHELL:-unknown}"
@@ -192,7 +404,43 @@ run_identity_audit() {
     section_end_ms=$(now_ms)
     emit_timing "login_shell" "$section_start_ms" "$section_end_ms"
 
-    append_ndjson_line "{\"type\":\"identity_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"local_users\":${local_users_count:-0},\"current_groups\":${current_groups_count:-0},\"ssh_keys\":${ssh_keys_count:-0},\"sudo_capable\":$sudo_capable}"
+    # `osaudit triage` also lists recent logins (see collector.TriageEnv).
+    if [ -n "${OSAUDIT_TRIAGE_HOURS:-}" ]; then
+        section_start_ms=$(now_ms)
+        section_header "🕒 Recent Logins"
+        local logins_count=0 logins_active=0 logins_remote=0 login_items="" login_user login_tty login_host login_time login_active
+        while IFS=$'\t' read -r login_user login_tty login_host login_time login_active; do
+            [ -n "$login_user" ] || continue
+            [ "$login_host" != "-" ] || login_host=""
+            if (( logins_count == 0 )); then
+                report_append "| User | Terminal | From | Login | Active |"
+                report_append "|------|----------|------|-------|--------|"
+            fi
+            if [ -n "$login_host" ]; then
+                logins_remote=$((logins_remote + 1))
+                if [[ "${REDACT_ALL:-false}" == "true" ]]; then
+                    login_host="<host>"
+                fi
+            fi
+            [ "$login_active" = "true" ] && logins_active=$((logins_active + 1))
+            report_append "| \`$login_user\` | $login_tty | ${login_host:--} | $login_time | $login_active |"
+            item="{\"user\":$(json_escape "$login_user"),\"tty\":$(json_escape "$login_tty"),\"host\":$(json_escape "$login_host"),\"login\":$(json_escape "$login_time"),\"active\":$login_active}"
+            if [ -z "$login_items" ]; then
+                login_items="$item"
+            else
+                login_items="${login_items},${item}"
+            fi
+            logins_count=$((logins_count + 1))
+        done < <(recent_logins identity)
+        if (( logins_count == 0 )); then
+            report_append "_No login records readable._"
+        fi
+        append_ndjson_line "{\"type\":\"recent_logins\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${logins_count},\"active\":${logins_active},\"remote\":${logins_remote},\"items\":[${login_items}]}"
+        section_end_ms=$(now_ms)
+        emit_timing "recent_logins" "$section_start_ms" "$section_end_ms"
+    fi
+
+    append_ndjson_line "{\"type\":\"identity_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"local_users\":${local_users_count:-0},\"current_groups\":${current_groups_count:-0},\"ssh_keys\":${ssh_keys_count:-0},\"authorized_keys\":${auth_keys_count:-0},\"unconstrained_agent_keys\":${unconstrained_keys:-0},\"env_secrets\":${env_secrets_count:-0},\"sudo_capable\":$sudo_capable}"
 }
 
 identity_main() {
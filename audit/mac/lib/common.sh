@@ -61,8 +61,27 @@ _common_cleanup_tmps() {
     _COMMON_TMPFILES=()
 }
 
+# INT/TERM (Ctrl-C, a service stop, or osaudit passing either on): mark the
+# snapshot so far as interrupted with a run_summary row and exit 128+signo.
+# The EXIT trap then writes run meta and removes temp files.
+AUDIT_INTERRUPTED=""
+_common_interrupted() {
+    AUDIT_INTERRUPTED="$1"
+    trap - INT TERM
+    if [ -n "${NDJSON_FILE:-}" ] && [ -f "$NDJSON_FILE" ]; then
+        printf '{"type":"run_summary","run_id":%s,"status":"interrupted","signal":"%s"}\n' \
+            "$(json_escape "$RUN_ID")" "$1" >> "$NDJSON_FILE" 2>/dev/null || true
+    fi
+    case "$1" in
+        TERM) exit 143 ;;
+        *) exit 130 ;;
+    esac
+}
+
 if [[ "${_COMMON_CLEANUP_TRAP_SET:-0}" != "1" ]]; then
-    trap _common_cleanup_tmps EXIT INT TERM
+    trap _common_cleanup_tmps EXIT
+    trap '_common_interrupted INT' INT
+    trap '_common_interrupted TERM' TERM
     _COMMON_CLEANUP_TRAP_SET=1
 fi
 
@@ -73,6 +92,219 @@ _common_is_true() {
     esac
 }
 
+# collector_enabled <id>: false when osaudit disabled the collector
+# (OSAUDIT_DISABLED_COLLECTORS, comma-separated IDs; see `osaudit collectors`).
+collector_enabled() {
+    case ",${OSAUDIT_DISABLED_COLLECTORS:-}," in
+        *",$1,"*) return 1 ;;
+    esac
+    return 0
+}
+
+# scope_matches <text>...: true when the run is not scoped, or when one of the
+# arguments names a path under a scope path (OSAUDIT_SCOPE, ":"-separated; see
+# `osaudit run --scope`). With no arguments it is true only for unscoped runs.
+scope_matches() {
+    [ -n "${OSAUDIT_SCOPE:-}" ] || return 0
+    local roots=() root arg
+    IFS=: read -r -a roots <<< "$OSAUDIT_SCOPE"
+    for root in "${roots[@]+"${roots[@]}"}"; do
+        root="${root%/}"
+        [ -n "$root" ] || continue
+        for arg in "$@"; do
+            case "$arg" in
+                *"$root"|*"$root"/*|*"$root"[[:space:]\"\'\;:]*) return 0 ;;
+            esac
+        done
+    done
+    return 1
+}
+
+# scope_matches_file <file>...: like scope_matches, but also looks for the
+# scope paths in the files' contents, e.g. a unit's ExecStart. The files are
+# only read in scoped runs.
+scope_matches_file() {
+    [ -n "${OSAUDIT_SCOPE:-}" ] || return 0
+    scope_matches "$@" && return 0
+    local roots=() root patterns=()
+    IFS=: read -r -a roots <<< "$OSAUDIT_SCOPE"
+    for root in "${roots[@]+"${roots[@]}"}"; do
+        [ -n "${root%/}" ] || continue
+        patterns+=(-e "${root%/}")
+    done
+    (( ${#patterns[@]} > 0 )) || return 1
+    grep -aqF "${patterns[@]}" -- "$@" 2>/dev/null
+}
+
+# scope_display prints the scope paths for reports, comma-separated and
+# redacted like NDJSON paths.
+scope_display() {
+    local roots=() root out=""
+    IFS=: read -r -a roots <<< "${OSAUDIT_SCOPE:-}"
+    for root in "${roots[@]+"${roots[@]}"}"; do
+        [ -n "$root" ] || continue
+        out="${out:+$out, }$(redact_path_for_ndjson "$root")"
+    done
+    printf '%s\n' "$out"
+}
+
+# emit_scope writes the scope row of a scoped run: the paths its collectors
+# were limited to, so diff can tell scoped snapshots apart.
+emit_scope() {
+    [ -n "$NDJSON_FILE" ] && [ -n "${OSAUDIT_SCOPE:-}" ] || return 0
+    local roots=() root paths=""
+    IFS=: read -r -a roots <<< "$OSAUDIT_SCOPE"
+    for root in "${roots[@]+"${roots[@]}"}"; do
+        [ -n "$root" ] || continue
+        paths="${paths:+$paths,}$(json_escape "$(redact_path_for_ndjson "$root")")"
+    done
+    append_ndjson_line "{\"type\":\"scope\",\"run_id\":$(json_escape "$RUN_ID"),\"paths\":[${paths}]}"
+}
+
+# mount_points prints "mount_point\tfstype" for each mounted filesystem, from
+# mount(8): "<device> on <mount point> (<fstype>, <options>...)".
+mount_points() {
+    mount 2>/dev/null | awk '{
+        i = index($0, " on /")
+        if (!i) next
+        line = substr($0, i + 4)
+        i = match(line, / \([^()]*\)$/)
+        if (!i) next
+        fstype = substr(line, i + 2)
+        sub(/[,)].*/, "", fstype)
+        print substr(line, 1, i - 1) "\t" fstype
+    }'
+}
+
+# exclude_fstypes prints the filesystem types osaudit skips, one per line: the
+# fstype:<type> lines of OSAUDIT_EXCLUDE.
+exclude_fstypes() {
+    printf '%s\n' "${OSAUDIT_EXCLUDE:-}" | sed -n 's/^[[:space:]]*fstype:[[:space:]]*\([^[:space:]]*\).*$/\1/p'
+}
+
+# skipped_subtrees <root> prints "path\treason\tfstype" for each mount at or
+# under root that find_excluding does not descend into: "fstype" for a type
+# excluded with fstype:<type>, and, when ONE_FILESYSTEM is true,
+# "other_filesystem" for every mount below root, bind mounts included. When
+# root itself is on an excluded type, root is the only subtree printed.
+skipped_subtrees() {
+    local root="${1%/}" types mp fstype prev="" owner_len=-1 owner_type=""
+    types=",$(exclude_fstypes | tr '\n' ','),"
+    while IFS=$'\t' read -r mp fstype; do
+        case "$root/" in
+            "${mp%/}/"*)
+                if (( ${#mp} > owner_len )); then
+                    owner_len=${#mp}
+                    owner_type="$fstype"
+                fi
+                ;;
+        esac
+    done < <(mount_points)
+    case "$types" in
+        *",$owner_type,"*)
+            if [ -n "$owner_type" ]; then
+                printf '%s\t%s\t%s\n' "${root:-/}" fstype "$owner_type"
+                return 0
+            fi
+            ;;
+    esac
+    while IFS=$'\t' read -r mp fstype; do
+        case "$mp" in
+            "${root:-/}"|"$root"/*) ;;
+            *) continue ;;
+        esac
+        if [ -n "$prev" ]; then
+            case "$mp" in
+                "$prev"/*) continue ;;
+            esac
+        fi
+        case "$types" in
+            *",$fstype,"*)
+                printf '%s\t%s\t%s\n' "$mp" fstype "$fstype"
+                prev="${mp%/}"
+                continue
+                ;;
+        esac
+        if [ "${ONE_FILESYSTEM:-false}" = true ] && [ "$mp" != "${root:-/}" ]; then
+            printf '%s\t%s\t%s\n' "$mp" other_filesystem "$fstype"
+            prev="${mp%/}"
+        fi
+    done < <(mount_points | LC_ALL=C sort)
+}
+
+# find_excluding <root> [-maxdepth|-mindepth <n>]... <expr>...: find(1) under
+# root that first prunes the paths osaudit excludes (OSAUDIT_EXCLUDE, one
+# .gitignore-style pattern per line, from ~/.osaudit/exclude) and the
+# subtrees skipped_subtrees lists. expr needs its own -print; without one find
+# would print the pruned directories too. find's * crosses /, so "a/*/b" here
+# also matches deeper paths than in osaudit hash.
+find_excluding() {
+    local root="$1" opts=() prune=() test=() line dir_only prefix collapsed
+    shift
+    while [ $# -ge 2 ]; do
+        case "$1" in
+            -maxdepth|-mindepth) opts+=("$1" "$2"); shift 2 ;;
+            *) break ;;
+        esac
+    done
+    while IFS= read -r line; do
+        line="${line#"${line%%[![:space:]]*}"}"
+        line="${line%"${line##*[![:space:]]}"}"
+        case "$line" in
+            ''|'#'*|'!'*) continue ;;
+            fstype:*)
+                line="${line#fstype:}"
+                line="${line#"${line%%[![:space:]]*}"}"
+                [ -n "$line" ] || continue
+                (( ${#prune[@]} == 0 )) || prune+=(-o)
+                prune+=(-fstype "$line")
+                continue
+                ;;
+        esac
+        dir_only=false
+        while [ "${line%/}" != "$line" ]; do
+            dir_only=true
+            line="${line%/}"
+        done
+        case "$line" in
+            '~') line="$HOME_DIR" ;;
+            '~/'*) line="$HOME_DIR/${line#\~/}" ;;
+        esac
+        line="${line#\*\*/}"
+        [ -n "$line" ] || continue
+        case "$line" in
+            */*)
+                prefix="*/"
+                [ "${line#/}" = "$line" ] || prefix=""
+                test=(-path "$prefix${line//\*\*/*}")
+                collapsed="${line//\/\*\*\//\/}"
+                if [ "$collapsed" != "$line" ]; then
+                    test=(\( "${test[@]}" -o -path "$prefix${collapsed//\*\*/*}" \))
+                fi
+                ;;
+            *) test=(-name "${line//\*\*/*}") ;;
+        esac
+        if $dir_only; then
+            test=(\( -type d "${test[@]}" \))
+        fi
+        (( ${#prune[@]} == 0 )) || prune+=(-o)
+        prune+=("${test[@]}")
+    done <<< "${OSAUDIT_EXCLUDE:-}"
+    if [ "${ONE_FILESYSTEM:-false}" = true ]; then
+        opts+=(-xdev)
+        while IFS=$'\t' read -r line _; do
+            [ "$line" != "$root" ] || continue
+            (( ${#prune[@]} == 0 )) || prune+=(-o)
+            prune+=(-path "$line")
+        done < <(skipped_subtrees "$root")
+    fi
+    if (( ${#prune[@]} == 0 )); then
+        find "$root" "${opts[@]+"${opts[@]}"}" "$@"
+        return
+    fi
+    find "$root" "${opts[@]+"${opts[@]}"}" \( "${prune[@]}" \) -prune -o \( "$@" \)
+}
+
 # Colors
 if _common_is_true "$NO_COLOR"; then
     RED=''
@@ -94,11 +326,1923 @@ json_escape() {
     python3 -c 'import json,sys; print(json.dumps(sys.argv[1]))' "${1-}"
 }
 
+# _AWK_JSON_ESC defines json_esc(s), s escaped for the inside of a JSON
+# string: backslashes, double quotes, and control characters. Awk programs
+# that build JSON put it ahead of their own code, awk "$_AWK_JSON_ESC"'...'.
+# gsub with "&&" doubles each backslash in every awk; "\\\\" would not.
+# shellcheck disable=SC2016
+_AWK_JSON_ESC='
+    function json_esc(s,   i, n, c, out) {
+        gsub(/\\/, "&&", s)
+        if (s !~ /["\001-\037]/) return s
+        if (!_json_esc_ready) {
+            for (i = 1; i < 32; i++) _json_esc_ctl[sprintf("%c", i)] = sprintf("\\u%04x", i)
+            _json_esc_ctl["\n"] = "\\n"; _json_esc_ctl["\r"] = "\\r"; _json_esc_ctl["\t"] = "\\t"
+            _json_esc_ready = 1
+        }
+        n = length(s)
+        for (i = 1; i <= n; i++) {
+            c = substr(s, i, 1)
+            if (c == "\"") out = out "\\\""
+            else if (c in _json_esc_ctl) out = out _json_esc_ctl[c]
+            else out = out c
+        }
+        return out
+    }'
+
+# path_line_escape <path> prints path on one line, for tab-separated
+# pipelines that file names could otherwise break: backslash, newline, tab,
+# and carriage return become \\, \n, \t, and \r. path_line_unescape undoes it.
+path_line_escape() {
+    local s="$1" bs='\'
+    # Unquoted on purpose: bash 3.2 keeps the quotes of a quoted replacement
+    # inside "${...}", and bash 5.2 drops unquoted backslashes from one.
+    s=${s//"$bs"/"$bs$bs"}
+    s=${s//$'\n'/"${bs}n"}
+    s=${s//$'\t'/"${bs}t"}
+    s=${s//$'\r'/"${bs}r"}
+    printf '%s\n' "$s"
+}
+
+# path_line_unescape <var> <line> sets var to the path path_line_escape wrote
+# as line. Every backslash in line starts one of its escapes, so %b decodes
+# exactly those.
+path_line_unescape() {
+    printf -v "$1" '%b' "$2"
+}
+
+# emit_event <kind> [<key> <value>]... writes one event line to the runner's
+# event pipe, fd $OSAUDIT_EVENT_FD, and does nothing when it is unset. Values
+# of keys ending in _code or _ms are written as numbers, others as strings.
+# Pure bash: probes emit events often enough that a python3 per call shows.
+emit_event() {
+    [ -n "${OSAUDIT_EVENT_FD:-}" ] || return 0
+    local line="{\"event\":\"$1\"" key val bs='\' q='"'
+    shift
+    [ -n "${RUN_ID:-}" ] && set -- run_id "$RUN_ID" "$@"
+    while [ $# -ge 2 ]; do
+        key="$1" val="$2"
+        shift 2
+        case "$key" in
+            *_code|*_ms)
+                case "$val" in ''|*[!0-9]*) val=0 ;; esac
+                line="$line,\"$key\":$val"
+                ;;
+            *)
+                val=${val//"$bs"/"$bs$bs"}
+                val=${val//"$q"/"$bs$q"}
+                val=${val//$'\n'/"${bs}n"}
+                val=${val//$'\t'/"${bs}t"}
+                val=${val//$'\r'/"${bs}r"}
+                line="$line,\"$key\":\"$val\""
+                ;;
+        esac
+    done
+    printf '%s}\n' "$line" 2>/dev/null >&"$OSAUDIT_EVENT_FD" || true
+}
+
+# collector_started and collector_finished <id> tell the runner which
+# collector the full audit is in, for its progress display.
+collector_started() {
+    COLLECTOR_START_MS=$(now_ms)
+    emit_event collector_started collector "$1"
+}
+
+collector_finished() {
+    emit_event collector_finished collector "$1" elapsed_ms "$(( $(now_ms) - ${COLLECTOR_START_MS:-0} ))"
+}
+
+# md_path <path> prints path for the report: on one line as path_line_escape
+# writes it, other control characters as ?, and | escaped for table cells.
+md_path() {
+    path_line_escape "$1" | LC_ALL=C sed 's/[[:cntrl:]]/?/g; s/|/\\|/g'
+}
+
 stat_bytes() {
     local path="$1"
     stat -f%z "$path" 2>/dev/null || stat -c%s "$path" 2>/dev/null || echo 0
 }
 
+# Prints one "runtime<TAB>id<TAB>name<TAB>image<TAB>status<TAB>user<TAB>privileged<TAB>rootless<TAB>ports"
+# line per running Docker or Podman container. ports is a comma-separated list
+# of host_ip:host_port->port/proto bindings; rootless is true when the runtime
+# runs without root, so a container's root user is not root on the host.
+# Probe names are prefixed with $1.
+container_inventory() {
+    local probe_prefix="${1:-execution}"
+    local runtime ids rootless fmt
+    fmt='{{.Id}}'$'\t''{{.Name}}'$'\t''{{.Config.Image}}'$'\t''{{.State.Status}}'$'\t''{{.Config.User}}'$'\t''{{.HostConfig.Privileged}}'$'\t''{{range $p, $b := .NetworkSettings.Ports}}{{range $b}}{{.HostIp}}:{{.HostPort}}->{{$p}},{{end}}{{end}}'
+    for runtime in docker podman; do
+        command -v "$runtime" >/dev/null 2>&1 || continue
+        ids="$(soft_out_probe "${probe_prefix}.${runtime}_ps" "$runtime" ps -q 2>/dev/null)"
+        [ -n "$ids" ] || continue
+        if [ "$runtime" = "podman" ]; then
+            rootless="$("$runtime" info --format '{{.Host.Security.Rootless}}' 2>/dev/null || true)"
+        else
+            rootless=false
+            "$runtime" info --format '{{.SecurityOptions}}' 2>/dev/null | grep -q 'rootless' && rootless=true
+        fi
+        # shellcheck disable=SC2086
+        soft_out_probe "${probe_prefix}.${runtime}_inspect" "$runtime" inspect --format "$fmt" $ids 2>/dev/null \
+            | awk -F'\t' -v rt="$runtime" -v rootless="${rootless:-false}" 'NF >= 6 {
+                name = $2; sub(/^\//, "", name)
+                ports = $7; sub(/,$/, "", ports)
+                print rt "\t" substr($1, 1, 12) "\t" name "\t" $3 "\t" $4 "\t" $5 "\t" $6 "\t" rootless "\t" ports
+            }'
+    done
+}
+
+# Prints one "name<TAB>version<TAB>bundle_id<TAB>path<TAB>scope<TAB>team_id<TAB>signed<TAB>notarized<TAB>gatekeeper"
+# line per application bundle in /Applications and its subfolders (e.g.
+# Utilities), the scope Jamf inventories by default, and in the user's
+# ~/Applications. scope is system or user. signed is true when the bundle is
+# signed with a certificate (ad-hoc signatures identify no one, so they count
+# as unsigned), and team_id is the signing team. notarized is true when
+# Gatekeeper's assessment names Apple as the source (notarized Developer ID,
+# Mac App Store, or Apple's own apps), and gatekeeper is that assessment,
+# accepted or rejected; both are "-" when spctl gives no answer. Probe names
+# are prefixed with $1.
+mac_applications() {
+    local probe_prefix="${1:-config}"
+    local app plist name version bundle_id scope sig team_id signed assess notarized gatekeeper
+    while IFS=$'\t' read -r scope app; do
+        plist="$app/Contents/Info.plist"
+        [ -f "$plist" ] || continue
+        name="${app##*/}"
+        name="${name%.app}"
+        version="$(plutil -extract CFBundleShortVersionString raw -o - "$plist" 2>/dev/null || true)"
+        bundle_id="$(plutil -extract CFBundleIdentifier raw -o - "$plist" 2>/dev/null || true)"
+        # codesign -dv only reads the signature; it exits 1 for unsigned code.
+        sig="$(codesign -dv --verbose=2 "$app" 2>&1 || true)"
+        team_id="$(printf '%s\n' "$sig" | awk -F= '$1 == "TeamIdentifier" && $2 != "not set" { print $2; exit }')"
+        signed=false
+        printf '%s\n' "$sig" | grep -q '^Authority=' && signed=true
+        # spctl exits 3 for a rejected app, which is an answer, not a failure.
+        assess="$(spctl --assess --type execute -vv "$app" 2>&1 || true)"
+        notarized="$(printf '%s\n' "$assess" | awk '
+            / accepted$/ { verdict = "accepted" }
+            / rejected/ { verdict = "rejected" }
+            /^source=/ { src = substr($0, 8) }
+            END {
+                if (verdict == "") print "-"
+                else print ((src ~ /^(Notarized Developer ID|Mac App Store|Apple System)$/) ? "true" : "false") "\t" verdict
+            }')"
+        gatekeeper="-"
+        case "$notarized" in
+            *$'\t'*) gatekeeper="${notarized#*$'\t'}"; notarized="${notarized%%$'\t'*}" ;;
+        esac
+        printf '%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n' "$name" "$version" "$bundle_id" "$app" "$scope" "$team_id" "$signed" "$notarized" "$gatekeeper"
+    done < <({
+        soft_out_probe "${probe_prefix}.applications" find /Applications -maxdepth 2 -name '*.app' -type d -prune 2>/dev/null | LC_ALL=C sort | awk '{ print "system\t" $0 }'
+        if [ -d "$HOME_DIR/Applications" ]; then
+            soft_out_probe "${probe_prefix}.user_applications" find "$HOME_DIR/Applications" -maxdepth 2 -name '*.app' -type d -prune 2>/dev/null | LC_ALL=C sort | awk '{ print "user\t" $0 }'
+        fi
+    })
+}
+
+# mac_app_store_apps prints "name\tversion\tbundle_id\tadam_id\tpath" for
+# each application in /Applications and its subfolders that the Mac App
+# Store installed, which it marks with a receipt in Contents/_MASReceipt.
+# adam_id is the app's App Store ID, from the receipt metadata Spotlight
+# indexes; it is "-" when Spotlight has none, e.g. with indexing turned off.
+# Probe names are prefixed with $1.
+mac_app_store_apps() {
+    local probe_prefix="${1:-config}" app plist name version bundle_id adam_id
+    while IFS= read -r app; do
+        plist="$app/Contents/Info.plist"
+        [ -f "$app/Contents/_MASReceipt/receipt" ] && [ -f "$plist" ] || continue
+        name="${app##*/}"
+        name="${name%.app}"
+        version="$(plutil -extract CFBundleShortVersionString raw -o - "$plist" 2>/dev/null || true)"
+        bundle_id="$(plutil -extract CFBundleIdentifier raw -o - "$plist" 2>/dev/null || true)"
+        adam_id="$(mdls -raw -name kMDItemAppStoreAdamID "$app" 2>/dev/null || true)"
+        case "$adam_id" in ''|'(null)') adam_id="-" ;; esac
+        printf '%s\t%s\t%s\t%s\t%s\n' "$name" "${version:--}" "${bundle_id:--}" "$adam_id" "$app"
+    done < <(soft_out_probe "${probe_prefix}.app_store_apps" find /Applications -maxdepth 2 -name '*.app' -type d -prune 2>/dev/null | LC_ALL=C sort)
+}
+
+# mac_download_quarantine prints "kind\tquarantined\tsource_domain\tsigned\tteam_id\tpath"
+# for each application bundle, disk image, installer package, and Mach-O
+# executable up to three levels under ~/Downloads, with the path as
+# path_line_escape writes it. kind is app, dmg, pkg, or executable.
+# quarantined is whether the com.apple.quarantine attribute that sends the
+# file through Gatekeeper is still set. source_domain is the host of the first
+# URL Spotlight recorded the file as downloaded from. signed is true when the
+# file is signed with a certificate (ad-hoc signatures count as unsigned), and
+# team_id is the signing team. Unknown fields are "-".
+mac_download_quarantine() {
+    local f kind quarantined domain sig signed team_id
+    [ -d "$HOME_DIR/Downloads" ] || return 0
+    while IFS= read -r -d '' f; do
+        case "$f" in
+            *.[Aa][Pp][Pp]) kind=app ;;
+            *.[Dd][Mm][Gg]) kind=dmg ;;
+            *.[Pp][Kk][Gg]|*.[Mm][Pp][Kk][Gg]) kind=pkg ;;
+            *)
+                case "$(file -b "$f" 2>/dev/null || true)" in
+                    Mach-O*) kind=executable ;;
+                    *) continue ;;
+                esac
+                ;;
+        esac
+        quarantined=false
+        xattr -p com.apple.quarantine "$f" >/dev/null 2>&1 && quarantined=true
+        domain="$(mdls -raw -name kMDItemWhereFroms "$f" 2>/dev/null | awk -F'"' '
+            $2 ~ /^[A-Za-z][A-Za-z0-9+.-]*:\/\// { h = $2; sub(/^[^:]*:\/\//, "", h); sub(/[\/?#].*/, "", h); sub(/^.*@/, "", h); sub(/:[0-9]+$/, "", h); print tolower(h); exit }')"
+        signed=false
+        team_id=""
+        if [ "$kind" = pkg ]; then
+            # pkgutil exits 1 for an unsigned package, which is an answer.
+            sig="$(pkgutil --check-signature "$f" 2>/dev/null || true)"
+            case "$sig" in
+                *"Status: signed"*)
+                    signed=true
+                    team_id="$(printf '%s\n' "$sig" | awk '/^ *1\. / { if (match($0, /\([A-Z0-9]+\)$/)) print substr($0, RSTART + 1, RLENGTH - 2); exit }')"
+                    ;;
+            esac
+        else
+            # codesign -dv only reads the signature; it exits 1 for unsigned code.
+            sig="$(codesign -dv --verbose=2 "$f" 2>&1 || true)"
+            team_id="$(printf '%s\n' "$sig" | awk -F= '$1 == "TeamIdentifier" && $2 != "not set" { print $2; exit }')"
+            printf '%s\n' "$sig" | grep -q '^Authority=' && signed=true
+        fi
+        printf '%s\t%s\t%s\t%s\t%s\t%s\n' "$kind" "$quarantined" "${domain:--}" "$signed" "${team_id:--}" "$(path_line_escape "$f")"
+    done < <(find_excluding "$HOME_DIR/Downloads" -maxdepth 3 \
+        \( -type d \( -iname '*.app' -o -iname '*.pkg' -o -iname '*.mpkg' \) -prune -print0 \) -o \
+        \( -type f \( -iname '*.dmg' -o -iname '*.pkg' -o -iname '*.mpkg' -o -perm -u+x \) -print0 \) 2>/dev/null || true)
+}
+
+# user_homes <probe prefix> prints "user<TAB>home" for each account that is
+# not a system account (whose names start with "_").
+user_homes() {
+    soft_out_probe "${1:-identity}.dscl_list_homes" dscl . -list /Users NFSHomeDirectory | awk '$1 !~ /^_/ && NF == 2 {print $1 "\t" $2}'
+}
+
+# ide_extensions <user> <home> prints
+# "user\tide\tid\tversion\tpublisher\tpublisher_id\tsource\tmarketplace" for
+# each extension the user has installed in VS Code, VS Code Insiders,
+# VSCodium, Cursor, Windsurf, or VS Code Server, and each plugin in the newest
+# version of each JetBrains IDE. For the VS Code family, source is what the
+# editor recorded when installing: "gallery" (from the marketplace), "vsix"
+# (sideloaded from a file), or "unlisted" for an extension directory the
+# editor has no record of; publisher_id is the marketplace's ID for the
+# publisher, which stays the same when a publisher is renamed. JetBrains does
+# not record where a plugin came from, so its source is "unknown" and
+# marketplace lists JetBrains Marketplace plus any custom plugin repositories.
+# Empty fields are "-".
+ide_extensions() {
+    local user="$1" home="$2" ide dir marketplace ext product pname plugin jar xml hosts
+    local jb_plugins_root="$home/Library/Application Support/JetBrains" jb_plugins_sub="/plugins" jb_config_root="$home/Library/Application Support/JetBrains"
+    for ide in vscode vscode-insiders vscodium cursor windsurf vscode-server; do
+        case "$ide" in
+            vscode) dir="$home/.vscode/extensions"; marketplace=marketplace.visualstudio.com ;;
+            vscode-insiders) dir="$home/.vscode-insiders/extensions"; marketplace=marketplace.visualstudio.com ;;
+            vscodium) dir="$home/.vscode-oss/extensions"; marketplace=open-vsx.org ;;
+            cursor) dir="$home/.cursor/extensions"; marketplace=marketplace.cursorapi.com ;;
+            windsurf) dir="$home/.windsurf/extensions"; marketplace=open-vsx.org ;;
+            vscode-server) dir="$home/.vscode-server/extensions"; marketplace=marketplace.visualstudio.com ;;
+        esac
+        [ -d "$dir" ] && [ -r "$dir" ] || continue
+        {
+            if [ -r "$dir/extensions.json" ]; then
+                echo "@listed"
+                json_flatten < "$dir/extensions.json"
+            fi
+            if [ -r "$dir/.obsolete" ]; then
+                echo "@obsolete"
+                json_flatten < "$dir/.obsolete"
+            fi
+            for ext in "$dir"/*/; do
+                ext="${ext%/}"
+                [ -r "$ext/package.json" ] || continue
+                printf '@ext\t%s\n' "${ext##*/}"
+                json_flatten < "$ext/package.json"
+            done
+        } | awk -F'\t' -v user="$user" -v ide="$ide" -v mp="$marketplace" '
+            function dash(s) { return s == "" ? "-" : s }
+            function index_listed(   i, l) {
+                for (i in rel) {
+                    l = rel[i]
+                    lsrc[l] = (i in msrc) ? msrc[i] : ((i in mgid) ? "gallery" : "unknown")
+                    lpid[l] = mpid[i]
+                    lpub[l] = mpub[i]
+                }
+                indexed = 1
+            }
+            function flush(   id, src, pid, publisher) {
+                if (loc == "" || pub == "" || name == "" || (loc in obsolete)) return
+                id = tolower(pub "." name)
+                publisher = (epub != "") ? epub : pub
+                pid = epid
+                src = (egid != "") ? "gallery" : "unknown"
+                if (esrc != "") src = esrc
+                if (listed) src = "unlisted"
+                if (loc in lsrc) {
+                    src = lsrc[loc]
+                    if (lpid[loc] != "") pid = lpid[loc]
+                    if (lpub[loc] != "") publisher = lpub[loc]
+                }
+                print user "\t" ide "\t" id "\t" dash(ver) "\t" publisher "\t" dash(pid) "\t" src "\t" (src == "gallery" ? mp : "-")
+            }
+            $1 == "@listed" { mode = "listed"; listed = 1; next }
+            $1 == "@obsolete" { mode = "obsolete"; next }
+            $1 == "@ext" {
+                if (!indexed) index_listed()
+                flush()
+                mode = "ext"; loc = $2; pub = name = ver = epub = epid = egid = esrc = ""
+                next
+            }
+            mode == "obsolete" { if ($2 == "true") obsolete[$1] = 1; next }
+            mode == "listed" {
+                i = $1; sub(/\..*/, "", i); key = substr($1, length(i) + 2)
+                if (key == "relativeLocation") rel[i] = $2
+                else if (key == "location.path" && !(i in rel)) { n = split($2, q, "/"); rel[i] = q[n] }
+                else if (key == "metadata.source") msrc[i] = $2
+                else if (key == "metadata.id") mgid[i] = $2
+                else if (key == "metadata.publisherId") mpid[i] = $2
+                else if (key == "metadata.publisherDisplayName") mpub[i] = $2
+                next
+            }
+            $1 == "publisher" { pub = $2 }
+            $1 == "name" { name = $2 }
+            $1 == "version" { ver = $2 }
+            $1 == "__metadata.id" { egid = $2 }
+            $1 == "__metadata.source" { esrc = $2 }
+            $1 == "__metadata.publisherId" { epid = $2 }
+            $1 == "__metadata.publisherDisplayName" { epub = $2 }
+            END { flush() }'
+    done
+    [ -d "$jb_plugins_root" ] && [ -r "$jb_plugins_root" ] || return 0
+    # Only the newest version of each IDE (e.g. PyCharm2024.2 over
+    # PyCharm2024.1) has plugins that load.
+    while IFS=$'\t' read -r pname product; do
+        hosts="$(awk '/name="pluginHosts"/ { f = 1 } f && match($0, /value="[^"]*"/) { v = substr($0, RSTART + 7, RLENGTH - 8); sub(/^https?:\/\//, "", v); sub(/\/.*/, "", v); printf "%s%s", (n++ ? "," : ""), v } f && /<\/list>/ { exit }' "$jb_config_root/$product/options/updates.xml" 2>/dev/null || true)"
+        marketplace="plugins.jetbrains.com${hosts:+,$hosts}"
+        for plugin in "$jb_plugins_root/$product$jb_plugins_sub"/*; do
+            [ -d "$plugin/lib" ] || continue
+            xml=""
+            if command -v unzip >/dev/null 2>&1; then
+                for jar in "$plugin/lib/${plugin##*/}.jar" "$plugin"/lib/*.jar; do
+                    [ -f "$jar" ] || continue
+                    xml="$(unzip -p "$jar" META-INF/plugin.xml 2>/dev/null || true)"
+                    [ -z "$xml" ] || break
+                done
+            fi
+            printf '%s\n' "$xml" | awk -v user="$user" -v ide="$pname" -v dir="${plugin##*/}" -v mp="$marketplace" '
+                id == "" && match($0, /<id>[^<]*<\/id>/) { id = substr($0, RSTART + 4, RLENGTH - 9) }
+                ver == "" && match($0, /<version>[^<]*<\/version>/) { ver = substr($0, RSTART + 9, RLENGTH - 19) }
+                vendor == "" && match($0, /<vendor[^>]*>[^<]*<\/vendor>/) {
+                    vendor = substr($0, RSTART, RLENGTH); sub(/^<vendor[^>]*>/, "", vendor); sub(/<\/vendor>$/, "", vendor)
+                }
+                END {
+                    print user "\t" ide "\t" (id == "" ? dir : id) "\t" (ver == "" ? "-" : ver) "\t" (vendor == "" ? "-" : vendor) "\t-\tunknown\t" mp
+                }'
+        done
+    done < <(for product in "$jb_plugins_root"/*/; do product="${product%/}"; printf '%s\n' "${product##*/}"; done | awk '
+        match($0, /[0-9][0-9][0-9][0-9]\.[0-9]+$/) { latest[substr($0, 1, RSTART - 1)] = $0 }
+        END { for (p in latest) print p "\t" latest[p] }' | LC_ALL=C sort)
+}
+
+# json_flatten reads JSON on stdin and prints one "path<TAB>value" line per
+# scalar, with object keys and array indexes joined by dots, e.g.
+# "0.identifier.id<TAB>ms-python.python". \" \\ and \/ are unescaped; other
+# escapes are kept as written. It is meant for small, well-formed files such
+# as editor metadata, not as a validating parser.
+json_flatten() {
+    awk '
+        function path(   j, p) {
+            p = ""
+            for (j = 1; j <= d; j++) p = p (j > 1 ? "." : "") (t[j] == "o" ? k[j] : n[j])
+            return p
+        }
+        { s = s $0 "\n" }
+        END {
+            len = length(s)
+            for (i = 1; i <= len; i++) {
+                c = substr(s, i, 1)
+                if (c == "{" || c == "[") {
+                    d++; t[d] = (c == "{") ? "o" : "a"; n[d] = 0; k[d] = ""; want[d] = (c == "{")
+                } else if (c == "}" || c == "]") {
+                    d--
+                } else if (c == ",") {
+                    if (t[d] == "a") n[d]++; else want[d] = 1
+                } else if (c == ":") {
+                    want[d] = 0
+                } else if (c == "\"") {
+                    v = ""
+                    for (i++; i <= len; i++) {
+                        c = substr(s, i, 1)
+                        if (c == "\\") {
+                            i++; c = substr(s, i, 1)
+                            if (c != "\"" && c != "\\" && c != "/") v = v "\\"
+                        } else if (c == "\"") {
+                            break
+                        }
+                        v = v c
+                    }
+                    if (t[d] == "o" && want[d]) k[d] = v; else print path() "\t" v
+                } else if (c !~ /[ \t\r\n]/) {
+                    v = c
+                    while (i < len && substr(s, i + 1, 1) !~ /[],} \t\r\n]/) { i++; v = v substr(s, i, 1) }
+                    print path() "\t" v
+                }
+            }
+        }'
+}
+
+# fd_system_usage prints "open\tmax" for the whole system: the files open
+# (kern.num_files) and kern.maxfiles. Either is "-" when unknown.
+fd_system_usage() {
+    local open max
+    open="$(sysctl -n kern.num_files 2>/dev/null || true)"
+    max="$(sysctl -n kern.maxfiles 2>/dev/null || true)"
+    printf '%s\t%s\n' "${open:--}" "${max:--}"
+}
+
+# fd_processes prints "pid\tuser\tcommand\topen\tsoft\thard" for each process
+# lsof lists with open descriptors, which without root is only the caller's
+# own. macOS does not show another process's RLIMIT_NOFILE, so soft is "-" and
+# hard is kern.maxfilesperproc, past which no process can raise its limit.
+fd_processes() {
+    local per_proc
+    per_proc="$(sysctl -n kern.maxfilesperproc 2>/dev/null || true)"
+    # lsof exits 1 whenever it cannot read some process, which is not a failure.
+    { lsof -n -P -w -F pcLf 2>/dev/null || true; } | awk -v hard="${per_proc:--}" '
+        function flush() { if (pid != "" && open > 0) print pid "\t" (user == "" ? "-" : user) "\t" cmd "\t" open "\t-\t" hard }
+        /^p/ { flush(); pid = substr($0, 2); cmd = ""; user = ""; open = 0; next }
+        /^c/ { cmd = substr($0, 2); next }
+        /^L/ { user = substr($0, 2); next }
+        /^f[0-9]+$/ { open++ }
+        END { flush() }'
+}
+
+# ai_agent_processes <probe prefix> prints
+# "agent\tname\tuser\tpid\tprocesses\tcommand" for each local AI agent, model
+# server, or MCP server process: Claude Code and Claude Desktop, Codex, Gemini
+# CLI, Aider, Goose, Ollama, llama.cpp, LM Studio, LocalAI, vLLM, GPT4All, and
+# anything run as an MCP server package ("@modelcontextprotocol/server-*",
+# "mcp-server-*", "*-mcp"). Processes of the same agent and user are counted
+# once, under the first pid; name is the MCP package, or the agent.
+ai_agent_processes() {
+    local prefix="${1:-execution}" agent name uid pid count command user
+    soft_out_probe "$prefix.ps_ai_agents" ps -A -o pid=,uid=,args= | awk '
+        {
+            pid = $1; uid = $2
+            cmd = $0
+            sub(/^[ \t]*[0-9]+[ \t]+[0-9]+[ \t]+/, "", cmd)
+            exe = $3; sub(/.*\//, "", exe)
+            if (exe ~ /^([gmn]?awk|grep|ps)$/) next
+            a = tolower(cmd); agent = ""; name = ""
+            if (match(a, /@modelcontextprotocol\/server-[a-z0-9_-]+|mcp-server-[a-z0-9_-]+|[a-z0-9_]+(-[a-z0-9_]+)*-mcp(-server)?( |$)/)) {
+                agent = "mcp-server"; name = substr(a, RSTART, RLENGTH); sub(/ $/, "", name)
+            } else if (a ~ /@anthropic-ai\/claude-code|(^|\/)claude( |$)/) agent = "claude-code"
+            else if (a ~ /\/contents\/macos\/claude( |$)|(^|\/)claude-desktop( |$)/) agent = "claude-desktop"
+            else if (a ~ /@openai\/codex|(^|\/)codex( |$)/) agent = "codex"
+            else if (a ~ /@google\/gemini-cli|(^|\/)gemini( |$)/) agent = "gemini-cli"
+            else if (a ~ /(^|\/)aider( |$)|aider-chat/) agent = "aider"
+            else if (a ~ /(^|\/)goose( |$)/) agent = "goose"
+            else if (a ~ /(^|\/)ollama( |$)/) agent = "ollama"
+            else if (a ~ /llama-server|llama\.cpp/) agent = "llama.cpp"
+            else if (a ~ /lm studio|lmstudio|(^|\/)lms( |$)/) agent = "lm-studio"
+            else if (a ~ /local-ai|localai/) agent = "localai"
+            else if (a ~ /(^|[ \/])vllm([ .]|$)/) agent = "vllm"
+            else if (a ~ /gpt4all/) agent = "gpt4all"
+            if (agent == "") next
+            if (name == "") name = agent
+            k = agent SUBSEP name SUBSEP uid
+            if (!(k in first)) { first[k] = pid; line[k] = cmd; order[++n] = k }
+            count[k]++
+        }
+        END {
+            for (i = 1; i <= n; i++) {
+                split(order[i], f, SUBSEP)
+                gsub(/\t/, " ", line[order[i]])
+                print f[1] "\t" f[2] "\t" f[3] "\t" first[order[i]] "\t" count[order[i]] "\t" substr(line[order[i]], 1, 200)
+            }
+        }' | while IFS=$'\t' read -r agent name uid pid count command; do
+        user="$(id -nu "$uid" 2>/dev/null || echo "$uid")"
+        printf '%s\t%s\t%s\t%s\t%s\t%s\n' "$agent" "$name" "$user" "$pid" "$count" "$command"
+    done
+}
+
+# mcp_servers <user> <home> prints
+# "user\tclient\tconfig\tname\ttransport\tenabled\tcommand\targs\turl\tpaths\tenv"
+# for each MCP server configured in the user's Claude Desktop, Claude Code,
+# Cursor, Windsurf, VS Code, Cline, Gemini CLI, Zed, and Codex settings.
+# transport is the configured type, or "stdio" for a command and "http" for a
+# URL. paths lists the absolute and home-relative arguments (and cwd), which
+# is where servers such as server-filesystem are allowed to reach; env lists
+# the names of the variables the server is given, never their values. args is
+# space-joined and lists are comma-joined; empty fields are "-".
+mcp_servers() {
+    local user="$1" home="$2" client config prefix
+    local app_support="$home/Library/Application Support"
+    while IFS='|' read -r client config prefix; do
+        [ -r "$config" ] || continue
+        case "$config" in
+            *.toml)
+                awk '
+                    /^[ \t]*\[/ { sec = $0; gsub(/^[ \t]*\[+|\]+[ \t]*$|"/, "", sec); next }
+                    sec ~ /^mcp_servers\./ && /=/ {
+                        key = $0; sub(/[ \t]*=.*/, "", key); gsub(/^[ \t]+|"/, "", key)
+                        val = $0; sub(/^[^=]*=[ \t]*/, "", val); sub(/[ \t]+$/, "", val)
+                        if (val ~ /^[[{]/) {
+                            table = (val ~ /^\{/)
+                            gsub(/^[[{][ \t]*|[ \t]*[]}]$/, "", val)
+                            n = split(val, a, /[ \t]*,[ \t]*/)
+                            for (i = 1; i <= n; i++) {
+                                v = a[i]
+                                if (table) {
+                                    k = v; sub(/[ \t]*=.*/, "", k); gsub(/"/, "", k)
+                                    sub(/^[^=]*=[ \t]*/, "", v); gsub(/^"|"$/, "", v)
+                                    if (k != "") print sec "." key "." k "\t" v
+                                } else {
+                                    gsub(/^"|"$/, "", v)
+                                    if (v != "") print sec "." key "." (i - 1) "\t" v
+                                }
+                            }
+                        } else {
+                            gsub(/^"|"$/, "", val)
+                            print sec "." key "\t" val
+                        }
+                    }' "$config" 2>/dev/null || true
+                ;;
+            *)
+                sed 's#^[[:space:]]*//.*##' "$config" 2>/dev/null | json_flatten || true
+                ;;
+        esac | awk -F'\t' -v user="$user" -v client="$client" -v config="$config" -v pre="$prefix" '
+            function dash(s) { return s == "" ? "-" : s }
+            function add(list, v) { return list (list == "" ? "" : ",") v }
+            index($1, pre) == 1 {
+                rest = substr($1, length(pre) + 1)
+                dot = index(rest, ".")
+                name = dot ? substr(rest, 1, dot - 1) : rest
+                field = dot ? substr(rest, dot + 1) : ""
+                if (name == "") next
+                if (!(name in seen)) { seen[name] = 1; order[++n] = name }
+                v = $2
+                if (field == "command" || field == "command.path") {
+                    cmd[name] = v
+                } else if (field ~ /^(command\.)?args\.[0-9]+$/) {
+                    args[name] = args[name] (args[name] == "" ? "" : " ") v
+                    if (v ~ /^[^=]*=[\/~]/) sub(/^[^=]*=/, "", v)
+                    if (v ~ /^(\/|~)/) paths[name] = add(paths[name], v)
+                } else if (field == "cwd") {
+                    paths[name] = add(paths[name], v)
+                } else if (field == "url" || field == "serverUrl" || field == "httpUrl") {
+                    url[name] = v
+                } else if (field == "type" || field == "transport") {
+                    type[name] = v
+                } else if (field ~ /^(command\.)?env\.[^.]+$/) {
+                    sub(/^(command\.)?env\./, "", field)
+                    env[name] = add(env[name], field)
+                } else if ((field == "disabled" && v == "true") || (field == "enabled" && v == "false")) {
+                    off[name] = 1
+                }
+            }
+            END {
+                for (i = 1; i <= n; i++) {
+                    s = order[i]
+                    if (cmd[s] == "" && url[s] == "") continue
+                    t = type[s] != "" ? type[s] : (cmd[s] != "" ? "stdio" : "http")
+                    print user "\t" client "\t" config "\t" s "\t" t "\t" (s in off ? "false" : "true") "\t" dash(cmd[s]) "\t" dash(args[s]) "\t" dash(url[s]) "\t" dash(paths[s]) "\t" dash(env[s])
+                }
+            }'
+    done << EOF
+claude-desktop|$app_support/Claude/claude_desktop_config.json|mcpServers.
+claude-code|$home/.claude.json|mcpServers.
+cursor|$home/.cursor/mcp.json|mcpServers.
+windsurf|$home/.codeium/windsurf/mcp_config.json|mcpServers.
+vscode|$app_support/Code/User/mcp.json|servers.
+vscode|$app_support/Code/User/settings.json|mcp.servers.
+cline|$app_support/Code/User/globalStorage/saoudrizwan.claude-dev/settings/cline_mcp_settings.json|mcpServers.
+gemini-cli|$home/.gemini/settings.json|mcpServers.
+zed|$home/.config/zed/settings.json|context_servers.
+codex|$home/.codex/config.toml|mcp_servers.
+EOF
+}
+
+# mac_dev_toolchains <probe prefix> prints "tool\tversion\tpath\tsource" for
+# each developer toolchain on PATH (git, node, python3, docker, java, go), then
+# the Xcode command line tools ("xcode_clt") or Xcode ("xcode") that
+# xcode-select points at. source is how the tool was installed: a version
+# manager (nvm, pyenv, asdf, mise, volta, sdkman), homebrew, docker_desktop,
+# nix, "xcode" for Apple's /usr/bin shims, "system" for the /usr/bin/java
+# launcher, "user" for other paths under the home directory, or "manual".
+# The /usr/bin shims offer to install the tools when they are missing, so
+# they are only run when the tools are installed.
+mac_dev_toolchains() {
+    local prefix="${1:-config}" tool bin link version origin clt
+    clt="$(xcode-select -p 2>/dev/null || true)"
+    for tool in git node python3 docker java go; do
+        bin="$(command -v "$tool" 2>/dev/null)" || continue
+        case "$bin" in
+            /usr/bin/java) /usr/libexec/java_home >/dev/null 2>&1 || continue ;;
+            /usr/bin/*) [ -n "$clt" ] && [ -d "$clt" ] || continue ;;
+        esac
+        version="$(_toolchain_version "$prefix" "$tool" "$bin")"
+        [ -n "$version" ] || continue
+        link="$(readlink "$bin" 2>/dev/null || true)"
+        case "$bin:$link" in
+            "$HOME_DIR"/.nvm/*) origin=nvm ;;
+            "$HOME_DIR"/.pyenv/*) origin=pyenv ;;
+            "$HOME_DIR"/.asdf/*) origin=asdf ;;
+            "$HOME_DIR"/.local/share/mise/*) origin=mise ;;
+            "$HOME_DIR"/.volta/*) origin=volta ;;
+            "$HOME_DIR"/.sdkman/*) origin=sdkman ;;
+            *Docker.app/*|"$HOME_DIR"/.docker/bin/*) origin=docker_desktop ;;
+            "$HOME_DIR"/*) origin=user ;;
+            /opt/homebrew/*|*/Cellar/*|*/Caskroom/*) origin=homebrew ;;
+            /nix/*|/run/current-system/*|*/.nix-profile/*) origin=nix ;;
+            /usr/bin/java:*) origin=system ;;
+            /usr/bin/*) origin=xcode ;;
+            *) origin=manual ;;
+        esac
+        printf '%s\t%s\t%s\t%s\n' "$tool" "$version" "$bin" "$origin"
+    done
+    [ -n "$clt" ] && [ -d "$clt" ] || return 0
+    case "$clt" in
+        */CommandLineTools)
+            version="$(soft_out_probe "${prefix}.pkgutil_clt" pkgutil --pkg-info=com.apple.pkg.CLTools_Executables | awk '$1 == "version:" { print $2; exit }')"
+            tool=xcode_clt
+            ;;
+        *)
+            version="$(soft_out_probe "${prefix}.xcodebuild_version" xcodebuild -version | awk 'NR == 1 { print $2 }')"
+            tool=xcode
+            ;;
+    esac
+    [ -z "$version" ] || printf '%s\t%s\t%s\t%s\n' "$tool" "$version" "$clt" "apple"
+}
+
+# language_packages <probe prefix> prints "manager\tname\tversion" for each
+# package installed globally with the language tools on PATH: npm -g,
+# pipx, pip --user, gem (default gems that ship with Ruby aside), and cargo
+# install. A gem installed in several versions lists them comma-separated.
+# These land outside the system package manager, so nothing else lists them.
+language_packages() {
+    local prefix="${1:-config}"
+    if command -v npm >/dev/null 2>&1; then
+        # "/usr/lib/node_modules/@scope/name:@scope/name@1.2.3"; the first
+        # line is the prefix itself.
+        soft_out_probe "${prefix}.npm_ls_global" npm ls -g --depth=0 --parseable --long | awk -F: '
+            $1 ~ /\/node_modules\// {
+                spec = $NF
+                at = 0
+                for (i = length(spec); i > 1; i--) if (substr(spec, i, 1) == "@") { at = i; break }
+                if (at) print "npm\t" substr(spec, 1, at - 1) "\t" substr(spec, at + 1)
+            }'
+    fi
+    if command -v pipx >/dev/null 2>&1; then
+        soft_out_probe "${prefix}.pipx_list" pipx list --short | awk 'NF >= 2 { print "pipx\t" $1 "\t" $2 }'
+    fi
+    if command -v python3 >/dev/null 2>&1 && python3 -c 'import pip' >/dev/null 2>&1; then
+        soft_out_probe "${prefix}.pip_list_user" python3 -m pip list --user --format=freeze --disable-pip-version-check \
+            | awk -F'==' 'NF == 2 { print "pip\t" $1 "\t" $2 }'
+    fi
+    if command -v gem >/dev/null 2>&1; then
+        # "json (default: 2.7.1)", "rake (13.1.0, 13.0.6)"
+        soft_out_probe "${prefix}.gem_list" gem list --local | awk '
+            match($0, /\(.*\)$/) {
+                name = substr($0, 1, RSTART - 2)
+                n = split(substr($0, RSTART + 1, RLENGTH - 2), vs, /, */)
+                out = ""
+                for (i = 1; i <= n; i++) if (vs[i] !~ /^default: /) out = out (out == "" ? "" : ",") vs[i]
+                if (name != "" && out != "") print "gem\t" name "\t" out
+            }'
+    fi
+    if command -v cargo >/dev/null 2>&1; then
+        # "ripgrep v14.1.0:" then the binaries, indented.
+        soft_out_probe "${prefix}.cargo_install_list" cargo install --list | awk '
+            /^[^ \t]/ && NF >= 2 { v = $2; sub(/^v/, "", v); sub(/:$/, "", v); print "cargo\t" $1 "\t" v }'
+    fi
+}
+
+# runtime_installs <probe prefix> prints
+# "runtime\tversion\tpath\tmanager\tactive" for every installed python3, node,
+# go, java, and ruby, not only the one on PATH. manager is "system" for
+# macOS's Ruby, the command line tools' Python, and the JDKs in
+# /Library/Java, "manual" under /usr/local and for python.org's installers,
+# "homebrew", or the version manager that installed it (nvm, volta, pyenv,
+# rbenv, asdf, mise, sdkman). path is the runtime's binary. Installs of a
+# version manager or Homebrew are versioned by their directory, so only the
+# system's binaries are run. active is true for the one PATH resolves to,
+# through a version manager's shims.
+runtime_installs() {
+    local prefix="${1:-config}" home="$HOME_DIR" clt brew
+    local found on_path="" runtime bin resolved version manager path active seen=$'\n'
+    clt="$(xcode-select -p 2>/dev/null || true)"
+    found="$(
+        # The /usr/bin python3 and java shims offer to install what is
+        # missing, so the command line tools and JDKs are read directly.
+        for bin in /usr/bin/ruby ${clt:+"$clt"/usr/bin/python3} /Library/Java/JavaVirtualMachines/*/Contents/Home/bin/java \
+            /Library/Frameworks/Python.framework/Versions/3*/bin/python3 /usr/local/bin/python3 /usr/local/bin/node \
+            /usr/local/go/bin/go /usr/local/bin/ruby; do
+            [ -x "$bin" ] || continue
+            resolved="$(_resolve_path "$bin")"
+            case "$resolved" in */Cellar/*) continue ;; esac
+            case "$seen" in *$'\n'"$resolved"$'\n'*) continue ;; esac
+            seen="$seen$resolved"$'\n'
+            runtime="${bin##*/}"
+            version="$(_toolchain_version "$prefix" "$runtime" "$bin")"
+            [ -n "$version" ] || continue
+            case "$bin" in /usr/local/*|/Library/Frameworks/*) manager=manual ;; *) manager=system ;; esac
+            printf '%s\t%s\t%s\t%s\n' "$runtime" "$version" "$bin" "$manager"
+        done
+        for brew in /opt/homebrew /usr/local; do
+            _runtime_dirs homebrew python3 bin/python3 "$brew"/Cellar/python@3*/*
+            _runtime_dirs homebrew node bin/node "$brew"/Cellar/node/* "$brew"/Cellar/node@*/*
+            _runtime_dirs homebrew go bin/go "$brew"/Cellar/go/* "$brew"/Cellar/go@*/*
+            _runtime_dirs homebrew java bin/java "$brew"/Cellar/openjdk/* "$brew"/Cellar/openjdk@*/*
+            _runtime_dirs homebrew ruby bin/ruby "$brew"/Cellar/ruby/* "$brew"/Cellar/ruby@*/*
+        done
+        _runtime_version_managers "$home"
+    )"
+    for runtime in python3 node go java ruby; do
+        bin="$(command -v "$runtime" 2>/dev/null)" || continue
+        case "$bin" in
+            /usr/bin/java) /usr/libexec/java_home >/dev/null 2>&1 || continue ;;
+            /usr/bin/python3) [ -n "$clt" ] && [ -d "$clt" ] || continue ;;
+        esac
+        resolved="$(_resolve_path "$bin")"
+        case "$resolved" in
+            */shims/*)
+                case "$resolved" in
+                    "$home"/.pyenv/*) manager=pyenv ;;
+                    "$home"/.rbenv/*) manager=rbenv ;;
+                    "$home"/.asdf/*) manager=asdf ;;
+                    *) manager=mise ;;
+                esac
+                on_path="$on_path$runtime"$'\t'"$manager"$'\t'"$(_toolchain_version "$prefix" "$runtime" "$bin")"$'\n'
+                ;;
+            /usr/bin/java)
+                # The launcher runs the JDK java_home picks.
+                on_path="$on_path$runtime"$'\t'"$(_resolve_path "$(/usr/libexec/java_home 2>/dev/null)/bin/java")"$'\n'
+                ;;
+            /usr/bin/python3) on_path="$on_path$runtime"$'\t'"$(_resolve_path "$clt/usr/bin/python3")"$'\n' ;;
+            *) on_path="$on_path$runtime"$'\t'"$resolved"$'\n' ;;
+        esac
+    done
+    while IFS=$'\t' read -r runtime version path manager; do
+        [ -n "$runtime" ] || continue
+        active=false
+        case "$on_path" in
+            *"$runtime"$'\t'"$(_resolve_path "$path")"$'\n'*) active=true ;;
+            *"$runtime"$'\t'"$manager"$'\t'"$version"$'\n'*) active=true ;;
+        esac
+        printf '%s\t%s\t%s\t%s\t%s\n' "$runtime" "${version:--}" "$path" "$manager" "$active"
+    done <<< "$found"
+}
+
+# _resolve_path <path> prints path with its symlinks followed, like GNU
+# readlink -f, which macOS before 12.3 lacks.
+_resolve_path() {
+    local p="$1" link n=0
+    while [ -L "$p" ] && (( n < 20 )); do
+        link="$(readlink "$p")"
+        case "$link" in
+            /*) p="$link" ;;
+            *) p="$(dirname "$p")/$link" ;;
+        esac
+        n=$((n + 1))
+    done
+    (cd -P "$(dirname "$p")" 2>/dev/null && printf '%s/%s\n' "$(pwd -P)" "$(basename "$p")") || printf '%s\n' "$p"
+}
+
+# _runtime_version_managers <home> lists the runtimes the version managers
+# under home installed, for runtime_installs.
+_runtime_version_managers() {
+    local home="$1" asdf="$1/.asdf/installs" mise="$1/.local/share/mise/installs"
+    _runtime_dirs nvm node bin/node "$home"/.nvm/versions/node/*
+    _runtime_dirs volta node bin/node "$home"/.volta/tools/image/node/*
+    _runtime_dirs pyenv python3 bin/python3 "$home"/.pyenv/versions/*
+    _runtime_dirs rbenv ruby bin/ruby "$home"/.rbenv/versions/*
+    _runtime_dirs asdf python3 bin/python3 "$asdf"/python/*
+    _runtime_dirs asdf node bin/node "$asdf"/nodejs/*
+    _runtime_dirs asdf go go/bin/go "$asdf"/golang/*
+    _runtime_dirs asdf java bin/java "$asdf"/java/*
+    _runtime_dirs asdf ruby bin/ruby "$asdf"/ruby/*
+    _runtime_dirs mise python3 bin/python3 "$mise"/python/*
+    _runtime_dirs mise node bin/node "$mise"/node/*
+    _runtime_dirs mise go bin/go "$mise"/go/*
+    _runtime_dirs mise java bin/java "$mise"/java/*
+    _runtime_dirs mise ruby bin/ruby "$mise"/ruby/*
+    _runtime_dirs sdkman java bin/java "$home"/.sdkman/candidates/java/*
+}
+
+# _runtime_dirs <manager> <runtime> <binary under dir> <dir>... prints a
+# runtime_installs line for each install directory holding the binary,
+# versioned by the directory's name: v20.11.1, 3.12.2_1, temurin-21.0.2+13.
+# Symlinked aliases such as mise's "20" or sdkman's "current" are skipped.
+_runtime_dirs() {
+    local manager="$1" runtime="$2" rel="$3" dir
+    shift 3
+    for dir in "$@"; do
+        [ -d "$dir" ] && [ ! -L "$dir" ] && [ -x "$dir/$rel" ] || continue
+        printf '%s\t%s\t%s\t%s\n' "$runtime" "$(printf '%s\n' "${dir##*/}" | awk 'match($0, /[0-9]+(\.[0-9]+)*/) { print substr($0, RSTART, RLENGTH) }')" "$dir/$rel" "$manager"
+    done
+}
+
+# _toolchain_version <probe prefix> <tool> <binary> prints the version a
+# developer tool reports, e.g. 20.11.1 for node or 1.8.0_402 for Java 8.
+_toolchain_version() {
+    local out
+    case "$2" in
+        go) out="$(soft_out_probe "$1.toolchain_version" "$3" version)" ;;
+        java) out="$(soft_out_probe "$1.toolchain_version" sh -c '"$0" -version 2>&1' "$3")" ;;
+        *) out="$(soft_out_probe "$1.toolchain_version" "$3" --version)" ;;
+    esac
+    printf '%s\n' "$out" | awk 'match($0, /[0-9]+(\.[0-9]+)+(_[0-9]+)?/) { print substr($0, RSTART, RLENGTH); exit }'
+}
+
+# Prints the sshd settings an audit cares about as "keyword<TAB>value" lines
+# (lowercase keywords; port and hostcertificate may repeat), after a
+# "source<TAB>..." line. The
+# source is "sshd -T", the effective configuration, when running as root;
+# otherwise "sshd_config", parsed from /etc/ssh/sshd_config and its Include
+# files the way sshd reads them (the first value of a keyword wins; Match
+# blocks are skipped), with OpenSSH's defaults for keywords left unset.
+# Prints nothing when there is no sshd. Probe names are prefixed with $1.
+sshd_settings() {
+    local probe_prefix="${1:-identity}"
+    local config=/etc/ssh/sshd_config out=""
+    if [ ! -f "$config" ] && ! command -v sshd >/dev/null 2>&1; then
+        return 0
+    fi
+    if [ "$(id -u)" = "0" ] && command -v sshd >/dev/null 2>&1; then
+        out="$(soft_out_probe "${probe_prefix}.sshd_t" sshd -T 2>/dev/null)"
+    fi
+    if [ -n "$out" ]; then
+        printf 'source\tsshd -T\n'
+    elif [ -r "$config" ]; then
+        printf 'source\tsshd_config\n'
+        out="$(_sshd_config_lines "$config" | awk '
+            { sub(/^[ \t]+/, "") }
+            /^#/ || $0 == "" { next }
+            $0 == "@end-include" { in_match = 0; next }
+            {
+                k = tolower($0); sub(/[ \t=].*/, "", k)
+                v = $0; sub(/^[^ \t=]+[ \t]*=?[ \t]*/, "", v); sub(/[ \t]+#.*$/, "", v)
+            }
+            k == "match" { in_match = 1; next }
+            in_match { next }
+            k == "challengeresponseauthentication" { k = "kbdinteractiveauthentication" }
+            k == "port" || k == "hostcertificate" { print k " " v; next }
+            !(k in seen) { seen[k] = 1; print k " " v }
+        ')"
+    else
+        return 0
+    fi
+    printf '%s\n' "$out" | awk '
+        BEGIN {
+            def["permitrootlogin"] = "prohibit-password"; def["passwordauthentication"] = "yes"
+            def["pubkeyauthentication"] = "yes"; def["kbdinteractiveauthentication"] = "yes"
+            def["permitemptypasswords"] = "no"; def["x11forwarding"] = "no"; def["maxauthtries"] = "6"
+            def["trustedusercakeys"] = "none"; def["authorizedprincipalsfile"] = "none"
+            def["authorizedkeysfile"] = ".ssh/authorized_keys .ssh/authorized_keys2"
+            paths["trustedusercakeys"]; paths["authorizedprincipalsfile"]; paths["authorizedkeysfile"]
+        }
+        {
+            k = tolower($1); v = $0; sub(/^[^ \t]+[ \t]+/, "", v)
+            if (k == "port") { print "port\t" v; ports++; next }
+            if (k == "hostcertificate") { print k "\t" v; next }
+            if (!(k in def) || (k in got)) next
+            if (k == "permitrootlogin" && v == "without-password") v = "prohibit-password"
+            got[k] = v
+        }
+        END {
+            if (!ports) print "port\t22"
+            for (k in def) print k "\t" (!(k in got) ? def[k] : (k in paths) ? got[k] : tolower(got[k]))
+        }
+    '
+}
+
+# _sshd_config_lines <file> prints an sshd_config with its Include directives
+# replaced by the files they name, each followed by an "@end-include" line,
+# since a Match block in an included file ends with the file.
+_sshd_config_lines() {
+    local line pattern file
+    while IFS= read -r line || [ -n "$line" ]; do
+        if [[ "$line" =~ ^[[:space:]]*[Ii][Nn][Cc][Ll][Uu][Dd][Ee][[:space:]=]+(.*)$ ]]; then
+            for pattern in ${BASH_REMATCH[1]}; do
+                [[ "$pattern" == /* ]] || pattern="/etc/ssh/$pattern"
+                # shellcheck disable=SC2086
+                for file in $pattern; do
+                    [ -r "$file" ] || continue
+                    cat "$file"
+                    printf '\n@end-include\n'
+                done
+            done
+        else
+            printf '%s\n' "$line"
+        fi
+    done < "$1"
+}
+
+# Prints one "alias<TAB>hostname<TAB>user<TAB>port<TAB>identity_file<TAB>proxy_jump"
+# line per Host alias in an ssh client config ($1), skipping wildcard
+# patterns. Values are the ones set inside the alias's own Host block; unset
+# ones are printed as "-".
+ssh_client_hosts() {
+    [ -r "$1" ] || return 0
+    awk '
+        function get(k) { return (k in v) ? v[k] : "-" }
+        function flush(   i) {
+            for (i = 1; i <= n; i++) print alias[i] "\t" get("hostname") "\t" get("user") "\t" get("port") "\t" get("identityfile") "\t" get("proxyjump")
+            n = 0; split("", v)
+        }
+        { sub(/^[ \t]+/, "") }
+        /^#/ || $0 == "" { next }
+        {
+            k = tolower($0); sub(/[ \t=].*/, "", k)
+            val = $0; sub(/^[^ \t=]+[ \t]*=?[ \t]*/, "", val); gsub(/"/, "", val)
+        }
+        k == "host" || k == "match" {
+            flush()
+            if (k == "host") {
+                m = split(val, pats, /[ \t]+/)
+                for (i = 1; i <= m; i++) if (pats[i] !~ /[*?!]/) alias[++n] = pats[i]
+            }
+            next
+        }
+        n && !(k in v) { v[k] = val }
+        END { flush() }
+    ' "$1" 2>/dev/null
+}
+
+# ssh_authorized_keys <user> <home> prints one
+# "user<TAB>file<TAB>type<TAB>fingerprint<TAB>options<TAB>age_days<TAB>cert_authority"
+# line per key in the user's authorized_keys files. options is true when the
+# entry restricts the key (from=, command=, ...); cert_authority when it trusts
+# the key as a CA for user certificates. age_days is the age of the file,
+# since authorized_keys records no date per key.
+ssh_authorized_keys() {
+    local user="$1" home="$2" file mtime age now key_type blob options cert_authority fingerprint
+    now="$(date +%s)"
+    for file in "$home/.ssh/authorized_keys" "$home/.ssh/authorized_keys2"; do
+        [ -r "$file" ] || continue
+        mtime="$(stat -f %m "$file" 2>/dev/null || echo "$now")"
+        age=$(( (now - mtime) / 86400 ))
+        while IFS=$'\t' read -r key_type blob options cert_authority; do
+            fingerprint="$(printf '%s %s\n' "$key_type" "$blob" | ssh-keygen -lf - 2>/dev/null | awk '{print $2; exit}')"
+            printf '%s\t%s\t%s\t%s\t%s\t%s\t%s\n' "$user" "$file" "$key_type" "${fingerprint:-unknown}" "$options" "$age" "$cert_authority"
+        done < <(awk '
+            /^[ \t]*(#|$)/ { next }
+            {
+                for (i = 1; i < NF; i++) if ($i ~ /^(ssh-|ecdsa-|sk-)/) {
+                    print $i "\t" $(i + 1) "\t" (i > 1 ? "true" : "false") "\t" (i > 1 && $1 ~ /(^|,)cert-authority(,|$)/ ? "true" : "false")
+                    break
+                }
+            }
+        ' "$file" 2>/dev/null)
+    done
+}
+
+# ssh_certificate_details <file> prints
+# "kind<TAB>key_id<TAB>principals<TAB>valid_to<TAB>days_left<TAB>ca_fingerprint"
+# for an OpenSSH certificate, from `ssh-keygen -L`. kind is user or host;
+# principals is comma-separated, or "-" when the certificate is valid for any.
+# valid_to is "forever" (days_left "-") or the expiry in the signer's local
+# time, which days_left reads as UTC.
+ssh_certificate_details() {
+    [ -r "$1" ] || return 0
+    ssh-keygen -L -f "$1" 2>/dev/null | awk -v now="$(date -u +%s)" "$_AWK_EPOCH"'
+        $1 == "Type:" { kind = ($(NF - 1) == "host") ? "host" : "user" }
+        $1 == "Signing" && $2 == "CA:" { ca = $4 }
+        $1 == "Key" && $2 == "ID:" { id = $0; sub(/^[^:]*: */, "", id); gsub(/"/, "", id) }
+        $1 == "Valid:" {
+            to = "forever"
+            if (match($0, /(to|before) [0-9][0-9T:-]*/)) { to = substr($0, RSTART, RLENGTH); sub(/^[a-z]+ /, "", to) }
+        }
+        in_principals && index($0, ":") { in_principals = 0 }
+        in_principals { principals = principals (principals == "" ? "" : ",") $1; next }
+        $1 == "Principals:" { in_principals = ($2 != "(none)") }
+        END {
+            if (kind == "") exit
+            days = "-"
+            if (to != "forever") {
+                split(to, t, /[-T:]/)
+                d = (epoch(t[1], t[2], t[3], t[4], t[5], t[6]) - now) / 86400
+                days = (d < 0 && d != int(d)) ? int(d) - 1 : int(d)
+            }
+            print kind "\t" id "\t" (principals == "" ? "-" : principals) "\t" to "\t" days "\t" ca
+        }'
+}
+
+# secrets_agents prints "agent\tsocket\tkeys\tlifetime\tconfirm" for each
+# secrets agent the current user runs or has a live SSH agent socket for:
+# ssh-agent, gpg-agent, 1Password, Bitwarden, gnome-keyring, KWallet, and
+# KeePassXC. keys is how many identities the agent's SSH socket lists. The
+# agent protocol does not expose per-key constraints, so lifetime and confirm
+# are what keys get by default: ssh-agent's -t, gpg-agent's SSH cache TTL and
+# sshcontrol confirm flags, and AddKeysToAgent in ~/.ssh/config for keys ssh
+# adds itself. Fields that do not apply, such as constraints for agents that
+# prompt from their own app, are "-".
+secrets_agents() {
+    local uid procs add_keys add_time add_confirm auth_agent agent pattern args sock cand keys lifetime confirm out rc
+    uid="$(id -u)"
+    procs="$(soft_out_probe "identity.ps_agents" ps -A -o uid=,args= 2>/dev/null | awk -v uid="$uid" '
+        $1 == uid { n = split($2, p, "/"); args = $0; sub(/^ *[0-9]+ +/, "", args); print tolower(p[n]) "\t" args }')"
+    add_keys="$(awk '{ l = tolower($0) } match(l, /^[ \t]*addkeystoagent[ \t=]+/) { print substr(l, RLENGTH + 1); exit }' "$HOME_DIR/.ssh/config" 2>/dev/null || true)"
+    add_time="$(printf '%s\n' "$add_keys" | awk '{ for (i = 1; i <= NF; i++) if ($i ~ /^[0-9]+[smhdw]?$/) { print $i; exit } }')"
+    add_confirm=false
+    case " $add_keys " in *" confirm "*) add_confirm=true ;; esac
+    case "$(printf '%s' "${SSH_AUTH_SOCK:-}" | tr '[:upper:]' '[:lower:]')" in
+        "") auth_agent="" ;;
+        *1password*) auth_agent=1password ;;
+        *bitwarden*) auth_agent=bitwarden ;;
+        *gpg-agent*|*gnupg*) auth_agent=gpg-agent ;;
+        *keyring*) auth_agent=gnome-keyring ;;
+        *keepassxc*) auth_agent=keepassxc ;;
+        *) auth_agent=ssh-agent ;;
+    esac
+    for agent in ssh-agent gpg-agent 1password bitwarden gnome-keyring kwallet keepassxc; do
+        case "$agent" in
+            gnome-keyring) pattern='gnome-keyring-daemon' ;;
+            kwallet) pattern='kwalletd[0-9]*' ;;
+            1password) pattern='1password|1password-ssh-agent' ;;
+            *) pattern="$agent" ;;
+        esac
+        args="$(printf '%s\n' "$procs" | awk -F'\t' -v p="^($pattern)$" '$1 ~ p { print $2; exit }')"
+        sock=""
+        if [ "$auth_agent" = "$agent" ]; then
+            sock="$SSH_AUTH_SOCK"
+        else
+            while IFS= read -r cand; do
+                if [ -S "$cand" ]; then sock="$cand"; break; fi
+            done < <(case "$agent" in
+                gpg-agent) printf '%s\n' "${GNUPGHOME:-$HOME_DIR/.gnupg}/S.gpg-agent.ssh" "/run/user/$uid/gnupg/S.gpg-agent.ssh" ;;
+                1password) printf '%s\n' "$HOME_DIR/.1password/agent.sock" "$HOME_DIR/Library/Group Containers/2BUA8C4S2C.com.1password/t/agent.sock" ;;
+                bitwarden) printf '%s\n' "$HOME_DIR/.bitwarden-ssh-agent.sock" "$HOME_DIR/Library/Containers/com.bitwarden.desktop/Data/.bitwarden-ssh-agent.sock" ;;
+                gnome-keyring) printf '%s\n' "${XDG_RUNTIME_DIR:-/run/user/$uid}/keyring/ssh" ;;
+            esac)
+        fi
+        keys="-"
+        if [ -n "$sock" ] && [ -S "$sock" ] && command -v ssh-add >/dev/null 2>&1; then
+            rc=0
+            out="$(SSH_AUTH_SOCK="$sock" ssh-add -l 2>/dev/null)" || rc=$?
+            case "$rc" in
+                0) keys="$(printf '%s\n' "$out" | grep -c . || true)" ;;
+                1) keys=0 ;;
+                *) sock="" ;;
+            esac
+        else
+            sock=""
+        fi
+        [ -n "$args" ] || [ -n "$sock" ] || continue
+        lifetime="-"
+        confirm="-"
+        case "$agent" in
+            ssh-agent|gnome-keyring|keepassxc)
+                lifetime="$(printf '%s\n' "$args" | awk '{ for (i = 1; i < NF; i++) if ($i == "-t") { print $(i + 1); exit } }')"
+                lifetime="${lifetime:-${add_time:--}}"
+                confirm="$add_confirm"
+                ;;
+            gpg-agent)
+                lifetime="$(awk '$1 == "default-cache-ttl-ssh" { print $2 "s"; exit }' "${GNUPGHOME:-$HOME_DIR/.gnupg}/gpg-agent.conf" 2>/dev/null || true)"
+                lifetime="${lifetime:-1800s}"
+                confirm="$(awk '!/^[ \t]*(#|$)/ && !/^!/ { n++; if ($0 ~ /confirm/) c++ } END { print (n > 0 && c == n) ? "true" : "false" }' "${GNUPGHOME:-$HOME_DIR/.gnupg}/sshcontrol" 2>/dev/null || true)"
+                confirm="${confirm:-false}"
+                ;;
+        esac
+        [ "$keys" != "-" ] || { lifetime="-"; confirm="-"; }
+        printf '%s\t%s\t%s\t%s\t%s\n' "$agent" "${sock:--}" "$keys" "$lifetime" "$confirm"
+    done
+}
+
+# env_secret_patterns <source> reads NAME=value lines, with or without
+# `export` and quotes, and prints "source\tname\tpattern" for each variable
+# whose value looks like a credential: a private key, a cloud or SaaS token
+# format, a JWT, a URL with a password, or any long value of a variable named
+# like a token, secret, password, or key. Values are never printed. Values
+# that point elsewhere (paths, $VARS, 1Password op:// and vault: references)
+# are not credentials themselves.
+env_secret_patterns() {
+    awk -F= -v src="$1" '
+        function pattern(name, v,   n) {
+            if (v ~ /-----BEGIN [A-Z ]*PRIVATE KEY-----/) return "private_key"
+            if (match(v, /(AKIA|ASIA)[A-Z0-9]+/) && RLENGTH >= 20) return "aws_access_key"
+            if ((match(v, /gh[pousr]_[A-Za-z0-9]+/) && RLENGTH >= 40) || v ~ /github_pat_[A-Za-z0-9_]/) return "github_token"
+            if (match(v, /glpat-[A-Za-z0-9_-]+/) && RLENGTH >= 26) return "gitlab_token"
+            if (match(v, /xox[abprs]-[A-Za-z0-9-]+/) && RLENGTH >= 15) return "slack_token"
+            if (match(v, /AIza[0-9A-Za-z_-]+/) && RLENGTH >= 39) return "google_api_key"
+            if (match(v, /[rs]k_live_[0-9A-Za-z]+/) && RLENGTH >= 24) return "stripe_key"
+            if (match(v, /sk-[A-Za-z0-9_-]+/) && RLENGTH >= 23) return "api_key"
+            if (match(v, /npm_[A-Za-z0-9]+/) && RLENGTH >= 40) return "npm_token"
+            if (match(v, /eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+/) && RLENGTH >= 40) return "jwt"
+            if (v ~ /[A-Za-z][A-Za-z0-9+.-]*:\/\/[^\/:@ ]+:[^\/@ ]+@/) return "url_credentials"
+            n = toupper(name)
+            if (n !~ /(TOKEN|SECRET|PASSWORD|PASSWD|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIALS?)/) return ""
+            if (n ~ /_(FILE|PATH|DIR|SOCK|SOCKET|URL|CMD|COMMAND|HELPER|ID|NAME|USER|TYPE)$/) return ""
+            if (length(v) < 8 || v ~ /[ \t]/ || v ~ /^(\/|~|\$|op:\/\/|vault:|keychain:)/) return ""
+            return "secret_name"
+        }
+        {
+            line = $0
+            sub(/^[ \t]*(export[ \t]+)?/, "", line)
+            if (line !~ /^[A-Za-z_][A-Za-z0-9_]*=/) next
+            name = substr(line, 1, index(line, "=") - 1)
+            v = substr(line, index(line, "=") + 1)
+            sub(/;[ \t]*export[ \t]+[A-Za-z_][A-Za-z0-9_]*;?[ \t]*$/, "", v)
+            if (v ~ /^".*"$/ || v ~ /^\047.*\047$/) v = substr(v, 2, length(v) - 2)
+            p = pattern(name, v)
+            if (p != "" && !seen[name]++) print src "\t" name "\t" p
+        }'
+}
+
+# environment_variables prints the audit's environment as NAME=value lines,
+# with newlines in values escaped so each variable is one line.
+environment_variables() {
+    awk 'BEGIN { for (k in ENVIRON) { v = ENVIRON[k]; gsub(/\n/, "\\n", v); print k "=" v } }'
+}
+
+# env_secret_exposures prints "source\tname\tpattern" for each variable that
+# looks like it holds a credential (see env_secret_patterns), in the exported
+# environment (source "environment"), in the variables set with `launchctl
+# setenv` that every app the user launches inherits (source "launchctl"), and
+# in ~/.env (source is the file).
+env_secret_exposures() {
+    environment_variables | env_secret_patterns environment
+    if command -v launchctl >/dev/null 2>&1; then
+        soft_out_probe "identity.launchctl_export" launchctl export 2>/dev/null | env_secret_patterns launchctl
+    fi
+    if [ -f "$HOME_DIR/.env" ] && [ -r "$HOME_DIR/.env" ]; then
+        env_secret_patterns "$HOME_DIR/.env" < "$HOME_DIR/.env"
+    fi
+}
+
+# Prints certificate_lines output for the user's login keychain and the
+# System keychain, whose certificates were added by users, admins, or an MDM
+# (user_added), then the SystemRootCertificates keychain Apple ships. Prints
+# nothing without openssl. Probe names are prefixed with $1.
+mac_certificates() {
+    local probe_prefix="${1:-config}"
+    command -v openssl >/dev/null 2>&1 || return 0
+    {
+        if [ -e "$HOME_DIR/Library/Keychains/login.keychain-db" ]; then
+            soft_out_probe "${probe_prefix}.security_find_certificate" security find-certificate -a -p "$HOME_DIR/Library/Keychains/login.keychain-db" \
+                | _pem_certificate_details login true
+        fi
+        soft_out_probe "${probe_prefix}.security_find_certificate" security find-certificate -a -p /Library/Keychains/System.keychain \
+            | _pem_certificate_details system true
+        soft_out_probe "${probe_prefix}.security_find_certificate" security find-certificate -a -p /System/Library/Keychains/SystemRootCertificates.keychain \
+            | _pem_certificate_details system_roots false
+    } | certificate_lines
+}
+
+# mac_mdm_enrollment <probe prefix> prints "mdm_enrolled\tdep_enrolled\tuser_approved"
+# from `profiles status -type enrollment`, each true or false.
+mac_mdm_enrollment() {
+    local probe_prefix="${1:-config}"
+    soft_out_probe "${probe_prefix}.profiles_status" profiles status -type enrollment \
+        | awk '
+            /^Enrolled via DEP:/ { dep = ($0 ~ /: *Yes/) }
+            /^MDM enrollment:/ { mdm = ($0 ~ /: *Yes/); approved = ($0 ~ /User Approved/) }
+            END { printf "%s\t%s\t%s\n", mdm ? "true" : "false", dep ? "true" : "false", approved ? "true" : "false" }
+        ' || true
+}
+
+# mac_config_profiles <probe prefix> prints
+# "scope\tidentifier\tname\torganization\tverified\tpayload_types\tmdm_server"
+# for each installed configuration profile in `profiles show -output
+# stdout-xml`. scope is "system" for device profiles and the user name for
+# user profiles, payload_types is a comma-separated list or "-", and
+# mdm_server is the host of the profile's MDM payload, or "-".
+mac_config_profiles() {
+    local probe_prefix="${1:-config}"
+    soft_out_probe "${probe_prefix}.profiles_show" profiles show -output stdout-xml \
+        | awk '
+            function flush(d,   ident, t) {
+                ident = f[d, "ProfileIdentifier"]
+                if (ident == "") return
+                t = types; if (t == "") t = "-"
+                print scope "\t" ident "\t" v(d, "ProfileDisplayName") "\t" v(d, "ProfileOrganization") "\t" \
+                    (f[d, "ProfileVerificationState"] == "verified" ? "true" : "false") "\t" t "\t" (server == "" ? "-" : server)
+                types = ""; split("", seen); server = ""
+            }
+            function v(d, k) { return f[d, k] == "" ? "-" : f[d, k] }
+            {
+                line = $0; gsub(/^[ \t]+|[ \t]+$/, "", line)
+                gsub(/\t/, " ", line)
+            }
+            line == "<dict>" || line == "<array>" { depth++; id[depth] = ++n; key = ""; next }
+            line == "</dict>" || line == "</array>" {
+                d = id[depth]
+                if (f[d, "PayloadType"] == "com.apple.mdm" && f[d, "ServerURL"] != "") {
+                    server = f[d, "ServerURL"]; sub(/^[a-zA-Z]+:\/\//, "", server); sub(/[\/:?].*/, "", server)
+                }
+                flush(d); depth--; key = ""; next
+            }
+            line ~ /^<key>/ {
+                key = line; gsub(/<\/?key>/, "", key)
+                if (depth == 1) scope = (key == "_computerlevel") ? "system" : key
+                next
+            }
+            key != "" && line ~ /^<(string|integer|real|date|true|false)/ {
+                val = line
+                if (val ~ /^<true/) val = "true"
+                else if (val ~ /^<false/) val = "false"
+                else { gsub(/<[^>]*>/, "", val); gsub(/&lt;/, "<", val); gsub(/&gt;/, ">", val); gsub(/&amp;/, "\\&", val) }
+                d = id[depth]
+                f[d, key] = val
+                if (key == "PayloadType" && !(val in seen)) { seen[val] = 1; types = types (types == "" ? "" : ",") val }
+                key = ""
+            }
+        ' || true
+}
+
+# mac_malware_protection <probe prefix> prints "component\tversion\tupdated"
+# for each of Apple's built-in malware protection data bundles that is
+# installed: xprotect (signatures), xprotect_remediator (the scanner that
+# replaced MRT), mrt (still present on older releases), and gatekeeper (the
+# gkopaque allowlist). updated is when the bundle was last replaced, in epoch
+# seconds; version is "-" when the bundle does not record one.
+mac_malware_protection() {
+    local probe_prefix="${1:-config}"
+    local component paths plist version updated
+    while IFS='|' read -r component paths; do
+        for plist in $paths; do
+            [ -f "$plist" ] || continue
+            version="$(soft_out_probe "${probe_prefix}.${component}_version" plutil -extract CFBundleShortVersionString raw -o - "$plist")"
+            updated="$(stat -f %m "$plist" 2>/dev/null || echo 0)"
+            printf '%s\t%s\t%s\n' "$component" "${version:--}" "$updated"
+            break
+        done
+    done << 'COMPONENTS'
+xprotect|/private/var/protected/xprotect/XProtect.bundle/Contents/Info.plist /Library/Apple/System/Library/CoreServices/XProtect.bundle/Contents/Info.plist /System/Library/CoreServices/XProtect.bundle/Contents/Info.plist
+xprotect_remediator|/Library/Apple/System/Library/CoreServices/XProtect.app/Contents/Info.plist
+mrt|/Library/Apple/System/Library/CoreServices/MRT.app/Contents/Info.plist /System/Library/CoreServices/MRT.app/Contents/Info.plist
+gatekeeper|/private/var/db/gkopaque.bundle/Contents/Info.plist
+COMPONENTS
+}
+
+# mac_firmware <probe prefix> prints "chip\tfirmware\tloader\tboot_security\tthird_party_kexts".
+# chip is apple_silicon, t2, or intel; firmware and loader are the System
+# Firmware and OS Loader versions. boot_security is the Startup Security
+# policy: full, reduced, or permissive from bputil on Apple Silicon, full,
+# medium, or none from the AppleSecureBootPolicy NVRAM variable on T2 Macs.
+# third_party_kexts is whether the policy lets third-party kernel extensions
+# load (Apple Silicon only). Fields that cannot be read are "-"; bputil needs
+# root on some releases.
+mac_firmware() {
+    local probe_prefix="${1:-config}"
+    local chip=intel firmware loader boot_security="-" kexts="-" hw out
+    hw="$(soft_out_probe "${probe_prefix}.system_profiler_hardware" system_profiler SPHardwareDataType)"
+    firmware="$(printf '%s\n' "$hw" | awk -F': ' '$1 ~ /System Firmware Version$/ { print $2; exit }')"
+    loader="$(printf '%s\n' "$hw" | awk -F': ' '$1 ~ /OS Loader Version$/ { print $2; exit }')"
+    if [ "$(uname -m)" = arm64 ]; then
+        chip=apple_silicon
+        out="$(soft_out_probe "${probe_prefix}.bputil_policy" bputil -d)"
+        boot_security="$(printf '%s\n' "$out" | awk -F': *' '$1 ~ /Security Mode/ { m = tolower($2); sub(/ .*/, "", m); print m; exit }')"
+        case "$(printf '%s\n' "$out" | awk -F': *' '$1 ~ /3rd Party Kexts Status/ { print tolower($2); exit }')" in
+            enabled*) kexts=true ;;
+            disabled*) kexts=false ;;
+        esac
+    else
+        case "$(soft_out_probe "${probe_prefix}.system_profiler_ibridge" system_profiler SPiBridgeDataType)" in
+            *T2*) chip=t2 ;;
+        esac
+    fi
+    if [ "$chip" = t2 ]; then
+        # "94b73556-...:AppleSecureBootPolicy	%02"
+        case "$(soft_out_probe "${probe_prefix}.nvram_secure_boot_policy" nvram 94b73556-2197-4702-82a8-3e1337dafbfb:AppleSecureBootPolicy | awk '{ print $NF }')" in
+            %02) boot_security=full ;;
+            %01) boot_security=medium ;;
+            %00) boot_security=none ;;
+        esac
+    fi
+    printf '%s\t%s\t%s\t%s\t%s\n' "$chip" "${firmware:--}" "${loader:--}" "${boot_security:--}" "$kexts"
+}
+
+# mac_time_sync <probe prefix> prints "service\tenabled\tsynchronized\toffset_ms\tservers"
+# like linux_time_sync. service is timed; enabled is the "Set time and date
+# automatically" setting, "-" when systemsetup cannot read it (it needs root).
+# macOS does not report whether the clock is in sync, so synchronized is
+# always "-". offset_ms is measured with one sntp query to the first server,
+# by NTP convention positive when the local clock is behind.
+mac_time_sync() {
+    local probe_prefix="${1:-config}"
+    local enabled="-" offset="" servers="" out
+    out="$(soft_out_probe "${probe_prefix}.systemsetup_networktime" systemsetup -getusingnetworktime)"
+    case "$out" in
+        *": On"*) enabled=true ;;
+        *": Off"*) enabled=false ;;
+    esac
+    if [ -r /etc/ntp.conf ]; then
+        servers="$(awk '$1 == "server" || $1 == "pool" { print $2 }' /etc/ntp.conf | awk 'NF && !seen[$0]++' | tr '\n' ' ')"
+        servers="${servers% }"
+    fi
+    if [ "$enabled" != false ] && [ -n "$servers" ] && command -v sntp >/dev/null 2>&1; then
+        # "+0.012345 +/- 0.034567 time.apple.com 17.253.4.125"
+        offset="$(soft_out_probe "${probe_prefix}.sntp_offset" sntp -t 2 "${servers%% *}" | awk '
+            $1 ~ /^[-+][0-9.]+$/ && $2 == "+/-" { printf "%.3f\n", $1 * 1000; exit }')"
+    fi
+    printf 'timed\t%s\t-\t%s\t%s\n' "$enabled" "${offset:--}" "${servers:--}"
+}
+
+# mac_disk_volumes <probe prefix> prints "mount\tdevice\tfstype\tencryption\tremovable"
+# for every mounted disk volume except the system's own helper volumes under
+# /System/Volumes (the Data volume is kept). encryption is apfs for an
+# encrypted APFS volume (FileVault on the boot volumes), corestorage for an
+# encrypted Core Storage volume, none otherwise, and "-" when diskutil cannot
+# tell. removable is true for removable media and external drives.
+mac_disk_volumes() {
+    local probe_prefix="${1:-config}"
+    local device target fstype info enc removable seen=""
+    while IFS=$'\t' read -r device target fstype; do
+        [ -n "$device" ] || continue
+        case "$target" in
+            /System/Volumes/Data) ;;
+            /System/Volumes/*) continue ;;
+        esac
+        case "$seen" in *"|$device|"*) continue ;; esac
+        seen="$seen|$device|"
+        info="$(soft_out_probe "${probe_prefix}.diskutil_info" diskutil info "$device")"
+        enc="$(printf '%s\n' "$info" | awk -F': *' -v fs="$fstype" '
+            $1 ~ /^ *FileVault$/ { fv = ($2 ~ /^Yes/) ? "yes" : "no" }
+            $1 ~ /^ *Encrypted$/ && $2 ~ /^Yes/ { cs = 1 }
+            END {
+                if (fv == "yes") print (fs == "apfs" ? "apfs" : "corestorage")
+                else if (cs) print "corestorage"
+                else if (fv == "no" || (fs != "apfs" && fs != "hfs")) print "none"
+                else print "-"
+            }')"
+        removable=false
+        printf '%s\n' "$info" | awk -F': *' '
+            $1 ~ /^ *Removable Media$/ && $2 ~ /^Removable/ { found = 1 }
+            $1 ~ /^ *Device Location$/ && $2 ~ /^External/ { found = 1 }
+            END { exit found ? 0 : 1 }' && removable=true
+        printf '%s\t%s\t%s\t%s\t%s\n' "$target" "$device" "$fstype" "$enc" "$removable"
+    done < <(soft_out_probe "${probe_prefix}.mount" mount | sed -n 's#^\(/dev/disk[^ ]*\) on \(.*\) (\([^,)]*\)[,)].*#\1\t\2\t\3#p')
+}
+
+# usb_class_names reads "vendor_id\tproduct_id\tvendor\tproduct\tserial\tcodes"
+# lines, codes being the space-separated hex USB class codes of a device and
+# its interfaces, and prints them with codes replaced by the comma-separated,
+# sorted class names, or "-" when none is known. Class 00 means "see the
+# interfaces" and is dropped.
+usb_class_names() {
+    awk -F'\t' -v OFS='\t' '
+        BEGIN {
+            split("01 audio 02 communications 03 hid 05 physical 06 image 07 printer 08 mass_storage 09 hub 0a cdc_data 0b smart_card 0d content_security 0e video 0f healthcare 10 audio_video 11 billboard 12 type_c_bridge dc diagnostic e0 wireless ef miscellaneous fe application_specific ff vendor_specific", t, " ")
+            for (i = 1; i in t; i += 2) name[t[i]] = t[i + 1]
+        }
+        {
+            n = split(tolower($6), codes, " ")
+            delete seen
+            list = ""
+            for (i = 1; i <= n; i++) {
+                c = codes[i]
+                if (c == "00" || c == "" || (c in seen)) continue
+                seen[c] = 1
+                cls = (c in name) ? name[c] : "class_" c
+                # Insertion sort keeps the list stable between runs.
+                m = split(list, cur, ",")
+                list = ""
+                placed = 0
+                for (j = 1; j <= m; j++) {
+                    if (!placed && cls < cur[j]) { list = list (list == "" ? "" : ",") cls; placed = 1 }
+                    list = list (list == "" ? "" : ",") cur[j]
+                }
+                if (!placed) list = list (list == "" ? "" : ",") cls
+            }
+            $6 = (list == "" ? "-" : list)
+            print
+        }'
+}
+
+# mac_usb_devices <probe prefix> prints "vendor_id\tproduct_id\tvendor\tproduct\tserial\tclasses"
+# for each attached USB device, sorted, from the IOUSB registry plane; the
+# interface classes come from the IOUSBHostInterface entries at the same
+# locationID. vendor_id and product_id are 4-digit hex; classes are
+# usb_class_names'. Unknown fields are "-".
+mac_usb_devices() {
+    local probe_prefix="${1:-security}"
+    command -v ioreg >/dev/null 2>&1 || return 0
+    {
+        soft_out_probe "${probe_prefix}.ioreg_usb_interfaces" ioreg -r -c IOUSBHostInterface -l -w0 | sed 's/^/I /'
+        soft_out_probe "${probe_prefix}.ioreg_usb" ioreg -p IOUSB -l -w0 | sed 's/^/D /'
+    } | awk '
+        function value(line) {
+            sub(/^[^=]*= */, "", line)
+            gsub(/^"|"$/, "", line)
+            gsub(/\t/, " ", line)
+            return line
+        }
+        function hex(n, width) { return (n == "" ? "-" : sprintf("%0" width "x", n + 0)) }
+        function flush() {
+            if (cls == "IOUSBHostInterface" && loc != "" && iclass != "") {
+                codes[loc] = codes[loc] " " hex(iclass, 2)
+            } else if ((cls == "IOUSBHostDevice" || cls == "IOUSBDevice") && vid != "") {
+                n++
+                dloc[n] = loc
+                dline[n] = hex(vid, 4) "\t" hex(pid, 4) "\t" (vname == "" ? "-" : vname) "\t" (pname == "" ? "-" : pname) "\t" (serial == "" ? "-" : serial) "\t" (dclass == "" ? "" : hex(dclass, 2))
+            }
+            cls = loc = iclass = vid = pid = vname = pname = serial = dclass = ""
+        }
+        { $0 = substr($0, 3) }
+        /\+-o / {
+            flush()
+            if (match($0, /<class [A-Za-z0-9_]+/)) cls = substr($0, RSTART + 7, RLENGTH - 7)
+            next
+        }
+        /"locationID" = / { loc = value($0) }
+        /"bInterfaceClass" = / { iclass = value($0) }
+        /"bDeviceClass" = / { dclass = value($0) }
+        /"idVendor" = / { vid = value($0) }
+        /"idProduct" = / { pid = value($0) }
+        /"USB Vendor Name" = / { vname = value($0) }
+        /"USB Product Name" = / { pname = value($0) }
+        /"USB Serial Number" = / { serial = value($0) }
+        END {
+            flush()
+            for (i = 1; i <= n; i++) print dline[i] codes[dloc[i]]
+        }' | usb_class_names | sort
+}
+
+# proxy_settings <probe prefix> prints "source\tscheme\tvalue" for each proxy
+# the audited session uses: the *_proxy environment variables (source
+# environment; no_proxy is scheme "no") and the system proxies of the
+# current network location from `scutil --proxy` (source system; scheme pac
+# for an autoconfig URL, wpad for auto-discovery). Credentials in proxy URLs
+# are dropped.
+proxy_settings() {
+    local prefix="${1:-network}" name value
+    {
+        for name in http_proxy https_proxy ftp_proxy all_proxy no_proxy; do
+            value="$(printenv "$name" 2>/dev/null || printenv "$(printf '%s' "$name" | tr '[:lower:]' '[:upper:]')" 2>/dev/null || true)"
+            [ -n "$value" ] || continue
+            printf 'environment\t%s\t%s\n' "${name%_proxy}" "$value"
+        done
+        soft_out_probe "${prefix}.scutil_proxy" scutil --proxy | awk -F' : ' '
+            { gsub(/^ +| +$/, "", $1); v[$1] = $2 }
+            END {
+                n = split("HTTP HTTPS FTP SOCKS RTSP Gopher", s, " ")
+                for (i = 1; i <= n; i++) if (v[s[i] "Enable"] == "1" && v[s[i] "Proxy"] != "") print "system\t" tolower(s[i]) "\t" v[s[i] "Proxy"] ":" v[s[i] "Port"]
+                if (v["ProxyAutoConfigEnable"] == "1") print "system\tpac\t" v["ProxyAutoConfigURLString"]
+                if (v["ProxyAutoDiscoveryEnable"] == "1") print "system\twpad\tauto"
+            }'
+    } | sed 's#://[^/@]*@#://#'
+}
+
+# smartctl_summary reads `smartctl -i -H -A` output on stdin and prints
+# "model\thealth\treallocated\tpending\twear\tfailing". health is passed or
+# failed; reallocated and pending are the raw sector counts (ATA attributes 5
+# and 197, or SCSI's grown defect list); wear is the percentage of rated life
+# used; failing lists the attributes at or past their failure threshold now,
+# comma-separated. Unknown fields are "-".
+smartctl_summary() {
+    awk -F': *' '
+        function add(v) { failing = failing (failing == "" ? "" : ",") v }
+        function set_wear(used) { if (used < 0) used = 0; if (used > 100) used = 100; wear = used }
+        $1 ~ /^(Device Model|Model Number|Product)$/ && model == "" { model = $2 }
+        $1 ~ /overall-health self-assessment test result$/ { health = ($2 ~ /^PASSED/) ? "passed" : "failed" }
+        $1 == "SMART Health Status" { health = ($2 ~ /^OK/) ? "passed" : "failed" }
+        $1 == "Percentage Used" { v = $2; sub(/%.*/, "", v); set_wear(v + 0) }
+        $1 == "Critical Warning" && $2 !~ /^0x00/ { add("critical_warning") }
+        $1 == "Elements in grown defect list" { reallocated = $2 + 0 }
+        {
+            split($0, f, " ")
+            if (f[1] !~ /^[0-9]+$/ || f[10] == "") next
+            raw = f[10]; sub(/[^0-9].*/, "", raw)
+            if (f[1] == 5) reallocated = raw + 0
+            if (f[1] == 197) pending = raw + 0
+            if (f[1] == 177 || f[1] == 202 || f[1] == 231 || f[1] == 233) set_wear(100 - f[4])
+            if (f[9] == "FAILING_NOW") add(f[2])
+        }
+        END {
+            printf "%s\t%s\t%s\t%s\t%s\t%s\n", (model == "" ? "-" : model), (health == "" ? "-" : health), (reallocated == "" ? "-" : reallocated), (pending == "" ? "-" : pending), (wear == "" ? "-" : wear), (failing == "" ? "-" : failing)
+        }'
+}
+
+# mac_disk_health <probe prefix> prints "device\tprotocol\tmodel\thealth\treallocated\tpending\twear\tfailing"
+# for every physical disk in diskutil, with the fields of smartctl_summary.
+# health comes from diskutil's SMART Status; the counters and failing
+# attributes need smartctl (smartmontools), and are "-" without it.
+mac_disk_health() {
+    local probe_prefix="${1:-storage}"
+    local device info protocol model health out code
+    local s_model s_health s_realloc s_pending s_wear s_failing
+    while read -r device; do
+        [ -n "$device" ] || continue
+        info="$(soft_out_probe "${probe_prefix}.diskutil_info" diskutil info "$device")"
+        protocol="$(printf '%s\n' "$info" | awk -F': *' '$1 ~ /^ *Protocol$/ { print $2; exit }')"
+        model="$(printf '%s\n' "$info" | awk -F': *' '$1 ~ /^ *Device \/ Media Name$/ { print $2; exit }')"
+        health="$(printf '%s\n' "$info" | awk -F': *' '
+            $1 ~ /^ *SMART Status$/ { print ($2 ~ /^Verified/) ? "passed" : ($2 ~ /^Failing/) ? "failed" : "-"; exit }')"
+        out=""
+        if command -v smartctl >/dev/null 2>&1; then
+            # smartctl's exit status is a bit mask: bits 0 and 1 mean the
+            # device could not be read, the others report what the disk says.
+            code=0
+            out="$(smartctl -i -H -A "$device" 2>/dev/null)" || code=$?
+            if (( code & 3 )); then
+                record_soft_failure "soft_out_probe:${probe_prefix}.smartctl:smartctl $device"
+                emit_probe_failed "${probe_prefix}.smartctl" "$code" smartctl
+                out=""
+            fi
+        fi
+        IFS=$'\t' read -r s_model s_health s_realloc s_pending s_wear s_failing < <(printf '%s\n' "$out" | smartctl_summary)
+        if [ "$s_model" = "-" ]; then s_model="${model:--}"; fi
+        if [ "$s_health" = "-" ]; then s_health="${health:--}"; fi
+        printf '%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n' "$device" "${protocol:--}" "$s_model" "$s_health" "$s_realloc" "$s_pending" "$s_wear" "$s_failing"
+    done < <(soft_out_probe "${probe_prefix}.diskutil_list" diskutil list physical | awk '/^\/dev\/disk[0-9]+ / { print $1 }')
+}
+
+# mac_dns_resolvers <probe prefix> prints "scope\tinterface\tdomain\tservers\tsearch"
+# for each resolver in scutil --dns that has nameservers: the default resolver
+# (global), resolvers for one domain such as a VPN's split DNS (domain), and
+# the per-interface resolvers used for scoped queries (interface). servers and
+# search are space-separated; empty fields are "-".
+mac_dns_resolvers() {
+    local probe_prefix="${1:-network}"
+    soft_out_probe "${probe_prefix}.scutil_dns" scutil --dns | awk '
+        function add(list, v) { return list (list == "" ? "" : " ") v }
+        function flush(   scope, key) {
+            if (servers != "") {
+                scope = scoped ? "interface" : (domain != "" ? "domain" : "global")
+                if (!scoped) iface = ""
+                key = scope SUBSEP iface SUBSEP domain
+                if (!seen[key]++) {
+                    printf "%s\t%s\t%s\t%s\t%s\n", scope, (iface == "" ? "-" : iface), (domain == "" ? "-" : domain), servers, (search == "" ? "-" : search)
+                }
+            }
+            servers = ""; search = ""; domain = ""; iface = ""
+        }
+        /^DNS configuration/ { flush(); inres = 0; scoped = ($0 ~ /scoped/); next }
+        /^resolver #/ { flush(); inres = 1; next }
+        !inres { next }
+        $1 ~ /^nameserver\[/ { servers = add(servers, $3) }
+        $1 == "search" && $2 ~ /^domain\[/ { search = add(search, $4) }
+        $1 == "domain" && $2 == ":" { domain = $3 }
+        $1 == "if_index" { iface = $4; gsub(/[()]/, "", iface) }
+        END { flush() }
+    '
+}
+
+# Prints the SHA-256 of a file, or nothing when it cannot be read.
+sha256_file() {
+    local path="$1"
+    [ -r "$path" ] || return 0
+    shasum -a 256 -- "$path" 2>/dev/null | awk '{print $1}'
+}
+
+# sha256_text <text> prints the SHA-256 of text.
+sha256_text() {
+    printf '%s' "$1" | shasum -a 256 | awk '{print $1}'
+}
+
+# WELL_KNOWN_DOMAINS are domains whose resolution an attacker gains from
+# redirecting (vendor update and software distribution servers, identity
+# providers); hosts_entries flags entries for them and their subdomains.
+WELL_KNOWN_DOMAINS="apple.com icloud.com mzstatic.com apple-cloudkit.com microsoft.com microsoftonline.com windowsupdate.com live.com office.com google.com googleapis.com gstatic.com gvt1.com github.com githubusercontent.com okta.com auth0.com onelogin.com duosecurity.com ubuntu.com canonical.com snapcraft.io debian.org fedoraproject.org redhat.com centos.org archlinux.org docker.com docker.io npmjs.org pypi.org mozilla.org"
+
+# hosts_entries <file> prints "ip\thostname\toverride" for each hostname the
+# hosts file maps, one line per address and name, lowercased. override is true
+# when the name is one of WELL_KNOWN_DOMAINS or under one, whatever address it
+# maps to: pointing an update server at 0.0.0.0 blocks updates as surely as
+# pointing it elsewhere hijacks them. Comments are ignored.
+hosts_entries() {
+    local file="${1:-/etc/hosts}"
+    [ -r "$file" ] || return 0
+    awk -v domains="$WELL_KNOWN_DOMAINS" '
+        BEGIN { n = split(domains, d, " "); for (i = 1; i <= n; i++) known[d[i]] = 1 }
+        function is_known(h,    s) {
+            for (s = h; s != ""; ) {
+                if (s in known) return 1
+                if (index(s, ".") == 0) return 0
+                s = substr(s, index(s, ".") + 1)
+            }
+            return 0
+        }
+        {
+            sub(/#.*/, "")
+            if (NF < 2) next
+            for (i = 2; i <= NF; i++) {
+                h = tolower($i); sub(/\.$/, "", h)
+                printf "%s\t%s\t%s\n", tolower($1), h, (is_known(h) ? "true" : "false")
+            }
+        }' "$file"
+}
+
+# shell_startup_files prints "scope\tpath" for each shell startup file that
+# exists: the system-wide profiles and rc files, the scripts in
+# /etc/profile.d (scope system), and the audited user's dotfiles (scope user).
+shell_startup_files() {
+    local f
+    for f in /etc/profile /etc/bashrc /etc/bash.bashrc /etc/zshenv /etc/zprofile /etc/zshrc /etc/zlogin /etc/zsh/zshenv /etc/zsh/zprofile /etc/zsh/zshrc /etc/zsh/zlogin /etc/profile.d/*.sh /etc/profile.d/*.zsh; do
+        [ -f "$f" ] && printf 'system\t%s\n' "$f"
+    done
+    for f in .profile .bash_profile .bash_login .bashrc .bash_logout .zshenv .zprofile .zshrc .zlogin .zlogout; do
+        [ -f "$HOME_DIR/$f" ] && printf 'user\t%s\n' "$HOME_DIR/$f"
+    done
+    return 0
+}
+
+# shell_startup_findings <file> prints "line\tpattern" for each line of a
+# shell startup file that matches a pattern malware uses to persist:
+#   download_exec        a download piped into a shell or interpreter (curl ... | bash)
+#   remote_eval          downloaded content run through eval, source, ., or sh -c
+#   path_prepend_unsafe  PATH prepended with a temporary or relative directory
+#   path_prepend         PATH prepended with any other directory
+# Only path_prepend is routine (~/.local/bin, Homebrew, pyenv); the others are
+# suspicious. Comment lines are ignored.
+shell_startup_findings() {
+    local file="$1"
+    [ -r "$file" ] || return 0
+    awk '
+        /^[ \t]*#/ { next }
+        /(curl|wget|fetch)[^|]*\|[ \t]*(sudo[ \t]+)?(env[ \t]+)?((ba|da|k|z)?sh|python[0-9.]*|perl|ruby|node)([ \t;&|)]|$)/ {
+            print NR "\tdownload_exec"; next
+        }
+        /(^|[ \t;&|({])(eval|source|\.|(ba|da|k|z)?sh[ \t]+-c)[ \t]+["'"'"']?(\$\(|`|<\()[^)`]*(curl|wget|fetch)/ {
+            print NR "\tremote_eval"; next
+        }
+        /(^|[ \t;&])(export[ \t]+)?PATH=/ {
+            v = $0
+            sub(/.*PATH=/, "", v)
+            gsub(/["'"'"']/, "", v)
+            if (v ~ /^\$(PATH|\{PATH\})/ || v !~ /\$(PATH|\{PATH\})/) next
+            dir = v
+            sub(/:.*/, "", dir)
+            if (dir ~ /^(\/tmp|\/var\/tmp|\/dev\/shm|\/private\/tmp|\/private\/var\/tmp)(\/|$)/ || dir !~ /^(\/|~|\$)/)
+                print NR "\tpath_prepend_unsafe"
+            else
+                print NR "\tpath_prepend"
+        }' "$file"
+}
+
+# watched_dotfiles prints "path\tmode\tsha256" for each file the
+# OSAUDIT_DOTFILES lines name (see internal/dotfiles), with ~/ under $HOME_DIR
+# and globs expanded. mode is octal and includes the setuid, setgid, and
+# sticky bits. A line that names no file prints with mode "-", and an
+# unreadable file with sha256 "-".
+watched_dotfiles() {
+    local entry pattern f found mode sha
+    while IFS= read -r entry; do
+        case "$entry" in ""|\#*) continue ;; esac
+        case "$entry" in "~/"*) pattern="$HOME_DIR/${entry#\~/}" ;; *) pattern="$entry" ;; esac
+        found=false
+        while IFS= read -r f; do
+            [ -f "$f" ] || continue
+            found=true
+            mode="$(stat -L -f %Mp%Lp "$f" 2>/dev/null || echo -)"
+            mode="${mode#0}"
+            sha="$(sha256_file "$f")"
+            printf '%s\t%s\t%s\n' "$f" "$mode" "${sha:--}"
+        done < <(compgen -G "$pattern" | sort || true)
+        $found || printf '%s\t-\t-\n' "$pattern"
+    done <<< "${OSAUDIT_DOTFILES:-}" | awk -F'\t' '!seen[$1]++'
+}
+
+# recent_logins <probe prefix> [count] prints "user\ttty\thost\tlogin\tactive"
+# for the most recent logins in the login records, newest first (default 25).
+# login is as last prints it ("Mon Oct 13 09:12"; last shows no year) and
+# active is true for a session still logged in. Reboots and shutdowns are
+# left out. An empty host is "-".
+recent_logins() {
+    local probe_prefix="${1:-identity}" count="${2:-25}"
+    command -v last >/dev/null 2>&1 || return 0
+    soft_out_probe "$probe_prefix.last" last -"$count" | awk '
+        NF < 4 || $1 == "reboot" || $1 == "shutdown" || $1 == "wtmp" { next }
+        {
+            t = 0
+            for (i = 3; i <= NF - 3; i++) if ($i ~ /^(Mon|Tue|Wed|Thu|Fri|Sat|Sun)$/) { t = i; break }
+            if (!t) next
+            host = (t > 3 ? $3 : "-")
+            printf "%s\t%s\t%s\t%s %s %s %s\t%s\n", $1, $2, host, $t, $(t + 1), $(t + 2), $(t + 3), ($0 ~ /still logged in/ ? "true" : "false")
+        }'
+}
+
+# recent_changes <hours> prints "epoch\tmodified\tpath" for each file modified
+# in the last <hours> hours where intruders drop and persist things:
+# /private/etc, /usr/local, the launch agent and daemon folders, the temporary
+# directories, cron tabs, and each user's launch agents, SSH and local bin
+# directories, and shell startup files. It stays on each directory's
+# filesystem, three levels deep, and skips the output directory. modified is
+# ISO-8601; newest first.
+recent_changes() {
+    local minutes=$(( ${1:-24} * 60 )) user home f
+    local roots=()
+    for f in /private/etc /usr/local/bin /usr/local/sbin /Library/LaunchAgents /Library/LaunchDaemons /Library/StartupItems /private/tmp /private/var/tmp /private/var/at/tabs; do
+        [ -e "$f" ] && roots+=("$f")
+    done
+    while IFS=$'\t' read -r user home; do
+        for f in Library/LaunchAgents .ssh .local/bin .profile .bash_profile .bashrc .zshenv .zprofile .zshrc .zlogin; do
+            [ -e "$home/$f" ] && roots+=("$home/$f")
+        done
+    done < <(user_homes persistence)
+    (( ${#roots[@]} > 0 )) || return 0
+    find "${roots[@]}" -xdev -maxdepth 3 -type f -mmin "-$minutes" -exec stat -f '%m%t%Sm%t%N' -t '%Y-%m-%dT%H:%M:%S%z' {} + 2>/dev/null \
+        | awk -F'\t' -v skip="${AUDIT_OUTPUT_ROOT:-}" 'skip == "" || index($3, skip) != 1' \
+        | sort -t "$(printf '\t')" -k1,1nr || true
+}
+
+# mac_vpn_connections <probe prefix> prints
+# "kind\tname\ttype\tinterface\tstate\troutes" for each VPN: the services
+# scutil --nc lists, built-in IPsec/L2TP/IKEv2 and VPN apps' network
+# extensions alike (kind service), then tunnel interfaces, utun, ipsec, and
+# ppp, that carry an address (kind tunnel). macOS keeps several utun
+# interfaces with only a link-local address for its own use; those are
+# skipped. state is connected or disconnected. routes lists the
+# destinations routed through a tunnel. Empty fields are "-".
+mac_vpn_connections() {
+    local probe_prefix="${1:-network}"
+    local name type state iface info routes
+    # * (Connected)  <UUID> VPN (com.wireguard.macos) "Home" [VPN:com.wireguard.macos]
+    while IFS=$'\t' read -r name type state; do
+        [ -n "$name" ] || continue
+        iface="-"
+        if [ "$state" = "connected" ]; then
+            iface="$(soft_out_probe "${probe_prefix}.scutil_nc_status" scutil --nc status "$name" | awk '$1 == "InterfaceName" { print $3; exit }')"
+        fi
+        printf 'service\t%s\t%s\t%s\t%s\t-\n' "$name" "$type" "${iface:--}" "$state"
+    done < <(soft_out_probe "${probe_prefix}.scutil_nc_list" scutil --nc list | awk '
+        /^\*/ || /^ / {
+            q1 = index($0, "\""); rest = substr($0, q1 + 1); q2 = index(rest, "\"")
+            if (q1 == 0 || q2 == 0) next
+            name = substr(rest, 1, q2 - 1)
+            state = $0; sub(/^[^(]*\(/, "", state); sub(/\).*/, "", state)
+            type = $NF; gsub(/[][]/, "", type); sub(/^[^:]*:/, "", type)
+            printf "%s\t%s\t%s\n", name, type, (state == "Connected" ? "connected" : "disconnected")
+        }
+    ' || true)
+    local inet inet6
+    inet="$(soft_out_probe "${probe_prefix}.netstat_routes" netstat -rn -f inet)"
+    inet6="$(soft_out_probe "${probe_prefix}.netstat_routes_inet6" netstat -rn -f inet6)"
+    for iface in $(soft_out_probe "${probe_prefix}.ifconfig_list" ifconfig -l); do
+        case "$iface" in
+            utun*) type="utun" ;;
+            ipsec*) type="ipsec" ;;
+            ppp*) type="ppp" ;;
+            *) continue ;;
+        esac
+        info="$(soft_out_probe "${probe_prefix}.ifconfig_iface" ifconfig "$iface")"
+        printf '%s\n' "$info" | awk '$1 == "inet" || ($1 == "inet6" && $2 !~ /^fe80:/) { found = 1 } END { exit found ? 0 : 1 }' || continue
+        state="disconnected"
+        if printf '%s\n' "$info" | awk 'NR == 1 && /<UP,/ && /RUNNING/ { found = 1 } END { exit found ? 0 : 1 }'; then
+            state="connected"
+        fi
+        # The Netif column moved between releases; find it by its header.
+        routes="$(printf '%s\n%s\n' "$inet" "$inet6" | awk -v ifc="$iface" '
+            $1 == "Destination" { for (i = 1; i <= NF; i++) if ($i == "Netif") col = i; next }
+            col && $col == ifc && $1 !~ /^(fe80:|ff[0-9a-f][0-9a-f]:)/ { d = $1; sub(/%.*/, "", d); print d }
+        ' | sort -u | tr '\n' ' ')"
+        routes="${routes% }"
+        printf 'tunnel\t%s\t%s\t%s\t%s\t%s\n' "$iface" "$type" "$iface" "$state" "${routes:--}"
+    done
+}
+
+# mac_neighbors <probe prefix> prints "family\tip\tmac\tinterface\tstate\tgateway"
+# for each resolved entry of the ARP (ipv4, from arp -an) and neighbor
+# discovery (ipv6, from ndp -an) caches. MAC addresses are written with two
+# digits per octet, which arp leaves out. state is permanent or dynamic for
+# ARP entries and the ndp state (reachable, stale, delay, probe, ...) for
+# IPv6 ones. gateway is true for the default route's next hop on that
+# interface. Broadcast and multicast entries are skipped.
+mac_neighbors() {
+    local probe_prefix="${1:-network}" gateways
+    # route exits 1 when there is no default route, which is not a failure.
+    gateways="$({
+        route -n get default 2>/dev/null || true
+        route -n get -inet6 default 2>/dev/null || true
+    } | awk '$1 == "gateway:" { gw = $2; sub(/%.*/, "", gw) } $1 == "interface:" && gw != "" { printf "%s%%%s ", gw, $2; gw = "" }')"
+    {
+        soft_out_probe "${probe_prefix}.arp" arp -an | awk '{ print "arp\t" $0 }'
+        soft_out_probe "${probe_prefix}.ndp" ndp -an | awk 'NR > 1 { print "ndp\t" $0 }'
+    } | awk -F'\t' -v gws="$gateways" '
+        BEGIN {
+            n = split(gws, g, " "); for (i = 1; i <= n; i++) isgw[g[i]] = 1
+            split("R reachable S stale D delay P probe N nostate I incomplete W waitdelete", st, " ")
+            for (i = 1; i < 12; i += 2) ndpstate[st[i]] = st[i + 1]
+        }
+        function normmac(m,    o, k, out) {
+            k = split(tolower(m), o, ":"); out = ""
+            if (k != 6) return ""
+            for (i = 1; i <= 6; i++) out = out (i > 1 ? ":" : "") (length(o[i]) == 1 ? "0" : "") o[i]
+            return out
+        }
+        function emit(family, ip, mac, dev, state) {
+            mac = normmac(mac)
+            if (mac == "" || mac == "ff:ff:ff:ff:ff:ff" || mac ~ /^01:00:5e:/ || mac ~ /^33:33:/) return
+            print family "\t" ip "\t" mac "\t" dev "\t" state "\t" (((ip "%" dev) in isgw) ? "true" : "false")
+        }
+        $1 == "arp" {
+            # ? (192.168.1.1) at 0:1b:2c:3d:4e:5f on en0 ifscope [ethernet]
+            split($2, f, " ")
+            ip = f[2]; gsub(/[()]/, "", ip)
+            state = ($2 ~ / permanent /) ? "permanent" : "dynamic"
+            emit("ipv4", ip, f[4], f[6], state)
+        }
+        $1 == "ndp" {
+            # fe80::1%en0  0:1b:2c:3d:4e:5f  en0  23h59m58s  S  R
+            split($2, f, " ")
+            ip = f[1]; sub(/%.*/, "", ip)
+            state = (f[5] in ndpstate) ? ndpstate[f[5]] : tolower(f[5])
+            emit("ipv6", ip, f[2], f[3], state)
+        }'
+}
+
+# mac_routes <probe prefix> prints "family\tdestination\tgateway\tinterface\tmetric\tprotocol\ttype"
+# for each route of netstat -rn, in the same form as linux_routes. netstat's
+# shortened destinations are written as prefixes (default is 0.0.0.0/0 or
+# ::/0, 192.168.1 is 192.168.1.0/24, a host is /32 or /128) and scope zones
+# are dropped. Cloned (W) and link-layer (L) routes are the ARP and neighbor
+# caches and are skipped. Directly connected routes have gateway "-"; type is
+# blackhole (B), unreachable (R), or unicast. macOS has no route metrics or
+# protocols, so metric is 0 and protocol is "-".
+mac_routes() {
+    local probe_prefix="${1:-network}"
+    {
+        soft_out_probe "${probe_prefix}.netstat_routes" netstat -rn -f inet | awk '{ print "ipv4 " $0 }'
+        soft_out_probe "${probe_prefix}.netstat_routes_inet6" netstat -rn -f inet6 | awk '{ print "ipv6 " $0 }'
+    } | awk '
+        $2 == "Destination" { for (i = 2; i <= NF; i++) if ($i == "Netif") netif[$1] = i; next }
+        !($1 in netif) || NF < netif[$1] || $4 ~ /[WL]/ { next }
+        {
+            family = $1; dest = $2; gw = $3
+            sub(/%[^\/]*/, "", dest); sub(/%.*/, "", gw)
+            if (dest == "default") dest = (family == "ipv4") ? "0.0.0.0/0" : "::/0"
+            else if (family == "ipv6") { if (dest !~ /\//) dest = dest "/128" }
+            else {
+                plen = ""
+                if (split(dest, p, "/") == 2) { dest = p[1]; plen = p[2] }
+                n = split(dest, o, ".")
+                if (plen == "") plen = (n == 4) ? 32 : n * 8
+                for (; n < 4; n++) dest = dest ".0"
+                dest = dest "/" plen
+            }
+            if (gw ~ /^link#/) gw = "-"
+            type = ($4 ~ /B/) ? "blackhole" : ($4 ~ /R/) ? "unreachable" : "unicast"
+            printf "%s\t%s\t%s\t%s\t0\t-\t%s\n", family, dest, gw, $(netif[family]), type
+        }'
+}
+
+# _AWK_EPOCH defines epoch(y, m, d, hh, mi, ss), the seconds since 1970 of
+# a UTC date, for awk programs; date(1) parses dates differently on GNU and BSD.
+# shellcheck disable=SC2016
+_AWK_EPOCH='
+    function epoch(y, m, d, hh, mi, ss,   a, yy, mm) {
+        a = int((14 - m) / 12); yy = y + 4800 - a; mm = m + 12 * a - 3
+        return (d + int((153 * mm + 2) / 5) + 365 * yy + int(yy / 4) - int(yy / 100) + int(yy / 400) - 32045 - 2440588) * 86400 + hh * 3600 + mi * 60 + ss
+    }'
+
+# _pem_certificate_details <store> <user_added> reads PEM certificates on
+# stdin and prints, for each, a "@cert<TAB>store<TAB>user_added" line followed
+# by openssl's subject, issuer, notAfter, and SHA-256 fingerprint lines, which
+# certificate_lines parses.
+_pem_certificate_details() {
+    local store="$1" user_added="$2" line pem=""
+    while IFS= read -r line; do
+        pem="${pem}${line}"$'\n'
+        if [[ "$line" == "-----END CERTIFICATE-----" ]]; then
+            printf '@cert\t%s\t%s\n' "$store" "$user_added"
+            printf '%s' "$pem" | openssl x509 -noout -subject -issuer -enddate -fingerprint -sha256 -nameopt RFC2253,-esc_msb 2>/dev/null
+            pem=""
+        fi
+    done
+}
+
+# Turns _pem_certificate_details output into one
+# "store<TAB>subject<TAB>issuer<TAB>not_after<TAB>days_left<TAB>sha256<TAB>root<TAB>user_added"
+# line per certificate; the first store listing a fingerprint wins. not_after
+# is ISO-8601 UTC, days_left is negative once a certificate has expired, and
+# root is true for self-issued certificates.
+certificate_lines() {
+    awk -F'\t' -v now="$(date -u +%s)" "$_AWK_EPOCH"'
+        function flush() {
+            if (fp != "" && !(fp in seen)) {
+                seen[fp] = 1
+                print store "\t" subject "\t" issuer "\t" not_after "\t" days "\t" fp "\t" (subject == issuer ? "true" : "false") "\t" user_added
+            }
+            fp = ""; subject = ""; issuer = ""; not_after = ""; days = 0
+        }
+        $1 == "@cert" { flush(); store = $2; user_added = $3; next }
+        /^subject=/ { subject = substr($0, 9); sub(/^ +/, "", subject); next }
+        /^issuer=/ { issuer = substr($0, 8); sub(/^ +/, "", issuer); next }
+        /^notAfter=/ {
+            # e.g. "notAfter=Jan  1 00:00:00 2030 GMT"
+            split(substr($0, 10), t, /[ :]+/)
+            m = (index("JanFebMarAprMayJunJulAugSepOctNovDec", t[1]) + 2) / 3
+            not_after = sprintf("%04d-%02d-%02dT%02d:%02d:%02dZ", t[6], m, t[2], t[3], t[4], t[5])
+            d = (epoch(t[6], m, t[2], t[3], t[4], t[5]) - now) / 86400
+            days = (d < 0 && d != int(d)) ? int(d) - 1 : int(d)
+            next
+        }
+        /[Ff]ingerprint=/ { fp = tolower(substr($0, index($0, "=") + 1)); gsub(/:/, "", fp); next }
+        END { flush() }
+    '
+}
+
+# certificate_items reads certificate_lines output and prints the JSON items
+# of the certificates row, comma-separated.
+certificate_items() {
+    awk -F'\t' "$_AWK_JSON_ESC"'
+        {
+            printf "%s{\"store\":\"%s\",\"subject\":\"%s\",\"issuer\":\"%s\",\"not_after\":\"%s\",\"days_left\":%d,\"sha256\":\"%s\",\"root\":%s,\"user_added\":%s}", \
+                (n++ ? "," : ""), json_esc($1), json_esc($2), json_esc($3), $4, $5, $6, $7, $8
+        }'
+}
+
 dir_bytes() {
     local path="$1"
     local kib=0
@@ -128,7 +2272,7 @@ get_audit_path_for_output() {
 redact_path_for_ndjson() {
     local input_path="$1"
     if ! _common_is_true "$REDACT_PATHS"; then
-        echo "$input_path"
+        printf '%s\n' "$input_path"
         return
     fi
 
@@ -137,13 +2281,13 @@ redact_path_for_ndjson() {
             echo "~"
             ;;
         "$HOME_DIR"/*)
-            echo "~/${input_path#$HOME_DIR/}"
+            printf '~/%s\n' "${input_path#$HOME_DIR/}"
             ;;
         *)
             if [ -n "$CURRENT_USER" ]; then
-                echo "$input_path" | sed "s#/${CURRENT_USER}/#/<user>/#g; s#/${CURRENT_USER}\$#/<user>#"
+                printf '%s\n' "$input_path" | LC_ALL=C sed "s#/${CURRENT_USER}/#/<user>/#g; s#/${CURRENT_USER}\$#/<user>#"
             else
-                echo "$input_path"
+                printf '%s\n' "$input_path"
             fi
             ;;
     esac
@@ -236,11 +2380,12 @@ now_ms() {
 }
 
 emit_timing() {
-    [ -n "$NDJSON_FILE" ] || return 0
     local section="$1"
     local start_ms="$2"
     local end_ms="$3"
     local elapsed_ms=$((end_ms - start_ms))
+    emit_event section_finished section "$section" elapsed_ms "$elapsed_ms"
+    [ -n "$NDJSON_FILE" ] || return 0
     append_ndjson_line "{\"type\":\"timing\",\"run_id\":$(json_escape "$RUN_ID"),\"section\":$(json_escape "$section"),\"elapsed_ms\":$elapsed_ms}"
 }
 
@@ -264,11 +2409,12 @@ record_soft_failure() {
 # message: optional 5th arg; first line of stderr (when AUDIT_CAPTURE_STDERR). Truncated to 200 chars.
 # For soft/soft_out pass argv0 (basename) to avoid cardinality explosion from variable args.
 emit_probe_failed() {
-    [ -n "$NDJSON_FILE" ] || return 0
     local probe="$1"
     local code="${2:-1}"
     # Never record success as failure; exit 0 means probe succeeded.
     [ "$code" -ne 0 ] 2>/dev/null || return 0
+    emit_event probe_finished probe "$probe" exit_code "$code"
+    [ -n "$NDJSON_FILE" ] || return 0
     local argv0="${3:-}"
     local count_key="${4:-$probe}"
     local message="${5:-}"
@@ -378,6 +2524,7 @@ soft_probe() {
         _common_register_tmp "$stderr_tmp"
         if "$@" 2>"${stderr_tmp:-/dev/null}"; then
             rm -f "$stderr_tmp" 2>/dev/null
+            emit_event probe_finished probe "$probe" exit_code 0
             return 0
         fi
         local code=$?
@@ -394,6 +2541,7 @@ soft_probe() {
         emit_probe_failed "$probe" "$code" "${1:-}"
         return 0
     }
+    emit_event probe_finished probe "$probe" exit_code 0
 }
 
 soft_out_probe() {
@@ -407,6 +2555,7 @@ soft_out_probe() {
         local code=$?
         if [ $code -eq 0 ]; then
             rm -f "$stderr_tmp" 2>/dev/null
+            emit_event probe_finished probe "$probe" exit_code 0
             echo "$out"
             return 0
         fi
@@ -423,6 +2572,7 @@ soft_out_probe() {
         emit_probe_failed "$probe" "$code" "${1:-}"
         return 0
     }
+    emit_event probe_finished probe "$probe" exit_code 0
 }
 
 # Like soft_probe but returns the actual exit code (doesn't swallow on failure).
@@ -435,6 +2585,7 @@ soft_probe_check() {
         _common_register_tmp "$stderr_tmp"
         if "$@" 2>"${stderr_tmp:-/dev/null}"; then
             rm -f "$stderr_tmp" 2>/dev/null
+            emit_event probe_finished probe "$probe" exit_code 0
             return 0
         fi
         local code=$?
@@ -446,6 +2597,7 @@ soft_probe_check() {
         return $code
     fi
     if "$@" 2>/dev/null; then
+        emit_event probe_finished probe "$probe" exit_code 0
         return 0
     else
         local code=$?
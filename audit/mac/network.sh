@@ -18,6 +18,7 @@ Options:
   --redact-paths         Redact NDJSON paths (default: on when --ndjson)
   --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
   --redact-all           Redact all sensitive text (implies --redact-paths)
+  --no-redact            Redact nothing, overriding redact in config.yaml
   --no-color             Disable ANSI colors in terminal output
   -h, --help             Show this help and exit
 EOF
@@ -149,20 +150,211 @@ run_network_audit() {
 
     section_start_ms=$(now_ms)
     section_header "🧭 DNS Configuration"
-    report_append "Configured DNS servers:"
-    report_append ""
-    local dns_count=0
-    while IFS= read -r dns; do
-        [ -n "$dns" ] || continue
-        report_append "- \`$dns\`"
+    report_append "| Scope | Interface | Domain | Servers | Search |"
+    report_append "|-------|-----------|--------|---------|--------|"
+    local dns_items="" dns_count=0 dns_servers="" dns_search=""
+    local scope iface domain servers search v servers_json search_json
+    while IFS=$'\t' read -r scope iface domain servers search; do
+        [ -n "$scope" ] || continue
+        [ "$iface" = "-" ] && iface=""
+        [ "$domain" = "-" ] && domain=""
+        [ "$servers" = "-" ] && servers=""
+        [ "$search" = "-" ] && search=""
+        report_append "| $scope | ${iface:-—} | ${domain:-—} | \`${servers:-none}\` | ${search:-—} |"
+        servers_json=""
+        for v in $servers; do
+            [ -n "$servers_json" ] && servers_json+=","
+            servers_json+="$(json_escape "$v")"
+            case " $dns_servers " in *" $v "*) ;; *) dns_servers="${dns_servers:+$dns_servers }$v" ;; esac
+        done
+        search_json=""
+        for v in $search; do
+            [ -n "$search_json" ] && search_json+=","
+            search_json+="$(json_escape "$v")"
+            case " $dns_search " in *" $v "*) ;; *) dns_search="${dns_search:+$dns_search }$v" ;; esac
+        done
+        [ -n "$dns_items" ] && dns_items+=","
+        dns_items+="{\"scope\":$(json_escape "$scope"),\"interface\":$(json_escape "$iface"),\"domain\":$(json_escape "$domain"),\"servers\":[${servers_json}],\"search\":[${search_json}]}"
         dns_count=$((dns_count + 1))
-    done < <(soft_out_probe "network.scutil_dns" scutil --dns | awk '/nameserver\[[0-9]+\]/ {print $3}' | sort -u || true)
+    done < <(mac_dns_resolvers network || true)
     if (( dns_count == 0 )); then
         report_append "_No DNS servers discovered._"
     fi
+    servers_json=""
+    for v in $dns_servers; do
+        [ -n "$servers_json" ] && servers_json+=","
+        servers_json+="$(json_escape "$v")"
+    done
+    search_json=""
+    for v in $dns_search; do
+        [ -n "$search_json" ] && search_json+=","
+        search_json+="$(json_escape "$v")"
+    done
+    append_ndjson_line "{\"type\":\"dns_resolvers\",\"run_id\":$(json_escape "$RUN_ID"),\"servers\":[${servers_json}],\"search_domains\":[${search_json}],\"items\":[${dns_items}]}"
     section_end_ms=$(now_ms)
     emit_timing "dns_configuration" "$section_start_ms" "$section_end_ms"
 
+    section_start_ms=$(now_ms)
+    section_header "📒 Hosts File"
+    local hosts_path=/etc/hosts hosts_sha hosts_ip hosts_name hosts_override hosts_items="" hosts_count=0
+    local hosts_overrides=0 hosts_override_json="" hosts_name_json
+    hosts_sha="$(sha256_file "$hosts_path")"
+    report_append "- File: \`$hosts_path\` (sha256 \`${hosts_sha:-unreadable}\`)"
+    while IFS=$'\t' read -r hosts_ip hosts_name hosts_override; do
+        [ -n "$hosts_name" ] || continue
+        hosts_count=$((hosts_count + 1))
+        case "$hosts_name" in
+            *[!a-z0-9._-]*) hosts_name_json="$(json_escape "$hosts_name")" ;;
+            *) hosts_name_json="\"$hosts_name\"" ;;
+        esac
+        if [ "$hosts_override" = true ]; then
+            hosts_overrides=$((hosts_overrides + 1))
+            report_append "- ⚠️ \`$hosts_name\` → \`$hosts_ip\` overrides a well-known domain"
+            (( hosts_overrides <= 20 )) && hosts_override_json="${hosts_override_json:+$hosts_override_json,}$hosts_name_json"
+        fi
+        # Blocklists put tens of thousands of names in hosts files; keep the
+        # first 500.
+        (( hosts_count <= 500 )) || continue
+        [ -n "$hosts_items" ] && hosts_items+=","
+        hosts_items+="{\"ip\":$(json_escape "$hosts_ip"),\"hostname\":$hosts_name_json,\"override\":$hosts_override}"
+    done < <(hosts_entries "$hosts_path" || true)
+    report_append "- Entries: **$hosts_count**"
+    report_append "- Entries overriding well-known domains: **$hosts_overrides**"
+    if (( hosts_overrides > 0 )); then
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"hosts_override\",\"count\":$hosts_overrides,\"hostnames\":[${hosts_override_json}]}"
+    fi
+    append_ndjson_line "{\"type\":\"hosts_file\",\"run_id\":$(json_escape "$RUN_ID"),\"path\":\"$hosts_path\",\"sha256\":$(json_escape "$hosts_sha"),\"entries\":$hosts_count,\"overrides\":$hosts_overrides,\"items\":[${hosts_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "hosts_file" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🛡️ VPN & Tunnels"
+    report_append "| Kind | Name | Type | Interface | State | Routes |"
+    report_append "|------|------|------|-----------|-------|--------|"
+    local vpn_items="" vpn_services=0 vpn_tunnels=0 vpn_connected=0 vpn_default_tunneled=false
+    local kind name vpn_type state routes routes_json
+    while IFS=$'\t' read -r kind name vpn_type iface state routes; do
+        [ -n "$kind" ] || continue
+        [ "$iface" = "-" ] && iface=""
+        [ "$routes" = "-" ] && routes=""
+        report_append "| $kind | \`$(md_path "$name")\` | $vpn_type | ${iface:-—} | $state | ${routes:-—} |"
+        routes_json=""
+        for v in $routes; do
+            [ -n "$routes_json" ] && routes_json+=","
+            routes_json+="$(json_escape "$v")"
+            if [ "$kind" = "tunnel" ] && [ "$state" = "connected" ]; then
+                # Full-tunnel VPNs route default, or its two halves so the real default stays.
+                case "$v" in default|0/1|128.0/1|0.0.0.0/1|128.0.0.0/1|::/0|::/1|8000::/1) vpn_default_tunneled=true ;; esac
+            fi
+        done
+        [ -n "$vpn_items" ] && vpn_items+=","
+        vpn_items+="{\"kind\":$(json_escape "$kind"),\"name\":$(json_escape "$name"),\"vpn_type\":$(json_escape "$vpn_type"),\"interface\":$(json_escape "$iface"),\"state\":$(json_escape "$state"),\"routes\":[${routes_json}]}"
+        if [ "$kind" = "service" ]; then
+            vpn_services=$((vpn_services + 1))
+        else
+            vpn_tunnels=$((vpn_tunnels + 1))
+        fi
+        if [ "$state" = "connected" ]; then
+            vpn_connected=$((vpn_connected + 1))
+        fi
+    done < <(mac_vpn_connections network || true)
+    if (( vpn_services + vpn_tunnels == 0 )); then
+        report_append "_No VPN services or tunnel interfaces discovered._"
+    else
+        report_append ""
+        report_append "- Default route tunneled: **${vpn_default_tunneled}**"
+    fi
+    append_ndjson_line "{\"type\":\"vpn_connections\",\"run_id\":$(json_escape "$RUN_ID"),\"services\":${vpn_services},\"tunnels\":${vpn_tunnels},\"connected\":${vpn_connected},\"default_route_tunneled\":${vpn_default_tunneled},\"items\":[${vpn_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "vpn_tunnels" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🛣️ Routing Table"
+    local rt_family rt_dest rt_gw rt_iface rt_metric rt_proto rt_type rt_gw_json rt_iface_json
+    local rt_items="" rt_count=0 rt_defaults=0
+    while IFS=$'\t' read -r rt_family rt_dest rt_gw rt_iface rt_metric rt_proto rt_type; do
+        [ -n "$rt_dest" ] || continue
+        if (( rt_count == 0 )); then
+            report_append "| Destination | Gateway | Interface | Metric | Protocol | Type |"
+            report_append "|-------------|---------|-----------|--------|----------|------|"
+        fi
+        rt_count=$((rt_count + 1))
+        case "$rt_dest" in 0.0.0.0/0|::/0) rt_defaults=$((rt_defaults + 1)) ;; esac
+        (( rt_count <= 200 )) || continue
+        report_append "| $rt_dest | $rt_gw | $rt_iface | $rt_metric | $rt_proto | $rt_type |"
+        # Addresses, metrics, protocols, and types are plain; gateways and
+        # interfaces can be interface names, which need escaping.
+        case "$rt_gw" in
+            *[!A-Za-z0-9.:,_-]*|'') rt_gw_json="$(json_escape "$rt_gw")" ;;
+            *) rt_gw_json="\"$rt_gw\"" ;;
+        esac
+        case "$rt_iface" in
+            *[!A-Za-z0-9.,_-]*|'') rt_iface_json="$(json_escape "$rt_iface")" ;;
+            *) rt_iface_json="\"$rt_iface\"" ;;
+        esac
+        [ -n "$rt_items" ] && rt_items+=","
+        rt_items+="{\"family\":\"$rt_family\",\"destination\":\"$rt_dest\",\"gateway\":$rt_gw_json,\"interface\":$rt_iface_json,\"metric\":${rt_metric:-0},\"protocol\":\"$rt_proto\",\"type\":\"$rt_type\"}"
+    done < <(mac_routes network || true)
+    if (( rt_count == 0 )); then
+        report_append "_No routes discovered (or probe unavailable)._"
+    elif (( rt_count > 200 )); then
+        report_append ""
+        report_append "_Showing the first 200 of $rt_count routes._"
+    fi
+    append_ndjson_line "{\"type\":\"routing_table\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$rt_count,\"default_routes\":$rt_defaults,\"items\":[${rt_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "routing_table" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "📇 Neighbor Table (ARP/ND)"
+    local nb_lines nb_items="" nb_count=0 gw_items="" gw_count=0 nb_shared nb_shared_json="" nb_shared_count=0
+    local ctx_gateway="" ctx_gateway_mac="" ctx_interface=""
+    local nb_family nb_ip nb_mac nb_iface nb_state nb_gateway nb_iface_json
+    nb_lines="$(mac_neighbors network || true)"
+    while IFS=$'\t' read -r nb_family nb_ip nb_mac nb_iface nb_state nb_gateway; do
+        [ -n "$nb_ip" ] || continue
+        if (( nb_count == 0 )); then
+            report_append "| Address | MAC | Interface | State | Gateway |"
+            report_append "|---------|-----|-----------|-------|---------|"
+        fi
+        report_append "| $nb_ip | \`$nb_mac\` | $nb_iface | $nb_state | $([ "$nb_gateway" = true ] && echo "yes" || echo "—") |"
+        # Addresses, MACs, and states are plain; only interface names need escaping.
+        case "$nb_iface" in
+            *[!A-Za-z0-9._-]*|'') nb_iface_json="$(json_escape "$nb_iface")" ;;
+            *) nb_iface_json="\"$nb_iface\"" ;;
+        esac
+        [ -n "$nb_items" ] && nb_items+=","
+        nb_items+="{\"family\":\"$nb_family\",\"ip\":\"$nb_ip\",\"mac\":\"$nb_mac\",\"interface\":$nb_iface_json,\"state\":\"$nb_state\",\"gateway\":$nb_gateway}"
+        nb_count=$((nb_count + 1))
+        if [ "$nb_gateway" = true ]; then
+            [ -n "$gw_items" ] && gw_items+=","
+            gw_items+="{\"interface\":$nb_iface_json,\"ip\":\"$nb_ip\",\"family\":\"$nb_family\",\"mac\":\"$nb_mac\"}"
+            gw_count=$((gw_count + 1))
+            if [ -z "$ctx_gateway" ] && [ "$nb_family" = ipv4 ]; then
+                ctx_gateway="$nb_ip" ctx_gateway_mac="$nb_mac" ctx_interface="$nb_iface"
+            fi
+        fi
+    done <<< "$nb_lines"
+    if (( nb_count == 0 )); then
+        report_append "_No resolved neighbor entries._"
+    fi
+    # A gateway whose MAC another IPv4 neighbor on the segment also answers
+    # with is what ARP spoofing leaves behind.
+    nb_shared="$(printf '%s\n' "$nb_lines" | awk -F'\t' '$1 == "ipv4" { k = $4 "\t" $3; n[k]++; if ($6 == "true") gw[k] = $2 } END { for (k in gw) if (n[k] > 1) print gw[k] }')"
+    for v in $nb_shared; do
+        nb_shared_json="${nb_shared_json:+$nb_shared_json,}\"$v\""
+        nb_shared_count=$((nb_shared_count + 1))
+    done
+    if (( nb_shared_count > 0 )); then
+        report_append ""
+        report_append "- ⚠️ Gateways sharing their MAC address with another neighbor: **$nb_shared_count** — possible ARP spoofing"
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"gateway_mac_shared\",\"count\":$nb_shared_count,\"gateways\":[${nb_shared_json}]}"
+    fi
+    append_ndjson_line "{\"type\":\"arp_neighbors\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$nb_count,\"items\":[${nb_items}]}"
+    append_ndjson_line "{\"type\":\"default_gateways\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$gw_count,\"items\":[${gw_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "neighbor_table" "$section_start_ms" "$section_end_ms"
+
     section_start_ms=$(now_ms)
     section_header "🧱 Firewall Status"
     local fw_global
@@ -206,6 +398,46 @@ run_network_audit() {
     section_end_ms=$(now_ms)
     emit_timing "wifi_info" "$section_start_ms" "$section_end_ms"
 
+    section_start_ms=$(now_ms)
+    section_header "🌐 Proxy Settings"
+    local proxy_items="" proxy_count=0 proxy_source proxy_scheme proxy_value
+    while IFS=$'\t' read -r proxy_source proxy_scheme proxy_value; do
+        [ -n "$proxy_scheme" ] || continue
+        if (( proxy_count == 0 )); then
+            report_append "| Source | Scheme | Proxy |"
+            report_append "|--------|--------|-------|"
+        fi
+        report_append "| $proxy_source | $proxy_scheme | \`$(md_path "$proxy_value")\` |"
+        [ -n "$proxy_items" ] && proxy_items+=","
+        proxy_items+="{\"source\":$(json_escape "$proxy_source"),\"scheme\":$(json_escape "$proxy_scheme"),\"value\":$(json_escape "$proxy_value")}"
+        proxy_count=$((proxy_count + 1))
+    done < <(proxy_settings network || true)
+    if (( proxy_count == 0 )); then
+        report_append "_No proxy configured._"
+    fi
+    append_ndjson_line "{\"type\":\"proxy_settings\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":$proxy_count,\"items\":[${proxy_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "proxy_settings" "$section_start_ms" "$section_end_ms"
+
+    # The network this run was on, identified by SSID, gateway MAC, and VPN
+    # state, so a run after a network change is compared with the last run on
+    # the same network.
+    section_start_ms=$(now_ms)
+    section_header "🧭 Network Context"
+    local ctx_ssid="" ctx_vpn=false ctx_id
+    [ "$ssid" = unknown ] || ctx_ssid="$ssid"
+    if (( vpn_connected > 0 )); then
+        ctx_vpn=true
+    fi
+    ctx_id="$(sha256_text "$ctx_ssid"$'\t'"$ctx_gateway_mac"$'\t'"$ctx_vpn" | cut -c1-12)"
+    if [[ "${REDACT_ALL:-false}" == "true" && -n "$ctx_ssid" ]]; then
+        ctx_ssid="<ssid>"
+    fi
+    report_append "- Context: \`$ctx_id\` (SSID: \`${ctx_ssid:-none}\`, gateway: \`${ctx_gateway:-none}\` on ${ctx_interface:-—}, VPN: **$ctx_vpn**)"
+    append_ndjson_line "{\"type\":\"network_context\",\"run_id\":$(json_escape "$RUN_ID"),\"id\":$(json_escape "$ctx_id"),\"ssid\":$(json_escape "$ctx_ssid"),\"interface\":$(json_escape "$ctx_interface"),\"gateway\":$(json_escape "$ctx_gateway"),\"gateway_mac\":$(json_escape "$ctx_gateway_mac"),\"vpn\":$ctx_vpn}"
+    section_end_ms=$(now_ms)
+    emit_timing "network_context" "$section_start_ms" "$section_end_ms"
+
     append_ndjson_line "{\"type\":\"network_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"interfaces\":${interfaces_count:-0},\"listening_ports\":${listening_count:-0},\"established_connections\":${established_count:-0}}"
 }
 
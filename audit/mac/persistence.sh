@@ -18,6 +18,7 @@ Options:
   --redact-paths         Redact NDJSON paths (default: on when --ndjson)
   --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
   --redact-all           Redact all sensitive text (implies --redact-paths)
+  --no-redact            Redact nothing, overriding redact in config.yaml
   --no-color             Disable ANSI colors in terminal output
   -h, --help             Show this help and exit
 EOF
@@ -79,23 +80,120 @@ persistence_init_ndjson_if_needed() {
     fi
     : > "$NDJSON_FILE"
     append_ndjson_line "{\"type\":\"meta\",\"run_id\":$(json_escape "$RUN_ID"),\"schema_version\":\"0.1\",\"tool_name\":\"operating-system-audit\",\"tool_component\":\"persistence-audit\",\"timestamp\":$(json_escape "$ISO_TIMESTAMP"),\"hostname\":$(json_escape "$HOSTNAME_VAL"),\"user\":$(json_escape "$CURRENT_USER"),\"os_version\":$(json_escape "$OS_VERSION"),\"kernel\":$(json_escape "$KERNEL_INFO"),\"path\":$(json_escape "$(get_audit_path_for_output)")}"
+    emit_scope
     PERSISTENCE_NDJSON_INITIALIZED=true
 }
 
+# Prints "user\ttype\tname\tidentifier\tdeveloper\tteam_id\tpath\texecutable\tparent\tenabled\tallowed"
+# for each login item and background item registered with Background Task
+# Management (`sfltool dumpbtm`, root only on macOS 13 and later): apps and
+# login items, SMAppService agents and daemons, and legacy launchd plists that
+# an app installed. Developer groupings and Apple's curated, Spotlight, and
+# Quick Look entries are skipped. user is "system" for items outside any
+# user's records. Empty fields are "-".
+btm_items() {
+    soft_out_probe "persistence.sfltool_dumpbtm" sfltool dumpbtm | awk '
+        function dash(s) { return s == "" ? "-" : s }
+        function unescape(s,   out, i, c) {
+            out = ""
+            for (i = 1; i <= length(s); i++) {
+                c = substr(s, i, 1)
+                if (c == "%" && i + 2 <= length(s)) {
+                    out = out sprintf("%c", (index("0123456789ABCDEF", toupper(substr(s, i + 1, 1))) - 1) * 16 + index("0123456789ABCDEF", toupper(substr(s, i + 2, 1))) - 1)
+                    i += 2
+                } else {
+                    out = out c
+                }
+            }
+            return out
+        }
+        function flush(   type, disp, path) {
+            if (!open) return
+            open = 0
+            type = f["Type"]; sub(/ *\(0x[0-9a-fA-F]+\)$/, "", type)
+            if (type !~ /^(app|login item|agent|daemon|legacy agent|legacy daemon)$/) return
+            disp = f["Disposition"]
+            path = f["URL"]; sub(/^file:\/\//, "", path); path = unescape(path); sub(/\/$/, "", path)
+            print user "\t" type "\t" dash(f["Name"]) "\t" dash(f["Identifier"]) "\t" dash(f["Developer Name"]) "\t" dash(f["Team Identifier"]) "\t" dash(path) "\t" dash(f["Executable Path"]) "\t" dash(f["Parent Identifier"]) "\t" (disp ~ /[[ ]enabled/ ? "true" : "false") "\t" (disp ~ /[[ ]allowed/ ? "true" : "false")
+        }
+        /Records for UID/ {
+            flush()
+            uid = $0; sub(/.*Records for UID /, "", uid); sub(/[ :].*/, "", uid)
+            user = uid
+            if (uid == "-2") user = "system"
+            else if ((("id -nu " uid " 2>/dev/null") | getline name) > 0) user = name
+            close("id -nu " uid " 2>/dev/null")
+            next
+        }
+        /^ *#[0-9]+:/ { flush(); delete f; open = 1; next }
+        open && /: / {
+            key = $0; sub(/^ */, "", key); sub(/: .*/, "", key)
+            val = $0; sub(/^[^:]*: */, "", val)
+            f[key] = val
+        }
+        END { flush() }'
+}
+
+# Prints "kind\tname\tuser\tstatus\tpath" for each background task that
+# comes from a tool rather than a plist the user can see in LaunchAgents:
+# services Homebrew manages (`brew services`, with their state: started,
+# stopped, scheduled, none, or error), the scripts periodic(8) runs daily,
+# weekly, and monthly (enabled when executable; the .local files are always
+# read), and the log rotation configs newsyslog reads. Periodic scripts and
+# log rotation configs are named by their path. Empty fields are "-".
+background_tasks() {
+    local period f state
+    if command -v brew >/dev/null 2>&1; then
+        soft_out_probe "persistence.brew_services_list" brew services list | awk -v home="$HOME" '
+            NR == 1 || NF < 2 { next }
+            {
+                i = 3
+                if ($2 == "error" && $3 ~ /^[0-9]+$/) i = 4
+                user = (NF >= i) ? $i : "-"
+                file = ""
+                for (j = i + 1; j <= NF; j++) file = file (file == "" ? "" : " ") $j
+                sub(/^~/, home, file)
+                print "brew_service\t" $1 "\t" user "\t" $2 "\t" (file == "" ? "-" : file)
+            }'
+    fi
+    for period in daily weekly monthly; do
+        for f in /etc/periodic/"$period"/* /usr/local/etc/periodic/"$period"/*; do
+            [ -f "$f" ] || continue
+            state=disabled
+            [ -x "$f" ] && state=enabled
+            printf 'periodic\t%s\troot\t%s\t%s\n' "$f" "$state" "$f"
+        done
+        f="/etc/$period.local"
+        [ -f "$f" ] && printf 'periodic\t%s\troot\tenabled\t%s\n' "$f" "$f"
+    done
+    for f in /etc/newsyslog.conf /etc/newsyslog.d/*.conf; do
+        [ -f "$f" ] || continue
+        printf 'log_rotation\t%s\troot\t-\t%s\n' "$f" "$f"
+    done
+}
+
 run_persistence_audit() {
     local system_daemons_count=0
     local system_agents_count=0
     local user_agents_count=0
     local third_party_kexts_count=0
+    local system_extensions_count=0
     local login_hooks=false
+    local shell_startup_count=0
+
+    if [ -n "${OSAUDIT_SCOPE:-}" ]; then
+        report_append "_Scoped to \`$(scope_display)\`: only persistence items that reference these paths are listed._"
+        report_append ""
+    fi
 
     section_start_ms=$(now_ms)
     section_header "🧱 System Launch Daemons"
     report_append "| Label | Program | File |"
     report_append "|-------|---------|------|"
-    local daemon_items=""
+    local daemon_items="" sig signed team_id
     shopt -s nullglob
     for plist in /Library/LaunchDaemons/*.plist; do
+        scope_matches_file "$plist" || continue
         label="$(soft_out_probe "persistence.launchdaemons_defaults_label" defaults read "$plist" Label)"
         label="${label:-$(basename "$plist")}"
         program="$(soft_out_probe "persistence.launchdaemons_defaults_program" defaults read "$plist" Program)"
@@ -105,7 +203,17 @@ run_persistence_audit() {
         program="${program:-unknown}"
         report_append "| \`$label\` | \`$program\` | \`$plist\` |"
         if (( system_daemons_count < 20 )); then
-            item="{\"label\":$(json_escape "$label"),\"program\":$(json_escape "$program")}"
+            # signed stays null when the program is not a file codesign can
+            # read; codesign -dv exits 1 for unsigned code.
+            signed=null
+            team_id=""
+            if [ -f "$program" ]; then
+                sig="$(codesign -dv --verbose=2 "$program" 2>&1 || true)"
+                team_id="$(printf '%s\n' "$sig" | awk -F= '$1 == "TeamIdentifier" && $2 != "not set" { print $2; exit }')"
+                signed=false
+                printf '%s\n' "$sig" | grep -q '^Authority=' && signed=true
+            fi
+            item="{\"label\":$(json_escape "$label"),\"program\":$(json_escape "$program"),\"signed\":${signed},\"team_id\":$(json_escape "$team_id")}"
             if [ -z "$daemon_items" ]; then
                 daemon_items="$item"
             else
@@ -121,69 +229,111 @@ run_persistence_audit() {
 
     section_start_ms=$(now_ms)
     section_header "🧬 Launch Agents (System + User)"
-    report_append "- System LaunchAgents:"
+    local agent_items="" agent_scope line system_agent_lines=() user_agent_lines=()
     shopt -s nullglob
-    for plist in /Library/LaunchAgents/*.plist; do
-        report_append "  - \`$plist\`"
-        system_agents_count=$((system_agents_count + 1))
-    done
-    report_append "- User LaunchAgents:"
-    for plist in "$HOME_DIR"/Library/LaunchAgents/*.plist; do
+    for plist in /Library/LaunchAgents/*.plist "$HOME_DIR"/Library/LaunchAgents/*.plist; do
+        scope_matches_file "$plist" || continue
+        label="$(soft_out_probe "persistence.launchagents_defaults_label" defaults read "$plist" Label)"
+        label="${label:-$(basename "$plist")}"
+        program="$(soft_out_probe "persistence.launchagents_defaults_program" defaults read "$plist" Program)"
+        if [ -z "$program" ]; then
+            program="$(soft_out_probe "persistence.launchagents_defaults_programarguments" defaults read "$plist" ProgramArguments | awk 'NR==2 {gsub(/[ ;"]/,"",$0); print $0; exit}')"
+        fi
+        program="${program:-unknown}"
         safe_plist="$(redact_path_for_ndjson "$plist")"
-        report_append "  - \`$safe_plist\`"
-        user_agents_count=$((user_agents_count + 1))
+        case "$plist" in
+            /Library/*)
+                agent_scope=system
+                system_agent_lines+=("  - \`$safe_plist\` — \`$label\`")
+                system_agents_count=$((system_agents_count + 1))
+                ;;
+            *)
+                agent_scope=user
+                user_agent_lines+=("  - \`$safe_plist\` — \`$label\`")
+                user_agents_count=$((user_agents_count + 1))
+                ;;
+        esac
+        item="{\"scope\":\"$agent_scope\",\"label\":$(json_escape "$label"),\"program\":$(json_escape "$(redact_path_for_ndjson "$program")"),\"path\":$(json_escape "$safe_plist")}"
+        if [ -z "$agent_items" ]; then
+            agent_items="$item"
+        else
+            agent_items="${agent_items},${item}"
+        fi
     done
     shopt -u nullglob
+    report_append "- System LaunchAgents:"
+    for line in "${system_agent_lines[@]+"${system_agent_lines[@]}"}"; do
+        report_append "$line"
+    done
     if (( system_agents_count == 0 )); then
         report_append "  - _none_"
     fi
+    report_append "- User LaunchAgents:"
+    for line in "${user_agent_lines[@]+"${user_agent_lines[@]}"}"; do
+        report_append "$line"
+    done
     if (( user_agents_count == 0 )); then
         report_append "  - _none_"
     fi
-    append_ndjson_line "{\"type\":\"launch_agents\",\"run_id\":$(json_escape "$RUN_ID"),\"system_count\":${system_agents_count:-0},\"user_count\":${user_agents_count:-0}}"
+    append_ndjson_line "{\"type\":\"launch_agents\",\"run_id\":$(json_escape "$RUN_ID"),\"system_count\":${system_agents_count:-0},\"user_count\":${user_agents_count:-0},\"items\":[${agent_items}]}"
     section_end_ms=$(now_ms)
     emit_timing "launch_agents" "$section_start_ms" "$section_end_ms"
 
     section_start_ms=$(now_ms)
     section_header "🧩 Kernel Extensions & System Extensions"
-    local kext_items=""
-    if command -v kmutil >/dev/null 2>&1; then
-        while IFS=$'\t' read -r bundle_id version; do
-            [ -n "$bundle_id" ] || continue
-            case "$bundle_id" in
-                com.apple.*) continue ;;
-            esac
-            item="{\"name\":$(json_escape "$bundle_id"),\"version\":$(json_escape "$version")}"
-            if [ -z "$kext_items" ]; then
-                kext_items="$item"
-            else
-                kext_items="${kext_items},${item}"
-            fi
-            third_party_kexts_count=$((third_party_kexts_count + 1))
-        done < <(soft_out_probe "persistence.kmutil_showloaded" kmutil showloaded | awk 'NR>1 {print $7 "\t" $5}')
-    else
-        while IFS=$'\t' read -r bundle_id version; do
-            [ -n "$bundle_id" ] || continue
-            case "$bundle_id" in
-                com.apple.*) continue ;;
-            esac
-            item="{\"name\":$(json_escape "$bundle_id"),\"version\":$(json_escape "$version")}"
-            if [ -z "$kext_items" ]; then
-                kext_items="$item"
-            else
-                kext_items="${kext_items},${item}"
-            fi
-            third_party_kexts_count=$((third_party_kexts_count + 1))
-        done < <(soft_out_probe "persistence.kextstat" kextstat | awk 'NR>1 {print $6 "\t" $4}')
-    fi
+    local kext_items="" kext_teams="" kext_team sysext_items=""
+    # Third-party kexts live in /Library/Extensions; their code signatures
+    # carry the developer's team ID. "not set" means ad-hoc or unsigned.
+    for kext in /Library/Extensions/*.kext; do
+        [ -d "$kext" ] || continue
+        bundle_id="$(defaults read "$kext/Contents/Info" CFBundleIdentifier 2>/dev/null || true)"
+        [ -n "$bundle_id" ] || continue
+        kext_team="$(codesign -dv "$kext" 2>&1 | awk -F= '$1 == "TeamIdentifier" && $2 != "not set" {print $2}')"
+        kext_teams="${kext_teams}${bundle_id}"$'\t'"${kext_team}"$'\n'
+    done
+    while IFS=$'\t' read -r bundle_id version; do
+        [ -n "$bundle_id" ] || continue
+        case "$bundle_id" in
+            com.apple.*) continue ;;
+        esac
+        kext_team="$(printf '%s' "$kext_teams" | awk -F'\t' -v b="$bundle_id" '$1 == b {print $2; exit}')"
+        report_append "- \`$bundle_id\` ${version:-unknown} (team ${kext_team:-unsigned})"
+        item="{\"name\":$(json_escape "$bundle_id"),\"version\":$(json_escape "$version"),\"team_id\":$(json_escape "$kext_team")}"
+        if [ -z "$kext_items" ]; then
+            kext_items="$item"
+        else
+            kext_items="${kext_items},${item}"
+        fi
+        third_party_kexts_count=$((third_party_kexts_count + 1))
+    done < <(
+        scope_matches || exit 0
+        if command -v kmutil >/dev/null 2>&1; then
+            soft_out_probe "persistence.kmutil_showloaded" kmutil showloaded 2>/dev/null | awk '$1 ~ /^[0-9]+$/ {v = $8; gsub(/[()]/, "", v); print $7 "\t" v}'
+        else
+            soft_out_probe "persistence.kextstat" kextstat 2>/dev/null | awk '$1 ~ /^[0-9]+$/ {v = $7; gsub(/[()]/, "", v); print $6 "\t" v}'
+        fi
+    )
     report_append "- Third-party kernel extensions: **${third_party_kexts_count:-0}**"
-    sysext_out="$(soft_out_probe "persistence.systemextensionsctl_list" systemextensionsctl list)"
+    append_ndjson_line "{\"type\":\"kernel_extensions\",\"run_id\":$(json_escape "$RUN_ID"),\"third_party_count\":${third_party_kexts_count:-0},\"items\":[${kext_items}]}"
+
+    # systemextensionsctl list: "enabled active teamID bundleID (version) name [state]",
+    # tab-separated, under one "--- <category>" header per extension point.
+    sysext_out="$(scope_matches || exit 0; soft_out_probe "persistence.systemextensionsctl_list" systemextensionsctl list)"
+    sysext_items="$(printf '%s\n' "$sysext_out" | awk -F'\t' "$_AWK_JSON_ESC"'
+        NF >= 6 && $3 != "teamID" && $4 ~ /\(/ {
+            bundle = $4; version = $4
+            sub(/ *\(.*/, "", bundle)
+            sub(/.*\(/, "", version); sub(/\).*/, "", version)
+            state = $6; gsub(/[][]/, "", state)
+            printf "%s{\"bundle_id\":\"%s\",\"team_id\":\"%s\",\"version\":\"%s\",\"name\":\"%s\",\"state\":\"%s\"}", (n++ ? "," : ""), json_esc(bundle), json_esc($3), json_esc(version), json_esc($5), json_esc(state)
+        }')"
+    system_extensions_count="$(printf '%s\n' "$sysext_out" | awk -F'\t' 'NF >= 6 && $3 != "teamID" && $4 ~ /\(/ {c++} END {print c+0}')"
     if [ -n "$sysext_out" ]; then
-        report_append "- System extensions output captured (best effort)."
-    else
+        report_append "- System extensions: **${system_extensions_count}**"
+    elif scope_matches; then
         report_append "- System extensions output unavailable (permissions or unsupported environment)."
     fi
-    append_ndjson_line "{\"type\":\"kernel_extensions\",\"run_id\":$(json_escape "$RUN_ID"),\"third_party_count\":${third_party_kexts_count:-0},\"items\":[${kext_items}]}"
+    append_ndjson_line "{\"type\":\"system_extensions\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${system_extensions_count:-0},\"items\":[${sysext_items}]}"
     section_end_ms=$(now_ms)
     emit_timing "kernel_extensions" "$section_start_ms" "$section_end_ms"
 
@@ -208,7 +358,187 @@ run_persistence_audit() {
     section_end_ms=$(now_ms)
     emit_timing "login_hooks_authorization_plugins" "$section_start_ms" "$section_end_ms"
 
-    append_ndjson_line "{\"type\":\"persistence_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"system_daemons\":${system_daemons_count:-0},\"system_agents\":${system_agents_count:-0},\"user_agents\":${user_agents_count:-0},\"third_party_kexts\":${third_party_kexts_count:-0},\"login_hooks\":$login_hooks}"
+    section_start_ms=$(now_ms)
+    section_header "🎒 Login and Background Items"
+    local btm_lines btm_source="btm" btm_items_json="" btm_count=0 btm_login_items=0 btm_disabled=0
+    local btm_user btm_type btm_name btm_identifier btm_developer btm_team btm_path btm_executable btm_parent btm_enabled btm_allowed btm_state
+    btm_lines="$(btm_items)"
+    if [ -z "$btm_lines" ]; then
+        # Without root, sfltool cannot read the BTM store; System Events still
+        # lists the current user's login items.
+        btm_source="system_events"
+        btm_lines="$(soft_out_probe "persistence.osascript_login_items" osascript -e 'set out to ""' -e 'tell application "System Events"' -e 'repeat with li in login items' -e 'set out to out & name of li & tab & path of li & linefeed' -e 'end repeat' -e 'end tell' -e 'return out' | awk -F'\t' -v user="$CURRENT_USER" 'NF >= 2 && $1 != "" {print user "\tlogin item\t" $1 "\t" $2 "\t-\t-\t" $2 "\t-\t-\ttrue\ttrue"}')"
+    fi
+    while IFS=$'\t' read -r btm_user btm_type btm_name btm_identifier btm_developer btm_team btm_path btm_executable btm_parent btm_enabled btm_allowed; do
+        [ -n "$btm_type" ] || continue
+        [ "$btm_name" != "-" ] || btm_name=""
+        [ "$btm_identifier" != "-" ] || btm_identifier=""
+        [ "$btm_developer" != "-" ] || btm_developer=""
+        [ "$btm_team" != "-" ] || btm_team=""
+        [ "$btm_path" != "-" ] || btm_path=""
+        [ "$btm_executable" != "-" ] || btm_executable=""
+        [ "$btm_parent" != "-" ] || btm_parent=""
+        scope_matches "$btm_path" "$btm_executable" || continue
+        btm_path="$(redact_path_for_ndjson "$btm_path")"
+        btm_executable="$(redact_path_for_ndjson "$btm_executable")"
+        if [ "$btm_source" = "system_events" ]; then
+            btm_identifier="$btm_path"
+        fi
+        if (( btm_count == 0 )); then
+            report_append "| User | Type | Name | Developer | Path | State |"
+            report_append "|------|------|------|-----------|------|-------|"
+        fi
+        btm_state="enabled"
+        if [ "$btm_enabled" != true ]; then
+            btm_state="disabled"
+        elif [ "$btm_allowed" != true ]; then
+            btm_state="not allowed"
+        fi
+        report_append "| $btm_user | $btm_type | ${btm_name:-$btm_identifier} | ${btm_developer:--}${btm_team:+ ($btm_team)} | \`${btm_executable:-${btm_path:--}}\` | $btm_state |"
+        item="{\"user\":$(json_escape "$btm_user"),\"type\":$(json_escape "$btm_type"),\"name\":$(json_escape "$btm_name"),\"identifier\":$(json_escape "$btm_identifier"),\"developer\":$(json_escape "$btm_developer"),\"team_id\":$(json_escape "$btm_team"),\"path\":$(json_escape "$btm_path"),\"executable\":$(json_escape "$btm_executable"),\"parent\":$(json_escape "$btm_parent"),\"enabled\":$btm_enabled,\"allowed\":$btm_allowed}"
+        if [ -z "$btm_items_json" ]; then
+            btm_items_json="$item"
+        else
+            btm_items_json="${btm_items_json},${item}"
+        fi
+        btm_count=$((btm_count + 1))
+        case "$btm_type" in
+            app|"login item") btm_login_items=$((btm_login_items + 1)) ;;
+        esac
+        [ "$btm_state" = "enabled" ] || btm_disabled=$((btm_disabled + 1))
+    done <<< "$btm_lines"
+    if (( btm_count == 0 )); then
+        report_append "_No login or background items found._"
+    elif [ "$btm_source" = "system_events" ]; then
+        report_append ""
+        report_append "_Background items registered with macOS need root to list (\`sudo sfltool dumpbtm\`); only login items are shown._"
+    fi
+    append_ndjson_line "{\"type\":\"background_items\",\"run_id\":$(json_escape "$RUN_ID"),\"source\":\"$btm_source\",\"count\":${btm_count},\"login_items\":${btm_login_items},\"disabled\":${btm_disabled},\"items\":[${btm_items_json}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "background_items" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🍺 Homebrew Services and Periodic Tasks"
+    local task_items="" task_kind task_name task_user task_status task_path task_sha safe_task_path background_tasks_count=0 brew_services_started=0
+    while IFS=$'\t' read -r task_kind task_name task_user task_status task_path; do
+        [ -n "$task_kind" ] || continue
+        [ "$task_user" != "-" ] || task_user=""
+        [ "$task_status" != "-" ] || task_status=""
+        [ "$task_path" != "-" ] || task_path=""
+        if [ -n "$task_path" ]; then
+            scope_matches_file "$task_path" || continue
+        else
+            scope_matches "$task_name" || continue
+        fi
+        task_sha="$(sha256_file "$task_path")"
+        safe_task_path="$(redact_path_for_ndjson "$task_path")"
+        [ "$task_kind" = "brew_service" ] || task_name="$safe_task_path"
+        if (( background_tasks_count == 0 )); then
+            report_append "| Kind | Name | User | Status | File |"
+            report_append "|------|------|------|--------|------|"
+        fi
+        report_append "| $task_kind | \`$task_name\` | ${task_user:--} | ${task_status:--} | \`${safe_task_path:--}\` |"
+        item="{\"kind\":\"$task_kind\",\"name\":$(json_escape "$task_name"),\"user\":$(json_escape "$task_user"),\"status\":$(json_escape "$task_status"),\"path\":$(json_escape "$safe_task_path"),\"sha256\":$(json_escape "$task_sha")}"
+        if [ -z "$task_items" ]; then
+            task_items="$item"
+        else
+            task_items="${task_items},${item}"
+        fi
+        background_tasks_count=$((background_tasks_count + 1))
+        [ "$task_kind:$task_status" != "brew_service:started" ] || brew_services_started=$((brew_services_started + 1))
+    done < <(background_tasks)
+    if (( background_tasks_count == 0 )); then
+        report_append "_No Homebrew services, periodic scripts, or log rotation configs found._"
+    fi
+    append_ndjson_line "{\"type\":\"background_tasks\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${background_tasks_count},\"brew_services_started\":${brew_services_started},\"items\":[${task_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "background_tasks" "$section_start_ms" "$section_end_ms"
+
+    section_start_ms=$(now_ms)
+    section_header "🐚 Shell Startup Files"
+    local rc_scope rc_path rc_sha rc_line rc_pattern rc_patterns rc_findings rc_flagged safe_rc_path
+    local shell_rc_items="" shell_rc_flagged_json="" shell_rc_flagged_lines=() shell_rc_suspicious=0
+    while IFS=$'\t' read -r rc_scope rc_path; do
+        [ -n "$rc_path" ] || continue
+        scope_matches_file "$rc_path" || continue
+        rc_sha="$(sha256_file "$rc_path")"
+        safe_rc_path="$(redact_path_for_ndjson "$rc_path")"
+        rc_patterns=""
+        rc_findings=0
+        while IFS=$'\t' read -r rc_line rc_pattern; do
+            [ -n "$rc_pattern" ] || continue
+            rc_findings=$((rc_findings + 1))
+            case ",$rc_patterns," in *",$rc_pattern,"*) ;; *) rc_patterns="${rc_patterns:+$rc_patterns,}$rc_pattern" ;; esac
+            [ "$rc_pattern" != "path_prepend" ] || continue
+            shell_rc_suspicious=$((shell_rc_suspicious + 1))
+            shell_rc_flagged_lines+=("- ⚠️ \`$safe_rc_path\` line $rc_line: $rc_pattern")
+            if (( shell_rc_suspicious <= 50 )); then
+                shell_rc_flagged_json="${shell_rc_flagged_json:+$shell_rc_flagged_json,}{\"path\":$(json_escape "$safe_rc_path"),\"line\":$rc_line,\"pattern\":\"$rc_pattern\"}"
+            fi
+        done < <(shell_startup_findings "$rc_path" || true)
+        if (( shell_startup_count == 0 )); then
+            report_append "| Scope | File | SHA-256 | Patterns |"
+            report_append "|-------|------|---------|----------|"
+        fi
+        report_append "| $rc_scope | \`$safe_rc_path\` | \`${rc_sha:0:12}\` | ${rc_patterns:--} |"
+        [ -n "$shell_rc_items" ] && shell_rc_items+=","
+        shell_rc_items+="{\"scope\":\"$rc_scope\",\"path\":$(json_escape "$safe_rc_path"),\"sha256\":$(json_escape "$rc_sha"),\"findings\":$rc_findings,\"patterns\":\"$rc_patterns\"}"
+        shell_startup_count=$((shell_startup_count + 1))
+    done < <(shell_startup_files)
+    if (( shell_startup_count == 0 )); then
+        report_append "_No shell startup files found._"
+    fi
+    if (( shell_rc_suspicious > 0 )); then
+        report_append ""
+        for rc_flagged in "${shell_rc_flagged_lines[@]}"; do
+            report_append "$rc_flagged"
+        done
+        append_ndjson_line "{\"type\":\"warning\",\"run_id\":$(json_escape "$RUN_ID"),\"code\":\"shell_startup_suspicious\",\"count\":$shell_rc_suspicious,\"items\":[${shell_rc_flagged_json}]}"
+    fi
+    append_ndjson_line "{\"type\":\"shell_startup_files\",\"run_id\":$(json_escape "$RUN_ID"),\"count\":${shell_startup_count:-0},\"suspicious\":$shell_rc_suspicious,\"items\":[${shell_rc_items}]}"
+    section_end_ms=$(now_ms)
+    emit_timing "shell_startup_files" "$section_start_ms" "$section_end_ms"
+
+    # `osaudit triage` also lists recently modified files (see
+    # collector.TriageEnv).
+    if [ -n "${OSAUDIT_TRIAGE_HOURS:-}" ]; then
+        section_start_ms=$(now_ms)
+        section_header "🕵️ Recently Modified Files"
+        local recent_hours="$OSAUDIT_TRIAGE_HOURS" recent_count=0 recent_items="" recent_epoch recent_modified recent_path safe_recent_path
+        [[ "$recent_hours" =~ ^[0-9]+$ ]] || recent_hours=24
+        local recent_out recent_total
+        recent_out="$(recent_changes "$recent_hours")"
+        recent_total=$(printf '%s' "$recent_out" | awk 'END{print NR}')
+        report_append "Files modified in the last **${recent_hours} hour(s)** in system configuration, temporary, and startup locations (newest first, at most 200)."
+        report_append ""
+        while IFS=$'\t' read -r recent_epoch recent_modified recent_path; do
+            [ -n "$recent_path" ] || continue
+            if (( recent_count == 0 )); then
+                report_append "| Modified | Path |"
+                report_append "|----------|------|"
+            fi
+            safe_recent_path="$(redact_path_for_ndjson "$recent_path")"
+            report_append "| $recent_modified | \`$safe_recent_path\` |"
+            item="{\"path\":$(json_escape "$safe_recent_path"),\"modified\":$(json_escape "$recent_modified")}"
+            if [ -z "$recent_items" ]; then
+                recent_items="$item"
+            else
+                recent_items="${recent_items},${item}"
+            fi
+            recent_count=$((recent_count + 1))
+        done < <(printf '%s\n' "$recent_out" | head -n 200)
+        if (( recent_count == 0 )); then
+            report_append "_No recently modified files._"
+        elif (( recent_total > recent_count )); then
+            report_append ""
+            report_append "- $((recent_total - recent_count)) older change(s) not listed."
+        fi
+        append_ndjson_line "{\"type\":\"recent_changes\",\"run_id\":$(json_escape "$RUN_ID"),\"hours\":${recent_hours},\"count\":${recent_total:-0},\"truncated\":$( (( recent_total > recent_count )) && echo true || echo false),\"items\":[${recent_items}]}"
+        section_end_ms=$(now_ms)
+        emit_timing "recent_changes" "$section_start_ms" "$section_end_ms"
+    fi
+
+    append_ndjson_line "{\"type\":\"persistence_summary\",\"run_id\":$(json_escape "$RUN_ID"),\"system_daemons\":${system_daemons_count:-0},\"system_agents\":${system_agents_count:-0},\"user_agents\":${user_agents_count:-0},\"third_party_kexts\":${third_party_kexts_count:-0},\"system_extensions\":${system_extensions_count:-0},\"background_items\":${btm_count:-0},\"background_tasks\":${background_tasks_count:-0},\"login_hooks\":$login_hooks,\"shell_startup_files\":${shell_startup_count:-0}}"
 }
 
 persistence_main() {
@@ -18,6 +18,7 @@ Options:
   --threshold-mb <int>   Large file threshold in MB (default: 100)
   --old-days <int>       Stale file threshold in days (default: 180)
   --deep                 Scan full home dir (pruned for Library/.Trash/.git/node_modules)
+  --one-filesystem       Do not descend into other filesystems or bind mounts
   --ndjson               Also write a compact NDJSON summary file
   --heatmap              Render HTML heatmaps (auto-enables NDJSON)
   --heatmap-emit-topn N  NDJSON top_paths/top_items emit count (default: 100)
@@ -25,6 +26,7 @@ Options:
   --redact-paths         Redact NDJSON paths (default: on when --ndjson)
   --no-redact-paths      Disable NDJSON path redaction (default off otherwise)
   --redact-all           Redact all sensitive text (implies --redact-paths)
+  --no-redact            Redact nothing, overriding redact in config.yaml
   --no-color             Disable ANSI colors in terminal output
   -h, --help             Show this help and exit
 EOF
@@ -37,6 +39,7 @@ storage_set_defaults_if_unset() {
     LARGE_FILE_THRESHOLD_MB="${LARGE_FILE_THRESHOLD_MB:-100}"
     OLD_FILE_DAYS="${OLD_FILE_DAYS:-180}"
     DEEP_SCAN="${DEEP_SCAN:-false}"
+    ONE_FILESYSTEM="${ONE_FILESYSTEM:-false}"
     ROOTS_OVERRIDE_RAW="${ROOTS_OVERRIDE_RAW:-}"
     HEATMAP="${HEATMAP:-false}"
     HEATMAP_EMIT_TOPN="${HEATMAP_EMIT_TOPN:-100}"
@@ -97,6 +100,10 @@ storage_parse_args() {
                 DEEP_SCAN=true
                 shift
                 ;;
+            --one-filesystem)
+                ONE_FILESYSTEM=true
+                shift
+                ;;
             --ndjson)
                 WRITE_NDJSON=true
                 shift
@@ -133,10 +140,23 @@ storage_parse_args() {
                 REDACT_ALL=true
                 shift
                 ;;
+            --no-redact)
+                REDACT_ALL=false
+                REDACT_PATHS_MODE="off"
+                shift
+                ;;
             --no-color)
                 NO_COLOR=true
                 shift
                 ;;
+            --run-meta-out)
+                if (($# < 2)); then
+                    echo "Error: --run-meta-out requires a path" >&2
+                    exit 1
+                fi
+                RUN_META_OUT="$2"
+                shift 2
+                ;;
             -h|--help)
                 storage_usage
                 exit 0
@@ -190,6 +210,11 @@ storage_build_scan_roots() {
         SCAN_ROOTS=("$HOME_DIR/Downloads" "$HOME_DIR/Desktop" "$HOME_DIR/Documents")
     fi
 
+    # osaudit run --scope limits the scan like --roots.
+    if [ -z "$ROOTS_OVERRIDE_RAW" ] && [ -n "${OSAUDIT_SCOPE:-}" ]; then
+        ROOTS_OVERRIDE_RAW="${OSAUDIT_SCOPE//:/,}"
+    fi
+
     if [ -n "$ROOTS_OVERRIDE_RAW" ]; then
         SCAN_ROOTS=()
         declare -a requested_roots=()
@@ -211,6 +236,14 @@ storage_build_scan_roots() {
             NDJSON_PENDING_NOTES+=("$note_msg")
         fi
     fi
+
+    # osaudit passes ~/.osaudit/exclude along; find_excluding prunes it.
+    exclude_count=$(printf '%s\n' "${OSAUDIT_EXCLUDE:-}" | grep -Ecv '^[[:space:]]*(#|$)' || true)
+    if (( ${exclude_count:-0} > 0 )); then
+        note_msg="Skipping paths matching $exclude_count exclude pattern(s) from ~/.osaudit/exclude"
+        METADATA_NOTES+=("$note_msg")
+        NDJSON_PENDING_NOTES+=("$note_msg")
+    fi
 }
 
 storage_prepare_files_and_common() {
@@ -270,6 +303,7 @@ storage_init_ndjson_if_needed() {
     fi
     append_ndjson_line "{\"type\":\"meta\",\"run_id\":$(json_escape "$RUN_ID"),\"schema_version\":\"0.1\",\"tool_name\":\"operating-system-audit\",\"tool_component\":\"storage-audit\",\"timestamp\":$(json_escape "$ISO_TIMESTAMP"),\"hostname\":$(json_escape "$HOSTNAME_VAL"),\"user\":$(json_escape "$CURRENT_USER"),\"os_version\":$(json_escape "$OS_VERSION"),\"kernel\":$(json_escape "$KERNEL_INFO"),\"path\":$(json_escape "$(get_audit_path_for_output)")}"
     append_ndjson_line "{\"type\":\"scan\",\"run_id\":$(json_escape "$RUN_ID"),\"mode\":$(json_escape "$scan_mode"),\"threshold_mb\":$LARGE_FILE_THRESHOLD_MB,\"old_days\":$OLD_FILE_DAYS,\"redact_paths\":$([ "$REDACT_PATHS" = true ] && echo true || echo false)}"
+    emit_scope
     for note in "${NDJSON_PENDING_NOTES[@]+"${NDJSON_PENDING_NOTES[@]}"}"; do
         append_ndjson_line "{\"type\":\"note\",\"run_id\":$(json_escape "$RUN_ID"),\"message\":$(json_escape "$note")}"
     done
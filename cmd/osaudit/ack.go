@@ -0,0 +1,256 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/noise"
+	"github.com/kareemsasa/operating-system-audit/internal/settings"
+)
+
+func runAck(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "ack requires subcommand: record, suggest, list")
+		printUsage()
+		return exitcode.Usage
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	switch args[0] {
+	case "record":
+		return ackRecord(dir, args[1:])
+	case "suggest":
+		return ackSuggest(dir, args[1:])
+	case "list":
+		return ackList(dir)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown ack subcommand: %s\n", args[0])
+		printUsage()
+		return exitcode.Usage
+	}
+}
+
+// ackRecord acknowledges every finding the baseline→current diff reports.
+func ackRecord(dir string, args []string) int {
+	fs := flag.NewFlagSet("ack record", flag.ContinueOnError)
+	baseline := fs.String("baseline", "", "Path to baseline NDJSON file")
+	current := fs.String("current", "", "Path to current NDJSON file")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if *baseline == "" || *current == "" {
+		fmt.Fprintln(os.Stderr, "ack record requires --baseline and --current")
+		printUsage()
+		return exitcode.Usage
+	}
+
+	baselineRows, err := diff.ReadNDJSON(*baseline)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	currentRows, err := diff.ReadNDJSON(*current)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	rules, err := noise.LoadRules(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	baselineRows = diff.ApplyIgnore(baselineRows, rules.Match)
+	currentRows = diff.ApplyIgnore(currentRows, rules.Match)
+
+	keys := diff.FindingKeys(baselineRows, currentRows)
+	if len(keys) == 0 {
+		fmt.Println("No findings to acknowledge.")
+		return exitcode.OK
+	}
+	acks, err := noise.LoadAcks(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	acks.Record(keys, snapshotRunID(currentRows, *current), time.Now().UTC())
+	if err := noise.SaveAcks(dir, acks); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	for _, k := range keys {
+		fmt.Printf("  acknowledged %s (%d total)\n", k, acks[k].Count)
+	}
+	return exitcode.OK
+}
+
+// ackSuggest reports finding types acknowledged often enough to ignore. With
+// --apply the suggestions are written to ignore.json.
+func ackSuggest(dir string, args []string) int {
+	fs := flag.NewFlagSet("ack suggest", flag.ContinueOnError)
+	minAcks := fs.Int("min-acks", noise.DefaultMinAcks, "Acknowledgements needed before a finding type is suggested")
+	apply := fs.Bool("apply", false, "Write suggested ignore rules instead of only listing them")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+
+	acks, rules, err := loadNoiseState(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	suggestions := noise.Suggest(acks, rules, *minAcks)
+	if len(suggestions) == 0 {
+		fmt.Println(i18n.T("ack.suggest.none"))
+		return exitcode.OK
+	}
+	fmt.Println(i18n.T("ack.suggest.header"))
+	now := time.Now().UTC()
+	for _, s := range suggestions {
+		fmt.Println("  " + i18n.T("ack.suggest.item", s.Key, s.Count, s.LastSeen.Format("2006-01-02")))
+		if *apply {
+			rules.Add(s.Key, s.Reason(), now)
+		}
+	}
+	if !*apply {
+		fmt.Printf("\n%s\n", i18n.T("ack.suggest.apply"))
+		return exitcode.OK
+	}
+	if err := noise.SaveRules(dir, rules); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	fmt.Printf("\n%s\n", i18n.T("ack.suggest.wrote", len(suggestions)))
+	return exitcode.OK
+}
+
+func ackList(dir string) int {
+	acks, rules, err := loadNoiseState(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	fmt.Println(i18n.T("ack.list.header"))
+	keys := make([]string, 0, len(acks))
+	for k := range acks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		suffix := ""
+		if rules.Match(k) {
+			suffix = " " + i18n.T("ack.list.ignored")
+		}
+		fmt.Printf("  %s: %d%s\n", k, acks[k].Count, suffix)
+	}
+	fmt.Printf("\n%s\n", i18n.T("ack.list.rules"))
+	for _, r := range rules {
+		fmt.Printf("  %s (%s)\n", r.Pattern, r.Reason)
+	}
+	return exitcode.OK
+}
+
+func loadNoiseState(dir string) (noise.AckStore, noise.Rules, error) {
+	acks, err := noise.LoadAcks(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := noise.LoadRules(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return acks, rules, nil
+}
+
+// loadIgnoreRules returns the configured ignore rules, ignore.json's and
+// config.yaml's ignore patterns, or only config.yaml's when the config
+// directory cannot be resolved (ignore rules are best-effort for diffs).
+func loadIgnoreRules() (noise.Rules, error) {
+	var rules noise.Rules
+	var err error
+	if text, ok := os.LookupEnv(config.IgnoreEnv); ok && strings.TrimSpace(text) != "" {
+		rules, err = ignoreRulesFromEnv(text)
+	} else if dir, derr := config.Dir(); derr == nil {
+		rules, err = noise.LoadRules(dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range userSettings.Ignore {
+		rules = append(rules, noise.Rule{Pattern: p, Reason: settings.File})
+	}
+	return rules, nil
+}
+
+// ignoreRulesFromEnv parses $OSAUDIT_IGNORE, which replaces ignore.json: a
+// comma-separated list of patterns, or a JSON list of patterns or rules.
+func ignoreRulesFromEnv(text string) (noise.Rules, error) {
+	v, err := config.ParseEnvValue(text, true)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", config.IgnoreEnv, err)
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: want a list of patterns", config.IgnoreEnv)
+	}
+	var rules noise.Rules
+	for _, item := range list {
+		var r noise.Rule
+		switch x := item.(type) {
+		case string:
+			r.Pattern = x
+		case map[string]any:
+			r.Pattern, _ = x["pattern"].(string)
+			r.Reason, _ = x["reason"].(string)
+		}
+		if r.Pattern == "" {
+			return nil, fmt.Errorf("%s: every rule needs a pattern", config.IgnoreEnv)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// snapshotRunID returns the meta.run_id of a snapshot, falling back to its path.
+func snapshotRunID(rows []diff.Row, fallback string) string {
+	if id, ok := diff.GroupByType(rows)["meta"]["run_id"].(string); ok && id != "" {
+		return id
+	}
+	return fallback
+}
+
+// reportSuggestedSuppressions prints a one-line hint when acknowledged findings
+// have become suppression candidates. Used by run-scheduled as a periodic report.
+func reportSuggestedSuppressions() {
+	dir, err := config.Dir()
+	if err != nil {
+		return
+	}
+	acks, rules, err := loadNoiseState(dir)
+	if err != nil {
+		return
+	}
+	if n := len(noise.Suggest(acks, rules, noise.DefaultMinAcks)); n > 0 {
+		fmt.Fprintf(os.Stderr, "run-scheduled: %d suppression(s) suggested from acknowledged findings; run `osaudit ack suggest`\n", n)
+	}
+}
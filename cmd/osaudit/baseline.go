@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/contexts"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/redact"
+	"github.com/kareemsasa/operating-system-audit/internal/render"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// baselineRedactField records on a baseline's meta row how it was redacted,
+// so snapshots diffed against it are redacted the same way.
+const baselineRedactField = "baseline_redaction"
+
+var baselineNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func runBaseline(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "approve":
+			return runBaselineApprove(args[1:])
+		case "diff":
+			return runBaselineDiff(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "baseline requires a subcommand: approve or diff")
+	printUsage()
+	return exitcode.Usage
+}
+
+// runBaselineApprove writes a snapshot into a git repository of baselines as
+// <name>.ndjson, canonicalized and redacted, and commits it with a message
+// that summarizes the drift from the previous baseline.
+func runBaselineApprove(args []string) int {
+	fs := flag.NewFlagSet("baseline approve", flag.ContinueOnError)
+	repo := fs.String("repo", "", "Git repository (or a directory inside one) that holds the baselines")
+	name := fs.String("name", "", "Baseline name (default: the snapshot's hostname, or \"baseline\" with --redact-all)")
+	redactAll := fs.Bool("redact-all", false, "Also redact hostnames, SSH fingerprints, and process arguments")
+	message := fs.String("m", "", "Commit message subject (default: a summary of the drift)")
+	context := fs.String("context", "", "Context the baseline is for, e.g. office (default: $OSAUDIT_CONTEXT, or the contexts.yaml match for the snapshot's network)")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if *repo == "" || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "baseline approve requires --repo and one snapshot")
+		printUsage()
+		return exitcode.Usage
+	}
+	if err := gitCheckRepo(*repo); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+
+	rows, err := diff.ReadNDJSON(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if *name == "" {
+		*name = "baseline"
+		if host, _ := metaField(rows, "hostname").(string); host != "" && !*redactAll {
+			*name = host
+		}
+	}
+	if !baselineNamePattern.MatchString(*name) {
+		fmt.Fprintf(os.Stderr, "Error: baseline name %q must match %s\n", *name, baselineNamePattern)
+		return exitcode.Usage
+	}
+	ctx, err := contexts.Resolve(*context, networkRow(rows))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	rows = canonicalBaseline(rows, *redactAll)
+
+	file := contextBaselineName(*name, ctx)
+	path := filepath.Join(*repo, file)
+	var previous []diff.Row
+	if _, err := os.Stat(path); err == nil {
+		if previous, err = diff.ReadNDJSON(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+	}
+	data, err := encodeSnapshot(rows, false)
+	if err == nil {
+		err = writeFileAtomic(path, data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+
+	msg := baselineCommitMessage(strings.TrimSuffix(file, ".ndjson"), previous, rows, *message)
+	if err := git(*repo, "add", "--", file); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if git(*repo, "diff", "--cached", "--quiet", "--", file) == nil {
+		fmt.Printf("%s: unchanged, nothing to commit\n", path)
+		return exitcode.OK
+	}
+	if err := git(*repo, "commit", "--quiet", "-m", msg, "--", file); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	fmt.Printf("%s: committed %q\n", path, strings.SplitN(msg, "\n", 2)[0])
+	return exitcode.OK
+}
+
+// runBaselineDiff diffs a snapshot against a baseline in a baseline
+// repository, as of --rev or in the working tree. The snapshot is redacted the
+// way the baseline was first, so redaction alone never shows as drift.
+func runBaselineDiff(args []string) int {
+	fs := flag.NewFlagSet("baseline diff", flag.ContinueOnError)
+	repo := fs.String("repo", "", "Git repository (or a directory inside one) that holds the baselines")
+	name := fs.String("name", "", "Baseline name (default: the snapshot's hostname)")
+	rev := fs.String("rev", "", "Git revision to read the baseline from (default: the working tree)")
+	ndjson := fs.Bool("ndjson", false, "Emit structured diff rows as NDJSON instead of human-readable summary")
+	noIgnore := fs.Bool("no-ignore", false, "Report findings suppressed by ignore rules in ~/.osaudit/ignore.json")
+	theme := fs.String("theme", render.ThemeMarkdown, themeUsage)
+	context := fs.String("context", "", "Context whose baseline to use, e.g. office (default: $OSAUDIT_CONTEXT, or the contexts.yaml match for the snapshot's network)")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if *repo == "" || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "baseline diff requires --repo and one snapshot")
+		printUsage()
+		return exitcode.Usage
+	}
+	if !validateTheme("baseline diff", *theme, *ndjson) {
+		return exitcode.Usage
+	}
+	if err := loadClassification(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+
+	currentRows, err := diff.ReadNDJSON(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if *name == "" {
+		*name, _ = metaField(currentRows, "hostname").(string)
+	}
+	if !baselineNamePattern.MatchString(*name) {
+		fmt.Fprintf(os.Stderr, "Error: baseline name %q must match %s (pass --name)\n", *name, baselineNamePattern)
+		return exitcode.Usage
+	}
+	ctx, err := contexts.Resolve(*context, networkRow(currentRows))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	// A context without a baseline of its own falls back to the host's.
+	file := contextBaselineName(*name, ctx)
+	path := filepath.Join(*repo, file)
+	if *rev != "" {
+		tmp, err := gitShowToTemp(*repo, *rev, file)
+		if err != nil && ctx != "" {
+			file = contextBaselineName(*name, "")
+			tmp, err = gitShowToTemp(*repo, *rev, file)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		defer writeguard.Remove(tmp)
+		path = tmp
+	} else if _, err := os.Stat(path); err != nil && ctx != "" {
+		file = contextBaselineName(*name, "")
+		path = filepath.Join(*repo, file)
+	}
+	if ctx != "" {
+		fmt.Fprintf(os.Stderr, "baseline diff: context %s; comparing with %s\n", ctx, file)
+	}
+	baselineRows, err := diff.ReadNDJSON(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	redactAll := metaField(baselineRows, baselineRedactField) == "all"
+	currentRows = canonicalBaseline(currentRows, redactAll)
+
+	if !*noIgnore {
+		rules, err := loadIgnoreRules()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		if len(rules) > 0 {
+			baselineRows = diff.ApplyIgnore(baselineRows, rules.Match)
+			currentRows = diff.ApplyIgnore(currentRows, rules.Match)
+		}
+	}
+	return withDiffTheme(*theme, func() int {
+		if hasDeltas, _ := diff.Run(baselineRows, currentRows, *ndjson, false); hasDeltas {
+			return exitcode.Drift
+		}
+		return exitcode.OK
+	})
+}
+
+// canonicalBaseline prepares rows for review in version control: untyped and
+// timing rows (which differ on every run) are dropped, as is every row's
+// run_id; values are redacted; exact duplicates are dropped; and rows are
+// grouped by type, meta first, with items arrays sorted. Rows of one type keep
+// their order, so later-row-wins readers see the same result.
+func canonicalBaseline(rows []diff.Row, redactAll bool) []diff.Row {
+	out := make([]diff.Row, 0, len(rows))
+	for _, r := range rows {
+		t, _ := r["type"].(string)
+		if t == "" || t == "timing" {
+			continue
+		}
+		if t != "meta" {
+			delete(r, "run_id")
+		}
+		if items, ok := r["items"].([]any); ok {
+			sortItems(items)
+		}
+		out = append(out, r)
+	}
+	redact.ForRows(out, redactAll).Apply(out)
+	out, _ = dedupeRows(out)
+	for _, r := range out {
+		if r["type"] == "meta" {
+			mode := "default"
+			if redactAll {
+				mode = "all"
+			}
+			r[baselineRedactField] = mode
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		ti, tj := out[i]["type"].(string), out[j]["type"].(string)
+		if (ti == "meta") != (tj == "meta") {
+			return ti == "meta"
+		}
+		return ti < tj
+	})
+	return out
+}
+
+func sortItems(items []any) {
+	keys := make([]string, len(items))
+	for i, it := range items {
+		data, _ := json.Marshal(it)
+		keys[i] = string(data)
+	}
+	sort.Sort(itemsByKey{items, keys})
+}
+
+type itemsByKey struct {
+	items []any
+	keys  []string
+}
+
+func (s itemsByKey) Len() int           { return len(s.items) }
+func (s itemsByKey) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s itemsByKey) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// baselineCommitMessage summarizes the drift from previous to current: the
+// subject counts the findings, and the body lists their keys.
+func baselineCommitMessage(name string, previous, current []diff.Row, subject string) string {
+	if previous == nil {
+		if subject == "" {
+			subject = fmt.Sprintf("Add baseline %s", name)
+		}
+		return subject
+	}
+	keys := diff.FindingKeys(previous, current)
+	if subject == "" {
+		switch len(keys) {
+		case 0:
+			subject = fmt.Sprintf("Approve baseline %s: no drift", name)
+		case 1:
+			subject = fmt.Sprintf("Approve baseline %s: 1 finding", name)
+		default:
+			subject = fmt.Sprintf("Approve baseline %s: %d findings", name, len(keys))
+		}
+	}
+	if len(keys) == 0 {
+		return subject
+	}
+	var b strings.Builder
+	b.WriteString(subject + "\n\n")
+	for _, k := range keys {
+		b.WriteString("- " + k + "\n")
+	}
+	return b.String()
+}
+
+// metaField returns field of rows' meta row, or nil.
+func metaField(rows []diff.Row, field string) any {
+	for _, r := range rows {
+		if r["type"] == "meta" {
+			return r[field]
+		}
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := writeguard.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := writeguard.Rename(tmp, path); err != nil {
+		writeguard.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func gitCheckRepo(dir string) error {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("baseline repository %s is not a directory", dir)
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return errors.New("git not found in PATH")
+	}
+	if err := git(dir, "rev-parse", "--git-dir"); err != nil {
+		return fmt.Errorf("%s is not inside a git repository (run `git init %s`)", dir, dir)
+	}
+	return nil
+}
+
+// git runs a git command in dir, returning its stderr as the error.
+func git(dir string, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// gitShowToTemp writes file as of rev to a temporary file and returns its path.
+func gitShowToTemp(dir, rev, file string) (string, error) {
+	cmd := exec.Command("git", "-C", dir, "show", rev+":./"+file)
+	data, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+			return "", fmt.Errorf("git show %s:%s: %s", rev, file, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return "", err
+	}
+	f, err := writeguard.CreateTemp("", "osaudit-baseline-*.ndjson")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		writeguard.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/perf"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// runBench diffs two synthetic snapshots and checks the cost per row against
+// the performance budget. It exits exitcode.PolicyFailure when a stage is over
+// budget, so CI can gate on it.
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	rows := fs.Int("rows", perf.DefaultRows, "Rows per synthetic snapshot")
+	seed := fs.Int64("seed", 1, "Generator seed")
+	churn := fs.Float64("churn", 0.01, "Fraction of per-file rows that differ between the snapshots")
+	iterations := fs.Int("iterations", 3, "Runs per stage; the fastest is reported")
+	budgetPath := fs.String("budget", "", "JSON file overriding the default budget per stage")
+	ndjson := fs.Bool("ndjson", false, "Emit one JSON object per stage")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if *rows < 1000 || *iterations < 1 || *churn < 0 || *churn > 1 {
+		fmt.Fprintln(os.Stderr, "Error: --rows must be at least 1000, --iterations at least 1, and --churn between 0 and 1")
+		return exitcode.Usage
+	}
+
+	budget := perf.DefaultBudget
+	if *budgetPath != "" {
+		b, err := perf.LoadBudget(*budgetPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+		budget = b
+	}
+
+	dir, err := writeguard.MkdirTemp("", "osaudit-bench-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	defer writeguard.RemoveAll(dir)
+
+	baseline, current := perf.Synthesize(perf.Options{Rows: *rows, Seed: *seed, Churn: *churn})
+	results, err := perf.Measure(dir, baseline, current, *iterations)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+
+	if *ndjson {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range results {
+			row := struct {
+				Type string `json:"type"`
+				perf.StageResult
+				WithinBudget bool `json:"within_budget"`
+			}{"bench", r, len(budget.Check([]perf.StageResult{r})) == 0}
+			if err := enc.Encode(row); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitcode.Error
+			}
+		}
+	} else {
+		fmt.Printf("Synthetic snapshots: %d baseline + %d current rows\n\n", len(baseline), len(current))
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "stage\ttime\trows/s\tns/row\tallocs\tB/row\tbudget ns/row\tbudget B/row\t")
+		for _, r := range results {
+			l := budget[r.Stage]
+			fmt.Fprintf(tw, "%s\t%s\t%.0f\t%.0f\t%d\t%.0f\t%.0f\t%.0f\t\n",
+				r.Stage, r.Duration.Round(time.Millisecond), r.RowsPerSec, r.NsPerRow, r.Allocs, r.BytesPerRow, l.MaxNsPerRow, l.MaxBytesPerRow)
+		}
+		tw.Flush()
+	}
+
+	if over := budget.Check(results); len(over) > 0 {
+		for _, msg := range over {
+			fmt.Fprintf(os.Stderr, "Over budget: %s\n", msg)
+		}
+		return exitcode.PolicyFailure
+	}
+	return exitcode.OK
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/render"
+	"github.com/kareemsasa/operating-system-audit/internal/timeline"
+)
+
+// runBlame lists each value one field took across a host's stored snapshots
+// (by default everything under output/), with the snapshot that first
+// recorded it and that run's notes: git blame for machine state.
+func runBlame(repoRoot string, args []string) int {
+	fs := flag.NewFlagSet("blame", flag.ContinueOnError)
+	field := fs.String("field", "", "Field to trace, as <row type>.<field>, e.g. security_config.firewall")
+	host := fs.String("host", "", "Host to report when the snapshots come from several")
+	ndjson := fs.Bool("ndjson", false, "Emit transitions as NDJSON instead of human-readable summary")
+	theme := fs.String("theme", render.ThemeMarkdown, themeUsage)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if *field == "" {
+		fmt.Fprintln(os.Stderr, "blame requires --field")
+		printUsage()
+		return exitcode.Usage
+	}
+	rowType, _, err := timeline.ParseField(*field)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "blame: %v\n", err)
+		return exitcode.Usage
+	}
+	if !validateTheme("blame", *theme, *ndjson) {
+		return exitcode.Usage
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{outputDir(repoRoot)}
+	}
+	name, snaps, code := hostSnapshots("blame", roots, *host)
+	if code != exitcode.OK {
+		return code
+	}
+	// An unreadable snapshot is skipped, as in check --against, rather than
+	// hiding the history of every other one.
+	read := func(path string) ([]diff.Row, error) {
+		rows, err := diff.ReadNDJSON(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+		}
+		return rows, nil
+	}
+	transitions, observed, err := timeline.Blame(snaps, *field, read)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if observed == 0 {
+		fmt.Fprintf(os.Stderr, "blame: none of the %d snapshot(s) of %s has a %s row\n", len(snaps), name, rowType)
+		return exitcode.Error
+	}
+	return withTheme(*theme, func() int {
+		if *ndjson {
+			printBlameNDJSON(name, *field, observed, transitions)
+		} else {
+			printBlameSummary(name, *field, observed, transitions)
+		}
+		return exitcode.OK
+	})
+}
+
+// blameValue formats a transition's value as JSON, so strings stand apart
+// from numbers and booleans.
+func blameValue(t timeline.Transition) string {
+	if t.Missing {
+		return "(missing)"
+	}
+	data, err := json.Marshal(t.Value)
+	if err != nil {
+		return fmt.Sprint(t.Value)
+	}
+	return string(data)
+}
+
+// shortRunID abbreviates a run ID like a commit hash; a snapshot without one
+// is named by its file.
+func shortRunID(s timeline.Snapshot) string {
+	if s.RunID == "" {
+		return s.Path
+	}
+	if len(s.RunID) > 8 {
+		return s.RunID[:8]
+	}
+	return s.RunID
+}
+
+func printBlameSummary(host, field string, observed int, transitions []timeline.Transition) {
+	fmt.Printf("%s\n\n", i18n.T("blame.header", field, host, observed))
+	for i, t := range transitions {
+		value := blameValue(t)
+		if i > 0 {
+			value = blameValue(transitions[i-1]) + " → " + value
+		}
+		fmt.Printf("  %s  %-8s  %s  [%s]\n", t.Time.Local().Format(timelineTimeFormat), shortRunID(t.Snapshot), value, t.Component)
+		for _, n := range t.Notes {
+			fmt.Printf("      note: %s\n", n)
+		}
+	}
+	fmt.Printf("\n%s\n", i18n.T("blame.summary", len(transitions), len(transitions)-1))
+}
+
+func printBlameNDJSON(host, field string, observed int, transitions []timeline.Transition) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for i, t := range transitions {
+		row := map[string]any{
+			"type":      "blame_transition",
+			"host":      host,
+			"field":     field,
+			"run_id":    t.RunID,
+			"snapshot":  t.Path,
+			"audit":     t.Component,
+			"timestamp": t.Time.UTC().Format(time.RFC3339),
+			"value":     t.Value,
+			"missing":   t.Missing,
+			"first":     i == 0,
+		}
+		if i > 0 {
+			prev := transitions[i-1]
+			row["previous"] = prev.Value
+			row["previous_missing"] = prev.Missing
+		}
+		if len(t.Notes) > 0 {
+			row["notes"] = t.Notes
+		}
+		enc.Encode(row)
+	}
+	enc.Encode(map[string]any{
+		"type":        "blame_summary",
+		"host":        host,
+		"field":       field,
+		"snapshots":   observed,
+		"transitions": len(transitions),
+	})
+}
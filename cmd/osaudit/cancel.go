@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// killGrace is how long an audit script gets to finalize its partial snapshot
+// after the signal is passed on, before its process group is killed.
+const killGrace = 10 * time.Second
+
+// interruptError is the cancellation cause of a run stopped by a signal.
+type interruptError struct {
+	sig os.Signal
+}
+
+func (e *interruptError) Error() string { return "interrupted by " + signalName(e.sig) }
+func (e *interruptError) Unwrap() error { return exitcode.ErrInterrupted }
+
+// signalName is the script-side name of sig, as in a bash trap: INT or TERM.
+func signalName(sig os.Signal) string {
+	if sig == syscall.SIGTERM {
+		return "TERM"
+	}
+	return "INT"
+}
+
+// signalContext returns a context canceled, with an *interruptError cause,
+// when osaudit receives SIGINT (Ctrl-C) or SIGTERM (a service stop). Only the
+// first signal is caught: a second one kills osaudit at once. stop releases
+// the signals.
+func signalContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-ch:
+			signal.Stop(ch)
+			fmt.Fprintf(os.Stderr, "\nosaudit: %s received; finishing the partial snapshot (repeat to quit now)\n", signalName(sig))
+			cancel(&interruptError{sig: sig})
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(ch)
+		close(done)
+		cancel(nil)
+	}
+}
+
+// interruptCause returns ctx's *interruptError, or nil when ctx was not
+// interrupted.
+func interruptCause(ctx context.Context) *interruptError {
+	ie, _ := context.Cause(ctx).(*interruptError)
+	return ie
+}
+
+// cancelWithProcessGroup makes canceling ctx pass the interrupting signal on
+// to cmd's whole process group, so a probe the script is waiting on stops
+// too, and kill the group when it has not exited after killGrace.
+func cancelWithProcessGroup(ctx context.Context, cmd *exec.Cmd) {
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		sig := os.Interrupt
+		if ie := interruptCause(ctx); ie != nil {
+			sig = ie.sig
+		}
+		err := signalProcessGroup(cmd.Process, sig)
+		time.AfterFunc(killGrace, func() { _ = killProcessGroup(cmd.Process) })
+		return err
+	}
+	// Background processes the script started may hold its output open.
+	cmd.WaitDelay = killGrace + 2*time.Second
+}
+
+// finalizeInterrupted makes the NDJSON snapshot of an interrupted run valid:
+// a row cut off mid-write is dropped, and a run_summary row marking the run
+// interrupted is appended unless the script wrote one before it exited.
+func finalizeInterrupted(path, runID string, ie *interruptError) error {
+	f, err := writeguard.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if i := bytes.LastIndexByte(data, '\n'); i+1 < len(data) {
+		data = data[:i+1]
+		if err := f.Truncate(int64(len(data))); err != nil {
+			return err
+		}
+	}
+	for line := range bytes.Lines(data) {
+		var row diff.Row
+		if rawjson.Unmarshal(line, &row) == nil && row["type"] == "run_summary" {
+			return nil
+		}
+	}
+	line, err := rawjson.Marshal(map[string]any{"type": "run_summary", "run_id": runID, "status": "interrupted", "signal": signalName(ie.sig)})
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt(append(line, '\n'), int64(len(data)))
+	return err
+}
+
+// finishInterrupted keeps what an interrupted script wrote. When the script
+// got as far as writing its run meta, the NDJSON snapshot the meta names is
+// finalized and the meta captured or printed as for a finished run. It
+// returns ie.
+func finishInterrupted(repoRoot, runMetaPath string, printRunMeta bool, captureMeta *latest.RunMeta, ie *interruptError) error {
+	if runMetaPath == "" {
+		return ie
+	}
+	data, _ := os.ReadFile(runMetaPath)
+	var meta latest.RunMeta
+	if len(bytes.TrimSpace(data)) == 0 || json.Unmarshal(data, &meta) != nil {
+		fmt.Fprintln(os.Stderr, "osaudit: the run stopped before it wrote run meta; no snapshot was kept")
+		return ie
+	}
+	if meta.NDJSON != "" {
+		if err := finalizeInterrupted(repoPath(repoRoot, meta.NDJSON), meta.RunID, ie); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: finalize partial snapshot: %v\n", err)
+		}
+	}
+	if captureMeta != nil {
+		*captureMeta = meta
+	} else if printRunMeta {
+		fmt.Println(string(bytes.TrimSpace(data)))
+	}
+	fmt.Fprintf(os.Stderr, "osaudit: partial snapshot kept in %s\n", meta.Dir)
+	return ie
+}
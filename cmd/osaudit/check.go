@@ -0,0 +1,584 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/benchmark"
+	"github.com/kareemsasa/operating-system-audit/internal/composite"
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/policy"
+	"github.com/kareemsasa/operating-system-audit/internal/render"
+	"github.com/kareemsasa/operating-system-audit/internal/rules"
+)
+
+func runCheck(repoRoot string, args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	benchmarkID := fs.String("benchmark", "", "Benchmark to evaluate (e.g. cis-macos, cis-linux)")
+	policyPath := fs.String("policy", "", "Path to a policy file of CEL rules (YAML or JSON)")
+	snapshot := fs.String("snapshot", "", "Path to snapshot NDJSON file")
+	against := fs.String("against", "", "Dry-run --policy against stored snapshots: store://last-<N>d, store://all, or a snapshot file or directory")
+	rulesDir := fs.String("rules", "", "Directory of custom rule files (default ~/.osaudit/rules)")
+	noRules := fs.Bool("no-rules", false, "Skip custom rules")
+	composites := fs.Bool("composite", false, "Run the built-in detectors that correlate rows of several types")
+	ndjson := fs.Bool("ndjson", false, "Emit per-control results as NDJSON instead of human-readable summary")
+	theme := fs.String("theme", render.ThemeMarkdown, themeUsage)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if !validateTheme("check", *theme, *ndjson) {
+		return exitcode.Usage
+	}
+	if *rulesDir != "" {
+		if info, err := os.Stat(*rulesDir); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "check --rules: %s is not a directory\n", *rulesDir)
+			printUsage()
+			return exitcode.Usage
+		}
+	}
+	if *against != "" {
+		if *snapshot != "" || *benchmarkID != "" || *policyPath == "" {
+			fmt.Fprintln(os.Stderr, "check --against requires --policy and takes no --snapshot or --benchmark")
+			printUsage()
+			return exitcode.Usage
+		}
+		return runCheckHistory(repoRoot, *policyPath, *against, *ndjson, *theme)
+	}
+	if *snapshot == "" {
+		fmt.Fprintln(os.Stderr, "check requires --snapshot")
+		printUsage()
+		return exitcode.Usage
+	}
+
+	var custom []rules.Rule
+	if !*noRules {
+		dir := *rulesDir
+		if dir == "" {
+			dir, _ = config.SubDir(config.RulesDirEnv, "rules")
+		}
+		var err error
+		if custom, err = rules.LoadDir(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+	if *benchmarkID == "" && *policyPath == "" && len(custom) == 0 && !*composites {
+		fmt.Fprintln(os.Stderr, "check requires --benchmark, --policy, --composite, or custom rules in ~/.osaudit/rules")
+		printUsage()
+		return exitcode.Usage
+	}
+
+	var b benchmark.Benchmark
+	if *benchmarkID != "" {
+		var err error
+		if b, err = benchmark.Load(*benchmarkID); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+	var pol policy.Policy
+	if *policyPath != "" {
+		var err error
+		if pol, err = policy.Load(*policyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+	rows, err := diff.ReadNDJSON(*snapshot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if *benchmarkID != "" {
+		if err := b.CheckPlatform(rows); err != nil {
+			fmt.Fprintf(os.Stderr, "check --benchmark: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+
+	return withTheme(*theme, func() int {
+		failed := false
+		if *benchmarkID != "" {
+			res := benchmark.Evaluate(b, rows)
+			if *ndjson {
+				printBenchmarkNDJSON(res)
+			} else {
+				printBenchmarkSummary(res)
+			}
+			failed = res.Fail > 0
+		}
+		if *policyPath != "" {
+			res := policy.Evaluate(pol, rows)
+			publishPolicyFailures(res)
+			if *ndjson {
+				printPolicyNDJSON(res)
+			} else {
+				if *benchmarkID != "" {
+					fmt.Println()
+				}
+				printPolicySummary(res)
+			}
+			failed = failed || res.Violations > 0
+		}
+		if len(custom) > 0 {
+			res := rules.Evaluate(custom, rows)
+			if *ndjson {
+				printRulesNDJSON(res)
+			} else {
+				if *benchmarkID != "" || *policyPath != "" {
+					fmt.Println()
+				}
+				printRulesSummary(res)
+			}
+			failed = failed || res.Fail > 0
+		}
+		if *composites {
+			findings := composite.Run(rows)
+			if *ndjson {
+				printCompositeNDJSON(findings)
+			} else {
+				if *benchmarkID != "" || *policyPath != "" || len(custom) > 0 {
+					fmt.Println()
+				}
+				printCompositeSummary(findings)
+			}
+			failed = failed || len(findings) > 0
+		}
+		if failed {
+			return exitcode.PolicyFailure
+		}
+		return exitcode.OK
+	})
+}
+
+func printBenchmarkSummary(res benchmark.Result) {
+	fmt.Println(i18n.T("check.benchmark.header", res.Benchmark.Title, res.Benchmark.Version, res.Benchmark.ID))
+	for _, cr := range res.Controls {
+		line := fmt.Sprintf("  [%-7s] %s %s", cr.Status, cr.Control.ID, cr.Control.Title)
+		if cr.Reason != "" && cr.Status != benchmark.StatusPass {
+			line += fmt.Sprintf(" (%s)", cr.Reason)
+		}
+		fmt.Println(line)
+		if u := cr.Control.DocURL(); u != "" && cr.Status == benchmark.StatusFail {
+			fmt.Println("              " + i18n.T("check.see", u))
+		}
+	}
+	fmt.Println()
+	fmt.Println(i18n.T("check.compliance", res.Percent(), res.Pass, res.Fail, res.Unknown))
+}
+
+func printBenchmarkNDJSON(res benchmark.Result) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for _, cr := range res.Controls {
+		row := map[string]any{
+			"type":      "check",
+			"check":     "benchmark_control",
+			"benchmark": res.Benchmark.ID,
+			"control":   cr.Control.ID,
+			"title":     cr.Control.Title,
+			"level":     cr.Control.Level,
+			"field":     cr.Control.Check.Field,
+			"status":    cr.Status,
+		}
+		if cr.Actual != nil {
+			row["actual"] = cr.Actual
+		}
+		if cr.Reason != "" {
+			row["reason"] = cr.Reason
+		}
+		if u := cr.Control.DocURL(); u != "" {
+			row["doc_url"] = u
+		}
+		enc.Encode(row)
+	}
+	enc.Encode(map[string]any{
+		"type":           "check",
+		"check":          "benchmark_summary",
+		"benchmark":      res.Benchmark.ID,
+		"version":        res.Benchmark.Version,
+		"pass":           res.Pass,
+		"fail":           res.Fail,
+		"unknown":        res.Unknown,
+		"compliance_pct": math.Round(res.Percent()*100) / 100,
+	})
+}
+
+func printPolicySummary(res policy.Result) {
+	fmt.Println(i18n.T("check.policy.header", res.Policy.Name))
+	for _, rr := range res.Rules {
+		line := fmt.Sprintf("  [%-9s] %s", rr.Status, rr.Rule.ID)
+		if rr.Rule.Severity != "" {
+			line += fmt.Sprintf(" (%s)", rr.Rule.Severity)
+		}
+		if rr.Reason != "" {
+			line += ": " + rr.Reason
+		}
+		fmt.Println(line)
+		if rr.Status == policy.StatusViolation && len(rr.Rows) > 1 {
+			fmt.Println("                " + i18n.T("check.rows", strings.Join(rr.Rows, ", ")))
+		}
+		if u := rr.Rule.DocURL(); u != "" && rr.Status == policy.StatusViolation {
+			fmt.Println("                " + i18n.T("check.see", u))
+		}
+	}
+	fmt.Println()
+	fmt.Println(i18n.T("check.policy.summary", res.Pass, res.Violations, res.Unknown))
+	if sc, ok := res.Policy.Score(res); ok {
+		line := i18n.T("check.policy.score", sc.Score, sc.Grade)
+		if len(sc.CappedBy) > 0 {
+			line += " " + i18n.T("check.policy.capped", strings.Join(sc.CappedBy, ", "))
+		}
+		fmt.Println(line)
+	}
+}
+
+func printPolicyNDJSON(res policy.Result) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for _, rr := range res.Rules {
+		row := map[string]any{
+			"type":   "check",
+			"check":  "policy_rule",
+			"policy": res.Policy.Name,
+			"rule":   rr.Rule.ID,
+			"expr":   rr.Rule.Expr,
+			"status": rr.Status,
+		}
+		if rr.Rule.Severity != "" {
+			row["severity"] = rr.Rule.Severity
+		}
+		if rr.Reason != "" {
+			row["reason"] = rr.Reason
+		}
+		if u := rr.Rule.DocURL(); u != "" {
+			row["doc_url"] = u
+		}
+		if len(rr.Rows) > 0 {
+			row["rows"] = rr.Rows
+		}
+		enc.Encode(row)
+	}
+	summary := map[string]any{
+		"type":       "check",
+		"check":      "policy_summary",
+		"policy":     res.Policy.Name,
+		"pass":       res.Pass,
+		"violations": res.Violations,
+		"unknown":    res.Unknown,
+	}
+	if sc, ok := res.Policy.Score(res); ok {
+		summary["score"] = math.Round(sc.Score*100) / 100
+		summary["grade"] = sc.Grade
+		if len(sc.CappedBy) > 0 {
+			summary["capped_by"] = sc.CappedBy
+		}
+	}
+	enc.Encode(summary)
+}
+
+func printRulesSummary(res rules.Result) {
+	fmt.Println(i18n.T("check.rules.header"))
+	for _, rr := range res.Rules {
+		line := fmt.Sprintf("  [%-7s] %s", rr.Status, rr.Rule.ID)
+		if rr.Rule.Severity != "" {
+			line += fmt.Sprintf(" (%s)", rr.Rule.Severity)
+		}
+		if rr.Reason != "" && rr.Status != benchmark.StatusPass {
+			line += ": " + rr.Reason
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+	fmt.Println(i18n.T("check.rules.summary", res.Pass, res.Fail, res.Unknown))
+}
+
+func printRulesNDJSON(res rules.Result) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for _, rr := range res.Rules {
+		row := map[string]any{
+			"type":   "check",
+			"check":  "custom_rule",
+			"rule":   rr.Rule.ID,
+			"source": rr.Rule.Source,
+			"status": rr.Status,
+		}
+		if rr.Rule.Expr != "" {
+			row["expr"] = rr.Rule.Expr
+		} else {
+			row["field"] = rr.Rule.Field
+		}
+		if rr.Rule.Severity != "" {
+			row["severity"] = rr.Rule.Severity
+		}
+		if rr.Actual != nil {
+			row["actual"] = rr.Actual
+		}
+		if rr.Reason != "" {
+			row["reason"] = rr.Reason
+		}
+		enc.Encode(row)
+	}
+	enc.Encode(map[string]any{
+		"type":    "check",
+		"check":   "custom_rules_summary",
+		"pass":    res.Pass,
+		"fail":    res.Fail,
+		"unknown": res.Unknown,
+	})
+}
+
+func printCompositeSummary(findings []composite.Finding) {
+	fmt.Println(i18n.T("check.composite.header"))
+	for _, f := range findings {
+		fmt.Printf("  [%-4s] %s (%s): %s\n", f.Detector.Severity, f.Detector.ID, f.Subject, f.Message)
+		refs := make([]string, len(f.Refs))
+		for i, r := range f.Refs {
+			refs[i] = r.String()
+		}
+		fmt.Println("         " + i18n.T("check.rows", strings.Join(refs, ", ")))
+		if u := f.Detector.DocURL(); u != "" {
+			fmt.Println("         " + i18n.T("check.see", u))
+		}
+	}
+	fmt.Println()
+	fmt.Println(i18n.T("check.composite.summary", len(findings), len(composite.Detectors())))
+}
+
+func printCompositeNDJSON(findings []composite.Finding) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for _, f := range findings {
+		row := map[string]any{
+			"type":     "check",
+			"check":    "composite_finding",
+			"detector": f.Detector.ID,
+			"title":    f.Detector.Title,
+			"severity": f.Detector.Severity,
+			"subject":  f.Subject,
+			"message":  f.Message,
+			"refs":     f.Refs,
+		}
+		if u := f.Detector.DocURL(); u != "" {
+			row["doc_url"] = u
+		}
+		enc.Encode(row)
+	}
+	enc.Encode(map[string]any{
+		"type":      "check",
+		"check":     "composite_summary",
+		"findings":  len(findings),
+		"detectors": len(composite.Detectors()),
+	})
+}
+
+// storeScheme prefixes check --against sources that name stored runs under
+// output/ rather than a path.
+const storeScheme = "store://"
+
+// historySource resolves a check --against source to the paths to read and
+// the oldest run to include (zero for all). store://last-<N>d (or <N>h) is
+// the stored runs of the last N days (hours), store://all every stored run.
+// Anything else is a snapshot file or a directory of them.
+func historySource(repoRoot, source string, now time.Time) (roots []string, since time.Time, err error) {
+	spec, ok := strings.CutPrefix(source, storeScheme)
+	if !ok {
+		return []string{source}, time.Time{}, nil
+	}
+	roots = []string{outputDir(repoRoot)}
+	if spec == "all" || spec == "" {
+		return roots, time.Time{}, nil
+	}
+	window, ok := strings.CutPrefix(spec, "last-")
+	unit := time.Duration(0)
+	if ok && window != "" {
+		switch window[len(window)-1] {
+		case 'd':
+			unit = 24 * time.Hour
+		case 'h':
+			unit = time.Hour
+		}
+		window = window[:len(window)-1]
+	}
+	n, convErr := strconv.Atoi(window)
+	if unit == 0 || convErr != nil || n <= 0 {
+		return nil, time.Time{}, fmt.Errorf("invalid source %q (want store://last-<N>d, store://last-<N>h, or store://all)", source)
+	}
+	return roots, now.Add(-time.Duration(n) * unit), nil
+}
+
+// snapshotTime returns when the snapshot at path was taken: the timestamp of
+// its run directory (output/<audit>/<YYYYMMDD-HHMMSS>/, with a "-NNN" suffix
+// for later runs in the same second), else its modification time.
+func snapshotTime(path string) time.Time {
+	const layout = "20060102-150405"
+	name := filepath.Base(filepath.Dir(path))
+	if len(name) > len(layout) && name[len(layout)] == '-' {
+		name = name[:len(layout)]
+	}
+	if t, err := time.ParseInLocation(layout, name, time.Local); err == nil {
+		return t
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// runCheckHistory evaluates a policy against every stored snapshot from
+// source and reports how many findings each rule would have produced per
+// host. It is a dry run: it publishes no policy_failed events and exits 0
+// however many rules fire.
+func runCheckHistory(repoRoot, policyPath, source string, ndjson bool, theme string) int {
+	pol, err := policy.Load(policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	roots, since, err := historySource(repoRoot, source, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check: %v\n", err)
+		return exitcode.Usage
+	}
+	paths, err := snapshotPaths(roots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	hist := policy.NewHistory(pol)
+	skipped := 0
+	for _, path := range paths {
+		if !since.IsZero() && snapshotTime(path).Before(since) {
+			continue
+		}
+		rows, err := diff.ReadNDJSON(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %v\n", err)
+			skipped++
+			continue
+		}
+		host := "unknown"
+		for _, r := range rows {
+			if r["type"] == "meta" {
+				if h, _ := r["hostname"].(string); h != "" {
+					host = h
+				}
+				break
+			}
+		}
+		hist.Add(host, policy.Evaluate(pol, rows))
+	}
+	if hist.Snapshots == 0 {
+		fmt.Fprintf(os.Stderr, "check: no snapshots found in %s\n", source)
+		return exitcode.Error
+	}
+	return withTheme(theme, func() int {
+		if ndjson {
+			printPolicyHistoryNDJSON(hist, skipped)
+		} else {
+			printPolicyHistorySummary(hist)
+		}
+		return exitcode.OK
+	})
+}
+
+func printPolicyHistorySummary(hist *policy.History) {
+	hosts := len(hist.Hosts())
+	fmt.Println(i18n.T("check.history.header", hist.Policy.Name, hist.Snapshots, hosts))
+	total, firing := 0, 0
+	for _, rh := range hist.Rules() {
+		line := "  " + rh.Rule.ID
+		if rh.Rule.Severity != "" {
+			line += fmt.Sprintf(" (%s)", rh.Rule.Severity)
+		}
+		violating, unknown := 0, 0
+		for _, t := range rh.Hosts {
+			if t.Violations > 0 {
+				violating++
+			}
+			unknown += t.Unknown
+		}
+		if unknown == hist.Snapshots {
+			line += ": " + i18n.T("check.history.unknown")
+		} else {
+			line += ": " + i18n.T("check.history.rule", rh.Violations, violating, hosts)
+		}
+		fmt.Println(line)
+		for _, t := range rh.Hosts {
+			if t.Violations > 0 {
+				fmt.Println("      " + i18n.T("check.history.host", t.Host, t.Violations, t.Snapshots))
+			}
+		}
+		total += rh.Violations
+		if rh.Violations > 0 {
+			firing++
+		}
+	}
+	fmt.Println()
+	fmt.Println(i18n.T("check.history.summary", total, firing, len(hist.Policy.Rules)))
+}
+
+func printPolicyHistoryNDJSON(hist *policy.History, skipped int) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	total := 0
+	for _, rh := range hist.Rules() {
+		violating, unknown := 0, 0
+		for _, t := range rh.Hosts {
+			if t.Violations > 0 {
+				violating++
+			}
+			unknown += t.Unknown
+			enc.Encode(map[string]any{
+				"type":       "check",
+				"check":      "policy_history_host",
+				"policy":     hist.Policy.Name,
+				"rule":       rh.Rule.ID,
+				"host":       t.Host,
+				"snapshots":  t.Snapshots,
+				"violations": t.Violations,
+				"unknown":    t.Unknown,
+			})
+		}
+		row := map[string]any{
+			"type":            "check",
+			"check":           "policy_history_rule",
+			"policy":          hist.Policy.Name,
+			"rule":            rh.Rule.ID,
+			"expr":            rh.Rule.Expr,
+			"violations":      rh.Violations,
+			"hosts_violating": violating,
+			"unknown":         unknown,
+		}
+		if rh.Rule.Severity != "" {
+			row["severity"] = rh.Rule.Severity
+		}
+		enc.Encode(row)
+		total += rh.Violations
+	}
+	enc.Encode(map[string]any{
+		"type":       "check",
+		"check":      "policy_history_summary",
+		"policy":     hist.Policy.Name,
+		"snapshots":  hist.Snapshots,
+		"hosts":      len(hist.Hosts()),
+		"violations": total,
+		"skipped":    skipped,
+	})
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/benchmark"
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/policy"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// ciCase is one result ci reports: a validation problem, a drift finding, or
+// a benchmark control or policy rule. Findings are the cases that report a
+// problem; failed findings are those at or above --fail-on.
+type ciCase struct {
+	Suite    string // "validate" | "drift" | "check"
+	Name     string
+	Severity string
+	Finding  bool
+	Failed   bool
+	Detail   string
+	DocURL   string
+}
+
+// runCI runs an audit (or reads --snapshot), validates the snapshot, diffs it
+// against a baseline file, optionally evaluates a policy or benchmark, writes
+// JUnit and Markdown artifacts, and exits non-zero only for findings at or
+// above --fail-on: policy failure for check results, drift for diff findings.
+func runCI(commands []auditCommand, repoRoot, detectedOS string, args []string) int {
+	var passthrough []string
+	for i, a := range args {
+		if a == "--" {
+			args, passthrough = args[:i], args[i+1:]
+			break
+		}
+	}
+	fs := flag.NewFlagSet("ci", flag.ContinueOnError)
+	profile := fs.String("profile", fullAuditID, "Audit command to run (see `osaudit list`)")
+	snapshot := fs.String("snapshot", "", "Use this snapshot instead of running the audit")
+	baseline := fs.String("baseline", "", "Baseline snapshot to diff against, e.g. one committed to the repository")
+	updateBaseline := fs.Bool("update-baseline", false, "Write the snapshot to --baseline instead of diffing against it")
+	artifacts := fs.String("artifacts", "", "Directory for junit.xml, summary.md, and snapshot.ndjson")
+	failOn := fs.String("fail-on", "high", "Lowest finding severity that fails the run: high, medium, low, or none")
+	benchmarkID := fs.String("benchmark", "", "Benchmark to evaluate (e.g. cis-macos, cis-linux)")
+	policyPath := fs.String("policy", "", "Path to a policy file of CEL rules (YAML or JSON)")
+	noIgnore := fs.Bool("no-ignore", false, "Report findings suppressed by ignore rules in ~/.osaudit/ignore.json")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if *baseline == "" {
+		fmt.Fprintln(os.Stderr, "ci requires --baseline")
+		printUsage()
+		return exitcode.Usage
+	}
+	if _, ok := diff.SeverityOrder[*failOn]; !ok && *failOn != "none" {
+		fmt.Fprintf(os.Stderr, "Error: --fail-on must be high, medium, low, or none, not %q\n", *failOn)
+		return exitcode.Usage
+	}
+	if _, err := os.Stat(*baseline); err != nil && !*updateBaseline {
+		fmt.Fprintf(os.Stderr, "Error: baseline %s: %v (run with --update-baseline to create it)\n", *baseline, errors.Unwrap(err))
+		return exitcode.Usage
+	}
+
+	var b benchmark.Benchmark
+	if *benchmarkID != "" {
+		var err error
+		if b, err = benchmark.Load(*benchmarkID); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+	var pol policy.Policy
+	if *policyPath != "" {
+		var err error
+		if pol, err = policy.Load(*policyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+	if err := loadClassification(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+
+	var partial error
+	current := *snapshot
+	if current == "" {
+		command, err := findCommandByID(commands, *profile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitcode.Usage
+		}
+		disabled, err := disabledCollectors(collector.Selection{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+		ctx, stop := signalContext()
+		defer stop()
+		var meta latest.RunMeta
+		passthrough = append([]string{"--ndjson"}, passthrough...)
+		partial = runAuditCommand(ctx, repoRoot, command, detectedOS, passthrough, true, &meta, disabled)
+		if partial != nil {
+			fmt.Fprintln(os.Stderr, partial)
+		}
+		if partial != nil && !finishedPartial(partial) {
+			indexSnapshot(repoRoot, meta)
+			return exitcode.Of(partial)
+		}
+		if meta.NDJSON == "" {
+			fmt.Fprintln(os.Stderr, "ci: audit did not produce NDJSON output")
+			return exitcode.Error
+		}
+		if *profile == fullAuditID {
+			if err := mergePlugins(ctx, repoRoot, detectedOS, meta); err != nil {
+				partial = err
+			}
+		}
+		indexSnapshot(repoRoot, meta)
+		if ie := interruptCause(ctx); ie != nil {
+			fmt.Fprintln(os.Stderr, ie)
+			return exitcode.Interrupted
+		}
+		current = repoPath(repoRoot, meta.NDJSON)
+	}
+
+	currentRows, err := diff.ReadNDJSON(current)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if *benchmarkID != "" {
+		if err := b.CheckPlatform(currentRows); err != nil {
+			fmt.Fprintf(os.Stderr, "ci --benchmark: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+	var cases []ciCase
+	for _, p := range validateSnapshot(currentRows) {
+		cases = append(cases, ciCase{Suite: "validate", Name: p, Severity: "high", Finding: true, Failed: true})
+	}
+
+	if *updateBaseline {
+		if err := copyFile(current, *baseline); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Fprintf(os.Stderr, "ci: wrote baseline %s\n", *baseline)
+	} else {
+		baselineRows, err := diff.ReadNDJSON(*baseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		diffBase, diffCurr := baselineRows, currentRows
+		if !*noIgnore {
+			rules, err := loadIgnoreRules()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitcode.Error
+			}
+			if len(rules) > 0 {
+				diffBase = diff.ApplyIgnore(baselineRows, rules.Match)
+				diffCurr = diff.ApplyIgnore(currentRows, rules.Match)
+			}
+		}
+		findings := diff.Findings(diffBase, diffCurr)
+		publishFindings(*profile, findings)
+		cases = append(cases, driftCases(findings)...)
+	}
+
+	if *benchmarkID != "" {
+		res := benchmark.Evaluate(b, currentRows)
+		for _, cr := range res.Controls {
+			c := ciCase{Suite: "check", Name: fmt.Sprintf("%s %s: %s", res.Benchmark.ID, cr.Control.ID, cr.Control.Title), Detail: cr.Reason, DocURL: cr.Control.DocURL()}
+			if cr.Status == benchmark.StatusFail {
+				c.Severity, c.Finding = "high", true
+			}
+			cases = append(cases, c)
+		}
+	}
+	if *policyPath != "" {
+		res := policy.Evaluate(pol, currentRows)
+		publishPolicyFailures(res)
+		for _, rr := range res.Rules {
+			c := ciCase{Suite: "check", Name: fmt.Sprintf("%s %s", res.Policy.Name, rr.Rule.ID), Detail: rr.Reason, DocURL: rr.Rule.DocURL()}
+			if rr.Status == policy.StatusViolation {
+				c.Severity, c.Finding = rr.Rule.Severity, true
+				if c.Severity == "" {
+					c.Severity = "high"
+				}
+			}
+			cases = append(cases, c)
+		}
+	}
+	for i := range cases {
+		if cases[i].Finding && cases[i].Suite != "validate" {
+			cases[i].Failed = severityAtLeast(cases[i].Severity, *failOn)
+		}
+	}
+
+	label := *profile
+	if *snapshot != "" {
+		label = filepath.Base(*snapshot)
+	}
+	code := ciExitCode(cases, partial)
+	summary := ciSummary(label, current, *baseline, *updateBaseline, *failOn, cases, code)
+	fmt.Print(summary)
+	if *artifacts != "" {
+		if err := writeCIArtifacts(*artifacts, current, summary, cases); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+	}
+	return code
+}
+
+// validateSnapshot returns what is wrong with a snapshot's rows: no meta row,
+// a schema this build cannot read, or rows without a type.
+func validateSnapshot(rows []diff.Row) []string {
+	if len(rows) == 0 {
+		return []string{"snapshot has no rows"}
+	}
+	var problems []string
+	hasMeta := false
+	for i, r := range rows {
+		t, _ := r["type"].(string)
+		if t == "" {
+			problems = append(problems, fmt.Sprintf("row %d has no type", i+1))
+		}
+		hasMeta = hasMeta || t == "meta"
+	}
+	if !hasMeta {
+		problems = append(problems, "snapshot has no meta row")
+	} else if v := diff.RowSchemaVersion(rows); v != diff.SchemaVersion {
+		problems = append(problems, fmt.Sprintf("snapshot schema_version %s is not %s", v, diff.SchemaVersion))
+	}
+	return problems
+}
+
+// driftCases turns the rows of a diff into findings.
+func driftCases(rows []diff.Row) []ciCase {
+	var cases []ciCase
+	for _, row := range rows {
+		detail, _ := json.Marshal(row)
+		docURL, _ := row["doc_url"].(string)
+		cases = append(cases, ciCase{
+			Suite:    "drift",
+			Name:     driftTitle(row),
+			Severity: driftSeverity(row),
+			Finding:  true,
+			Detail:   string(detail),
+			DocURL:   docURL,
+		})
+	}
+	return cases
+}
+
+// driftSeverity is a diff row's severity when it has one. Otherwise security
+// changes are high, inventory changes medium, and count changes low.
+func driftSeverity(row diff.Row) string {
+	if s, _ := row["severity"].(string); s != "" {
+		return s
+	}
+	switch row["diff_type"] {
+	case "security_config", "sshd", "security_events":
+		return "high"
+	case "inventory":
+		return "medium"
+	case "install_group":
+		sev := "medium"
+		members, _ := row["changes"].([]any)
+		for _, m := range members {
+			if s, _ := m.(map[string]any)["severity"].(string); s != "" && diff.SeverityOrder[s] < diff.SeverityOrder[sev] {
+				sev = s
+			}
+		}
+		return sev
+	}
+	return "low"
+}
+
+func driftTitle(row diff.Row) string {
+	switch row["diff_type"] {
+	case "probe_failure":
+		return fmt.Sprintf("probe %v %v", row["probe"], row["status"])
+	case "inventory":
+		return fmt.Sprintf("%v %v: %v", row["row_type"], row["status"], row["key"])
+	case "install_group":
+		return fmt.Sprintf("%v %v %v", row["label"], row["version"], row["status"])
+	case "security_config", "sshd", "security_events":
+		return fmt.Sprintf("%v %v: %v → %v", row["diff_type"], row["field"], row["baseline"], row["current"])
+	}
+	return strings.Join(diff.FindingKeysFromDiffRow(row), ", ")
+}
+
+// severityAtLeast reports whether sev is at or above the --fail-on threshold.
+func severityAtLeast(sev, failOn string) bool {
+	if failOn == "none" {
+		return false
+	}
+	rank, ok := diff.SeverityOrder[sev]
+	if !ok {
+		rank = diff.SeverityOrder["low"]
+	}
+	return rank <= diff.SeverityOrder[failOn]
+}
+
+// ciExitCode applies the exit-code precedence to the failed cases: an invalid
+// snapshot is an error, then policy failure, drift, and a partial run.
+func ciExitCode(cases []ciCase, partial error) int {
+	failed := map[string]bool{}
+	for _, c := range cases {
+		if c.Failed {
+			failed[c.Suite] = true
+		}
+	}
+	switch {
+	case failed["validate"]:
+		return exitcode.Error
+	case failed["check"]:
+		return exitcode.PolicyFailure
+	case failed["drift"]:
+		return exitcode.Drift
+	}
+	return exitcode.Of(partial)
+}
+
+func sortedFindings(cases []ciCase) []ciCase {
+	var out []ciCase
+	for _, c := range cases {
+		if c.Finding {
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return diff.SeverityOrder[out[i].Severity] < diff.SeverityOrder[out[j].Severity]
+	})
+	return out
+}
+
+func ciSummary(label, snapshot, baseline string, updated bool, failOn string, cases []ciCase, code int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", i18n.T("ci.header", label))
+	fmt.Fprintf(&b, "- %s\n", i18n.T("ci.snapshot", snapshot))
+	if updated {
+		fmt.Fprintf(&b, "- %s\n", i18n.T("ci.baseline.updated", baseline))
+	} else {
+		fmt.Fprintf(&b, "- %s\n", i18n.T("ci.baseline", baseline))
+	}
+	result := i18n.T("ci.passed")
+	if code != exitcode.OK {
+		result = i18n.T("ci.failed")
+	}
+	fmt.Fprintf(&b, "- %s\n", i18n.T("ci.fail_on", failOn))
+	fmt.Fprintf(&b, "- %s\n\n", i18n.T("ci.result", result, code, exitcode.Name(code)))
+
+	findings := sortedFindings(cases)
+	if len(findings) == 0 {
+		b.WriteString(i18n.T("ci.no_findings") + "\n")
+		return b.String()
+	}
+	b.WriteString(i18n.T("ci.table.header") + "\n")
+	b.WriteString("|----------|--------|---------|------|\n")
+	for _, c := range findings {
+		gate := i18n.T("ci.gate.below")
+		if c.Failed {
+			gate = i18n.T("ci.gate.fail")
+		}
+		name := strings.ReplaceAll(c.Name, "|", `\|`)
+		if c.DocURL != "" {
+			name = fmt.Sprintf("[%s](%s)", name, c.DocURL)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", c.Severity, c.Suite, name, gate)
+	}
+	return b.String()
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ciJUnit renders cases as one JUnit test suite per source. Findings below
+// --fail-on pass, with their details as output.
+func ciJUnit(cases []ciCase) ([]byte, error) {
+	var doc junitSuites
+	for _, suite := range []string{"validate", "drift", "check"} {
+		s := junitSuite{Name: "osaudit." + suite}
+		for _, c := range cases {
+			if c.Suite != suite {
+				continue
+			}
+			tc := junitCase{Name: c.Name, Classname: "osaudit." + suite}
+			detail := c.Detail
+			if c.DocURL != "" && !strings.Contains(detail, c.DocURL) {
+				detail = strings.TrimSpace(detail + "\n" + c.DocURL)
+			}
+			if c.Failed {
+				tc.Failure = &junitFailure{Message: c.Name, Type: c.Severity, Text: detail}
+				s.Failures++
+			} else if c.Finding {
+				tc.SystemOut = fmt.Sprintf("%s severity, below --fail-on\n%s", c.Severity, detail)
+			}
+			s.Cases = append(s.Cases, tc)
+		}
+		if suite == "validate" && len(s.Cases) == 0 {
+			s.Cases = append(s.Cases, junitCase{Name: "snapshot is valid", Classname: "osaudit.validate"})
+		}
+		if len(s.Cases) == 0 {
+			continue
+		}
+		s.Tests = len(s.Cases)
+		doc.Suites = append(doc.Suites, s)
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+func writeCIArtifacts(dir, snapshot, summary string, cases []ciCase) error {
+	if err := writeguard.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	junit, err := ciJUnit(cases)
+	if err != nil {
+		return err
+	}
+	if err := writeguard.WriteFile(filepath.Join(dir, "junit.xml"), junit, 0o644); err != nil {
+		return err
+	}
+	if err := writeguard.WriteFile(filepath.Join(dir, "summary.md"), []byte(summary), 0o644); err != nil {
+		return err
+	}
+	return copyFile(snapshot, filepath.Join(dir, "snapshot.ndjson"))
+}
+
+// copyFile copies src to dst through a temporary file, so dst is never
+// left half-written.
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(dst); dir != "" {
+		if err := writeguard.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := dst + ".tmp"
+	if err := writeguard.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return writeguard.Rename(tmp, dst)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+)
+
+// disabledCollectors resolves the disabled collector IDs from
+// ~/.osaudit/collectors.yaml, the collectors in config.yaml under it, and the
+// --enable/--disable flags in flags.
+func disabledCollectors(flags collector.Selection) ([]string, error) {
+	file := userSettings.Collectors
+	if dir, err := config.Dir(); err == nil {
+		s, err := collector.LoadSelection(filepath.Join(dir, "collectors.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		if len(s.Enable) > 0 {
+			file.Enable = s.Enable
+		}
+		file.Disable = append(slices.Clip(file.Disable), s.Disable...)
+	}
+	return collector.Resolve(file, flags)
+}
+
+// applyScope prepares a run scoped to paths: it checks that run id can be
+// scoped and exports the paths, made absolute, in collector.ScopeEnv. The full
+// audit and --all run only the scoped collectors, so for them it returns
+// disabled with every other collector added.
+func applyScope(id string, paths, disabled []string, detectedOS string) ([]string, error) {
+	if detectedOS == "windows" {
+		return nil, errors.New("--scope is not supported on Windows")
+	}
+	var scoped []string
+	for _, c := range collector.All() {
+		if c.Scoped {
+			scoped = append(scoped, c.ID)
+		} else if (id == fullAuditID || id == "--all") && !slices.Contains(disabled, c.ID) {
+			disabled = append(disabled, c.ID)
+		}
+	}
+	if c, ok := collector.Lookup(id); id != fullAuditID && id != "--all" && (!ok || !c.Scoped) {
+		return nil, fmt.Errorf("%s cannot be scoped; --scope works with %s, %s, and --all", id, strings.Join(scoped, ", "), fullAuditID)
+	}
+	sort.Strings(disabled)
+
+	abs := make([]string, len(paths))
+	for i, p := range paths {
+		if rest, ok := strings.CutPrefix(p, "~"); ok && (rest == "" || os.IsPathSeparator(rest[0])) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			p = home + rest
+		}
+		a, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		if strings.ContainsRune(a, os.PathListSeparator) {
+			return nil, fmt.Errorf("scope path %s contains %q", a, os.PathListSeparator)
+		}
+		if _, err := os.Stat(a); err != nil {
+			return nil, fmt.Errorf("scope: %w", err)
+		}
+		abs[i] = a
+	}
+	if err := os.Setenv(collector.ScopeEnv, strings.Join(abs, string(os.PathListSeparator))); err != nil {
+		return nil, err
+	}
+	return disabled, nil
+}
+
+// runCollectors lists the built-in collectors and whether the current
+// configuration runs them. --enable and --disable preview a run's flags.
+func runCollectors(args []string) int {
+	fs := flag.NewFlagSet("collectors", flag.ContinueOnError)
+	enable := fs.String("enable", "", "Comma-separated collector IDs to run instead of all")
+	disable := fs.String("disable", "", "Comma-separated collector IDs to skip")
+	ndjson := fs.Bool("ndjson", false, "Emit one JSON object per collector")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	disabled, err := disabledCollectors(collector.Selection{Enable: collector.ParseList(*enable), Disable: collector.ParseList(*disable)})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	off := make(map[string]bool, len(disabled))
+	for _, id := range disabled {
+		off[id] = true
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, c := range collector.All() {
+		status := "enabled"
+		if off[c.ID] {
+			status = "disabled"
+		}
+		if *ndjson {
+			row := struct {
+				collector.Collector
+				Status string `json:"status"`
+			}{c, status}
+			if err := enc.Encode(row); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitcode.Error
+			}
+			continue
+		}
+		fmt.Printf("%s\t%s\t%s\n", c.ID, status, c.Reads)
+	}
+	return exitcode.OK
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/deprecation"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/settings"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
+)
+
+// configFile is a YAML file in the configuration directory that `osaudit
+// config` edits, validated against the type its loader reads it into.
+type configFile struct {
+	name     string
+	format   string // deprecation format
+	validate func(doc any) error
+}
+
+// configFiles are the editable files, by the first segment of a key:
+// "collectors.disable" is the disable list in collectors.yaml.
+var configFiles = map[string]configFile{
+	"collectors": {"collectors.yaml", deprecation.Collectors, func(doc any) error {
+		var s collector.Selection
+		if err := decodeConfig(doc, &s); err != nil {
+			return err
+		}
+		return s.Validate()
+	}},
+	"classify": {"classify.yaml", deprecation.Classification, func(doc any) error {
+		var c diff.Classification
+		if err := decodeConfig(doc, &c); err != nil {
+			return err
+		}
+		return c.Validate()
+	}},
+	"config": {settings.File, deprecation.Settings, func(doc any) error {
+		var s settings.Settings
+		if err := decodeConfig(doc, &s); err != nil {
+			return err
+		}
+		return s.Validate()
+	}},
+}
+
+// decodeConfig decodes doc into v like yamlite.Unmarshal, but rejects fields
+// v does not have, so a misspelled key fails instead of being ignored.
+func decodeConfig(doc any, v any) error {
+	if doc == nil {
+		return nil
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+func configFileNames() string {
+	names := make([]string, 0, len(configFiles))
+	for n := range configFiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func runConfig(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "set":
+			return runConfigSet(args[1:], true)
+		case "unset":
+			return runConfigSet(args[1:], false)
+		case "edit":
+			return runConfigEdit(args[1:])
+		case "env":
+			return runConfigEnv(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "config requires a subcommand: set, unset, edit, or env")
+	printUsage()
+	return exitcode.Usage
+}
+
+// runConfigSet sets or unsets one key and rewrites the file, after validating
+// the result. Values are JSON, or YAML scalars and flow collections:
+// `high`, `3`, `[execution, network]`.
+func runConfigSet(args []string, set bool) int {
+	name, want, usage := "config unset", 1, "config unset requires a key"
+	if set {
+		name, want, usage = "config set", 2, "config set requires a key and a value"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if fs.NArg() != want {
+		fmt.Fprintln(os.Stderr, usage)
+		printUsage()
+		return exitcode.Usage
+	}
+	file, path, err := parseConfigKey(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	var value any
+	if set {
+		if value, err = parseConfigValue(fs.Arg(1)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+
+	dir, err := config.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	target := filepath.Join(dir, file.name)
+	doc, err := readConfigDoc(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if set {
+		doc = setConfigKey(doc, path, value)
+	} else if !unsetConfigKey(doc, path) {
+		return exitcode.OK // already unset
+	}
+	if err := writeConfigDoc(target, file, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", target, err)
+		return exitcode.Usage
+	}
+	return exitcode.OK
+}
+
+// parseConfigKey splits a key into its file and the key path inside it.
+// Segments are separated by dots; a segment in brackets may contain dots, as
+// probe names do: classify.severity.exact[config.fdesetup_status].
+func parseConfigKey(key string) (configFile, []string, error) {
+	var segs []string
+	for rest := key; rest != ""; {
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return configFile{}, nil, fmt.Errorf("key %q: unterminated [", key)
+			}
+			segs = append(segs, rest[1:end])
+			rest = strings.TrimPrefix(rest[end+1:], ".")
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			segs = append(segs, rest[:end])
+			rest = strings.TrimPrefix(rest[end:], ".")
+		}
+	}
+	for _, s := range segs {
+		if s == "" {
+			return configFile{}, nil, fmt.Errorf("key %q has an empty segment", key)
+		}
+	}
+	if len(segs) < 2 {
+		return configFile{}, nil, fmt.Errorf("key %q must name a file and a field, e.g. collectors.disable", key)
+	}
+	file, ok := configFiles[segs[0]]
+	if !ok {
+		return configFile{}, nil, fmt.Errorf("key %q: unknown config file %q (known: %s)", key, segs[0], configFileNames())
+	}
+	return file, segs[1:], nil
+}
+
+func parseConfigValue(text string) (any, error) {
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err == nil {
+		return v, nil
+	}
+	return yamlite.ParseValue(text)
+}
+
+// readConfigDoc reads a config file as a mapping. A missing or empty file is
+// an empty one.
+func readConfigDoc(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := yamlite.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if doc == nil {
+		return map[string]any{}, nil
+	}
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: top level is not a mapping", path)
+	}
+	return m, nil
+}
+
+// setConfigKey sets path in doc to value, replacing whatever is in the way.
+func setConfigKey(doc map[string]any, path []string, value any) map[string]any {
+	m := doc
+	for _, seg := range path[:len(path)-1] {
+		next, ok := m[seg].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[seg] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+	return doc
+}
+
+// unsetConfigKey removes path from doc, and the mappings it leaves empty. It
+// reports whether path was set.
+func unsetConfigKey(m map[string]any, path []string) bool {
+	if len(path) == 1 {
+		_, ok := m[path[0]]
+		delete(m, path[0])
+		return ok
+	}
+	next, ok := m[path[0]].(map[string]any)
+	if !ok || !unsetConfigKey(next, path[1:]) {
+		return false
+	}
+	if len(next) == 0 {
+		delete(m, path[0])
+	}
+	return true
+}
+
+// writeConfigDoc validates doc as file and atomically replaces path with it.
+func writeConfigDoc(path string, file configFile, doc any) error {
+	if err := file.validate(doc); err != nil {
+		return err
+	}
+	for _, w := range deprecation.Find(file.format, path, doc) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	data, err := yamlite.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := writeguard.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := writeguard.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := writeguard.Rename(tmp, path); err != nil {
+		writeguard.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// runConfigEdit opens a copy of a config file in $VISUAL or $EDITOR and
+// replaces the file with it only when it parses and validates. An invalid
+// copy is kept so the edits are not lost.
+func runConfigEdit(args []string) int {
+	fs := flag.NewFlagSet("config edit", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "config edit requires a file: %s\n", configFileNames())
+		printUsage()
+		return exitcode.Usage
+	}
+	file, ok := configFiles[strings.TrimSuffix(fs.Arg(0), ".yaml")]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown config file %q (known: %s)\n", fs.Arg(0), configFileNames())
+		return exitcode.Usage
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	target := filepath.Join(dir, file.name)
+	original, err := os.ReadFile(target)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+
+	tmp, err := writeguard.CreateTemp("", "osaudit-*-"+file.name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	tmp.Write(original)
+	tmp.Close()
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor variable may carry arguments, e.g. "code --wait".
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], tmp.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		writeguard.Remove(tmp.Name())
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", editor, err)
+		return exitcode.Error
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err == nil && bytes.Equal(edited, original) {
+		writeguard.Remove(tmp.Name())
+		return exitcode.OK
+	}
+	if err == nil {
+		err = validateConfigData(file, target, edited)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nYour edits were not saved; they are in %s\n", err, tmp.Name())
+		return exitcode.Usage
+	}
+	if err := writeguard.MkdirAll(dir, 0o755); err == nil {
+		err = writeguard.WriteFile(target, edited, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nYour edits were not saved; they are in %s\n", err, tmp.Name())
+		return exitcode.Error
+	}
+	writeguard.Remove(tmp.Name())
+	return exitcode.OK
+}
+
+// validateConfigData parses and validates an edited file. The file is saved
+// as written, so its comments are kept.
+func validateConfigData(file configFile, path string, data []byte) error {
+	doc, err := yamlite.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := file.validate(doc); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, w := range deprecation.Find(file.format, path, doc) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	return nil
+}
+
+// envSetting is one environment variable `osaudit config env` documents.
+type envSetting struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Set         bool   `json:"set"`
+}
+
+// envSettings lists every OSAUDIT_* variable that configures osaudit, in
+// precedence order within each file: the whole-file variable, then its keys.
+func envSettings() []envSetting {
+	out := []envSetting{
+		{Name: "OSAUDIT_HOME", Description: "configuration directory (default ~/.osaudit)"},
+		{Name: "OSAUDIT_ROOT", Description: "directory holding cli/commands.json and the audit scripts"},
+		{Name: "OSAUDIT_LANG", Description: "message locale, before LC_ALL, LC_MESSAGES, and LANG"},
+		{Name: "OSAUDIT_DOCS_URL", Description: "base URL of finding documentation links"},
+	}
+	for _, f := range config.Files {
+		out = append(out, envSetting{Name: config.EnvName(f.Name, ""), Description: "whole " + f.Name + ".yaml document, replacing the file"})
+		for _, k := range f.Keys {
+			desc := strings.TrimSuffix(k, "[]") + " in " + f.Name + ".yaml"
+			if strings.HasSuffix(k, "[]") {
+				desc += " (list; comma-separated)"
+			}
+			out = append(out, envSetting{Name: config.EnvName(f.Name, k), Description: desc})
+		}
+	}
+	out = append(out,
+		envSetting{Name: config.IgnoreEnv, Description: "ignore patterns, replacing ignore.json (list; comma-separated)"},
+		envSetting{Name: config.ExcludeEnv, Description: "paths filesystem walkers skip, replacing <OSAUDIT_HOME>/exclude (one pattern per line)"},
+		envSetting{Name: config.DotfilesEnv, Description: "dotfiles whose hash and mode the config collector records, replacing <OSAUDIT_HOME>/dotfiles (one path per line)"},
+		envSetting{Name: config.RulesDirEnv, Description: "custom rules directory (default <OSAUDIT_HOME>/rules)"},
+		envSetting{Name: config.PluginsDirEnv, Description: "collector plugins directory (default <OSAUDIT_HOME>/plugins)"},
+		envSetting{Name: config.EventsEnv, Description: "file to append run, probe, finding, and policy events to as NDJSON (\"-\" for stderr)"},
+		envSetting{Name: config.MemoryLimitEnv, Description: "memory for snapshot rows in merge, diff, and compare before they spill to temporary files (default 256M)"},
+		envSetting{Name: config.HashAlgEnv, Description: "hash algorithm for file-integrity snapshots: blake3 (default) or sha256"},
+		envSetting{Name: config.ContextEnv, Description: "context whose baselines diffs use, e.g. office, instead of the one <OSAUDIT_HOME>/contexts.yaml matches"},
+		envSetting{Name: writeguard.Env, Description: "directory a forensic run confines writes to; set by --forensic for the processes it starts"},
+	)
+	for i := range out {
+		_, out[i].Set = os.LookupEnv(out[i].Name)
+	}
+	return out
+}
+
+// runConfigEnv lists the environment variables that configure osaudit and
+// which of them are set. Values are not printed; they may hold secrets.
+func runConfigEnv(args []string) int {
+	fs := flag.NewFlagSet("config env", flag.ContinueOnError)
+	ndjson := fs.Bool("ndjson", false, "Emit one JSON object per variable")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range envSettings() {
+		if *ndjson {
+			if err := enc.Encode(e); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitcode.Error
+			}
+			continue
+		}
+		status := "unset"
+		if e.Set {
+			status = "set"
+		}
+		fmt.Printf("%s\t%s\t%s\n", e.Name, status, e.Description)
+	}
+	return exitcode.OK
+}
@@ -0,0 +1,18 @@
+package main
+
+import "path/filepath"
+
+// contextBaselinePath is where the last full run in context is recorded,
+// beside the audit's .latest.json.
+func contextBaselinePath(repoRoot, auditRoot, context string) string {
+	return filepath.Join(repoPath(repoRoot, auditRoot), ".context", context+".json")
+}
+
+// contextBaselineName is the file of baseline name for context in a baseline
+// repository: <name>@<context>.ndjson, or <name>.ndjson without a context.
+func contextBaselineName(name, context string) string {
+	if context == "" {
+		return name + ".ndjson"
+	}
+	return name + "@" + context + ".ndjson"
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/crosscheck"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/render"
+)
+
+// runCrosscheck compares the users, packages, and services Ansible or
+// Terraform expects on a host, or the device state Jamf or Intune reports, with
+// a snapshot of it.
+func runCrosscheck(args []string) int {
+	fs := flag.NewFlagSet("crosscheck", flag.ContinueOnError)
+	ansible := fs.String("ansible", "", "Ansible facts JSON (setup module output, fact cache entry, or ansible-inventory --host)")
+	terraform := fs.String("terraform", "", "Terraform state file (terraform state pull)")
+	jamf := fs.String("jamf", "", "Jamf Pro computer inventory export (computers-inventory API JSON or advanced search CSV)")
+	intune := fs.String("intune", "", "Intune device export (Graph managedDevices JSON or devices CSV)")
+	device := fs.String("device", "", "Device name or serial number to select from an MDM export (default: the snapshot's hostname)")
+	maxAge := fs.Duration("max-age", 7*24*time.Hour, "Report MDM inventory older than this when the snapshot was taken as stale (0 disables)")
+	missingOnly := fs.Bool("missing-only", false, "Report only resources the tool expects but the snapshot lacks, not unmanaged ones")
+	ndjson := fs.Bool("ndjson", false, "Emit findings as NDJSON instead of human-readable summary")
+	theme := fs.String("theme", render.ThemeMarkdown, themeUsage)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	sources := 0
+	for _, path := range []string{*ansible, *terraform, *jamf, *intune} {
+		if path != "" {
+			sources++
+		}
+	}
+	if sources != 1 || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "crosscheck requires one of --ansible, --terraform, --jamf, or --intune, and one snapshot")
+		printUsage()
+		return exitcode.Usage
+	}
+	if !validateTheme("crosscheck", *theme, *ndjson) {
+		return exitcode.Usage
+	}
+
+	rows, err := diff.ReadNDJSON(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	var exp crosscheck.Expected
+	var dev crosscheck.Device
+	switch {
+	case *ansible != "":
+		exp, err = crosscheck.LoadAnsible(*ansible)
+	case *terraform != "":
+		exp, err = crosscheck.LoadTerraform(*terraform)
+	case *jamf != "":
+		exp, dev, err = crosscheck.LoadMDM(*jamf, "jamf", *device, snapshotHostname(rows))
+	default:
+		exp, dev, err = crosscheck.LoadMDM(*intune, "intune", *device, snapshotHostname(rows))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+
+	var res crosscheck.Result
+	if *jamf != "" || *intune != "" {
+		res = crosscheck.CompareMDM(exp, dev, rows, *maxAge)
+	} else {
+		res = crosscheck.Compare(exp, rows)
+	}
+	if *missingOnly {
+		kept := res.Findings[:0]
+		for _, f := range res.Findings {
+			if f.Status != crosscheck.StatusUnmanaged {
+				kept = append(kept, f)
+			}
+		}
+		res.Findings = kept
+	}
+	return withDiffTheme(*theme, func() int {
+		if *ndjson {
+			printCrosscheckNDJSON(exp, res)
+		} else {
+			printCrosscheckSummary(exp, res)
+		}
+		if len(res.Findings) > 0 {
+			return exitcode.Drift
+		}
+		return exitcode.OK
+	})
+}
+
+var crosscheckTitles = map[string]string{
+	crosscheck.KindUser:    "Users",
+	crosscheck.KindPackage: "Packages",
+	crosscheck.KindService: "Services",
+	crosscheck.KindApp:     "Apps",
+	crosscheck.KindSetting: "Settings",
+}
+
+func snapshotHostname(rows []diff.Row) string {
+	host, _ := metaField(rows, "hostname").(string)
+	return host
+}
+
+func printCrosscheckSummary(exp crosscheck.Expected, res crosscheck.Result) {
+	fmt.Println(i18n.T("crosscheck.header", exp.Source))
+	for _, kind := range res.Compared {
+		counts := map[string]int{}
+		for _, f := range res.Findings {
+			if f.Kind == kind {
+				counts[f.Status]++
+			}
+		}
+		if kind == crosscheck.KindSetting {
+			fmt.Printf("\n%s\n", i18n.T("crosscheck.settings", crosscheckTitles[kind], counts[crosscheck.StatusMismatch], counts[crosscheck.StatusStale]))
+		} else {
+			fmt.Printf("\n%s\n", i18n.T("crosscheck.items", crosscheckTitles[kind],
+				counts[crosscheck.StatusMissing], counts[crosscheck.StatusUnmanaged], counts[crosscheck.StatusVersion]))
+		}
+		for _, f := range res.Findings {
+			if f.Kind != kind {
+				continue
+			}
+			switch f.Status {
+			case crosscheck.StatusMissing:
+				fmt.Printf("  - %s (listed by %s, not on the host)\n", f.Name, exp.Source)
+			case crosscheck.StatusUnmanaged:
+				fmt.Printf("  + %s (on the host, unknown to %s)\n", f.Name, exp.Source)
+			case crosscheck.StatusVersion:
+				fmt.Printf("  ~ %s: expected %s, found %s\n", f.Name, f.Expected, f.Actual)
+			case crosscheck.StatusMismatch:
+				fmt.Printf("  ~ %s: %s reports %s, found %s\n", f.Name, exp.Source, f.Expected, f.Actual)
+			case crosscheck.StatusStale:
+				fmt.Printf("  ! %s: %s inventory last updated %s, not %s of the snapshot\n", f.Name, exp.Source, f.Actual, f.Expected)
+			}
+		}
+	}
+	if len(res.Partial) > 0 {
+		fmt.Printf("\n%s\n", i18n.T("crosscheck.partial", pluralKinds(res.Partial)))
+	}
+	if len(res.Skipped) > 0 {
+		fmt.Printf("\n%s\n", i18n.T("crosscheck.skipped", pluralKinds(res.Skipped)))
+	}
+	if len(res.Findings) == 0 {
+		fmt.Printf("\n%s\n", i18n.T("crosscheck.none"))
+	}
+}
+
+func pluralKinds(kinds []string) string {
+	out := make([]string, len(kinds))
+	for i, k := range kinds {
+		out[i] = k + "s"
+	}
+	return strings.Join(out, ", ")
+}
+
+func printCrosscheckNDJSON(exp crosscheck.Expected, res crosscheck.Result) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for _, f := range res.Findings {
+		row := map[string]any{
+			"type":   "crosscheck",
+			"source": exp.Source,
+			"kind":   f.Kind,
+			"name":   f.Name,
+			"status": f.Status,
+		}
+		if f.Expected != "" {
+			row["expected"] = f.Expected
+			row["actual"] = f.Actual
+		}
+		enc.Encode(row)
+	}
+	summary := map[string]any{
+		"type":     "crosscheck_summary",
+		"source":   exp.Source,
+		"compared": nonNil(res.Compared),
+		"skipped":  nonNil(res.Skipped),
+		"partial":  nonNil(res.Partial),
+		"findings": len(res.Findings),
+	}
+	enc.Encode(summary)
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// deviceCollectors are the collectors the device run enables by default: the
+// security collector lists the USB devices and the mounted removable volumes,
+// and is fast enough to run on every attach.
+const deviceCollectors = "security"
+
+// alertClasses are the USB classes a device run notifies about when they
+// appear: a HID device can type commands, and mass storage carries files.
+var alertClasses = []string{"hid", "mass_storage"}
+
+// deviceUnit and deviceLabel name the systemd user units and the
+// LaunchAgent that run auditID when a device is attached.
+func deviceUnit(auditID string) string  { return "osaudit-" + auditID + "-device" }
+func deviceLabel(auditID string) string { return "com.osaudit." + auditID + ".device" }
+
+// devicePaths are what the Linux path unit watches: a device node appears
+// under /dev/bus/usb/<bus> for every USB device, under /dev/disk/by-id and
+// /dev/input/by-id for drives and input devices, and the desktop mounts
+// drives under /run/media/<user> or /media/<user>. systemd watches the
+// parent of a path that does not exist yet.
+func devicePaths(user string) []string {
+	buses, _ := filepath.Glob("/dev/bus/usb/[0-9]*")
+	paths := append(buses, "/dev/disk/by-id", "/dev/input/by-id")
+	if user != "" {
+		paths = append(paths, "/run/media/"+user, "/media/"+user)
+	}
+	return paths
+}
+
+// deviceInstall installs a run of auditID with only the enabled collectors
+// whenever a device is attached: a systemd path unit watching the device
+// nodes and the desktop's mount points on Linux, and a LaunchAgent with
+// StartOnMount on macOS, where launchd offers plain programs no USB trigger,
+// so a device that mounts nothing waits for the next daily or login run.
+func deviceInstall(repoRoot, auditID, detectedOS, enable string) int {
+	enabled := collector.ParseList(enable)
+	if _, err := disabledCollectors(collector.Selection{Enable: enabled}); err != nil {
+		fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+		return exitcode.Usage
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+		return exitcode.Error
+	}
+	exe, _ = filepath.EvalSymlinks(exe)
+	exe, _ = filepath.Abs(exe)
+	args := []string{"run-scheduled", auditID, "--enable", strings.Join(enabled, ","), "--", "--redact-all"}
+
+	switch detectedOS {
+	case "linux":
+		configDir := filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user")
+		if err := writeguard.MkdirAll(configDir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		unitName := deviceUnit(auditID)
+		servicePath := filepath.Join(configDir, unitName+".service")
+		pathPath := filepath.Join(configDir, unitName+".path")
+		// The sleep lets the desktop mount a drive that was just attached,
+		// so the one run sees both the device and its volumes.
+		serviceContent := fmt.Sprintf(`[Unit]
+Description=OS Audit device run (%s: %s)
+
+[Service]
+Type=oneshot
+SuccessExitStatus=%d %d
+KillMode=process
+WorkingDirectory=%s
+Environment=OSAUDIT_ROOT=%s
+ExecStartPre=/bin/sleep 3
+ExecStart=%s %s
+`, auditID, strings.Join(enabled, ", "), exitcode.Drift, exitcode.Partial, repoRoot, repoRoot, exe, strings.Join(args, " "))
+		var watch strings.Builder
+		for _, p := range devicePaths(os.Getenv("USER")) {
+			fmt.Fprintf(&watch, "PathChanged=%s\n", p)
+		}
+		pathContent := fmt.Sprintf(`[Unit]
+Description=OS Audit device watch (%s)
+
+[Path]
+%sUnit=%s.service
+
+[Install]
+WantedBy=default.target
+`, auditID, watch.String(), unitName)
+		if err := writeguard.WriteFile(servicePath, []byte(serviceContent), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		if err := writeguard.WriteFile(pathPath, []byte(pathContent), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Printf("Installed. Reload and enable with:\n  systemctl --user daemon-reload\n  systemctl --user enable --now %s.path\n", unitName)
+		return exitcode.OK
+
+	case "mac":
+		agentsDir := filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents")
+		if err := writeguard.MkdirAll(agentsDir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		label := deviceLabel(auditID)
+		plistPath := filepath.Join(agentsDir, label+".plist")
+		var argXML strings.Builder
+		for _, a := range append([]string{exe}, args...) {
+			fmt.Fprintf(&argXML, "\t\t<string>%s</string>\n", a)
+		}
+		plistContent := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>OSAUDIT_ROOT</key>
+		<string>%s</string>
+	</dict>
+	<key>StartOnMount</key>
+	<true/>
+	<key>ThrottleInterval</key>
+	<integer>10</integer>
+	<key>LowPriorityIO</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>/dev/null</string>
+	<key>StandardErrorPath</key>
+	<string>/dev/null</string>
+</dict>
+</plist>
+`, label, argXML.String(), repoRoot, repoRoot)
+		if err := writeguard.WriteFile(plistPath, []byte(plistContent), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Printf("Installed. It runs whenever a volume is mounted; load it now with: launchctl load %s\n", plistPath)
+		return exitcode.OK
+	}
+
+	fmt.Fprintln(os.Stderr, "schedule install: unsupported OS")
+	return exitcode.Error
+}
+
+func deviceUninstall(auditID, detectedOS string) int {
+	switch detectedOS {
+	case "linux":
+		unitName := deviceUnit(auditID)
+		exec.Command("systemctl", "--user", "disable", "--now", unitName+".path").Run()
+		configDir := filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user")
+		writeguard.Remove(filepath.Join(configDir, unitName+".path"))
+		writeguard.Remove(filepath.Join(configDir, unitName+".service"))
+		fmt.Printf("Uninstalled %s\n", unitName)
+		return exitcode.OK
+	case "mac":
+		label := deviceLabel(auditID)
+		plistPath := filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents", label+".plist")
+		exec.Command("launchctl", "unload", plistPath).Run()
+		writeguard.Remove(plistPath)
+		fmt.Printf("Uninstalled %s\n", label)
+		return exitcode.OK
+	}
+	fmt.Fprintln(os.Stderr, "schedule uninstall: unsupported OS")
+	return exitcode.Error
+}
+
+func deviceStatus(auditID, detectedOS string) int {
+	var path string
+	switch detectedOS {
+	case "linux":
+		path = filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user", deviceUnit(auditID)+".path")
+	case "mac":
+		path = filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents", deviceLabel(auditID)+".plist")
+	default:
+		fmt.Fprintln(os.Stderr, "schedule status: unsupported OS")
+		return exitcode.Error
+	}
+	if _, err := os.Stat(path); err != nil {
+		fmt.Printf("%s on device attach: not installed\n", auditID)
+		return exitcode.OK
+	}
+	fmt.Printf("%s on device attach: installed (%s)\n", auditID, path)
+	return exitcode.OK
+}
+
+// newUSBDevices describes the USB devices in current that present a HID or
+// mass-storage class they did not in baseline, including devices baseline
+// lacks, as "product (vendor_id:product_id): classes".
+func newUSBDevices(baseline, current []diff.Row) []string {
+	var out []string
+	for _, c := range diff.BuildInventoryChanges(baseline, current) {
+		if c.RowType != "usb_devices" || c.Status == "removed" {
+			continue
+		}
+		was := stringItems(c.Base["classes"])
+		var gained []string
+		for _, class := range stringItems(c.Item["classes"]) {
+			if slices.Contains(alertClasses, class) && !slices.Contains(was, class) {
+				gained = append(gained, class)
+			}
+		}
+		if len(gained) == 0 {
+			continue
+		}
+		id := fmt.Sprintf("%v:%v", c.Item["vendor_id"], c.Item["product_id"])
+		if product, _ := c.Item["product"].(string); product != "" {
+			id = product + " (" + id + ")"
+		}
+		out = append(out, id+": "+strings.Join(gained, ", "))
+	}
+	return out
+}
+
+// notifyNewDevices raises one notification naming the new devices.
+func notifyNewDevices(repoRoot, auditRoot string, devices []string) {
+	notify(repoRoot, auditRoot, i18n.T("notify.device.title"), i18n.T("notify.device.body", strings.Join(devices, "; ")))
+}
+
+func stringItems(v any) []string {
+	items, _ := v.([]any)
+	out := make([]string, 0, len(items))
+	for _, it := range items {
+		if s, ok := it.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
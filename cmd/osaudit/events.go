@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/events"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/policy"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// bus carries this process's events from the runner, diff, and policy checks
+// to the sinks subscribed to it.
+var bus = &events.Bus{}
+
+// startEventLog subscribes the $OSAUDIT_EVENTS exporter, which appends every
+// event to a file as one NDJSON line. The returned function closes the file.
+func startEventLog() (stop func(), err error) {
+	path, ok := os.LookupEnv(config.EventsEnv)
+	if !ok || path == "" {
+		return func() {}, nil
+	}
+	var w io.Writer = os.Stderr
+	var f *os.File
+	if path != "-" {
+		f, err = writeguard.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", config.EventsEnv, err)
+		}
+		w = f
+	}
+	enc := rawjson.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	unsubscribe := bus.Subscribe(func(e events.Event) { _ = enc.Encode(e) })
+	return func() {
+		unsubscribe()
+		if f != nil {
+			f.Close()
+		}
+	}, nil
+}
+
+// forwardScriptEvents hands cmd a pipe as its event fd and publishes what the
+// script writes to it. Call the returned function once cmd has exited. Windows
+// cannot pass extra descriptors, so scripts there publish nothing.
+func forwardScriptEvents(cmd *exec.Cmd, auditID string) (wait func(), err error) {
+	if runtime.GOOS == "windows" {
+		return func() {}, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create event pipe: %w", err)
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	cmd.Env = append(cmd.Env, events.FDEnv+"="+strconv.Itoa(2+len(cmd.ExtraFiles)))
+	done := make(chan struct{})
+	go func() {
+		_ = events.Forward(bus, r, events.Event{AuditID: auditID})
+		close(done)
+	}()
+	return func() {
+		w.Close()
+		// A background process the script left behind may still hold the
+		// pipe open; don't wait on it.
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+		r.Close()
+		<-done
+	}, nil
+}
+
+// publishRunFinished publishes the end of a run that started at start and
+// ended with err.
+func publishRunFinished(auditID string, start time.Time, err error) {
+	e := events.Event{Kind: events.RunFinished, AuditID: auditID, ExitCode: exitcode.Of(err), ElapsedMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			e.ExitCode = exitErr.ExitCode()
+		}
+		e.Message = err.Error()
+	}
+	bus.Publish(e)
+}
+
+// publishFindings publishes one finding event per finding key of the diff
+// rows, with the severity and title CI reports them under.
+func publishFindings(auditID string, rows []diff.Row) {
+	for _, row := range rows {
+		for _, key := range diff.FindingKeysFromDiffRow(row) {
+			bus.Publish(events.Event{Kind: events.FindingEmitted, AuditID: auditID, Finding: key, Severity: driftSeverity(row), Message: driftTitle(row)})
+		}
+	}
+}
+
+// publishPolicyFailures publishes one event per violated rule of res.
+func publishPolicyFailures(res policy.Result) {
+	for _, rr := range res.Rules {
+		if rr.Status != policy.StatusViolation {
+			continue
+		}
+		sev := rr.Rule.Severity
+		if sev == "" {
+			sev = "high"
+		}
+		bus.Publish(events.Event{Kind: events.PolicyFailed, Policy: res.Policy.Name + " " + rr.Rule.ID, Severity: sev, Message: rr.Reason})
+	}
+}
+
+// notifyOnFindings subscribes the desktop notification sink: the first
+// finding of auditID raises one notification, by send.
+func notifyOnFindings(auditID string, send func()) (unsubscribe func()) {
+	notified := false
+	return bus.Subscribe(func(e events.Event) {
+		if notified || e.AuditID != auditID {
+			return
+		}
+		notified = true
+		send()
+	}, events.FindingEmitted)
+}
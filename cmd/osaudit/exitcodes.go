@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+)
+
+// runExitCodes prints the exit-code contract, one code per line as
+// "<code>\t<name>\t<description>", or as NDJSON.
+func runExitCodes(args []string) int {
+	fs := flag.NewFlagSet("exit-codes", flag.ContinueOnError)
+	ndjson := fs.Bool("ndjson", false, "Emit one JSON object per exit code")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range exitcode.Contract {
+		if *ndjson {
+			if err := enc.Encode(e); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitcode.Error
+			}
+			continue
+		}
+		fmt.Printf("%d\t%s\t%s\n", e.Code, e.Name, e.Description)
+	}
+	return exitcode.OK
+}
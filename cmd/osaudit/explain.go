@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/benchmark"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/kb"
+)
+
+// runExplain prints the knowledge base article for a probe, field, finding key,
+// or benchmark control ("cis-macos/2.2.1"). Without an argument it lists articles.
+func runExplain(args []string) int {
+	if len(args) == 0 {
+		for _, a := range kb.Articles() {
+			fmt.Printf("%-40s %s\n", a.ID, a.Title)
+		}
+		return exitcode.OK
+	}
+	id := args[0]
+
+	if benchID, controlID, ok := strings.Cut(id, "/"); ok {
+		b, err := benchmark.Load(benchID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+		for _, c := range b.Controls {
+			if c.ID != controlID {
+				continue
+			}
+			fmt.Printf("## %s %s: %s\n\n", b.Title, c.ID, c.Title)
+			fmt.Printf("Checks %s %s %v", c.Check.Field, c.Check.Op, c.Check.Value)
+			if c.Check.Probe != "" {
+				fmt.Printf(" (probe %s)", c.Check.Probe)
+			}
+			fmt.Println()
+			a, ok := kb.Lookup(c.Check.Probe)
+			if !ok {
+				a, ok = kb.Lookup(c.Check.Field)
+			}
+			if ok {
+				fmt.Println()
+				printArticle(a)
+			}
+			return exitcode.OK
+		}
+		fmt.Fprintf(os.Stderr, "Error: benchmark %s has no control %q\n", benchID, controlID)
+		return exitcode.Usage
+	}
+
+	a, ok := kb.Lookup(id)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "No documentation for %q; run `osaudit explain` to list articles\n", id)
+		return exitcode.Usage
+	}
+	if a.ID != id {
+		fmt.Printf("(%s is covered by %s)\n\n", id, a.ID)
+	}
+	fmt.Printf("## %s: %s\n\n", a.ID, a.Title)
+	printArticle(a)
+	return exitcode.OK
+}
+
+func printArticle(a kb.Article) {
+	fmt.Println(a.Summary)
+	fmt.Println()
+	fmt.Printf("Remediation: %s\n", a.Remediation)
+	fmt.Println()
+	fmt.Printf("Docs: %s\n", kb.URL(a))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// forensic is set by the global --forensic flag, or inherited from a parent
+// osaudit through writeguard.Env.
+var forensic bool
+
+// forensicRefused are the subcommands that change the audited system by
+// other means than the files osaudit writes itself: service managers, git,
+// an editor, plugins, or the config directory.
+var forensicRefused = map[string][]string{
+	"schedule": {"install", "uninstall"},
+	"pkghook":  {"install", "uninstall", "run"},
+	"baseline": {"approve"},
+	"config":   {"set", "unset", "edit"},
+	"ack":      {"record"},
+	"plugins":  {"stop"},
+}
+
+// enableForensic turns on the write guard for a forensic run of args. Writes
+// are confined to dir, else to the tree's output directory, where the audit
+// scripts write their reports. ok is false, with the exit code, when args is
+// refused or the guard cannot be set up.
+func enableForensic(repoRoot, dir string, args []string) (code int, ok bool) {
+	if dir == "" {
+		dir = outputDir(repoRoot)
+	}
+	if err := writeguard.Enable(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: forensic mode: %v\n", err)
+		return exitcode.Error, false
+	}
+	if len(args) >= 2 {
+		for _, sub := range forensicRefused[args[0]] {
+			if args[1] == sub {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", writeguard.Refuse("osaudit "+args[0]+" "+sub))
+				return exitcode.Usage, false
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Forensic mode: writing only under %s\n", writeguard.Dir())
+	return exitcode.OK, true
+}
+
+// checkForensicOutput checks where an audit script will write, the output
+// directory or the paths its pass-through arguments name, against the write
+// guard; the scripts write their reports themselves.
+func checkForensicOutput(repoRoot string, passthrough []string) error {
+	if !writeguard.Enabled() {
+		return nil
+	}
+	if err := writeguard.Check(outputDir(repoRoot)); err != nil {
+		return err
+	}
+	for i := 0; i < len(passthrough); i++ {
+		name, value, hasValue := strings.Cut(passthrough[i], "=")
+		if name != "--report-dir" && name != "--output" {
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(passthrough) {
+				return nil
+			}
+			i++
+			value = passthrough[i]
+		}
+		if err := writeguard.Check(repoPath(repoRoot, value)); err != nil {
+			return exitcode.Usagef("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// forensicSkipped reports an optional step that forensic mode leaves out.
+func forensicSkipped(what string) bool {
+	if err := writeguard.Refuse(what); err != nil {
+		fmt.Fprintf(os.Stderr, "Note: %v\n", err)
+		return true
+	}
+	return false
+}
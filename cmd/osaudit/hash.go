@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/digest"
+	"github.com/kareemsasa/operating-system-audit/internal/exclude"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/hashing"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+	"github.com/kareemsasa/operating-system-audit/internal/snapindex"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// runHash hashes every regular file under the given roots and writes one
+// file_hash row per file, for file-integrity diffs and binary allowlists.
+func runHash(args []string) int {
+	fs := flag.NewFlagSet("hash", flag.ContinueOnError)
+	defaultWorkers := runtime.NumCPU()
+	if userSettings.Concurrency > 0 {
+		defaultWorkers = userSettings.Concurrency
+	}
+	workers := fs.Int("workers", defaultWorkers, "Files hashed in parallel (default concurrency in config.yaml, else one per CPU)")
+	rateMB := fs.Int("rate-mb", 0, "Cap the combined read rate in MB/s (0 = no cap)")
+	paranoid := fs.Bool("paranoid", false, "Re-hash files even when size and mtime are unchanged")
+	noCache := fs.Bool("no-cache", false, "Neither read nor update the hash cache")
+	out := fs.String("out", "", "Write NDJSON to this file (and index it) instead of stdout")
+	store := fs.Bool("store", false, "Store the snapshot under <output dir>/hash/, delta-encoded against the previous one")
+	full := fs.Bool("full", false, "With --store, store every row instead of a delta")
+	oneFS := fs.Bool("one-filesystem", false, "Do not descend into other filesystems under the paths")
+	progress := fs.Bool("progress", isTerminal(os.Stderr), "Report progress on stderr")
+	algName := fs.String("alg", "", "Hash algorithm: blake3 or sha256 (default $OSAUDIT_HASH_ALG, else blake3)")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if fs.NArg() == 0 || *workers < 1 || *rateMB < 0 {
+		fmt.Fprintln(os.Stderr, "hash requires at least one path; --workers must be positive")
+		printUsage()
+		return exitcode.Usage
+	}
+	alg, err := digest.FromEnv()
+	if *algName != "" {
+		alg, err = digest.Parse(*algName)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hash: %v\n", err)
+		return exitcode.Usage
+	}
+	if *store && *out != "" {
+		fmt.Fprintln(os.Stderr, "hash: --store and --out are mutually exclusive")
+		printUsage()
+		return exitcode.Usage
+	}
+
+	roots := make([]string, fs.NArg())
+	for i, r := range fs.Args() {
+		abs, err := filepath.Abs(r)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		roots[i] = abs
+	}
+
+	var cache hashing.Cache
+	var cachePath string
+	if !*noCache && !forensicSkipped("the hash cache") {
+		dir, err := config.Dir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		cachePath = filepath.Join(dir, "cache", "hashes.json")
+		if cache, err = hashing.LoadCache(cachePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: hash cache %s ignored: %v\n", cachePath, err)
+			cache = hashing.Cache{}
+		}
+	}
+
+	excludes, err := exclude.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	var walkErrs []error
+	var skipped []hashing.Skipped
+	files := hashing.Walk(roots, hashing.WalkOptions{
+		Skip:          excludes.Match,
+		OneFilesystem: *oneFS,
+		SkipFSTypes:   excludes.FSTypes(),
+		OnErr:         func(path string, err error) { walkErrs = append(walkErrs, err) },
+		OnSkip:        func(s hashing.Skipped) { skipped = append(skipped, s) },
+	})
+
+	opts := hashing.Options{Algorithm: alg, Workers: *workers, BytesPerSecond: int64(*rateMB) << 20, Paranoid: *paranoid}
+	if *progress {
+		var last time.Time
+		opts.Progress = func(p hashing.Progress) {
+			if p.Done < p.Total && time.Since(last) < 200*time.Millisecond {
+				return
+			}
+			last = time.Now()
+			fmt.Fprintf(os.Stderr, "\rHashed %d/%d files, read %s (%d unchanged, %d failed)", p.Done, p.Total, humanBytes(p.Bytes), p.Reused, p.Failed)
+			if p.Done == p.Total {
+				fmt.Fprintln(os.Stderr)
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	start := time.Now()
+	results, err := hashing.Run(ctx, files, cache, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	elapsed := time.Since(start)
+	if cache != nil {
+		if err := cache.Save(cachePath, files); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: save hash cache: %v\n", err)
+		}
+	}
+
+	rows, failed := hashRows(roots, alg, results, walkErrs, skipped, elapsed)
+	switch {
+	case *store:
+		err = storeHashSnapshot(rows, *full)
+	case *out != "":
+		if err = writeNDJSONFile(*out, rows); err == nil {
+			if _, ierr := snapindex.Write(*out); ierr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: index snapshot: %v\n", ierr)
+			}
+		}
+	default:
+		enc := rawjson.NewEncoder(os.Stdout)
+		for _, row := range rows {
+			if err = enc.Encode(row); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d files or directories could not be hashed\n", failed)
+		return exitcode.Partial
+	}
+	return exitcode.OK
+}
+
+// hashRows builds the NDJSON snapshot of a hash run and returns the number of
+// failures recorded in it. Each subtree the walk left out is a capability row,
+// so a snapshot with fewer files than expected says why.
+func hashRows(roots []string, alg digest.Algorithm, results []hashing.Result, walkErrs []error, skipped []hashing.Skipped, elapsed time.Duration) (rows []map[string]any, failed int) {
+	runID := newRunID()
+	host, _ := os.Hostname()
+	rows = []map[string]any{{
+		"type": "meta", "run_id": runID, "schema_version": diff.SchemaVersion, "tool_name": "operating-system-audit", "tool_component": "hash",
+		"timestamp": time.Now().UTC().Format(time.RFC3339), "hostname": host, "roots": roots, "hash_alg": string(alg),
+	}}
+	var reused int
+	var read int64
+	for _, r := range results {
+		if r.Err != nil {
+			rows = append(rows, map[string]any{"type": "warning", "run_id": runID, "code": "hash_failed", "path": r.Path, "error": r.Err.Error()})
+			failed++
+			continue
+		}
+		if r.Reused {
+			reused++
+		} else {
+			read += r.Size
+		}
+		rows = append(rows, map[string]any{
+			"type": "file_hash", "run_id": runID, "path": r.Path, "size_bytes": r.Size,
+			"mode": fmt.Sprintf("%04o", r.Mode.Perm()), "mtime": r.ModTime.UTC().Format(time.RFC3339), "hash_alg": string(alg), string(alg): r.Digest,
+		})
+	}
+	for _, e := range walkErrs {
+		rows = append(rows, map[string]any{"type": "warning", "run_id": runID, "code": "hash_walk_failed", "error": e.Error()})
+	}
+	failed += len(walkErrs)
+	for _, s := range skipped {
+		rows = append(rows, map[string]any{
+			"type": "capability", "run_id": runID, "capability": "filesystem_walk", "status": "skipped",
+			"path": s.Path, "reason": s.Reason, "fstype": s.FSType,
+		})
+	}
+	rows = append(rows, map[string]any{
+		"type": "hash_summary", "run_id": runID, "files": len(results), "reused": reused, "failed": failed,
+		"skipped": len(skipped), "bytes_read": read, "elapsed_ms": elapsed.Milliseconds(),
+	})
+	return rows, failed
+}
+
+// hashAuditID names the hash store: output/hash/<timestamp>/ run directories
+// and output/hash/.latest.json, like an audit's.
+const hashAuditID = "hash"
+
+// storeHashSnapshot writes rows as the newest snapshot in the hash store. Its
+// file_hash rows are stored as a delta against the previous snapshot unless
+// full is set, there is none, or the delta chain is already diff.MaxFIMChain
+// long.
+func storeHashSnapshot(rows []map[string]any, full bool) error {
+	repoRoot, err := resolveRepoRoot()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	stamp := freeRunStamp(filepath.Join(outputDir(repoRoot), hashAuditID), now.Format("20060102-150405"))
+	dir := repoRelative(repoRoot, filepath.Join(outputDir(repoRoot), hashAuditID, stamp))
+	file := filepath.Join(dir, hashAuditID+"-"+stamp+".ndjson")
+
+	stored := make([]diff.Row, len(rows))
+	for i, r := range rows {
+		stored[i] = r
+	}
+	note := "full snapshot"
+	if prev, ok := latestHashSnapshot(repoRoot); ok && !full && prev != filepath.ToSlash(file) {
+		raw, err := diff.ReadNDJSONRaw(repoPath(repoRoot, prev))
+		var base []diff.Row
+		if err == nil {
+			base, err = diff.ReadNDJSON(repoPath(repoRoot, prev))
+		}
+		switch depth := diff.FIMDepth(raw); {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Warning: previous hash snapshot unreadable, storing in full: %v\n", err)
+		case depth >= diff.MaxFIMChain:
+			note = fmt.Sprintf("full snapshot (delta chain reached %d)", depth)
+		default:
+			rel, err := filepath.Rel(repoPath(repoRoot, dir), repoPath(repoRoot, prev))
+			if err != nil {
+				return err
+			}
+			stored = diff.EncodeFIMDelta(stored, base, rel, depth)
+			for _, r := range stored {
+				if r["type"] == diff.FIMDeltaType {
+					note = fmt.Sprintf("delta against %s: %d unchanged, %d removed", prev, r["unchanged"], len(r["removed"].([]string)))
+				}
+			}
+		}
+	}
+
+	if err := writeguard.MkdirAll(repoPath(repoRoot, dir), 0o755); err != nil {
+		return err
+	}
+	out := make([]map[string]any, len(stored))
+	for i, r := range stored {
+		out[i] = r
+	}
+	if err := writeNDJSONFile(repoPath(repoRoot, file), out); err != nil {
+		return err
+	}
+	if _, err := snapindex.Write(repoPath(repoRoot, file)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: index snapshot: %v\n", err)
+	}
+	detectedOS, _ := detectOS()
+	meta := latest.RunMeta{
+		RunID: fmt.Sprint(rows[0]["run_id"]), CreatedAt: now.UTC().Format(time.RFC3339), Platform: detectedOS,
+		AuditID: hashAuditID, Dir: filepath.ToSlash(dir), NDJSON: filepath.ToSlash(file),
+	}
+	if err := latest.WriteLatestManifest(repoRoot, hashAuditID, meta); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Stored %s (%s)\n", file, note)
+	return nil
+}
+
+// freeRunStamp returns stamp, or stamp with the first free "-002", "-003", ...
+// suffix when a run directory of that name already exists under parent, so runs
+// within the same second do not overwrite each other. The suffix is zero-padded
+// so the names sort in run order, after the unsuffixed first.
+func freeRunStamp(parent, stamp string) string {
+	name := stamp
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(filepath.Join(parent, name)); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%03d", stamp, n)
+	}
+}
+
+// latestHashSnapshot returns the NDJSON path, repo-relative when it can be, of the newest
+// stored hash snapshot.
+func latestHashSnapshot(repoRoot string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(outputDir(repoRoot), hashAuditID, ".latest.json"))
+	if err != nil {
+		return "", false
+	}
+	var meta latest.RunMeta
+	if json.Unmarshal(data, &meta) != nil || meta.NDJSON == "" {
+		return "", false
+	}
+	return meta.NDJSON, true
+}
+
+// newRunID returns a random UUID-shaped ID, like the audit scripts' run IDs.
+func newRunID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/snapindex"
+)
+
+// runIndex writes the sidecar index of each snapshot given, e.g. for
+// snapshots copied in from other machines.
+func runIndex(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "index requires at least one snapshot path")
+		printUsage()
+		return exitcode.Usage
+	}
+	for _, path := range args {
+		idx, err := snapindex.Write(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Printf("%s: %d rows, %d row types\n", snapindex.Path(path), idx.Rows, len(idx.Types))
+	}
+	return exitcode.OK
+}
+
+// indexSnapshot indexes a finished run's NDJSON. It must run after plugin rows
+// are merged, or the index is stale from the start.
+func indexSnapshot(repoRoot string, meta latest.RunMeta) {
+	if meta.NDJSON == "" {
+		return
+	}
+	if _, err := snapindex.Write(repoPath(repoRoot, meta.NDJSON)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: index snapshot: %v\n", err)
+	}
+}
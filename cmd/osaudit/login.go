@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// loginCollectors are the collectors the login run enables by default: the
+// fast ones that see accounts, keys, persistence, and PATH tampering, so the
+// run is over before the user has settled in.
+const loginCollectors = "identity,persistence,security"
+
+// loginUnit and loginLabel name the systemd user unit and the LaunchAgent
+// that run auditID at login, beside the daily ones.
+func loginUnit(auditID string) string  { return "osaudit-" + auditID + "-login" }
+func loginLabel(auditID string) string { return "com.osaudit." + auditID + ".login" }
+
+// loginInstall installs a run of auditID with only the enabled collectors at
+// each login: a systemd user service started with the user's session on
+// Linux, and a LaunchAgent with RunAtLoad on macOS. The run is checked
+// against the latest full snapshot, so changes made while the machine sat
+// unattended turn up without waiting for the daily run.
+func loginInstall(repoRoot, auditID, detectedOS, enable string) int {
+	enabled := collector.ParseList(enable)
+	if _, err := disabledCollectors(collector.Selection{Enable: enabled}); err != nil {
+		fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+		return exitcode.Usage
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+		return exitcode.Error
+	}
+	exe, _ = filepath.EvalSymlinks(exe)
+	exe, _ = filepath.Abs(exe)
+	args := []string{"run-scheduled", auditID, "--enable", strings.Join(enabled, ","), "--", "--redact-all"}
+
+	switch detectedOS {
+	case "linux":
+		configDir := filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user")
+		if err := writeguard.MkdirAll(configDir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		unitName := loginUnit(auditID)
+		servicePath := filepath.Join(configDir, unitName+".service")
+		// default.target is reached when the user's first session starts.
+		serviceContent := fmt.Sprintf(`[Unit]
+Description=OS Audit login run (%s: %s)
+
+[Service]
+Type=oneshot
+SuccessExitStatus=%d %d
+KillMode=process
+WorkingDirectory=%s
+Environment=OSAUDIT_ROOT=%s
+ExecStart=%s %s
+
+[Install]
+WantedBy=default.target
+`, auditID, strings.Join(enabled, ", "), exitcode.Drift, exitcode.Partial, repoRoot, repoRoot, exe, strings.Join(args, " "))
+		if err := writeguard.WriteFile(servicePath, []byte(serviceContent), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Printf("Installed. Reload and enable with:\n  systemctl --user daemon-reload\n  systemctl --user enable %s.service\n", unitName)
+		return exitcode.OK
+
+	case "mac":
+		agentsDir := filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents")
+		if err := writeguard.MkdirAll(agentsDir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		label := loginLabel(auditID)
+		plistPath := filepath.Join(agentsDir, label+".plist")
+		var argXML strings.Builder
+		for _, a := range append([]string{exe}, args...) {
+			fmt.Fprintf(&argXML, "\t\t<string>%s</string>\n", a)
+		}
+		plistContent := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>OSAUDIT_ROOT</key>
+		<string>%s</string>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>LowPriorityIO</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>/dev/null</string>
+	<key>StandardErrorPath</key>
+	<string>/dev/null</string>
+</dict>
+</plist>
+`, label, argXML.String(), repoRoot, repoRoot)
+		if err := writeguard.WriteFile(plistPath, []byte(plistContent), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Printf("Installed. It runs at each login; load it now with: launchctl load %s\n", plistPath)
+		return exitcode.OK
+	}
+
+	fmt.Fprintln(os.Stderr, "schedule install: unsupported OS")
+	return exitcode.Error
+}
+
+func loginUninstall(auditID, detectedOS string) int {
+	switch detectedOS {
+	case "linux":
+		unitName := loginUnit(auditID)
+		exec.Command("systemctl", "--user", "disable", unitName+".service").Run()
+		writeguard.Remove(filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user", unitName+".service"))
+		fmt.Printf("Uninstalled %s\n", unitName)
+		return exitcode.OK
+	case "mac":
+		label := loginLabel(auditID)
+		plistPath := filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents", label+".plist")
+		exec.Command("launchctl", "unload", plistPath).Run()
+		writeguard.Remove(plistPath)
+		fmt.Printf("Uninstalled %s\n", label)
+		return exitcode.OK
+	}
+	fmt.Fprintln(os.Stderr, "schedule uninstall: unsupported OS")
+	return exitcode.Error
+}
+
+func loginStatus(auditID, detectedOS string) int {
+	var path string
+	switch detectedOS {
+	case "linux":
+		path = filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user", loginUnit(auditID)+".service")
+	case "mac":
+		path = filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents", loginLabel(auditID)+".plist")
+	default:
+		fmt.Fprintln(os.Stderr, "schedule status: unsupported OS")
+		return exitcode.Error
+	}
+	if _, err := os.Stat(path); err != nil {
+		fmt.Printf("%s at login: not installed\n", auditID)
+		return exitcode.OK
+	}
+	fmt.Printf("%s at login: installed (%s)\n", auditID, path)
+	return exitcode.OK
+}
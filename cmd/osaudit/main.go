@@ -7,24 +7,44 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	embedded "github.com/kareemsasa/operating-system-audit"
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/contexts"
+	"github.com/kareemsasa/operating-system-audit/internal/deprecation"
 	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/dotfiles"
+	"github.com/kareemsasa/operating-system-audit/internal/events"
+	"github.com/kareemsasa/operating-system-audit/internal/exclude"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
 	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/plugin"
+	"github.com/kareemsasa/operating-system-audit/internal/render"
+	"github.com/kareemsasa/operating-system-audit/internal/rowbuf"
+	"github.com/kareemsasa/operating-system-audit/internal/settings"
+	"github.com/kareemsasa/operating-system-audit/internal/snapindex"
+	"github.com/kareemsasa/operating-system-audit/internal/term"
+	"github.com/kareemsasa/operating-system-audit/internal/wincollect"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
 )
 
 type manifest struct {
@@ -46,6 +66,9 @@ var validManifestOS = map[string]struct{}{
 }
 
 func main() {
+	if dir, err := config.Dir(); err == nil {
+		i18n.RegisterLoader(i18n.DirLoader(filepath.Join(dir, "locales")))
+	}
 	var exitCode int
 	func() {
 		defer func() {
@@ -64,10 +87,41 @@ func run(args []string) int {
 		fatalf("%v\n", err)
 	}
 
+	// --forensic, or a parent osaudit's forensic mode, confines every write
+	// to the output directory; see forensic.go.
+	forensicDir := strings.TrimSpace(os.Getenv(writeguard.Env))
+	if len(args) > 0 && args[0] == "--forensic" {
+		forensic, args = true, args[1:]
+	}
+	forensic = forensic || forensicDir != ""
+
 	repoRoot, err := resolveRepoRoot()
 	if err != nil {
 		fatalf("%v\n", err)
 	}
+	if userSettings, err = settings.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		// `config` is how a broken file gets fixed.
+		if len(args) == 0 || args[0] != "config" {
+			return exitcode.Usage
+		}
+	}
+	if forensic {
+		if code, ok := enableForensic(repoRoot, forensicDir, args); !ok {
+			return code
+		}
+	}
+
+	stopEventLog, err := startEventLog()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	defer stopEventLog()
+
+	if len(args) > 0 && args[0] == "manifest" {
+		return runManifest(repoRoot, args[1:])
+	}
 
 	commands, err := loadCommands(filepath.Join(repoRoot, "cli", "commands.json"))
 	if err != nil {
@@ -79,32 +133,76 @@ func run(args []string) int {
 	if len(args) == 0 {
 		if len(supported) == 0 {
 			fmt.Println(noCommandsMessage)
-			return 0
+			return exitcode.OK
 		}
 		runMenu(supported, detectedOS, repoRoot)
-		return 0
+		return exitcode.OK
 	}
 
 	switch args[0] {
+	case "-h", "--help", "help":
+		printHelp()
+		return exitcode.OK
 	case "list":
-		if len(supported) == 0 {
-			fmt.Println(noCommandsMessage)
-			return 0
-		}
-		printCommandList(supported)
-		return 0
+		return runList(supported, noCommandsMessage, args[1:])
 	case "run":
 		return runSubcommand(commands, repoRoot, detectedOS, args[1:])
 	case "run-scheduled":
 		return runRunScheduled(commands, repoRoot, detectedOS, args[1:])
+	case "serve":
+		return runServe(commands, repoRoot, detectedOS, args[1:])
+	case "triage":
+		return runTriage(commands, repoRoot, detectedOS, args[1:])
+	case "selftest":
+		return runSelftest(commands, repoRoot, detectedOS, args[1:])
 	case "schedule":
 		return runSchedule(repoRoot, args[1:])
+	case "pkghook":
+		return runPkghook(commands, repoRoot, detectedOS, args[1:])
 	case "diff":
 		return runDiff(args[1:])
+	case "check":
+		return runCheck(repoRoot, args[1:])
+	case "policy":
+		return runPolicy(args[1:])
+	case "ci":
+		return runCI(commands, repoRoot, detectedOS, args[1:])
+	case "crosscheck":
+		return runCrosscheck(args[1:])
+	case "baseline":
+		return runBaseline(args[1:])
+	case "timeline":
+		return runTimeline(repoRoot, args[1:])
+	case "blame":
+		return runBlame(repoRoot, args[1:])
+	case "state":
+		return runState(repoRoot, args[1:])
+	case "ack":
+		return runAck(args[1:])
+	case "explain":
+		return runExplain(args[1:])
+	case "render":
+		return runRender(args[1:])
+	case "plugins":
+		return runPlugins(detectedOS, args[1:])
+	case "exit-codes":
+		return runExitCodes(args[1:])
+	case "bench":
+		return runBench(args[1:])
+	case "collectors":
+		return runCollectors(args[1:])
+	case "config":
+		return runConfig(args[1:])
+	case "index":
+		return runIndex(args[1:])
+	case "hash":
+		return runHash(args[1:])
+	case "snapshot":
+		return runSnapshot(repoRoot, args[1:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown subcommand: %s\n", args[0])
 		printUsage()
-		return 2
+		return exitcode.Usage
 	}
 }
 
@@ -151,6 +249,9 @@ func resolveRepoRoot() (string, error) {
 	}
 
 	// Fallback: extract embedded files to temp dir for standalone binary
+	if forensic {
+		return "", errors.New("forensic mode will not extract the audit scripts to a temporary directory: set OSAUDIT_ROOT to an on-disk copy of the tree")
+	}
 	root, cleanup, err := extractEmbedded()
 	if err != nil {
 		return "", fmt.Errorf("could not determine repository root (set OSAUDIT_ROOT): %w", err)
@@ -163,12 +264,12 @@ func resolveRepoRoot() (string, error) {
 var extractedCleanup func()
 
 func extractEmbedded() (string, func(), error) {
-	tmpDir, err := os.MkdirTemp("", "osaudit-*")
+	tmpDir, err := writeguard.MkdirTemp("", "osaudit-*")
 	if err != nil {
 		return "", nil, fmt.Errorf("create temp dir: %w", err)
 	}
 	cleanup := func() {
-		os.RemoveAll(tmpDir)
+		writeguard.RemoveAll(tmpDir)
 	}
 
 	err = fs.WalkDir(embedded.EmbeddedFS, ".", func(path string, d fs.DirEntry, err error) error {
@@ -180,20 +281,20 @@ func extractEmbedded() (string, func(), error) {
 		}
 		dst := filepath.Join(tmpDir, path)
 		if d.IsDir() {
-			return os.MkdirAll(dst, 0o755)
+			return writeguard.MkdirAll(dst, 0o755)
 		}
 		data, err := fs.ReadFile(embedded.EmbeddedFS, path)
 		if err != nil {
 			return err
 		}
-		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		if err := writeguard.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
 			return err
 		}
 		mode := os.FileMode(0o644)
 		if strings.HasSuffix(path, ".sh") || strings.HasSuffix(path, ".py") {
 			mode = 0o755
 		}
-		return os.WriteFile(dst, data, mode)
+		return writeguard.WriteFile(dst, data, mode)
 	})
 	if err != nil {
 		cleanup()
@@ -225,6 +326,11 @@ func loadCommands(manifestPath string) ([]auditCommand, error) {
 	if err := validateManifest(filepath.Dir(filepath.Dir(manifestPath)), m); err != nil {
 		return nil, fmt.Errorf("invalid manifest: %w", err)
 	}
+	var doc any
+	json.Unmarshal(data, &doc)
+	if err := deprecation.Check(deprecation.Manifest, manifestPath, doc); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
 
 	return m.Commands, nil
 }
@@ -297,6 +403,12 @@ func validateManifestExecPath(repoRoot, ref string, execValues []string) error {
 	if strings.HasPrefix(execPath, "-") {
 		return fmt.Errorf("%s: exec[0] must not start with '-': %q", ref, execPath)
 	}
+	if name, ok := strings.CutPrefix(execPath, builtinPrefix); ok {
+		if !wincollect.Lookup(name) {
+			return fmt.Errorf("%s: unknown built-in collector %q", ref, name)
+		}
+		return nil
+	}
 
 	absoluteExecPath := filepath.Join(repoRoot, execPath)
 	absoluteExecPath, err := filepath.Abs(absoluteExecPath)
@@ -339,7 +451,23 @@ func commandExecForOS(cmd auditCommand, detectedOS string) ([]string, error) {
 	return nil, fmt.Errorf("command %q is not available on %q (no os_exec target configured)", cmd.ID, detectedOS)
 }
 
+// runMenu is osaudit with no arguments. On a terminal it is the full-screen
+// menu of runTUI; otherwise, or where raw mode is unsupported, it prompts for
+// one numbered command at a time.
 func runMenu(commands []auditCommand, detectedOS, repoRoot string) {
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		err := runTUI(commands, detectedOS, repoRoot)
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, term.ErrUnsupported) {
+			fmt.Fprintf(os.Stderr, "osaudit: interactive menu unavailable: %v\n", err)
+		}
+	}
+	runNumberedMenu(commands, detectedOS, repoRoot)
+}
+
+func runNumberedMenu(commands []auditCommand, detectedOS, repoRoot string) {
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Println("Operating System Audit Tool")
@@ -357,8 +485,19 @@ func runMenu(commands []auditCommand, detectedOS, repoRoot string) {
 
 		selected := commands[choice-1]
 		fmt.Printf("\nRunning: %s\n\n", selected.Display)
-		if code, err := runAuditCommand(repoRoot, selected, detectedOS, nil, false, nil); err != nil {
-			fmt.Printf("Command failed (exit %d): %v\n", code, err)
+		disabled, err := disabledCollectors(collector.Selection{})
+		if err == nil {
+			ctx, stop := signalContext()
+			stopProgress := func() {}
+			if isTerminal(os.Stderr) {
+				stopProgress = startProgress(bus, os.Stderr, enabledCollectors(disabled), progressEvery)
+			}
+			err = runAuditCommand(ctx, repoRoot, selected, detectedOS, nil, false, nil, disabled)
+			stopProgress()
+			stop()
+		}
+		if err != nil {
+			fmt.Printf("Command failed: %v\n", err)
 		}
 
 		again, ok := promptRunAgain(reader)
@@ -410,64 +549,130 @@ func promptRunAgain(reader *bufio.Reader) (bool, bool) {
 	return answer == "y" || answer == "yes", true
 }
 
-func runAuditCommand(repoRoot string, command auditCommand, detectedOS string, passthrough []string, printRunMeta bool, captureMeta *latest.RunMeta) (int, error) {
+// finishedPartial reports whether err from runAuditCommand is a run that
+// finished with some collectors crashed. Its snapshot is complete apart from
+// them, so callers go on as for a successful run and exit partial.
+func finishedPartial(err error) bool {
+	return errors.Is(err, exitcode.ErrPartial)
+}
+
+// keepInterruptedSnapshot indexes and writes out the partial snapshot of a run
+// that err ended, when a signal interrupted it and it kept one. A script that
+// failed on its own left nothing to write, and err already says why.
+func keepInterruptedSnapshot(repoRoot string, meta latest.RunMeta, out snapshotOutput, start time.Time, err error) {
+	if !errors.Is(err, exitcode.ErrInterrupted) || meta.NDJSON == "" {
+		return
+	}
+	indexSnapshot(repoRoot, meta)
+	out.write(repoRoot, meta, start)
+}
+
+// scriptIO holds the standard streams runAuditCommand connects the audit
+// scripts to. The interactive menu points them at its output pane.
+var scriptIO = struct {
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+}{os.Stdin, os.Stdout, os.Stderr}
+
+// runAuditCommand runs an audit script. A failed script yields its
+// *exec.ExitError; see exitcode.Of for how that maps onto the exit-code contract.
+// The disabled collectors are skipped by the full audit; running one of them on
+// its own is a usage error. Canceling ctx with a signal stops the script's
+// process group; the partial snapshot is finalized, captured, and the
+// *interruptError returned.
+func runAuditCommand(ctx context.Context, repoRoot string, command auditCommand, detectedOS string, passthrough []string, printRunMeta bool, captureMeta *latest.RunMeta, disabled []string) (err error) {
+	for _, id := range disabled {
+		if id == command.ID {
+			return exitcode.Usagef("collector %s is disabled (see `osaudit collectors`)", id)
+		}
+	}
+	start := time.Now()
+	bus.Publish(events.Event{Kind: events.RunStarted, AuditID: command.ID})
+	defer func() { publishRunFinished(command.ID, start, err) }()
 	execValues, err := commandExecForOS(command, detectedOS)
 	if err != nil {
-		return 1, err
+		return err
+	}
+	if name, ok := strings.CutPrefix(execValues[0], builtinPrefix); ok {
+		return runNativeCollector(ctx, repoRoot, name, passthrough, printRunMeta, captureMeta, disabled)
 	}
 
 	targetPath, err := resolveCommandPath(repoRoot, execValues[0])
 	if err != nil {
-		return 1, err
+		return err
+	}
+	excludes, err := exclude.Load()
+	if err != nil {
+		return err
+	}
+	watched, err := dotfiles.Load()
+	if err != nil {
+		return err
 	}
 
+	if err := checkForensicOutput(repoRoot, passthrough); err != nil {
+		return err
+	}
 	args := append([]string{}, execValues[1:]...)
 	args = append(args, passthrough...)
 
 	var runMetaPath string
-	if printRunMeta {
+	if printRunMeta || captureMeta != nil {
 		tmpDir := filepath.Join(repoRoot, ".tmp")
-		_ = os.MkdirAll(tmpDir, 0o755)
-		f, err := os.CreateTemp(tmpDir, "osaudit-run-meta-*.json")
+		if writeguard.Enabled() {
+			tmpDir = os.TempDir()
+		}
+		_ = writeguard.MkdirAll(tmpDir, 0o755)
+		f, err := writeguard.CreateTemp(tmpDir, "osaudit-run-meta-*.json")
 		if err != nil {
-			return 1, fmt.Errorf("create temp file for run meta: %w", err)
+			return fmt.Errorf("create temp file for run meta: %w", err)
 		}
 		runMetaPath = f.Name()
 		f.Close()
 		args = append(args, "--run-meta-out", runMetaPath)
-		defer os.Remove(runMetaPath)
+		defer writeguard.Remove(runMetaPath)
 	}
 
-	cmd := exec.Command(targetPath, args...)
+	cmd := exec.CommandContext(ctx, targetPath, args...)
+	cancelWithProcessGroup(ctx, cmd)
 	if printRunMeta {
-		cmd.Stdout = os.Stderr // human output to stderr so stdout stays clean for JSON
+		cmd.Stdout = scriptIO.Stderr // human output to stderr so stdout stays clean for JSON
 	} else {
-		cmd.Stdout = os.Stdout
+		cmd.Stdout = scriptIO.Stdout
 	}
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	cmd.Stderr = scriptIO.Stderr
+	cmd.Stdin = scriptIO.Stdin
 	cmd.Dir = repoRoot
-	cmd.Env = append(os.Environ(), "OSAUDIT_ROOT="+repoRoot)
+	cmd.Env = append(os.Environ(), "OSAUDIT_ROOT="+repoRoot, collector.DisabledEnv+"="+strings.Join(disabled, ","), config.ExcludeEnv+"="+excludes.Lines(), config.DotfilesEnv+"="+strings.Join(watched, "\n"))
+	cmd.Env = append(cmd.Env, settingsEnv(repoRoot)...)
+	waitEvents, err := forwardScriptEvents(cmd, command.ID)
+	if err != nil {
+		return err
+	}
 
 	err = cmd.Run()
+	waitEvents()
+	if ie := interruptCause(ctx); ie != nil {
+		return finishInterrupted(repoRoot, runMetaPath, printRunMeta, captureMeta, ie)
+	}
 	if err != nil {
-		return exitCodeFromError(err), err
+		return err
 	}
 
-	if printRunMeta && runMetaPath != "" {
+	if runMetaPath != "" {
 		data, err := os.ReadFile(runMetaPath)
 		if err != nil {
-			return 1, fmt.Errorf("read run meta: %w", err)
+			return fmt.Errorf("read run meta: %w", err)
 		}
 		if captureMeta != nil {
 			if err := json.Unmarshal(data, captureMeta); err != nil {
-				return 1, fmt.Errorf("parse run meta: %w", err)
+				return fmt.Errorf("parse run meta: %w", err)
 			}
 		} else {
 			fmt.Println(string(data))
 		}
 	}
-	return 0, nil
+	return nil
 }
 
 func resolveCommandPath(repoRoot, manifestPath string) (string, error) {
@@ -487,44 +692,164 @@ func resolveCommandPath(repoRoot, manifestPath string) (string, error) {
 }
 
 func runSubcommand(commands []auditCommand, repoRoot, detectedOS string, args []string) int {
-	id, passthrough, printRunMeta, err := parseRunArgs(args)
+	ra, err := parseRunArgs(args)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		printUsage()
-		return 2
+		return exitcode.Usage
+	}
+	id, passthrough, printRunMeta, scope, out := ra.ID, ra.Passthrough, ra.PrintRunMeta, ra.Scope, ra.Output
+	if err := out.check(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	disabled, err := disabledCollectors(ra.Selection)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	if len(scope) > 0 {
+		if disabled, err = applyScope(id, scope, disabled, detectedOS); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
 	}
 
+	ctx, stop := signalContext()
+	defer stop()
+	if !ra.NoProgress && isTerminal(os.Stderr) {
+		defer startProgress(bus, os.Stderr, enabledCollectors(disabled), progressEvery)()
+	}
+	if id == "--all" {
+		return runAll(ctx, commands, repoRoot, detectedOS, passthrough, printRunMeta, disabled, out)
+	}
 	command, err := findCommandByID(commands, id)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		return 2
+		return exitcode.Usage
+	}
+	if out.set() {
+		passthrough = withNDJSON(passthrough)
 	}
 
-	code, runErr := runAuditCommand(repoRoot, command, detectedOS, passthrough, printRunMeta, nil)
-	if runErr != nil {
-		fmt.Fprintln(os.Stderr, runErr)
-		return code
+	start := time.Now()
+	var meta latest.RunMeta
+	partial := runAuditCommand(ctx, repoRoot, command, detectedOS, passthrough, printRunMeta || out.Stdout, &meta, disabled)
+	if partial != nil {
+		fmt.Fprintln(os.Stderr, partial)
 	}
-	return 0
+	if partial != nil && !finishedPartial(partial) {
+		keepInterruptedSnapshot(repoRoot, meta, out, start, partial)
+		return exitcode.Of(partial)
+	}
+	indexSnapshot(repoRoot, meta)
+	if err := out.write(repoRoot, meta, start); err != nil {
+		return exitcode.Error
+	}
+	if printRunMeta {
+		data, err := json.Marshal(meta)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Println(string(data))
+	}
+	return exitcode.Of(partial)
 }
 
-func parseRunArgs(args []string) (id string, passthrough []string, printRunMeta bool, err error) {
+// runArgs are the arguments of `osaudit run`.
+type runArgs struct {
+	ID           string
+	Passthrough  []string // the audit script's arguments, after "--"
+	PrintRunMeta bool
+	Selection    collector.Selection
+	Scope        []string
+	Output       snapshotOutput
+	NoProgress   bool
+}
+
+func parseRunArgs(args []string) (ra runArgs, err error) {
 	if len(args) == 0 {
-		return "", nil, false, errors.New("missing command id for 'run'")
+		return runArgs{}, errors.New("missing command id for 'run'")
 	}
-	id = args[0]
+	ra.ID = args[0]
 	i := 1
-	for i < len(args) && args[i] == "--print-run-meta" {
-		printRunMeta = true
-		i++
+	for ; i < len(args) && args[i] != "--"; i++ {
+		switch name, value, hasValue := strings.Cut(args[i], "="); name {
+		case "--print-run-meta":
+			ra.PrintRunMeta = true
+			continue
+		case "--no-progress":
+			ra.NoProgress = true
+			continue
+		case "--output", "--output-dir":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return runArgs{}, fmt.Errorf("%s requires a path", name)
+				}
+				i++
+				value = args[i]
+			}
+			if name == "--output" {
+				ra.Output.File = value
+			} else {
+				ra.Output.Dir = value
+			}
+			if ra.Output.File != "" && ra.Output.Dir != "" {
+				return runArgs{}, errors.New("--output and --output-dir cannot be combined")
+			}
+			continue
+		case "--format":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return runArgs{}, errors.New("--format requires pretty or ndjson")
+				}
+				i++
+				value = args[i]
+			}
+			switch value {
+			case formatPretty:
+				ra.Output.Stdout = false
+			case formatNDJSON:
+				ra.Output.Stdout = true
+			default:
+				return runArgs{}, fmt.Errorf("unknown --format %q (want %s, %s)", value, formatPretty, formatNDJSON)
+			}
+			continue
+		case "--scope":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return runArgs{}, errors.New("--scope requires a path")
+				}
+				i++
+				value = args[i]
+			}
+			ra.Scope = append(ra.Scope, value)
+			continue
+		case "--enable", "--disable":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return runArgs{}, fmt.Errorf("%s requires a comma-separated list of collector IDs", name)
+				}
+				i++
+				value = args[i]
+			}
+			if name == "--enable" {
+				ra.Selection.Enable = append(ra.Selection.Enable, collector.ParseList(value)...)
+			} else {
+				ra.Selection.Disable = append(ra.Selection.Disable, collector.ParseList(value)...)
+			}
+			continue
+		}
+		return runArgs{}, errors.New("pass-through arguments must be after '--'")
 	}
-	if i >= len(args) {
-		return id, nil, printRunMeta, nil
+	if ra.Output.Stdout && ra.PrintRunMeta {
+		return runArgs{}, errors.New("--format ndjson and --print-run-meta both write to stdout")
 	}
-	if args[i] != "--" {
-		return "", nil, false, errors.New("pass-through arguments must be after '--'")
+	if i < len(args) {
+		ra.Passthrough = args[i+1:]
 	}
-	return id, args[i+1:], printRunMeta, nil
+	return ra, nil
 }
 
 func findCommandByID(commands []auditCommand, id string) (auditCommand, error) {
@@ -536,6 +861,41 @@ func findCommandByID(commands []auditCommand, id string) (auditCommand, error) {
 	return auditCommand{}, fmt.Errorf("unknown command id: %s", id)
 }
 
+// runList prints the audits available on this OS, as text or, for tooling,
+// one JSON object per audit.
+func runList(commands []auditCommand, noCommandsMessage string, args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	format := fs.String("format", formatText, "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if !validateFormat("list", *format, formatText, formatJSON) {
+		return exitcode.Usage
+	}
+	if *format == formatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		for _, cmd := range commands {
+			enc.Encode(struct {
+				ID      string `json:"id"`
+				Display string `json:"display"`
+			}{cmd.ID, cmd.Display})
+		}
+		return exitcode.OK
+	}
+	if len(commands) == 0 {
+		fmt.Println(noCommandsMessage)
+		return exitcode.OK
+	}
+	printCommandList(commands)
+	return exitcode.OK
+}
+
 func printCommandList(commands []auditCommand) {
 	for _, cmd := range commands {
 		fmt.Printf("%s %s\n", cmd.ID, cmd.Display)
@@ -546,83 +906,269 @@ func runRunScheduled(commands []auditCommand, repoRoot, detectedOS string, args
 	if len(args) < 1 {
 		fmt.Fprintln(os.Stderr, "run-scheduled requires audit id")
 		printUsage()
-		return 2
+		return exitcode.Usage
 	}
 	auditID := args[0]
 	passthrough := []string{"--ndjson"}
+	var enable []string
+	var perNetwork bool
 	for i := 1; i < len(args); i++ {
 		if args[i] == "--" {
 			passthrough = append(passthrough, args[i+1:]...)
 			break
 		}
+		if args[i] == "--enable" && i+1 < len(args) {
+			enable = collector.ParseList(args[i+1])
+			i++
+		}
+		if args[i] == "--per-network" {
+			perNetwork = true
+		}
 	}
 
 	command, err := findCommandByID(commands, auditID)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		return 2
+		return exitcode.Usage
+	}
+	// A run of only some collectors, such as the login run, is checked
+	// against the latest full snapshot but never replaces it.
+	light := len(enable) > 0
+	if light && auditID != fullAuditID {
+		fmt.Fprintf(os.Stderr, "run-scheduled: --enable applies to %s only\n", fullAuditID)
+		return exitcode.Usage
+	}
+	if perNetwork && !light {
+		fmt.Fprintln(os.Stderr, "run-scheduled: --per-network requires --enable")
+		return exitcode.Usage
+	}
+
+	disabled, err := disabledCollectors(collector.Selection{Enable: enable})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-scheduled: %v\n", err)
+		return exitcode.Usage
 	}
 
+	ctx, stop := signalContext()
+	defer stop()
 	var meta latest.RunMeta
-	code, runErr := runAuditCommand(repoRoot, command, detectedOS, passthrough, true, &meta)
-	if runErr != nil {
-		fmt.Fprintln(os.Stderr, runErr)
-		return code
+	partial := runAuditCommand(ctx, repoRoot, command, detectedOS, passthrough, true, &meta, disabled)
+	if partial != nil {
+		fmt.Fprintln(os.Stderr, partial)
+	}
+	if partial != nil && !finishedPartial(partial) {
+		// An interrupted snapshot is kept but never becomes the drift baseline.
+		indexSnapshot(repoRoot, meta)
+		return exitcode.Of(partial)
 	}
 	if meta.NDJSON == "" {
 		fmt.Fprintln(os.Stderr, "run-scheduled: audit did not produce NDJSON output")
-		return 1
+		return exitcode.Error
+	}
+	if auditID == fullAuditID && !light {
+		if err := mergePlugins(ctx, repoRoot, detectedOS, meta); err != nil {
+			partial = err
+		}
+	}
+	indexSnapshot(repoRoot, meta)
+	if ie := interruptCause(ctx); ie != nil {
+		fmt.Fprintln(os.Stderr, ie)
+		return exitcode.Interrupted
+	}
+
+	if err := loadClassification(); err != nil {
+		fmt.Fprintf(os.Stderr, "run-scheduled: %v\n", err)
+		return exitcode.Error
 	}
 
 	auditRoot := filepath.Dir(meta.Dir)
-	baselinePath := filepath.Join(repoRoot, auditRoot, ".latest.json")
+	baselinePath := filepath.Join(repoPath(repoRoot, auditRoot), ".latest.json")
+	// A per-network run is checked against the last run on the same network.
+	// A network seen for the first time is checked against the full snapshot,
+	// so a firewall that is off only there still shows.
+	networkRows, err := snapindex.ReadTypes(repoPath(repoRoot, meta.NDJSON), []string{"network_context"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-scheduled: read current NDJSON: %v\n", err)
+		return exitcode.Error
+	}
+	var network networkContext
+	var onNetwork, seenNetwork bool
+	if perNetwork {
+		if network, onNetwork = readNetworkContext(networkRows); onNetwork {
+			fmt.Fprintf(os.Stderr, "run-scheduled: network %s\n", network.Label)
+			p := networkBaselinePath(repoRoot, auditRoot, network)
+			if _, err := os.Stat(p); err == nil {
+				baselinePath, seenNetwork = p, true
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, "run-scheduled: no network context recorded; checking against .latest.json")
+		}
+	}
+	// A run in a named context (contexts.yaml or $OSAUDIT_CONTEXT) is checked
+	// against the last full run in that context, unless it has the last run
+	// on its network to go by.
+	runContext, err := contexts.Resolve("", networkRow(networkRows))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run-scheduled: contexts not applied: %v\n", err)
+	}
+	var seenContext bool
+	if runContext != "" {
+		fmt.Fprintf(os.Stderr, "run-scheduled: context %s\n", runContext)
+		p := contextBaselinePath(repoRoot, auditRoot, runContext)
+		if _, err := os.Stat(p); err == nil {
+			seenContext = true
+			if !seenNetwork {
+				baselinePath = p
+			}
+		}
+	}
 	var hasDeltas bool
 	var capturedOutput []byte
+	var findings []diff.Row
+	var newDevices []string
 	baselineData, err := os.ReadFile(baselinePath)
 	hadBaseline := err == nil
 	if hadBaseline {
 		var baseline latest.RunMeta
 		if err := json.Unmarshal(baselineData, &baseline); err != nil {
 			fmt.Fprintf(os.Stderr, "run-scheduled: invalid baseline: %v\n", err)
-			return 1
+			return exitcode.Error
 		}
-		baselineNDJSON := filepath.Join(repoRoot, baseline.NDJSON)
-		currentNDJSON := filepath.Join(repoRoot, meta.NDJSON)
+		baselineNDJSON := repoPath(repoRoot, baseline.NDJSON)
+		currentNDJSON := repoPath(repoRoot, meta.NDJSON)
 		baselineRows, err := diff.ReadNDJSON(baselineNDJSON)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "run-scheduled: read baseline NDJSON: %v\n", err)
-			return 1
+			return exitcode.Error
 		}
 		currentRows, err := diff.ReadNDJSON(currentNDJSON)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "run-scheduled: read current NDJSON: %v\n", err)
-			return 1
+			return exitcode.Error
+		}
+		if rules, err := loadIgnoreRules(); err != nil {
+			fmt.Fprintf(os.Stderr, "run-scheduled: ignore rules not applied: %v\n", err)
+		} else if len(rules) > 0 {
+			baselineRows = diff.ApplyIgnore(baselineRows, rules.Match)
+			currentRows = diff.ApplyIgnore(currentRows, rules.Match)
+		}
+		if light {
+			baselineRows = collectedRows(baselineRows, currentRows, enable)
 		}
 		hasDeltas, capturedOutput = diff.Run(baselineRows, currentRows, false, true)
+		reportSuggestedSuppressions()
+		if hasDeltas {
+			findings = diff.Findings(baselineRows, currentRows)
+			newDevices = newUSBDevices(baselineRows, currentRows)
+		}
 	}
 
-	if err := latest.WriteLatestManifest(repoRoot, auditID, meta); err != nil {
-		fmt.Fprintf(os.Stderr, "run-scheduled: write latest manifest: %v\n", err)
-		return 1
+	if onNetwork {
+		if err := latest.WriteManifest(networkBaselinePath(repoRoot, auditRoot, network), meta); err != nil {
+			fmt.Fprintf(os.Stderr, "run-scheduled: write network manifest: %v\n", err)
+			return exitcode.Error
+		}
+		if !seenNetwork {
+			fmt.Fprintf(os.Stderr, "run-scheduled: first run on network %s; recorded it as that network's baseline\n", network.Label)
+		}
 	}
-	if !hadBaseline {
+	if light {
+		if !hadBaseline {
+			fmt.Fprintf(os.Stderr, "run-scheduled: no baseline found; run %s without --enable first\n", fullAuditID)
+		}
+	} else if err := latest.WriteLatestManifest(repoRoot, auditID, meta); err != nil {
+		fmt.Fprintf(os.Stderr, "run-scheduled: write latest manifest: %v\n", err)
+		return exitcode.Error
+	} else if !hadBaseline {
 		fmt.Fprintf(os.Stderr, "run-scheduled: no baseline found; wrote .latest.json\n")
 	}
+	if !light && runContext != "" {
+		if err := latest.WriteManifest(contextBaselinePath(repoRoot, auditRoot, runContext), meta); err != nil {
+			fmt.Fprintf(os.Stderr, "run-scheduled: write context manifest: %v\n", err)
+			return exitcode.Error
+		}
+		if !seenContext {
+			fmt.Fprintf(os.Stderr, "run-scheduled: first run in context %s; recorded it as that context's baseline\n", runContext)
+		}
+	}
 
 	if hasDeltas {
+		if onNetwork {
+			fmt.Printf("%s\n\n", i18n.T("run_scheduled.network", network.Label))
+		} else if runContext != "" {
+			fmt.Printf("%s\n\n", i18n.T("run_scheduled.context", runContext))
+		}
 		if len(capturedOutput) > 0 {
 			os.Stdout.Write(capturedOutput)
 		}
-		notifyOnChange(repoRoot, auditRoot, auditID)
-		return 2
+		// A new HID or mass-storage device gets a notification naming it,
+		// and a per-network run one naming the network, instead of the
+		// generic one.
+		stopNotify := func() {}
+		if len(newDevices) > 0 {
+			notifyNewDevices(repoRoot, auditRoot, newDevices)
+		} else if onNetwork {
+			stopNotify = notifyOnFindings(auditID, func() { notifyNetworkChanges(repoRoot, auditRoot, auditID, network) })
+		} else {
+			stopNotify = notifyOnFindings(auditID, func() { notifyOnChange(repoRoot, auditRoot, auditID) })
+		}
+		publishFindings(auditID, findings)
+		stopNotify()
+		return exitcode.Drift
 	}
-	return 0
+	return exitcode.Of(partial)
+}
+
+// collectedRows returns the rows a run of the enabled collectors could have
+// written: those whose type occurs in like, less the probe failures of other
+// collectors.
+func collectedRows(rows, like []diff.Row, enabled []string) []diff.Row {
+	types := make(map[any]bool)
+	for _, r := range like {
+		types[r["type"]] = true
+	}
+	ran := func(probe any) bool {
+		s, ok := probe.(string)
+		id, _, _ := strings.Cut(s, ".")
+		return !ok || slices.Contains(enabled, id)
+	}
+	var out []diff.Row
+	for _, r := range rows {
+		switch {
+		case !types[r["type"]]:
+			continue
+		case r["type"] == "probe_failed" && !ran(r["probe"]):
+			continue
+		case r["type"] == "probe_failures_summary":
+			items, _ := r["items"].([]any)
+			kept := make([]any, 0, len(items))
+			for _, it := range items {
+				if m, ok := it.(map[string]any); !ok || ran(m["probe"]) {
+					kept = append(kept, it)
+				}
+			}
+			r = maps.Clone(r)
+			r["items"] = kept
+		}
+		out = append(out, r)
+	}
+	return out
 }
 
 func notifyOnChange(repoRoot, auditRoot, auditID string) {
-	title := "OS Audit: changes detected"
-	body := fmt.Sprintf("Audit %s found changes since last run.", auditID)
+	notify(repoRoot, auditRoot, i18n.T("notify.changes.title"), i18n.T("notify.changes.body", auditID))
+}
+
+// notify shows a desktop notification, or writes it under alerts/ in the
+// audit root when there is no desktop to show it on or in forensic mode,
+// where the notification service's own records would change. It is also
+// POSTed to the notify.urls in config.yaml.
+func notify(repoRoot, auditRoot, title, body string) {
+	postNotifications(title, body)
 	detectedOS, _ := detectOS()
+	if writeguard.Enabled() {
+		detectedOS = ""
+	}
 
 	var notified bool
 	switch detectedOS {
@@ -639,11 +1185,11 @@ func notifyOnChange(repoRoot, auditRoot, auditID string) {
 	}
 
 	if !notified {
-		alertsDir := filepath.Join(repoRoot, auditRoot, "alerts")
-		_ = os.MkdirAll(alertsDir, 0o755)
+		alertsDir := filepath.Join(repoPath(repoRoot, auditRoot), "alerts")
+		_ = writeguard.MkdirAll(alertsDir, 0o755)
 		logName := fmt.Sprintf("%s.txt", strings.ReplaceAll(time.Now().Format(time.RFC3339), ":", "-"))
 		logPath := filepath.Join(alertsDir, logName)
-		if err := os.WriteFile(logPath, []byte(fmt.Sprintf("%s\n%s\n", title, body)), 0o644); err == nil {
+		if err := writeguard.WriteFile(logPath, []byte(fmt.Sprintf("%s\n%s\n", title, body)), 0o644); err == nil {
 			fmt.Fprintf(os.Stderr, "run-scheduled: desktop notification unavailable; wrote alerts/%s\n", logName)
 		} else {
 			fmt.Fprintf(os.Stderr, "run-scheduled: desktop notification unavailable; could not write alerts/%s: %v\n", logName, err)
@@ -655,23 +1201,80 @@ func runSchedule(repoRoot string, args []string) int {
 	if len(args) < 1 {
 		fmt.Fprintln(os.Stderr, "schedule requires subcommand: install, uninstall, status")
 		printUsage()
-		return 2
+		return exitcode.Usage
 	}
 	sub := args[0]
 	rest := args[1:]
 	if len(rest) < 1 {
 		fmt.Fprintf(os.Stderr, "schedule %s requires audit id\n", sub)
 		printUsage()
-		return 2
+		return exitcode.Usage
 	}
 	auditID := rest[0]
+	fs := flag.NewFlagSet("schedule "+sub, flag.ContinueOnError)
+	on := fs.String("on", "daily", "When to run: daily (8:00), login, device (on attach), or network (on change)")
+	enable := fs.String("enable", "", "Collectors the login, device, or network run enables (default "+loginCollectors+" at login, "+deviceCollectors+" on device attach, "+networkCollectors+" on network change)")
+	if err := fs.Parse(rest[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.Usage
+	}
+	if *on != "daily" && *on != "login" && *on != "device" && *on != "network" {
+		fmt.Fprintf(os.Stderr, "schedule: --on must be daily, login, device, or network, not %q\n", *on)
+		return exitcode.Usage
+	}
+	if *on != "daily" && auditID != fullAuditID {
+		fmt.Fprintf(os.Stderr, "schedule: --on %s runs %s with --enable; use %s\n", *on, fullAuditID, fullAuditID)
+		return exitcode.Usage
+	}
 
 	detectedOS, err := detectOS()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		return 1
+		return exitcode.Error
 	}
 
+	if *on == "login" {
+		switch sub {
+		case "install":
+			if *enable == "" {
+				*enable = loginCollectors
+			}
+			return loginInstall(repoRoot, auditID, detectedOS, *enable)
+		case "uninstall":
+			return loginUninstall(auditID, detectedOS)
+		case "status":
+			return loginStatus(auditID, detectedOS)
+		}
+	}
+	if *on == "device" {
+		switch sub {
+		case "install":
+			if *enable == "" {
+				*enable = deviceCollectors
+			}
+			return deviceInstall(repoRoot, auditID, detectedOS, *enable)
+		case "uninstall":
+			return deviceUninstall(auditID, detectedOS)
+		case "status":
+			return deviceStatus(auditID, detectedOS)
+		}
+	}
+	if *on == "network" {
+		switch sub {
+		case "install":
+			if *enable == "" {
+				*enable = networkCollectors
+			}
+			return networkInstall(repoRoot, auditID, detectedOS, *enable)
+		case "uninstall":
+			return networkUninstall(auditID, detectedOS)
+		case "status":
+			return networkStatus(auditID, detectedOS)
+		}
+	}
 	switch sub {
 	case "install":
 		return scheduleInstall(repoRoot, auditID, detectedOS)
@@ -682,7 +1285,7 @@ func runSchedule(repoRoot string, args []string) int {
 	default:
 		fmt.Fprintf(os.Stderr, "schedule: unknown subcommand %q\n", sub)
 		printUsage()
-		return 2
+		return exitcode.Usage
 	}
 }
 
@@ -690,7 +1293,7 @@ func scheduleInstall(repoRoot, auditID, detectedOS string) int {
 	exe, err := os.Executable()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
-		return 1
+		return exitcode.Error
 	}
 	exe, _ = filepath.EvalSymlinks(exe)
 	exe, _ = filepath.Abs(exe)
@@ -699,9 +1302,9 @@ func scheduleInstall(repoRoot, auditID, detectedOS string) int {
 
 	if detectedOS == "linux" {
 		configDir := filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user")
-		if err := os.MkdirAll(configDir, 0o755); err != nil {
+		if err := writeguard.MkdirAll(configDir, 0o755); err != nil {
 			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
-			return 1
+			return exitcode.Error
 		}
 		unitName := "osaudit-" + auditID
 		servicePath := filepath.Join(configDir, unitName+".service")
@@ -712,11 +1315,12 @@ Description=OS Audit scheduled run (%s)
 
 [Service]
 Type=oneshot
-SuccessExitStatus=2
+SuccessExitStatus=%d %d
+KillMode=process
 WorkingDirectory=%s
 Environment=OSAUDIT_ROOT=%s
 ExecStart=%s %s
-`, auditID, repoRoot, repoRoot, exe, strings.Join(args, " "))
+`, auditID, exitcode.Drift, exitcode.Partial, repoRoot, repoRoot, exe, strings.Join(args, " "))
 
 		timerContent := fmt.Sprintf(`[Unit]
 Description=OS Audit scheduled run (%s)
@@ -729,24 +1333,24 @@ Persistent=true
 WantedBy=timers.target
 `, auditID)
 
-		if err := os.WriteFile(servicePath, []byte(serviceContent), 0o644); err != nil {
+		if err := writeguard.WriteFile(servicePath, []byte(serviceContent), 0o644); err != nil {
 			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
-			return 1
+			return exitcode.Error
 		}
-		if err := os.WriteFile(timerPath, []byte(timerContent), 0o644); err != nil {
-			os.Remove(servicePath)
+		if err := writeguard.WriteFile(timerPath, []byte(timerContent), 0o644); err != nil {
+			writeguard.Remove(servicePath)
 			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
-			return 1
+			return exitcode.Error
 		}
 		fmt.Printf("Installed. Reload and enable with:\n  systemctl --user daemon-reload\n  systemctl --user enable --now %s.timer\n", unitName)
-		return 0
+		return exitcode.OK
 	}
 
 	if detectedOS == "mac" {
 		agentsDir := filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents")
-		if err := os.MkdirAll(agentsDir, 0o755); err != nil {
+		if err := writeguard.MkdirAll(agentsDir, 0o755); err != nil {
 			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
-			return 1
+			return exitcode.Error
 		}
 		label := "com.osaudit." + auditID
 		plistPath := filepath.Join(agentsDir, label+".plist")
@@ -787,16 +1391,16 @@ WantedBy=timers.target
 </plist>
 `, label, exe, auditID, repoRoot, repoRoot)
 
-		if err := os.WriteFile(plistPath, []byte(plistContent), 0o644); err != nil {
+		if err := writeguard.WriteFile(plistPath, []byte(plistContent), 0o644); err != nil {
 			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
-			return 1
+			return exitcode.Error
 		}
 		fmt.Printf("Installed. Load with: launchctl load %s\n", plistPath)
-		return 0
+		return exitcode.OK
 	}
 
 	fmt.Fprintln(os.Stderr, "schedule install: unsupported OS")
-	return 1
+	return exitcode.Error
 }
 
 func scheduleUninstall(auditID, detectedOS string) int {
@@ -810,10 +1414,10 @@ func scheduleUninstall(auditID, detectedOS string) int {
 		exec.Command("systemctl", "--user", "stop", unitName+".timer").Run()
 		exec.Command("systemctl", "--user", "disable", unitName+".timer").Run()
 
-		os.Remove(timerPath)
-		os.Remove(servicePath)
+		writeguard.Remove(timerPath)
+		writeguard.Remove(servicePath)
 		fmt.Printf("Uninstalled %s\n", unitName)
-		return 0
+		return exitcode.OK
 	}
 
 	if detectedOS == "mac" {
@@ -821,13 +1425,13 @@ func scheduleUninstall(auditID, detectedOS string) int {
 		plistPath := filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents", label+".plist")
 
 		exec.Command("launchctl", "unload", plistPath).Run()
-		os.Remove(plistPath)
+		writeguard.Remove(plistPath)
 		fmt.Printf("Uninstalled %s\n", label)
-		return 0
+		return exitcode.OK
 	}
 
 	fmt.Fprintln(os.Stderr, "schedule uninstall: unsupported OS")
-	return 1
+	return exitcode.Error
 }
 
 func scheduleStatus(auditID, detectedOS string) int {
@@ -836,14 +1440,14 @@ func scheduleStatus(auditID, detectedOS string) int {
 		timerPath := filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user", unitName+".timer")
 		if _, err := os.Stat(timerPath); err != nil {
 			fmt.Printf("%s: not installed\n", auditID)
-			return 0
+			return exitcode.OK
 		}
 		fmt.Printf("%s: installed\n", auditID)
 		cmd := exec.Command("systemctl", "--user", "list-timers", unitName+".timer", "--no-pager")
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		_ = cmd.Run()
-		return 0
+		return exitcode.OK
 	}
 
 	if detectedOS == "mac" {
@@ -851,70 +1455,184 @@ func scheduleStatus(auditID, detectedOS string) int {
 		plistPath := filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents", label+".plist")
 		if _, err := os.Stat(plistPath); err != nil {
 			fmt.Printf("%s: not installed\n", auditID)
-			return 0
+			return exitcode.OK
 		}
 		fmt.Printf("%s: installed (%s)\n", auditID, plistPath)
 		fmt.Println("Next run: daily at 8:00 AM (launchd does not expose next run time)")
-		return 0
+		return exitcode.OK
 	}
 
 	fmt.Fprintln(os.Stderr, "schedule status: unsupported OS")
-	return 1
+	return exitcode.Error
 }
 
 func runDiff(args []string) int {
 	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
-	baseline := fs.String("baseline", "", "Path to baseline NDJSON file")
-	current := fs.String("current", "", "Path to current NDJSON file")
-	ndjson := fs.Bool("ndjson", false, "Emit structured diff rows as NDJSON instead of human-readable summary")
+	baselinePath := fs.String("baseline", "", "Path to baseline NDJSON file")
+	currentPath := fs.String("current", "", "Path to current NDJSON file")
+	ndjson := fs.Bool("ndjson", false, "Emit structured diff rows as NDJSON instead of human-readable summary (same as --format json)")
+	format := fs.String("format", formatMarkdown, "Output format: markdown, json (one diff row per line), or table")
+	noIgnore := fs.Bool("no-ignore", false, "Report findings suppressed by ignore rules in ~/.osaudit/ignore.json")
+	theme := fs.String("theme", render.ThemeMarkdown, themeUsage)
+	types := fs.String("type", "", "Comma-separated row types to compare; indexed snapshots are read only for these")
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
-			return 0
+			return exitcode.OK
 		}
 		fmt.Fprintln(os.Stderr, err)
 		printUsage()
-		return 2
+		return exitcode.Usage
 	}
-	if *baseline == "" || *current == "" {
+	if *baselinePath == "" || *currentPath == "" {
 		fmt.Fprintln(os.Stderr, "diff requires --baseline and --current")
 		printUsage()
-		return 2
+		return exitcode.Usage
+	}
+	if !validateFormat("diff", *format, formatMarkdown, formatJSON, formatTable) {
+		return exitcode.Usage
+	}
+	if *ndjson {
+		if *format != formatMarkdown && *format != formatJSON {
+			fmt.Fprintf(os.Stderr, "diff: --ndjson and --format %s are mutually exclusive\n", *format)
+			return exitcode.Usage
+		}
+		*format = formatJSON
+	}
+	if !validateTheme("diff", *theme, *format != formatMarkdown) {
+		return exitcode.Usage
 	}
 
-	baselineRows, err := diff.ReadNDJSON(*baseline)
+	if err := loadClassification(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	limit, err := rowbuf.EnvLimit()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	read := func(path string) (*diff.Snapshot, error) { return diff.ReadSnapshot(path, limit) }
+	if *types != "" {
+		// Plugin rows declare how plugin row types are keyed.
+		only := append(collector.ParseList(*types), plugin.RowType)
+		read = func(path string) (*diff.Snapshot, error) {
+			rows, err := snapindex.ReadTypes(path, only)
+			return &diff.Snapshot{Rows: rows}, err
+		}
+	}
+	baseline, err := read(*baselinePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return 1
+		return exitcode.Error
 	}
-	currentRows, err := diff.ReadNDJSON(*current)
+	defer baseline.Close()
+	current, err := read(*currentPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return 1
+		return exitcode.Error
 	}
+	defer current.Close()
 
-	hasDeltas, _ := diff.Run(baselineRows, currentRows, *ndjson, false)
-	if hasDeltas {
-		return 2
+	if !*noIgnore {
+		rules, err := loadIgnoreRules()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		if len(rules) > 0 {
+			baseline.ApplyIgnore(rules.Match)
+			current.ApplyIgnore(rules.Match)
+		}
 	}
-	return 0
+
+	return withDiffTheme(*theme, func() int {
+		// The table is built from the diff rows of a quiet JSON run.
+		table := *format == formatTable
+		hasDeltas, out, err := diff.RunSnapshots(baseline, current, *format != formatMarkdown, table)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		if table {
+			if err := diff.WriteTable(os.Stdout, diff.ParseFindings(out)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitcode.Error
+			}
+		}
+		if hasDeltas {
+			return exitcode.Drift
+		}
+		return exitcode.OK
+	})
+}
+
+// loadClassification applies ~/.osaudit/classify.yaml (probe severities,
+// expected exit codes, topics) on top of the built-in classification.
+func loadClassification() error {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil
+	}
+	return diff.LoadClassificationFile(filepath.Join(dir, "classify.yaml"))
 }
 
 func printUsage() {
 	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  osaudit [--forensic] <subcommand> ...   (--forensic: write nothing outside output/)")
 	fmt.Fprintln(os.Stderr, "  osaudit")
-	fmt.Fprintln(os.Stderr, "  osaudit list")
-	fmt.Fprintln(os.Stderr, "  osaudit run <id> [--print-run-meta] -- [args...]")
-	fmt.Fprintln(os.Stderr, "  osaudit run-scheduled <audit_id> [--] [args...]")
-	fmt.Fprintln(os.Stderr, "  osaudit schedule install|uninstall|status <audit_id>")
-	fmt.Fprintln(os.Stderr, "  osaudit diff --baseline <path> --current <path> [--ndjson]")
+	fmt.Fprintln(os.Stderr, "  osaudit list [--format text|json]")
+	fmt.Fprintln(os.Stderr, "  osaudit run <id> [--print-run-meta] [--no-progress] [--format pretty|ndjson] [--output <file> | --output-dir <dir>] [--enable <ids>] [--disable <ids>] [--scope <path>]... -- [args...]")
+	fmt.Fprintln(os.Stderr, "  osaudit run --all [--print-run-meta] [--no-progress] [--format pretty|ndjson] [--output <file> | --output-dir <dir>] [--enable <ids>] [--disable <ids>] [--scope <path>]... -- [args...]")
+	fmt.Fprintln(os.Stderr, "  osaudit run-scheduled <audit_id> [--enable <ids> [--per-network]] [--] [args...]")
+	fmt.Fprintln(os.Stderr, "  osaudit serve [--listen <127.0.0.1:9464>] [--every <1h>] <audit_id> [--enable <ids> [--per-network]] [--] [args...]")
+	fmt.Fprintln(os.Stderr, "  osaudit triage [--hours N] [--out <bundle.tar.gz>]")
+	fmt.Fprintln(os.Stderr, "  osaudit selftest [--keep] [--ndjson]")
+	fmt.Fprintln(os.Stderr, "  osaudit schedule install|uninstall|status <audit_id> [--on <daily|login|device|network>] [--enable <ids>]")
+	fmt.Fprintln(os.Stderr, "  osaudit pkghook install|uninstall|status <apt|dnf|brew>")
+	fmt.Fprintln(os.Stderr, "  osaudit pkghook run <apt|dnf|brew> [--detach] [--enable <ids>] [-- brew args...]")
+	fmt.Fprintln(os.Stderr, "  osaudit diff --baseline <path> --current <path> [--type <row types>] [--format markdown|json|table | --ndjson] [--no-ignore] [--theme <markdown|plain|high-contrast>]")
+	fmt.Fprintln(os.Stderr, "  osaudit check [--benchmark <cis-macos|cis-linux>] [--policy <policy.yaml>] [--rules <dir>|--no-rules] [--composite] --snapshot <path> [--ndjson] [--theme <markdown|plain|high-contrast>]")
+	fmt.Fprintln(os.Stderr, "  osaudit check --policy <policy.yaml> --against <store://last-30d | store://all | path> [--ndjson] [--theme <markdown|plain|high-contrast>]")
+	fmt.Fprintln(os.Stderr, "  osaudit policy test [--ndjson] [<test file | dir>...]")
+	fmt.Fprintln(os.Stderr, "  osaudit ci --baseline <path> [--update-baseline] [--profile <id> | --snapshot <path>] [--artifacts <dir>] [--fail-on <high|medium|low|none>] [--benchmark <id>] [--policy <policy.yaml>] [--no-ignore] [-- args...]")
+	fmt.Fprintln(os.Stderr, "  osaudit baseline approve --repo <dir> [--name <name>] [--redact-all] [-m <subject>] [--context <name>] <snapshot.ndjson>")
+	fmt.Fprintln(os.Stderr, "  osaudit baseline diff --repo <dir> [--name <name>] [--rev <rev>] [--ndjson] [--no-ignore] [--theme <theme>] [--context <name>] <snapshot.ndjson>")
+	fmt.Fprintln(os.Stderr, "  osaudit crosscheck (--ansible <facts.json> | --terraform <terraform.tfstate> | --jamf <export> | --intune <export>) [--device <name|serial>] [--max-age <duration>] [--missing-only] [--ndjson] [--theme <theme>] <snapshot.ndjson>")
+	fmt.Fprintln(os.Stderr, "  osaudit timeline [--from <date>] [--to <date>] [--host <name>] [--ndjson | --export <timesketch-jsonl|timesketch-csv>] [--theme <theme>] [<snapshot | dir>...]")
+	fmt.Fprintln(os.Stderr, "  osaudit blame --field <row type>.<field> [--host <name>] [--ndjson] [--theme <theme>] [<snapshot | dir>...]")
+	fmt.Fprintln(os.Stderr, "  osaudit ack record --baseline <path> --current <path>")
+	fmt.Fprintln(os.Stderr, "  osaudit ack suggest [--min-acks N] [--apply]")
+	fmt.Fprintln(os.Stderr, "  osaudit ack list")
+	fmt.Fprintln(os.Stderr, "  osaudit state export [--out <archive.tar.gz>]")
+	fmt.Fprintln(os.Stderr, "  osaudit state import [--force] <archive.tar.gz>")
+	fmt.Fprintln(os.Stderr, "  osaudit collectors [--enable <ids>] [--disable <ids>] [--ndjson]")
+	fmt.Fprintln(os.Stderr, "  osaudit config set <file.key> <value>")
+	fmt.Fprintln(os.Stderr, "  osaudit config unset <file.key>")
+	fmt.Fprintln(os.Stderr, "  osaudit config edit <collectors|classify|config>")
+	fmt.Fprintln(os.Stderr, "  osaudit config env [--ndjson]")
+	fmt.Fprintln(os.Stderr, "  osaudit plugins [stop]")
+	fmt.Fprintln(os.Stderr, "  osaudit index <snapshot.ndjson>...")
+	fmt.Fprintln(os.Stderr, "  osaudit hash [--alg <blake3|sha256>] [--workers N] [--rate-mb N] [--paranoid] [--no-cache] [--one-filesystem] [--out <path> | --store [--full]] <path>...")
+	fmt.Fprintln(os.Stderr, "  osaudit snapshot compact [--dry-run] [--gzip] [--redact-all | --no-redact] [<snapshot.ndjson | dir>...]")
+	fmt.Fprintln(os.Stderr, "  osaudit snapshot reclassify [--dry-run] [<snapshot.ndjson | dir>...]")
+	fmt.Fprintln(os.Stderr, "  osaudit manifest lint [--strict] [--ndjson] [<commands.json>]")
+	fmt.Fprintln(os.Stderr, "  osaudit exit-codes [--ndjson]")
+	fmt.Fprintln(os.Stderr, "  osaudit bench [--rows N] [--seed N] [--churn F] [--iterations N] [--budget <path>] [--ndjson]")
+	fmt.Fprintln(os.Stderr, "  osaudit explain [<probe|field|benchmark/control>]")
+	fmt.Fprintln(os.Stderr, "  osaudit render --theme <plain|high-contrast> [--out <path>] <report.md>")
 }
 
-func exitCodeFromError(err error) int {
-	var exitErr *exec.ExitError
-	if errors.As(err, &exitErr) {
-		return exitErr.ExitCode()
-	}
-	return 1
+// printHelp is printUsage and where settings come from, for --help.
+func printHelp() {
+	printUsage()
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Defaults come from ~/.osaudit/config.yaml (output_dir, redact, concurrency, collectors, notify.urls, ignore).")
+	fmt.Fprintln(os.Stderr, "For each setting the first of these that sets it wins:")
+	fmt.Fprintln(os.Stderr, "  1. command-line flags (e.g. --report-dir, --redact-all/--no-redact, --workers, --enable/--disable)")
+	fmt.Fprintln(os.Stderr, "  2. OSAUDIT_CONFIG_<KEY>, e.g. OSAUDIT_CONFIG_REDACT=all")
+	fmt.Fprintln(os.Stderr, "  3. OSAUDIT_CONFIG, a whole config.yaml document")
+	fmt.Fprintln(os.Stderr, "  4. config.yaml in OSAUDIT_HOME; collectors.yaml overrides its collectors, and ignore.json adds to its ignore")
+	fmt.Fprintln(os.Stderr, "  5. built-in defaults")
 }
 
 func fatalf(format string, args ...any) {
@@ -1,14 +1,39 @@
 package main
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/events"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/settings"
+	"github.com/kareemsasa/operating-system-audit/internal/term"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
 )
 
 func TestValidateManifest(t *testing.T) {
@@ -110,6 +135,25 @@ func TestValidateManifest(t *testing.T) {
 			},
 			wantErr: "does not exist",
 		},
+		{
+			name:     "built-in windows collector",
+			repoRoot: tmp,
+			m: manifest{
+				Commands: []auditCommand{
+					{ID: "persistence", Display: "P", OSExec: map[string][]string{"windows": []string{"builtin:persistence"}}},
+				},
+			},
+		},
+		{
+			name:     "unknown built-in collector",
+			repoRoot: tmp,
+			m: manifest{
+				Commands: []auditCommand{
+					{ID: "x", Display: "X", OSExec: map[string][]string{"windows": []string{"builtin:storage"}}},
+				},
+			},
+			wantErr: "unknown built-in collector",
+		},
 		{
 			name:     "empty commands",
 			repoRoot: tmp,
@@ -145,17 +189,27 @@ func TestParseRunArgs(t *testing.T) {
 		wantPrintMeta bool
 		wantErr       bool
 		wantErrMsg    string
+		wantSel       collector.Selection
 	}{
-		{"no args (error)", []string{}, "", nil, false, true, "missing command id"},
-		{"id only", []string{"full"}, "full", nil, false, false, ""},
-		{"id + -- + passthrough", []string{"full", "--", "-x", "y"}, "full", []string{"-x", "y"}, false, false, ""},
-		{"id + --print-run-meta", []string{"full", "--print-run-meta"}, "full", nil, true, false, ""},
-		{"id + --print-run-meta + -- + passthrough", []string{"full", "--print-run-meta", "--", "-x"}, "full", []string{"-x"}, true, false, ""},
-		{"id + extra without -- (error)", []string{"full", "extra"}, "", nil, false, true, "pass-through"},
+		{"no args (error)", []string{}, "", nil, false, true, "missing command id", collector.Selection{}},
+		{"id only", []string{"full"}, "full", nil, false, false, "", collector.Selection{}},
+		{"id + -- + passthrough", []string{"full", "--", "-x", "y"}, "full", []string{"-x", "y"}, false, false, "", collector.Selection{}},
+		{"id + --print-run-meta", []string{"full", "--print-run-meta"}, "full", nil, true, false, "", collector.Selection{}},
+		{"id + --print-run-meta + -- + passthrough", []string{"full", "--print-run-meta", "--", "-x"}, "full", []string{"-x"}, true, false, "", collector.Selection{}},
+		{"id + extra without -- (error)", []string{"full", "extra"}, "", nil, false, true, "pass-through", collector.Selection{}},
+		{"id + --disable + --enable=", []string{"full", "--disable", "network,execution", "--enable=storage", "--", "-x"}, "full", []string{"-x"}, false, false, "",
+			collector.Selection{Enable: []string{"storage"}, Disable: []string{"network", "execution"}}},
+		{"--disable without value (error)", []string{"full", "--disable"}, "", nil, false, true, "requires a comma-separated list", collector.Selection{}},
+		{"--output + --output-dir (error)", []string{"full", "--output", "a.ndjson", "--output-dir=snaps"}, "", nil, false, true, "cannot be combined", collector.Selection{}},
+		{"--output-dir without value (error)", []string{"full", "--output-dir"}, "", nil, false, true, "requires a path", collector.Selection{}},
+		{"--format unknown (error)", []string{"full", "--format", "json"}, "", nil, false, true, "unknown --format", collector.Selection{}},
+		{"id + --no-progress", []string{"full", "--no-progress", "--", "-x"}, "full", []string{"-x"}, false, false, "", collector.Selection{}},
+		{"--format ndjson + --print-run-meta (error)", []string{"full", "--format=ndjson", "--print-run-meta"}, "", nil, false, true, "both write to stdout", collector.Selection{}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			id, pass, printMeta, err := parseRunArgs(tt.args)
+			ra, err := parseRunArgs(tt.args)
+			id, pass, printMeta, sel := ra.ID, ra.Passthrough, ra.PrintRunMeta, ra.Selection
 			if tt.wantErr {
 				if err == nil {
 					t.Fatalf("parseRunArgs() = %q, %v, %v, nil; want error containing %q", id, pass, printMeta, tt.wantErrMsg)
@@ -178,10 +232,118 @@ func TestParseRunArgs(t *testing.T) {
 			if printMeta != tt.wantPrintMeta {
 				t.Errorf("parseRunArgs() printMeta = %v, want %v", printMeta, tt.wantPrintMeta)
 			}
+			if !sliceEqual(sel.Enable, tt.wantSel.Enable) || !sliceEqual(sel.Disable, tt.wantSel.Disable) {
+				t.Errorf("parseRunArgs() selection = %+v, want %+v", sel, tt.wantSel)
+			}
 		})
 	}
 }
 
+func TestApplyScope(t *testing.T) {
+	t.Setenv(collector.ScopeEnv, "")
+	dir := t.TempDir()
+	if ra, err := parseRunArgs([]string{"storage", "--scope", dir, "--scope=" + dir + "/", "--", "--ndjson"}); err != nil || len(ra.Scope) != 2 {
+		t.Fatalf("parseRunArgs() scope = %v, %v", ra.Scope, err)
+	}
+	if _, err := parseRunArgs([]string{"storage", "--scope"}); err == nil {
+		t.Error("parseRunArgs() accepted --scope without a path")
+	}
+
+	disabled, err := applyScope("storage", []string{dir}, []string{"network"}, "linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(disabled, []string{"network"}) || os.Getenv(collector.ScopeEnv) != dir {
+		t.Errorf("applyScope(storage) = %v, %s=%q", disabled, collector.ScopeEnv, os.Getenv(collector.ScopeEnv))
+	}
+	disabled, err = applyScope("full", []string{dir}, nil, "linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range collector.All() {
+		if slices.Contains(disabled, c.ID) == c.Scoped {
+			t.Errorf("applyScope(full) disabled = %v; scoped collectors must run and the rest must not", disabled)
+			break
+		}
+	}
+	if _, err := applyScope("network", []string{dir}, nil, "linux"); err == nil || !strings.Contains(err.Error(), "cannot be scoped") {
+		t.Errorf("applyScope(network) error = %v", err)
+	}
+	if _, err := applyScope("storage", []string{filepath.Join(dir, "missing")}, nil, "linux"); err == nil {
+		t.Error("applyScope() accepted a missing path")
+	}
+}
+
+func TestCollectedRows(t *testing.T) {
+	baseline := []diff.Row{
+		{"type": "local_users", "items": []any{}},
+		{"type": "listening_ports", "items": []any{}},
+		{"type": "probe_failed", "probe": "identity.dscl_users"},
+		{"type": "probe_failed", "probe": "network.lsof_listen"},
+		{"type": "probe_failures_summary", "items": []any{
+			map[string]any{"probe": "identity.dscl_users", "count": 1.0},
+			map[string]any{"probe": "network.lsof_listen", "count": 2.0},
+		}},
+	}
+	current := []diff.Row{
+		{"type": "local_users", "items": []any{}},
+		{"type": "probe_failed", "probe": "identity.dscl_users"},
+		{"type": "probe_failures_summary", "items": []any{}},
+	}
+
+	got := collectedRows(baseline, current, []string{"identity", "persistence"})
+	if len(got) != 3 || got[0]["type"] != "local_users" || got[1]["probe"] != "identity.dscl_users" {
+		t.Fatalf("collectedRows() = %v, want local_users, the identity probe failure, and the summary", got)
+	}
+	if items := got[2]["items"].([]any); len(items) != 1 || items[0].(map[string]any)["probe"] != "identity.dscl_users" {
+		t.Errorf("probe_failures_summary items = %v, want only identity.dscl_users", items)
+	}
+	if items := baseline[4]["items"].([]any); len(items) != 2 {
+		t.Errorf("collectedRows() changed the baseline summary: %v", items)
+	}
+}
+
+func TestNewUSBDevices(t *testing.T) {
+	devices := func(items ...any) []diff.Row {
+		return []diff.Row{{"type": "usb_devices", "items": items}}
+	}
+	device := func(pid, product string, classes ...any) map[string]any {
+		return map[string]any{"vendor_id": "03eb", "product_id": pid, "product": product, "serial": "", "classes": classes}
+	}
+	baseline := devices(device("0001", "Keyboard", "hid"), device("0002", "Hub", "hub"))
+	current := devices(
+		device("0001", "Keyboard", "hid"),
+		device("0002", "Hub", "hub", "mass_storage"),
+		device("0003", "", "hid", "mass_storage"),
+		device("0004", "Webcam", "video"),
+	)
+	got := newUSBDevices(baseline, current)
+	want := []string{"Hub (03eb:0002): mass_storage", "03eb:0003: hid, mass_storage"}
+	if !slices.Equal(got, want) {
+		t.Errorf("newUSBDevices() = %q, want %q", got, want)
+	}
+}
+
+func TestReadNetworkContext(t *testing.T) {
+	tests := []struct {
+		row  diff.Row
+		want string
+	}{
+		{diff.Row{"type": "network_context", "id": "3f9a1c0b22de", "ssid": "CoffeeShop", "interface": "wlan0", "gateway": "10.0.0.1", "vpn": false}, `Wi-Fi "CoffeeShop" [3f9a1c0b22de]`},
+		{diff.Row{"type": "network_context", "id": "3f9a1c0b22de", "ssid": "<ssid>", "interface": "wlan0", "gateway": "10.0.0.1", "vpn": true}, "10.0.0.1 on wlan0 over VPN [3f9a1c0b22de]"},
+		{diff.Row{"type": "network_context", "id": "e3b0c44298fc", "ssid": "", "interface": "", "gateway": "", "vpn": false}, "no default route [e3b0c44298fc]"},
+	}
+	for _, tt := range tests {
+		got, ok := readNetworkContext([]diff.Row{{"type": "interfaces"}, tt.row})
+		if !ok || got.Label != tt.want || got.ID != tt.row["id"] {
+			t.Errorf("readNetworkContext(%v) = %+v, %v, want label %q", tt.row, got, ok, tt.want)
+		}
+	}
+	if _, ok := readNetworkContext([]diff.Row{{"type": "interfaces"}}); ok {
+		t.Error("readNetworkContext() without a network_context row reported one")
+	}
+}
+
 func TestDetectOS(t *testing.T) {
 	got, err := detectOS()
 	if err != nil {
@@ -683,6 +845,63 @@ func TestLinuxProbeFailureWarningDetailsUseSummarySource(t *testing.T) {
 	}
 }
 
+func TestCertificateItemsEscapeSubjects(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bash helper test requires a Unix shell")
+	}
+	cwd, _ := os.Getwd()
+	for d := cwd; d != ""; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			cwd = d
+			break
+		}
+	}
+
+	// openssl -nameopt RFC2253 escapes commas in names with a backslash, as
+	// in the stock DigiCert roots.
+	subject := `CN=DigiCert Global Root G2,O=DigiCert\, Inc.,C=US`
+	issuer := "CN=\"Quoted\" CA\x01,O=Example"
+	line := strings.Join([]string{"bundle", subject, issuer, "2038-01-15T12:00:00Z", "4400", "df3c24", "true", "false"}, "\t")
+	for _, osName := range []string{"linux", "mac"} {
+		t.Run(osName, func(t *testing.T) {
+			tmp := t.TempDir()
+			cmd := exec.Command("bash", "-c", `source "$1"; certificate_items`, "bash", filepath.Join(cwd, "audit", osName, "lib", "common.sh"))
+			cmd.Dir = cwd
+			cmd.Stdin = strings.NewReader(line + "\n")
+			cmd.Env = append(os.Environ(),
+				"AUDIT_INIT_LOADED=1",
+				"NO_COLOR=true",
+				"NDJSON_FILE="+filepath.Join(tmp, "out.ndjson"),
+				"RUN_ID=test-run",
+				"REPORT_FILE="+filepath.Join(tmp, "report.md"),
+				"SOFT_FAILURE_LOG="+filepath.Join(tmp, "soft.log"),
+				"REDACT_PATHS=false",
+				"REDACT_ALL=false",
+				"HOME_DIR="+tmp,
+				"CURRENT_USER=kareem",
+				"HOSTNAME_VAL=test-host",
+			)
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("certificate_items: %v\n%s", err, stderr.String())
+			}
+			var items []struct {
+				Subject  string `json:"subject"`
+				Issuer   string `json:"issuer"`
+				DaysLeft int    `json:"days_left"`
+			}
+			if err := json.Unmarshal([]byte("["+strings.TrimSpace(string(out))+"]"), &items); err != nil {
+				t.Fatalf("items are not valid JSON: %v\n%s", err, out)
+			}
+			if len(items) != 1 || items[0].Subject != subject || items[0].Issuer != issuer || items[0].DaysLeft != 4400 {
+				t.Errorf("items = %+v, want subject %q and issuer %q", items, subject, issuer)
+			}
+		})
+	}
+}
+
 func buildOSAuditBinary(t *testing.T, root string) string {
 	t.Helper()
 
@@ -694,3 +913,767 @@ func buildOSAuditBinary(t *testing.T, root string) string {
 	}
 	return bin
 }
+
+func TestConfigSetUnset(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("OSAUDIT_HOME", home)
+
+	if code := runConfig([]string{"set", "classify.severity.exact[config.fdesetup_status]", "low"}); code != exitcode.OK {
+		t.Fatalf("config set = %d", code)
+	}
+	if code := runConfig([]string{"set", "classify.topics[net.]", "Network"}); code != exitcode.OK {
+		t.Fatalf("config set = %d", code)
+	}
+	for _, args := range [][]string{
+		{"set", "classify.severity.exact.x", "urgent"},      // invalid severity
+		{"set", "classify.severity.exactly.x", "low"},       // unknown field
+		{"set", "alerts.slack.webhook_url", "https://x"},    // unknown file
+		{"set", "collectors.disable", "[execution, bogus]"}, // unknown collector
+		{"set", "config.redact", "some"},                    // invalid redaction
+		{"set", "config.notify.urls", "[ftp://x]"},          // not a webhook URL
+	} {
+		if code := runConfig(args); code != exitcode.Usage {
+			t.Errorf("config %v = %d, want %d", args, code, exitcode.Usage)
+		}
+	}
+
+	if code := runConfig([]string{"set", "config.redact", "all"}); code != exitcode.OK {
+		t.Fatalf("config set config.redact = %d", code)
+	}
+
+	path := filepath.Join(home, "classify.yaml")
+	var c diff.Classification
+	data, _ := os.ReadFile(path)
+	if err := yamlite.Unmarshal(data, &c); err != nil {
+		t.Fatalf("classify.yaml: %v\n%s", err, data)
+	}
+	if c.Severity.Exact["config.fdesetup_status"] != "low" || c.Topics["net."] != "Network" {
+		t.Errorf("classify.yaml = %s", data)
+	}
+
+	if code := runConfig([]string{"unset", "classify.severity.exact[config.fdesetup_status]"}); code != exitcode.OK {
+		t.Fatalf("config unset = %d", code)
+	}
+	data, _ = os.ReadFile(path)
+	if want := "topics:\n  net.: Network\n"; string(data) != want {
+		t.Errorf("classify.yaml after unset = %q, want %q", data, want)
+	}
+}
+
+func TestCI(t *testing.T) {
+	t.Setenv("OSAUDIT_HOME", t.TempDir())
+	baseline := filepath.Join("..", "..", "tests", "fixtures", "probe_diff_baseline.ndjson")
+	current := filepath.Join("..", "..", "tests", "fixtures", "probe_diff_current.ndjson")
+	artifacts := t.TempDir()
+
+	ci := func(args ...string) int {
+		t.Helper()
+		stdout := os.Stdout
+		os.Stdout, _ = os.Open(os.DevNull)
+		defer func() { os.Stdout = stdout }()
+		return runCI(nil, "", "linux", append([]string{"--snapshot", current}, args...))
+	}
+
+	if code := ci("--baseline", filepath.Join(artifacts, "missing.ndjson")); code != exitcode.Usage {
+		t.Errorf("ci with missing baseline = %d, want %d", code, exitcode.Usage)
+	}
+	if code := ci("--baseline", baseline, "--fail-on", "urgent"); code != exitcode.Usage {
+		t.Errorf("ci --fail-on urgent = %d, want %d", code, exitcode.Usage)
+	}
+	// The fixtures differ by one new high-severity probe failure and three medium ones.
+	var findings []string
+	stop := bus.Subscribe(func(e events.Event) { findings = append(findings, e.Severity+" "+e.Finding) }, events.FindingEmitted)
+	if code := ci("--baseline", baseline, "--fail-on", "none"); code != exitcode.OK {
+		t.Errorf("ci --fail-on none = %d, want %d", code, exitcode.OK)
+	}
+	stop()
+	if len(findings) != 4 || !slices.ContainsFunc(findings, func(f string) bool { return strings.HasPrefix(f, "high probe_failure:") }) {
+		t.Errorf("ci published findings %q, want the four drift findings", findings)
+	}
+	if code := ci("--baseline", baseline, "--artifacts", artifacts); code != exitcode.Drift {
+		t.Errorf("ci = %d, want %d", code, exitcode.Drift)
+	}
+
+	junit, err := os.ReadFile(filepath.Join(artifacts, "junit.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(junit), `<testsuite name="osaudit.drift" tests="4" failures="1">`) {
+		t.Errorf("junit.xml lacks the gated drift suite:\n%s", junit)
+	}
+	summary, _ := os.ReadFile(filepath.Join(artifacts, "summary.md"))
+	if !strings.Contains(string(summary), "**failed** (exit 3, drift)") {
+		t.Errorf("summary.md:\n%s", summary)
+	}
+	if _, err := os.Stat(filepath.Join(artifacts, "snapshot.ndjson")); err != nil {
+		t.Errorf("snapshot.ndjson not written: %v", err)
+	}
+
+	updated := filepath.Join(artifacts, "baseline", "linux.ndjson")
+	if code := ci("--baseline", updated, "--update-baseline"); code != exitcode.OK {
+		t.Errorf("ci --update-baseline = %d, want %d", code, exitcode.OK)
+	}
+	if code := ci("--baseline", updated); code != exitcode.OK {
+		t.Errorf("ci against the updated baseline = %d, want %d", code, exitcode.OK)
+	}
+}
+
+func TestBaselineApprove(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("OSAUDIT_HOME", t.TempDir())
+	baseline := filepath.Join("..", "..", "tests", "fixtures", "probe_diff_baseline.ndjson")
+	current := filepath.Join("..", "..", "tests", "fixtures", "probe_diff_current.ndjson")
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"config", "user.name", "osaudit"},
+		{"config", "user.email", "osaudit@example.com"},
+	} {
+		if err := git(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	baselineCmd := func(args ...string) int {
+		t.Helper()
+		stdout := os.Stdout
+		os.Stdout, _ = os.Open(os.DevNull)
+		defer func() { os.Stdout = stdout }()
+		return runBaseline(args)
+	}
+
+	if code := baselineCmd("approve", "--repo", t.TempDir(), baseline); code != exitcode.Usage {
+		t.Errorf("approve outside a git repository = %d, want %d", code, exitcode.Usage)
+	}
+	for _, snapshot := range []string{baseline, baseline, current} {
+		if code := baselineCmd("approve", "--repo", repo, "--name", "web", snapshot); code != exitcode.OK {
+			t.Fatalf("approve %s = %d, want %d", snapshot, code, exitcode.OK)
+		}
+	}
+	out, err := exec.Command("git", "-C", repo, "log", "--format=%s").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Approving an unchanged snapshot does not commit.
+	subjects := strings.Split(strings.TrimSpace(string(out)), "\n")
+	want := []string{"Approve baseline web: 4 findings", "Add baseline web"}
+	if !slices.Equal(subjects, want) {
+		t.Errorf("commits = %q, want %q", subjects, want)
+	}
+
+	if code := baselineCmd("diff", "--repo", repo, "--name", "web", "--ndjson", current); code != exitcode.OK {
+		t.Errorf("diff against the approved baseline = %d, want %d", code, exitcode.OK)
+	}
+	if code := baselineCmd("diff", "--repo", repo, "--name", "web", "--rev", "HEAD~1", "--ndjson", current); code != exitcode.Drift {
+		t.Errorf("diff against HEAD~1 = %d, want %d", code, exitcode.Drift)
+	}
+
+	// A context keeps its own baseline; one without falls back to the host's.
+	if code := baselineCmd("approve", "--repo", repo, "--name", "web", "--context", "office", baseline); code != exitcode.OK {
+		t.Fatalf("approve --context office = %d, want %d", code, exitcode.OK)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "web@office.ndjson")); err != nil {
+		t.Errorf("approve --context office did not write web@office.ndjson: %v", err)
+	}
+	if code := baselineCmd("diff", "--repo", repo, "--name", "web", "--context", "office", "--ndjson", baseline); code != exitcode.OK {
+		t.Errorf("diff --context office against its baseline = %d, want %d", code, exitcode.OK)
+	}
+	if code := baselineCmd("diff", "--repo", repo, "--name", "web", "--context", "travel", "--ndjson", current); code != exitcode.OK {
+		t.Errorf("diff --context travel, which has no baseline = %d, want %d", code, exitcode.OK)
+	}
+	if code := baselineCmd("diff", "--repo", repo, "--name", "web", "--context", "../x", current); code != exitcode.Usage {
+		t.Errorf("diff --context ../x = %d, want %d", code, exitcode.Usage)
+	}
+}
+
+func TestFinalizeInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	if err := os.WriteFile(path, []byte("{\"type\":\"meta\",\"run_id\":\"r1\"}\n{\"type\":\"ports\",\"co"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ie := &interruptError{sig: syscall.SIGTERM}
+	for range 2 {
+		if err := finalizeInterrupted(path, "r1", ie); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("finalized snapshot = %q, want meta and one run_summary", data)
+	}
+	var summary map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary["type"] != "run_summary" || summary["status"] != "interrupted" || summary["signal"] != "TERM" {
+		t.Errorf("run_summary = %v", summary)
+	}
+	if !errors.Is(ie, exitcode.ErrInterrupted) || exitcode.Of(ie) != exitcode.Interrupted {
+		t.Errorf("exitcode.Of(%v) = %d, want %d", ie, exitcode.Of(ie), exitcode.Interrupted)
+	}
+}
+
+func TestCheckAgainstStore(t *testing.T) {
+	repoRoot := t.TempDir()
+	now := time.Now()
+	store := func(host string, age time.Duration, firewall bool) {
+		t.Helper()
+		dir := filepath.Join(repoRoot, "output", "config-audit", now.Add(-age).Format("20060102-150405"))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		rows := fmt.Sprintf("{\"type\":\"meta\",\"hostname\":%q}\n{\"type\":\"security_config\",\"firewall\":%t}\n", host, firewall)
+		if err := os.WriteFile(filepath.Join(dir, "config-audit.ndjson"), []byte(rows), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store("web1", time.Hour, false)
+	store("web1", 50*time.Hour, false)
+	store("db1", 2*time.Hour, true)
+	store("db1", 60*24*time.Hour, false) // outside the window
+	policyPath := filepath.Join(repoRoot, "policy.yaml")
+	if err := os.WriteFile(policyPath, []byte("name: new\nrules:\n  - id: firewall-on\n    expr: security_config.firewall == true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(args ...string) (int, string) {
+		t.Helper()
+		stdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		code := runCheck(repoRoot, args)
+		w.Close()
+		os.Stdout = stdout
+		out, _ := io.ReadAll(r)
+		return code, string(out)
+	}
+	if code, _ := check("--policy", policyPath, "--against", "store://last-month"); code != exitcode.Usage {
+		t.Errorf("check --against store://last-month = %d, want %d", code, exitcode.Usage)
+	}
+	code, out := check("--policy", policyPath, "--against", "store://last-30d", "--ndjson")
+	if code != exitcode.OK {
+		t.Fatalf("check --against = %d, want %d\n%s", code, exitcode.OK, out)
+	}
+	for _, want := range []string{
+		`"check":"policy_history_host","host":"web1","policy":"new","rule":"firewall-on","snapshots":2,"type":"check","unknown":0,"violations":2`,
+		`"check":"policy_history_host","host":"db1","policy":"new","rule":"firewall-on","snapshots":1,"type":"check","unknown":0,"violations":0`,
+		`"check":"policy_history_summary","hosts":2,"policy":"new","skipped":0,"snapshots":3,"type":"check","violations":2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("check --against output lacks %s:\n%s", want, out)
+		}
+	}
+	if code, out := check("--policy", policyPath, "--against", "store://all"); code != exitcode.OK || !strings.Contains(out, "3 finding(s) on 2 of 2 host(s)") {
+		t.Errorf("check --against store://all = %d:\n%s", code, out)
+	}
+}
+
+func TestForensic(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("audit scripts only exist for linux/mac")
+	}
+	cwd, _ := os.Getwd()
+	root := filepath.Dir(filepath.Dir(cwd))
+	bin := buildOSAuditBinary(t, root)
+	tmp, home := t.TempDir(), t.TempDir()
+	osaudit := func(args ...string) (string, int) {
+		cmd := exec.Command(bin, append([]string{"--forensic"}, args...)...)
+		cmd.Dir = root
+		cmd.Env = append(os.Environ(), "OSAUDIT_ROOT="+root, "OSAUDIT_HOME="+home, "TMPDIR="+tmp)
+		out, err := cmd.CombinedOutput()
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return string(out), ee.ExitCode()
+		}
+		return string(out), 0
+	}
+
+	for _, args := range [][]string{
+		{"schedule", "install", "execution"},
+		{"config", "set", "collectors.disable", "network"},
+		{"run", "execution", "--", "--report-dir", tmp},
+	} {
+		if out, code := osaudit(args...); code != exitcode.Usage || !strings.Contains(out, "forensic mode") {
+			t.Errorf("--forensic %v = %d, want %d\n%s", args, code, exitcode.Usage, out)
+		}
+	}
+
+	out, code := osaudit("run", "execution", "--print-run-meta", "--", "--ndjson", "--redact-all")
+	if code != exitcode.OK {
+		t.Fatalf("--forensic run = %d\n%s", code, out)
+	}
+	if !strings.Contains(out, "output/execution-audit/") {
+		t.Errorf("--forensic run meta does not name output/execution-audit/:\n%s", out)
+	}
+	for _, dir := range []string{tmp, home} {
+		if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+			t.Errorf("--forensic run wrote to %s: %v", dir, entries)
+		}
+	}
+}
+
+func TestPostNotifications(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	defer func() { userSettings = settings.Settings{} }()
+	userSettings.Notify.URLs = []string{srv.URL + "/hook"}
+
+	postNotifications("OS Audit: changes", "Audit full found changes.")
+	if got["title"] != "OS Audit: changes" || got["text"] != "OS Audit: changes\nAudit full found changes." {
+		t.Errorf("webhook body = %v", got)
+	}
+	if got := redactURL("https://hooks.example.com/services/T0/B0/secret"); got != "https://hooks.example.com/…" {
+		t.Errorf("redactURL() = %q", got)
+	}
+}
+
+func TestWriteTriageBundle(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "triage", "20261016-093820")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"full-audit-20261016-093820.md":     "# report\n",
+		"full-audit-20261016-093820.ndjson": `{"type":"recent_logins","count":0,"items":[]}` + "\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bundle := filepath.Join(filepath.Dir(dir), "triage-20261016-093820.tar.gz")
+	if err := writeTriageBundle(bundle, dir, latest.RunMeta{RunID: "r1", AuditID: fullAuditID}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	got := map[string]string{}
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		got[strings.TrimPrefix(hdr.Name, "triage-20261016-093820/")] = string(data)
+	}
+	if len(names) != 4 || names[len(names)-1] != "triage-20261016-093820/SHA256SUMS" {
+		t.Fatalf("bundle entries = %v", names)
+	}
+	for name, body := range files {
+		if got[name] != body {
+			t.Errorf("%s = %q, want %q", name, got[name], body)
+		}
+		sum := sha256.Sum256([]byte(body))
+		if line := hex.EncodeToString(sum[:]) + "  " + name + "\n"; !strings.Contains(got["SHA256SUMS"], line) {
+			t.Errorf("SHA256SUMS lacks %q:\n%s", line, got["SHA256SUMS"])
+		}
+	}
+	if !strings.Contains(got["run-meta.json"], `"run_id": "r1"`) {
+		t.Errorf("run-meta.json = %s", got["run-meta.json"])
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(dir), ".triage-*")); len(tmp) > 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestCheckInjections(t *testing.T) {
+	inj := func() []*injection {
+		return []*injection{
+			{Change: "autostart entry", Collector: "persistence", RowType: "xdg_autostart", Key: "/" + selftestLabel + ".desktop"},
+			{Change: "listening socket", Collector: "network", RowType: "listening_ports", Key: ":40123"},
+		}
+	}
+	baseline := []diff.Row{{"type": "xdg_autostart", "items": []any{}}, {"type": "listening_ports", "items": []any{}}}
+	current := []diff.Row{
+		{"type": "xdg_autostart", "items": []any{map[string]any{"path": "~/.config/autostart/" + selftestLabel + ".desktop", "name": "osaudit selftest"}}},
+		{"type": "listening_ports", "items": []any{map[string]any{"process": "osaudit", "pid": float64(7), "port": float64(40123)}}},
+	}
+
+	all := inj()
+	if missed := checkInjections(all, baseline, current, nil, nil); missed != 0 || !all[0].Detected || !all[1].Detected {
+		t.Errorf("checkInjections = %d missed, %+v %+v", missed, *all[0], *all[1])
+	}
+
+	// A disabled collector writes no rows, and an ignore rule hides what it
+	// matches; both say why.
+	some := inj()
+	ignored := func(key string) bool { return strings.HasPrefix(key, "inventory:listening_ports") }
+	if missed := checkInjections(some, baseline, current[1:], []string{"persistence"}, ignored); missed != 2 {
+		t.Fatalf("checkInjections = %d missed, want 2", missed)
+	}
+	if some[0].Reason != "collector persistence is disabled" || some[1].Reason != "suppressed by ignore rules" {
+		t.Errorf("reasons = %q, %q", some[0].Reason, some[1].Reason)
+	}
+}
+
+func TestSnapshotOutput(t *testing.T) {
+	ra, err := parseRunArgs([]string{"--all", "--output-dir", "snaps", "--format", "ndjson", "--", "--deep"})
+	pass, out := ra.Passthrough, ra.Output
+	if err != nil || out.Dir != "snaps" || !out.Stdout || !sliceEqual(withNDJSON(pass), []string{"--ndjson", "--deep"}) {
+		t.Fatalf("parseRunArgs() = %v, %+v, %v", pass, out, err)
+	}
+	start := time.Date(2026, 3, 2, 10, 9, 7, 0, time.Local)
+	if got, want := out.path("mbp.local", start), filepath.Join("snaps", "mbp.local-20260302-100907.ndjson"); got != want {
+		t.Errorf("path() = %q, want %q", got, want)
+	}
+	if got := (snapshotOutput{File: "x.ndjson"}).path("mbp", start); got != "x.ndjson" {
+		t.Errorf("path() with --output = %q", got)
+	}
+
+	root := t.TempDir()
+	src := filepath.Join(root, "output", "full-audit", "20260302-100907", "audit.ndjson")
+	if err := os.MkdirAll(filepath.Dir(src), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte(`{"type":"meta"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(root, "snaps", "latest.ndjson")
+	meta := latest.RunMeta{NDJSON: "output/full-audit/20260302-100907/audit.ndjson"}
+	if err := (snapshotOutput{File: dst}).write(root, meta, start); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != `{"type":"meta"}`+"\n" {
+		t.Errorf("written snapshot = %q, %v", data, err)
+	}
+}
+
+func TestKeepInterruptedSnapshot(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "output", "full-audit", "20260302-100907", "audit.ndjson")
+	if err := os.MkdirAll(filepath.Dir(src), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte(`{"type":"meta"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	meta := latest.RunMeta{NDJSON: "output/full-audit/20260302-100907/audit.ndjson"}
+	start := time.Date(2026, 3, 2, 10, 9, 7, 0, time.Local)
+
+	failed := filepath.Join(root, "failed.ndjson")
+	keepInterruptedSnapshot(root, meta, snapshotOutput{File: failed}, start, errors.New("audit script exited 1"))
+	if _, err := os.Stat(failed); !os.IsNotExist(err) {
+		t.Errorf("snapshot of a failed run written: %v", err)
+	}
+	interrupted := filepath.Join(root, "interrupted.ndjson")
+	keepInterruptedSnapshot(root, meta, snapshotOutput{File: interrupted}, start, &interruptError{sig: os.Interrupt})
+	if _, err := os.Stat(interrupted); err != nil {
+		t.Errorf("partial snapshot of an interrupted run not written: %v", err)
+	}
+}
+
+func TestStateArchiveRoundTrip(t *testing.T) {
+	oldRoot := t.TempDir()
+	oldConfig, oldOutput := filepath.Join(oldRoot, "home"), filepath.Join(oldRoot, "output")
+	files := map[string]string{
+		filepath.Join(oldConfig, "ignore.json"):                               `[{"pattern":"inventory:usb_devices"}]`,
+		filepath.Join(oldConfig, "rules", "local.yaml"):                       "rules: []\n",
+		filepath.Join(oldConfig, "cache", "hashes.json"):                      "{}",
+		filepath.Join(oldOutput, "full-audit", "20261016-090320", "a.ndjson"): `{"type":"meta"}` + "\n",
+		filepath.Join(oldOutput, "full-audit", ".latest.json"):                `{"run_id":"r1","dir":"output/full-audit/20261016-090320","ndjson":"output/full-audit/20261016-090320/a.ndjson"}`,
+		filepath.Join(oldOutput, ".pkghook", "apt.offset"):                    "42",
+	}
+	for path, body := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := filepath.Join(t.TempDir(), "state.tar.gz")
+	manifest := stateManifest{Format: stateFormat, Hostname: "old", Root: oldRoot, OutputDir: oldOutput}
+	n, err := writeStateArchive(archive, manifest, map[string]string{stateConfig: oldConfig, stateOutput: oldOutput})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("exported %d files, want 4 (no hash cache or package log offsets)", n)
+	}
+
+	newRoot := t.TempDir()
+	newConfig, newOutput := filepath.Join(newRoot, "home"), filepath.Join(newRoot, "snapshots")
+	if err := os.MkdirAll(newConfig, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(newConfig, "ignore.json"), []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sections := map[string]string{stateConfig: newConfig, stateOutput: newOutput}
+	res, err := readStateArchive(archive, newRoot, sections, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Written != 3 || res.Kept != 1 || res.Rebased != 1 || res.Manifest.Hostname != "old" {
+		t.Errorf("import = %+v, want 3 written, 1 kept, 1 rebased", res)
+	}
+	if data, _ := os.ReadFile(filepath.Join(newConfig, "ignore.json")); string(data) != "[]" {
+		t.Errorf("existing ignore.json replaced without --force: %s", data)
+	}
+	var meta latest.RunMeta
+	data, err := os.ReadFile(filepath.Join(newOutput, "full-audit", ".latest.json"))
+	if err != nil || json.Unmarshal(data, &meta) != nil {
+		t.Fatalf(".latest.json = %s, %v", data, err)
+	}
+	if meta.NDJSON != "snapshots/full-audit/20261016-090320/a.ndjson" || meta.RunID != "r1" {
+		t.Errorf("rebased .latest.json = %+v", meta)
+	}
+	if _, err := os.Stat(repoPath(newRoot, meta.NDJSON)); err != nil {
+		t.Errorf("rebased baseline does not resolve: %v", err)
+	}
+
+	if res, err = readStateArchive(archive, newRoot, sections, true); err != nil || res.Written != 4 {
+		t.Errorf("import --force = %+v, %v", res, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(newConfig, "ignore.json")); !strings.Contains(string(data), "usb_devices") {
+		t.Errorf("ignore.json not replaced with --force: %s", data)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	b := &events.Bus{}
+	var buf bytes.Buffer
+	stop := startProgress(b, &buf, 2, time.Hour)
+	t0 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	b.Publish(events.Event{Kind: events.CollectorStarted, Time: t0, Collector: "storage"})
+	b.Publish(events.Event{Kind: events.SectionFinished, Time: t0.Add(time.Minute), Section: "large_files"})
+	b.Publish(events.Event{Kind: events.CollectorFinished, Time: t0.Add(2 * time.Minute), Collector: "storage", ElapsedMS: 123456})
+	b.Publish(events.Event{Kind: events.CollectorStarted, Time: t0.Add(2 * time.Minute), Collector: "network"})
+	stop()
+	b.Publish(events.Event{Kind: events.CollectorFinished, Time: t0.Add(3 * time.Minute), Collector: "network"})
+
+	want := "[1/2] storage started, 0s elapsed\n" +
+		"[1/2] storage done in 2m3s, 1 remaining\n" +
+		"[2/2] network started, 2m0s elapsed\n"
+	if buf.String() != want {
+		t.Errorf("progress =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestMenu(t *testing.T) {
+	cmds := []auditCommand{{ID: "full", Display: "Full audit"}, {ID: "network", Display: "Network"}, {ID: "storage", Display: "Storage"}}
+	m := newMenu(cmds, "linux")
+	press := func(keys string) (last menuAction) {
+		for _, k := range term.ParseKeys([]byte(keys)) {
+			last = m.key(k)
+		}
+		return last
+	}
+
+	// Down to Network, select it and Storage, then run both in order.
+	if got := press("\x1b[B \x1b[B \r"); got != actionStart {
+		t.Fatalf("enter = %v, want actionStart", got)
+	}
+	if m.screen != screenRun || len(m.runs) != 2 || m.runs[0].Command.ID != "network" || m.runs[1].Command.ID != "storage" {
+		t.Fatalf("runs = %+v on screen %v", m.runs, m.screen)
+	}
+
+	run := m.runs[0]
+	run.Started = true
+	run.write([]byte("\x1b[1;32mScanning\x1b[0m\tports\nworking 10%\rworking 100%\r\npart"))
+	run.write([]byte("ial\n"))
+	if want := []string{"Scanning    ports", "working 100%", "partial"}; !slices.Equal(run.Output, want) {
+		t.Errorf("output = %q, want %q", run.Output, want)
+	}
+	if got := press("\x03"); got != actionCancel || !m.stopping {
+		t.Errorf("ctrl-c = %v, stopping %v; want actionCancel", got, m.stopping)
+	}
+	if got := press("\x03"); got != actionNone {
+		t.Errorf("second ctrl-c = %v, want actionNone", got)
+	}
+
+	run.Done, run.Err, run.Meta.Report = true, &interruptError{sig: os.Interrupt}, "/home/u/report.html"
+	m.screen = screenSummary
+	view := strings.Join(m.view(80, 6, time.Now()), "\n")
+	for _, want := range []string{"■ Network", "interrupted", "/home/u/report.html", "· Storage", "skipped"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("summary missing %q:\n%s", want, view)
+		}
+	}
+	if lines := m.view(20, 6, time.Now()); len(lines) != 6 || utf8.RuneCountInString(lines[2]) > 20 {
+		t.Errorf("view(20, 6) = %q, want 6 lines of at most 20 cells", lines)
+	}
+
+	press("\r")
+	if m.screen != screenOutput {
+		t.Fatalf("enter on summary: screen %v, want output", m.screen)
+	}
+	press("\x1b[5~")
+	if m.scroll != len(run.Output) {
+		t.Errorf("pgup scroll = %d, want %d", m.scroll, len(run.Output))
+	}
+	press("\x1bm")
+	if m.screen != screenSelect || slices.Contains(m.selected, true) {
+		t.Errorf("back to menu: screen %v, selected %v", m.screen, m.selected)
+	}
+
+	// With nothing selected, enter runs the command under the cursor; a
+	// toggles every command.
+	press("\r")
+	if len(m.runs) != 1 || m.runs[0].Command.ID != "storage" {
+		t.Errorf("enter with no selection ran %+v, want storage", m.runs)
+	}
+	m.screen = screenSelect
+	press("a")
+	if slices.Contains(m.selected, false) {
+		t.Errorf("a selected %v, want all", m.selected)
+	}
+	if got := press("q"); got != actionQuit {
+		t.Errorf("q = %v, want actionQuit", got)
+	}
+}
+
+func TestFreeRunStampSkipsTakenNames(t *testing.T) {
+	parent := t.TempDir()
+	const stamp = "20260301-100000"
+	if got := freeRunStamp(parent, stamp); got != stamp {
+		t.Errorf("freeRunStamp() = %q, want %q with nothing stored", got, stamp)
+	}
+	for n := 1; n <= 10; n++ {
+		if err := os.Mkdir(filepath.Join(parent, freeRunStamp(parent, stamp)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := freeRunStamp(parent, stamp), stamp+"-011"; got != want {
+		t.Errorf("freeRunStamp() = %q, want %q", got, want)
+	}
+	// Names sort in the order the runs took them.
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entries[len(entries)-1].Name(); got != stamp+"-010" {
+		t.Errorf("last stored run sorts as %q, want %q", got, stamp+"-010")
+	}
+}
+
+func TestCheckRejectsMissingRulesDir(t *testing.T) {
+	repoRoot := t.TempDir()
+	snapshot := filepath.Join(repoRoot, "snap.ndjson")
+	if err := os.WriteFile(snapshot, []byte("{\"type\":\"meta\",\"os\":\"linux\"}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(repoRoot, "no-such-rules")
+	for _, args := range [][]string{
+		{"--snapshot", snapshot, "--rules", missing},
+		{"--snapshot", snapshot, "--rules", missing, "--benchmark", "cis-linux"},
+	} {
+		stdout, stderr := os.Stdout, os.Stderr
+		r, w, _ := os.Pipe()
+		os.Stdout, os.Stderr = w, w
+		code := runCheck(repoRoot, args)
+		w.Close()
+		os.Stdout, os.Stderr = stdout, stderr
+		out, _ := io.ReadAll(r)
+		if code != exitcode.Usage || !strings.Contains(string(out), missing) {
+			t.Errorf("check %v = %d, want %d naming %s:\n%s", args, code, exitcode.Usage, missing, out)
+		}
+	}
+}
+
+func TestAgentMetrics(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	m := newAgentMetrics(time.Hour, start)
+	var b events.Bus
+	b.Subscribe(m.observe)
+	b.Publish(events.Event{Kind: events.ProbeFinished, AuditID: "full", Probe: "network.ss_listen", ExitCode: 1})
+	b.Publish(events.Event{Kind: events.ProbeFinished, AuditID: "full", Probe: "network.ip_route", ExitCode: 0})
+	b.Publish(events.Event{Kind: events.RunFinished, AuditID: "full", Time: start.Add(5 * time.Minute), ElapsedMS: 4500})
+	b.Publish(events.Event{Kind: events.FindingEmitted, AuditID: "full", Finding: "inventory:listening_ports"})
+	b.Publish(events.Event{Kind: events.NotifyPosted, Message: "https://hooks.example.com/…: HTTP 500"})
+
+	srv := httptest.NewServer(m.handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{
+		`osaudit_runs_total{audit_id="full"} 1`,
+		`osaudit_last_run_timestamp_seconds{audit_id="full"} 1772359500`,
+		`osaudit_last_run_duration_seconds{audit_id="full"} 4.5`,
+		`osaudit_last_run_exit_code{audit_id="full"} 0`,
+		`osaudit_probe_failures_total{audit_id="full",probe="network.ss_listen"} 1`,
+		`osaudit_findings_total{audit_id="full"} 1`,
+		`osaudit_notify_failures_total 1`,
+		`osaudit_last_notify_success 0`,
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("/metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(string(body), "network.ip_route") {
+		t.Errorf("/metrics counts a probe that succeeded:\n%s", body)
+	}
+
+	if ok, reason := m.health(start.Add(time.Hour)); !ok {
+		t.Errorf("health after a clean run = unhealthy: %s", reason)
+	}
+	if ok, _ := m.health(start.Add(3 * time.Hour)); ok {
+		t.Error("health three hours after the last run = healthy, want stale")
+	}
+	b.Publish(events.Event{Kind: events.RunFinished, AuditID: "full", Time: start.Add(65 * time.Minute), ExitCode: exitcode.Error})
+	if ok, reason := m.health(start.Add(70 * time.Minute)); ok || !strings.Contains(reason, "exited 1") {
+		t.Errorf("health after a failed run = %v, %q", ok, reason)
+	}
+}
+
+func TestLoopbackAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:9464": true, "localhost:9464": true, "[::1]:9464": true,
+		"0.0.0.0:9464": false, ":9464": false, "192.168.1.5:9464": false, "127.0.0.1": false,
+	} {
+		if got := loopbackAddr(addr); got != want {
+			t.Errorf("loopbackAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+// Every catalog key the command looks up must exist, or the report shows the
+// key itself.
+func TestI18nKeysInCatalog(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := regexp.MustCompile(`i18n\.T\("([^"]+)"`)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range call.FindAllStringSubmatch(string(data), -1) {
+			if i18n.T(m[1]) == m[1] {
+				t.Errorf("%s: key %q is not in the catalog", f, m[1])
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/deprecation"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
+)
+
+// runManifest runs before the manifest is loaded, so that lint can report
+// a manifest osaudit would refuse to start with.
+func runManifest(repoRoot string, args []string) int {
+	if len(args) > 0 && args[0] == "lint" {
+		return runManifestLint(repoRoot, args[1:])
+	}
+	fmt.Fprintln(os.Stderr, "manifest requires a subcommand: lint")
+	printUsage()
+	return exitcode.Usage
+}
+
+// lintFile is a manifest or config file lint checks: its format, and the
+// loader that validates it.
+type lintFile struct {
+	format string
+	path   string
+	load   func(path string) error
+}
+
+// lintProblem is a file lint could not load, as written by --ndjson.
+type lintProblem struct {
+	Type  string `json:"type"` // always "lint_error"
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// runManifestLint validates the command manifest and the config files in the
+// osaudit configuration directory, and lists the deprecated fields they use.
+// With --strict, deprecated fields fail the lint like invalid ones.
+func runManifestLint(repoRoot string, args []string) int {
+	fs := flag.NewFlagSet("manifest lint", flag.ContinueOnError)
+	strict := fs.Bool("strict", false, "Treat deprecated fields as errors")
+	ndjson := fs.Bool("ndjson", false, "Emit one JSON object per deprecation or error")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "manifest lint takes at most one manifest path")
+		printUsage()
+		return exitcode.Usage
+	}
+
+	files := []lintFile{{deprecation.Manifest, filepath.Join(repoRoot, "cli", "commands.json"), func(path string) error {
+		_, err := loadCommands(path)
+		return err
+	}}}
+	if fs.NArg() == 1 {
+		files[0].path = fs.Arg(0)
+	}
+	if dir, err := config.Dir(); err == nil {
+		files = append(files,
+			lintFile{deprecation.Collectors, filepath.Join(dir, "collectors.yaml"), func(path string) error {
+				_, err := collector.LoadSelection(path)
+				return err
+			}},
+			lintFile{deprecation.Classification, filepath.Join(dir, "classify.yaml"), diff.LoadClassificationFile},
+		)
+	}
+
+	// Loaders would print the warnings themselves; lint reports them below.
+	deprecation.Output = io.Discard
+	enc := json.NewEncoder(os.Stdout)
+	checked, problems, deprecated := 0, 0, 0
+	for _, f := range files {
+		data, err := os.ReadFile(f.path)
+		if errors.Is(err, os.ErrNotExist) && f.format != deprecation.Manifest {
+			continue // config files are optional
+		}
+		checked++
+		if err == nil {
+			err = f.load(f.path)
+		}
+		if err != nil {
+			problems++
+			if *ndjson {
+				enc.Encode(lintProblem{Type: "lint_error", File: f.path, Error: err.Error()})
+			} else {
+				fmt.Printf("Error: %v\n", err)
+			}
+			continue
+		}
+		var doc any
+		if f.format == deprecation.Manifest {
+			err = json.Unmarshal(data, &doc)
+		} else {
+			doc, err = yamlite.Parse(data)
+		}
+		if err != nil {
+			continue // the loader accepted it, so this cannot happen
+		}
+		for _, w := range deprecation.Find(f.format, f.path, doc) {
+			deprecated++
+			if *ndjson {
+				enc.Encode(w)
+			} else {
+				fmt.Printf("Warning: %s\n", w)
+			}
+		}
+	}
+
+	if !*ndjson {
+		fmt.Printf("%d files checked: %d invalid, %d deprecated fields\n", checked, problems, deprecated)
+	}
+	if problems > 0 || (*strict && deprecated > 0) {
+		return exitcode.Usage
+	}
+	return exitcode.OK
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/term"
+)
+
+// menuOutputLines caps the output the menu keeps per run. Older lines are
+// dropped; the report and snapshot on disk have everything.
+const menuOutputLines = 5000
+
+// menuRedraw is how often the menu redraws while output arrives, at most.
+const menuRedraw = 100 * time.Millisecond
+
+type menuScreen int
+
+const (
+	screenSelect  menuScreen = iota // pick the commands to run
+	screenRun                       // a run's output as it arrives
+	screenSummary                   // the results of the runs
+	screenOutput                    // one run's full output, scrollable
+)
+
+// menuAction is what the menu loop has to do after a key press.
+type menuAction int
+
+const (
+	actionNone menuAction = iota
+	actionStart
+	actionCancel
+	actionQuit
+)
+
+// menuRun is one command the menu runs.
+type menuRun struct {
+	Command auditCommand
+	Started bool
+	Done    bool
+	Elapsed time.Duration
+	Err     error
+	Meta    latest.RunMeta
+	Output  []string
+
+	partial string // output after the last newline
+}
+
+// menu is the interactive menu's state. Its methods only change state and
+// render it; runTUI does the terminal and process work.
+type menu struct {
+	commands []auditCommand
+	os       string
+	cursor   int
+	selected []bool
+	screen   menuScreen
+	runs     []*menuRun
+	current  int  // the run in progress, or the one the summary points at
+	scroll   int  // lines scrolled up from the end of the output
+	stopping bool // the current run was asked to stop
+	started  time.Time
+}
+
+func newMenu(commands []auditCommand, detectedOS string) *menu {
+	return &menu{commands: commands, os: detectedOS, selected: make([]bool, len(commands))}
+}
+
+// key handles one key press.
+func (m *menu) key(k term.Key) menuAction {
+	switch m.screen {
+	case screenSelect:
+		return m.selectKey(k)
+	case screenRun:
+		switch {
+		case k.Code == term.KeyCtrlC || k.Code == term.KeyEsc || isRune(k, 'q'):
+			if m.stopping {
+				return actionNone
+			}
+			m.stopping = true
+			return actionCancel
+		default:
+			m.scrollKey(k, m.runs[m.current])
+		}
+	case screenSummary:
+		switch {
+		case k.Code == term.KeyUp || isRune(k, 'k'):
+			m.current = max(m.current-1, 0)
+		case k.Code == term.KeyDown || isRune(k, 'j'):
+			m.current = min(m.current+1, len(m.runs)-1)
+		case k.Code == term.KeyEnter || isRune(k, 'o'):
+			m.screen, m.scroll = screenOutput, 0
+		case k.Code == term.KeyEsc || isRune(k, 'm'):
+			m.screen = screenSelect
+			clear(m.selected)
+		case k.Code == term.KeyCtrlC || isRune(k, 'q'):
+			return actionQuit
+		}
+	case screenOutput:
+		switch {
+		case k.Code == term.KeyEsc || isRune(k, 'q'):
+			m.screen = screenSummary
+		case k.Code == term.KeyCtrlC:
+			return actionQuit
+		default:
+			m.scrollKey(k, m.runs[m.current])
+		}
+	}
+	return actionNone
+}
+
+func (m *menu) selectKey(k term.Key) menuAction {
+	switch {
+	case k.Code == term.KeyUp || isRune(k, 'k'):
+		m.cursor = (m.cursor + len(m.commands) - 1) % len(m.commands)
+	case k.Code == term.KeyDown || isRune(k, 'j'):
+		m.cursor = (m.cursor + 1) % len(m.commands)
+	case k.Code == term.KeyHome:
+		m.cursor = 0
+	case k.Code == term.KeyEnd:
+		m.cursor = len(m.commands) - 1
+	case k.Code == term.KeySpace:
+		m.selected[m.cursor] = !m.selected[m.cursor]
+	case isRune(k, 'a'):
+		all := !slices.Contains(m.selected, false)
+		for i := range m.selected {
+			m.selected[i] = !all
+		}
+	case k.Code == term.KeyEnter:
+		m.runs = nil
+		for i, cmd := range m.commands {
+			if m.selected[i] {
+				m.runs = append(m.runs, &menuRun{Command: cmd})
+			}
+		}
+		if len(m.runs) == 0 {
+			m.runs = []*menuRun{{Command: m.commands[m.cursor]}}
+		}
+		m.screen, m.current, m.scroll, m.stopping = screenRun, 0, 0, false
+		return actionStart
+	case k.Code == term.KeyEsc || k.Code == term.KeyCtrlC || isRune(k, 'q'):
+		return actionQuit
+	}
+	return actionNone
+}
+
+// scrollKey scrolls run's output; scroll counts lines up from the end, so
+// new output keeps the view at the bottom unless the user scrolled away.
+func (m *menu) scrollKey(k term.Key, run *menuRun) {
+	page := 10
+	switch {
+	case k.Code == term.KeyUp || isRune(k, 'k'):
+		m.scroll++
+	case k.Code == term.KeyDown || isRune(k, 'j'):
+		m.scroll--
+	case k.Code == term.KeyPgUp:
+		m.scroll += page
+	case k.Code == term.KeyPgDn:
+		m.scroll -= page
+	case k.Code == term.KeyHome || isRune(k, 'g'):
+		m.scroll = len(run.Output)
+	case k.Code == term.KeyEnd || isRune(k, 'G'):
+		m.scroll = 0
+	}
+	m.scroll = min(max(m.scroll, 0), len(run.Output))
+}
+
+func isRune(k term.Key, r rune) bool { return k.Code == term.KeyRune && k.Rune == r }
+
+// ansiRe matches the escape sequences the audit scripts color their output
+// with, and the other CSI and OSC sequences a terminal would interpret.
+var ansiRe = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[@-Z\\-_])`)
+
+// write appends output to run, split into lines without escape sequences. A
+// carriage return starts the line over, as it would on a terminal.
+func (run *menuRun) write(b []byte) {
+	text := run.partial + string(b)
+	lines := strings.Split(text, "\n")
+	run.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		line = strings.TrimSuffix(line, "\r")
+		if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+			line = line[i+1:]
+		}
+		line = strings.ReplaceAll(ansiRe.ReplaceAllString(line, ""), "\t", "    ")
+		run.Output = append(run.Output, line)
+	}
+	if over := len(run.Output) - menuOutputLines; over > 0 {
+		run.Output = append(run.Output[:0], run.Output[over:]...)
+	}
+}
+
+// status is run's outcome as the summary shows it.
+func (run *menuRun) status() string {
+	switch {
+	case !run.Started:
+		return "skipped"
+	case !run.Done:
+		return "running"
+	case run.Err == nil:
+		return "ok"
+	case finishedPartial(run.Err):
+		return "partial"
+	case errors.Is(run.Err, exitcode.ErrInterrupted):
+		return "interrupted"
+	}
+	return fmt.Sprintf("failed (exit %d)", exitcode.Of(run.Err))
+}
+
+var menuMarks = map[string]string{"ok": "✓", "partial": "!", "interrupted": "■", "running": "▶", "skipped": "·"}
+
+func (run *menuRun) mark() string {
+	if mark, ok := menuMarks[run.status()]; ok {
+		return mark
+	}
+	return "✗"
+}
+
+// view renders the current screen as height lines of at most width cells.
+func (m *menu) view(width, height int, now time.Time) []string {
+	var lines []string
+	switch m.screen {
+	case screenSelect:
+		lines = append(lines, "Operating System Audit Tool ("+m.os+")", "")
+		for i, cmd := range m.commands {
+			cursor, box := "  ", "[ ]"
+			if i == m.cursor {
+				cursor = "> "
+			}
+			if m.selected[i] {
+				box = "[x]"
+			}
+			lines = append(lines, cursor+box+" "+cmd.Display)
+		}
+		lines = fitLines(lines, height-1)
+		lines = append(lines, "↑/↓ move · space select · a all · enter run · q quit")
+	case screenRun, screenOutput:
+		run := m.runs[m.current]
+		var head, foot string
+		if m.screen == screenRun {
+			head = fmt.Sprintf("Running %s (%d of %d) · %s", run.Command.Display, m.current+1, len(m.runs), roundDuration(now.Sub(m.started)).Round(time.Second))
+			foot = "↑/↓ pgup/pgdn scroll · ctrl-c stop"
+			if m.stopping {
+				foot = "Stopping: finishing the partial snapshot…"
+			}
+		} else {
+			head = fmt.Sprintf("Output of %s (%s)", run.Command.Display, run.status())
+			foot = "↑/↓ pgup/pgdn g/G scroll · esc back · ctrl-c quit"
+		}
+		pane := max(height-4, 1)
+		end := len(run.Output) - m.scroll
+		out := run.Output[max(end-pane, 0):end]
+		lines = append(lines, head, strings.Repeat("─", width))
+		lines = append(lines, out...)
+		lines = fitLines(lines, height-2)
+		lines = append(lines, strings.Repeat("─", width), foot)
+	case screenSummary:
+		lines = append(lines, "Results", "")
+		name := 0
+		for _, run := range m.runs {
+			name = max(name, utf8.RuneCountInString(run.Command.Display))
+		}
+		for i, run := range m.runs {
+			cursor := "  "
+			if i == m.current {
+				cursor = "> "
+			}
+			line := fmt.Sprintf("%s%s %-*s  %-16s %6s", cursor, run.mark(), name, run.Command.Display, run.status(), run.Elapsed.Round(time.Second))
+			if run.Meta.Report != "" {
+				line += "  " + run.Meta.Report
+			}
+			lines = append(lines, line)
+		}
+		lines = fitLines(lines, height-1)
+		lines = append(lines, "↑/↓ move · enter output · m menu · q quit")
+	}
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return lines
+}
+
+// fitLines pads or cuts lines to n.
+func fitLines(lines []string, n int) []string {
+	if len(lines) > n {
+		return lines[:max(n, 0)]
+	}
+	for len(lines) < n {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// truncate cuts s to width runes.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:max(width, 0)])
+}
+
+// runTUI runs the full-screen menu on the terminal: pick one or more commands
+// with the arrow keys and space, watch each run's output in a pane, and read
+// the results. It returns term.ErrUnsupported where the terminal cannot be
+// put into raw mode, and the menu falls back to numbered prompts.
+func runTUI(commands []auditCommand, detectedOS, repoRoot string) error {
+	in := int(os.Stdin.Fd())
+	restore, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	out := os.Stdout
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan []term.Key)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				keys <- term.ParseKeys(buf[:n])
+			}
+			if err != nil {
+				close(keys)
+				return
+			}
+		}
+	}()
+
+	m := newMenu(commands, detectedOS)
+	draw := func() {
+		width, height, err := term.Size(int(out.Fd()))
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		var b strings.Builder
+		b.WriteString("\x1b[H")
+		for i, line := range m.view(width, height, time.Now()) {
+			if i > 0 {
+				b.WriteString("\r\n")
+			}
+			b.WriteString(line)
+			b.WriteString("\x1b[K")
+		}
+		b.WriteString("\x1b[J")
+		io.WriteString(out, b.String())
+	}
+
+	type chunk struct {
+		run  int
+		data []byte
+	}
+	type result struct {
+		run int
+		err error
+	}
+	output := make(chan chunk)
+	done := make(chan result)
+	// Raw mode turns Ctrl-C into a key press, which cancels the run itself;
+	// SIGTERM still arrives through signalContext.
+	var cancel context.CancelCauseFunc
+	stopSignals := func() {}
+	start := func(i int) {
+		run := m.runs[i]
+		run.Started = true
+		m.current, m.scroll, m.stopping, m.started = i, 0, false, time.Now()
+		var parent, ctx context.Context
+		parent, stopSignals = signalContext()
+		ctx, cancel = context.WithCancelCause(parent)
+		go func() {
+			r, w, err := os.Pipe()
+			if err != nil {
+				done <- result{i, err}
+				return
+			}
+			copied := make(chan struct{})
+			go func() {
+				buf := make([]byte, 32*1024)
+				for {
+					n, err := r.Read(buf)
+					if n > 0 {
+						output <- chunk{i, append([]byte(nil), buf[:n]...)}
+					}
+					if err != nil {
+						close(copied)
+						return
+					}
+				}
+			}()
+			err = runMenuCommand(ctx, repoRoot, run.Command, detectedOS, &run.Meta, w)
+			w.Close()
+			// A background process the script left behind may still hold
+			// the pipe open; don't wait on it.
+			select {
+			case <-copied:
+			case <-time.After(time.Second):
+			}
+			r.Close()
+			<-copied
+			done <- result{i, err}
+		}()
+	}
+
+	ticker := time.NewTicker(menuRedraw)
+	defer ticker.Stop()
+	dirty := false
+	draw()
+	for {
+		select {
+		case ks, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, k := range ks {
+				switch m.key(k) {
+				case actionStart:
+					start(0)
+				case actionCancel:
+					cancel(&interruptError{sig: os.Interrupt})
+				case actionQuit:
+					return nil
+				}
+			}
+			draw()
+		case c := <-output:
+			m.runs[c.run].write(c.data)
+			dirty = true
+		case r := <-done:
+			run := m.runs[r.run]
+			run.Done, run.Err, run.Elapsed = true, r.err, time.Since(m.started)
+			if run.partial != "" {
+				run.write([]byte("\n"))
+			}
+			cancel(nil)
+			stopSignals()
+			if r.run+1 < len(m.runs) && !m.stopping && !errors.Is(r.err, exitcode.ErrInterrupted) {
+				start(r.run + 1)
+			} else {
+				m.screen, m.current = screenSummary, 0
+			}
+			draw()
+		case <-ticker.C:
+			if dirty || m.screen == screenRun {
+				draw()
+				dirty = false
+			}
+		}
+	}
+}
+
+// runMenuCommand runs command for the menu with the script's output, and the
+// full audit's progress lines, written to w.
+func runMenuCommand(ctx context.Context, repoRoot string, command auditCommand, detectedOS string, meta *latest.RunMeta, w io.Writer) error {
+	disabled, err := disabledCollectors(collector.Selection{})
+	if err != nil {
+		fmt.Fprintf(w, "Error: %v\n", err)
+		return err
+	}
+	saved := scriptIO
+	scriptIO.Stdin, scriptIO.Stdout, scriptIO.Stderr = nil, w, w
+	defer func() { scriptIO = saved }()
+	stop := startProgress(bus, w, enabledCollectors(disabled), progressEvery)
+	defer stop()
+	err = runAuditCommand(ctx, repoRoot, command, detectedOS, []string{"--no-color"}, false, meta, disabled)
+	if err != nil {
+		fmt.Fprintf(w, "\n%v\n", err)
+	}
+	return err
+}
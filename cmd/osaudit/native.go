@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+	"github.com/kareemsasa/operating-system-audit/internal/settings"
+	"github.com/kareemsasa/operating-system-audit/internal/wincollect"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// builtinPrefix marks a manifest exec target implemented inside osaudit rather
+// than by a script, e.g. "builtin:persistence" for the native Windows
+// collectors.
+const builtinPrefix = "builtin:"
+
+// runNativeCollector runs a built-in collector with the scripts' arguments
+// (--report-dir, --output, --ndjson, redaction flags) and writes the same
+// report, NDJSON, and run meta they do. When ctx is interrupted the collectors
+// that finished are kept as a partial snapshot and the *interruptError returned.
+// When collectors crashed the snapshot is kept and the error wraps
+// exitcode.ErrPartial; see finishedPartial.
+func runNativeCollector(ctx context.Context, repoRoot, id string, passthrough []string, printRunMeta bool, captureMeta *latest.RunMeta, disabled []string) error {
+	fs := flag.NewFlagSet(id, flag.ContinueOnError)
+	reportDir := fs.String("report-dir", filepath.Join(outputDir(repoRoot), id+"-audit"), "Directory for timestamped run directories")
+	output := fs.String("output", "", "Write the report to this file")
+	ndjson := fs.Bool("ndjson", false, "Also write NDJSON next to the report")
+	// config.yaml's redact sets the defaults, as it does for the scripts.
+	redactOn := fs.Bool("redact-paths", userSettings.Redact == settings.RedactPaths, "Replace the home directory with ~")
+	redactOff := fs.Bool("no-redact-paths", userSettings.Redact == settings.RedactNone, "Keep paths as-is in NDJSON")
+	redactAll := fs.Bool("redact-all", userSettings.Redact == settings.RedactAll, "Redact everything that can be redacted")
+	noRedact := fs.Bool("no-redact", false, "Redact nothing, overriding redact in config.yaml")
+	fs.Bool("no-color", false, "Accepted for compatibility; output is not colored")
+	if err := fs.Parse(passthrough); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return exitcode.Usagef("%s: %v", id, err)
+	}
+	if fs.NArg() > 0 {
+		return exitcode.Usagef("%s: unknown argument %q", id, fs.Arg(0))
+	}
+
+	now := time.Now()
+	stamp := now.Format("20060102-150405")
+	component := id + "-audit"
+	reportFile := *output
+	dir := filepath.Dir(reportFile)
+	if reportFile == "" {
+		dir = filepath.Join(*reportDir, stamp)
+		reportFile = filepath.Join(dir, component+"-"+stamp+".md")
+	}
+	var ndjsonFile string
+	if *ndjson {
+		ndjsonFile = strings.TrimSuffix(reportFile, filepath.Ext(reportFile)) + ".ndjson"
+	}
+
+	runID := newRunID()
+	res, err := wincollect.Run(ctx, wincollect.System(ctx), id, wincollect.Options{
+		RunID:          runID,
+		Disabled:       disabled,
+		RedactPaths:    !*noRedact && (*redactAll || *redactOn || (*ndjson && !*redactOff)),
+		CertExpiryDays: certExpiryDays(),
+		Now:            func() time.Time { return now },
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeguard.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeguard.WriteFile(reportFile, []byte(res.Report), 0o644); err != nil {
+		return err
+	}
+	if ndjsonFile != "" {
+		if err := writeNDJSONFile(ndjsonFile, res.Rows); err != nil {
+			return err
+		}
+	}
+	// A run with crashed collectors finished; its snapshot is kept like any
+	// other, and the exit code says it is partial.
+	var outcome error
+	if res.Crashes > 0 {
+		outcome = fmt.Errorf("%d native collector(s) crashed (see collector_crash rows): %w", res.Crashes, exitcode.ErrPartial)
+	}
+	if ie := interruptCause(ctx); ie != nil && res.Interrupted {
+		outcome = ie
+		if ndjsonFile != "" {
+			if err := finalizeInterrupted(ndjsonFile, runID, ie); err != nil {
+				return err
+			}
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if printRunMeta {
+		out = os.Stderr // stdout stays clean for JSON, as with the scripts
+	}
+	fmt.Fprintf(out, "Report saved to:\n  %s\n", reportFile)
+	if ndjsonFile != "" {
+		fmt.Fprintf(out, "NDJSON summary saved to:\n  %s\n", ndjsonFile)
+	}
+	if res.ProbeFailures > 0 {
+		fmt.Fprintf(out, "Soft probe warnings: %d (see probe_failed rows)\n", res.ProbeFailures)
+	}
+
+	if !printRunMeta && captureMeta == nil {
+		return outcome
+	}
+	meta := latest.RunMeta{
+		RunID: runID, CreatedAt: now.UTC().Format(time.RFC3339), Platform: "windows", AuditID: id,
+		Dir: repoRelative(repoRoot, dir), NDJSON: repoRelative(repoRoot, ndjsonFile), Report: repoRelative(repoRoot, reportFile),
+	}
+	if captureMeta != nil {
+		*captureMeta = meta
+		return outcome
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return outcome
+}
+
+func writeNDJSONFile(path string, rows []map[string]any) error {
+	f, err := writeguard.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := rawjson.NewEncoder(w)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// repoRelative makes path relative to repoRoot when it is inside it, like the
+// scripts' run meta.
+func repoRelative(repoRoot, path string) string {
+	if path == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(repoRoot, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// certExpiryDays reads CERT_EXPIRY_DAYS, the expiry window the config
+// scripts take from the environment; 0 (unset or invalid) means the default.
+func certExpiryDays() int {
+	n, _ := strconv.Atoi(os.Getenv("CERT_EXPIRY_DAYS"))
+	return n
+}
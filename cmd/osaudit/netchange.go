@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// networkCollectors are the collectors the network-change run enables by
+// default: listeners, resolvers, proxies, gateways, and the firewall.
+const networkCollectors = "network"
+
+// networkUnit and networkLabel name the systemd user units and the
+// LaunchAgent that run auditID when the network changes.
+func networkUnit(auditID string) string  { return "osaudit-" + auditID + "-network" }
+func networkLabel(auditID string) string { return "com.osaudit." + auditID + ".network" }
+
+// networkWatchPaths are the files the network stack rewrites when the
+// default route, Wi-Fi network, or VPN changes: the resolver configuration
+// of systemd-resolved, NetworkManager, and plain resolvconf setups, and
+// systemd-networkd's DHCP leases on Linux; the resolver configuration and
+// Wi-Fi preferences configd maintains on macOS.
+var networkWatchPaths = map[string][]string{
+	"linux": {"/etc/resolv.conf", "/run/systemd/resolve/resolv.conf", "/run/NetworkManager/resolv.conf", "/run/NetworkManager/no-stub-resolv.conf", "/run/systemd/netif/leases"},
+	"mac":   {"/var/run/resolv.conf", "/Library/Preferences/SystemConfiguration/com.apple.airport.preferences.plist"},
+}
+
+// networkSettle is how long a network-change run waits before it starts, so
+// DHCP, the gateway's ARP entry, and the VPN have settled.
+const networkSettle = "5"
+
+// networkInstall installs a run of auditID with only the enabled collectors
+// whenever the network changes: a systemd path unit on Linux and a
+// LaunchAgent with WatchPaths on macOS, both watching networkWatchPaths. The
+// run is diffed against the last run on the same network (--per-network).
+func networkInstall(repoRoot, auditID, detectedOS, enable string) int {
+	enabled := collector.ParseList(enable)
+	if _, err := disabledCollectors(collector.Selection{Enable: enabled}); err != nil {
+		fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+		return exitcode.Usage
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+		return exitcode.Error
+	}
+	exe, _ = filepath.EvalSymlinks(exe)
+	exe, _ = filepath.Abs(exe)
+	args := []string{"run-scheduled", auditID, "--enable", strings.Join(enabled, ","), "--per-network", "--", "--redact-all"}
+
+	switch detectedOS {
+	case "linux":
+		configDir := filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user")
+		if err := writeguard.MkdirAll(configDir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		unitName := networkUnit(auditID)
+		servicePath := filepath.Join(configDir, unitName+".service")
+		pathPath := filepath.Join(configDir, unitName+".path")
+		serviceContent := fmt.Sprintf(`[Unit]
+Description=OS Audit network-change run (%s: %s)
+
+[Service]
+Type=oneshot
+SuccessExitStatus=%d %d
+KillMode=process
+WorkingDirectory=%s
+Environment=OSAUDIT_ROOT=%s
+ExecStartPre=/bin/sleep %s
+ExecStart=%s %s
+`, auditID, strings.Join(enabled, ", "), exitcode.Drift, exitcode.Partial, repoRoot, repoRoot, networkSettle, exe, strings.Join(args, " "))
+		var watch strings.Builder
+		for _, p := range networkWatchPaths["linux"] {
+			fmt.Fprintf(&watch, "PathChanged=%s\n", p)
+		}
+		pathContent := fmt.Sprintf(`[Unit]
+Description=OS Audit network watch (%s)
+
+[Path]
+%sUnit=%s.service
+
+[Install]
+WantedBy=default.target
+`, auditID, watch.String(), unitName)
+		if err := writeguard.WriteFile(servicePath, []byte(serviceContent), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		if err := writeguard.WriteFile(pathPath, []byte(pathContent), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Printf("Installed. Reload and enable with:\n  systemctl --user daemon-reload\n  systemctl --user enable --now %s.path\n", unitName)
+		return exitcode.OK
+
+	case "mac":
+		agentsDir := filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents")
+		if err := writeguard.MkdirAll(agentsDir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		label := networkLabel(auditID)
+		plistPath := filepath.Join(agentsDir, label+".plist")
+		// launchd has no delay before a WatchPaths run; sh sleeps instead.
+		var argXML strings.Builder
+		for _, a := range append([]string{"/bin/sh", "-c", `sleep ` + networkSettle + `; exec "$0" "$@"`, exe}, args...) {
+			fmt.Fprintf(&argXML, "\t\t<string>%s</string>\n", a)
+		}
+		var watchXML strings.Builder
+		for _, p := range networkWatchPaths["mac"] {
+			fmt.Fprintf(&watchXML, "\t\t<string>%s</string>\n", p)
+		}
+		plistContent := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>OSAUDIT_ROOT</key>
+		<string>%s</string>
+	</dict>
+	<key>WatchPaths</key>
+	<array>
+%s	</array>
+	<key>ThrottleInterval</key>
+	<integer>30</integer>
+	<key>LowPriorityIO</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>/dev/null</string>
+	<key>StandardErrorPath</key>
+	<string>/dev/null</string>
+</dict>
+</plist>
+`, label, argXML.String(), repoRoot, repoRoot, watchXML.String())
+		if err := writeguard.WriteFile(plistPath, []byte(plistContent), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "schedule install: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Printf("Installed. It runs whenever the network changes; load it now with: launchctl load %s\n", plistPath)
+		return exitcode.OK
+	}
+
+	fmt.Fprintln(os.Stderr, "schedule install: unsupported OS")
+	return exitcode.Error
+}
+
+func networkUninstall(auditID, detectedOS string) int {
+	switch detectedOS {
+	case "linux":
+		unitName := networkUnit(auditID)
+		exec.Command("systemctl", "--user", "disable", "--now", unitName+".path").Run()
+		configDir := filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user")
+		writeguard.Remove(filepath.Join(configDir, unitName+".path"))
+		writeguard.Remove(filepath.Join(configDir, unitName+".service"))
+		fmt.Printf("Uninstalled %s\n", unitName)
+		return exitcode.OK
+	case "mac":
+		label := networkLabel(auditID)
+		plistPath := filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents", label+".plist")
+		exec.Command("launchctl", "unload", plistPath).Run()
+		writeguard.Remove(plistPath)
+		fmt.Printf("Uninstalled %s\n", label)
+		return exitcode.OK
+	}
+	fmt.Fprintln(os.Stderr, "schedule uninstall: unsupported OS")
+	return exitcode.Error
+}
+
+func networkStatus(auditID, detectedOS string) int {
+	var path string
+	switch detectedOS {
+	case "linux":
+		path = filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user", networkUnit(auditID)+".path")
+	case "mac":
+		path = filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents", networkLabel(auditID)+".plist")
+	default:
+		fmt.Fprintln(os.Stderr, "schedule status: unsupported OS")
+		return exitcode.Error
+	}
+	if _, err := os.Stat(path); err != nil {
+		fmt.Printf("%s on network change: not installed\n", auditID)
+		return exitcode.OK
+	}
+	fmt.Printf("%s on network change: installed (%s)\n", auditID, path)
+	return exitcode.OK
+}
+
+// networkContext is the network a run was on, from its network_context row.
+type networkContext struct {
+	ID    string
+	Label string
+}
+
+// readNetworkContext returns the network context of a run's rows, labeled
+// by SSID when the run recorded one, else by the default gateway. ok is
+// false when the rows hold no network_context row.
+func readNetworkContext(rows []diff.Row) (ctx networkContext, ok bool) {
+	r := networkRow(rows)
+	if ctx.ID, _ = r["id"].(string); ctx.ID == "" {
+		return ctx, false
+	}
+	ssid, _ := r["ssid"].(string)
+	iface, _ := r["interface"].(string)
+	gateway, _ := r["gateway"].(string)
+	switch {
+	case ssid != "" && ssid != "<ssid>":
+		ctx.Label = fmt.Sprintf("Wi-Fi %q", ssid)
+	case gateway != "":
+		ctx.Label = gateway + " on " + iface
+	default:
+		ctx.Label = "no default route"
+	}
+	if vpn, _ := r["vpn"].(bool); vpn {
+		ctx.Label += " over VPN"
+	}
+	ctx.Label += " [" + ctx.ID + "]"
+	return ctx, true
+}
+
+// networkRow returns rows' network_context row, or nil.
+func networkRow(rows []diff.Row) diff.Row {
+	for _, r := range rows {
+		if r["type"] == "network_context" {
+			return r
+		}
+	}
+	return nil
+}
+
+// networkBaselinePath is where the last run on the network is recorded, beside
+// the audit's .latest.json.
+func networkBaselinePath(repoRoot, auditRoot string, ctx networkContext) string {
+	return filepath.Join(repoPath(repoRoot, auditRoot), ".network", ctx.ID+".json")
+}
+
+// notifyNetworkChanges raises one notification for changes found on ctx.
+func notifyNetworkChanges(repoRoot, auditRoot, auditID string, ctx networkContext) {
+	notify(repoRoot, auditRoot, i18n.T("notify.network.title"), i18n.T("notify.network.body", auditID, ctx.Label))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// snapshotOutput is where `osaudit run --output` or `--output-dir` puts the
+// run's NDJSON snapshot, in addition to the run directory the script writes.
+// Stdout, set by --format ndjson, also streams it to stdout, with the audit's
+// own output moved to stderr.
+type snapshotOutput struct {
+	File   string
+	Dir    string
+	Stdout bool
+}
+
+func (o snapshotOutput) set() bool {
+	return o.File != "" || o.Dir != "" || o.Stdout
+}
+
+// check refuses a destination outside the output directory in forensic mode
+// before the audit runs, rather than after.
+func (o snapshotOutput) check() error {
+	for _, p := range []string{o.File, o.Dir} {
+		if p == "" {
+			continue
+		}
+		if err := writeguard.Check(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns File, or a hostname-YYYYMMDD-HHMMSS.ndjson file in Dir named
+// for host and the run's start.
+func (o snapshotOutput) path(host string, start time.Time) string {
+	if o.File != "" {
+		return o.File
+	}
+	host = strings.NewReplacer("/", "_", `\`, "_", " ", "_").Replace(host)
+	if host == "" {
+		host = "unknown"
+	}
+	return filepath.Join(o.Dir, host+"-"+start.Format("20060102-150405")+".ndjson")
+}
+
+// write copies the snapshot of meta to the destination, naming it on stderr,
+// and to stdout for --format ndjson. It does nothing when neither was asked
+// for; an error has already been printed.
+func (o snapshotOutput) write(repoRoot string, meta latest.RunMeta, start time.Time) error {
+	if !o.set() {
+		return nil
+	}
+	if meta.NDJSON == "" {
+		err := fmt.Errorf("audit did not produce NDJSON output")
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return err
+	}
+	src := repoPath(repoRoot, meta.NDJSON)
+	if o.File != "" || o.Dir != "" {
+		host, _ := os.Hostname()
+		dst := o.path(host, start)
+		if err := copyFile(src, dst); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: write snapshot: %v\n", err)
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Snapshot written to %s\n", dst)
+	}
+	if o.Stdout {
+		f, err := os.Open(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// withNDJSON adds --ndjson to the pass-through arguments unless they have it.
+func withNDJSON(passthrough []string) []string {
+	for _, a := range passthrough {
+		if a == "--ndjson" {
+			return passthrough
+		}
+	}
+	return append([]string{"--ndjson"}, passthrough...)
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/pkghook"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// pkghookCollectors are the collectors a hook run enables by default: where
+// a package leaves its records, services, and listeners.
+const pkghookCollectors = "config,persistence,network"
+
+// runPkghook installs and runs the package manager hooks that capture a
+// snapshot after each install, upgrade, or removal, tagged with the
+// transaction, so diff can say which package a change came from.
+func runPkghook(commands []auditCommand, repoRoot, detectedOS string, args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "pkghook requires a subcommand (install, uninstall, status, run) and a package manager (%s)\n", strings.Join(pkghook.Managers, ", "))
+		printUsage()
+		return exitcode.Usage
+	}
+	sub, manager := args[0], args[1]
+	if !slices.Contains(pkghook.Managers, manager) {
+		fmt.Fprintf(os.Stderr, "pkghook: unknown package manager %q (want %s)\n", manager, strings.Join(pkghook.Managers, ", "))
+		return exitcode.Usage
+	}
+	switch sub {
+	case "install":
+		return pkghookInstall(repoRoot, manager)
+	case "uninstall":
+		return pkghookUninstall(repoRoot, manager)
+	case "status":
+		return pkghookStatus(manager)
+	case "run":
+		return pkghookRun(commands, repoRoot, detectedOS, manager, args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "pkghook: unknown subcommand %q\n", sub)
+		printUsage()
+		return exitcode.Usage
+	}
+}
+
+// pkghookFiles returns the hook files for manager, pointing at this binary.
+func pkghookFiles(repoRoot, manager string) ([]pkghook.Hook, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	exe, _ = filepath.EvalSymlinks(exe)
+	exe, _ = filepath.Abs(exe)
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	binDir := filepath.Join(dir, "bin")
+	return pkghook.Hooks(manager, exe, repoRoot, binDir, lookBrew(binDir))
+}
+
+// lookBrew finds Homebrew's brew on PATH, skipping the wrapper in binDir.
+func lookBrew(binDir string) string {
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" || filepath.Clean(dir) == binDir {
+			continue
+		}
+		p := filepath.Join(dir, "brew")
+		if info, err := os.Stat(p); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return p
+		}
+	}
+	return ""
+}
+
+// pkghookStatePath is where a hook run records how far it read the package
+// manager's log.
+func pkghookStatePath(repoRoot, manager string) string {
+	return filepath.Join(outputDir(repoRoot), ".pkghook", manager+".offset")
+}
+
+func readPkghookOffset(repoRoot, manager string) int64 {
+	data, err := os.ReadFile(pkghookStatePath(repoRoot, manager))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return n
+}
+
+func writePkghookOffset(repoRoot, manager string, offset int64) error {
+	path := pkghookStatePath(repoRoot, manager)
+	if err := writeguard.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeguard.WriteFile(path, []byte(strconv.FormatInt(offset, 10)+"\n"), 0o644)
+}
+
+func pkghookInstall(repoRoot, manager string) int {
+	hooks, err := pkghookFiles(repoRoot, manager)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook install: %v\n", err)
+		return exitcode.Error
+	}
+	var installed []string
+	for _, h := range hooks {
+		dir := filepath.Dir(h.Path)
+		if manager == "brew" {
+			if err := writeguard.MkdirAll(dir, 0o755); err != nil {
+				fmt.Fprintf(os.Stderr, "pkghook install: %v\n", err)
+				return exitcode.Error
+			}
+		} else if _, err := os.Stat(dir); err != nil {
+			// dnf 4 and dnf 5 each read one of the two directories, and only
+			// with their actions plugin installed.
+			continue
+		}
+		if err := writeguard.WriteFile(h.Path, []byte(h.Content), h.Mode); err != nil {
+			fmt.Fprintf(os.Stderr, "pkghook install: %v\n", err)
+			return exitcode.Error
+		}
+		installed = append(installed, h.Path)
+	}
+	if len(installed) == 0 {
+		switch manager {
+		case "dnf":
+			fmt.Fprintln(os.Stderr, "pkghook install: no dnf actions plugin; install python3-dnf-plugin-post-transaction-actions (dnf 4) or libdnf5-plugin-actions (dnf 5)")
+		default:
+			fmt.Fprintf(os.Stderr, "pkghook install: %s is not installed (%s does not exist)\n", manager, filepath.Dir(hooks[0].Path))
+		}
+		return exitcode.Error
+	}
+	// Start from the end of the log, so the first hook run reports the
+	// transaction that triggered it rather than the log's history.
+	if log := pkghook.Logs[manager]; log != "" {
+		_, offset, err := pkghook.ReadLogSince(log, 0)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "pkghook install: %v\n", err)
+			return exitcode.Error
+		}
+		if err := writePkghookOffset(repoRoot, manager, offset); err != nil {
+			fmt.Fprintf(os.Stderr, "pkghook install: %v\n", err)
+			return exitcode.Error
+		}
+	}
+	for _, p := range installed {
+		fmt.Printf("Installed %s\n", p)
+	}
+	if manager == "brew" {
+		fmt.Printf("Put the wrapper ahead of Homebrew on PATH, e.g. in ~/.zprofile:\n  export PATH=\"%s:$PATH\"\n", filepath.Dir(installed[0]))
+	}
+	return exitcode.OK
+}
+
+// hookPaths returns the paths of the files pkghookInstall writes for manager.
+func hookPaths(manager string) ([]string, error) {
+	if manager == "brew" {
+		dir, err := config.Dir()
+		if err != nil {
+			return nil, err
+		}
+		return []string{filepath.Join(dir, "bin", "brew")}, nil
+	}
+	hooks, err := pkghook.Hooks(manager, "", "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(hooks))
+	for i, h := range hooks {
+		paths[i] = h.Path
+	}
+	return paths, nil
+}
+
+func pkghookUninstall(repoRoot, manager string) int {
+	paths, err := hookPaths(manager)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook uninstall: %v\n", err)
+		return exitcode.Error
+	}
+	for _, p := range paths {
+		if err := writeguard.Remove(p); err == nil {
+			fmt.Printf("Removed %s\n", p)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "pkghook uninstall: %v\n", err)
+			return exitcode.Error
+		}
+	}
+	writeguard.Remove(pkghookStatePath(repoRoot, manager))
+	return exitcode.OK
+}
+
+func pkghookStatus(manager string) int {
+	paths, err := hookPaths(manager)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook status: %v\n", err)
+		return exitcode.Error
+	}
+	found := false
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			fmt.Printf("%s: installed (%s)\n", manager, p)
+			found = true
+		}
+	}
+	if !found {
+		fmt.Printf("%s: not installed\n", manager)
+	}
+	return exitcode.OK
+}
+
+// pkghookRun is what a hook runs after a transaction: it reads what the
+// transaction changed, runs the full audit with the pkghook collectors,
+// appends the transaction to the snapshot as a package_transaction row, and
+// diffs the snapshot against the full audit's latest one. The latest
+// manifest is left alone; a partial snapshot is not a baseline.
+func pkghookRun(commands []auditCommand, repoRoot, detectedOS, manager string, args []string) int {
+	fs := flag.NewFlagSet("pkghook run", flag.ContinueOnError)
+	detach := fs.Bool("detach", false, "Return at once and run in the background, so the package manager is not held up")
+	enable := fs.String("enable", pkghookCollectors, "Comma-separated collector IDs to run")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.Usage
+	}
+	if *detach {
+		exe, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pkghook run: %v\n", err)
+			return exitcode.Error
+		}
+		cmd := exec.Command(exe, append([]string{"pkghook", "run", manager, "--enable", *enable, "--"}, fs.Args()...)...)
+		setProcessGroup(cmd)
+		// The package manager has returned by the time the run prints; its
+		// output goes to a log beside the offset instead.
+		logPath := filepath.Join(filepath.Dir(pkghookStatePath(repoRoot, manager)), manager+".log")
+		if err := writeguard.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "pkghook run: %v\n", err)
+			return exitcode.Error
+		}
+		logFile, err := writeguard.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pkghook run: %v\n", err)
+			return exitcode.Error
+		}
+		defer logFile.Close()
+		cmd.Stdout, cmd.Stderr = logFile, logFile
+		if err := cmd.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "pkghook run: %v\n", err)
+			return exitcode.Error
+		}
+		return exitcode.OK
+	}
+
+	var tx pkghook.Transaction
+	if log := pkghook.Logs[manager]; log != "" {
+		data, offset, err := pkghook.ReadLogSince(log, readPkghookOffset(repoRoot, manager))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pkghook run: %v\n", err)
+			return exitcode.Error
+		}
+		// Record the offset first: dnf 4 starts a run per package, and the
+		// later ones must find nothing left to report.
+		if err := writePkghookOffset(repoRoot, manager, offset); err != nil {
+			fmt.Fprintf(os.Stderr, "pkghook run: %v\n", err)
+			return exitcode.Error
+		}
+		if manager == "apt" {
+			tx = pkghook.ParseDpkgLog(bytes.NewReader(data))
+		} else {
+			tx = pkghook.ParseDNFLog(bytes.NewReader(data))
+		}
+		if len(tx.Packages) == 0 {
+			return exitcode.OK
+		}
+	} else {
+		tx = pkghook.ParseBrew(fs.Args(), brewVersions, time.Now())
+	}
+
+	command, err := findCommandByID(commands, fullAuditID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.Usage
+	}
+	disabled, err := disabledCollectors(collector.Selection{Enable: collector.ParseList(*enable)})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook run: %v\n", err)
+		return exitcode.Usage
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	var meta latest.RunMeta
+	partial := runAuditCommand(ctx, repoRoot, command, detectedOS, []string{"--ndjson", "--redact-all"}, true, &meta, disabled)
+	if partial != nil {
+		fmt.Fprintln(os.Stderr, partial)
+	}
+	if meta.NDJSON == "" {
+		if partial == nil {
+			fmt.Fprintln(os.Stderr, "pkghook run: audit did not produce NDJSON output")
+			return exitcode.Error
+		}
+		return exitcode.Of(partial)
+	}
+	if err := appendTransaction(repoRoot, meta, tx); err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook run: %v\n", err)
+	}
+	indexSnapshot(repoRoot, meta)
+	if partial != nil && !finishedPartial(partial) {
+		return exitcode.Of(partial)
+	}
+	if err := loadClassification(); err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook run: %v\n", err)
+		return exitcode.Error
+	}
+
+	baselineData, err := os.ReadFile(filepath.Join(repoPath(repoRoot, filepath.Dir(meta.Dir)), ".latest.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook run: no baseline; wrote %s\n", meta.NDJSON)
+		return exitcode.Of(partial)
+	}
+	var baseline latest.RunMeta
+	if err := json.Unmarshal(baselineData, &baseline); err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook run: invalid baseline: %v\n", err)
+		return exitcode.Error
+	}
+	baselineRows, err := diff.ReadNDJSON(repoPath(repoRoot, baseline.NDJSON))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook run: read baseline NDJSON: %v\n", err)
+		return exitcode.Error
+	}
+	currentRows, err := diff.ReadNDJSON(repoPath(repoRoot, meta.NDJSON))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook run: read current NDJSON: %v\n", err)
+		return exitcode.Error
+	}
+	// The hook ran only some collectors; what the others record is not gone.
+	baselineRows = collectedRows(baselineRows, currentRows, collector.ParseList(*enable))
+	if rules, err := loadIgnoreRules(); err != nil {
+		fmt.Fprintf(os.Stderr, "pkghook run: ignore rules not applied: %v\n", err)
+	} else if len(rules) > 0 {
+		baselineRows = diff.ApplyIgnore(baselineRows, rules.Match)
+		currentRows = diff.ApplyIgnore(currentRows, rules.Match)
+	}
+	if hasDeltas, _ := diff.Run(baselineRows, currentRows, false, false); hasDeltas {
+		return exitcode.Drift
+	}
+	return exitcode.Of(partial)
+}
+
+// brewVersions returns the installed version of each formula or cask in
+// names, from `brew list --versions`; with several versions kept, the last
+// one listed.
+func brewVersions(names []string) map[string]string {
+	out, _ := exec.Command("brew", append([]string{"list", "--versions"}, names...)...).Output()
+	versions := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if f := strings.Fields(sc.Text()); len(f) >= 2 {
+			versions[f[0]] = f[len(f)-1]
+		}
+	}
+	return versions
+}
+
+// appendTransaction tags the snapshot with tx: a package_transaction row in
+// its NDJSON and a section in its report.
+func appendTransaction(repoRoot string, meta latest.RunMeta, tx pkghook.Transaction) error {
+	row, err := json.Marshal(tx.Row(meta.RunID))
+	if err != nil {
+		return err
+	}
+	if err := appendFile(repoPath(repoRoot, meta.NDJSON), append(row, '\n')); err != nil {
+		return err
+	}
+	if meta.Report == "" {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n## Package Transaction\n\nCaptured after the %s transaction", tx.Manager)
+	if !tx.Started.IsZero() {
+		fmt.Fprintf(&b, " at %s", tx.Started.Local().Format("2006-01-02 15:04:05"))
+	}
+	if tx.Command != "" {
+		fmt.Fprintf(&b, ": `%s`", tx.Command)
+	}
+	b.WriteString(".\n\n")
+	if len(tx.Packages) > 0 {
+		b.WriteString("| Action | Package | Version |\n|--------|---------|---------|\n")
+		for _, p := range tx.Packages {
+			v := p.Version
+			if p.Previous != "" {
+				v = p.Previous + " → " + p.Version
+			}
+			fmt.Fprintf(&b, "| %s | `%s` | %s |\n", p.Action, p.Name, v)
+		}
+	}
+	return appendFile(repoPath(repoRoot, meta.Report), []byte(b.String()))
+}
+
+func appendFile(path string, data []byte) error {
+	f, err := writeguard.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/plugin"
+	"github.com/kareemsasa/operating-system-audit/internal/rowbuf"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+	"github.com/kareemsasa/operating-system-audit/pluginsdk"
+)
+
+// fullAuditID is the manifest command that runs every built-in collector.
+// Collector plugins run alongside it.
+const fullAuditID = "full"
+
+func pluginDir() (string, error) {
+	return config.SubDir(config.PluginsDirEnv, "plugins")
+}
+
+// runAll runs the full audit with NDJSON output, then every collector plugin,
+// and merges the plugins' rows into the audit's NDJSON and report.
+func runAll(ctx context.Context, commands []auditCommand, repoRoot, detectedOS string, passthrough []string, printRunMeta bool, disabled []string, out snapshotOutput) int {
+	command, err := findCommandByID(commands, fullAuditID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.Usage
+	}
+	passthrough = withNDJSON(passthrough)
+
+	start := time.Now()
+	var meta latest.RunMeta
+	partial := runAuditCommand(ctx, repoRoot, command, detectedOS, passthrough, printRunMeta || out.Stdout, &meta, disabled)
+	if partial != nil {
+		fmt.Fprintln(os.Stderr, partial)
+	}
+	if partial != nil && !finishedPartial(partial) {
+		keepInterruptedSnapshot(repoRoot, meta, out, start, partial)
+		return exitcode.Of(partial)
+	}
+	// Plugins cannot be scoped, so a scoped run leaves them out. Forensic
+	// mode does too: the guard cannot reach a plugin's own writes.
+	if os.Getenv(collector.ScopeEnv) == "" && !forensicSkipped("running collector plugins") {
+		if err := mergePlugins(ctx, repoRoot, detectedOS, meta); err != nil {
+			partial = err
+		}
+	}
+	if ie := interruptCause(ctx); ie != nil {
+		partial = ie
+	}
+	indexSnapshot(repoRoot, meta)
+	if err := out.write(repoRoot, meta, start); err != nil {
+		return exitcode.Error
+	}
+	if printRunMeta {
+		data, err := json.Marshal(meta)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Println(string(data))
+	}
+	return exitcode.Of(partial)
+}
+
+// mergePlugins runs the collector plugins for detectedOS and appends their rows
+// to the run's NDJSON and a "Plugins" section to its report. Plugin problems
+// are warnings recorded in the plugin's summary row; when any plugin failed the
+// result is exitcode.ErrPartial. Canceling ctx stops the running plugin.
+func mergePlugins(ctx context.Context, repoRoot, detectedOS string, meta latest.RunMeta) error {
+	dir, err := pluginDir()
+	if err != nil {
+		return nil
+	}
+	plugins, errs := plugin.Discover(dir)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if len(plugins) == 0 || meta.NDJSON == "" {
+		return nil
+	}
+
+	env := plugin.Env{
+		RunID:      meta.RunID,
+		Platform:   detectedOS,
+		Root:       repoRoot,
+		Privileged: os.Geteuid() == 0,
+		StateDir:   filepath.Join(dir, ".run"),
+	}
+	limit, err := rowbuf.EnvLimit()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		limit = rowbuf.DefaultLimit
+	}
+	// Plugin output is not bounded; keep it within the memory ceiling
+	// until it is appended.
+	rows := rowbuf.New(limit)
+	defer rows.Close()
+	var failed []string
+	var report strings.Builder
+	report.WriteString("\n## Plugins\n\n| Plugin | Version | Status | Rows |\n|--------|---------|--------|------|\n")
+	for _, p := range plugins {
+		if !p.SupportsPlatform(detectedOS) {
+			continue
+		}
+		pr, err := plugin.Run(ctx, p, env)
+		status := "ok"
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			status = "failed"
+			failed = append(failed, p.Name)
+		} else if pr[0]["status"] == "skipped" {
+			status = fmt.Sprintf("skipped (%v)", pr[0]["reason"])
+		}
+		for _, r := range pr {
+			if err := rows.Add(r); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: buffer plugin rows: %v\n", err)
+				break
+			}
+		}
+		fmt.Fprintf(&report, "| %s | %s | %s | %d |\n", p.Name, p.Version, status, len(pr)-1)
+	}
+	if rows.Len() == 0 {
+		return nil
+	}
+	if err := plugin.AppendNDJSON(repoPath(repoRoot, meta.NDJSON), rows); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: write plugin rows: %v\n", err)
+	}
+	if meta.Report != "" {
+		f, err := writeguard.OpenFile(repoPath(repoRoot, meta.Report), os.O_WRONLY|os.O_APPEND, 0)
+		if err == nil {
+			f.WriteString(report.String())
+			f.Close()
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: plugins failed: %s", exitcode.ErrPartial, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// repoPath resolves a run meta path, which scripts write repo-relative when
+// they can.
+func repoPath(repoRoot, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(repoRoot, p)
+}
+
+// runPlugins lists the collector plugins in ~/.osaudit/plugins, or stops the
+// resident ones.
+func runPlugins(detectedOS string, args []string) int {
+	if len(args) > 1 || len(args) == 1 && args[0] != "stop" {
+		fmt.Fprintln(os.Stderr, "plugins takes no arguments except stop")
+		printUsage()
+		return exitcode.Usage
+	}
+	dir, err := pluginDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if len(args) == 1 {
+		stopped, err := plugin.StopResident(filepath.Join(dir, ".run"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		for _, name := range stopped {
+			fmt.Printf("Stopped %s\n", name)
+		}
+		return exitcode.OK
+	}
+	plugins, errs := plugin.Discover(dir)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if len(plugins) == 0 {
+		fmt.Printf("No plugins in %s\n", dir)
+		return exitcode.OK
+	}
+	for _, p := range plugins {
+		types := make([]string, 0, len(p.RowTypes))
+		for _, rt := range p.RowTypes {
+			types = append(types, rt.Type)
+		}
+		line := fmt.Sprintf("%s %s (%s)", p.Name, p.Version, strings.Join(types, ", "))
+		if p.Protocol == pluginsdk.ProtocolRPC {
+			line += " [resident]"
+		}
+		if !p.SupportsPlatform(detectedOS) {
+			line += " [not for " + detectedOS + "]"
+		}
+		fmt.Println(strings.Join(strings.Fields(line), " "))
+		if p.Description.Description != "" {
+			fmt.Printf("  %s\n", p.Description.Description)
+		}
+	}
+	return exitcode.OK
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/policy"
+)
+
+func runPolicy(args []string) int {
+	if len(args) > 0 && args[0] == "test" {
+		return runPolicyTest(args[1:])
+	}
+	fmt.Fprintln(os.Stderr, "policy requires a subcommand: test")
+	printUsage()
+	return exitcode.Usage
+}
+
+// runPolicyTest runs policy test files (see policy.Suite) found under the
+// given files and directories, so a policy pack can be checked in CI against
+// example snapshots. It exits PolicyFailure when any case gets a verdict it
+// did not expect, and Usage when a test file or its policy is invalid.
+func runPolicyTest(args []string) int {
+	fs := flag.NewFlagSet("policy test", flag.ContinueOnError)
+	ndjson := fs.Bool("ndjson", false, "Emit per-case results as NDJSON instead of human-readable summary")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	paths, err := policy.FindSuites(roots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "policy test: no test files (*_test.yaml, *_test.yml, *_test.json) found")
+		return exitcode.Usage
+	}
+
+	suites := make([]policy.Suite, 0, len(paths))
+	for _, p := range paths {
+		s, err := policy.LoadSuite(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Usage
+		}
+		suites = append(suites, s)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	cases, failed := 0, 0
+	for _, s := range suites {
+		results, err := s.Run()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		for _, cr := range results {
+			cases++
+			if len(cr.Mismatches) > 0 {
+				failed++
+			}
+			if *ndjson {
+				printPolicyTestCaseNDJSON(enc, s, cr)
+			} else {
+				printPolicyTestCase(s, cr)
+			}
+		}
+	}
+	if *ndjson {
+		enc.Encode(map[string]any{
+			"type":   "check",
+			"check":  "policy_test_summary",
+			"suites": len(suites),
+			"cases":  cases,
+			"failed": failed,
+		})
+	} else {
+		fmt.Println()
+		fmt.Println(i18n.T("policy.test.summary", cases, len(suites), failed))
+	}
+	if failed > 0 {
+		return exitcode.PolicyFailure
+	}
+	return exitcode.OK
+}
+
+func printPolicyTestCase(s policy.Suite, cr policy.CaseResult) {
+	status := "ok"
+	if len(cr.Mismatches) > 0 {
+		status = "FAIL"
+	}
+	fmt.Printf("  [%-4s] %s: %s\n", status, s.Path, cr.Case.Name)
+	for _, m := range cr.Mismatches {
+		line := "      " + i18n.T("policy.test.mismatch", m.Rule, m.Want, m.Got)
+		if m.Reason != "" {
+			line += fmt.Sprintf(" (%s)", m.Reason)
+		}
+		fmt.Println(line)
+	}
+}
+
+func printPolicyTestCaseNDJSON(enc *json.Encoder, s policy.Suite, cr policy.CaseResult) {
+	status := "pass"
+	mismatches := make([]map[string]any, 0, len(cr.Mismatches))
+	for _, m := range cr.Mismatches {
+		status = "fail"
+		mm := map[string]any{"rule": m.Rule, "want": m.Want, "got": m.Got}
+		if m.Reason != "" {
+			mm["reason"] = m.Reason
+		}
+		mismatches = append(mismatches, mm)
+	}
+	enc.Encode(map[string]any{
+		"type":       "check",
+		"check":      "policy_test_case",
+		"suite":      s.Path,
+		"policy":     s.Policy.Name,
+		"case":       cr.Case.Name,
+		"status":     status,
+		"mismatches": mismatches,
+	})
+}
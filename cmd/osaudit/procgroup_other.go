@@ -0,0 +1,16 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing where there are no process groups to signal;
+// a canceled run kills the process itself.
+func setProcessGroup(*exec.Cmd) {}
+
+func signalProcessGroup(p *os.Process, _ os.Signal) error { return p.Kill() }
+
+func killProcessGroup(p *os.Process) error { return p.Kill() }
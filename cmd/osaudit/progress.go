@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/events"
+)
+
+// progressEvery is how often the progress display repeats the collector that
+// is still running, so a storage scan that takes minutes does not look hung.
+const progressEvery = 30 * time.Second
+
+// progress is the full audit's progress display: one line when a collector
+// starts, one when it finishes, and one every progressEvery once it has run
+// that long.
+// It writes whole lines, since the audit script's own output shares the
+// terminal.
+type progress struct {
+	w     io.Writer
+	total int
+	every time.Duration
+
+	mu      sync.Mutex
+	start   time.Time // when the first collector started
+	done    int
+	current string // the running collector, "" between collectors
+	since   time.Time
+}
+
+// enabledCollectors returns how many collectors a full audit runs with the
+// disabled ones skipped.
+func enabledCollectors(disabled []string) int {
+	n := 0
+	for _, c := range collector.All() {
+		if !slices.Contains(disabled, c.ID) {
+			n++
+		}
+	}
+	return n
+}
+
+// startProgress subscribes a progress display to b for a full audit that runs
+// total collectors, writing to w. The returned function stops it. Audits that
+// publish no collector events (single collectors, Windows) print nothing.
+func startProgress(b *events.Bus, w io.Writer, total int, every time.Duration) (stop func()) {
+	p := &progress{w: w, total: total, every: every}
+	unsubscribe := b.Subscribe(p.handle, events.CollectorStarted, events.CollectorFinished)
+	ticker := time.NewTicker(every)
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-quit:
+				return
+			case now := <-ticker.C:
+				p.tick(now)
+			}
+		}
+	}()
+	return func() {
+		unsubscribe()
+		ticker.Stop()
+		close(quit)
+	}
+}
+
+func (p *progress) handle(e events.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.start.IsZero() {
+		p.start = e.Time
+	}
+	// A script may run a collector the registry does not count.
+	total := max(p.total, p.done+1)
+	switch e.Kind {
+	case events.CollectorStarted:
+		p.current, p.since = e.Collector, e.Time
+		fmt.Fprintf(p.w, "[%d/%d] %s started, %s elapsed\n", p.done+1, total, e.Collector, roundDuration(e.Time.Sub(p.start)))
+	case events.CollectorFinished:
+		p.done++
+		p.current = ""
+		fmt.Fprintf(p.w, "[%d/%d] %s done in %s, %d remaining\n", p.done, total, e.Collector, roundDuration(time.Duration(e.ElapsedMS)*time.Millisecond), total-p.done)
+	}
+}
+
+func (p *progress) tick(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == "" || now.Sub(p.since) < p.every {
+		return
+	}
+	fmt.Fprintf(p.w, "[%d/%d] %s still running (%s), %s elapsed\n", p.done+1, max(p.total, p.done+1), p.current, roundDuration(now.Sub(p.since)), roundDuration(now.Sub(p.start)))
+}
+
+// roundDuration rounds d for display: to the second, or to the tenth of a
+// second under ten seconds.
+func roundDuration(d time.Duration) time.Duration {
+	if d < 10*time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
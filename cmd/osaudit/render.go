@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/kb"
+	"github.com/kareemsasa/operating-system-audit/internal/render"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+var themeUsage = "Output theme: " + strings.Join(render.Themes, ", ")
+
+// Output formats for --format. list, run, and diff each take the ones that
+// fit what they print; json and ndjson are one JSON object per line.
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatPretty   = "pretty"
+	formatNDJSON   = "ndjson"
+	formatMarkdown = "markdown"
+	formatTable    = "table"
+)
+
+// validateFormat reports a usage error unless format is one of allowed.
+func validateFormat(cmd, format string, allowed ...string) bool {
+	if slices.Contains(allowed, format) {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "%s: unknown --format %q (want %s)\n", cmd, format, strings.Join(allowed, ", "))
+	printUsage()
+	return false
+}
+
+// runRender converts an existing Markdown report (e.g. one written by `osaudit
+// run`) to another theme.
+//
+//	osaudit render --theme <plain|high-contrast> [--out <path>] <report.md>
+func runRender(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	theme := fs.String("theme", render.ThemePlain, themeUsage)
+	out := fs.String("out", "", "Write to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if fs.NArg() != 1 || !render.ValidTheme(*theme) {
+		fmt.Fprintf(os.Stderr, "render requires --theme <%s> and one report path\n", strings.Join(render.Themes, "|"))
+		printUsage()
+		return exitcode.Usage
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := writeguard.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := render.Render(w, *theme, string(data), render.Options{Lang: i18n.Locale()}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	return exitcode.OK
+}
+
+// withTheme runs fn and re-renders what it prints to stdout in theme. The
+// markdown theme runs fn directly.
+func withTheme(theme string, fn func() int) int {
+	return renderThemed(theme, render.Options{}, fn)
+}
+
+// withDiffTheme is withTheme for diff output, whose "-" items are removals.
+// The high-contrast theme links each item to its finding's article.
+func withDiffTheme(theme string, fn func() int) int {
+	return renderThemed(theme, render.Options{Diff: true, DocURL: diffItemDocURL}, fn)
+}
+
+// diffItemDocURL returns the doc_url of the finding a diff item describes. An
+// item starts with what changed: the row type of an inventory change
+// ("sensitive_dirs: ~/.ssh ..."), the probe of a probe failure, or the code
+// of a new warning.
+func diffItemDocURL(item string) string {
+	fields := strings.Fields(item)
+	if len(fields) == 0 {
+		return ""
+	}
+	return kb.DocURL(strings.Trim(fields[0], "`:"))
+}
+
+func renderThemed(theme string, opts render.Options, fn func() int) int {
+	if theme == "" || theme == render.ThemeMarkdown {
+		return fn()
+	}
+	var buf bytes.Buffer
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	old := os.Stdout
+	os.Stdout = w
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&buf, r)
+		r.Close()
+		close(done)
+	}()
+	code := fn()
+	w.Close()
+	os.Stdout = old
+	<-done
+
+	opts.Lang = i18n.Locale()
+	if err := render.Render(os.Stdout, theme, buf.String(), opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	return code
+}
+
+// validateTheme reports a usage error for an unknown theme, or a non-default
+// theme combined with --ndjson or another format that is not Markdown.
+func validateTheme(cmd, theme string, ndjson bool) bool {
+	if !render.ValidTheme(theme) {
+		fmt.Fprintf(os.Stderr, "%s: unknown --theme %q (want %s)\n", cmd, theme, strings.Join(render.Themes, ", "))
+		printUsage()
+		return false
+	}
+	if ndjson && theme != render.ThemeMarkdown {
+		fmt.Fprintf(os.Stderr, "%s: --theme applies to Markdown output only\n", cmd)
+		printUsage()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// selftestCollectors are the collectors whose findings `osaudit selftest`
+// provokes.
+var selftestCollectors = []string{"network", "persistence"}
+
+// selftestLabel names everything the self-test creates, so a leftover is
+// easy to attribute.
+const selftestLabel = "com.osaudit.selftest"
+
+// injection is one benign, reversible change the self-test makes between
+// two audits, and the inventory change a working pipeline reports for it.
+type injection struct {
+	Change    string `json:"change"`
+	Collector string `json:"collector"`
+	RowType   string `json:"row_type"`
+	// Key is the end of the reported change's key; the start depends on
+	// path redaction and the process name.
+	Key      string `json:"key"`
+	Detected bool   `json:"detected"`
+	// Reason says why an undetected change was missed, when osaudit can tell.
+	Reason string `json:"reason,omitempty"`
+
+	inject func() (undo func(), err error)
+}
+
+// selftestInjections returns the changes the self-test makes for detectedOS,
+// all inside home, the sandbox the audits read as the user's home directory,
+// except the listening socket, which is osaudit's own and closed on undo.
+func selftestInjections(home, detectedOS string) []*injection {
+	file := func(path, content string) func() (func(), error) {
+		return func() (func(), error) {
+			if err := writeguard.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return nil, err
+			}
+			if err := writeguard.WriteFile(path, []byte(content), 0o644); err != nil {
+				return nil, err
+			}
+			return func() { writeguard.Remove(path) }, nil
+		}
+	}
+	var agent *injection
+	switch detectedOS {
+	case "mac":
+		agent = &injection{Change: "launch agent", Collector: "persistence", RowType: "launch_agents", Key: selftestLabel,
+			inject: file(filepath.Join(home, "Library", "LaunchAgents", selftestLabel+".plist"), `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>`+selftestLabel+`</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>/usr/bin/true</string>
+	</array>
+</dict>
+</plist>
+`)}
+	default:
+		agent = &injection{Change: "autostart entry", Collector: "persistence", RowType: "xdg_autostart", Key: "/" + selftestLabel + ".desktop",
+			inject: file(filepath.Join(home, ".config", "autostart", selftestLabel+".desktop"), "[Desktop Entry]\nType=Application\nName=osaudit selftest\nExec=/bin/true\n")}
+	}
+	rc := &injection{Change: "shell startup file", Collector: "persistence", RowType: "shell_startup_files", Key: "/.profile",
+		inject: file(filepath.Join(home, ".profile"), "# written by osaudit selftest\n")}
+	port := &injection{Change: "listening socket", Collector: "network", RowType: "listening_ports"}
+	port.inject = func() (func(), error) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		port.Key = ":" + strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+		return func() { ln.Close() }, nil
+	}
+	return []*injection{agent, rc, port}
+}
+
+// runSelftest checks the scheduled pipeline end to end: it audits a sandbox,
+// injects known changes, audits again, diffs the two snapshots with the
+// ignore rules run-scheduled applies, and reports whether each change was
+// found. Everything it injects is undone before it returns.
+func runSelftest(commands []auditCommand, repoRoot, detectedOS string, args []string) int {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	keep := fs.Bool("keep", false, "Keep the sandbox and both audits' output for inspection")
+	ndjson := fs.Bool("ndjson", false, "Emit one JSON object per injected change")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "selftest takes no arguments")
+		printUsage()
+		return exitcode.Usage
+	}
+	if detectedOS == "windows" {
+		fmt.Fprintln(os.Stderr, "selftest: not supported on Windows")
+		return exitcode.Usage
+	}
+	// Even benign changes are not made on a machine under investigation.
+	if err := writeguard.Refuse("osaudit selftest"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+
+	command, err := findCommandByID(commands, fullAuditID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.Error
+	}
+	disabled, err := disabledCollectors(collector.Selection{Enable: selftestCollectors})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+		return exitcode.Usage
+	}
+	rules, err := loadIgnoreRules()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+		return exitcode.Usage
+	}
+
+	sandbox, err := writeguard.MkdirTemp("", "osaudit-selftest-*")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if *keep {
+		fmt.Fprintf(os.Stderr, "selftest: keeping %s\n", sandbox)
+	} else {
+		defer writeguard.RemoveAll(sandbox)
+	}
+	home := filepath.Join(sandbox, "home")
+	if err := writeguard.MkdirAll(home, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	// The audit scripts read HOME_DIR as the audited user's home.
+	if err := os.Setenv("HOME_DIR", home); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	defer os.Unsetenv("HOME_DIR")
+
+	ctx, stop := signalContext()
+	defer stop()
+	audit := func(name string) ([]diff.Row, error) {
+		var meta latest.RunMeta
+		passthrough := []string{"--ndjson", "--report-dir", filepath.Join(sandbox, "output", name)}
+		if err := runAuditCommand(ctx, repoRoot, command, detectedOS, passthrough, true, &meta, disabled); err != nil && !finishedPartial(err) {
+			return nil, fmt.Errorf("%s audit: %w", name, err)
+		}
+		if meta.NDJSON == "" {
+			return nil, fmt.Errorf("%s audit did not produce NDJSON output", name)
+		}
+		return diff.ReadNDJSON(repoPath(repoRoot, meta.NDJSON))
+	}
+
+	fmt.Fprintln(os.Stderr, "selftest: auditing the sandbox before any change")
+	baselineRows, err := audit("baseline")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+		return exitcode.Of(err)
+	}
+	injections := selftestInjections(home, detectedOS)
+	currentRows, err := injectAndAudit(ctx, injections, func() ([]diff.Row, error) { return audit("current") })
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "selftest: %v\n", err)
+		return exitcode.Of(err)
+	}
+
+	var ignored func(string) bool
+	if len(rules) > 0 {
+		ignored = rules.Match
+	}
+	missed := checkInjections(injections, baselineRows, currentRows, disabled, ignored)
+	enc := json.NewEncoder(os.Stdout)
+	for _, inj := range injections {
+		if *ndjson {
+			if err := enc.Encode(inj); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitcode.Error
+			}
+			continue
+		}
+		status := "detected"
+		if !inj.Detected {
+			status = "MISSED  "
+		}
+		fmt.Printf("%s  %-20s %-20s %s", status, inj.Change, inj.RowType, strings.TrimPrefix(inj.Key, "/"))
+		if inj.Reason != "" {
+			fmt.Printf(" (%s)", inj.Reason)
+		}
+		fmt.Println()
+	}
+	if missed > 0 {
+		fmt.Fprintf(os.Stderr, "selftest: %d of %d injected changes were not detected; scheduled runs would miss them too\n", missed, len(injections))
+		return exitcode.PolicyFailure
+	}
+	fmt.Fprintf(os.Stderr, "selftest: all %d injected changes were detected\n", len(injections))
+	return exitcode.OK
+}
+
+// injectAndAudit makes each injection, runs audit, and undoes them all
+// whatever happens.
+func injectAndAudit(ctx context.Context, injections []*injection, audit func() ([]diff.Row, error)) ([]diff.Row, error) {
+	var undos []func()
+	defer func() {
+		for i := len(undos) - 1; i >= 0; i-- {
+			undos[i]()
+		}
+	}()
+	for _, inj := range injections {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		undo, err := inj.inject()
+		if err != nil {
+			return nil, fmt.Errorf("inject %s: %w", inj.Change, err)
+		}
+		undos = append(undos, undo)
+		fmt.Fprintf(os.Stderr, "selftest: injected %s\n", inj.Change)
+	}
+	return audit()
+}
+
+// checkInjections sets Detected on each injection that the diff of baseline
+// and current, with ignored findings suppressed, reports as added, and a
+// Reason on those it does not. It returns how many were missed.
+func checkInjections(injections []*injection, baseline, current []diff.Row, disabled []string, ignored func(key string) bool) (missed int) {
+	added := func(changes []diff.InventoryChange, inj *injection) bool {
+		for _, c := range changes {
+			if c.RowType == inj.RowType && c.Status == "added" && strings.HasSuffix(c.Key, inj.Key) {
+				return true
+			}
+		}
+		return false
+	}
+	all := diff.BuildInventoryChanges(baseline, current)
+	kept := all
+	if ignored != nil {
+		kept = diff.BuildInventoryChanges(diff.ApplyIgnore(baseline, ignored), diff.ApplyIgnore(current, ignored))
+	}
+	for _, inj := range injections {
+		inj.Detected = added(kept, inj)
+		switch {
+		case inj.Detected:
+			continue
+		case slices.Contains(disabled, inj.Collector):
+			inj.Reason = "collector " + inj.Collector + " is disabled"
+		case added(all, inj):
+			inj.Reason = "suppressed by ignore rules"
+		}
+		missed++
+	}
+	return missed
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/events"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+)
+
+// defaultServeAddr is where serve listens unless --listen says otherwise.
+const defaultServeAddr = "127.0.0.1:9464"
+
+// runServe runs `osaudit serve`: the agent mode. It runs the audit like
+// run-scheduled, once at start and then every --every, and serves its own
+// health on localhost while it waits: /healthz, and /metrics in the Prometheus
+// text format, built from the events the runs publish on the bus.
+func runServe(commands []auditCommand, repoRoot, detectedOS string, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", defaultServeAddr, "Loopback address to serve /metrics and /healthz on")
+	every := fs.Duration("every", time.Hour, "Time between the starts of two runs")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	runArgs := fs.Args()
+	if len(runArgs) < 1 {
+		fmt.Fprintln(os.Stderr, "serve requires audit id")
+		printUsage()
+		return exitcode.Usage
+	}
+	if *every <= 0 {
+		fmt.Fprintln(os.Stderr, "serve --every must be positive")
+		return exitcode.Usage
+	}
+	if !loopbackAddr(*listen) {
+		fmt.Fprintf(os.Stderr, "serve --listen: %s is not a loopback address; serve only listens on localhost\n", *listen)
+		return exitcode.Usage
+	}
+	if _, err := findCommandByID(commands, runArgs[0]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.Usage
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "serve: %v\n", err)
+		return exitcode.Error
+	}
+	m := newAgentMetrics(*every, time.Now())
+	unsubscribe := bus.Subscribe(m.observe)
+	defer unsubscribe()
+	srv := &http.Server{Handler: m.handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "serve: %v\n", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "serve: /metrics and /healthz on http://%s\n", ln.Addr())
+
+	// The run in progress handles a signal itself, finishing its partial
+	// snapshot; serve then stops instead of waiting for the next run.
+	// Releasing the signals once one arrived lets a second one quit at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	code := exitcode.OK
+	for ctx.Err() == nil {
+		start := time.Now()
+		code = runRunScheduled(commands, repoRoot, detectedOS, runArgs)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Until(start.Add(*every))):
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(shutdownCtx)
+	if code == exitcode.Interrupted {
+		return code
+	}
+	return exitcode.OK
+}
+
+// loopbackAddr reports whether addr, a host:port, names localhost or a
+// loopback IP. The endpoints have no authentication, so they stay on the
+// machine they describe.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// agentMetrics accumulates what serve reports, from the events on the bus.
+type agentMetrics struct {
+	mu      sync.Mutex
+	every   time.Duration
+	started time.Time
+
+	runs      map[string]*auditRuns // by audit ID
+	probeFail map[[2]string]int     // by audit ID and probe
+	findings  map[string]int        // by audit ID
+	policy    int
+
+	notifyPosts, notifyFailures int
+	lastNotifyOK                bool
+	lastNotify                  time.Time
+}
+
+// auditRuns is the run history of one audit.
+type auditRuns struct {
+	total, errors int
+	last          time.Time
+	lastExit      int
+	lastElapsed   time.Duration
+}
+
+func newAgentMetrics(every time.Duration, started time.Time) *agentMetrics {
+	return &agentMetrics{
+		every: every, started: started,
+		runs: map[string]*auditRuns{}, probeFail: map[[2]string]int{}, findings: map[string]int{},
+	}
+}
+
+// runFailed reports whether a run's exit code says the run itself failed, as
+// opposed to finding drift, running partially, or being interrupted.
+func runFailed(code int) bool {
+	return code == exitcode.Error || code == exitcode.Usage || exitcode.Name(code) == "unknown"
+}
+
+// observe records e. It is the bus subscriber.
+func (m *agentMetrics) observe(e events.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch e.Kind {
+	case events.RunFinished:
+		r := m.runs[e.AuditID]
+		if r == nil {
+			r = &auditRuns{}
+			m.runs[e.AuditID] = r
+		}
+		r.total++
+		if runFailed(e.ExitCode) {
+			r.errors++
+		}
+		r.last, r.lastExit, r.lastElapsed = e.Time, e.ExitCode, time.Duration(e.ElapsedMS)*time.Millisecond
+	case events.ProbeFinished:
+		if e.ExitCode != 0 {
+			m.probeFail[[2]string{e.AuditID, e.Probe}]++
+		}
+	case events.FindingEmitted:
+		m.findings[e.AuditID]++
+	case events.PolicyFailed:
+		m.policy++
+	case events.NotifyPosted:
+		m.notifyPosts++
+		m.lastNotifyOK = e.Message == ""
+		if !m.lastNotifyOK {
+			m.notifyFailures++
+		}
+		m.lastNotify = e.Time
+	}
+}
+
+// health reports whether the agent is healthy at now, and why not. It is
+// unhealthy when the latest run failed, or when no run has finished within
+// two intervals.
+func (m *agentMetrics) health(now time.Time) (bool, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var last time.Time
+	var lastID string
+	for id, r := range m.runs {
+		if r.last.After(last) {
+			last, lastID = r.last, id
+		}
+	}
+	if last.IsZero() {
+		if now.Sub(m.started) > 2*m.every {
+			return false, fmt.Sprintf("no run has finished since %s", m.started.UTC().Format(time.RFC3339))
+		}
+		return true, "ok"
+	}
+	if r := m.runs[lastID]; runFailed(r.lastExit) {
+		return false, fmt.Sprintf("the last %s run exited %d", lastID, r.lastExit)
+	}
+	if now.Sub(last) > 2*m.every {
+		return false, fmt.Sprintf("the last run finished at %s", last.UTC().Format(time.RFC3339))
+	}
+	return true, "ok"
+}
+
+func (m *agentMetrics) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		ok, reason := m.health(time.Now())
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprintln(w, reason)
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		fmt.Fprint(w, m.exposition())
+	})
+	return mux
+}
+
+// exposition renders the metrics in the Prometheus text format, series in a
+// stable order.
+func (m *agentMetrics) exposition() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b strings.Builder
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	ids := make([]string, 0, len(m.runs))
+	for id := range m.runs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	perAudit := func(name, typ, help string, value func(*auditRuns) float64) {
+		metric(name, typ, help)
+		for _, id := range ids {
+			fmt.Fprintf(&b, "%s{audit_id=%s} %s\n", name, promLabel(id), strconv.FormatFloat(value(m.runs[id]), 'f', -1, 64))
+		}
+	}
+
+	metric("osaudit_agent_start_time_seconds", "gauge", "When serve started, as a Unix time.")
+	fmt.Fprintf(&b, "osaudit_agent_start_time_seconds %d\n", m.started.Unix())
+	perAudit("osaudit_runs_total", "counter", "Audit runs finished.", func(r *auditRuns) float64 { return float64(r.total) })
+	perAudit("osaudit_run_errors_total", "counter", "Audit runs that failed with an error.", func(r *auditRuns) float64 { return float64(r.errors) })
+	perAudit("osaudit_last_run_timestamp_seconds", "gauge", "When the last run finished, as a Unix time.", func(r *auditRuns) float64 { return float64(r.last.Unix()) })
+	perAudit("osaudit_last_run_duration_seconds", "gauge", "How long the last run took.", func(r *auditRuns) float64 { return r.lastElapsed.Seconds() })
+	perAudit("osaudit_last_run_exit_code", "gauge", "The exit code of the last run's audit script.", func(r *auditRuns) float64 { return float64(r.lastExit) })
+
+	metric("osaudit_probe_failures_total", "counter", "Probes that exited non-zero.")
+	probes := make([][2]string, 0, len(m.probeFail))
+	for k := range m.probeFail {
+		probes = append(probes, k)
+	}
+	sort.Slice(probes, func(i, j int) bool {
+		if probes[i][0] != probes[j][0] {
+			return probes[i][0] < probes[j][0]
+		}
+		return probes[i][1] < probes[j][1]
+	})
+	for _, k := range probes {
+		fmt.Fprintf(&b, "osaudit_probe_failures_total{audit_id=%s,probe=%s} %d\n", promLabel(k[0]), promLabel(k[1]), m.probeFail[k])
+	}
+
+	metric("osaudit_findings_total", "counter", "Diff findings against the baseline.")
+	findingIDs := make([]string, 0, len(m.findings))
+	for id := range m.findings {
+		findingIDs = append(findingIDs, id)
+	}
+	sort.Strings(findingIDs)
+	for _, id := range findingIDs {
+		fmt.Fprintf(&b, "osaudit_findings_total{audit_id=%s} %d\n", promLabel(id), m.findings[id])
+	}
+
+	metric("osaudit_policy_failures_total", "counter", "Policy rules violated.")
+	fmt.Fprintf(&b, "osaudit_policy_failures_total %d\n", m.policy)
+	metric("osaudit_notify_posts_total", "counter", "Notifications POSTed to notify.urls endpoints.")
+	fmt.Fprintf(&b, "osaudit_notify_posts_total %d\n", m.notifyPosts)
+	metric("osaudit_notify_failures_total", "counter", "Notification POSTs that failed.")
+	fmt.Fprintf(&b, "osaudit_notify_failures_total %d\n", m.notifyFailures)
+	if !m.lastNotify.IsZero() {
+		ok := 0
+		if m.lastNotifyOK {
+			ok = 1
+		}
+		metric("osaudit_last_notify_success", "gauge", "Whether the last notification POST succeeded.")
+		fmt.Fprintf(&b, "osaudit_last_notify_success %d\n", ok)
+	}
+	return b.String()
+}
+
+// promLabel quotes v as a Prometheus label value.
+func promLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
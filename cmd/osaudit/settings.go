@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/events"
+	"github.com/kareemsasa/operating-system-audit/internal/settings"
+)
+
+// userSettings are the defaults from config.yaml and its environment
+// variables, loaded by run before any subcommand.
+var userSettings settings.Settings
+
+// outputDir returns the directory audits write under: output_dir from
+// config.yaml, else the tree's output/.
+func outputDir(repoRoot string) string {
+	return userSettings.Output(repoRoot)
+}
+
+// settingsEnv passes config.yaml's output directory and redaction to the
+// audit scripts as the defaults their flags override.
+func settingsEnv(repoRoot string) []string {
+	env := []string{"AUDIT_OUTPUT_ROOT=" + outputDir(repoRoot)}
+	switch userSettings.Redact {
+	case settings.RedactAll:
+		env = append(env, "REDACT_ALL=true")
+	case settings.RedactPaths:
+		env = append(env, "REDACT_PATHS_MODE=on")
+	case settings.RedactNone:
+		env = append(env, "REDACT_ALL=false", "REDACT_PATHS_MODE=off")
+	}
+	return env
+}
+
+// webhookTimeout bounds each notification POST, so an unreachable endpoint
+// does not hold up a scheduled run.
+const webhookTimeout = 10 * time.Second
+
+// postNotifications POSTs a notification to every notify.urls endpoint as a
+// JSON object with title and body, and text joining the two for chat
+// webhooks that only read text. Failures are warnings. Each POST publishes a
+// notify_posted event.
+func postNotifications(title, body string) {
+	if len(userSettings.Notify.URLs) == 0 {
+		return
+	}
+	data, _ := json.Marshal(map[string]string{"title": title, "body": body, "text": title + "\n" + body})
+	client := &http.Client{Timeout: webhookTimeout}
+	for _, u := range userSettings.Notify.URLs {
+		resp, err := client.Post(u, "application/json", bytes.NewReader(data))
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err // without the URL
+		}
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("HTTP %s", resp.Status)
+			}
+		}
+		posted := events.Event{Kind: events.NotifyPosted}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "run-scheduled: notify %s: %v\n", redactURL(u), err)
+			posted.Message = fmt.Sprintf("%s: %v", redactURL(u), err)
+		}
+		bus.Publish(posted)
+	}
+}
+
+// redactURL drops everything after a webhook URL's host; the path usually
+// holds its secret.
+func redactURL(u string) string {
+	if i := strings.Index(u, "://"); i >= 0 {
+		if j := strings.IndexByte(u[i+3:], '/'); j >= 0 {
+			return u[:i+3+j] + "/…"
+		}
+	}
+	return u
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/digest"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+	"github.com/kareemsasa/operating-system-audit/internal/redact"
+	"github.com/kareemsasa/operating-system-audit/internal/snapindex"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+func runSnapshot(repoRoot string, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "compact":
+			return runSnapshotCompact(repoRoot, args[1:])
+		case "reclassify":
+			return runSnapshotReclassify(repoRoot, args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "snapshot requires a subcommand: compact or reclassify")
+	printUsage()
+	return exitcode.Usage
+}
+
+// compactStats describes what compacting one snapshot changed.
+type compactStats struct {
+	Before, After int64
+	Untyped       int // rows without a type, which no reader uses
+	Duplicates    int // exact repeats of a later row
+	Redacted      int // values rewritten by the current redaction rules
+}
+
+// runSnapshotCompact rewrites stored snapshots in the current encoding:
+// upgraded to the current schema version, untyped and duplicate rows dropped,
+// values re-redacted, compact JSON,
+// optionally gzip-compressed. Snapshots keep their paths, so .latest.json and
+// delta bases still point at them.
+func runSnapshotCompact(repoRoot string, args []string) int {
+	fs := flag.NewFlagSet("snapshot compact", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Report what would change without rewriting anything")
+	compress := fs.Bool("gzip", false, "Gzip-compress the rewritten snapshots (they are no longer indexed)")
+	redactAll := fs.Bool("redact-all", false, "Also redact hostnames, SSH fingerprints, and process arguments")
+	noRedact := fs.Bool("no-redact", false, "Do not re-apply redaction rules")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if *noRedact && *redactAll {
+		fmt.Fprintln(os.Stderr, "snapshot compact: --no-redact and --redact-all are mutually exclusive")
+		printUsage()
+		return exitcode.Usage
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{outputDir(repoRoot)}
+	}
+	paths, err := snapshotPaths(roots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+
+	var total compactStats
+	for _, path := range paths {
+		st, err := compactSnapshot(path, compactOptions{DryRun: *dryRun, Gzip: *compress, Redact: !*noRedact, RedactAll: *redactAll})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		fmt.Printf("%s: %s -> %s, %d duplicate and %d untyped rows dropped, %d values redacted\n",
+			path, humanBytes(st.Before), humanBytes(st.After), st.Duplicates, st.Untyped, st.Redacted)
+		total.Before += st.Before
+		total.After += st.After
+	}
+	verb := "Reclaimed"
+	if *dryRun {
+		verb = "Would reclaim"
+	}
+	fmt.Printf("%s %s across %d snapshots\n", verb, humanBytes(total.Before-total.After), len(paths))
+	return exitcode.OK
+}
+
+// snapshotPaths expands directories among roots to the .ndjson files under
+// them, in a stable order.
+func snapshotPaths(roots []string) ([]string, error) {
+	var paths []string
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, ".ndjson") {
+				paths = append(paths, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+type compactOptions struct {
+	DryRun, Gzip, Redact, RedactAll bool
+}
+
+// compactSnapshot rewrites one snapshot. Rows are read as stored, so
+// delta-encoded hash snapshots stay deltas; compacting a whole directory
+// redacts a chain and its bases alike. A snapshot that is already compressed
+// stays compressed.
+func compactSnapshot(path string, opts compactOptions) (compactStats, error) {
+	var st compactStats
+	info, err := os.Stat(path)
+	if err != nil {
+		return st, err
+	}
+	st.Before = info.Size()
+	rows, err := diff.ReadNDJSONRaw(path)
+	if err != nil {
+		return st, err
+	}
+	wasGzip, err := isGzipFile(path)
+	if err != nil {
+		return st, err
+	}
+
+	typed := rows[:0]
+	for _, r := range rows {
+		if t, ok := r["type"].(string); ok && t != "" {
+			typed = append(typed, r)
+		} else {
+			st.Untyped++
+		}
+	}
+	rows, st.Duplicates = dedupeRows(diff.UpgradeRows(typed))
+	if opts.Redact {
+		st.Redacted = redact.ForRows(rows, opts.RedactAll).Apply(rows)
+	}
+
+	data, err := encodeSnapshot(rows, opts.Gzip || wasGzip)
+	if err != nil {
+		return st, fmt.Errorf("%s: %w", path, err)
+	}
+	st.After = int64(len(data))
+	if opts.DryRun {
+		return st, nil
+	}
+	return st, replaceSnapshot(path, data, info.Mode().Perm(), opts.Gzip || wasGzip)
+}
+
+// encodeSnapshot encodes rows as compact NDJSON, gzip-compressed if asked.
+func encodeSnapshot(rows []diff.Row, compressed bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := rawjson.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return nil, err
+		}
+	}
+	if !compressed {
+		return buf.Bytes(), nil
+	}
+	var zbuf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&zbuf, gzip.BestCompression)
+	zw.Write(buf.Bytes())
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return zbuf.Bytes(), nil
+}
+
+// replaceSnapshot atomically replaces the snapshot at path with data and
+// rebuilds its index, or removes it for a compressed snapshot.
+func replaceSnapshot(path string, data []byte, perm fs.FileMode, compressed bool) error {
+	tmp := path + ".tmp"
+	if err := writeguard.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := writeguard.Rename(tmp, path); err != nil {
+		writeguard.Remove(tmp)
+		return err
+	}
+	if compressed {
+		if err := writeguard.Remove(snapindex.Path(path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if _, err := snapindex.Write(path); err != nil {
+		return fmt.Errorf("index %s: %w", path, err)
+	}
+	return nil
+}
+
+// dedupeRows drops rows that repeat a later row exactly, keeping the last
+// occurrence in place so later-row-wins readers see the same result.
+func dedupeRows(rows []diff.Row) ([]diff.Row, int) {
+	keys := make([]string, len(rows))
+	last := make(map[string]int, len(rows))
+	for i, r := range rows {
+		data, _ := rawjson.Marshal(r) // map keys are sorted, so equal rows encode alike
+		keys[i], _ = digest.Default.Sum(data)
+		last[keys[i]] = i
+	}
+	out := make([]diff.Row, 0, len(rows))
+	for i, r := range rows {
+		if last[keys[i]] == i {
+			out = append(out, r)
+		}
+	}
+	return out, len(rows) - len(out)
+}
+
+func isGzipFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return diff.IsGzip(bufio.NewReader(f)), nil
+}
+
+// runSnapshotReclassify annotates stored snapshots with the current
+// classification (see diff.Reclassify), so history recorded before a
+// classify.yaml or osaudit change compares with new runs. Snapshots already
+// annotated under the current classification are left alone.
+func runSnapshotReclassify(repoRoot string, args []string) int {
+	fs := flag.NewFlagSet("snapshot reclassify", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Report what would change without rewriting anything")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if err := loadClassification(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{outputDir(repoRoot)}
+	}
+	paths, err := snapshotPaths(roots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+
+	version := diff.ClassificationVersion()
+	now := time.Now()
+	updated := 0
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		rows, err := diff.ReadNDJSONRaw(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		previous := "none"
+		for _, r := range rows {
+			if r["type"] == diff.ClassificationType {
+				previous = fmt.Sprint(r["version"])
+			}
+		}
+		rows, changed := diff.Reclassify(rows, now)
+		if changed == 0 && previous == version {
+			continue
+		}
+		updated++
+		fmt.Printf("%s: %d values reclassified (classification %s -> %s)\n", path, changed, previous, version)
+		if *dryRun {
+			continue
+		}
+		compressed, err := isGzipFile(path)
+		if err == nil {
+			var data []byte
+			if data, err = encodeSnapshot(rows, compressed); err == nil {
+				err = replaceSnapshot(path, data, info.Mode().Perm(), compressed)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+			return exitcode.Error
+		}
+	}
+	verb := "Reclassified"
+	if *dryRun {
+		verb = "Would reclassify"
+	}
+	fmt.Printf("%s %d of %d snapshots under classification %s\n", verb, updated, len(paths), version)
+	return exitcode.OK
+}
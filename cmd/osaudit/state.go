@@ -0,0 +1,397 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// stateFormat is the version of the state archive layout; import refuses
+// archives from a newer osaudit.
+const stateFormat = 1
+
+// stateManifest is state.json at the top of a state archive: where the
+// state came from, so import can move baselines to their new home.
+type stateManifest struct {
+	Format    int    `json:"format"`
+	CreatedAt string `json:"created_at"`
+	Hostname  string `json:"hostname"`
+	// Root and OutputDir are the exporting install's tree and output
+	// directory. Baseline manifests name snapshots by paths under them.
+	Root      string `json:"root"`
+	OutputDir string `json:"output_dir"`
+}
+
+// Archive sections: the configuration directory and the output directory.
+const (
+	stateConfig = "config"
+	stateOutput = "output"
+)
+
+// stateSkips are the paths, relative to their section, that describe this
+// machine rather than the user's history and tuning: the hash cache and
+// resident plugin sockets, the brew wrapper and package log offsets, which
+// point at this install, and triage bundles, which are evidence for this
+// host only.
+var stateSkips = map[string][]string{
+	stateConfig: {"cache", "bin", "plugins/.run"},
+	stateOutput: {".pkghook", triageDir},
+}
+
+func stateSkipped(section, rel string) bool {
+	for _, s := range stateSkips[section] {
+		if rel == s || strings.HasPrefix(rel, s+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// runState moves osaudit's state between machines.
+//
+//	osaudit state export [--out <archive.tar.gz>]
+//	osaudit state import [--force] <archive.tar.gz>
+func runState(repoRoot string, args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "state requires subcommand: export, import")
+		printUsage()
+		return exitcode.Usage
+	}
+	switch args[0] {
+	case "export":
+		return stateExport(repoRoot, args[1:])
+	case "import":
+		return stateImport(repoRoot, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown state subcommand: %s\n", args[0])
+		printUsage()
+		return exitcode.Usage
+	}
+}
+
+func stateExport(repoRoot string, args []string) int {
+	fs := flag.NewFlagSet("state export", flag.ContinueOnError)
+	out := fs.String("out", "", "Write the archive to `path` (default osaudit-state-<host>-<timestamp>.tar.gz)")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "state export takes no arguments")
+		printUsage()
+		return exitcode.Usage
+	}
+	configDir, err := config.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	host, _ := os.Hostname()
+	archive := *out
+	if archive == "" {
+		archive = "osaudit-state-" + host + "-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	}
+	manifest := stateManifest{
+		Format:    stateFormat,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Hostname:  host,
+		Root:      repoRoot,
+		OutputDir: outputDir(repoRoot),
+	}
+	files, err := writeStateArchive(archive, manifest, map[string]string{stateConfig: configDir, stateOutput: manifest.OutputDir})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "state export: %v\n", err)
+		return exitcode.Error
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d file(s) from %s and %s\n", files, configDir, manifest.OutputDir)
+	fmt.Println(archive)
+	return exitcode.OK
+}
+
+// writeStateArchive packs state.json and each section's directory into a
+// gzip-compressed tar at path, readable only by the user: the configuration
+// can hold webhook secrets and the snapshots everything the audits saw. A
+// missing section directory is left out; symlinks are not followed.
+func writeStateArchive(path string, manifest stateManifest, sections map[string]string) (files int, err error) {
+	absOut, _ := filepath.Abs(path)
+	if dir := filepath.Dir(path); dir != "" {
+		if err := writeguard.MkdirAll(dir, 0o755); err != nil {
+			return 0, err
+		}
+	}
+	f, err := writeguard.CreateTemp(filepath.Dir(path), ".state-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			writeguard.Remove(f.Name())
+		}
+	}()
+	absTmp, _ := filepath.Abs(f.Name())
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	data = append(data, '\n')
+	if err := tw.WriteHeader(&tar.Header{Name: "state.json", Mode: 0o644, Size: int64(len(data)), ModTime: time.Now(), Typeflag: tar.TypeReg}); err != nil {
+		return 0, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return 0, err
+	}
+	for _, section := range []string{stateConfig, stateOutput} {
+		root := sections[section]
+		if _, err := os.Stat(root); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil || rel == "." {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if stateSkipped(section, rel) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// The other section may live inside this one, e.g. an output
+			// directory under ~/.osaudit; it is packed under its own name.
+			if d.IsDir() && section == stateConfig && p == filepath.Clean(sections[stateOutput]) {
+				return filepath.SkipDir
+			}
+			if abs, _ := filepath.Abs(p); !d.Type().IsRegular() || abs == absOut || abs == absTmp {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			src, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			// PAX keeps modification times to the nanosecond, which snapshot
+			// indexes compare exactly.
+			hdr := &tar.Header{Name: section + "/" + rel, Mode: int64(info.Mode().Perm()), Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg, Format: tar.FormatPAX}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(tw, src, info.Size()); err != nil {
+				return err
+			}
+			files++
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	return files, writeguard.Rename(f.Name(), path)
+}
+
+func stateImport(repoRoot string, args []string) int {
+	fs := flag.NewFlagSet("state import", flag.ContinueOnError)
+	force := fs.Bool("force", false, "Overwrite files that already exist here instead of keeping them")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "state import requires one archive")
+		printUsage()
+		return exitcode.Usage
+	}
+	if err := writeguard.Refuse("osaudit state import"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Usage
+	}
+	configDir, err := config.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	sections := map[string]string{stateConfig: configDir, stateOutput: outputDir(repoRoot)}
+	res, err := readStateArchive(fs.Arg(0), repoRoot, sections, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "state import: %v\n", err)
+		return exitcode.Error
+	}
+	fmt.Printf("Imported %d file(s) exported from %s on %s", res.Written, res.Manifest.Hostname, res.Manifest.CreatedAt)
+	if res.Rebased > 0 {
+		fmt.Printf(", %d baseline(s) moved to %s", res.Rebased, sections[stateOutput])
+	}
+	fmt.Println()
+	if res.Kept > 0 {
+		fmt.Printf("Kept %d existing file(s); pass --force to replace them\n", res.Kept)
+	}
+	return exitcode.OK
+}
+
+// stateImportResult counts what readStateArchive did.
+type stateImportResult struct {
+	Manifest stateManifest
+	Written  int
+	Kept     int // already present and not replaced
+	Rebased  int // baseline manifests whose snapshot paths were moved
+}
+
+// readStateArchive unpacks an archive written by writeStateArchive into the
+// section directories. Existing files are kept unless force is set. Baseline
+// manifests (.latest.json and the per-network and per-context ones beside
+// it) name snapshots under the exporting install's output directory; they
+// are rewritten to name the same snapshots under this one.
+func readStateArchive(archive, repoRoot string, sections map[string]string, force bool) (res stateImportResult, err error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return res, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != "state.json" {
+		return res, fmt.Errorf("%s is not an osaudit state archive", archive)
+	}
+	if err := json.NewDecoder(tr).Decode(&res.Manifest); err != nil {
+		return res, fmt.Errorf("state.json: %w", err)
+	}
+	if res.Manifest.Format > stateFormat {
+		return res, fmt.Errorf("archive format %d is newer than this osaudit supports (%d)", res.Manifest.Format, stateFormat)
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		section, rel, ok := strings.Cut(hdr.Name, "/")
+		root := sections[section]
+		if !ok || root == "" || hdr.Typeflag != tar.TypeReg || !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return res, fmt.Errorf("unexpected entry %q", hdr.Name)
+		}
+		dst := filepath.Join(root, filepath.FromSlash(rel))
+		if _, err := os.Lstat(dst); err == nil && !force {
+			res.Kept++
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return res, err
+		}
+		if section == stateOutput && isBaselineManifest(rel) {
+			if data, ok = rebaseManifest(data, res.Manifest, repoRoot, root); ok {
+				res.Rebased++
+			}
+		}
+		if err := writeguard.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return res, err
+		}
+		if err := writeguard.WriteFile(dst, data, fs.FileMode(hdr.Mode).Perm()); err != nil {
+			return res, err
+		}
+		// Snapshot indexes and the dating of snapshots outside run
+		// directories go by modification time.
+		if err := writeguard.Chtimes(dst, time.Time{}, hdr.ModTime); err != nil {
+			return res, err
+		}
+		res.Written++
+	}
+	return res, nil
+}
+
+// isBaselineManifest reports whether rel, a slash path in the output
+// directory, is a run manifest that names snapshots by path.
+func isBaselineManifest(rel string) bool {
+	dir, name := path.Split(rel)
+	switch path.Base(dir) {
+	case ".network", ".context":
+		return strings.HasSuffix(name, ".json")
+	}
+	return name == ".latest.json"
+}
+
+// rebaseManifest rewrites the paths of the run manifest data from the
+// exporting install's output directory to outputDir. ok is false when there
+// was nothing to move.
+func rebaseManifest(data []byte, from stateManifest, repoRoot, outputDir string) (_ []byte, ok bool) {
+	var meta latest.RunMeta
+	if json.Unmarshal(data, &meta) != nil {
+		return data, false
+	}
+	rebase := func(p string) string {
+		if p == "" {
+			return p
+		}
+		old := p
+		if !filepath.IsAbs(old) {
+			old = filepath.Join(from.Root, old)
+		}
+		rel, err := filepath.Rel(from.OutputDir, old)
+		if err != nil || !filepath.IsLocal(rel) {
+			return p
+		}
+		moved := repoRelative(repoRoot, filepath.Join(outputDir, rel))
+		ok = ok || moved != p
+		return moved
+	}
+	meta.Dir, meta.NDJSON, meta.Report = rebase(meta.Dir), rebase(meta.NDJSON), rebase(meta.Report)
+	if !ok {
+		return data, false
+	}
+	out, err := json.Marshal(meta)
+	if err != nil {
+		return data, false
+	}
+	return out, true
+}
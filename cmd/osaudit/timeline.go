@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/kb"
+	"github.com/kareemsasa/operating-system-audit/internal/render"
+	"github.com/kareemsasa/operating-system-audit/internal/timeline"
+)
+
+// timelineLayouts are the date forms --from and --to accept, in local time
+// unless they carry a zone.
+var timelineLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+func parseTimelineDate(s string) (time.Time, error) {
+	for _, layout := range timelineLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD, YYYY-MM-DDTHH:MM, or RFC 3339)", s)
+}
+
+// runTimeline reconstructs the changes on one host from its stored snapshots
+// (by default everything under output/), as an ordered record for incident
+// response: when each persistence item, user, package, or port first
+// appeared, changed, or went away, to within the interval between two runs.
+func runTimeline(repoRoot string, args []string) int {
+	fs := flag.NewFlagSet("timeline", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "Report changes after this date (YYYY-MM-DD, YYYY-MM-DDTHH:MM, or RFC 3339)")
+	toFlag := fs.String("to", "", "Report changes up to this date")
+	host := fs.String("host", "", "Host to report when the snapshots come from several")
+	ndjson := fs.Bool("ndjson", false, "Emit events as NDJSON instead of human-readable summary")
+	export := fs.String("export", "", "Write events for forensic timeline tools instead: "+strings.Join(timeline.TimesketchFormats, " or "))
+	theme := fs.String("theme", render.ThemeMarkdown, themeUsage)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if !validateTheme("timeline", *theme, *ndjson) {
+		return exitcode.Usage
+	}
+	if *export != "" {
+		if !slices.Contains(timeline.TimesketchFormats, *export) {
+			fmt.Fprintf(os.Stderr, "timeline: --export must be one of %s\n", strings.Join(timeline.TimesketchFormats, ", "))
+			return exitcode.Usage
+		}
+		if *ndjson {
+			fmt.Fprintln(os.Stderr, "timeline: --export and --ndjson are mutually exclusive")
+			return exitcode.Usage
+		}
+	}
+	var from, to time.Time
+	var err error
+	if *fromFlag != "" {
+		if from, err = parseTimelineDate(*fromFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "timeline: --from: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+	if *toFlag != "" {
+		if to, err = parseTimelineDate(*toFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "timeline: --to: %v\n", err)
+			return exitcode.Usage
+		}
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{outputDir(repoRoot)}
+	}
+	name, snaps, code := hostSnapshots("timeline", roots, *host)
+	if code != exitcode.OK {
+		return code
+	}
+
+	events, err := timeline.Build(snaps, from, to, diff.ReadNDJSON)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+	if *export != "" {
+		if err := timeline.WriteTimesketch(os.Stdout, *export, events, timelineDocURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Error
+		}
+		return exitcode.OK
+	}
+	return withTheme(*theme, func() int {
+		if *ndjson {
+			printTimelineNDJSON(name, len(snaps), events)
+		} else {
+			printTimelineSummary(name, len(snaps), from, to, events)
+		}
+		return exitcode.OK
+	})
+}
+
+// hostSnapshots reads the meta of every snapshot under roots and returns
+// those of host, or of the only host when host is empty. A failure has been
+// reported on behalf of cmd and its exit code is returned.
+func hostSnapshots(cmd string, roots []string, host string) (name string, snaps []timeline.Snapshot, code int) {
+	paths, err := snapshotPaths(roots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return "", nil, exitcode.Error
+	}
+	byHost := make(map[string][]timeline.Snapshot)
+	for _, p := range paths {
+		s, err := timeline.ReadMeta(p, snapshotTime)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %v\n", err)
+			continue
+		}
+		byHost[s.Host] = append(byHost[s.Host], s)
+	}
+	if len(byHost) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: no snapshots found in %s\n", cmd, strings.Join(roots, ", "))
+		return "", nil, exitcode.Error
+	}
+	name = host
+	if name == "" {
+		if len(byHost) > 1 {
+			hosts := make([]string, 0, len(byHost))
+			for h := range byHost {
+				hosts = append(hosts, h)
+			}
+			sort.Strings(hosts)
+			fmt.Fprintf(os.Stderr, "%s: snapshots come from %d hosts (%s); pick one with --host\n", cmd, len(hosts), strings.Join(hosts, ", "))
+			return "", nil, exitcode.Usage
+		}
+		for h := range byHost {
+			name = h
+		}
+	}
+	snaps, ok := byHost[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: no snapshots of host %s\n", cmd, name)
+		return "", nil, exitcode.Error
+	}
+	return name, snaps, exitcode.OK
+}
+
+// timelineDocURL links a finding to the article on its warning code, and an
+// inventory change to the article on its row type.
+func timelineDocURL(e timeline.Event) string {
+	if e.RowType == timeline.FindingRowType {
+		return kb.DocURL(e.Key)
+	}
+	return kb.DocURL(e.RowType)
+}
+
+// timelineStatus words an event for the summary: an added item is first seen
+// or seen again.
+func timelineStatus(e timeline.Event) string {
+	switch {
+	case e.RowType == timeline.FindingRowType && e.Status == "added":
+		return "raised"
+	case e.RowType == timeline.FindingRowType:
+		return "cleared"
+	case e.Status != "added":
+		return e.Status
+	case e.First:
+		return "first seen"
+	default:
+		return "reappeared"
+	}
+}
+
+const timelineTimeFormat = "2006-01-02 15:04"
+
+func printTimelineSummary(host string, snapshots int, from, to time.Time, events []timeline.Event) {
+	span := i18n.T("timeline.span.all")
+	switch {
+	case !from.IsZero() && !to.IsZero():
+		span = i18n.T("timeline.span.between", from.Format(timelineTimeFormat), to.Format(timelineTimeFormat))
+	case !from.IsZero():
+		span = i18n.T("timeline.span.since", from.Format(timelineTimeFormat))
+	case !to.IsZero():
+		span = i18n.T("timeline.span.until", to.Format(timelineTimeFormat))
+	}
+	fmt.Println(i18n.T("timeline.header", host, span, snapshots))
+	var window string
+	for _, e := range events {
+		w := fmt.Sprintf("%s – %s", e.After.Local().Format(timelineTimeFormat), e.Before.Local().Format(timelineTimeFormat))
+		if w != window {
+			window = w
+			fmt.Printf("\n### %s\n", w)
+		}
+		line := fmt.Sprintf("  [%-10s] %s %s: %s", timelineStatus(e), e.Topic, e.RowType, e.Key)
+		if e.Severity != "" {
+			line += fmt.Sprintf(" (%s)", e.Severity)
+		}
+		fmt.Printf("%s  [%s]\n", line, e.Component)
+	}
+	if len(events) == 0 {
+		fmt.Printf("\n%s\n", i18n.T("timeline.none"))
+	}
+	fmt.Printf("\n%s\n", i18n.T("timeline.summary", len(events)))
+}
+
+func printTimelineNDJSON(host string, snapshots int, events []timeline.Event) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for _, e := range events {
+		row := map[string]any{
+			"type":     "timeline_event",
+			"host":     host,
+			"audit":    e.Component,
+			"after":    e.After.UTC().Format(time.RFC3339),
+			"before":   e.Before.UTC().Format(time.RFC3339),
+			"topic":    e.Topic,
+			"row_type": e.RowType,
+			"key":      e.Key,
+			"status":   e.Status,
+			"first":    e.First,
+			"item":     e.Item,
+		}
+		if e.Severity != "" {
+			row["severity"] = e.Severity
+		}
+		enc.Encode(row)
+	}
+	enc.Encode(map[string]any{
+		"type":      "timeline_summary",
+		"host":      host,
+		"snapshots": snapshots,
+		"events":    len(events),
+	})
+}
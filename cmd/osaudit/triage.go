@@ -0,0 +1,186 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/exitcode"
+	"github.com/kareemsasa/operating-system-audit/internal/latest"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// triageCollectors are the collectors `osaudit triage` runs: what is running,
+// what is listening, what persists, and who logged in. The storage walks that
+// make a full audit slow are left out, as are the configuration and security
+// inventories, which rarely change in the first minutes of an incident.
+var triageCollectors = []string{"execution", "identity", "network", "persistence"}
+
+// triageDir is the directory under the output directory that triage runs and
+// their bundles are written to, apart from the scheduled snapshots.
+const triageDir = "triage"
+
+// runTriage runs the triage subset of the full audit and packs the run into
+// one bundle. It never writes .latest.json or notifies: a triage run is
+// evidence, not a baseline.
+func runTriage(commands []auditCommand, repoRoot, detectedOS string, args []string) int {
+	fs := flag.NewFlagSet("triage", flag.ContinueOnError)
+	hours := fs.Int("hours", 24, "List files modified in the last `N` hours")
+	out := fs.String("out", "", "Write the bundle to `path` (default <output>/triage/triage-<timestamp>.tar.gz)")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitcode.OK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitcode.Usage
+	}
+	if fs.NArg() > 0 || *hours < 1 {
+		fmt.Fprintln(os.Stderr, "triage: takes no arguments, and --hours must be at least 1")
+		printUsage()
+		return exitcode.Usage
+	}
+
+	command, err := findCommandByID(commands, fullAuditID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.Error
+	}
+	disabled, err := disabledCollectors(collector.Selection{Enable: triageCollectors})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "triage: %v\n", err)
+		return exitcode.Usage
+	}
+	if err := os.Setenv(collector.TriageEnv, strconv.Itoa(*hours)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Error
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	start := time.Now()
+	var meta latest.RunMeta
+	passthrough := []string{"--ndjson", "--report-dir", filepath.Join(outputDir(repoRoot), triageDir)}
+	partial := runAuditCommand(ctx, repoRoot, command, detectedOS, passthrough, true, &meta, disabled)
+	if partial != nil {
+		fmt.Fprintln(os.Stderr, partial)
+	}
+	// An interrupted run is bundled all the same: what it collected is
+	// still evidence.
+	if meta.Dir == "" {
+		fmt.Fprintln(os.Stderr, "triage: audit did not report its run directory")
+		if partial != nil {
+			return exitcode.Of(partial)
+		}
+		return exitcode.Error
+	}
+
+	dir := repoPath(repoRoot, meta.Dir)
+	bundle := *out
+	if bundle == "" {
+		bundle = filepath.Join(filepath.Dir(dir), "triage-"+filepath.Base(dir)+".tar.gz")
+	}
+	if err := writeTriageBundle(bundle, dir, meta); err != nil {
+		fmt.Fprintf(os.Stderr, "triage: write bundle: %v\n", err)
+		return exitcode.Error
+	}
+	fmt.Fprintf(os.Stderr, "Triage bundle written in %s\n", time.Since(start).Round(time.Second))
+	fmt.Println(bundle)
+	return exitcode.Of(partial)
+}
+
+// writeTriageBundle packs the run directory dir and its run meta into a
+// gzip-compressed tar at path. Everything is under one top-level directory
+// named for the bundle, and a SHA256SUMS file, written last, lists each
+// file's digest so the bundle can be checked after it leaves the host.
+func writeTriageBundle(path, dir string, meta latest.RunMeta) (err error) {
+	if err := writeguard.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := writeguard.CreateTemp(filepath.Dir(path), ".triage-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			writeguard.Remove(f.Name())
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	prefix := strings.TrimSuffix(filepath.Base(path), ".tar.gz") + "/"
+	var sums strings.Builder
+	add := func(name string, mode int64, modTime time.Time, r io.Reader, size int64) error {
+		hdr := &tar.Header{Name: prefix + name, Mode: mode, Size: size, ModTime: modTime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(tw, h), r); err != nil {
+			return err
+		}
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), name)
+		return nil
+	}
+
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	metaData = append(metaData, '\n')
+	if err := add("run-meta.json", 0o644, time.Now(), strings.NewReader(string(metaData)), int64(len(metaData))); err != nil {
+		return err
+	}
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		return add(filepath.ToSlash(rel), int64(info.Mode().Perm()), info.ModTime(), src, info.Size())
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: prefix + "SHA256SUMS", Mode: 0o644, Size: int64(sums.Len()), ModTime: time.Now(), Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(tw, sums.String()); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return writeguard.Rename(f.Name(), path)
+}
@@ -0,0 +1,303 @@
+// Package benchmark evaluates snapshots against embedded compliance benchmarks
+// (CIS macOS / Linux). Each control maps to a snapshot field and, optionally,
+// the probe that populates it so missing data can be explained.
+package benchmark
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/kb"
+)
+
+//go:embed benchmarks/*.json
+var benchmarkFS embed.FS
+
+const (
+	StatusPass    = "pass"
+	StatusFail    = "fail"
+	StatusUnknown = "unknown"
+)
+
+// Benchmark is a named set of controls for one platform.
+type Benchmark struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Version  string    `json:"version"`
+	Platform string    `json:"platform"`
+	Controls []Control `json:"controls"`
+}
+
+// Control is a single benchmark recommendation mapped to a snapshot field.
+type Control struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Level int    `json:"level"`
+	Check Check  `json:"check"`
+}
+
+// Check compares Field ("<row type>.<field>") against Value using Op
+// (see Compare). Probe names the probe that feeds the field; when it
+// failed in the snapshot, a missing field is reported as unknown with that reason.
+type Check struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+	Probe string `json:"probe,omitempty"`
+}
+
+// DocURL links the control to the knowledge base article for its probe, falling
+// back to the article covering its field.
+func (c Control) DocURL() string {
+	if u := kb.DocURL(c.Check.Probe); c.Check.Probe != "" && u != "" {
+		return u
+	}
+	return kb.DocURL(c.Check.Field)
+}
+
+// ControlResult is the outcome of evaluating one control.
+type ControlResult struct {
+	Control Control
+	Status  string
+	Actual  any
+	Reason  string
+}
+
+// Result is the outcome of evaluating a benchmark against a snapshot.
+type Result struct {
+	Benchmark Benchmark
+	Controls  []ControlResult
+	Pass      int
+	Fail      int
+	Unknown   int
+}
+
+// Percent returns the compliance percentage over scored (pass + fail) controls.
+// Unknown controls are excluded so missing data does not count as failure.
+func (r Result) Percent() float64 {
+	scored := r.Pass + r.Fail
+	if scored == 0 {
+		return 0
+	}
+	return float64(r.Pass) / float64(scored) * 100
+}
+
+// List returns the IDs of all embedded benchmarks, sorted.
+func List() []string {
+	entries, _ := fs.ReadDir(benchmarkFS, "benchmarks")
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Load returns the embedded benchmark with the given ID.
+func Load(id string) (Benchmark, error) {
+	data, err := benchmarkFS.ReadFile("benchmarks/" + id + ".json")
+	if err != nil {
+		return Benchmark{}, fmt.Errorf("unknown benchmark %q (available: %s)", id, strings.Join(List(), ", "))
+	}
+	var b Benchmark
+	if err := json.Unmarshal(data, &b); err != nil {
+		return Benchmark{}, fmt.Errorf("parse benchmark %s: %w", id, err)
+	}
+	return b, nil
+}
+
+// SnapshotPlatform returns the platform rows were collected on: mac, linux,
+// or windows. It is the meta row's platform, which the native Windows
+// collectors write, else read from the first word of its kernel (`uname -a`),
+// or "" when the meta row says neither.
+func SnapshotPlatform(rows []diff.Row) string {
+	for _, r := range rows {
+		if r["type"] != "meta" {
+			continue
+		}
+		if p, _ := r["platform"].(string); p != "" {
+			return p
+		}
+		kernel, _ := r["kernel"].(string)
+		switch first, _, _ := strings.Cut(kernel, " "); first {
+		case "Darwin":
+			return "mac"
+		case "Linux":
+			return "linux"
+		}
+		return ""
+	}
+	return ""
+}
+
+// CheckPlatform returns an error when rows were collected on a platform other
+// than b's. A snapshot whose platform is unknown passes.
+func (b Benchmark) CheckPlatform(rows []diff.Row) error {
+	if p := SnapshotPlatform(rows); p != "" && b.Platform != "" && p != b.Platform {
+		return fmt.Errorf("benchmark %s is for %s, but the snapshot was collected on %s", b.ID, b.Platform, p)
+	}
+	return nil
+}
+
+// Evaluate runs every control in b against the snapshot rows.
+func Evaluate(b Benchmark, rows []diff.Row) Result {
+	byType := diff.GroupByType(rows)
+	failedProbes := failedProbeSet(byType["probe_failures_summary"])
+
+	res := Result{Benchmark: b}
+	for _, c := range b.Controls {
+		cr := evaluateControl(c, byType, failedProbes)
+		switch cr.Status {
+		case StatusPass:
+			res.Pass++
+		case StatusFail:
+			res.Fail++
+		default:
+			res.Unknown++
+		}
+		res.Controls = append(res.Controls, cr)
+	}
+	return res
+}
+
+func evaluateControl(c Control, byType map[string]diff.Row, failedProbes map[string]struct{}) ControlResult {
+	cr := ControlResult{Control: c, Status: StatusUnknown}
+	actual, ok := LookupField(byType, c.Check.Field)
+	if !ok {
+		cr.Reason = fmt.Sprintf("%s not present in snapshot", c.Check.Field)
+		if _, failed := failedProbes[c.Check.Probe]; failed {
+			cr.Reason = fmt.Sprintf("probe %s failed", c.Check.Probe)
+		}
+		return cr
+	}
+	cr.Actual = actual
+	matched, err := Compare(actual, c.Check.Op, c.Check.Value)
+	if err != nil {
+		cr.Reason = err.Error()
+		return cr
+	}
+	if matched {
+		cr.Status = StatusPass
+	} else {
+		cr.Status = StatusFail
+		cr.Reason = fmt.Sprintf("%s is %v, want %s %v", c.Check.Field, actual, c.Check.Op, c.Check.Value)
+	}
+	return cr
+}
+
+// LookupField resolves "<row type>.<field>[.<nested>...]" against rows grouped by type.
+func LookupField(byType map[string]diff.Row, path string) (any, bool) {
+	parts := strings.Split(path, ".")
+	if len(parts) < 2 {
+		return nil, false
+	}
+	row, ok := byType[parts[0]]
+	if !ok {
+		return nil, false
+	}
+	var cur any = map[string]any(row)
+	for _, p := range parts[1:] {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[p]
+		if !ok || cur == nil {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// Compare applies op to actual and want. Operators: eq, ne, lt, le, gt, ge
+// (numeric), in (want is a list containing actual), and contains (actual is a
+// string containing want, or a list with an element equal to want).
+func Compare(actual any, op string, want any) (bool, error) {
+	switch op {
+	case "in":
+		list, ok := want.([]any)
+		if !ok {
+			return false, fmt.Errorf("operator in requires a list value (got %v)", want)
+		}
+		for _, w := range list {
+			if equal(actual, w) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "contains":
+		switch a := actual.(type) {
+		case string:
+			return strings.Contains(a, fmt.Sprint(want)), nil
+		case []any:
+			for _, e := range a {
+				if equal(e, want) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return false, fmt.Errorf("operator contains requires a string or list field (got %v)", actual)
+	case "eq":
+		return equal(actual, want), nil
+	case "ne":
+		return !equal(actual, want), nil
+	case "lt", "le", "gt", "ge":
+		a, aok := actual.(float64)
+		w, wok := toFloat(want)
+		if !aok || !wok {
+			return false, fmt.Errorf("operator %s requires numeric values (got %v, %v)", op, actual, want)
+		}
+		switch op {
+		case "lt":
+			return a < w, nil
+		case "le":
+			return a <= w, nil
+		case "gt":
+			return a > w, nil
+		default:
+			return a >= w, nil
+		}
+	default:
+		return false, fmt.Errorf("unsupported operator %q", op)
+	}
+}
+
+func equal(a, b any) bool {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		return af == bf
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	default:
+		return 0, false
+	}
+}
+
+func failedProbeSet(pf diff.Row) map[string]struct{} {
+	out := make(map[string]struct{})
+	items, _ := pf["items"].([]any)
+	for _, it := range items {
+		row, _ := it.(map[string]any)
+		if p, ok := row["probe"].(string); ok {
+			out[p] = struct{}{}
+		}
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package benchmark
+
+import (
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+func TestLoad_EmbeddedBenchmarks(t *testing.T) {
+	for _, id := range []string{"cis-macos", "cis-linux", "cis-kubernetes-node"} {
+		b, err := Load(id)
+		if err != nil {
+			t.Fatalf("Load(%q): %v", id, err)
+		}
+		if b.ID != id {
+			t.Errorf("Load(%q).ID = %q", id, b.ID)
+		}
+		if len(b.Controls) == 0 {
+			t.Errorf("Load(%q): no controls", id)
+		}
+		for _, c := range b.Controls {
+			if c.ID == "" || c.Title == "" || c.Check.Field == "" || c.Check.Op == "" {
+				t.Errorf("Load(%q): incomplete control %+v", id, c)
+			}
+		}
+	}
+	if _, err := Load("cis-solaris"); err == nil {
+		t.Error("Load(cis-solaris) = nil error, want unknown benchmark")
+	}
+}
+
+func TestEvaluate_PassFailUnknown(t *testing.T) {
+	b := Benchmark{
+		ID: "test",
+		Controls: []Control{
+			{ID: "1", Title: "FileVault", Check: Check{Field: "security_config.filevault", Op: "eq", Value: true}},
+			{ID: "2", Title: "SIP", Check: Check{Field: "security_config.sip", Op: "eq", Value: true}},
+			{ID: "3", Title: "Gatekeeper", Check: Check{Field: "security_config.gatekeeper", Op: "eq", Value: true, Probe: "config.spctl_status"}},
+			{ID: "4", Title: "Symlinks", Check: Check{Field: "counts.broken_symlinks", Op: "lt", Value: 10.0}},
+		},
+	}
+	rows := []diff.Row{
+		{"type": "security_config", "filevault": true, "sip": false},
+		{"type": "counts", "broken_symlinks": 3.0},
+		{"type": "probe_failures_summary", "items": []any{map[string]any{"probe": "config.spctl_status"}}},
+	}
+
+	res := Evaluate(b, rows)
+	want := map[string]string{"1": StatusPass, "2": StatusFail, "3": StatusUnknown, "4": StatusPass}
+	for _, cr := range res.Controls {
+		if cr.Status != want[cr.Control.ID] {
+			t.Errorf("control %s status = %s, want %s (%s)", cr.Control.ID, cr.Status, want[cr.Control.ID], cr.Reason)
+		}
+	}
+	if res.Controls[2].Reason != "probe config.spctl_status failed" {
+		t.Errorf("unknown reason = %q, want probe failure", res.Controls[2].Reason)
+	}
+	if res.Pass != 2 || res.Fail != 1 || res.Unknown != 1 {
+		t.Errorf("counts = %d/%d/%d, want 2/1/1", res.Pass, res.Fail, res.Unknown)
+	}
+	if got := res.Percent(); got < 66.6 || got > 66.7 {
+		t.Errorf("Percent() = %.2f, want 66.67", got)
+	}
+}
+
+func TestEvaluate_KubernetesNode(t *testing.T) {
+	b, err := Load("cis-kubernetes-node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []diff.Row{{"type": "k8s_node", "anonymous_auth": true, "authorization_mode": "Webhook", "read_only_port": 10255.0}}
+	res := Evaluate(b, rows)
+	if res.Pass != 1 || res.Fail != 2 {
+		t.Errorf("pass/fail = %d/%d, want 1/2", res.Pass, res.Fail)
+	}
+	// A host without a kubelet has no k8s_node row, so nothing is scored.
+	if res := Evaluate(b, nil); res.Unknown != len(b.Controls) {
+		t.Errorf("unknown without a k8s_node row = %d, want %d", res.Unknown, len(b.Controls))
+	}
+}
+
+func TestCheckPlatform(t *testing.T) {
+	mac, err := Load("cis-macos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		meta diff.Row
+		ok   bool
+	}{
+		{diff.Row{"type": "meta", "kernel": "Darwin mbp.local 23.4.0 Darwin Kernel Version 23.4.0"}, true},
+		{diff.Row{"type": "meta", "kernel": "Linux vm 6.8.0-45-generic #45-Ubuntu SMP x86_64 GNU/Linux"}, false},
+		{diff.Row{"type": "meta", "platform": "windows"}, false},
+		{diff.Row{"type": "meta"}, true}, // unknown platform
+	}
+	for _, tc := range cases {
+		err := mac.CheckPlatform([]diff.Row{tc.meta, {"type": "security_config"}})
+		if (err == nil) != tc.ok {
+			t.Errorf("CheckPlatform(%v) = %v, want ok %v", tc.meta, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package collector is the registry of built-in collectors: the audit modules
+// in audit/<os>/ that the full audit runs in turn and that also run alone as
+// manifest commands. Collectors are enabled or disabled by ID, from
+// ~/.osaudit/collectors.yaml and the --enable/--disable flags of `osaudit run`,
+// so environments that must never list processes or probe the network can turn
+// those collectors off.
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/deprecation"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
+)
+
+// DisabledEnv is the environment variable that passes the disabled collector
+// IDs, comma-separated, to the audit scripts.
+const DisabledEnv = "OSAUDIT_DISABLED_COLLECTORS"
+
+// ScopeEnv is the environment variable that passes the paths of a scoped run
+// (`osaudit run --scope`), joined with os.PathListSeparator, to the audit
+// scripts.
+const ScopeEnv = "OSAUDIT_SCOPE"
+
+// TriageEnv is the environment variable that passes the window of an
+// `osaudit triage` run, in hours, to the audit scripts. When set, identity
+// also lists recent logins and persistence the files modified in the window.
+const TriageEnv = "OSAUDIT_TRIAGE_HOURS"
+
+// Collector is a registered built-in collector.
+type Collector struct {
+	ID      string `json:"id"`
+	Display string `json:"display"`
+	// Reads summarizes what the collector inspects, so privacy reviews can
+	// decide what to disable.
+	Reads string `json:"reads"`
+	// Scoped collectors can be limited to paths with `osaudit run --scope`.
+	Scoped bool `json:"scoped,omitempty"`
+}
+
+var registry = map[string]Collector{}
+
+// Register adds c to the registry. It panics on an empty or duplicate ID, which
+// is a programming error.
+func Register(c Collector) {
+	if c.ID == "" {
+		panic("collector: empty ID")
+	}
+	if _, dup := registry[c.ID]; dup {
+		panic("collector: duplicate ID " + c.ID)
+	}
+	registry[c.ID] = c
+}
+
+func init() {
+	Register(Collector{ID: "storage", Display: "Storage", Reads: "home directory sizes, SMART disk health, large and stale files, quarantine flags of downloaded executables, caches, installers", Scoped: true})
+	Register(Collector{ID: "network", Display: "Network", Reads: "interfaces, listening ports, DNS resolvers, hosts file, VPNs and tunnels, routing table, ARP and neighbor caches, firewall, active connections, Wi-Fi, proxy settings, network context"})
+	Register(Collector{ID: "identity", Display: "Identity & access", Reads: "local users, groups, sudoers, SSH keys, authorized_keys, ssh client hosts, sshd settings, secrets agents, credentials in environment variables, login shell, and under `osaudit triage` recent logins"})
+	Register(Collector{ID: "config", Display: "System configuration", Reads: "security defaults, per-volume disk encryption, firmware and Secure Boot state, MDM enrollment and configuration profiles, XProtect and Gatekeeper data freshness, time synchronization, environment, package managers, installed applications and their code signatures, Mac App Store apps, developer toolchains, installed python3, node, go, java, and ruby runtimes, global npm, pipx, pip, gem, and cargo packages, IDE extensions, trusted certificates, shell profiles, watched dotfiles, kubelet settings and static pods, Windows security event counts"})
+	Register(Collector{ID: "execution", Display: "Execution & processes", Reads: "process listing (top CPU and memory), Docker and Podman containers, AI agents and MCP server configs, scheduled tasks, timers, open file descriptors against their limits"})
+	Register(Collector{ID: "persistence", Display: "Persistence surfaces", Reads: "launch daemons and agents, login and background items, services, cron and at jobs, kernel modules and extensions, autostart, shell startup files, and under `osaudit triage` recently modified files", Scoped: true})
+	Register(Collector{ID: "security", Display: "Security checks", Reads: "PATH directory owners and permissions, attached USB devices and their classes, mounted removable volumes"})
+}
+
+// All returns the registered collectors sorted by ID.
+func All() []Collector {
+	out := make([]Collector, 0, len(registry))
+	for _, c := range registry {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Lookup returns the collector registered under id.
+func Lookup(id string) (Collector, bool) {
+	c, ok := registry[id]
+	return c, ok
+}
+
+// Selection lists collectors to enable or disable. An empty Enable means all
+// collectors.
+//
+// ~/.osaudit/collectors.yaml:
+//
+//	# never list processes or probe the network on this fleet
+//	disable: [execution, network]
+type Selection struct {
+	Enable  []string `json:"enable"`
+	Disable []string `json:"disable"`
+}
+
+// LoadSelection reads a collectors file, with the environment's settings
+// (see config.ApplyEnv) over it. A missing file is an empty Selection.
+func LoadSelection(path string) (Selection, error) {
+	var s Selection
+	var doc any
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return s, err
+	default:
+		if doc, err = yamlite.Parse(data); err != nil {
+			return s, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := deprecation.Check(deprecation.Collectors, path, doc); err != nil {
+			return s, err
+		}
+	}
+	if doc, err = config.ApplyEnv("collectors", doc); err != nil {
+		return s, err
+	}
+	if err := yamlite.Decode(doc, &s); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// ParseList splits a comma-separated flag value into collector IDs.
+func ParseList(v string) []string {
+	var ids []string
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			ids = append(ids, f)
+		}
+	}
+	return ids
+}
+
+// Validate reports the first unknown collector ID in s.
+func (s Selection) Validate() error {
+	for _, list := range [][]string{s.Enable, s.Disable} {
+		for _, id := range list {
+			if _, ok := registry[id]; !ok {
+				return fmt.Errorf("unknown collector %q (run `osaudit collectors`)", id)
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve combines the config file's selection with the flags' and returns the
+// disabled collector IDs, sorted. A flag enable list replaces the config's, but
+// disables add up: a collector disabled in the config file cannot be turned
+// back on from the command line.
+func Resolve(config, flags Selection) ([]string, error) {
+	if err := flags.Validate(); err != nil {
+		return nil, err
+	}
+	enable := config.Enable
+	if len(flags.Enable) > 0 {
+		enable = flags.Enable
+	}
+	off := make(map[string]bool)
+	if len(enable) > 0 {
+		on := make(map[string]bool, len(enable))
+		for _, id := range enable {
+			on[id] = true
+		}
+		for id := range registry {
+			off[id] = !on[id]
+		}
+	}
+	for _, id := range append(append([]string(nil), config.Disable...), flags.Disable...) {
+		off[id] = true
+	}
+	var disabled []string
+	for id, isOff := range off {
+		if isOff {
+			disabled = append(disabled, id)
+		}
+	}
+	sort.Strings(disabled)
+	return disabled, nil
+}
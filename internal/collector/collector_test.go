@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		config, flags Selection
+		want          string
+	}{
+		{"default", Selection{}, Selection{}, ""},
+		{"config disable", Selection{Disable: []string{"network", "execution"}}, Selection{}, "execution,network"},
+		{"config enable", Selection{Enable: []string{"storage", "config"}}, Selection{}, "execution,identity,network,persistence,security"},
+		{"flag enable replaces config enable", Selection{Enable: []string{"storage"}}, Selection{Enable: []string{"network"}}, "config,execution,identity,persistence,security,storage"},
+		{"config disable wins over flag enable", Selection{Disable: []string{"network"}}, Selection{Enable: []string{"network", "storage"}}, "config,execution,identity,network,persistence,security"},
+		{"disables add up", Selection{Disable: []string{"network"}}, Selection{Disable: []string{"execution"}}, "execution,network"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Resolve(tc.config, tc.flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != tc.want {
+				t.Errorf("Resolve() = %v, want %s", got, tc.want)
+			}
+		})
+	}
+	if _, err := Resolve(Selection{}, Selection{Disable: []string{"processes"}}); err == nil || !strings.Contains(err.Error(), "unknown collector") {
+		t.Errorf("Resolve(unknown) err = %v", err)
+	}
+}
+
+func TestLoadSelection(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "collectors.yaml")
+	if s, err := LoadSelection(path); err != nil || len(s.Enable)+len(s.Disable) != 0 {
+		t.Errorf("LoadSelection(missing) = %+v, %v", s, err)
+	}
+	if err := os.WriteFile(path, []byte("# privacy\ndisable:\n  - execution\n  - network\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSelection(path)
+	if err != nil || strings.Join(s.Disable, ",") != "execution,network" {
+		t.Errorf("LoadSelection() = %+v, %v", s, err)
+	}
+	if err := os.WriteFile(path, []byte("enable: [storage, procs]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSelection(path); err == nil || !strings.Contains(err.Error(), `"procs"`) {
+		t.Errorf("LoadSelection(unknown) err = %v", err)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	if got := strings.Join(ParseList(" network, ,execution "), "|"); got != "network|execution" {
+		t.Errorf("ParseList() = %q", got)
+	}
+}
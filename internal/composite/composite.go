@@ -0,0 +1,132 @@
+// Package composite correlates rows of different types into single findings.
+// Each row type alone may look harmless, e.g. an unsigned launch daemon or a
+// process listening on a port, while the combination (an unsigned daemon that
+// is listening) is worth acting on. A composite finding names every row item
+// it was built from, so a reader can go from the finding to the evidence.
+package composite
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/kb"
+)
+
+// Ref links a finding to one constituent row item: the row type and the
+// item's identity within that row (a label, a port, a path).
+type Ref struct {
+	Type string `json:"type"`
+	Key  string `json:"key"`
+}
+
+func (r Ref) String() string { return r.Type + ":" + r.Key }
+
+// Finding is one composite finding.
+type Finding struct {
+	Detector Detector
+	Subject  string
+	Message  string
+	Refs     []Ref
+}
+
+// Detector is a built-in correlation across row types. Find receives the last
+// row of each type (as diff.GroupByType returns them) and reports nothing when
+// a row type it needs is missing.
+type Detector struct {
+	ID       string
+	Title    string
+	Severity string
+	// Types are the row types the detector reads.
+	Types []string
+	Find  func(byType map[string]diff.Row) []Finding
+}
+
+// DocURL is the knowledge base link for the detector.
+func (d Detector) DocURL() string { return kb.DocURL("composite." + d.ID) }
+
+var detectors = []Detector{
+	{
+		ID:       "unsigned_persistent_listener",
+		Title:    "Unsigned persistent program listening on the network",
+		Severity: "high",
+		Types:    []string{"launch_daemons", "listening_ports"},
+		Find:     findUnsignedPersistentListeners,
+	},
+}
+
+// Detectors returns the built-in detectors in report order.
+func Detectors() []Detector { return detectors }
+
+// Run runs every built-in detector against the snapshot rows.
+func Run(rows []diff.Row) []Finding {
+	byType := diff.GroupByType(rows)
+	var out []Finding
+	for _, d := range detectors {
+		for _, f := range d.Find(byType) {
+			f.Detector = d
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// lsofCommandWidth is how many characters of a command name lsof prints by
+// default; longer names are truncated.
+const lsofCommandWidth = 9
+
+// findUnsignedPersistentListeners reports launch daemons whose program is
+// unsigned and whose process listens on a TCP port. Listening ports carry the
+// process name only, so a daemon matches by its program's base name, or by a
+// prefix of it when lsof truncated the name.
+func findUnsignedPersistentListeners(byType map[string]diff.Row) []Finding {
+	daemons, ports := items(byType["launch_daemons"]), items(byType["listening_ports"])
+	if len(daemons) == 0 || len(ports) == 0 {
+		return nil
+	}
+	var out []Finding
+	for _, d := range daemons {
+		if signed, ok := d["signed"].(bool); !ok || signed {
+			continue
+		}
+		label, _ := d["label"].(string)
+		program, _ := d["program"].(string)
+		if label == "" || program == "" || program == "unknown" {
+			continue
+		}
+		name := path.Base(program)
+		var refs []Ref
+		var portList []string
+		for _, p := range ports {
+			proc, _ := p["process"].(string)
+			proc = strings.ReplaceAll(proc, `\x20`, " ")
+			if proc == "" || !(proc == name || len(proc) >= lsofCommandWidth && strings.HasPrefix(name, proc)) {
+				continue
+			}
+			port := fmt.Sprint(p["port"])
+			refs = append(refs, Ref{Type: "listening_ports", Key: port})
+			portList = append(portList, port)
+		}
+		if len(refs) == 0 {
+			continue
+		}
+		out = append(out, Finding{
+			Subject: label,
+			Message: fmt.Sprintf("launch daemon %s runs unsigned %s, which listens on port(s) %s", label, program, strings.Join(portList, ", ")),
+			Refs:    append([]Ref{{Type: "launch_daemons", Key: label}}, refs...),
+		})
+	}
+	return out
+}
+
+func items(row diff.Row) []map[string]any {
+	list, _ := row["items"].([]any)
+	out := make([]map[string]any, 0, len(list))
+	for _, it := range list {
+		if m, ok := it.(map[string]any); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
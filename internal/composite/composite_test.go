@@ -0,0 +1,46 @@
+package composite
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+func TestRun_UnsignedPersistentListener(t *testing.T) {
+	daemon := func(label, program string, signed any) map[string]any {
+		return map[string]any{"label": label, "program": program, "signed": signed, "team_id": ""}
+	}
+	rows := []diff.Row{
+		{"type": "launch_daemons", "items": []any{
+			daemon("com.example.agent", "/usr/local/bin/example-agentd", false),
+			daemon("com.vendor.helper", "/Library/PrivilegedHelperTools/helperd", true),
+			daemon("com.example.gone", "/opt/gone/bin/goned", nil),
+			daemon("com.example.idle", "/usr/local/bin/idled", false),
+		}},
+		{"type": "listening_ports", "items": []any{
+			// lsof truncates command names to nine characters.
+			map[string]any{"process": "example-a", "pid": 412.0, "port": 8443.0},
+			map[string]any{"process": "example-a", "pid": 412.0, "port": 9000.0},
+			map[string]any{"process": "helperd", "pid": 98.0, "port": 5000.0},
+			map[string]any{"process": "goned", "pid": 77.0, "port": 7000.0},
+		}},
+	}
+
+	findings := Run(rows)
+	if len(findings) != 1 {
+		t.Fatalf("Run() = %+v, want one finding", findings)
+	}
+	f := findings[0]
+	if f.Detector.ID != "unsigned_persistent_listener" || f.Subject != "com.example.agent" || f.Detector.Severity != "high" {
+		t.Errorf("finding = %s %s %s", f.Detector.ID, f.Subject, f.Detector.Severity)
+	}
+	want := []Ref{{"launch_daemons", "com.example.agent"}, {"listening_ports", "8443"}, {"listening_ports", "9000"}}
+	if !reflect.DeepEqual(f.Refs, want) {
+		t.Errorf("refs = %v, want %v", f.Refs, want)
+	}
+
+	if got := Run(rows[:1]); len(got) != 0 {
+		t.Errorf("Run() without listening_ports = %+v, want none", got)
+	}
+}
@@ -0,0 +1,23 @@
+// Package config resolves osaudit's per-user configuration directory, where acks,
+// ignore rules, custom rules, and plugins live.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir returns the osaudit configuration directory: $OSAUDIT_HOME when set,
+// otherwise ~/.osaudit. The directory is not created.
+func Dir() (string, error) {
+	if override := strings.TrimSpace(os.Getenv("OSAUDIT_HOME")); override != "" {
+		return filepath.Clean(override), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home directory (set OSAUDIT_HOME): %w", err)
+	}
+	return filepath.Join(home, ".osaudit"), nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
+)
+
+// File is a config file whose settings can also come from the environment,
+// so containers and CI can configure osaudit without mounting it.
+//
+// OSAUDIT_<NAME> holds a whole document (YAML or JSON) that replaces the file,
+// and OSAUDIT_<NAME>_<KEY> replaces one key of it, e.g.
+// OSAUDIT_COLLECTORS_DISABLE=execution,network. Values are JSON or YAML
+// scalars and flow collections; keys marked as lists also take a plain
+// comma-separated string.
+type File struct {
+	Name string   // file name without extension, e.g. "collectors"
+	Keys []string // dotted key paths; a "[]" suffix marks a list
+}
+
+// Files lists every config file the environment can configure.
+var Files = []File{
+	{Name: "collectors", Keys: []string{"enable[]", "disable[]"}},
+	{Name: "classify", Keys: []string{"severity.prefixes", "severity.exact", "expected_exit_codes", "topics"}},
+	{Name: "config", Keys: []string{"output_dir", "redact", "concurrency", "collectors.enable[]", "collectors.disable[]", "notify.urls[]", "ignore[]"}},
+}
+
+// Directory and rule settings that have their own variables. Each defaults to
+// a path under Dir.
+const (
+	IgnoreEnv      = "OSAUDIT_IGNORE"       // ignore patterns, replacing ignore.json
+	ExcludeEnv     = "OSAUDIT_EXCLUDE"      // excluded paths, replacing <Dir>/exclude
+	DotfilesEnv    = "OSAUDIT_DOTFILES"     // watched dotfiles, replacing <Dir>/dotfiles
+	RulesDirEnv    = "OSAUDIT_RULES_DIR"    // custom rules, default <Dir>/rules
+	PluginsDirEnv  = "OSAUDIT_PLUGINS_DIR"  // collector plugins, default <Dir>/plugins
+	EventsEnv      = "OSAUDIT_EVENTS"       // event log file, "-" for stderr; unset for none
+	MemoryLimitEnv = "OSAUDIT_MEMORY_LIMIT" // rows held in memory before spilling to disk, e.g. 512M
+	HashAlgEnv     = "OSAUDIT_HASH_ALG"     // content hash algorithm: blake3 (default) or sha256
+	ContextEnv     = "OSAUDIT_CONTEXT"      // baseline context, overriding <Dir>/contexts.yaml
+)
+
+// EnvName returns the variable for key of config file name; an empty key
+// names the whole-document variable.
+func EnvName(name, key string) string {
+	v := "OSAUDIT_" + strings.ToUpper(name)
+	if key = strings.TrimSuffix(key, "[]"); key != "" {
+		v += "_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	}
+	return v
+}
+
+// ApplyEnv overlays the environment's settings for config file name onto doc,
+// the file as parsed by yamlite.Parse (nil when there is no file), and returns
+// the result. The whole-document variable replaces doc before the per-key
+// variables are applied.
+func ApplyEnv(name string, doc any) (any, error) {
+	var file File
+	for _, f := range Files {
+		if f.Name == name {
+			file = f
+		}
+	}
+	if file.Name == "" {
+		return doc, nil
+	}
+	if text, ok := lookupEnv(EnvName(name, "")); ok {
+		parsed, err := yamlite.Parse([]byte(text))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvName(name, ""), err)
+		}
+		doc = parsed
+	}
+	for _, key := range file.Keys {
+		env := EnvName(name, key)
+		text, ok := lookupEnv(env)
+		if !ok {
+			continue
+		}
+		value, err := ParseEnvValue(text, strings.HasSuffix(key, "[]"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", env, err)
+		}
+		m, _ := doc.(map[string]any)
+		if m == nil {
+			m = map[string]any{}
+		}
+		setPath(m, strings.Split(strings.TrimSuffix(key, "[]"), "."), value)
+		doc = m
+	}
+	return doc, nil
+}
+
+// ParseEnvValue parses a variable's value: JSON, a YAML scalar or flow
+// collection, or, for a list, a comma-separated string.
+func ParseEnvValue(text string, list bool) (any, error) {
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err == nil {
+		return v, nil
+	}
+	v, err := yamlite.ParseValue(text)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := v.(string); ok && list {
+		items := []any{}
+		for _, f := range strings.Split(s, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				items = append(items, f)
+			}
+		}
+		return items, nil
+	}
+	return v, nil
+}
+
+func setPath(m map[string]any, path []string, value any) {
+	for _, seg := range path[:len(path)-1] {
+		next, ok := m[seg].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[seg] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+// lookupEnv returns a variable's value when it is set and not blank.
+func lookupEnv(name string) (string, bool) {
+	v, ok := os.LookupEnv(name)
+	if !ok || strings.TrimSpace(v) == "" {
+		return "", false
+	}
+	return v, true
+}
+
+// SubDir returns the directory for a setting with its own variable: the
+// variable when set, otherwise name under Dir.
+func SubDir(env, name string) (string, error) {
+	if v, ok := lookupEnv(env); ok {
+		return strings.TrimSpace(v), nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	cases := map[[2]string]string{
+		{"collectors", ""}:                  "OSAUDIT_COLLECTORS",
+		{"collectors", "disable[]"}:         "OSAUDIT_COLLECTORS_DISABLE",
+		{"classify", "severity.prefixes"}:   "OSAUDIT_CLASSIFY_SEVERITY_PREFIXES",
+		{"classify", "expected_exit_codes"}: "OSAUDIT_CLASSIFY_EXPECTED_EXIT_CODES",
+	}
+	for in, want := range cases {
+		if got := EnvName(in[0], in[1]); got != want {
+			t.Errorf("EnvName(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
+
+func TestParseEnvValue(t *testing.T) {
+	cases := []struct {
+		text string
+		list bool
+		want any
+	}{
+		{"execution, network", true, []any{"execution", "network"}},
+		{"[execution, network]", true, []any{"execution", "network"}},
+		{`["execution"]`, true, []any{"execution"}},
+		{"{config.: low}", false, map[string]any{"config.": "low"}},
+		{`{"config.sip_status": "high"}`, false, map[string]any{"config.sip_status": "high"}},
+		{"low", false, "low"},
+	}
+	for _, c := range cases {
+		got, err := ParseEnvValue(c.text, c.list)
+		if err != nil {
+			t.Errorf("ParseEnvValue(%q) error: %v", c.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseEnvValue(%q) = %#v, want %#v", c.text, got, c.want)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	file := map[string]any{"enable": []any{"storage"}, "disable": []any{"network"}}
+
+	t.Setenv("OSAUDIT_COLLECTORS_DISABLE", "execution,network")
+	got, err := ApplyEnv("collectors", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"enable": []any{"storage"}, "disable": []any{"execution", "network"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("key override = %#v, want %#v", got, want)
+	}
+
+	// The whole-document variable replaces the file; key variables still win.
+	t.Setenv("OSAUDIT_COLLECTORS", "{enable: [identity]}")
+	got, err = ApplyEnv("collectors", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]any{"enable": []any{"identity"}, "disable": []any{"execution", "network"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("document override = %#v, want %#v", got, want)
+	}
+
+	// Nested keys are created when there is no file.
+	t.Setenv("OSAUDIT_CLASSIFY_SEVERITY_EXACT", "{config.sip_status: high}")
+	got, err = ApplyEnv("classify", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]any{"severity": map[string]any{"exact": map[string]any{"config.sip_status": "high"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nested key = %#v, want %#v", got, want)
+	}
+
+	t.Setenv("OSAUDIT_CLASSIFY", "{topics: [")
+	if _, err := ApplyEnv("classify", nil); err == nil {
+		t.Error("ApplyEnv accepted a malformed OSAUDIT_CLASSIFY")
+	}
+}
+
+func TestSubDir(t *testing.T) {
+	t.Setenv("OSAUDIT_HOME", "/etc/osaudit")
+	t.Setenv(RulesDirEnv, "")
+	if got, _ := SubDir(RulesDirEnv, "rules"); got != "/etc/osaudit/rules" && got != `\etc\osaudit\rules` {
+		t.Errorf("SubDir default = %q", got)
+	}
+	t.Setenv(RulesDirEnv, "/srv/rules")
+	if got, _ := SubDir(RulesDirEnv, "rules"); got != "/srv/rules" {
+		t.Errorf("SubDir override = %q, want /srv/rules", got)
+	}
+}
@@ -0,0 +1,130 @@
+// Package contexts holds the named contexts a host is audited in, such as
+// home, office, and travel, so each can keep its own baseline and the
+// expected differences between locations stop showing as drift.
+//
+// Contexts live in <config dir>/contexts.yaml and are tried in order against
+// a snapshot's network_context row:
+//
+//	contexts:
+//	  - name: home
+//	    networks: [3f9a1c0b22de]
+//	  - name: office
+//	    ssids: [CorpWiFi]
+//	    gateway_macs: ["00:11:22:33:44:55"]
+//	  - name: travel
+//
+// A context matches when any of its networks (network_context ids), ssids,
+// or gateway_macs does. One that lists none matches every network, so it
+// belongs last. $OSAUDIT_CONTEXT names the context outright instead.
+package contexts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
+)
+
+// File is the contexts file's name in the config directory.
+const File = "contexts.yaml"
+
+// NamePattern is what a context name must match: it becomes part of
+// baseline file names.
+var NamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Context is one named context and the networks it covers.
+type Context struct {
+	Name        string   `json:"name"`
+	Networks    []string `json:"networks"`
+	SSIDs       []string `json:"ssids"`
+	GatewayMACs []string `json:"gateway_macs"`
+}
+
+// Parse reads a contexts file; name prefixes errors.
+func Parse(name string, data []byte) ([]Context, error) {
+	var doc struct {
+		Contexts []Context `json:"contexts"`
+	}
+	if err := yamlite.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	seen := make(map[string]bool)
+	for i, c := range doc.Contexts {
+		if !NamePattern.MatchString(c.Name) {
+			return nil, fmt.Errorf("%s: context %d: name %q must match %s", name, i+1, c.Name, NamePattern)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("%s: context %q is defined twice", name, c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return doc.Contexts, nil
+}
+
+// Load returns the contexts in the config directory's contexts file. A
+// missing file defines none.
+func Load() ([]Context, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, nil
+	}
+	p := filepath.Join(dir, File)
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(p, data)
+}
+
+// Match returns the name of the first context covering network, a
+// network_context row, or "" when none does or network is nil. SSIDs and
+// MAC addresses compare case-insensitively.
+func Match(contexts []Context, network map[string]any) string {
+	if network == nil {
+		return ""
+	}
+	id, _ := network["id"].(string)
+	ssid, _ := network["ssid"].(string)
+	mac, _ := network["gateway_mac"].(string)
+	has := func(list []string, v string) bool {
+		return v != "" && slices.ContainsFunc(list, func(s string) bool { return strings.EqualFold(s, v) })
+	}
+	for _, c := range contexts {
+		if len(c.Networks)+len(c.SSIDs)+len(c.GatewayMACs) == 0 {
+			return c.Name
+		}
+		if has(c.Networks, id) || has(c.SSIDs, ssid) || has(c.GatewayMACs, mac) {
+			return c.Name
+		}
+	}
+	return ""
+}
+
+// Resolve returns the context a snapshot was taken in: name when it is set,
+// else $OSAUDIT_CONTEXT, else the contexts file's match for network. "" means
+// no context, and baselines are the host's usual ones.
+func Resolve(name string, network map[string]any) (string, error) {
+	if name == "" {
+		name = strings.TrimSpace(os.Getenv(config.ContextEnv))
+	}
+	if name != "" {
+		if !NamePattern.MatchString(name) {
+			return "", fmt.Errorf("context name %q must match %s", name, NamePattern)
+		}
+		return name, nil
+	}
+	contexts, err := Load()
+	if err != nil {
+		return "", err
+	}
+	return Match(contexts, network), nil
+}
@@ -0,0 +1,76 @@
+package contexts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+)
+
+const file = `contexts:
+  - name: home
+    networks: [3f9a1c0b22de]
+  - name: office
+    ssids: [CorpWiFi]
+    gateway_macs: ["00:11:22:33:44:55"]
+  - name: travel
+`
+
+func TestMatch(t *testing.T) {
+	contexts, err := Parse("contexts.yaml", []byte(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		network map[string]any
+		want    string
+	}{
+		{map[string]any{"id": "3f9a1c0b22de", "ssid": "<ssid>"}, "home"},
+		{map[string]any{"id": "a41c07d9e5f2", "ssid": "corpwifi"}, "office"},
+		{map[string]any{"id": "a41c07d9e5f2", "ssid": "<ssid>", "gateway_mac": "00:11:22:33:44:55"}, "office"},
+		{map[string]any{"id": "e3b0c44298fc", "ssid": "CoffeeShop"}, "travel"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Match(contexts, tt.network); got != tt.want {
+			t.Errorf("Match(%v) = %q, want %q", tt.network, got, tt.want)
+		}
+	}
+	if got := Match(contexts[:2], map[string]any{"id": "e3b0c44298fc"}); got != "" {
+		t.Errorf("Match() without a catch-all = %q, want none", got)
+	}
+
+	for _, bad := range []string{"contexts:\n  - name: ../home\n", "contexts:\n  - name: home\n  - name: home\n"} {
+		if _, err := Parse("contexts.yaml", []byte(bad)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("OSAUDIT_HOME", dir)
+	t.Setenv(config.ContextEnv, "")
+	network := map[string]any{"id": "3f9a1c0b22de"}
+
+	if got, err := Resolve("", network); err != nil || got != "" {
+		t.Errorf("Resolve() without contexts.yaml = %q, %v, want none", got, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, File), []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Resolve("", network); err != nil || got != "home" {
+		t.Errorf("Resolve() = %q, %v, want home", got, err)
+	}
+	t.Setenv(config.ContextEnv, "office")
+	if got, err := Resolve("", network); err != nil || got != "office" {
+		t.Errorf("Resolve() with $%s = %q, %v, want office", config.ContextEnv, got, err)
+	}
+	if got, err := Resolve("travel", network); err != nil || got != "travel" {
+		t.Errorf("Resolve(travel) = %q, %v, want travel", got, err)
+	}
+	if _, err := Resolve("a/b", network); err == nil {
+		t.Error("Resolve(a/b) succeeded, want an error")
+	}
+}
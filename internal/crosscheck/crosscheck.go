@@ -0,0 +1,405 @@
+// Package crosscheck compares the host state a management tool expects with
+// a live snapshot. Expected state is imported from Ansible facts or a
+// Terraform state file, covering local users, system packages, and enabled
+// services, or from a Jamf or Intune inventory export, covering applications
+// and device settings (see mdm.go):
+//
+//   - missing:   the tool believes the resource exists, the snapshot lacks it
+//   - unmanaged: the snapshot has a resource the tool does not know about
+//   - version:   a package or app is installed at a version the tool does not expect
+//
+// Only kinds present in both the expected state and the snapshot are compared,
+// so a Terraform state that manages no packages reports no package findings.
+package crosscheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+// Resource kinds.
+const (
+	KindUser    = "user"
+	KindPackage = "package"
+	KindService = "service"
+	KindApp     = "app"
+	// KindSetting covers single values an MDM reports about a device:
+	// encryption, os_version, and last_inventory.
+	KindSetting = "setting"
+)
+
+// Kinds lists the resource kinds in report order.
+var Kinds = []string{KindUser, KindPackage, KindService, KindApp}
+
+// Finding statuses.
+const (
+	StatusMissing   = "missing"
+	StatusUnmanaged = "unmanaged"
+	StatusVersion   = "version"
+)
+
+// kindRows maps each kind to the snapshot row type listing it.
+var kindRows = map[string]string{
+	KindUser:    "local_users",
+	KindPackage: "system_packages",
+	KindService: "enabled_services",
+	KindApp:     "applications",
+}
+
+// Resource is one expected or observed host resource. Version is empty when
+// the source does not pin one. UID is -1 when unknown.
+type Resource struct {
+	Kind    string
+	Name    string
+	Version string
+	UID     int
+}
+
+// Expected is the state a configuration management tool expects on one host.
+type Expected struct {
+	Source    string
+	Resources []Resource
+	// Kinds records the kinds the source covers, even when it lists none of
+	// them (e.g. package_facts on a host without packages).
+	Kinds map[string]bool
+}
+
+func (e *Expected) add(kind, name, version string, uid int) {
+	if name == "" {
+		return
+	}
+	e.Kinds[kind] = true
+	e.Resources = append(e.Resources, Resource{Kind: kind, Name: name, Version: version, UID: uid})
+}
+
+// Finding is one difference between expected and observed state.
+type Finding struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// Result is the outcome of a cross-check.
+type Result struct {
+	Findings []Finding
+	// Compared lists the kinds that were compared; Skipped, the kinds the
+	// source covers but the snapshot does not.
+	Compared []string
+	Skipped  []string
+	// Partial lists kinds whose snapshot list is truncated (e.g. enabled
+	// services beyond the report limit), so missing ones are not reported.
+	Partial []string
+}
+
+// LoadAnsible reads expected state from an Ansible facts file: the JSON output
+// of the setup module (`ansible -m setup --tree`), a jsonfile fact cache entry,
+// or host variables from `ansible-inventory --host`. It reads the facts set by
+// the package_facts, service_facts, and getent (database=passwd) modules, and
+// plain `users`, `packages`, and `services` lists of names or of objects with a
+// name (and, for packages, a version).
+func LoadAnsible(path string) (Expected, error) {
+	var doc map[string]any
+	if err := readJSON(path, &doc); err != nil {
+		return Expected{}, err
+	}
+	if facts, ok := doc["ansible_facts"].(map[string]any); ok {
+		doc = facts
+	}
+	fact := func(name string) any {
+		if v, ok := doc[name]; ok {
+			return v
+		}
+		return doc["ansible_"+name]
+	}
+
+	exp := Expected{Source: "ansible", Kinds: map[string]bool{}}
+	if passwd, ok := fact("getent_passwd").(map[string]any); ok {
+		exp.Kinds[KindUser] = true
+		for name, fields := range passwd {
+			uid := -1
+			// getent passwd fields after the name: password, uid, gid, ...
+			if f, ok := fields.([]any); ok && len(f) > 1 {
+				fmt.Sscan(fmt.Sprint(f[1]), &uid)
+			}
+			exp.add(KindUser, name, "", uid)
+		}
+	}
+	switch pkgs := fact("packages").(type) {
+	case map[string]any:
+		exp.Kinds[KindPackage] = true
+		for name, versions := range pkgs {
+			list, _ := versions.([]any)
+			if len(list) == 0 {
+				exp.add(KindPackage, name, "", -1)
+			}
+			for _, v := range list {
+				m, _ := v.(map[string]any)
+				exp.add(KindPackage, name, ansiblePackageVersion(m), -1)
+			}
+		}
+	case []any:
+		exp.Kinds[KindPackage] = true
+		addList(&exp, KindPackage, pkgs)
+	}
+	switch svcs := fact("services").(type) {
+	case map[string]any:
+		exp.Kinds[KindService] = true
+		for name, v := range svcs {
+			m, _ := v.(map[string]any)
+			if status, _ := m["status"].(string); status == "enabled" || status == "auto" {
+				exp.add(KindService, name, "", -1)
+			}
+		}
+	case []any:
+		exp.Kinds[KindService] = true
+		addList(&exp, KindService, svcs)
+	}
+	if users, ok := fact("users").([]any); ok {
+		exp.Kinds[KindUser] = true
+		addList(&exp, KindUser, users)
+	}
+	if len(exp.Kinds) == 0 {
+		return Expected{}, fmt.Errorf("%s: no users, packages, or services facts (gather them with the getent, package_facts, and service_facts modules)", path)
+	}
+	return exp, nil
+}
+
+// ansiblePackageVersion formats a package_facts entry the way the snapshot
+// records it: rpm packages as [epoch:]version-release.
+func ansiblePackageVersion(m map[string]any) string {
+	version, _ := m["version"].(string)
+	if release, _ := m["release"].(string); release != "" {
+		version += "-" + release
+	}
+	if epoch, ok := m["epoch"]; ok && epoch != nil && version != "" {
+		version = fmt.Sprint(epoch) + ":" + version
+	}
+	return version
+}
+
+func addList(exp *Expected, kind string, list []any) {
+	for _, v := range list {
+		switch x := v.(type) {
+		case string:
+			exp.add(kind, x, "", -1)
+		case map[string]any:
+			name, _ := x["name"].(string)
+			version, _ := x["version"].(string)
+			exp.add(kind, name, version, uidOf(x))
+		}
+	}
+}
+
+// terraformKinds maps resource type suffixes to kinds. Host-level resources
+// come from community providers with varying names (linux_user, ssh_user,
+// system_package, system_systemd_unit, ...), so they are matched by suffix.
+var terraformKinds = []struct{ suffix, kind string }{
+	{"_user", KindUser},
+	{"_package", KindPackage},
+	{"_packages", KindPackage},
+	{"_service", KindService},
+	{"_systemd_unit", KindService},
+}
+
+// LoadTerraform reads expected state from a Terraform state file (format
+// version 4, as written by `terraform state pull`). Managed resources whose
+// type ends in _user, _package(s), _service, or _systemd_unit are imported by
+// their name (or username, or unit) attribute; packages keep their version,
+// and services with enabled = false are skipped.
+func LoadTerraform(path string) (Expected, error) {
+	var state struct {
+		Version   int `json:"version"`
+		Resources []struct {
+			Mode      string `json:"mode"`
+			Type      string `json:"type"`
+			Instances []struct {
+				Attributes map[string]any `json:"attributes"`
+			} `json:"instances"`
+		} `json:"resources"`
+	}
+	if err := readJSON(path, &state); err != nil {
+		return Expected{}, err
+	}
+	if state.Version != 4 {
+		return Expected{}, fmt.Errorf("%s: unsupported Terraform state version %d (want 4)", path, state.Version)
+	}
+	exp := Expected{Source: "terraform", Kinds: map[string]bool{}}
+	for _, r := range state.Resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		kind := ""
+		for _, k := range terraformKinds {
+			if strings.HasSuffix(r.Type, k.suffix) {
+				kind = k.kind
+				break
+			}
+		}
+		if kind == "" {
+			continue
+		}
+		for _, inst := range r.Instances {
+			a := inst.Attributes
+			if kind == KindService && a["enabled"] == false {
+				continue
+			}
+			var name string
+			for _, field := range []string{"name", "username", "unit"} {
+				if name, _ = a[field].(string); name != "" {
+					break
+				}
+			}
+			if names, ok := a["names"].([]any); ok && kind == KindPackage {
+				addList(&exp, kind, names)
+				continue
+			}
+			version, _ := a["version"].(string)
+			exp.add(kind, name, version, uidOf(a))
+		}
+	}
+	if len(exp.Kinds) == 0 {
+		return Expected{}, fmt.Errorf("%s: no user, package, or service resources", path)
+	}
+	return exp, nil
+}
+
+func uidOf(m map[string]any) int {
+	if uid, ok := m["uid"].(float64); ok {
+		return int(uid)
+	}
+	return -1
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// listedUID reports whether the snapshot's local_users lists accounts with
+// uid: root and human accounts (UID 1000-59999) on Linux and macOS.
+func listedUID(uid int) bool {
+	return uid < 0 || uid == 0 || (uid >= 1000 && uid < 60000)
+}
+
+// Compare checks exp against snapshot rows.
+func Compare(exp Expected, rows []diff.Row) Result {
+	byType := diff.GroupByType(rows)
+	var res Result
+	for _, kind := range Kinds {
+		if !exp.Kinds[kind] {
+			continue
+		}
+		row, ok := byType[kindRows[kind]]
+		if !ok {
+			res.Skipped = append(res.Skipped, kind)
+			continue
+		}
+		res.Compared = append(res.Compared, kind)
+		items, _ := row["items"].([]any)
+		partial := false
+		if count, ok := row["count"].(float64); ok && int(count) > len(items) {
+			partial = true
+			res.Partial = append(res.Partial, kind)
+		}
+
+		actual := map[string][]string{}
+		for _, it := range items {
+			m, _ := it.(map[string]any)
+			// MDM inventories cover /Applications, not apps users keep in
+			// ~/Applications.
+			if kind == KindApp && m["scope"] == "user" {
+				continue
+			}
+			name := observedName(kind, m)
+			if name == "" {
+				continue
+			}
+			version, _ := m["version"].(string)
+			actual[name] = append(actual[name], version)
+		}
+		expected := map[string][]string{}
+		for _, r := range exp.Resources {
+			if r.Kind != kind || (kind == KindUser && !listedUID(r.UID)) {
+				continue
+			}
+			name := normalizeName(kind, r.Name)
+			expected[name] = append(expected[name], r.Version)
+		}
+
+		for name, versions := range expected {
+			found, ok := actual[name]
+			if !ok {
+				if !partial {
+					res.Findings = append(res.Findings, Finding{Kind: kind, Name: name, Status: StatusMissing})
+				}
+				continue
+			}
+			for _, v := range versions {
+				if v != "" && !slices.Contains(found, v) {
+					res.Findings = append(res.Findings, Finding{Kind: kind, Name: name, Status: StatusVersion, Expected: v, Actual: strings.Join(found, ", ")})
+				}
+			}
+		}
+		for name := range actual {
+			if _, ok := expected[name]; !ok {
+				res.Findings = append(res.Findings, Finding{Kind: kind, Name: name, Status: StatusUnmanaged})
+			}
+		}
+	}
+	sort.Slice(res.Findings, func(i, j int) bool {
+		a, b := res.Findings[i], res.Findings[j]
+		if a.Kind != b.Kind {
+			return kindIndex(a.Kind) < kindIndex(b.Kind)
+		}
+		if a.Status != b.Status {
+			return a.Status < b.Status
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Expected < b.Expected
+	})
+	return res
+}
+
+func observedName(kind string, item map[string]any) string {
+	field := map[string]string{KindUser: "username", KindPackage: "name", KindService: "unit", KindApp: "name"}[kind]
+	name, _ := item[field].(string)
+	return normalizeName(kind, name)
+}
+
+// normalizeName makes names from both sides comparable: systemd units are
+// compared without their .service suffix, which Ansible keeps and Terraform
+// providers usually drop, and apps without .app.
+func normalizeName(kind, name string) string {
+	switch kind {
+	case KindService:
+		return strings.TrimSuffix(name, ".service")
+	case KindApp:
+		return appName(name)
+	}
+	return name
+}
+
+func kindIndex(kind string) int {
+	for i, k := range append([]string{KindSetting}, Kinds...) {
+		if k == kind {
+			return i
+		}
+	}
+	return len(Kinds)
+}
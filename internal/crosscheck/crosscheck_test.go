@@ -0,0 +1,156 @@
+package crosscheck
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func snapshotRows() []diff.Row {
+	return []diff.Row{
+		{"type": "local_users", "count": 2.0, "items": []any{
+			map[string]any{"username": "root", "uid": 0.0},
+			map[string]any{"username": "alice", "uid": 1000.0},
+		}},
+		{"type": "system_packages", "count": 3.0, "items": []any{
+			map[string]any{"manager": "dpkg", "name": "openssl", "version": "3.0.2-0ubuntu1.12"},
+			map[string]any{"manager": "dpkg", "name": "curl", "version": "7.81.0-1"},
+			map[string]any{"manager": "rpm", "name": "bash", "version": "5.1.8-6.el9"},
+		}},
+		{"type": "enabled_services", "count": 2.0, "items": []any{
+			map[string]any{"unit": "ssh.service", "state": "enabled"},
+			map[string]any{"unit": "cron.service", "state": "enabled"},
+		}},
+	}
+}
+
+func statuses(res Result) []string {
+	var out []string
+	for _, f := range res.Findings {
+		out = append(out, f.Status+" "+f.Kind+" "+f.Name)
+	}
+	return out
+}
+
+func TestCompare_Ansible(t *testing.T) {
+	path := writeFile(t, "state.json", `{"ansible_facts": {
+		"getent_passwd": {"root": ["x", "0", "0"], "daemon": ["x", "1", "1"], "deploy": ["x", "1001", "1001"]},
+		"packages": {
+			"openssl": [{"name": "openssl", "version": "3.0.2-0ubuntu1.10", "source": "apt"}],
+			"curl": [{"name": "curl", "version": "7.81.0-1", "source": "apt"}],
+			"bash": [{"name": "bash", "version": "5.1.8", "release": "6.el9", "epoch": null, "source": "rpm"}]
+		},
+		"services": {
+			"ssh.service": {"name": "ssh.service", "state": "running", "status": "enabled"},
+			"cups.service": {"name": "cups.service", "state": "stopped", "status": "disabled"}
+		}
+	}}`)
+	exp, err := LoadAnsible(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := Compare(exp, snapshotRows())
+	// daemon (UID 1) is a system account, which the snapshot does not list.
+	want := []string{
+		"missing user deploy", "unmanaged user alice",
+		"version package openssl",
+		"unmanaged service cron",
+	}
+	if got := statuses(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+	if f := res.Findings[2]; f.Expected != "3.0.2-0ubuntu1.10" || f.Actual != "3.0.2-0ubuntu1.12" {
+		t.Errorf("version finding = %+v", f)
+	}
+}
+
+func TestCompare_Terraform(t *testing.T) {
+	path := writeFile(t, "state.json", `{"version": 4, "resources": [
+		{"mode": "managed", "type": "linux_user", "name": "deploy", "instances": [{"attributes": {"name": "deploy", "uid": 1001}}]},
+		{"mode": "managed", "type": "system_systemd_unit", "name": "ssh", "instances": [{"attributes": {"name": "ssh", "enabled": true}}]},
+		{"mode": "managed", "type": "system_systemd_unit", "name": "cups", "instances": [{"attributes": {"name": "cups", "enabled": false}}]},
+		{"mode": "data", "type": "linux_user", "name": "root", "instances": [{"attributes": {"name": "root"}}]},
+		{"mode": "managed", "type": "aws_instance", "name": "web", "instances": [{"attributes": {"name": "web"}}]}
+	]}`)
+	exp, err := LoadTerraform(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := snapshotRows()
+	// Only one of two enabled services is listed, so missing ones cannot be told apart.
+	rows[2]["count"] = 40.0
+	res := Compare(exp, rows)
+	want := []string{
+		"missing user deploy", "unmanaged user alice", "unmanaged user root",
+		"unmanaged service cron",
+	}
+	if got := statuses(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(res.Compared, []string{KindUser, KindService}) || !reflect.DeepEqual(res.Partial, []string{KindService}) {
+		t.Errorf("compared = %v, partial = %v", res.Compared, res.Partial)
+	}
+
+	if _, err := LoadTerraform(writeFile(t, "state.json", `{"version": 3, "resources": []}`)); err == nil {
+		t.Error("LoadTerraform accepted a version 3 state")
+	}
+}
+
+func TestCompareMDM(t *testing.T) {
+	path := writeFile(t, "jamf.json", `{"results": [
+		{"general": {"name": "mbp-alice", "reportDate": "2026-09-01T10:00:00Z"}, "hardware": {"serialNumber": "C02XYZ"},
+		 "operatingSystem": {"version": "14.5"},
+		 "diskEncryption": {"bootPartitionEncryptionDetails": {"partitionFileVault2State": "ENCRYPTED"}},
+		 "applications": [{"name": "Safari.app", "version": "17.5"}, {"name": "Slack.app", "version": "4.38.0"}]},
+		{"general": {"name": "mbp-bob"}, "hardware": {"serialNumber": "C02ABC"}}
+	]}`)
+	if _, _, err := LoadMDM(path, "jamf", "", ""); err == nil {
+		t.Error("LoadMDM picked a device from a multi-device export without a selector")
+	}
+	exp, dev, err := LoadMDM(path, "jamf", "", "MBP-Alice.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []diff.Row{
+		{"type": "meta", "hostname": "mbp-alice.local", "os_version": "14.5.1", "timestamp": "2026-10-01T10:00:00Z"},
+		{"type": "security_config", "filevault": false, "mdm_enrolled": false},
+		{"type": "applications", "count": 3.0, "items": []any{
+			map[string]any{"name": "Safari", "version": "17.5"},
+			map[string]any{"name": "Zoom", "version": "6.0"},
+			map[string]any{"name": "Scratch", "version": "1.0", "scope": "user"},
+		}},
+	}
+	res := CompareMDM(exp, dev, rows, 7*24*time.Hour)
+	// 14.5.1 is a 14.5 update, so the OS version matches.
+	want := []string{"mismatch setting encryption", "mismatch setting mdm_enrollment", "stale setting last_inventory", "missing app Slack", "unmanaged app Zoom"}
+	if got := statuses(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+
+	csvPath := writeFile(t, "intune.csv", "\ufeffDevice name,Serial number,OS version,Encrypted,Last check-in\nWS01,ABC,10.0.22631.2861,False,2026-10-01 09:00:00\n")
+	exp, dev, err = LoadMDM(csvPath, "intune", "abc", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows = []diff.Row{
+		{"type": "meta", "os_version": "Windows 11 Pro 23H2", "kernel": "Windows NT build 22631", "timestamp": "2026-10-01T10:00:00Z"},
+		{"type": "security_config", "bitlocker": true},
+	}
+	res = CompareMDM(exp, dev, rows, 7*24*time.Hour)
+	if got := statuses(res); !reflect.DeepEqual(got, []string{"mismatch setting encryption"}) {
+		t.Errorf("intune findings = %q", got)
+	}
+}
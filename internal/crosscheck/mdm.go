@@ -0,0 +1,343 @@
+package crosscheck
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+// Finding statuses for settings.
+const (
+	StatusMismatch = "mismatch"
+	StatusStale    = "stale"
+)
+
+// Device is one device record from an MDM inventory export.
+type Device struct {
+	Name      string
+	Serial    string
+	OSVersion string
+	// Encrypted is nil when the export does not say.
+	Encrypted *bool
+	// LastInventory is when the device last reported inventory; zero when
+	// the export does not say.
+	LastInventory time.Time
+	// Apps is nil when the export has no application list.
+	Apps []Resource
+}
+
+// mdmFields lists, per device field, the export columns or JSON paths that
+// hold it, normalized to lowercase letters and digits. Jamf Pro exports come
+// from the computers-inventory API or an advanced computer search; Intune
+// exports from Graph managedDevices (with detectedApps expanded) or the
+// devices CSV.
+var mdmFields = map[string][]string{
+	"name":      {"generalname", "computername", "devicename", "name"},
+	"serial":    {"hardwareserialnumber", "serialnumber"},
+	"os":        {"operatingsystemversion", "osversion"},
+	"encrypted": {"diskencryptionbootpartitionencryptiondetailspartitionfilevault2state", "filevault2status", "filevault2bootpartitionstatus", "filevault2partitionencryptionstate", "isencrypted", "encrypted"},
+	"inventory": {"generalreportdate", "generallastinventoryupdate", "lastinventoryupdate", "lastsyncdatetime", "lastcheckin"},
+}
+
+// LoadMDM reads a Jamf Pro or Intune device inventory export (JSON or CSV)
+// and returns the state the MDM reports for one device, as Expected with
+// source as its Source. device selects the record by name or serial number
+// (case-insensitive, ignoring a .local suffix). Without it, the export's only
+// device is used, or else the one named hostname.
+func LoadMDM(path, source, device, hostname string) (Expected, Device, error) {
+	devices, err := readDevices(path)
+	if err != nil {
+		return Expected{}, Device{}, err
+	}
+	if device == "" && len(devices) > 1 {
+		device = hostname
+	}
+	d, err := selectDevice(devices, device)
+	if err != nil {
+		return Expected{}, Device{}, fmt.Errorf("%s: %w", path, err)
+	}
+	exp := Expected{Source: source, Kinds: map[string]bool{KindSetting: true}}
+	if d.Apps != nil {
+		exp.Kinds[KindApp] = true
+		exp.Resources = d.Apps
+	}
+	return exp, d, nil
+}
+
+func readDevices(path string) ([]Device, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return csvDevices(path, data)
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// Jamf wraps records in "results", Graph in "value"; a bare record or
+	// array is accepted too.
+	var records []any
+	switch x := doc.(type) {
+	case []any:
+		records = x
+	case map[string]any:
+		if list, ok := x["results"].([]any); ok {
+			records = list
+		} else if list, ok := x["value"].([]any); ok {
+			records = list
+		} else {
+			records = []any{x}
+		}
+	}
+	var devices []Device
+	for _, r := range records {
+		m, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		flat := map[string]string{}
+		flatten(m, "", flat)
+		d := deviceFrom(flat)
+		if apps, ok := firstList(m, "applications", "detectedApps"); ok {
+			d.Apps = []Resource{}
+			for _, a := range apps {
+				am, _ := a.(map[string]any)
+				name, _ := am["name"].(string)
+				if name == "" {
+					name, _ = am["displayName"].(string)
+				}
+				version, _ := am["version"].(string)
+				if name != "" {
+					d.Apps = append(d.Apps, Resource{Kind: KindApp, Name: appName(name), Version: version, UID: -1})
+				}
+			}
+		}
+		devices = append(devices, d)
+	}
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("%s: no device records", path)
+	}
+	return devices, nil
+}
+
+func csvDevices(path string, data []byte) ([]Device, error) {
+	r := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), "\ufeff")))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%s: no device records", path)
+	}
+	header := make([]string, len(records[0]))
+	for i, h := range records[0] {
+		header[i] = normalizeKey(h)
+	}
+	var devices []Device
+	for _, rec := range records[1:] {
+		flat := map[string]string{}
+		for i, v := range rec {
+			if i < len(header) {
+				flat[header[i]] = v
+			}
+		}
+		devices = append(devices, deviceFrom(flat))
+	}
+	return devices, nil
+}
+
+// flatten maps every scalar under m to its path, normalized, e.g.
+// general.name to "generalname". Lists are skipped.
+func flatten(m map[string]any, prefix string, out map[string]string) {
+	for k, v := range m {
+		key := prefix + normalizeKey(k)
+		switch x := v.(type) {
+		case map[string]any:
+			flatten(x, key, out)
+		case []any, nil:
+		default:
+			out[key] = fmt.Sprint(x)
+		}
+	}
+}
+
+func normalizeKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+func firstList(m map[string]any, keys ...string) ([]any, bool) {
+	for _, k := range keys {
+		if list, ok := m[k].([]any); ok {
+			return list, true
+		}
+	}
+	return nil, false
+}
+
+func deviceFrom(flat map[string]string) Device {
+	field := func(name string) string {
+		for _, k := range mdmFields[name] {
+			if v := strings.TrimSpace(flat[k]); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	d := Device{Name: field("name"), Serial: field("serial"), OSVersion: field("os")}
+	if enc, ok := parseEncrypted(field("encrypted")); ok {
+		d.Encrypted = &enc
+	}
+	d.LastInventory = parseTime(field("inventory"))
+	return d
+}
+
+// parseEncrypted reads an encryption state: a boolean, or a status such as
+// Jamf's "ENCRYPTED", "All Partitions Encrypted", or "Not Encrypted".
+func parseEncrypted(s string) (bool, bool) {
+	v := strings.ToLower(s)
+	switch {
+	case v == "":
+		return false, false
+	case v == "true" || v == "yes":
+		return true, true
+	case v == "false" || v == "no" || strings.Contains(v, "not") || strings.Contains(v, "unencrypted") || strings.HasPrefix(v, "no "):
+		return false, true
+	case strings.Contains(v, "encrypted"):
+		return true, true
+	}
+	return false, false
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"1/2/2006 3:04:05 PM",
+	"1/2/2006 15:04",
+	"2006-01-02",
+}
+
+func parseTime(s string) time.Time {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func selectDevice(devices []Device, want string) (Device, error) {
+	if len(devices) == 1 && want == "" {
+		return devices[0], nil
+	}
+	if want == "" {
+		return Device{}, fmt.Errorf("%d devices in export; select one by name or serial number", len(devices))
+	}
+	host := func(s string) string { return strings.TrimSuffix(strings.ToLower(s), ".local") }
+	var found []Device
+	for _, d := range devices {
+		if host(d.Name) == host(want) || (d.Serial != "" && strings.EqualFold(d.Serial, want)) {
+			found = append(found, d)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Device{}, fmt.Errorf("no device named %q or with that serial number", want)
+	case 1:
+		return found[0], nil
+	}
+	return Device{}, fmt.Errorf("%d devices match %q; select one by serial number", len(found), want)
+}
+
+// appName normalizes an application name for matching: Jamf lists bundles
+// with their .app suffix, osaudit without.
+func appName(name string) string {
+	return strings.TrimSuffix(name, ".app")
+}
+
+// CompareMDM checks the state an MDM reports for a device against snapshot
+// rows: disk encryption against security_config (FileVault, BitLocker, or
+// LUKS), the MDM enrollment a macOS snapshot reports (the export lists the
+// device, so it should be enrolled), the OS version against the meta row, how old the MDM's inventory was
+// when the snapshot was taken (stale when older than maxAge; 0 disables the
+// check), and its application list against the applications row.
+func CompareMDM(exp Expected, d Device, rows []diff.Row, maxAge time.Duration) Result {
+	res := Compare(exp, rows)
+	var meta, sec diff.Row
+	for _, r := range rows {
+		switch r["type"] {
+		case "meta":
+			if meta == nil {
+				meta = r
+			}
+		case "security_config":
+			sec = r
+		}
+	}
+	var settings []Finding
+	if d.Encrypted != nil && sec != nil {
+		for _, field := range []string{"filevault", "bitlocker", "luks_encrypted"} {
+			actual, ok := sec[field].(bool)
+			if !ok {
+				continue
+			}
+			if actual != *d.Encrypted {
+				settings = append(settings, Finding{Kind: KindSetting, Name: "encryption", Status: StatusMismatch,
+					Expected: fmt.Sprint(*d.Encrypted), Actual: fmt.Sprintf("%s=%t", field, actual)})
+			}
+			break
+		}
+	}
+	if enrolled, ok := sec["mdm_enrolled"].(bool); ok && !enrolled {
+		settings = append(settings, Finding{Kind: KindSetting, Name: "mdm_enrollment", Status: StatusMismatch,
+			Expected: "true", Actual: "mdm_enrolled=false"})
+	}
+	if d.OSVersion != "" && meta != nil {
+		osVersion, _ := meta["os_version"].(string)
+		kernel, _ := meta["kernel"].(string)
+		if osVersion != "" && !sameOSVersion(d.OSVersion, osVersion, kernel) {
+			settings = append(settings, Finding{Kind: KindSetting, Name: "os_version", Status: StatusMismatch, Expected: d.OSVersion, Actual: osVersion})
+		}
+	}
+	if maxAge > 0 && !d.LastInventory.IsZero() && meta != nil {
+		taken := parseTime(fmt.Sprint(meta["timestamp"]))
+		if !taken.IsZero() && taken.Sub(d.LastInventory) > maxAge {
+			settings = append(settings, Finding{Kind: KindSetting, Name: "last_inventory", Status: StatusStale,
+				Expected: "within " + maxAge.String(), Actual: d.LastInventory.Format(time.RFC3339)})
+		}
+	}
+	res.Compared = append([]string{KindSetting}, res.Compared...)
+	res.Findings = append(settings, res.Findings...)
+	return res
+}
+
+// sameOSVersion reports whether an MDM OS version matches the snapshot's:
+// equal, or one a dotted prefix of the other (14.2 and 14.2.1). Windows
+// versions such as 10.0.22631.2861 are matched against the build number in
+// the kernel string.
+func sameOSVersion(mdm, osVersion, kernel string) bool {
+	if mdm == osVersion || strings.HasPrefix(mdm, osVersion+".") || strings.HasPrefix(osVersion, mdm+".") {
+		return true
+	}
+	parts := strings.Split(mdm, ".")
+	if len(parts) >= 3 && strings.Contains(kernel, "build ") {
+		return strings.Contains(kernel, "build "+parts[2])
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+// Package deprecation tracks the fields of osaudit's manifest and config files
+// that are still read but will be removed. Loaders report the deprecated fields
+// a file uses as machine-readable warnings, so a format can rename or drop a
+// field without silently breaking files written for an older osaudit.
+// `osaudit manifest lint --strict` treats them as errors.
+package deprecation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Formats osaudit reads, as used in Field.Format.
+const (
+	Manifest       = "manifest"       // cli/commands.json
+	Collectors     = "collectors"     // ~/.osaudit/collectors.yaml
+	Classification = "classification" // ~/.osaudit/classify.yaml
+	Settings       = "settings"       // ~/.osaudit/config.yaml
+)
+
+// Field is a deprecated field of one format.
+type Field struct {
+	Format string `json:"format"`
+	// Field is the field's key path. "[]" after a key matches every element
+	// of that list, e.g. "commands[].exec".
+	Field string `json:"field"`
+	// Replacement says what to use instead; empty when the field is dropped
+	// without one.
+	Replacement string `json:"replacement,omitempty"`
+	// RemovedIn is the first release that rejects the field.
+	RemovedIn string `json:"removed_in"`
+}
+
+// Fields lists the deprecated fields of every format. Add an entry when a
+// field is renamed or dropped, and keep reading the old field until RemovedIn.
+var Fields []Field
+
+// Warning is one use of a deprecated field.
+type Warning struct {
+	Type string `json:"type"` // always "deprecation"
+	File string `json:"file"`
+	Field
+	// Path is where the field was found, e.g. "commands[2].exec".
+	Path string `json:"path"`
+}
+
+func (w Warning) String() string {
+	msg := fmt.Sprintf("%s: %s is deprecated and will be removed in %s", w.File, w.Path, w.RemovedIn)
+	if w.Replacement != "" {
+		msg += "; use " + w.Replacement
+	}
+	return msg
+}
+
+// Find returns the deprecated fields of format that doc uses. doc is the file
+// decoded into map[string]any and []any, by encoding/json or yamlite.Parse.
+func Find(format, file string, doc any) []Warning {
+	var out []Warning
+	for _, f := range Fields {
+		if f.Format != format {
+			continue
+		}
+		for _, path := range match(doc, strings.Split(f.Field, "."), "") {
+			out = append(out, Warning{Type: "deprecation", File: file, Field: f, Path: path})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// match returns the paths in doc that the key path segs matches, prefixed by
+// at.
+func match(doc any, segs []string, at string) []string {
+	if len(segs) == 0 {
+		return []string{at}
+	}
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return nil
+	}
+	key, list := strings.CutSuffix(segs[0], "[]")
+	v, ok := m[key]
+	if !ok {
+		return nil
+	}
+	if at != "" {
+		key = at + "." + key
+	}
+	if !list {
+		return match(v, segs[1:], key)
+	}
+	items, _ := v.([]any)
+	var out []string
+	for i, item := range items {
+		out = append(out, match(item, segs[1:], fmt.Sprintf("%s[%d]", key, i))...)
+	}
+	return out
+}
+
+// Strict makes Check fail on deprecated fields instead of warning.
+var Strict bool
+
+// Output is where Check writes warnings: one JSON object per line.
+var Output io.Writer = os.Stderr
+
+// Check reports the deprecated fields of format that doc uses. It writes them
+// to Output and returns nil, or, when Strict is set, returns them as an error.
+func Check(format, file string, doc any) error {
+	warnings := Find(format, file, doc)
+	if len(warnings) == 0 {
+		return nil
+	}
+	if Strict {
+		msgs := make([]string, len(warnings))
+		for i, w := range warnings {
+			msgs[i] = w.String()
+		}
+		return fmt.Errorf("deprecated fields (strict): %s", strings.Join(msgs, "; "))
+	}
+	enc := json.NewEncoder(Output)
+	for _, w := range warnings {
+		enc.Encode(w)
+	}
+	return nil
+}
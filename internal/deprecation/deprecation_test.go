@@ -0,0 +1,66 @@
+package deprecation
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func withFields(t *testing.T, fields ...Field) {
+	t.Helper()
+	saved, savedStrict, savedOutput := Fields, Strict, Output
+	Fields = fields
+	t.Cleanup(func() { Fields, Strict, Output = saved, savedStrict, savedOutput })
+}
+
+func TestFind(t *testing.T) {
+	withFields(t,
+		Field{Format: Manifest, Field: "commands[].exec", Replacement: "os_exec", RemovedIn: "1.0"},
+		Field{Format: Collectors, Field: "skip", Replacement: "disable", RemovedIn: "1.0"},
+	)
+	var doc any
+	json.Unmarshal([]byte(`{"commands":[{"id":"a"},{"id":"b","exec":["x.sh"]},{"id":"c","exec":["y.sh"]}],"skip":["network"]}`), &doc)
+
+	var got []string
+	for _, w := range Find(Manifest, "commands.json", doc) {
+		got = append(got, w.Path)
+	}
+	if want := []string{"commands[1].exec", "commands[2].exec"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Find(manifest) paths = %v, want %v", got, want)
+	}
+	if ws := Find(Classification, "classify.yaml", doc); len(ws) != 0 {
+		t.Errorf("Find(classification) = %v, want none", ws)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	withFields(t, Field{Format: Collectors, Field: "skip", Replacement: "disable", RemovedIn: "1.0"})
+	doc := map[string]any{"skip": []any{"network"}}
+
+	var buf bytes.Buffer
+	Output = &buf
+	if err := Check(Collectors, "collectors.yaml", doc); err != nil {
+		t.Fatalf("Check = %v, want nil", err)
+	}
+	var w Warning
+	if err := json.Unmarshal(buf.Bytes(), &w); err != nil {
+		t.Fatalf("warning is not JSON: %q", buf.String())
+	}
+	want := Warning{Type: "deprecation", File: "collectors.yaml", Path: "skip",
+		Field: Field{Format: Collectors, Field: "skip", Replacement: "disable", RemovedIn: "1.0"}}
+	if w != want {
+		t.Errorf("warning = %+v, want %+v", w, want)
+	}
+
+	Strict = true
+	buf.Reset()
+	err := Check(Collectors, "collectors.yaml", doc)
+	if err == nil || !strings.Contains(err.Error(), "skip is deprecated") {
+		t.Errorf("strict Check = %v, want deprecation error", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("strict Check wrote %q", buf.String())
+	}
+}
@@ -0,0 +1,320 @@
+package diff
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+)
+
+// minInstallGroupSize is the smallest cluster reported as one attributed install.
+const minInstallGroupSize = 2
+
+// installWindow is how close together in time items must have been written to be
+// clustered as one install when no vendor identity ties them together.
+const installWindow = 5 * time.Minute
+
+// Item fields carrying a file's modification time, and the layouts they come in:
+// RFC 3339 from hash, and find/stat's ISO 8601 with a numeric zone from the
+// collectors.
+var (
+	installTimeFields  = []string{"mtime", "modified"}
+	installTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05-0700"}
+)
+
+// Item fields that carry an explicit signing/vendor identity. When present they win
+// over name heuristics.
+var vendorIdentityFields = []string{"team_id", "signing_team", "vendor"}
+
+// Item fields inspected (in order) for a vendor token.
+var vendorNameFields = []string{"label", "name", "unit", "module", "program", "path", "process", "file"}
+
+// Tokens too generic to identify a vendor.
+var vendorStopTokens = map[string]struct{}{
+	"app": {}, "agent": {}, "bin": {}, "com": {}, "daemon": {}, "helper": {}, "lib": {},
+	"local": {}, "net": {}, "opt": {}, "org": {}, "sbin": {}, "service": {}, "system": {},
+	"the": {}, "update": {}, "updater": {}, "user": {}, "usr": {}, "var": {},
+}
+
+var (
+	appBundlePattern  = regexp.MustCompile(`/([^/]+)\.app(/|$)`)
+	vendorDirPattern  = regexp.MustCompile(`^/(?:opt|Library/Application Support|usr/local/opt)/([^/]+)`)
+	versionPattern    = regexp.MustCompile(`\d+\.\d+(?:\.\d+)*`)
+	reverseDNSPattern = regexp.MustCompile(`^[a-z]{2,3}\.[A-Za-z0-9-]+\.`)
+	tokenSplitPattern = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// InstallGroup is a cluster of inventory changes attributed to one vendor/product.
+type InstallGroup struct {
+	Vendor  string
+	Label   string
+	Version string
+	Status  string // "installed" | "upgraded" | "removed"
+	Source  string // the package manager whose transaction it was, if known
+	Changes []InventoryChange
+}
+
+// Summary renders the group headline, e.g. "Installed: Zoom 5.17 (12 related changes)".
+func (g InstallGroup) Summary() string {
+	name := g.Label
+	if g.Version != "" {
+		name += " " + g.Version
+	}
+	if g.Source != "" {
+		return i18n.T("diff.install."+g.Status+"_by", g.Source, name, len(g.Changes))
+	}
+	if g.Status == "removed" {
+		return i18n.T("diff.install.removed", name, len(g.Changes))
+	}
+	return i18n.T("diff.install.installed", name, len(g.Changes))
+}
+
+// AttributeInstalls clusters added (and separately removed) inventory items by
+// common vendor identity. Added items no vendor accounts for are then clustered
+// by install time: one written within installWindow of an installed vendor
+// group's item joins that group, and the rest are chained into clusters of items
+// written within installWindow of each other. Clusters of at least
+// minInstallGroupSize become InstallGroups; everything else is returned
+// unchanged in rest.
+func AttributeInstalls(changes []InventoryChange) (groups []InstallGroup, rest []InventoryChange) {
+	type groupKey struct{ vendor, status string }
+	byKey := make(map[groupKey]*InstallGroup)
+	var order []groupKey
+	var unattributed []InventoryChange
+
+	for _, ch := range changes {
+		status := map[string]string{"added": "installed", "removed": "removed"}[ch.Status]
+		vendor, label := itemVendor(ch.Item)
+		if status == "" || vendor == "" {
+			unattributed = append(unattributed, ch)
+			continue
+		}
+		k := groupKey{vendor, status}
+		g, ok := byKey[k]
+		if !ok {
+			g = &InstallGroup{Vendor: vendor, Label: label, Status: status}
+			byKey[k] = g
+			order = append(order, k)
+		}
+		g.Changes = append(g.Changes, ch)
+	}
+
+	for _, k := range order {
+		g := byKey[k]
+		if len(g.Changes) < minInstallGroupSize {
+			unattributed = append(unattributed, g.Changes...)
+			continue
+		}
+		groups = append(groups, *g)
+	}
+	groups = append(groups, clusterByInstallTime(groups, unattributed)...)
+	for i := range groups {
+		groups[i].Version = groupVersion(groups[i].Changes)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].Changes) != len(groups[j].Changes) {
+			return len(groups[i].Changes) > len(groups[j].Changes)
+		}
+		return groups[i].Vendor < groups[j].Vendor
+	})
+
+	// Keep the original inventory order for everything not grouped.
+	grouped := make(map[string]struct{})
+	for _, g := range groups {
+		for _, ch := range g.Changes {
+			grouped[ch.RowType+"\x00"+ch.Key+"\x00"+ch.Status] = struct{}{}
+		}
+	}
+	for _, ch := range changes {
+		if _, ok := grouped[ch.RowType+"\x00"+ch.Key+"\x00"+ch.Status]; !ok {
+			rest = append(rest, ch)
+		}
+	}
+	return groups, rest
+}
+
+// clusterByInstallTime attributes the added, timestamped items of unattributed
+// by install time. An item written within installWindow of a timestamped item of
+// an installed group joins that group (the closest one); the rest are chained in
+// time order, and chains of at least minInstallGroupSize are returned as new
+// groups labeled with the time of their first item.
+func clusterByInstallTime(groups []InstallGroup, unattributed []InventoryChange) []InstallGroup {
+	type stamped struct {
+		ch InventoryChange
+		at time.Time
+	}
+	var loose []stamped
+	for _, ch := range unattributed {
+		if ch.Status != "added" {
+			continue
+		}
+		if at, ok := installTime(ch.Item); ok {
+			loose = append(loose, stamped{ch, at})
+		}
+	}
+	if len(loose) == 0 {
+		return nil
+	}
+
+	var chainable []stamped
+	for _, s := range loose {
+		best, bestGap := -1, installWindow+1
+		for i, g := range groups {
+			if g.Status != "installed" {
+				continue
+			}
+			for _, ch := range g.Changes {
+				at, ok := installTime(ch.Item)
+				if !ok {
+					continue
+				}
+				if gap := absDuration(s.at.Sub(at)); gap <= installWindow && gap < bestGap {
+					best, bestGap = i, gap
+				}
+			}
+		}
+		if best < 0 {
+			chainable = append(chainable, s)
+			continue
+		}
+		groups[best].Changes = append(groups[best].Changes, s.ch)
+	}
+
+	sort.SliceStable(chainable, func(i, j int) bool { return chainable[i].at.Before(chainable[j].at) })
+	var clusters []InstallGroup
+	for start := 0; start < len(chainable); {
+		end := start + 1
+		for end < len(chainable) && chainable[end].at.Sub(chainable[end-1].at) <= installWindow {
+			end++
+		}
+		if end-start >= minInstallGroupSize {
+			g := InstallGroup{
+				Label:  i18n.T("diff.install.at", chainable[start].at.UTC().Format("2006-01-02 15:04 MST")),
+				Status: "installed",
+			}
+			for _, s := range chainable[start:end] {
+				g.Changes = append(g.Changes, s.ch)
+			}
+			clusters = append(clusters, g)
+		}
+		start = end
+	}
+	return clusters
+}
+
+// installTime returns the modification time an item carries, if any.
+func installTime(item Row) (time.Time, bool) {
+	for _, f := range installTimeFields {
+		s, ok := item[f].(string)
+		if !ok || s == "" {
+			continue
+		}
+		for _, layout := range installTimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// itemVendor returns a normalized vendor token and a display label for an item.
+func itemVendor(item Row) (vendor, label string) {
+	for _, f := range vendorIdentityFields {
+		if s, ok := item[f].(string); ok && strings.TrimSpace(s) != "" {
+			return normalizeVendor(s), strings.TrimSpace(s)
+		}
+	}
+	for _, f := range vendorNameFields {
+		s, ok := item[f].(string)
+		if !ok || s == "" {
+			continue
+		}
+		if tok := vendorToken(s); tok != "" {
+			return normalizeVendor(tok), displayVendor(tok)
+		}
+	}
+	return "", ""
+}
+
+func vendorToken(s string) string {
+	if m := appBundlePattern.FindStringSubmatch(s); m != nil {
+		return firstVendorWord(m[1])
+	}
+	if m := vendorDirPattern.FindStringSubmatch(s); m != nil {
+		return firstVendorWord(m[1])
+	}
+	if reverseDNSPattern.MatchString(s) {
+		if tok := strings.Split(s, ".")[1]; usableVendorToken(tok) {
+			return tok
+		}
+	}
+	base := filepath.Base(s)
+	for _, ext := range []string{".service", ".desktop", ".plist", ".pkg", ".dmg", ".zip", ".ko", ".kext", ".app"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return firstVendorWord(base)
+}
+
+func firstVendorWord(s string) string {
+	for _, tok := range tokenSplitPattern.Split(s, -1) {
+		if usableVendorToken(tok) {
+			return tok
+		}
+	}
+	return ""
+}
+
+func usableVendorToken(tok string) bool {
+	if len(tok) < 3 {
+		return false
+	}
+	if strings.Trim(tok, "0123456789") == "" {
+		return false
+	}
+	_, stop := vendorStopTokens[strings.ToLower(tok)]
+	return !stop
+}
+
+func normalizeVendor(s string) string {
+	return strings.ToLower(tokenSplitPattern.ReplaceAllString(s, ""))
+}
+
+func displayVendor(tok string) string {
+	if tok == strings.ToLower(tok) {
+		return strings.ToUpper(tok[:1]) + tok[1:]
+	}
+	return tok
+}
+
+// groupVersion picks the most common version among the group's items, preferring
+// explicit version fields over versions parsed from names/paths.
+func groupVersion(changes []InventoryChange) string {
+	counts := make(map[string]int)
+	for _, ch := range changes {
+		if v, ok := ch.Item["version"].(string); ok && v != "" {
+			counts[v] += 2
+			continue
+		}
+		if v := versionPattern.FindString(ch.Key); v != "" {
+			counts[v]++
+		}
+	}
+	best, bestCount := "", 0
+	for v, c := range counts {
+		if c > bestCount || (c == bestCount && v < best) {
+			best, bestCount = v, c
+		}
+	}
+	return best
+}
@@ -0,0 +1,193 @@
+package diff
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAttributeInstalls_GroupsByVendor(t *testing.T) {
+	baselineRows := []Row{
+		{"type": "launch_daemons", "items": []any{
+			map[string]any{"label": "com.apple.existing", "program": "/usr/libexec/existing"},
+		}},
+		{"type": "listening_ports", "items": []any{}},
+	}
+	currentRows := []Row{
+		{"type": "launch_daemons", "items": []any{
+			map[string]any{"label": "com.apple.existing", "program": "/usr/libexec/existing"},
+			map[string]any{"label": "us.zoom.ZoomDaemon", "program": "/Library/PrivilegedHelperTools/us.zoom.ZoomDaemon"},
+			map[string]any{"label": "com.other.thing", "program": "/opt/other/bin/thing"},
+		}},
+		{"type": "listening_ports", "items": []any{
+			map[string]any{"process": "zoom.us", "pid": 4242.0, "port": 19421.0},
+		}},
+	}
+	baselineRows = append(baselineRows, Row{"type": "large_file", "path": "~/Downloads/old.iso", "bytes": 1.0})
+	currentRows = append(currentRows,
+		Row{"type": "large_file", "path": "~/Downloads/old.iso", "bytes": 1.0},
+		Row{"type": "large_file", "path": "~/Downloads/Zoom-5.17.0.pkg", "bytes": 2.0},
+	)
+
+	changes := BuildInventoryChanges(baselineRows, currentRows)
+	if len(changes) != 4 {
+		t.Fatalf("BuildInventoryChanges() = %d changes, want 4: %+v", len(changes), changes)
+	}
+
+	groups, rest := AttributeInstalls(changes)
+	if len(groups) != 1 {
+		t.Fatalf("AttributeInstalls() = %d groups, want 1: %+v", len(groups), groups)
+	}
+	g := groups[0]
+	if g.Vendor != "zoom" || g.Status != "installed" || len(g.Changes) != 3 {
+		t.Errorf("group = %+v, want zoom installed with 3 changes", g)
+	}
+	if got, want := g.Summary(), "Installed: Zoom 5.17.0 (3 related changes)"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+	if len(rest) != 1 || rest[0].Key != "com.other.thing" {
+		t.Errorf("rest = %+v, want only com.other.thing", rest)
+	}
+}
+
+func TestAttributeInstalls_ClustersByInstallTime(t *testing.T) {
+	hashed := func(path, mtime string) InventoryChange {
+		return InventoryChange{RowType: "file_hash", Key: path, Status: "added", Item: Row{"path": path, "mtime": mtime}}
+	}
+	changes := []InventoryChange{
+		{RowType: "launch_daemons", Key: "us.zoom.ZoomDaemon", Status: "added", Item: Row{"label": "us.zoom.ZoomDaemon"}},
+		{RowType: "listening_ports", Key: "zoom.us:19421", Status: "added", Item: Row{"process": "zoom.us"}},
+		hashed("/Applications/zoom.us.app/Contents/MacOS/zoom.us", "2026-03-01T10:00:00Z"),
+		// No vendor token, but written a minute after Zoom's binary.
+		hashed("/usr/local/bin/zcli", "2026-03-01T10:01:00Z"),
+		// Two unrelated files written together hours later.
+		{RowType: "recent_changes", Key: "/etc/cron.d/job", Status: "added", Item: Row{"path": "/etc/cron.d/job", "modified": "2026-03-01T14:00:00+0000"}},
+		{RowType: "recent_changes", Key: "/tmp/x", Status: "added", Item: Row{"path": "/tmp/x", "modified": "2026-03-01T14:03:00+0000"}},
+		// Alone in its window.
+		hashed("/etc/motd", "2026-03-02T09:00:00Z"),
+	}
+
+	groups, rest := AttributeInstalls(changes)
+	if len(groups) != 2 {
+		t.Fatalf("AttributeInstalls() = %d groups, want 2: %+v", len(groups), groups)
+	}
+	if g := groups[0]; g.Vendor != "zoom" || len(g.Changes) != 4 {
+		t.Errorf("groups[0] = %+v, want zoom with 4 changes", g)
+	}
+	if got, want := groups[1].Summary(), "Installed: files written at 2026-03-01 14:00 UTC (2 related changes)"; got != want {
+		t.Errorf("groups[1].Summary() = %q, want %q", got, want)
+	}
+	if len(rest) != 1 || rest[0].Key != "/etc/motd" {
+		t.Errorf("rest = %+v, want only /etc/motd", rest)
+	}
+}
+
+func TestBuildInventoryChanges_SkipsTypesMissingFromOneSide(t *testing.T) {
+	baselineRows := []Row{{"type": "kernel_modules", "items": []any{map[string]any{"module": "ext4"}}}}
+	currentRows := []Row{{"type": "meta"}}
+	if changes := BuildInventoryChanges(baselineRows, currentRows); len(changes) != 0 {
+		t.Errorf("BuildInventoryChanges() = %+v, want none when current lacks kernel_modules", changes)
+	}
+}
+
+func TestRun_InventoryChangedItem(t *testing.T) {
+	baselineRows := []Row{{"type": "kernel_extensions", "items": []any{map[string]any{"name": "com.vendor.driver", "version": "1.0"}}}}
+	currentRows := []Row{{"type": "kernel_extensions", "items": []any{map[string]any{"name": "com.vendor.driver", "version": "2.0"}}}}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	hasDeltas, _ := Run(baselineRows, currentRows, false, false)
+
+	w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	out := buf.String()
+
+	if !hasDeltas {
+		t.Fatal("Run with changed kext version must return true")
+	}
+	if !strings.Contains(out, "~ kernel_extensions: com.vendor.driver (version 1.0 → 2.0)") {
+		t.Errorf("expected changed kext line, got:\n%s", out)
+	}
+}
+
+func TestBuildInventoryChanges_PluginRowTypes(t *testing.T) {
+	pluginRow := Row{"type": "plugin", "plugin": "corp-vpn", "row_types": []any{
+		map[string]any{"type": "corp_vpn_profiles", "key": []any{"name"}, "compare": []any{"state"}, "items": true},
+		map[string]any{"type": "kernel_modules", "key": []any{"bogus"}},
+	}}
+	vpn := func(state string) Row {
+		return Row{"type": "corp_vpn_profiles", "items": []any{map[string]any{"name": "hq", "state": state}}}
+	}
+	modules := Row{"type": "kernel_modules", "items": []any{map[string]any{"module": "ext4"}}}
+	baselineRows := []Row{pluginRow, vpn("on"), modules}
+	currentRows := []Row{pluginRow, vpn("off"), modules}
+
+	changes := BuildInventoryChanges(baselineRows, currentRows)
+	if len(changes) != 1 {
+		t.Fatalf("BuildInventoryChanges() = %+v, want one plugin change", changes)
+	}
+	ch := changes[0]
+	if ch.RowType != "corp_vpn_profiles" || ch.Topic != "Plugins" || ch.Status != "changed" {
+		t.Errorf("change = %+v", ch)
+	}
+	if got := formatInventoryChange(ch); got != "  ~ corp_vpn_profiles: hq (state on → off)" {
+		t.Errorf("formatInventoryChange() = %q", got)
+	}
+}
+
+func TestRun_PackageTransactionAttribution(t *testing.T) {
+	baselineRows := []Row{
+		{"type": "enabled_services", "items": []any{map[string]any{"unit": "ssh.service", "state": "enabled"}}},
+		{"type": "listening_ports", "items": []any{}},
+	}
+	currentRows := []Row{
+		{"type": "enabled_services", "items": []any{
+			map[string]any{"unit": "ssh.service", "state": "enabled"},
+			map[string]any{"unit": "nginx.service", "state": "enabled"},
+			map[string]any{"unit": "other.service", "state": "enabled"},
+		}},
+		{"type": "listening_ports", "items": []any{
+			map[string]any{"process": "nginx", "pid": 812.0, "port": 80.0},
+		}},
+		{"type": "package_transaction", "run_id": "r2", "manager": "apt", "packages": []any{
+			map[string]any{"action": "install", "name": "nginx", "version": "1.24.0-2ubuntu7"},
+		}},
+	}
+
+	changes := BuildInventoryChanges(baselineRows, currentRows)
+	groups, rest := AttributeTransaction(currentRows[2], changes)
+	if len(groups) != 1 {
+		t.Fatalf("AttributeTransaction() = %d groups, want 1: %+v", len(groups), groups)
+	}
+	if got, want := groups[0].Summary(), "Installed by apt: nginx 1.24.0-2ubuntu7 (2 related changes)"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+	if len(rest) != 1 || rest[0].Key != "other.service" {
+		t.Errorf("rest = %+v, want only other.service", rest)
+	}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	hasDeltas, _ := Run(baselineRows, currentRows, false, false)
+	w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	out := buf.String()
+
+	if !hasDeltas {
+		t.Fatal("Run with new services must return true")
+	}
+	for _, want := range []string{"## Package transaction", "* install nginx 1.24.0-2ubuntu7", "Installed by apt: nginx"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -1,16 +1,30 @@
 package diff
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/deprecation"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
 )
 
 // Severity: prefix patterns first (data-driven), then exact overrides.
 // config.* = security-critical, identity.dscl_* / network.ifconfig_* = load-bearing, etc.
-var probeSeverityPrefix = []struct {
+type severityPrefix struct {
 	prefix string
 	sev    string
-}{
+}
+
+var probeSeverityPrefix = []severityPrefix{
 	{"config.", "high"},
 	{"network.defaults_", "high"},
 	{"network.socketfilterfw_", "high"},
@@ -31,20 +45,20 @@ var probeSeverityExact = map[string]string{
 // Probes that commonly fail with these codes in non-interactive contexts (permission, TCC, etc).
 // When all exit_codes match expected, severity can be downgraded for display.
 var probeExpectedExitCodes = map[string]map[int]struct{}{
-	"config.fdesetup_status":                 {15: {}, 1: {}},
-	"config.defaults_firewall_globalstate":   {1: {}},
-	"config.defaults_screen_lock_delay":     {1: {}},
-	"network.defaults_firewall_globalstate":  {1: {}},
-	"identity.dscl_list_users":               {70: {}, 1: {}},
-	"identity.dseditgroup_checkmember":       {1: {}, 67: {}},
-	"execution.crontab_l":                    {1: {}},
-	"execution.launchagents_defaults_label":  {1: {}},
-	"execution.launchagents_defaults_program": {1: {}},
-	"execution.launchagents_defaults_programarguments": {1: {}},
-	"persistence.defaults_loginwindow_loginhook":  {1: {}},
-	"persistence.defaults_loginwindow_logouthook": {1: {}},
-	"persistence.launchdaemons_defaults_label":  {1: {}},
-	"persistence.launchdaemons_defaults_program": {1: {}},
+	"config.fdesetup_status":                              {15: {}, 1: {}},
+	"config.defaults_firewall_globalstate":                {1: {}},
+	"config.defaults_screen_lock_delay":                   {1: {}},
+	"network.defaults_firewall_globalstate":               {1: {}},
+	"identity.dscl_list_users":                            {70: {}, 1: {}},
+	"identity.dseditgroup_checkmember":                    {1: {}, 67: {}},
+	"execution.crontab_l":                                 {1: {}},
+	"execution.launchagents_defaults_label":               {1: {}},
+	"execution.launchagents_defaults_program":             {1: {}},
+	"execution.launchagents_defaults_programarguments":    {1: {}},
+	"persistence.defaults_loginwindow_loginhook":          {1: {}},
+	"persistence.defaults_loginwindow_logouthook":         {1: {}},
+	"persistence.launchdaemons_defaults_label":            {1: {}},
+	"persistence.launchdaemons_defaults_program":          {1: {}},
 	"persistence.launchdaemons_defaults_programarguments": {1: {}},
 }
 
@@ -59,7 +73,7 @@ var probeTopic = map[string]string{
 }
 
 // TopicOrder defines display priority for grouping probe failures.
-var TopicOrder = []string{"Security", "Network", "Identity", "Storage", "Execution", "Persistence", "Other"}
+var TopicOrder = []string{"Security", "Network", "Identity", "Storage", "Integrity", "Execution", "Persistence", "Software", "Plugins", "Other"}
 
 // SeverityOrder maps severity to sort priority (lower = higher priority).
 var SeverityOrder = map[string]int{"high": 0, "medium": 1, "low": 2}
@@ -77,14 +91,25 @@ func ProbeSeverity(probe string) string {
 	return "low"
 }
 
-// ProbeTopic derives topic from probe prefix. Falls back to "Other" only if unclassifiable.
+// topicLabel returns the localized display name for a topic. Topics added via
+// classify.yaml without a translation are shown as written.
+func topicLabel(topic string) string {
+	if s, ok := i18n.Lookup("topic." + topic); ok {
+		return s
+	}
+	return topic
+}
+
+// ProbeTopic derives topic from probe prefix (longest match wins). Falls back to
+// "Other" only if unclassifiable.
 func ProbeTopic(probe string) string {
-	for prefix, topic := range probeTopic {
-		if strings.HasPrefix(probe, prefix) {
-			return topic
+	best, topic := "", "Other"
+	for prefix, t := range probeTopic {
+		if strings.HasPrefix(probe, prefix) && len(prefix) > len(best) {
+			best, topic = prefix, t
 		}
 	}
-	return "Other"
+	return topic
 }
 
 // ExpectedState returns "expected" | "mixed" | "unexpected".
@@ -129,10 +154,228 @@ func ExpectedSuffix(probe string, exitCodes map[string]any) string {
 	state := ExpectedState(probe, exitCodes)
 	switch state {
 	case "expected":
-		return " (expected)"
+		return i18n.T("diff.expected")
 	case "mixed":
-		return " (mixed)"
+		return i18n.T("diff.mixed")
 	default:
 		return ""
 	}
 }
+
+// Classification is the user-editable form of the maps above, loaded from
+// ~/.osaudit/classify.yaml:
+//
+//	severity:
+//	  prefixes:
+//	    - {prefix: "custom.", severity: high}
+//	  exact:
+//	    network.lsof_listen: low
+//	expected_exit_codes:
+//	  config.fdesetup_status: [1, 15]
+//	topics:
+//	  custom.: Custom
+//
+// User prefixes are checked before the built-in ones, exact severities and
+// topics override built-ins, and expected exit codes are added to the built-in set.
+type Classification struct {
+	Severity struct {
+		Prefixes []struct {
+			Prefix   string `json:"prefix"`
+			Severity string `json:"severity"`
+		} `json:"prefixes"`
+		Exact map[string]string `json:"exact"`
+	} `json:"severity"`
+	ExpectedExitCodes map[string][]int  `json:"expected_exit_codes"`
+	Topics            map[string]string `json:"topics"`
+}
+
+// LoadClassificationFile reads a classification file, with the environment's
+// settings (see config.ApplyEnv) over it, and applies it. A missing file is
+// not an error.
+func LoadClassificationFile(path string) error {
+	var doc any
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if doc, err = yamlite.Parse(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := deprecation.Check(deprecation.Classification, path, doc); err != nil {
+			return err
+		}
+	}
+	if doc, err = config.ApplyEnv("classify", doc); err != nil {
+		return err
+	}
+	if doc == nil {
+		return nil
+	}
+	var c Classification
+	if err := yamlite.Decode(doc, &c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := ApplyClassification(c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports the first setting in c that ApplyClassification would
+// reject.
+func (c Classification) Validate() error {
+	for _, p := range c.Severity.Prefixes {
+		if p.Prefix == "" {
+			return fmt.Errorf("severity.prefixes: prefix is required")
+		}
+		if _, ok := SeverityOrder[p.Severity]; !ok {
+			return fmt.Errorf("severity.prefixes[%s]: severity must be high, medium, or low", p.Prefix)
+		}
+	}
+	for probe, sev := range c.Severity.Exact {
+		if _, ok := SeverityOrder[sev]; !ok {
+			return fmt.Errorf("severity.exact[%s]: severity must be high, medium, or low", probe)
+		}
+	}
+	for prefix, topic := range c.Topics {
+		if prefix == "" || topic == "" {
+			return fmt.Errorf("topics: prefix and topic must be non-empty")
+		}
+	}
+	return nil
+}
+
+// ApplyClassification merges c into the built-in classification. It validates
+// everything before changing anything.
+func ApplyClassification(c Classification) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	var prefixes []severityPrefix
+	for _, p := range c.Severity.Prefixes {
+		prefixes = append(prefixes, severityPrefix{p.Prefix, p.Severity})
+	}
+	probeSeverityPrefix = append(prefixes, probeSeverityPrefix...)
+	for probe, sev := range c.Severity.Exact {
+		probeSeverityExact[probe] = sev
+	}
+	for probe, codes := range c.ExpectedExitCodes {
+		set, ok := probeExpectedExitCodes[probe]
+		if !ok {
+			set = make(map[int]struct{})
+			probeExpectedExitCodes[probe] = set
+		}
+		for _, code := range codes {
+			set[code] = struct{}{}
+		}
+	}
+	var newTopics []string
+	for prefix, topic := range c.Topics {
+		probeTopic[prefix] = topic
+		if topicSortKey(topic) == 99 {
+			newTopics = append(newTopics, topic)
+		}
+	}
+	if len(newTopics) > 0 {
+		sort.Strings(newTopics)
+		other := TopicOrder[len(TopicOrder)-1]
+		order := append([]string(nil), TopicOrder[:len(TopicOrder)-1]...)
+		for i, t := range newTopics {
+			if i == 0 || t != newTopics[i-1] {
+				order = append(order, t)
+			}
+		}
+		TopicOrder = append(order, other)
+	}
+	return nil
+}
+
+// ClassificationType is the row that records which classification a stored
+// snapshot was annotated under (see Reclassify).
+const ClassificationType = "classification"
+
+// ClassificationVersion fingerprints the effective classification, built-in
+// tables plus classify.yaml, so annotated snapshots can tell whether they
+// were classified alike.
+func ClassificationVersion() string {
+	prefixes := make([]string, len(probeSeverityPrefix))
+	for i, p := range probeSeverityPrefix {
+		prefixes[i] = p.prefix + "=" + p.sev
+	}
+	expected := make(map[string][]int, len(probeExpectedExitCodes))
+	for probe, set := range probeExpectedExitCodes {
+		for c := range set {
+			expected[probe] = append(expected[probe], c)
+		}
+		sort.Ints(expected[probe])
+	}
+	data, _ := json.Marshal([]any{prefixes, probeSeverityExact, expected, probeTopic, TopicOrder})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Reclassify annotates a snapshot's probe failures under the current
+// classification, in place: probe_failed rows and probe_failures_summary
+// items get severity and topic (items also get expected, see ExpectedState),
+// and the summary gets severity_counts. It then records the classification
+// version in a classification row after meta, replacing any earlier one.
+// Stored history annotated this way stays comparable after classify.yaml or
+// the built-in tables change. It returns the rows and the number of values
+// that changed, not counting the classification row.
+func Reclassify(rows []Row, now time.Time) ([]Row, int) {
+	changed := 0
+	set := func(m map[string]any, k string, v any) {
+		if fmt.Sprint(m[k]) != fmt.Sprint(v) {
+			m[k] = v
+			changed++
+		}
+	}
+	var previous any
+	out := make([]Row, 0, len(rows)+1)
+	at := 0
+	for _, r := range rows {
+		switch r["type"] {
+		case ClassificationType:
+			previous = r["version"]
+			continue
+		case "meta":
+			at = len(out) + 1
+		case "probe_failed":
+			if p, ok := r["probe"].(string); ok {
+				set(r, "severity", ProbeSeverity(p))
+				set(r, "topic", ProbeTopic(p))
+			}
+		case "probe_failures_summary":
+			counts := map[string]any{"high": 0, "medium": 0, "low": 0}
+			for _, it := range getSlice(r, "items") {
+				item, ok := it.(map[string]any)
+				p, _ := item["probe"].(string)
+				if !ok || p == "" {
+					continue
+				}
+				sev := ProbeSeverity(p)
+				counts[sev] = counts[sev].(int) + 1
+				set(item, "severity", sev)
+				set(item, "topic", ProbeTopic(p))
+				set(item, "expected", ExpectedState(p, getMap(item, "exit_codes")))
+			}
+			set(r, "severity_counts", counts)
+		}
+		out = append(out, r)
+	}
+
+	row := Row{"type": ClassificationType, "version": ClassificationVersion(), "classified_at": now.UTC().Format(time.RFC3339)}
+	if previous != nil && previous != row["version"] {
+		row["previous_version"] = previous
+	}
+	if at > 0 {
+		row["run_id"] = out[at-1]["run_id"]
+	}
+	out = append(out, nil)
+	copy(out[at+1:], out[at:])
+	out[at] = row
+	return out, changed
+}
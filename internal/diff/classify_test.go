@@ -0,0 +1,136 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// restoreClassification snapshots the package-level classification maps and
+// restores them when the test ends.
+func restoreClassification(t *testing.T) {
+	t.Helper()
+	prefixes := append([]severityPrefix(nil), probeSeverityPrefix...)
+	exact := make(map[string]string)
+	for k, v := range probeSeverityExact {
+		exact[k] = v
+	}
+	expected := make(map[string]map[int]struct{})
+	for k, v := range probeExpectedExitCodes {
+		set := make(map[int]struct{})
+		for c := range v {
+			set[c] = struct{}{}
+		}
+		expected[k] = set
+	}
+	topics := make(map[string]string)
+	for k, v := range probeTopic {
+		topics[k] = v
+	}
+	order := append([]string(nil), TopicOrder...)
+	t.Cleanup(func() {
+		probeSeverityPrefix, probeSeverityExact, probeExpectedExitCodes, probeTopic, TopicOrder = prefixes, exact, expected, topics, order
+	})
+}
+
+func TestLoadClassificationFile(t *testing.T) {
+	restoreClassification(t)
+	path := filepath.Join(t.TempDir(), "classify.yaml")
+	err := os.WriteFile(path, []byte(`severity:
+  prefixes:
+    - {prefix: "config.brew_", severity: low}
+  exact:
+    network.lsof_listen: low
+expected_exit_codes:
+  config.spctl_status: [1]
+topics:
+  config.brew_: Packages
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadClassificationFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ProbeSeverity("config.brew_list_cask"); got != "low" {
+		t.Errorf("ProbeSeverity(config.brew_list_cask) = %s, want low (user prefix first)", got)
+	}
+	if got := ProbeSeverity("config.spctl_status"); got != "high" {
+		t.Errorf("ProbeSeverity(config.spctl_status) = %s, want built-in high", got)
+	}
+	if got := ProbeSeverity("network.lsof_listen"); got != "low" {
+		t.Errorf("ProbeSeverity(network.lsof_listen) = %s, want low", got)
+	}
+	if got := ExpectedState("config.spctl_status", map[string]any{"1": 1.0}); got != "expected" {
+		t.Errorf("ExpectedState(config.spctl_status, 1) = %s, want expected", got)
+	}
+	if got := ProbeTopic("config.brew_prefix"); got != "Packages" {
+		t.Errorf("ProbeTopic(config.brew_prefix) = %s, want Packages (longest prefix)", got)
+	}
+	if n := len(TopicOrder); TopicOrder[n-1] != "Other" || TopicOrder[n-2] != "Packages" {
+		t.Errorf("TopicOrder = %v, want Packages before Other", TopicOrder)
+	}
+
+	if err := LoadClassificationFile(filepath.Join(t.TempDir(), "absent.yaml")); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+	if err := ApplyClassification(Classification{Topics: map[string]string{"x.": ""}}); err == nil {
+		t.Error("ApplyClassification with empty topic = nil error")
+	}
+}
+
+func TestReclassify(t *testing.T) {
+	restoreClassification(t)
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	rows := []Row{
+		{"type": "meta", "run_id": "r1"},
+		{"type": "probe_failed", "run_id": "r1", "probe": "custom.check"},
+		{"type": "probe_failures_summary", "run_id": "r1", "items": []any{
+			map[string]any{"probe": "config.fdesetup_status", "count": 1.0, "exit_codes": map[string]any{"15": 1.0}},
+			map[string]any{"probe": "custom.check", "count": 2.0, "exit_codes": map[string]any{"1": 2.0}},
+		}},
+	}
+	rows, changed := Reclassify(rows, now)
+	if changed != 9 {
+		t.Errorf("first Reclassify changed %d values, want 9", changed)
+	}
+	if rows[1]["type"] != ClassificationType || rows[1]["run_id"] != "r1" || rows[1]["version"] != ClassificationVersion() {
+		t.Fatalf("classification row = %v", rows[1])
+	}
+	if rows[2]["severity"] != "low" || rows[2]["topic"] != "Other" {
+		t.Errorf("probe_failed = %v", rows[2])
+	}
+	first := getSlice(rows[3], "items")[0].(map[string]any)
+	if first["severity"] != "high" || first["topic"] != "Security" || first["expected"] != "expected" {
+		t.Errorf("summary item = %v", first)
+	}
+	oldVersion := ClassificationVersion()
+
+	var c Classification
+	c.Topics = map[string]string{"custom.": "Custom"}
+	c.Severity.Exact = map[string]string{"custom.check": "high"}
+	if err := ApplyClassification(c); err != nil {
+		t.Fatal(err)
+	}
+	if ClassificationVersion() == oldVersion {
+		t.Fatal("classification version did not change")
+	}
+	rows, changed = Reclassify(rows, now)
+	if len(rows) != 4 {
+		t.Fatalf("reclassified snapshot has %d rows, want 4 (classification row replaced)", len(rows))
+	}
+	if changed != 5 { // probe_failed severity and topic, item severity and topic, severity_counts
+		t.Errorf("second Reclassify changed %d values, want 5", changed)
+	}
+	if rows[1]["previous_version"] != oldVersion {
+		t.Errorf("classification row = %v, want previous_version %s", rows[1], oldVersion)
+	}
+	if counts := getMap(rows[3], "severity_counts"); counts["high"] != 2 || counts["low"] != 0 {
+		t.Errorf("severity_counts = %v", counts)
+	}
+	if _, changed = Reclassify(rows, now); changed != 0 {
+		t.Errorf("Reclassify under the same classification changed %d values", changed)
+	}
+}
@@ -2,15 +2,19 @@ package diff
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/kb"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
 )
 
 const (
@@ -24,21 +28,34 @@ const (
 // When quiet is true, captures output to a buffer instead of stdout; the caller
 // should print the returned output when hasDeltas is true (for forensic breadcrumbs).
 func Run(baselineRows, currentRows []Row, ndjson bool, quiet bool) (hasDeltas bool, capturedOutput []byte) {
+	// Rows held in memory are never spilled, so there is nothing to fail.
+	hasDeltas, capturedOutput, _ = RunSnapshots(&Snapshot{Rows: baselineRows}, &Snapshot{Rows: currentRows}, ndjson, quiet)
+	return hasDeltas, capturedOutput
+}
+
+// RunSnapshots is Run for snapshots read by ReadSnapshot, whose per-row
+// inventories may have spilled to disk. It fails only when spilled rows
+// cannot be read back.
+func RunSnapshots(base, curr *Snapshot, ndjson bool, quiet bool) (hasDeltas bool, capturedOutput []byte, err error) {
 	var buf bytes.Buffer
 	if quiet {
 		r, w, _ := os.Pipe()
 		old := os.Stdout
 		os.Stdout = w
+		done := make(chan struct{})
 		go func() {
 			io.Copy(&buf, r)
 			r.Close()
+			close(done)
 		}()
 		defer func() {
 			w.Close()
 			os.Stdout = old
+			<-done
 			capturedOutput = buf.Bytes()
 		}()
 	}
+	baselineRows, currentRows := base.Rows, curr.Rows
 	baseByType := GroupByType(baselineRows)
 	currByType := GroupByType(currentRows)
 
@@ -46,8 +63,18 @@ func Run(baselineRows, currentRows []Row, ndjson bool, quiet bool) (hasDeltas bo
 	hasDeltas = emitStorageDelta(baseByType["summary"], currByType["summary"], ndjson) || hasDeltas
 	hasDeltas = emitCountDelta(baseByType["counts"], currByType["counts"], ndjson) || hasDeltas
 	hasDeltas = emitSecurityConfigDelta(baseByType["security_config"], currByType["security_config"], ndjson) || hasDeltas
+	hasDeltas = emitSSHDDelta(baseByType["sshd_config"], currByType["sshd_config"], ndjson) || hasDeltas
+	hasDeltas = emitSecurityEventsDelta(baseByType["security_events_summary"], currByType["security_events_summary"], ndjson) || hasDeltas
 	hasDeltas = emitHomebrewDelta(baseByType["homebrew_summary"], currByType["homebrew_summary"], ndjson) || hasDeltas
+	hasDeltas = emitPackageCountDelta("packages", baseByType["system_packages_summary"], currByType["system_packages_summary"], []string{"dpkg", "rpm", "pacman"}, ndjson) || hasDeltas
 	hasDeltas = emitRunContextDelta(baseByType["run_context"], currByType["run_context"], ndjson) || hasDeltas
+	hasDeltas = emitScopeDelta(baseByType["scope"], currByType["scope"], ndjson) || hasDeltas
+	emitTransaction(baseByType[transactionRowType], currByType[transactionRowType], ndjson)
+	inventoryDeltas, err := emitInventoryDelta(base, curr, currByType[transactionRowType], ndjson)
+	if err != nil {
+		return false, nil, err
+	}
+	hasDeltas = inventoryDeltas || hasDeltas
 
 	baseWarnings := CollectWarningCodes(baselineRows)
 	currWarnings := CollectWarningCodes(currentRows)
@@ -62,7 +89,7 @@ func Run(baselineRows, currentRows []Row, ndjson bool, quiet bool) (hasDeltas bo
 	hasDeltas = emitProbeFailuresDelta(baseByType["probe_failures_summary"], currByType["probe_failures_summary"], ndjson) || hasDeltas
 
 	if !hasDeltas && !ndjson && !quiet {
-		fmt.Println("No changes detected between baseline and current.")
+		fmt.Println(i18n.T("diff.no_changes"))
 	}
 	return
 }
@@ -72,7 +99,12 @@ func emitDiffRow(diffType string, fields map[string]any) {
 	for k, v := range fields {
 		row[k] = v
 	}
-	enc := json.NewEncoder(os.Stdout)
+	if keys := FindingKeysFromDiffRow(row); len(keys) == 1 {
+		if u := kb.DocURL(keys[0]); u != "" {
+			row["doc_url"] = u
+		}
+	}
+	enc := rawjson.NewEncoder(os.Stdout)
 	enc.SetEscapeHTML(false)
 	enc.Encode(row)
 }
@@ -414,14 +446,14 @@ func formatProbeEntryNew(probe string, currIt Row) string {
 		fmtTsMs,
 	)
 	expSuffix := ExpectedSuffix(probe, ec)
-	return fmt.Sprintf("  + %s failed %d× (%s), exit_codes: {%s}%s", probe, c, spanStr, formatExitCodes(ec), expSuffix)
+	return i18n.T("diff.probe.new", probe, c, spanStr, formatExitCodes(ec), expSuffix)
 }
 
 func formatProbeEntryResolved(probe string, baseIt Row) string {
 	c := toInt(baseIt["count"])
 	ec := getMap(baseIt, "exit_codes")
 	expSuffix := ExpectedSuffix(probe, ec)
-	return fmt.Sprintf("  - %s resolved (was %d×, exit_codes: {%s})%s", probe, c, formatExitCodes(ec), expSuffix)
+	return i18n.T("diff.probe.resolved", probe, c, formatExitCodes(ec), expSuffix)
 }
 
 func formatProbeEntryChanged(probe string, baseIt, currIt Row) string {
@@ -482,7 +514,7 @@ func emitStorageDelta(baseSum, currSum Row, ndjson bool) bool {
 			})
 		}
 	} else {
-		fmt.Println("## Storage delta")
+		fmt.Println(i18n.T("diff.section.storage"))
 		for _, d := range deltas {
 			sign := ""
 			if d.delta >= 0 {
@@ -528,7 +560,7 @@ func emitCountDelta(baseCounts, currCounts Row, ndjson bool) bool {
 			})
 		}
 	} else {
-		fmt.Println("## Count changes")
+		fmt.Println(i18n.T("diff.section.counts"))
 		for _, d := range deltas {
 			sign := ""
 			if d.delta >= 0 {
@@ -541,15 +573,28 @@ func emitCountDelta(baseCounts, currCounts Row, ndjson bool) bool {
 	return true
 }
 
+// securityConfigStrings are the security_config settings diff compares as
+// values rather than on/off, e.g. the Linux firewall's default incoming
+// policy ("drop" → "accept"), the MDM server a Mac is enrolled with, the
+// daemon that keeps the clock in sync, or a Mac's Startup Security policy
+// ("full" → "reduced") and firmware version.
+var securityConfigStrings = []string{"firewall_default_input_policy", "firewall_zone", "mdm_server", "time_sync_service", "boot_security", "firmware_version"}
+
 func emitSecurityConfigDelta(baseSec, currSec Row, ndjson bool) bool {
-	secFields := []string{"filevault", "sip", "gatekeeper", "firewall", "firewall_service_enabled", "firewall_service_active", "firewall_rules_active"}
+	secFields := []string{"filevault", "sip", "gatekeeper", "firewall", "firewall_service_enabled", "firewall_service_active", "firewall_rules_active",
+		"firewall_default_deny_incoming",
+		"secure_boot", "uac", "smb1", "rdp", "lsa_protection", "defender_realtime", "tamper_protection", "bitlocker",
+		"firewall_domain", "firewall_private", "firewall_public",
+		"mdm_enrolled", "dep_enrolled", "mdm_user_approved", "malware_data_stale",
+		"time_sync", "time_offset_exceeded", "secure_boot_setup_mode", "third_party_kexts"}
 	if baseSec == nil || currSec == nil {
 		return false
 	}
-	var changes []struct {
+	type change struct {
 		field string
-		b, c  bool
+		b, c  any
 	}
+	var changes []change
 	for _, f := range secFields {
 		b, c := baseSec[f], currSec[f]
 		if b == nil || c == nil {
@@ -558,12 +603,17 @@ func emitSecurityConfigDelta(baseSec, currSec Row, ndjson bool) bool {
 		bb := toBool(b)
 		cc := toBool(c)
 		if bb != cc {
-			changes = append(changes, struct {
-				field string
-				b, c  bool
-			}{f, bb, cc})
+			changes = append(changes, change{f, bb, cc})
 		}
 	}
+	for _, f := range securityConfigStrings {
+		b, bok := baseSec[f].(string)
+		c, cok := currSec[f].(string)
+		if !bok || !cok || b == c {
+			continue
+		}
+		changes = append(changes, change{f, b, c})
+	}
 	if len(changes) == 0 {
 		return false
 	}
@@ -576,16 +626,119 @@ func emitSecurityConfigDelta(baseSec, currSec Row, ndjson bool) bool {
 			})
 		}
 	} else {
-		fmt.Println("## Security config changes")
+		fmt.Println(i18n.T("diff.section.security_config"))
 		for _, ch := range changes {
-			bStr, cStr := "off", "off"
-			if ch.b {
-				bStr = "on"
-			}
-			if ch.c {
-				cStr = "on"
-			}
-			fmt.Printf("  %s: %s → %s\n", ch.field, bStr, cStr)
+			fmt.Printf("  %s: %s → %s\n", ch.field, securityConfigValue(ch.b), securityConfigValue(ch.c))
+		}
+		fmt.Println()
+	}
+	return true
+}
+
+// sshdFields are the sshd_config settings diff compares: the authentication
+// posture, what it is derived from, and root and empty-password logins.
+var sshdFields = []string{"auth_posture", "cert_auth", "plain_pubkey_auth", "password_auth", "trusted_user_ca_keys", "permit_root_login", "permit_empty_passwords"}
+
+func emitSSHDDelta(baseRow, currRow Row, ndjson bool) bool {
+	if baseRow == nil || currRow == nil {
+		return false
+	}
+	var changed []string
+	for _, f := range sshdFields {
+		b, bok := baseRow[f]
+		c, cok := currRow[f]
+		if bok && cok && fmt.Sprint(b) != fmt.Sprint(c) {
+			changed = append(changed, f)
+		}
+	}
+	if len(changed) == 0 {
+		return false
+	}
+	if ndjson {
+		for _, f := range changed {
+			emitDiffRow("sshd", map[string]any{"field": f, "baseline": baseRow[f], "current": currRow[f]})
+		}
+	} else {
+		fmt.Println(i18n.T("diff.section.sshd"))
+		for _, f := range changed {
+			fmt.Printf("  %s: %s → %s\n", f, securityConfigValue(baseRow[f]), securityConfigValue(currRow[f]))
+		}
+		fmt.Println()
+	}
+	return true
+}
+
+// securityConfigValue formats a security_config value for the text diff.
+func securityConfigValue(v any) string {
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return i18n.T("diff.on")
+		}
+		return i18n.T("diff.off")
+	case string:
+		if x == "" {
+			return `""`
+		}
+		return x
+	}
+	return fmt.Sprint(v)
+}
+
+// securityEventSpikes are the security_events_summary counts diff compares,
+// with the smallest increase that counts as a spike. The counts cover a fixed
+// window before each run, so they are compared as rates, not running totals.
+var securityEventSpikes = []struct {
+	field    string
+	minDelta int
+}{
+	{"logon_failures", 10},
+	{"account_lockouts", 1},
+	{"service_installs", 1},
+	{"audit_policy_changes", 1},
+	{"log_cleared", 1},
+}
+
+// emitSecurityEventsDelta reports event counts that spiked: rose by at least
+// the field's minimum and, unless the baseline was zero, at least doubled.
+// Drops are not reported. Counts that either run could not read are skipped.
+func emitSecurityEventsDelta(baseEv, currEv Row, ndjson bool) bool {
+	if baseEv == nil || currEv == nil {
+		return false
+	}
+	var spikes []struct {
+		field string
+		b, c  int
+	}
+	for _, f := range securityEventSpikes {
+		if baseEv[f.field] == nil || currEv[f.field] == nil {
+			continue
+		}
+		b, c := toInt(baseEv[f.field]), toInt(currEv[f.field])
+		if c-b >= f.minDelta && (b == 0 || c >= 2*b) {
+			spikes = append(spikes, struct {
+				field string
+				b, c  int
+			}{f.field, b, c})
+		}
+	}
+	if len(spikes) == 0 {
+		return false
+	}
+	if ndjson {
+		for _, sp := range spikes {
+			emitDiffRow("security_events", map[string]any{
+				"field":        sp.field,
+				"baseline":     sp.b,
+				"current":      sp.c,
+				"delta":        sp.c - sp.b,
+				"window_hours": toInt(currEv["window_hours"]),
+			})
+		}
+	} else {
+		fmt.Println(i18n.T("diff.section.security_events"))
+		for _, sp := range spikes {
+			fmt.Printf(i18n.T("diff.security_events.spike")+"\n", sp.field, sp.b, sp.c, toInt(currEv["window_hours"]))
 		}
 		fmt.Println()
 	}
@@ -609,7 +762,13 @@ func toBool(v any) bool {
 }
 
 func emitHomebrewDelta(baseBrew, currBrew Row, ndjson bool) bool {
-	if baseBrew == nil || currBrew == nil {
+	return emitPackageCountDelta("homebrew", baseBrew, currBrew, []string{"formulae", "casks"}, ndjson)
+}
+
+// emitPackageCountDelta reports changed package counts between two summary
+// rows, under diff type diffType and its section heading.
+func emitPackageCountDelta(diffType string, base, curr Row, fields []string, ndjson bool) bool {
+	if base == nil || curr == nil {
 		return false
 	}
 	var deltas []struct {
@@ -617,8 +776,8 @@ func emitHomebrewDelta(baseBrew, currBrew Row, ndjson bool) bool {
 		b, c  int
 		delta int
 	}
-	for _, f := range []string{"formulae", "casks"} {
-		b, c := toInt(baseBrew[f]), toInt(currBrew[f])
+	for _, f := range fields {
+		b, c := toInt(base[f]), toInt(curr[f])
 		if c-b != 0 {
 			deltas = append(deltas, struct {
 				field string
@@ -632,7 +791,7 @@ func emitHomebrewDelta(baseBrew, currBrew Row, ndjson bool) bool {
 	}
 	if ndjson {
 		for _, d := range deltas {
-			emitDiffRow("homebrew", map[string]any{
+			emitDiffRow(diffType, map[string]any{
 				"field":    d.field,
 				"baseline": d.b,
 				"current":  d.c,
@@ -640,7 +799,7 @@ func emitHomebrewDelta(baseBrew, currBrew Row, ndjson bool) bool {
 			})
 		}
 	} else {
-		fmt.Println("## Homebrew delta")
+		fmt.Println(i18n.T("diff.section." + diffType))
 		for _, d := range deltas {
 			sign := ""
 			if d.delta >= 0 {
@@ -686,7 +845,7 @@ func emitRunContextDelta(baseCtx, currCtx Row, ndjson bool) bool {
 			})
 		}
 	} else {
-		fmt.Println("## Run context changes")
+		fmt.Println(i18n.T("diff.section.run_context"))
 		for _, ch := range changes {
 			fmt.Printf("  %s: %v → %v\n", ch.field, ch.b, ch.c)
 		}
@@ -695,6 +854,39 @@ func emitRunContextDelta(baseCtx, currCtx Row, ndjson bool) bool {
 	return true
 }
 
+// emitScopeDelta reports snapshots taken with different `osaudit run --scope`
+// paths. A snapshot without a scope row was not scoped. Scoped rows list only
+// the items under the scope, so their inventory changes mean little across
+// scopes.
+func emitScopeDelta(baseScope, currScope Row, ndjson bool) bool {
+	b, c := scopePaths(baseScope), scopePaths(currScope)
+	if slices.Equal(b, c) {
+		return false
+	}
+	if ndjson {
+		emitDiffRow("scope", map[string]any{"field": "paths", "baseline": b, "current": c})
+		return true
+	}
+	show := func(paths []string) string {
+		if len(paths) == 0 {
+			return i18n.T("diff.scope.none")
+		}
+		return strings.Join(paths, ", ")
+	}
+	fmt.Println(i18n.T("diff.section.scope"))
+	fmt.Printf("  paths: %s → %s\n", show(b), show(c))
+	fmt.Println()
+	return true
+}
+
+func scopePaths(r Row) []string {
+	paths := []string{}
+	for _, p := range getSlice(r, "paths") {
+		paths = append(paths, fmt.Sprint(p))
+	}
+	return paths
+}
+
 func emitNewWarnings(codes []string, ndjson bool) bool {
 	if len(codes) == 0 {
 		return false
@@ -703,9 +895,9 @@ func emitNewWarnings(codes []string, ndjson bool) bool {
 	if ndjson {
 		emitDiffRow("new_warnings", map[string]any{"codes": codes})
 	} else {
-		fmt.Println("## New warnings")
+		fmt.Println(i18n.T("diff.section.new_warnings"))
 		for _, c := range codes {
-			fmt.Printf("  - %s\n", c)
+			fmt.Printf("  + %s\n", c)
 		}
 		fmt.Println()
 	}
@@ -725,8 +917,8 @@ func emitProbeFailuresDelta(basePF, currPF Row, ndjson bool) bool {
 	entries := buildProbeFailureEntries(basePF, currPF)
 	if len(entries) == 0 {
 		if !ndjson {
-			fmt.Println("## Probe failures delta")
-			fmt.Println("  No changes detected")
+			fmt.Println(i18n.T("diff.section.probe_failures"))
+			fmt.Println(i18n.T("diff.none"))
 			fmt.Println()
 		}
 		return false
@@ -785,10 +977,10 @@ func emitProbeFailuresDelta(basePF, currPF Row, ndjson bool) bool {
 		}
 		return topics[i] < topics[j]
 	})
-	fmt.Println("## Probe failures delta")
+	fmt.Println(i18n.T("diff.section.probe_failures"))
 	for _, topic := range topics {
 		items := byTopic[topic]
-		fmt.Printf("\n### %s\n", topic)
+		fmt.Printf("\n### %s\n", topicLabel(topic))
 		for _, e := range items {
 			switch e.status {
 			case "new":
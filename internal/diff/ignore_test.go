@@ -0,0 +1,43 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyIgnore_MasksFindingKeys(t *testing.T) {
+	baselineRows := []Row{
+		{"type": "security_config", "firewall": true, "sip": true},
+		{"type": "listening_ports", "items": []any{}},
+		{"type": "probe_failures_summary", "items": []any{}},
+	}
+	currentRows := []Row{
+		{"type": "security_config", "firewall": false, "sip": false},
+		{"type": "listening_ports", "items": []any{map[string]any{"process": "nc", "port": 4444.0}}},
+		{"type": "probe_failures_summary", "items": []any{map[string]any{"probe": "config.spctl_status", "reason": "timeout"}}},
+		{"type": "warning", "code": "network_audit_failed"},
+	}
+
+	want := []string{
+		"inventory:listening_ports",
+		"new_warnings:network_audit_failed",
+		"probe_failure:config.spctl_status",
+		"security_config:firewall",
+		"security_config:sip",
+	}
+	if got := FindingKeys(baselineRows, currentRows); !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindingKeys() = %v, want %v", got, want)
+	}
+
+	ignored := map[string]bool{
+		"inventory:listening_ports":         true,
+		"new_warnings:network_audit_failed": true,
+		"probe_failure:config.spctl_status": true,
+		"security_config:sip":               true,
+	}
+	match := func(k string) bool { return ignored[k] }
+	got := FindingKeys(ApplyIgnore(baselineRows, match), ApplyIgnore(currentRows, match))
+	if !reflect.DeepEqual(got, []string{"security_config:firewall"}) {
+		t.Errorf("FindingKeys() after ignore = %v, want only security_config:firewall", got)
+	}
+}
@@ -0,0 +1,496 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/digest"
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+	"github.com/kareemsasa/operating-system-audit/internal/rowbuf"
+)
+
+// inventorySpec describes how to key and compare the items of an inventory row
+// type. Types with items set read the "items" array of the (last) row of that
+// type; types without it treat every row of the type as one item (e.g. large_file).
+// Compare fields listed in optional were added after the type was; they are
+// compared only when both items have them, so older baselines do not show
+// every item as changed. Added and changed items get severity, when set;
+// changed items get changeSeverity's instead, when that is set, for types
+// where only some changes matter. defaults fills fields older items lack,
+// before they are keyed and compared.
+type inventorySpec struct {
+	rowType        string
+	topic          string
+	key            []string
+	compare        []string
+	optional       []string
+	defaults       map[string]any
+	items          bool
+	severity       string
+	changeSeverity func(base, curr Row) string
+}
+
+// Inventory row types diffed item-by-item. Order = display order.
+var inventorySpecs = []inventorySpec{
+	{rowType: "launch_daemons", topic: "Persistence", key: []string{"label"}, compare: []string{"program"}, items: true},
+	{rowType: "launch_agents", topic: "Persistence", key: []string{"scope", "label"}, compare: []string{"program"}, items: true},
+	{rowType: "background_items", topic: "Persistence", key: []string{"user", "identifier"}, compare: []string{"path", "executable", "team_id", "enabled", "allowed"}, items: true},
+	{rowType: "kernel_extensions", topic: "Persistence", key: []string{"name"}, compare: []string{"version", "team_id"}, optional: []string{"team_id"}, items: true, severity: "high"},
+	{rowType: "system_extensions", topic: "Persistence", key: []string{"bundle_id"}, compare: []string{"team_id", "version", "state"}, items: true, severity: "high"},
+	{rowType: "enabled_services", topic: "Persistence", key: []string{"unit"}, compare: []string{"state"}, items: true},
+	{rowType: "systemd_units", topic: "Persistence", key: []string{"unit"}, compare: []string{"path", "sha256", "dropins_sha256"}, items: true},
+	{rowType: "user_services", topic: "Persistence", key: []string{"unit"}, compare: []string{"state"}, items: true},
+	{rowType: "kernel_modules", topic: "Persistence", key: []string{"module"}, compare: []string{"version", "signer", "taint"}, optional: []string{"version", "signer", "taint"}, items: true, severity: "high"},
+	{rowType: "containers", topic: "Execution", key: []string{"runtime", "name"}, compare: []string{"image", "privileged", "root", "ports"}, items: true},
+	{rowType: "ai_agents", topic: "Execution", key: []string{"agent", "name", "user"}, items: true},
+	{rowType: "mcp_servers", topic: "Execution", key: []string{"user", "client", "name"}, compare: []string{"transport", "enabled", "command", "args", "url", "paths"}, items: true, severity: "high"},
+	{rowType: "k8s_node", topic: "Persistence", key: []string{"file"}, compare: []string{"sha256", "images", "privileged", "host_network"}, items: true, severity: "high"},
+	{rowType: "scheduled_tasks", topic: "Persistence", key: []string{"path"}, compare: []string{"program", "state"}, items: true},
+	{rowType: "cron_jobs", topic: "Persistence", key: []string{"path", "user", "command"}, compare: []string{"schedule"}, items: true},
+	{rowType: "background_tasks", topic: "Persistence", key: []string{"kind", "user", "name"}, compare: []string{"status", "path", "sha256"}, items: true},
+	{rowType: "shell_startup_files", topic: "Persistence", key: []string{"path"}, compare: []string{"sha256", "patterns"}, items: true},
+	{rowType: "recent_changes", topic: "Persistence", key: []string{"path"}, compare: []string{"modified"}, items: true},
+	{rowType: "xdg_autostart", topic: "Persistence", key: []string{"path"}, compare: []string{"name"}, items: true},
+	{rowType: "system_packages", topic: "Software", key: []string{"manager", "name", "arch"}, compare: []string{"version"}, items: true},
+	{rowType: "dev_toolchains", topic: "Software", key: []string{"tool"}, compare: []string{"version", "source"}, items: true},
+	{rowType: "language_packages", topic: "Software", key: []string{"manager", "name"}, compare: []string{"version"}, items: true},
+	{rowType: "runtimes", topic: "Software", key: []string{"runtime", "path"}, compare: []string{"version", "manager"}, items: true},
+	{rowType: "ide_extensions", topic: "Software", key: []string{"user", "ide", "id"}, compare: []string{"publisher", "publisher_id", "source", "marketplace"}, items: true, severity: "high"},
+	// Apps from before ~/Applications was read are all in /Applications.
+	{rowType: "applications", topic: "Software", key: []string{"name", "scope"}, compare: []string{"version", "team_id", "signed", "notarized"}, optional: []string{"team_id", "signed", "notarized"}, defaults: map[string]any{"scope": "system"}, items: true},
+	{rowType: "app_store_apps", topic: "Software", key: []string{"bundle_id"}, compare: []string{"version", "adam_id"}, items: true},
+	{rowType: "certificates", topic: "Security", key: []string{"sha256"}, compare: []string{"store", "user_added"}, items: true, severity: "high"},
+	{rowType: "config_profiles", topic: "Security", key: []string{"scope", "identifier"}, compare: []string{"organization", "payload_types", "verified"}, items: true, severity: "high"},
+	{rowType: "malware_protection", topic: "Security", key: []string{"component"}, items: true},
+	{rowType: "path_hijack", topic: "Security", key: []string{"path"}, compare: []string{"issues"}, items: true, severity: "high"},
+	{rowType: "sensitive_dirs", topic: "Security", key: []string{"path"}, compare: []string{"owner", "group", "mode"}, items: true, changeSeverity: loosened},
+	{rowType: "usb_devices", topic: "Security", key: []string{"vendor_id", "product_id", "serial"}, compare: []string{"classes"}, items: true, severity: "high"},
+	{rowType: "local_users", topic: "Identity", key: []string{"username"}, compare: []string{"uid", "admin"}, items: true},
+	{rowType: "ssh_keys", topic: "Identity", key: []string{"fingerprint"}, compare: []string{"file"}, items: true},
+	{rowType: "authorized_keys", topic: "Identity", key: []string{"user", "fingerprint"}, compare: []string{"file", "options"}, items: true, severity: "high"},
+	{rowType: "ssh_certificates", topic: "Identity", key: []string{"kind", "file", "key_id"}, compare: []string{"principals", "valid_to", "ca_fingerprint"}, items: true},
+	{rowType: "secrets_agents", topic: "Identity", key: []string{"agent"}, compare: []string{"lifetime", "confirm", "unconstrained"}, items: true},
+	{rowType: "env_secrets", topic: "Identity", key: []string{"source", "name"}, compare: []string{"pattern"}, items: true, severity: "high"},
+	{rowType: "recent_logins", topic: "Identity", key: []string{"user", "tty", "login"}, compare: []string{"host", "active"}, items: true},
+	{rowType: "listening_ports", topic: "Network", key: []string{"process", "port"}, items: true},
+	{rowType: "firewall_open_ports", topic: "Network", key: []string{"port", "proto"}, items: true},
+	{rowType: "dns_resolvers", topic: "Network", key: []string{"scope", "interface", "domain"}, compare: []string{"servers", "search"}, items: true, severity: "high"},
+	{rowType: "hosts_file", topic: "Network", key: []string{"hostname", "ip"}, items: true, severity: "high"},
+	{rowType: "vpn_connections", topic: "Network", key: []string{"kind", "name"}, compare: []string{"vpn_type", "interface", "state", "routes"}, items: true, severity: "high"},
+	{rowType: "routing_table", topic: "Network", key: []string{"destination", "interface"}, compare: []string{"gateway", "type"}, items: true, severity: "high"},
+	{rowType: "default_gateways", topic: "Network", key: []string{"interface", "ip"}, compare: []string{"mac"}, items: true, severity: "high"},
+	{rowType: "proxy_settings", topic: "Network", key: []string{"source", "scheme"}, compare: []string{"value"}, items: true, severity: "high"},
+	{rowType: "disk_volumes", topic: "Storage", key: []string{"mount"}, compare: []string{"device", "fstype", "encryption", "removable"}, items: true, severity: "high"},
+	{rowType: "removable_volumes", topic: "Storage", key: []string{"mount"}, compare: []string{"device", "fstype", "encryption"}, items: true},
+	{rowType: "disk_health", topic: "Storage", key: []string{"device"}, compare: []string{"health", "reallocated", "pending", "failing"}, items: true},
+	{rowType: "download_quarantine", topic: "Storage", key: []string{"path"}, compare: []string{"quarantined", "signed", "team_id"}, items: true, severity: "high"},
+	{rowType: "large_file", topic: "Storage", key: []string{"path"}},
+	// A file's digest is named after its hash_alg; rows from before the
+	// algorithm was recorded are SHA-256. A changed hash_alg makes every
+	// file changed, since its content can no longer be compared.
+	{rowType: "file_hash", topic: "Integrity", key: []string{"path"}, compare: []string{"hash_alg", "blake3", "sha256", "mode"}, optional: []string{"blake3", "sha256"}, defaults: map[string]any{"hash_alg": string(digest.Legacy)}},
+	{rowType: "watched_dotfiles", topic: "Integrity", key: []string{"path"}, compare: []string{"sha256", "mode"}, items: true},
+}
+
+// InventoryChange is one added, removed, or changed inventory item.
+type InventoryChange struct {
+	RowType  string
+	Topic    string
+	Key      string
+	Status   string // "added" | "removed" | "changed"
+	Severity string // set for added and changed items of types with a severity
+	Item     Row    // current item (baseline item when removed)
+	Base     Row    // baseline item for changed entries
+
+	compare []string
+}
+
+// pluginRowType is the row a collector plugin run writes (see internal/plugin).
+// Its row_types declare how the plugin's own rows are keyed and compared.
+const pluginRowType = "plugin"
+
+// inventorySpecsFor returns the built-in inventory specs followed by the keyed
+// row types declared by collector plugins in rows. Plugin types without a topic
+// are grouped under "Plugins"; built-in types cannot be redefined.
+func inventorySpecsFor(rowSets ...[]Row) []inventorySpec {
+	specs := append([]inventorySpec(nil), inventorySpecs...)
+	seen := make(map[string]bool, len(specs))
+	for _, s := range specs {
+		seen[s.rowType] = true
+	}
+	for _, rows := range rowSets {
+		for _, row := range rows {
+			if t, _ := row["type"].(string); t != pluginRowType {
+				continue
+			}
+			for _, rt := range getSlice(row, "row_types") {
+				m, _ := rt.(map[string]any)
+				spec := inventorySpec{
+					rowType: fmt.Sprint(m["type"]),
+					topic:   "Plugins",
+					key:     stringSlice(m["key"]),
+					compare: stringSlice(m["compare"]),
+				}
+				spec.items, _ = m["items"].(bool)
+				if topic, ok := m["topic"].(string); ok && topic != "" {
+					spec.topic = topic
+				}
+				if len(spec.key) == 0 || seen[spec.rowType] {
+					continue
+				}
+				seen[spec.rowType] = true
+				specs = append(specs, spec)
+			}
+		}
+	}
+	return specs
+}
+
+func stringSlice(v any) []string {
+	list, _ := v.([]any)
+	out := make([]string, 0, len(list))
+	for _, x := range list {
+		if s, ok := x.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// BuildInventoryChanges compares inventory items between baseline and current rows.
+// A type is only compared when both snapshots contain it, so diffing a network-only
+// snapshot against a full one does not report every persistence item as removed.
+// Keyed row types declared by collector plugins are compared the same way.
+func BuildInventoryChanges(baselineRows, currentRows []Row) []InventoryChange {
+	changes, _ := buildInventoryChanges(&Snapshot{Rows: baselineRows}, &Snapshot{Rows: currentRows})
+	return changes
+}
+
+// buildInventoryChanges is BuildInventoryChanges for snapshots. Per-row types
+// that either snapshot holds partitioned (see ReadSnapshot) are compared one
+// bucket at a time; the error is from reading spilled rows back.
+func buildInventoryChanges(base, curr *Snapshot) ([]InventoryChange, error) {
+	var changes []InventoryChange
+	for _, spec := range inventorySpecsFor(base.Rows, curr.Rows) {
+		if !spec.items && (base.perRow[spec.rowType] != nil || curr.perRow[spec.rowType] != nil) {
+			found, err := comparePartitioned(spec, base, curr)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, found...)
+			continue
+		}
+		baseItems, baseOK := inventoryItems(base.Rows, spec)
+		currItems, currOK := inventoryItems(curr.Rows, spec)
+		if !baseOK || !currOK {
+			continue
+		}
+		changes = append(changes, compareItems(spec, baseItems, currItems)...)
+	}
+	return changes, nil
+}
+
+// comparePartitioned compares the rows of per-row spec bucket by bucket, so
+// only one bucket of each snapshot is in memory at a time.
+func comparePartitioned(spec inventorySpec, base, curr *Snapshot) ([]InventoryChange, error) {
+	bp, err := base.partitioned(spec)
+	if err != nil || bp == nil {
+		return nil, err
+	}
+	cp, err := curr.partitioned(spec)
+	if err != nil || cp == nil {
+		return nil, err
+	}
+	var changes []InventoryChange
+	for i := 0; i < bp.Count(); i++ {
+		baseItems, err := bucketItems(spec, bp.Bucket(i))
+		if err != nil {
+			return nil, err
+		}
+		currItems, err := bucketItems(spec, cp.Bucket(i))
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, compareItems(spec, baseItems, currItems)...)
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+func bucketItems(spec inventorySpec, b *rowbuf.Buffer) (map[string]Row, error) {
+	out := make(map[string]Row, b.Len())
+	err := b.Each(func(r rowbuf.Row) error {
+		if k := inventoryKey(spec, r); k != "" {
+			out[k] = spec.fill(r)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// compareItems returns the changes between two keyed item sets, by key.
+func compareItems(spec inventorySpec, baseItems, currItems map[string]Row) []InventoryChange {
+	var changes []InventoryChange
+	var keys []string
+	seen := make(map[string]struct{})
+	for k := range baseItems {
+		keys = append(keys, k)
+		seen[k] = struct{}{}
+	}
+	for k := range currItems {
+		if _, ok := seen[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b, inBase := baseItems[k]
+		c, inCurr := currItems[k]
+		switch {
+		case !inBase:
+			changes = append(changes, InventoryChange{RowType: spec.rowType, Topic: spec.topic, Key: k, Status: "added", Severity: spec.severity, Item: c})
+		case !inCurr:
+			changes = append(changes, InventoryChange{RowType: spec.rowType, Topic: spec.topic, Key: k, Status: "removed", Item: b})
+		case inventoryItemChanged(spec, b, c):
+			sev := spec.severity
+			if spec.changeSeverity != nil {
+				sev = spec.changeSeverity(b, c)
+			}
+			changes = append(changes, InventoryChange{RowType: spec.rowType, Topic: spec.topic, Key: k, Status: "changed", Severity: sev, Item: c, Base: b, compare: compareFields(spec, b, c)})
+		}
+	}
+	return changes
+}
+
+func inventoryItems(rows []Row, spec inventorySpec) (map[string]Row, bool) {
+	out := make(map[string]Row)
+	found := false
+	for _, row := range rows {
+		if t, _ := row["type"].(string); t != spec.rowType {
+			continue
+		}
+		found = true
+		if !spec.items {
+			if k := inventoryKey(spec, row); k != "" {
+				out[k] = spec.fill(row)
+			}
+			continue
+		}
+		// Last row of the type wins, matching GroupByType.
+		out = make(map[string]Row)
+		for _, it := range getSlice(row, "items") {
+			item, _ := it.(map[string]any)
+			if item == nil {
+				continue
+			}
+			if k := inventoryKey(spec, item); k != "" {
+				out[k] = spec.fill(item)
+			}
+		}
+	}
+	return out, found
+}
+
+// fill returns item with the spec's defaults for the fields it lacks, copying
+// it rather than editing the snapshot's row.
+func (spec inventorySpec) fill(item Row) Row {
+	var out Row
+	for f, v := range spec.defaults {
+		if _, ok := item[f]; ok {
+			continue
+		}
+		if out == nil {
+			out = make(Row, len(item)+len(spec.defaults))
+			for k, v := range item {
+				out[k] = v
+			}
+		}
+		out[f] = v
+	}
+	if out == nil {
+		return item
+	}
+	return out
+}
+
+func inventoryKey(spec inventorySpec, item Row) string {
+	parts := make([]string, 0, len(spec.key))
+	for _, f := range spec.key {
+		v, ok := item[f]
+		if !ok {
+			v, ok = spec.defaults[f]
+		}
+		if !ok || v == nil {
+			return ""
+		}
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return strings.Join(parts, ":")
+}
+
+// compareFields returns the spec's compare fields that apply to b and c:
+// optional fields are skipped unless both items have them.
+func compareFields(spec inventorySpec, b, c Row) []string {
+	if len(spec.optional) == 0 {
+		return spec.compare
+	}
+	out := make([]string, 0, len(spec.compare))
+	for _, f := range spec.compare {
+		_, inBase := b[f]
+		_, inCurr := c[f]
+		if (!inBase || !inCurr) && slices.Contains(spec.optional, f) {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// loosened is the severity of a sensitive_dirs change: high when the
+// directory's owner changed, its mode gained a permission bit or lost the
+// sticky bit, or its group changed while the mode gives the group access.
+// Tightening a directory is reported without a severity.
+func loosened(base, curr Row) string {
+	bm, errB := strconv.ParseUint(fmt.Sprint(base["mode"]), 8, 32)
+	cm, errC := strconv.ParseUint(fmt.Sprint(curr["mode"]), 8, 32)
+	switch {
+	case fmt.Sprint(base["owner"]) != fmt.Sprint(curr["owner"]):
+	case errB == nil && errC == nil && (cm&^bm&0o6777 != 0 || bm&^cm&0o1000 != 0):
+	case fmt.Sprint(base["group"]) != fmt.Sprint(curr["group"]) && (errC != nil || cm&0o070 != 0):
+	default:
+		return ""
+	}
+	return "high"
+}
+
+func inventoryItemChanged(spec inventorySpec, b, c Row) bool {
+	for _, f := range compareFields(spec, b, c) {
+		if fmt.Sprint(b[f]) != fmt.Sprint(c[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatInventoryChange(ch InventoryChange) string {
+	sev := ""
+	if ch.Severity != "" {
+		sev = " [" + ch.Severity + "]"
+	}
+	key := rawjson.Display(ch.Key) // keys are often file names
+	switch ch.Status {
+	case "added":
+		return fmt.Sprintf("  + %s: %s%s", ch.RowType, key, sev)
+	case "removed":
+		return fmt.Sprintf("  - %s: %s", ch.RowType, key)
+	}
+	var parts []string
+	for _, f := range ch.compare {
+		if fmt.Sprint(ch.Base[f]) != fmt.Sprint(ch.Item[f]) {
+			parts = append(parts, fmt.Sprintf("%s %s → %s", f, displayValue(ch.Base[f]), displayValue(ch.Item[f])))
+		}
+	}
+	return fmt.Sprintf("  ~ %s: %s (%s)%s", ch.RowType, key, strings.Join(parts, ", "), sev)
+}
+
+// displayValue formats an item field for text output, quoting strings that
+// would break the line or the terminal.
+func displayValue(v any) string {
+	if s, ok := v.(string); ok {
+		return rawjson.Display(s)
+	}
+	return fmt.Sprint(v)
+}
+
+func inventoryChangeFields(ch InventoryChange) map[string]any {
+	fields := map[string]any{
+		"row_type": ch.RowType,
+		"topic":    ch.Topic,
+		"key":      ch.Key,
+		"status":   ch.Status,
+	}
+	if ch.Severity != "" {
+		fields["severity"] = ch.Severity
+	}
+	switch ch.Status {
+	case "removed":
+		fields["baseline"] = ch.Item
+	case "changed":
+		fields["baseline"] = ch.Base
+		fields["current"] = ch.Item
+	default:
+		fields["current"] = ch.Item
+	}
+	return fields
+}
+
+// emitInventoryDelta reports the inventory changes, grouped first by the
+// packages of tx, the current snapshot's package transaction (nil for none),
+// then by vendor.
+func emitInventoryDelta(base, curr *Snapshot, tx Row, ndjson bool) (bool, error) {
+	changes, err := buildInventoryChanges(base, curr)
+	if err != nil || len(changes) == 0 {
+		return false, err
+	}
+	txGroups, changes := AttributeTransaction(tx, changes)
+	groups, rest := AttributeInstalls(changes)
+	groups = append(txGroups, groups...)
+	if ndjson {
+		for _, g := range groups {
+			members := make([]map[string]any, 0, len(g.Changes))
+			for _, ch := range g.Changes {
+				members = append(members, inventoryChangeFields(ch))
+			}
+			fields := map[string]any{
+				"vendor":  g.Vendor,
+				"label":   g.Label,
+				"version": g.Version,
+				"status":  g.Status,
+				"changes": members,
+			}
+			if g.Source != "" {
+				fields["source"] = g.Source
+			}
+			emitDiffRow("install_group", fields)
+		}
+		for _, ch := range rest {
+			emitDiffRow("inventory", inventoryChangeFields(ch))
+		}
+		return true, nil
+	}
+	if len(groups) > 0 {
+		fmt.Println(i18n.T("diff.section.installs"))
+		for _, g := range groups {
+			fmt.Printf("  %s\n", g.Summary())
+			for _, ch := range g.Changes {
+				fmt.Printf("  %s\n", formatInventoryChange(ch))
+			}
+		}
+		fmt.Println()
+	}
+	if len(rest) > 0 {
+		fmt.Println(i18n.T("diff.section.inventory"))
+		lastTopic := ""
+		for _, ch := range sortedByTopic(rest) {
+			if ch.Topic != lastTopic {
+				fmt.Printf("\n### %s\n", topicLabel(ch.Topic))
+				lastTopic = ch.Topic
+			}
+			fmt.Println(formatInventoryChange(ch))
+		}
+		fmt.Println()
+	}
+	return true, nil
+}
+
+func sortedByTopic(changes []InventoryChange) []InventoryChange {
+	out := append([]InventoryChange(nil), changes...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return topicSortKey(out[i].Topic) < topicSortKey(out[j].Topic)
+	})
+	return out
+}
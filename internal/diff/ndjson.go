@@ -2,10 +2,14 @@ package diff
 
 import (
 	"bufio"
-	"encoding/json"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
 )
 
 const maxLineSize = 1024 * 1024 // 1MB buffer limit since audit output can be large
@@ -14,16 +18,62 @@ const maxLineSize = 1024 * 1024 // 1MB buffer limit since audit output can be la
 type Row map[string]any
 
 // ReadNDJSON reads an NDJSON file from path. Skips empty lines.
-// Returns a clear error with the line number on bad JSON.
+// Returns a clear error with the line number on bad JSON. Gzip-compressed
+// files (see `osaudit snapshot compact`) are read transparently. Delta-encoded
+// file_hash rows (see FIMDeltaType) are materialized from their base, and
+// rows from an older schema version are upconverted (see UpgradeRows).
 func ReadNDJSON(path string) ([]Row, error) {
+	rows, err := readNDJSONFile(path)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if rows, err = materializeFIM(path, rows, map[string]bool{abs: true}); err != nil {
+		return nil, err
+	}
+	return UpgradeRows(rows), nil
+}
+
+// ReadNDJSONRaw reads an NDJSON file as stored, without materializing
+// delta-encoded rows or upgrading old schema versions.
+func ReadNDJSONRaw(path string) ([]Row, error) { return readNDJSONFile(path) }
+
+func readNDJSONFile(path string) ([]Row, error) {
+	var rows []Row
+	err := scanNDJSONFile(path, func(r Row) error {
+		rows = append(rows, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// scanNDJSONFile calls fn with each row of the NDJSON file at path, as stored,
+// stopping at the first error.
+func scanNDJSONFile(path string, fn func(Row) error) error {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("open %s: %w", path, err)
+		return fmt.Errorf("open %s: %w", path, err)
 	}
 	defer f.Close()
 
-	var rows []Row
-	scanner := bufio.NewScanner(f)
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if IsGzip(br) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	scanner := bufio.NewScanner(r)
 	buf := make([]byte, 0, maxLineSize)
 	scanner.Buffer(buf, maxLineSize)
 
@@ -33,21 +83,29 @@ func ReadNDJSON(path string) ([]Row, error) {
 			continue
 		}
 		var obj map[string]any
-		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+		if err := rawjson.Unmarshal([]byte(line), &obj); err != nil {
 			msg := err.Error()
 			if idx := strings.Index(msg, "\n"); idx >= 0 {
 				msg = msg[:idx]
 			}
-			return nil, fmt.Errorf("invalid JSON at line %d: %s", lineNo, msg)
+			return fmt.Errorf("invalid JSON at line %d: %s", lineNo, msg)
+		}
+		if err := fn(obj); err != nil {
+			return err
 		}
-		rows = append(rows, obj)
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("read %s: %w", path, err)
+		return fmt.Errorf("read %s: %w", path, err)
 	}
+	return nil
+}
 
-	return rows, nil
+// IsGzip reports whether r starts with the gzip magic number, without
+// consuming it.
+func IsGzip(r *bufio.Reader) bool {
+	magic, err := r.Peek(2)
+	return err == nil && magic[0] == 0x1f && magic[1] == 0x8b
 }
 
 // CollectWarningCodes collects unique warning identifiers from all warning rows.
@@ -1,8 +1,18 @@
 package diff
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"unicode/utf8"
+
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
 )
 
 func TestReadNDJSON(t *testing.T) {
@@ -46,3 +56,80 @@ func TestReadNDJSON(t *testing.T) {
 		t.Errorf("current GroupByType: expected %d keys, got %d", len(wantKeys), len(currByType))
 	}
 }
+
+func TestReadNDJSONGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.ndjson")
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("{\"type\":\"meta\",\"run_id\":\"r\"}\n\n{\"type\":\"system\"}\n"))
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadNDJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1]["type"] != "system" {
+		t.Errorf("ReadNDJSON(gzip) = %v", rows)
+	}
+}
+
+func TestReadNDJSONUnusualPaths(t *testing.T) {
+	// The fixtures hold large_file rows as json_escape writes them: non-UTF-8
+	// bytes as \udcXX escapes, plus control characters and a 4.5 KB path.
+	base, err := ReadNDJSON(filepath.Join("..", "..", "tests", "fixtures", "unusual_paths_baseline.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	curr, err := ReadNDJSON(filepath.Join("..", "..", "tests", "fixtures", "unusual_paths_current.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := base[1]["path"]; p != "/home/u/latin1-\xe9t\xe9.iso" {
+		t.Errorf("path = %q, want the original bytes", p)
+	}
+	// ISO-8859-1 names that differ in one byte must not both read as U+FFFD.
+	var added []string
+	for _, c := range BuildInventoryChanges(base, curr) {
+		added = append(added, c.Status+" "+c.Key)
+	}
+	want := []string{"added /home/u/\x1b[31mred\tfile", "added /home/u/latin1-\xe8t\xe9.iso"}
+	if !reflect.DeepEqual(added, want) {
+		t.Errorf("changes = %q, want %q", added, want)
+	}
+
+	run := func(jsonOut bool) []byte {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		Run(base, curr, jsonOut, false)
+		w.Close()
+		os.Stdout = oldStdout
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		return buf.Bytes()
+	}
+	// JSON output keeps the bytes, escaped the way the scripts escape them.
+	var keys []string
+	for _, line := range bytes.Split(bytes.TrimSpace(run(true)), []byte("\n")) {
+		if !json.Valid(line) || bytes.ContainsRune(line, utf8.RuneError) {
+			t.Errorf("diff line %q is not clean JSON", line)
+		}
+		var row Row
+		if err := rawjson.Unmarshal(line, &row); err != nil {
+			t.Fatal(err)
+		}
+		if k, ok := row["key"].(string); ok {
+			keys = append(keys, k)
+		}
+	}
+	if want := []string{"/home/u/\x1b[31mred\tfile", "/home/u/latin1-\xe8t\xe9.iso"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("JSON diff keys = %q, want %q", keys, want)
+	}
+	// Text output quotes the names instead of printing raw escapes.
+	text := string(run(false))
+	if strings.Contains(text, "\x1b") || !strings.Contains(text, `"/home/u/latin1-\xe8t\xe9.iso"`) {
+		t.Errorf("text diff = %q", text)
+	}
+}
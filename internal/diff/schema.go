@@ -0,0 +1,113 @@
+package diff
+
+import "sort"
+
+// SchemaVersion is the row schema this build writes, as the meta row's
+// schema_version. Bump it when a row type's fields are renamed or a type is
+// split, and add a schemaUpgrade from the previous version so that older
+// snapshots keep diffing against new ones.
+const SchemaVersion = "0.1"
+
+// schemaUpgrade converts rows written under schema version from to version to.
+// apply returns the rows that replace one row: itself, edited, for renamed
+// fields, several for a split type, none for a dropped one. reads lists the
+// row types apply changes and writes the types it produces, so readers of
+// only some types (snapindex.ReadTypes) know what to read for them. No
+// upgrade may read large_file or file_hash: ReadSnapshot keeps those rows out
+// of the rows it upgrades.
+type schemaUpgrade struct {
+	from, to string
+	reads    []string
+	writes   []string
+	apply    func(Row) []Row
+}
+
+// schemaUpgrades is the chain of upgrades, oldest first. No release has
+// changed a row schema yet.
+var schemaUpgrades []schemaUpgrade
+
+// renameField moves r[from] to r[to] unless r already has to.
+func renameField(r Row, from, to string) {
+	v, ok := r[from]
+	if !ok {
+		return
+	}
+	delete(r, from)
+	if _, exists := r[to]; !exists {
+		r[to] = v
+	}
+}
+
+// RowSchemaVersion returns the schema_version of rows' meta row. Snapshots
+// without one predate versioning and are read as 0.1.
+func RowSchemaVersion(rows []Row) string {
+	for _, r := range rows {
+		if r["type"] == "meta" {
+			if v, ok := r["schema_version"].(string); ok && v != "" {
+				return v
+			}
+			break
+		}
+	}
+	return "0.1"
+}
+
+// UpgradeRows upconverts rows written under an older schema version to
+// SchemaVersion, editing them in place, and updates the meta row's
+// schema_version. Rows already current, or from a version this build does not
+// know (a newer osaudit), are returned unchanged.
+func UpgradeRows(rows []Row) []Row {
+	version := RowSchemaVersion(rows)
+	upgraded := false
+	for _, u := range schemaUpgrades {
+		if u.from != version {
+			continue
+		}
+		out := make([]Row, 0, len(rows))
+		for _, r := range rows {
+			out = append(out, u.apply(r)...)
+		}
+		rows, version, upgraded = out, u.to, true
+	}
+	if upgraded {
+		for _, r := range rows {
+			if r["type"] == "meta" {
+				r["schema_version"] = version
+				break
+			}
+		}
+	}
+	return rows
+}
+
+// UpgradeSourceTypes returns types plus every row type an older snapshot may
+// hold them under, so a reader of only those types also reads what upgrades
+// into them. The result is sorted and includes meta, which carries the
+// schema version.
+func UpgradeSourceTypes(types []string) []string {
+	set := map[string]bool{"meta": true}
+	for _, t := range types {
+		set[t] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, u := range schemaUpgrades {
+			for _, w := range u.writes {
+				if !set[w] {
+					continue
+				}
+				for _, r := range u.reads {
+					if !set[r] {
+						set[r], changed = true, true
+					}
+				}
+			}
+		}
+	}
+	out := make([]string, 0, len(set))
+	for t := range set {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withTestUpgrade registers, for the test, an upgrade from the current schema
+// to a "next" one that renames security_config.firewall_on to firewall and
+// splits its filevault field into a disk_encryption row.
+func withTestUpgrade(t *testing.T) (next string) {
+	t.Helper()
+	next = SchemaVersion + "-next"
+	saved := schemaUpgrades
+	schemaUpgrades = []schemaUpgrade{{
+		from: SchemaVersion, to: next,
+		reads: []string{"security_config"}, writes: []string{"security_config", "disk_encryption"},
+		apply: func(r Row) []Row {
+			if r["type"] != "security_config" {
+				return []Row{r}
+			}
+			renameField(r, "firewall_on", "firewall")
+			v, ok := r["filevault"]
+			if !ok {
+				return []Row{r}
+			}
+			delete(r, "filevault")
+			return []Row{r, {"type": "disk_encryption", "run_id": r["run_id"], "enabled": v}}
+		},
+	}}
+	t.Cleanup(func() { schemaUpgrades = saved })
+	return next
+}
+
+func TestUpgradeRows_AppliesRegisteredUpgrades(t *testing.T) {
+	next := withTestUpgrade(t)
+	old := []Row{
+		{"type": "meta", "run_id": "r1", "schema_version": SchemaVersion},
+		{"type": "security_config", "run_id": "r1", "firewall_on": true, "filevault": false},
+	}
+	got := UpgradeRows(old)
+	want := []Row{
+		{"type": "meta", "run_id": "r1", "schema_version": next},
+		{"type": "security_config", "run_id": "r1", "firewall": true},
+		{"type": "disk_encryption", "run_id": "r1", "enabled": false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UpgradeRows =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestUpgradeRows_LeavesCurrentAndUnknownVersions(t *testing.T) {
+	next := withTestUpgrade(t)
+	for _, version := range []string{next, "9.0"} {
+		rows := []Row{
+			{"type": "meta", "schema_version": version},
+			{"type": "security_config", "firewall_on": true},
+		}
+		if got := UpgradeRows(rows); got[1]["firewall_on"] != true || got[0]["schema_version"] != version {
+			t.Errorf("UpgradeRows(%s) = %v, want unchanged", version, got)
+		}
+	}
+}
+
+func TestDiffOldBaselineAgainstNewSnapshot(t *testing.T) {
+	next := withTestUpgrade(t)
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.ndjson")
+	curr := filepath.Join(dir, "curr.ndjson")
+	writeRows(t, base, []Row{
+		{"type": "meta", "run_id": "old"}, // before schema_version existed
+		{"type": "security_config", "run_id": "old", "firewall_on": true, "sip": true},
+	})
+	writeRows(t, curr, []Row{
+		{"type": "meta", "run_id": "new", "schema_version": next},
+		{"type": "security_config", "run_id": "new", "firewall": false, "sip": true},
+	})
+	baseRows, err := ReadNDJSON(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	currRows, err := ReadNDJSON(curr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := FindingKeys(baseRows, currRows), []string{"security_config:firewall"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindingKeys = %v, want %v", got, want)
+	}
+}
+
+func TestUpgradeSourceTypes(t *testing.T) {
+	withTestUpgrade(t)
+	got := UpgradeSourceTypes([]string{"disk_encryption", "listening_ports"})
+	want := []string{"disk_encryption", "listening_ports", "meta", "security_config"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UpgradeSourceTypes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,237 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/kareemsasa/operating-system-audit/internal/rowbuf"
+)
+
+// snapshotPartitions is how many buckets ReadSnapshot splits each per-row
+// inventory into. Comparing one bucket holds about 1/snapshotPartitions of
+// each snapshot's rows of the type in memory.
+const snapshotPartitions = 64
+
+// Snapshot is a snapshot read for diffing within a memory ceiling. Rows holds
+// its rows except, when read by ReadSnapshot, those of the per-row inventory
+// types (large_file, file_hash), which grow with the host and are kept
+// partitioned by key in buffers that spill to temporary files.
+type Snapshot struct {
+	Rows []Row
+
+	perRow map[string]*rowbuf.Partitions
+	limit  int64
+}
+
+// ReadSnapshot reads the snapshot at path like ReadNDJSON, but keeps about
+// limit bytes of per-row inventory rows in memory and spills the rest to
+// temporary files. Close the snapshot to remove them.
+func ReadSnapshot(path string, limit int64) (*Snapshot, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return readSnapshot(path, limit, map[string]bool{abs: true})
+}
+
+func readSnapshot(path string, limit int64, seen map[string]bool) (*Snapshot, error) {
+	s := &Snapshot{perRow: make(map[string]*rowbuf.Partitions), limit: limit}
+	err := scanNDJSONFile(path, func(r Row) error {
+		t, _ := r["type"].(string)
+		spec, ok := perRowSpec(t)
+		if !ok {
+			s.Rows = append(s.Rows, r)
+			return nil
+		}
+		return s.add(spec, r)
+	})
+	if err == nil {
+		err = s.materializeFIM(path, seen)
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.Rows = UpgradeRows(s.Rows)
+	return s, nil
+}
+
+// perRowSpec returns the built-in spec of a per-row inventory type.
+func perRowSpec(rowType string) (inventorySpec, bool) {
+	for _, spec := range inventorySpecs {
+		if spec.rowType == rowType && !spec.items {
+			return spec, true
+		}
+	}
+	return inventorySpec{}, false
+}
+
+// add files r, a row of per-row spec, in its bucket.
+func (s *Snapshot) add(spec inventorySpec, r Row) error {
+	p := s.perRow[spec.rowType]
+	if p == nil {
+		p = rowbuf.NewPartitions(snapshotPartitions, s.ceiling())
+		s.perRow[spec.rowType] = p
+	}
+	return p.Add(inventoryKey(spec, r), r)
+}
+
+func (s *Snapshot) ceiling() int64 {
+	if s.limit > 0 {
+		return s.limit
+	}
+	return rowbuf.DefaultLimit
+}
+
+// partitioned returns the rows of per-row spec in buckets, or nil when the
+// snapshot has none. A snapshot not read by ReadSnapshot has its rows of the
+// type partitioned on first use.
+func (s *Snapshot) partitioned(spec inventorySpec) (*rowbuf.Partitions, error) {
+	if p := s.perRow[spec.rowType]; p != nil {
+		return p, nil
+	}
+	if s.perRow == nil {
+		s.perRow = make(map[string]*rowbuf.Partitions)
+	}
+	for _, r := range s.Rows {
+		if r["type"] == spec.rowType {
+			if err := s.add(spec, r); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.perRow[spec.rowType], nil
+}
+
+// materializeFIM replaces a fim_delta row, like the package-level
+// materializeFIM, bucket by bucket: each bucket of the base's file_hash rows
+// is merged with the same bucket of this snapshot's.
+func (s *Snapshot) materializeFIM(path string, seen map[string]bool) error {
+	at := -1
+	for i, r := range s.Rows {
+		if r["type"] == FIMDeltaType {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return nil
+	}
+	delta := s.Rows[at]
+	s.Rows = append(s.Rows[:at], s.Rows[at+1:]...)
+	rel, _ := delta["base"].(string)
+	if rel == "" {
+		return fmt.Errorf("%s: fim_delta row has no base", path)
+	}
+	basePath := filepath.Join(filepath.Dir(path), filepath.FromSlash(rel))
+	if abs, err := filepath.Abs(basePath); err == nil {
+		basePath = abs
+	}
+	if seen[basePath] {
+		return fmt.Errorf("%s: fim_delta base chain loops at %s", path, basePath)
+	}
+	seen[basePath] = true
+
+	base, err := readSnapshot(basePath, s.limit, seen)
+	if err != nil {
+		return fmt.Errorf("%s: read delta base: %w", path, err)
+	}
+	defer base.Close()
+	for _, r := range base.Rows {
+		if r["type"] == "meta" {
+			if want, _ := delta["base_run_id"].(string); want != "" && r["run_id"] != want {
+				return fmt.Errorf("%s: delta base %s is from run %v, want %s", path, basePath, r["run_id"], want)
+			}
+			break
+		}
+	}
+	bp := base.perRow[fimRowType]
+	if bp == nil {
+		return nil
+	}
+	removed := make(map[string]bool)
+	for _, p := range getSlice(delta, "removed") {
+		if p, ok := p.(string); ok {
+			removed[p] = true
+		}
+	}
+	runID, _ := delta["run_id"].(string)
+	spec, _ := perRowSpec(fimRowType)
+	cp := s.perRow[fimRowType]
+	merged := rowbuf.NewPartitions(snapshotPartitions, s.ceiling())
+	for i := 0; i < snapshotPartitions; i++ {
+		out := merged.Bucket(i)
+		own := make(map[string]bool)
+		if cp != nil {
+			err := cp.Bucket(i).Each(func(r rowbuf.Row) error {
+				own[inventoryKey(spec, r)] = true
+				return out.Add(r)
+			})
+			if err != nil {
+				merged.Close()
+				return err
+			}
+		}
+		err := bp.Bucket(i).Each(func(r rowbuf.Row) error {
+			p, _ := r["path"].(string)
+			if own[p] || removed[p] {
+				return nil
+			}
+			c := make(Row, len(r))
+			for k, v := range r {
+				c[k] = v
+			}
+			c["run_id"] = runID
+			return out.Add(c)
+		})
+		if err != nil {
+			merged.Close()
+			return err
+		}
+	}
+	if cp != nil {
+		cp.Close()
+	}
+	s.perRow[fimRowType] = merged
+	if merged.Len() == 0 {
+		merged.Close()
+		delete(s.perRow, fimRowType)
+	}
+	return nil
+}
+
+// ApplyIgnore masks ignored findings out of the snapshot, like the
+// package-level ApplyIgnore.
+func (s *Snapshot) ApplyIgnore(ignored func(key string) bool) {
+	if ignored == nil {
+		return
+	}
+	s.Rows = ApplyIgnore(s.Rows, ignored)
+	for t, p := range s.perRow {
+		if ignored("inventory:" + t) {
+			p.Close()
+			delete(s.perRow, t)
+		}
+	}
+}
+
+// Spilled reports whether any of the snapshot's rows were moved to disk.
+func (s *Snapshot) Spilled() bool {
+	for _, p := range s.perRow {
+		if p.Spilled() {
+			return true
+		}
+	}
+	return false
+}
+
+// Close removes the snapshot's spill files.
+func (s *Snapshot) Close() error {
+	var errs []error
+	for t, p := range s.perRow {
+		errs = append(errs, p.Close())
+		delete(s.perRow, t)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,84 @@
+package diff
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadSnapshotSpillsAndDiffsLikeRun(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 500; i++ {
+		files[fmt.Sprintf("/etc/f%03d", i)] = "h"
+	}
+	s1 := fimSnapshot("r1", files)
+	files["/etc/f007"] = "changed"
+	delete(files, "/etc/f100")
+	files["/etc/new"] = "n"
+	s2 := fimSnapshot("r2", files)
+
+	p1 := filepath.Join(dir, "1", "hash.ndjson")
+	p2 := filepath.Join(dir, "2", "hash.ndjson")
+	writeRows(t, p1, s1)
+	base, err := ReadNDJSON(p1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRows(t, p2, EncodeFIMDelta(s2, base, "../1/hash.ndjson", 0))
+	curr, err := ReadNDJSON(p2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, want := Run(base, curr, true, true)
+
+	sb, err := ReadSnapshot(p1, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Close()
+	sc, err := ReadSnapshot(p2, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	if !sb.Spilled() || !sc.Spilled() {
+		t.Fatalf("spilled: baseline=%v current=%v, want both", sb.Spilled(), sc.Spilled())
+	}
+	if n := sc.perRow[fimRowType].Len(); n != 500 {
+		t.Fatalf("current has %d file_hash rows after materializing, want 500", n)
+	}
+	hasDeltas, got, err := RunSnapshots(sb, sc, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasDeltas || string(got) != string(want) {
+		t.Fatalf("RunSnapshots output differs from Run:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSnapshotApplyIgnoreDropsPerRowType(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "hash.ndjson")
+	writeRows(t, p, fimSnapshot("r1", map[string]string{"/a": "1"}))
+	s, err := ReadSnapshot(p, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.ApplyIgnore(func(key string) bool { return key == "inventory:file_hash" })
+	if s.perRow[fimRowType] != nil {
+		t.Fatal("ignored file_hash rows kept")
+	}
+}
+
+func TestSchemaUpgradesSkipPerRowTypes(t *testing.T) {
+	for _, u := range schemaUpgrades {
+		for _, spec := range inventorySpecs {
+			if !spec.items && slices.Contains(u.reads, spec.rowType) {
+				t.Errorf("upgrade %s->%s reads %s, which ReadSnapshot does not upgrade", u.from, u.to, spec.rowType)
+			}
+		}
+	}
+}
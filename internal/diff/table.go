@@ -0,0 +1,101 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+)
+
+// WriteTable writes diff rows as an aligned table, one change per line, for
+// terminals and tickets where the sectioned summary is too long: what kind of
+// change, what it is about, and the baseline and current values. Of an item
+// that changed, only the fields that differ are shown.
+func WriteTable(w io.Writer, rows []Row) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHANGE\tSUBJECT\tBASELINE\tCURRENT")
+	for _, r := range rows {
+		change, _ := r["diff_type"].(string)
+		if status, _ := r["status"].(string); status != "" {
+			change += " " + status
+		}
+		base, curr := tableValues(r["baseline"], r["current"])
+		if version, _ := r["version"].(string); version != "" && curr == "-" {
+			curr = version
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", change, tableSubject(r), base, curr)
+	}
+	return tw.Flush()
+}
+
+// tableSubject names what a diff row is about.
+func tableSubject(r Row) string {
+	switch {
+	case r["key"] != nil:
+		return fmt.Sprintf("%v %s", r["row_type"], rawjson.Display(fmt.Sprint(r["key"])))
+	case r["field"] != nil:
+		return fmt.Sprint(r["field"])
+	case r["probe"] != nil:
+		return fmt.Sprint(r["probe"])
+	case r["label"] != nil:
+		return fmt.Sprintf("%v (%d items)", r["label"], len(getSlice(r, "changes")))
+	case r["codes"] != nil:
+		var codes []string
+		for _, c := range getSlice(r, "codes") {
+			codes = append(codes, fmt.Sprint(c))
+		}
+		return strings.Join(codes, ", ")
+	}
+	return strings.Join(FindingKeysFromDiffRow(r), ", ")
+}
+
+// tableValues formats a row's baseline and current values. Two objects are
+// reduced to the fields that differ; an object on one side only shows as
+// present.
+func tableValues(base, curr any) (string, string) {
+	bm, bok := base.(map[string]any)
+	cm, cok := curr.(map[string]any)
+	if bok && cok {
+		var fields []string
+		for k := range bm {
+			if fmt.Sprint(bm[k]) != fmt.Sprint(cm[k]) {
+				fields = append(fields, k)
+			}
+		}
+		for k := range cm {
+			if _, ok := bm[k]; !ok {
+				fields = append(fields, k)
+			}
+		}
+		sort.Strings(fields)
+		var b, c []string
+		for _, f := range fields {
+			b = append(b, f+"="+tableCell(bm[f]))
+			c = append(c, f+"="+tableCell(cm[f]))
+		}
+		return strings.Join(b, " "), strings.Join(c, " ")
+	}
+	return tableCell(base), tableCell(curr)
+}
+
+func tableCell(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "-"
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case map[string]any:
+		return "present"
+	case []any:
+		parts := make([]string, len(x))
+		for i, e := range x {
+			parts[i] = tableCell(e)
+		}
+		return strings.Join(parts, ",")
+	}
+	return displayValue(v)
+}
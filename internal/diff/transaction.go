@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+)
+
+// transactionRowType is the row `osaudit pkghook run` appends to a snapshot
+// it captured after a package manager transaction (see internal/pkghook).
+const transactionRowType = "package_transaction"
+
+type transactionPackage struct {
+	action, name, version, previous string
+}
+
+func transactionPackages(tx Row) []transactionPackage {
+	var pkgs []transactionPackage
+	for _, v := range getSlice(tx, "packages") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		p := transactionPackage{}
+		p.action, _ = m["action"].(string)
+		p.name, _ = m["name"].(string)
+		p.version, _ = m["version"].(string)
+		p.previous, _ = m["previous_version"].(string)
+		if p.name != "" {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs
+}
+
+// emitTransaction reports the package manager transaction the current
+// snapshot was captured after, unless the baseline was captured after the
+// same one. It is context for the changes, not a change, so it reports no
+// delta.
+func emitTransaction(baseTx, currTx Row, ndjson bool) {
+	if currTx == nil || (baseTx != nil && fmt.Sprint(baseTx["run_id"]) == fmt.Sprint(currTx["run_id"])) {
+		return
+	}
+	manager, _ := currTx["manager"].(string)
+	started, _ := currTx["started"].(string)
+	command, _ := currTx["command"].(string)
+	pkgs := transactionPackages(currTx)
+	if ndjson {
+		fields := map[string]any{"manager": manager, "packages": currTx["packages"]}
+		if started != "" {
+			fields["started"] = started
+		}
+		if command != "" {
+			fields["command"] = command
+		}
+		emitDiffRow("package_transaction", fields)
+		return
+	}
+	fmt.Println(i18n.T("diff.section.transaction"))
+	line := "  " + manager
+	if command != "" {
+		line += ": " + command
+	}
+	if started != "" {
+		line += " (" + started + ")"
+	}
+	fmt.Println(line)
+	for _, p := range pkgs {
+		v := p.version
+		if p.previous != "" {
+			v = p.previous + " → " + p.version
+		}
+		fmt.Printf("  * %s %s %s\n", p.action, p.name, v)
+	}
+	fmt.Println()
+}
+
+// AttributeTransaction groups the changes that belong to the packages of a
+// package_transaction row: an item named after a package, or whose vendor
+// token is the package name (its unit, binary, or launchd label), added or
+// changed by an install or upgrade, or removed by a removal. Each package
+// with changes becomes an InstallGroup, however small; the transaction says
+// what was installed, so there is nothing to guess. Changes that match no
+// package are returned in rest, in order.
+func AttributeTransaction(tx Row, changes []InventoryChange) (groups []InstallGroup, rest []InventoryChange) {
+	pkgs := transactionPackages(tx)
+	if len(pkgs) == 0 {
+		return nil, changes
+	}
+	manager, _ := tx["manager"].(string)
+	byName := make(map[string]int)
+	for i, p := range pkgs {
+		byName[normalizeVendor(p.name)] = i
+	}
+	members := make([][]InventoryChange, len(pkgs))
+	for _, ch := range changes {
+		i, ok := transactionMatch(byName, ch)
+		if !ok || (pkgs[i].action == "remove") != (ch.Status == "removed") {
+			rest = append(rest, ch)
+			continue
+		}
+		members[i] = append(members[i], ch)
+	}
+	for i, p := range pkgs {
+		if len(members[i]) == 0 {
+			continue
+		}
+		status := "installed"
+		switch p.action {
+		case "remove":
+			status = "removed"
+		case "upgrade", "downgrade":
+			status = "upgraded"
+		}
+		groups = append(groups, InstallGroup{
+			Vendor:  normalizeVendor(p.name),
+			Label:   p.name,
+			Version: p.version,
+			Status:  status,
+			Source:  manager,
+			Changes: members[i],
+		})
+	}
+	return groups, rest
+}
+
+func transactionMatch(byName map[string]int, ch InventoryChange) (int, bool) {
+	if name, ok := ch.Item["name"].(string); ok {
+		if i, ok := byName[normalizeVendor(name)]; ok {
+			return i, true
+		}
+	}
+	if vendor, _ := itemVendor(ch.Item); vendor != "" {
+		if i, ok := byName[vendor]; ok {
+			return i, true
+		}
+	}
+	// Keys such as "dpkg:nginx:amd64" carry the package name as a part.
+	for _, part := range strings.Split(ch.Key, ":") {
+		if i, ok := byName[normalizeVendor(part)]; ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
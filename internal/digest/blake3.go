@@ -0,0 +1,165 @@
+package digest
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// BLAKE3 in its default hash mode, after the reference implementation in the
+// BLAKE3 specification: input is split into 1 KiB chunks, each compressed in
+// 64-byte blocks, and chunk chaining values are merged pairwise into a
+// binary tree whose root gives the 32-byte digest.
+
+const (
+	blockLen = 64
+	chunkLen = 1024
+
+	flagChunkStart = 1 << 0
+	flagChunkEnd   = 1 << 1
+	flagParent     = 1 << 2
+	flagRoot       = 1 << 3
+)
+
+var iv = [8]uint32{0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A, 0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19}
+
+// output is a node whose compression is deferred until it is known whether
+// it is the root.
+type output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o *output) chainingValue() [8]uint32 {
+	s := compress(&o.cv, &o.block, o.counter, o.blockLen, o.flags)
+	return [8]uint32(s[:8])
+}
+
+func (o *output) rootBytes(out []byte) {
+	var buf [64]byte
+	for counter := uint64(0); len(out) > 0; counter++ {
+		s := compress(&o.cv, &o.block, counter, o.blockLen, o.flags|flagRoot)
+		for i, w := range s {
+			binary.LittleEndian.PutUint32(buf[4*i:], w)
+		}
+		out = out[copy(out, buf[:]):]
+	}
+}
+
+type chunkState struct {
+	cv               [8]uint32
+	counter          uint64
+	block            [blockLen]byte
+	blockLen         int
+	blocksCompressed int
+}
+
+func (c *chunkState) len() int { return blockLen*c.blocksCompressed + c.blockLen }
+
+func (c *chunkState) startFlag() uint32 {
+	if c.blocksCompressed == 0 {
+		return flagChunkStart
+	}
+	return 0
+}
+
+func (c *chunkState) update(p []byte) {
+	for len(p) > 0 {
+		// A full block is only compressed once more input arrives, since
+		// the last block of the chunk needs the end flag.
+		if c.blockLen == blockLen {
+			var m [16]uint32
+			words(&m, &c.block)
+			s := compress(&c.cv, &m, c.counter, blockLen, c.startFlag())
+			c.cv = [8]uint32(s[:8])
+			c.blocksCompressed++
+			c.block = [blockLen]byte{}
+			c.blockLen = 0
+		}
+		n := copy(c.block[c.blockLen:], p)
+		c.blockLen += n
+		p = p[n:]
+	}
+}
+
+func (c *chunkState) output() output {
+	o := output{cv: c.cv, counter: c.counter, blockLen: uint32(c.blockLen), flags: c.startFlag() | flagChunkEnd}
+	words(&o.block, &c.block)
+	return o
+}
+
+func parentOutput(left, right [8]uint32) output {
+	o := output{cv: iv, blockLen: blockLen, flags: flagParent}
+	copy(o.block[:8], left[:])
+	copy(o.block[8:], right[:])
+	return o
+}
+
+func words(m *[16]uint32, b *[blockLen]byte) {
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+}
+
+// blake3 is a hash.Hash computing 32-byte BLAKE3 digests.
+type blake3 struct {
+	chunk  chunkState
+	stack  [54][8]uint32 // enough for 2^64 bytes of input
+	stackN int
+}
+
+// NewBLAKE3 returns a hash.Hash computing the 32-byte BLAKE3 digest.
+func NewBLAKE3() hash.Hash {
+	h := &blake3{}
+	h.Reset()
+	return h
+}
+
+func (h *blake3) Reset() {
+	h.chunk = chunkState{cv: iv}
+	h.stackN = 0
+}
+
+func (h *blake3) Size() int      { return 32 }
+func (h *blake3) BlockSize() int { return blockLen }
+
+func (h *blake3) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if h.chunk.len() == chunkLen {
+			cv := h.chunk.output()
+			h.pushChunk(cv.chainingValue(), h.chunk.counter+1)
+			h.chunk = chunkState{cv: iv, counter: h.chunk.counter + 1}
+		}
+		take := min(chunkLen-h.chunk.len(), len(p))
+		h.chunk.update(p[:take])
+		p = p[take:]
+	}
+	return n, nil
+}
+
+// pushChunk adds a completed chunk's chaining value, first merging it with
+// every subtree it completes: one per trailing zero bit of total, the number
+// of chunks so far.
+func (h *blake3) pushChunk(cv [8]uint32, total uint64) {
+	for total&1 == 0 {
+		h.stackN--
+		p := parentOutput(h.stack[h.stackN], cv)
+		cv = p.chainingValue()
+		total >>= 1
+	}
+	h.stack[h.stackN] = cv
+	h.stackN++
+}
+
+func (h *blake3) Sum(b []byte) []byte {
+	o := h.chunk.output()
+	for i := h.stackN - 1; i >= 0; i-- {
+		o = parentOutput(h.stack[i], o.chainingValue())
+	}
+	var sum [32]byte
+	o.rootBytes(sum[:])
+	return append(b, sum[:]...)
+}
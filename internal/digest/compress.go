@@ -0,0 +1,474 @@
+package digest
+
+import "math/bits"
+
+// compress is the BLAKE3 compression function. It returns the full 16-word
+// state: the first 8 words are the new chaining value, and all 16 are output
+// bytes for the root node. The seven rounds are written out with the message
+// permutation applied as a fixed schedule, which keeps the state in
+// registers.
+func compress(cv *[8]uint32, m *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	v0, v1, v2, v3 := cv[0], cv[1], cv[2], cv[3]
+	v4, v5, v6, v7 := cv[4], cv[5], cv[6], cv[7]
+	v8, v9, v10, v11 := iv[0], iv[1], iv[2], iv[3]
+	v12, v13, v14, v15 := uint32(counter), uint32(counter>>32), blockLen, flags
+	// Round 1.
+	v0 += v4 + m[0]
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m[1]
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m[2]
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m[3]
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m[4]
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m[5]
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m[6]
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m[7]
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m[8]
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m[9]
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m[10]
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m[11]
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m[12]
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m[13]
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m[14]
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m[15]
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 2.
+	v0 += v4 + m[2]
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m[6]
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m[3]
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m[10]
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m[7]
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m[0]
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m[4]
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m[13]
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m[1]
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m[11]
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m[12]
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m[5]
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m[9]
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m[14]
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m[15]
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m[8]
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 3.
+	v0 += v4 + m[3]
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m[4]
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m[10]
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m[12]
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m[13]
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m[2]
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m[7]
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m[14]
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m[6]
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m[5]
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m[9]
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m[0]
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m[11]
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m[15]
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m[8]
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m[1]
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 4.
+	v0 += v4 + m[10]
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m[7]
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m[12]
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m[9]
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m[14]
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m[3]
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m[13]
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m[15]
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m[4]
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m[0]
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m[11]
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m[2]
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m[5]
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m[8]
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m[1]
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m[6]
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 5.
+	v0 += v4 + m[12]
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m[13]
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m[9]
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m[11]
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m[15]
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m[10]
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m[14]
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m[8]
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m[7]
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m[2]
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m[5]
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m[3]
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m[0]
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m[1]
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m[6]
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m[4]
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 6.
+	v0 += v4 + m[9]
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m[14]
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m[11]
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m[5]
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m[8]
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m[12]
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m[15]
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m[1]
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m[13]
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m[3]
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m[0]
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m[10]
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m[2]
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m[6]
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m[4]
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m[7]
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 7.
+	v0 += v4 + m[11]
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m[15]
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m[5]
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m[0]
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m[1]
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m[9]
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m[8]
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m[6]
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m[14]
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m[10]
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m[2]
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m[12]
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m[3]
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m[4]
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m[7]
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m[13]
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	return [16]uint32{
+		v0 ^ v8, v1 ^ v9, v2 ^ v10, v3 ^ v11, v4 ^ v12, v5 ^ v13, v6 ^ v14, v7 ^ v15,
+		v8 ^ cv[0], v9 ^ cv[1], v10 ^ cv[2], v11 ^ cv[3], v12 ^ cv[4], v13 ^ cv[5], v14 ^ cv[6], v15 ^ cv[7],
+	}
+}
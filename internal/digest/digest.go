@@ -0,0 +1,87 @@
+// Package digest is the one place osaudit picks a hash algorithm for content
+// fingerprints: file-integrity snapshots and their cache, snapshot row
+// de-duplication, and delta fingerprints. BLAKE3 is the default because it is
+// several times faster than SHA-256 without hardware support; SHA-256 stays
+// available where compliance requires a FIPS-approved algorithm.
+//
+// Every stored digest records the algorithm that made it (a file_hash row's
+// hash_alg, a cache entry's alg), so digests made under other settings or by
+// older versions, which were all SHA-256, are never compared as if alike.
+package digest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+)
+
+// Algorithm names a hash algorithm, as recorded next to its digests.
+type Algorithm string
+
+const (
+	SHA256 Algorithm = "sha256"
+	BLAKE3 Algorithm = "blake3"
+)
+
+// Default is the algorithm used unless another is configured.
+const Default = BLAKE3
+
+// Legacy is the algorithm of digests recorded without one: osaudit wrote
+// only SHA-256 before the algorithm was selectable.
+const Legacy = SHA256
+
+var constructors = map[Algorithm]func() hash.Hash{
+	SHA256: sha256.New,
+	BLAKE3: NewBLAKE3,
+}
+
+// Algorithms lists the supported algorithms, the default first.
+func Algorithms() []Algorithm { return []Algorithm{BLAKE3, SHA256} }
+
+// Parse returns the algorithm named s, case-insensitively. "sha-256" is
+// accepted for sha256.
+func Parse(s string) (Algorithm, error) {
+	a := Algorithm(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "-", ""))
+	if _, ok := constructors[a]; !ok {
+		return "", fmt.Errorf("unknown hash algorithm %q (want blake3 or sha256)", s)
+	}
+	return a, nil
+}
+
+// FromEnv returns the algorithm set by OSAUDIT_HASH_ALG, or Default.
+func FromEnv() (Algorithm, error) {
+	s, ok := os.LookupEnv(config.HashAlgEnv)
+	if !ok || strings.TrimSpace(s) == "" {
+		return Default, nil
+	}
+	a, err := Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", config.HashAlgEnv, err)
+	}
+	return a, nil
+}
+
+// New returns a new hash.Hash for a. An algorithm not listed in Algorithms,
+// as a snapshot or cache from elsewhere may record, is an error.
+func (a Algorithm) New() (hash.Hash, error) {
+	newHash, ok := constructors[a]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %q", string(a))
+	}
+	return newHash(), nil
+}
+
+// Sum returns the hex digest of data under a.
+func (a Algorithm) Sum(data []byte) (string, error) {
+	h, err := a.New()
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
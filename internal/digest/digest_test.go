@@ -0,0 +1,68 @@
+package digest
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Official BLAKE3 test vectors: input byte i is i % 251.
+var blake3Vectors = []struct {
+	n    int
+	want string
+}{
+	{0, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+	{1, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+	{64, "4eed7141ea4a5cd4b788606bd23f46e212af9cacebacdc7d1f4c6dc7f2511b98"},
+	{1023, "10108970eeda3eb932baac1428c7a2163b0e924c9a9e25b35bba72b28f70bd11"},
+	{1024, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+	{1025, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+	{2049, "5f4d72f40d7a5f82b15ca2b2e44b1de3c2ef86c426c95c1af0b6879522563030"},
+	{3073, "7124b49501012f81cc7f11ca069ec9226cecb8a2c850cfe644e327d22d3e1cd3"},
+	{8193, "bab6c09cb8ce8cf459261398d2e7aef35700bf488116ceb94a36d0f5f1b7bc3b"},
+	{102400, "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"},
+}
+
+func TestBLAKE3Vectors(t *testing.T) {
+	for _, v := range blake3Vectors {
+		data := make([]byte, v.n)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		if got, err := BLAKE3.Sum(data); err != nil || got != v.want {
+			t.Errorf("BLAKE3(%d bytes) = %s, %v; want %s", v.n, got, err, v.want)
+		}
+		// Uneven writes must not change the result, and Sum must not
+		// change the state.
+		h := NewBLAKE3()
+		for p := data; len(p) > 0; {
+			k := min(len(p), 1+len(p)%700)
+			h.Write(p[:k])
+			p = p[k:]
+			h.Sum(nil)
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != v.want {
+			t.Errorf("BLAKE3(%d bytes, in pieces) = %s, want %s", v.n, got, v.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Algorithm{"blake3": BLAKE3, "BLAKE3": BLAKE3, "sha256": SHA256, "SHA-256": SHA256} {
+		if got, err := Parse(in); err != nil || got != want {
+			t.Errorf("Parse(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := Parse("md5"); err == nil {
+		t.Error("Parse(md5) succeeded")
+	}
+	if got, err := SHA256.Sum([]byte("hello\n")); err != nil || got != "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03" {
+		t.Errorf("SHA256.Sum = %s, %v", got, err)
+	}
+	// An algorithm recorded by a snapshot is not trusted to be known.
+	if h, err := Algorithm("md5").New(); err == nil {
+		t.Errorf("New(md5) = %v, want an error", h)
+	}
+	if _, err := Algorithm("md5").Sum(nil); err == nil {
+		t.Error("Sum(md5) succeeded")
+	}
+}
@@ -0,0 +1,70 @@
+// Package dotfiles holds the watched dotfiles: files the config collector
+// records the hash and mode bits of, so diff can say which of them changed
+// between runs without any snapshot storing their contents.
+//
+// Paths live one per line in <config dir>/dotfiles, or in $OSAUDIT_DOTFILES,
+// which replaces the file:
+//   - blank lines and lines starting with # are skipped
+//   - a path starting with ~/ is under the audited user's home
+//   - any other path must be absolute
+//   - *, ?, and [...] match within one path component, e.g. ~/.config/git/*
+//
+// Directories are not descended into; name the files, or glob them.
+package dotfiles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+)
+
+// File is the watched dotfiles list's name in the config directory.
+const File = "dotfiles"
+
+// Parse reads watched paths; name prefixes errors. ~ is left for the audit
+// scripts to expand, since they may audit another user's home.
+func Parse(name, text string) ([]string, error) {
+	var out []string
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rest, home := strings.CutPrefix(line, "~/")
+		if !home && !strings.HasPrefix(line, "/") {
+			return nil, fmt.Errorf("%s: line %d: %q is neither absolute nor under ~/", name, i+1, line)
+		}
+		if rest = strings.Trim(rest, "/"); rest == "" {
+			return nil, fmt.Errorf("%s: line %d: %q names no file", name, i+1, line)
+		}
+		if _, err := filepath.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("%s: line %d: %q: %w", name, i+1, line, err)
+		}
+		out = append(out, line)
+	}
+	return out, nil
+}
+
+// Load returns the watched paths from $OSAUDIT_DOTFILES, or else from the
+// dotfiles file in the config directory. A missing file watches nothing.
+func Load() ([]string, error) {
+	if text, ok := os.LookupEnv(config.DotfilesEnv); ok && strings.TrimSpace(text) != "" {
+		return Parse(config.DotfilesEnv, text)
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, nil
+	}
+	p := filepath.Join(dir, File)
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(p, string(data))
+}
@@ -0,0 +1,51 @@
+package dotfiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse("dotfiles", strings.Join([]string{
+		"# shell",
+		"~/.zshrc",
+		"",
+		"  ~/.config/git/*  ",
+		"/etc/zshenv",
+	}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"~/.zshrc", "~/.config/git/*", "/etc/zshenv"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{".zshrc", "~", "~/", "/", "~/[.zshrc"} {
+		if _, err := Parse("dotfiles", bad); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("OSAUDIT_HOME", dir)
+	t.Setenv(config.DotfilesEnv, "")
+
+	if got, err := Load(); err != nil || got != nil {
+		t.Errorf("Load() without a file = %q, %v; want nothing", got, err)
+	}
+	os.WriteFile(filepath.Join(dir, File), []byte("~/.gitconfig\n"), 0o644)
+	if got, err := Load(); err != nil || !reflect.DeepEqual(got, []string{"~/.gitconfig"}) {
+		t.Errorf("Load() = %q, %v", got, err)
+	}
+	t.Setenv(config.DotfilesEnv, "/etc/zshenv\n~/.zshrc")
+	if got, err := Load(); err != nil || !reflect.DeepEqual(got, []string{"/etc/zshenv", "~/.zshrc"}) {
+		t.Errorf("Load() with %s = %q, %v", config.DotfilesEnv, got, err)
+	}
+}
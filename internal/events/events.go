@@ -0,0 +1,145 @@
+// Package events is the in-process bus that connects a run's stages. The
+// runner, the audit scripts, diff, and policy checks publish what happens as
+// it happens; notification sinks, exporters, and progress displays subscribe
+// to the kinds they care about instead of re-reading the run's output files
+// afterwards.
+//
+// The audit scripts cannot call into Go, so the runner hands them a pipe as
+// file descriptor $OSAUDIT_EVENT_FD and Forward publishes the event lines
+// they write to it (emit_event in audit/<os>/lib/common.sh).
+package events
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+)
+
+// FDEnv is the environment variable that tells the audit scripts which file
+// descriptor to write event lines to. It is unset when nothing listens.
+const FDEnv = "OSAUDIT_EVENT_FD"
+
+// Kind names what happened.
+type Kind string
+
+// Event kinds, roughly in the order a run produces them.
+const (
+	RunStarted        Kind = "run_started"        // the runner started an audit script
+	CollectorStarted  Kind = "collector_started"  // the full audit started a collector
+	ProbeFinished     Kind = "probe_finished"     // a named probe exited; ExitCode is its status
+	SectionFinished   Kind = "section_finished"   // a report section is done; ElapsedMS is its time
+	CollectorFinished Kind = "collector_finished" // the full audit finished a collector; ElapsedMS is its time
+	RunFinished       Kind = "run_finished"       // the audit script exited; ExitCode is its status
+	FindingEmitted    Kind = "finding_emitted"    // a diff found a change; Finding is its key
+	PolicyFailed      Kind = "policy_failed"      // a policy rule was violated
+	NotifyPosted      Kind = "notify_posted"      // a notification was POSTed to a notify.urls endpoint; Message is the error, if any
+)
+
+// Event is one thing that happened. Kind and Time are always set; the other
+// fields are set for the kinds their comments name.
+type Event struct {
+	Kind      Kind      `json:"event"`
+	Time      time.Time `json:"time"`
+	AuditID   string    `json:"audit_id,omitempty"` // the command ID, e.g. "full", when known
+	RunID     string    `json:"run_id,omitempty"`
+	Collector string    `json:"collector,omitempty"`  // collector_started, collector_finished
+	Probe     string    `json:"probe,omitempty"`      // probe_finished
+	Section   string    `json:"section,omitempty"`    // section_finished
+	ExitCode  int       `json:"exit_code,omitempty"`  // probe_finished, run_finished
+	ElapsedMS int64     `json:"elapsed_ms,omitempty"` // section_finished, collector_finished, run_finished
+	Finding   string    `json:"finding,omitempty"`    // finding_emitted, e.g. "inventory:listening_ports"
+	Policy    string    `json:"policy,omitempty"`     // policy_failed: policy name and rule ID
+	Severity  string    `json:"severity,omitempty"`   // policy_failed
+	Message   string    `json:"message,omitempty"`    // run_finished and notify_posted errors, policy_failed reasons
+}
+
+// Bus delivers published events to subscribers. The zero value is ready to
+// use, and a nil *Bus drops everything, so publishers need no checks.
+//
+// Events are delivered one at a time, in publish order, on the goroutine that
+// published the first of a burst. A subscriber may publish or subscribe; its
+// events are delivered after the current one reaches every subscriber.
+type Bus struct {
+	mu         sync.Mutex
+	subs       []*subscription
+	queue      []Event
+	delivering bool
+}
+
+type subscription struct {
+	kinds []Kind
+	fn    func(Event)
+}
+
+// Subscribe calls fn for every published event of the given kinds, or of
+// every kind when none are given, until the returned function is called.
+func (b *Bus) Subscribe(fn func(Event), kinds ...Kind) (unsubscribe func()) {
+	s := &subscription{kinds: kinds, fn: fn}
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		b.subs = slices.DeleteFunc(b.subs, func(x *subscription) bool { return x == s })
+		b.mu.Unlock()
+	}
+}
+
+// Publish delivers e to the subscribers of its kind, stamping Time when it
+// is zero.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	b.queue = append(b.queue, e)
+	if b.delivering {
+		b.mu.Unlock()
+		return
+	}
+	b.delivering = true
+	for len(b.queue) > 0 {
+		e := b.queue[0]
+		b.queue = b.queue[1:]
+		subs := slices.Clone(b.subs)
+		b.mu.Unlock()
+		for _, s := range subs {
+			if len(s.kinds) == 0 || slices.Contains(s.kinds, e.Kind) {
+				s.fn(e)
+			}
+		}
+		b.mu.Lock()
+	}
+	b.delivering = false
+	b.mu.Unlock()
+}
+
+// Forward publishes the event lines read from r, one JSON Event per line, until
+// r ends. Fields the line leaves empty are taken from defaults, so a script
+// need not know its audit ID. Lines that do not parse are skipped: a garbled
+// progress line must not fail a run.
+func Forward(b *Bus, r io.Reader, defaults Event) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if rawjson.Unmarshal(scanner.Bytes(), &e) != nil || e.Kind == "" {
+			continue
+		}
+		if e.AuditID == "" {
+			e.AuditID = defaults.AuditID
+		}
+		if e.RunID == "" {
+			e.RunID = defaults.RunID
+		}
+		b.Publish(e)
+	}
+	return scanner.Err()
+}
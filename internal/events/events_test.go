@@ -0,0 +1,98 @@
+package events
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	var b Bus
+	var all, findings []string
+	b.Subscribe(func(e Event) {
+		all = append(all, string(e.Kind))
+		if e.Kind == RunFinished {
+			b.Publish(Event{Kind: FindingEmitted, Finding: "inventory:listening_ports"}) // from a subscriber
+		}
+	})
+	stop := b.Subscribe(func(e Event) {
+		if e.Time.IsZero() {
+			t.Error("Publish did not stamp Time")
+		}
+		findings = append(findings, e.Finding)
+	}, FindingEmitted)
+
+	b.Publish(Event{Kind: RunStarted})
+	b.Publish(Event{Kind: RunFinished})
+	stop()
+	b.Publish(Event{Kind: FindingEmitted, Finding: "new_warnings:x"})
+
+	want := []string{"run_started", "run_finished", "finding_emitted", "finding_emitted"}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("all = %v, want %v", all, want)
+	}
+	if want := []string{"inventory:listening_ports"}; !reflect.DeepEqual(findings, want) {
+		t.Errorf("findings = %v, want %v (none after unsubscribing)", findings, want)
+	}
+
+	var nilBus *Bus
+	nilBus.Publish(Event{Kind: RunStarted}) // must not panic
+}
+
+func TestBusConcurrentPublish(t *testing.T) {
+	var b Bus
+	var mu sync.Mutex
+	inside, n := 0, 0
+	b.Subscribe(func(Event) {
+		mu.Lock()
+		inside++
+		if inside > 1 {
+			t.Error("events delivered concurrently")
+		}
+		mu.Unlock()
+		mu.Lock()
+		inside--
+		n++
+		mu.Unlock()
+	})
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				b.Publish(Event{Kind: ProbeFinished})
+			}
+		}()
+	}
+	wg.Wait()
+	if n != 800 {
+		t.Errorf("delivered %d events, want 800", n)
+	}
+}
+
+func TestForward(t *testing.T) {
+	var b Bus
+	var got []Event
+	b.Subscribe(func(e Event) { got = append(got, e) })
+	lines := strings.Join([]string{
+		`{"event":"probe_finished","probe":"network.ss_listen","exit_code":1}`,
+		`not json`,
+		`{"probe":"no kind"}`,
+		``,
+		`{"event":"section_finished","run_id":"r2","section":"dns_configuration","elapsed_ms":12}`,
+	}, "\n")
+	if err := Forward(&b, strings.NewReader(lines), Event{AuditID: "network", RunID: "r1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Forward published %d events, want 2: %+v", len(got), got)
+	}
+	if e := got[0]; e.Kind != ProbeFinished || e.Probe != "network.ss_listen" || e.ExitCode != 1 || e.AuditID != "network" || e.RunID != "r1" {
+		t.Errorf("first event = %+v", e)
+	}
+	if e := got[1]; e.Kind != SectionFinished || e.RunID != "r2" || e.ElapsedMS != 12 {
+		t.Errorf("second event = %+v, want its own run ID kept", e)
+	}
+}
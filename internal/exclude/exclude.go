@@ -0,0 +1,193 @@
+// Package exclude holds the paths every filesystem-walking collector skips:
+// the storage walker and duplicate finder in the audit scripts, and the file
+// hasher behind file-integrity diffs.
+//
+// Patterns live one per line in <config dir>/exclude, or in $OSAUDIT_EXCLUDE,
+// which replaces the file. The syntax is a subset of .gitignore:
+//   - blank lines and lines starting with # are skipped
+//   - a trailing / matches directories only
+//   - a pattern without a / matches a file or directory name at any depth
+//   - a pattern starting with / or ~/ matches an absolute path
+//   - any other pattern with a / matches that path under any directory
+//   - * and ? do not cross a /, and a ** component matches any number of
+//     directories
+//   - fstype:<type> skips every filesystem of that type, as the OS names it
+//     (nfs, smbfs, fuse.sshfs)
+//
+// An excluded directory is not descended into. Negated (!) patterns are not
+// supported: find(1) cannot re-include a path under a pruned directory.
+package exclude
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+)
+
+// File is the exclude file's name in the config directory.
+const File = "exclude"
+
+// Pattern is one parsed exclude line.
+type Pattern struct {
+	Text     string // the line as written, trimmed
+	fsType   string // set for fstype:<type> lines, which match no path
+	dirOnly  bool
+	anchored bool
+	parts    []string // slash-separated components, ~ expanded
+}
+
+// Patterns is an exclude list. The zero value excludes nothing.
+type Patterns []Pattern
+
+// Parse reads exclude lines. home expands a leading ~/; name prefixes errors.
+func Parse(name, text, home string) (Patterns, error) {
+	var out Patterns
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := parsePattern(line, home)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", name, i+1, err)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func parsePattern(line, home string) (Pattern, error) {
+	p := Pattern{Text: line}
+	if strings.HasPrefix(line, "!") {
+		return p, fmt.Errorf("negated pattern %q is not supported", line)
+	}
+	if t, ok := strings.CutPrefix(line, "fstype:"); ok {
+		if p.fsType = strings.TrimSpace(t); p.fsType == "" {
+			return p, fmt.Errorf("pattern %q names no filesystem type", line)
+		}
+		return p, nil
+	}
+	s := line
+	if strings.HasSuffix(s, "/") {
+		p.dirOnly = true
+		s = strings.TrimRight(s, "/")
+	}
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		if home == "" {
+			return p, fmt.Errorf("pattern %q needs a home directory", line)
+		}
+		s = filepath.ToSlash(home) + s[1:]
+	}
+	if s == "" {
+		return p, fmt.Errorf("pattern %q matches every path", line)
+	}
+	p.anchored = strings.HasPrefix(s, "/")
+	for _, part := range strings.Split(strings.Trim(s, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if _, err := path.Match(part, ""); err != nil {
+			return p, fmt.Errorf("pattern %q: %w", line, err)
+		}
+		p.parts = append(p.parts, part)
+	}
+	return p, nil
+}
+
+// Load returns the exclude patterns from $OSAUDIT_EXCLUDE, or else from the
+// exclude file in the config directory. A missing file excludes nothing.
+func Load() (Patterns, error) {
+	home, _ := os.UserHomeDir()
+	if text, ok := os.LookupEnv(config.ExcludeEnv); ok && strings.TrimSpace(text) != "" {
+		return Parse(config.ExcludeEnv, text, home)
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, nil
+	}
+	p := filepath.Join(dir, File)
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(p, string(data), home)
+}
+
+// Lines returns the patterns as written, one per line, the form
+// $OSAUDIT_EXCLUDE hands to the audit scripts.
+func (ps Patterns) Lines() string {
+	lines := make([]string, len(ps))
+	for i, p := range ps {
+		lines[i] = p.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FSTypes returns the filesystem types the fstype: lines skip.
+func (ps Patterns) FSTypes() []string {
+	var types []string
+	for _, p := range ps {
+		if p.fsType != "" {
+			types = append(types, p.fsType)
+		}
+	}
+	return types
+}
+
+// Match reports whether the absolute path is excluded. dir says whether it
+// names a directory; directory-only patterns match nothing else. Parents are
+// not checked: walkers skip an excluded directory instead of asking about
+// everything under it.
+func (ps Patterns) Match(name string, dir bool) bool {
+	if len(ps) == 0 {
+		return false
+	}
+	parts := strings.Split(strings.Trim(filepath.ToSlash(name), "/"), "/")
+	for _, p := range ps {
+		if p.fsType != "" || p.dirOnly && !dir {
+			continue
+		}
+		if p.anchored {
+			if matchParts(p.parts, parts) {
+				return true
+			}
+			continue
+		}
+		for i := range parts {
+			if matchParts(p.parts, parts[i:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchParts matches pattern components against path components exactly;
+// "**" matches zero or more of them.
+func matchParts(pat, parts []string) bool {
+	if len(pat) == 0 {
+		return len(parts) == 0
+	}
+	if pat[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchParts(pat[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pat[0], parts[0]); !ok {
+		return false
+	}
+	return matchParts(pat[1:], parts[1:])
+}
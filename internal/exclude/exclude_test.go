@@ -0,0 +1,89 @@
+package exclude
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+)
+
+func TestMatch(t *testing.T) {
+	ps, err := Parse("exclude", strings.Join([]string{
+		"# build trees",
+		"node_modules/",
+		"*.iso",
+		"",
+		"target/debug",
+		"/mnt/backup",
+		"~/Library/Caches/",
+		"/srv/**/tmp",
+		"fstype: nfs4",
+	}, "\n"), "/home/u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 7 {
+		t.Fatalf("Parse() = %d patterns, want 7", len(ps))
+	}
+	if got := ps.FSTypes(); !reflect.DeepEqual(got, []string{"nfs4"}) {
+		t.Errorf("FSTypes() = %v, want [nfs4]", got)
+	}
+	tests := []struct {
+		path string
+		dir  bool
+		want bool
+	}{
+		{"/home/u/src/app/node_modules", true, true},
+		{"/home/u/src/app/node_modules", false, false}, // directories only
+		{"/home/u/Downloads/ubuntu.iso", false, true},
+		{"/home/u/Downloads/iso", false, false},
+		{"/home/u/rust/target/debug", true, true},
+		{"/home/u/rust/target/release", true, false},
+		{"/mnt/backup", true, true},
+		{"/data/mnt/backup", true, false}, // anchored
+		{"/home/u/Library/Caches", true, true},
+		{"/home/v/Library/Caches", true, false},
+		{"/srv/tmp", true, true},
+		{"/srv/a/b/tmp", true, true},
+		{"/srv/a/b/tmpx", true, false},
+	}
+	for _, tt := range tests {
+		if got := ps.Match(tt.path, tt.dir); got != tt.want {
+			t.Errorf("Match(%q, dir=%v) = %v, want %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+	if Patterns(nil).Match("/anything", true) {
+		t.Error("nil Patterns matched")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{"!keep.iso", "/", "bad[", "fstype:"} {
+		if _, err := Parse("exclude", "ok\n"+line, "/home/u"); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("Parse(%q) error = %v, want one naming line 2", line, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("OSAUDIT_HOME", dir)
+	t.Setenv(config.ExcludeEnv, "")
+	if ps, err := Load(); err != nil || ps != nil {
+		t.Fatalf("Load() without a file = %v, %v", ps, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, File), []byte("*.vmdk\n.cache/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ps, err := Load()
+	if err != nil || ps.Lines() != "*.vmdk\n.cache/" {
+		t.Fatalf("Load() = %q, %v", ps.Lines(), err)
+	}
+	t.Setenv(config.ExcludeEnv, "*.img")
+	if ps, err = Load(); err != nil || ps.Lines() != "*.img" {
+		t.Errorf("Load() with %s = %q, %v; want the variable to replace the file", config.ExcludeEnv, ps.Lines(), err)
+	}
+}
@@ -0,0 +1,99 @@
+// Package exitcode defines osaudit's exit-code contract. Every subcommand exits
+// with one of these codes, so scripts can branch on the outcome without parsing
+// output. `osaudit exit-codes` prints the table.
+//
+// When several outcomes apply, the most specific one wins: policy failure, then
+// drift, then partial run.
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+const (
+	OK            = 0 // success, nothing to report
+	Error         = 1 // runtime error: unreadable file, failed audit script
+	Usage         = 2 // invalid arguments or input files
+	Drift         = 3 // diff, baseline diff, run-scheduled, pkghook run, or ci found changes; crosscheck found differences
+	PolicyFailure = 4 // check or ci found a failing control, rule, or policy violation; policy test failed; bench went over budget; selftest missed a change
+	Partial       = 5 // the run finished, but some collectors (e.g. plugins) failed
+	Interrupted   = 6 // SIGINT or SIGTERM stopped the run; a partial snapshot was kept
+)
+
+// Entry documents one code.
+type Entry struct {
+	Code        int    `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Contract lists every exit code in order.
+var Contract = []Entry{
+	{OK, "ok", "Success; no drift or failures"},
+	{Error, "error", "Runtime error, e.g. an unreadable snapshot or a failed audit script"},
+	{Usage, "usage", "Invalid arguments, unknown subcommand, or invalid input file"},
+	{Drift, "drift", "diff, baseline diff, run-scheduled, pkghook run, or ci found changes between snapshots, or crosscheck found differences from expected state"},
+	{PolicyFailure, "policy_failure", "check or ci found a failing benchmark control, custom rule, or policy violation, policy test found a case with unexpected verdicts, bench exceeded the performance budget, or selftest missed an injected change"},
+	{Partial, "partial", "The run finished but some collectors failed; results are incomplete"},
+	{Interrupted, "interrupted", "SIGINT or SIGTERM stopped the run; the snapshot so far was kept and marked interrupted"},
+}
+
+// Name returns the contract name of code, or "unknown".
+func Name(code int) string {
+	for _, e := range Contract {
+		if e.Code == code {
+			return e.Name
+		}
+	}
+	return "unknown"
+}
+
+// ExitError pairs an error with the exit code it should produce.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// Sentinel outcomes that are not failures of osaudit itself.
+var (
+	ErrDrift         = &ExitError{Drift, errors.New("drift detected")}
+	ErrPolicyFailure = &ExitError{PolicyFailure, errors.New("policy check failed")}
+	ErrPartial       = &ExitError{Partial, errors.New("partial run")}
+	ErrInterrupted   = &ExitError{Interrupted, errors.New("interrupted")}
+)
+
+// Wrap attaches code to err. A nil err stays nil.
+func Wrap(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// Usagef returns a usage error.
+func Usagef(format string, args ...any) error {
+	return &ExitError{Code: Usage, Err: fmt.Errorf(format, args...)}
+}
+
+// Of maps err to its exit code: nil is OK, an *ExitError carries its own code,
+// and a failed child process maps to Usage when it exited 2 and Error
+// otherwise. Anything else is Error.
+func Of(err error) int {
+	if err == nil {
+		return OK
+	}
+	var ee *ExitError
+	if errors.As(err, &ee) {
+		return ee.Code
+	}
+	var pe *exec.ExitError
+	if errors.As(err, &pe) && pe.ExitCode() == Usage {
+		return Usage
+	}
+	return Error
+}
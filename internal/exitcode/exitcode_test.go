@@ -0,0 +1,58 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestContractIsStable(t *testing.T) {
+	want := []string{"ok", "error", "usage", "drift", "policy_failure", "partial", "interrupted"}
+	if len(Contract) != len(want) {
+		t.Fatalf("Contract has %d codes, want %d", len(Contract), len(want))
+	}
+	for i, e := range Contract {
+		if e.Code != i || e.Name != want[i] || e.Description == "" {
+			t.Errorf("Contract[%d] = %+v, want code %d named %q", i, e, i, want[i])
+		}
+	}
+	if Name(Drift) != "drift" || Name(42) != "unknown" {
+		t.Error("Name mismatch")
+	}
+}
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, OK},
+		{"plain", errors.New("boom"), Error},
+		{"usage", Usagef("bad flag %s", "--x"), Usage},
+		{"wrapped sentinel", fmt.Errorf("%w: plugins failed", ErrPartial), Partial},
+		{"wrap", Wrap(PolicyFailure, errors.New("violations")), PolicyFailure},
+	}
+	for _, tt := range tests {
+		if got := Of(tt.err); got != tt.want {
+			t.Errorf("Of(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if Wrap(Drift, nil) != nil {
+		t.Error("Wrap(nil) must stay nil")
+	}
+}
+
+func TestOfChildProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	for code, want := range map[string]int{"2": Usage, "3": Error, "70": Error} {
+		err := exec.Command("sh", "-c", "exit "+code).Run()
+		if got := Of(err); got != want {
+			t.Errorf("Of(child exit %s) = %d, want %d", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package hashing
+
+import "io/fs"
+
+func fileID(fs.FileInfo) (fileKey, bool) { return fileKey{}, false }
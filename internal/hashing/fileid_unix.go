@@ -0,0 +1,17 @@
+//go:build unix
+
+package hashing
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID returns the device and inode of a directory or file.
+func fileID(info fs.FileInfo) (fileKey, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
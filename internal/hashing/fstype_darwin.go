@@ -0,0 +1,19 @@
+package hashing
+
+import "syscall"
+
+// fsType returns the type of the filesystem path is on, e.g. apfs or smbfs.
+func fsType(path string) string {
+	var st syscall.Statfs_t
+	if syscall.Statfs(path, &st) != nil {
+		return ""
+	}
+	b := make([]byte, 0, len(st.Fstypename))
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
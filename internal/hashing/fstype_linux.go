@@ -0,0 +1,61 @@
+package hashing
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// fsType returns the type of the filesystem path is on: that of the longest
+// mount point in /proc/self/mountinfo that contains it.
+func fsType(path string) string {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	var best, bestType string
+	for _, line := range strings.Split(string(data), "\n") {
+		// id parent major:minor root mount-point options [optional...] - type source super-options
+		fields := strings.Fields(line)
+		sep := -1
+		for i, f := range fields {
+			if f == "-" && i >= 6 {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+1 >= len(fields) {
+			continue
+		}
+		mp := unescapeMountinfo(fields[4])
+		if !underMount(path, mp) || len(mp) < len(best) {
+			continue
+		}
+		best, bestType = mp, fields[sep+1]
+	}
+	return bestType
+}
+
+func underMount(path, mp string) bool {
+	return mp == "/" || path == mp || strings.HasPrefix(path, mp+"/")
+}
+
+// unescapeMountinfo decodes the octal escapes (\040 for a space) mountinfo
+// uses in paths.
+func unescapeMountinfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
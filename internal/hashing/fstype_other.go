@@ -0,0 +1,5 @@
+//go:build !linux && !darwin
+
+package hashing
+
+func fsType(string) string { return "" }
@@ -0,0 +1,276 @@
+// Package hashing hashes files for file-integrity monitoring and binary
+// allowlisting. Files are read by a bounded pool of workers that share one
+// read-rate limit, so a scan of thousands of files keeps the disk usable, and
+// files whose size and modification time match the previous scan reuse their
+// recorded hash instead of being read again.
+package hashing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/digest"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// Result is the outcome for one file.
+type Result struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Mode    fs.FileMode
+	Digest  string // hex digest under the run's Options.Algorithm
+	Reused  bool   // hash taken from the cache; the file was not read
+	Err     error
+}
+
+// Progress is reported after every file.
+type Progress struct {
+	Done, Total int
+	Bytes       int64 // bytes read so far
+	Reused      int
+	Failed      int
+}
+
+// Options tunes a Run. The zero value hashes with digest.Default and one
+// worker per CPU, without a rate limit, reusing cached hashes.
+type Options struct {
+	Algorithm digest.Algorithm
+	Workers   int
+	// BytesPerSecond caps the combined read rate of all workers; 0 means no cap.
+	BytesPerSecond int64
+	// Paranoid re-reads every file even when size and mtime are unchanged.
+	Paranoid bool
+	// Progress, if set, is called after each file from a single goroutine.
+	Progress func(Progress)
+}
+
+// Run hashes paths and returns one result per path, sorted by path. cache may
+// be nil; when it is not, it is updated with the new hashes. Run stops early,
+// returning ctx.Err(), when ctx is cancelled. An unknown opts.Algorithm is an
+// error.
+func Run(ctx context.Context, paths []string, cache Cache, opts Options) ([]Result, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	alg := opts.Algorithm
+	if alg == "" {
+		alg = digest.Default
+	}
+	if _, err := alg.New(); err != nil {
+		return nil, err
+	}
+	var lim *limiter
+	if opts.BytesPerSecond > 0 {
+		lim = &limiter{rate: float64(opts.BytesPerSecond)}
+	}
+
+	jobs := make(chan string)
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 256*1024)
+			for path := range jobs {
+				results <- hashFile(path, alg, cache, opts.Paranoid, lim, buf)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, p := range paths {
+			select {
+			case jobs <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	out := make([]Result, 0, len(paths))
+	prog := Progress{Total: len(paths)}
+	for r := range results {
+		out = append(out, r)
+		prog.Done++
+		switch {
+		case r.Err != nil:
+			prog.Failed++
+		case r.Reused:
+			prog.Reused++
+		default:
+			prog.Bytes += r.Size
+		}
+		if opts.Progress != nil {
+			opts.Progress(prog)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	for _, r := range out {
+		if r.Err == nil && cache != nil {
+			cache[r.Path] = Entry{Size: r.Size, ModTime: r.ModTime, Alg: alg, Digest: r.Digest}
+		}
+	}
+	return out, nil
+}
+
+// hashFile hashes one file with alg. Workers do not write to cache, so
+// lookups need no lock.
+func hashFile(path string, alg digest.Algorithm, cache Cache, paranoid bool, lim *limiter, buf []byte) Result {
+	r := Result{Path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if !info.Mode().IsRegular() {
+		r.Err = &fs.PathError{Op: "hash", Path: path, Err: errors.New("not a regular file")}
+		return r
+	}
+	r.Size, r.ModTime, r.Mode = info.Size(), info.ModTime(), info.Mode()
+	if e, ok := cache[path]; ok && !paranoid && e.Size == r.Size && e.ModTime.Equal(r.ModTime) {
+		if sum, ok := e.digest(alg); ok {
+			r.Digest, r.Reused = sum, true
+			return r
+		}
+	}
+
+	h, err := alg.New()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	var src io.Reader = f
+	if lim != nil {
+		src = &throttledReader{r: f, lim: lim}
+	}
+	if _, err := io.CopyBuffer(h, src, buf); err != nil {
+		r.Err = err
+		return r
+	}
+	r.Digest = hex.EncodeToString(h.Sum(nil))
+	return r
+}
+
+// limiter spaces reads so that all workers together stay under rate bytes per
+// second.
+type limiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time // when the next read may start
+}
+
+func (l *limiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+type throttledReader struct {
+	r   io.Reader
+	lim *limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.lim.wait(n)
+	}
+	return n, err
+}
+
+// Entry is a cached hash, valid while the file keeps its size and mtime.
+// Caches written before the algorithm was selectable hold SHA256 only.
+type Entry struct {
+	Size    int64            `json:"size"`
+	ModTime time.Time        `json:"mtime"`
+	Alg     digest.Algorithm `json:"alg,omitempty"`
+	Digest  string           `json:"digest,omitempty"`
+	SHA256  string           `json:"sha256,omitempty"`
+}
+
+// digest returns the entry's digest under alg, if it has one.
+func (e Entry) digest(alg digest.Algorithm) (string, bool) {
+	if e.Digest != "" && e.Alg == alg {
+		return e.Digest, true
+	}
+	if e.SHA256 != "" && alg == digest.SHA256 {
+		return e.SHA256, true
+	}
+	return "", false
+}
+
+// Cache maps paths to their last hash.
+type Cache map[string]Entry
+
+// LoadCache reads a cache file. A missing file is an empty cache.
+func LoadCache(path string) (Cache, error) {
+	c := Cache{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := rawjson.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Save writes the cache atomically, keeping only the given paths so entries
+// for deleted or out-of-scope files do not accumulate.
+func (c Cache) Save(path string, keep []string) error {
+	kept := make(Cache, len(keep))
+	for _, p := range keep {
+		if e, ok := c[p]; ok {
+			kept[p] = e
+		}
+	}
+	data, err := rawjson.Marshal(kept)
+	if err != nil {
+		return err
+	}
+	if err := writeguard.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := writeguard.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return writeguard.Rename(tmp, path)
+}
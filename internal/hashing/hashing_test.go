@@ -0,0 +1,207 @@
+package hashing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/digest"
+)
+
+// sha256("hello\n")
+const helloSHA = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+func writeTree(t *testing.T) (dir string, files []string) {
+	t.Helper()
+	dir = t.TempDir()
+	for _, name := range []string{"a.txt", "sub/b.txt", "sub/deeper/c.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("hello\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	return dir, Walk([]string{dir, filepath.Join(dir, "sub")}, WalkOptions{})
+}
+
+func TestWalk(t *testing.T) {
+	dir, files := writeTree(t)
+	if len(files) != 3 {
+		t.Fatalf("Walk() = %v, want 3 regular files without duplicates or symlinks", files)
+	}
+	var errs []error
+	Walk([]string{filepath.Join(dir, "missing")}, WalkOptions{OnErr: func(_ string, err error) { errs = append(errs, err) }})
+	if len(errs) != 1 {
+		t.Errorf("Walk(missing) reported %v", errs)
+	}
+	skipDeeper := func(path string, dir bool) bool { return dir && filepath.Base(path) == "deeper" }
+	if files := Walk([]string{dir}, WalkOptions{Skip: skipDeeper}); len(files) != 2 {
+		t.Errorf("Walk(skip deeper) = %v, want a.txt and sub/b.txt", files)
+	}
+}
+
+func TestWalkSkips(t *testing.T) {
+	dir, _ := writeTree(t)
+	var skipped []Skipped
+	onSkip := func(s Skipped) { skipped = append(skipped, s) }
+	files := Walk([]string{filepath.Join(dir, "sub"), dir}, WalkOptions{OneFilesystem: true, OnSkip: onSkip})
+	if len(files) != 3 || len(skipped) != 0 {
+		t.Fatalf("Walk(overlapping roots, one filesystem) = %v, skipped %v; want 3 files and no skips", files, skipped)
+	}
+	fstype := fsType(dir)
+	if fstype == "" {
+		t.Skip("filesystem type not available on this platform")
+	}
+	files = Walk([]string{dir}, WalkOptions{SkipFSTypes: []string{fstype}, OnSkip: onSkip})
+	if want := []Skipped{{Path: dir, Reason: SkipFSType, FSType: fstype}}; len(files) != 0 || !reflect.DeepEqual(skipped, want) {
+		t.Errorf("Walk(skip %s) = %v, skipped %v; want no files and %v", fstype, files, skipped, want)
+	}
+}
+
+func TestRunIncremental(t *testing.T) {
+	_, files := writeTree(t)
+	cache := Cache{}
+	var calls int
+	first, err := Run(context.Background(), files, cache, Options{Algorithm: digest.SHA256, Workers: 2, Progress: func(Progress) { calls++ }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(files) {
+		t.Errorf("Progress called %d times, want %d", calls, len(files))
+	}
+	for _, r := range first {
+		if r.Err != nil || r.Digest != helloSHA || r.Reused {
+			t.Errorf("first run: %+v", r)
+		}
+	}
+
+	// Change one file but keep its size; a new mtime makes it stale.
+	changed := files[0]
+	if err := os.WriteFile(changed, []byte("HELLO\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(changed, later, later)
+
+	second, err := Run(context.Background(), files, cache, Options{Algorithm: digest.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range second {
+		if wantReused := r.Path != changed; r.Reused != wantReused {
+			t.Errorf("second run %s: Reused = %v, want %v", r.Path, r.Reused, wantReused)
+		}
+	}
+	if second[0].Digest == helloSHA {
+		t.Error("changed file kept its old hash")
+	}
+
+	paranoid, err := Run(context.Background(), files, cache, Options{Algorithm: digest.SHA256, Paranoid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range paranoid {
+		if r.Reused {
+			t.Errorf("paranoid run reused the cache for %s", r.Path)
+		}
+	}
+}
+
+func TestRunCacheKeepsAlgorithmsApart(t *testing.T) {
+	_, files := writeTree(t)
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A cache entry from before the algorithm was recorded is SHA-256.
+	cache := Cache{files[0]: {Size: info.Size(), ModTime: info.ModTime(), SHA256: helloSHA}}
+
+	results, err := Run(context.Background(), files[:1], cache, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results[0]; r.Reused || r.Digest == helloSHA || len(r.Digest) != 64 {
+		t.Errorf("blake3 run over a sha256 cache: %+v", r)
+	}
+	if e := cache[files[0]]; e.Alg != digest.BLAKE3 || e.Digest != results[0].Digest {
+		t.Errorf("cache entry after blake3 run = %+v", e)
+	}
+
+	cache[files[0]] = Entry{Size: info.Size(), ModTime: info.ModTime(), SHA256: helloSHA}
+	results, err = Run(context.Background(), files[:1], cache, Options{Algorithm: digest.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results[0]; !r.Reused || r.Digest != helloSHA {
+		t.Errorf("sha256 run over a legacy cache entry: %+v", r)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	results, err := Run(context.Background(), []string{filepath.Join(dir, "gone"), dir}, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Err == nil {
+			t.Errorf("%s: want an error", r.Path)
+		}
+	}
+	if !strings.Contains(results[0].Err.Error(), "not a regular file") {
+		t.Errorf("directory error = %v", results[0].Err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, []string{dir}, nil, Options{}); err != context.Canceled {
+		t.Errorf("Run(cancelled) err = %v", err)
+	}
+	if _, err := Run(context.Background(), []string{dir}, nil, Options{Algorithm: "md5"}); err == nil {
+		t.Error("Run with an unknown algorithm succeeded")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i := 0; i < 4; i++ {
+		path := filepath.Join(dir, string(rune('a'+i)))
+		if err := os.WriteFile(path, make([]byte, 64*1024), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	// 256 KiB at 1 MiB/s is ~250ms however many workers read it.
+	start := time.Now()
+	if _, err := Run(context.Background(), files, nil, Options{Workers: 4, BytesPerSecond: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("throttled run took %s, want about 250ms", elapsed)
+	}
+}
+
+func TestCacheSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "hashes.json")
+	c := Cache{"/keep": {Size: 1, SHA256: "x"}, "/drop": {Size: 2}}
+	if err := c.Save(path, []string{"/keep"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadCache(path)
+	if err != nil || len(got) != 1 || got["/keep"].SHA256 != "x" {
+		t.Errorf("LoadCache() = %v, %v", got, err)
+	}
+	if c, err := LoadCache(filepath.Join(t.TempDir(), "absent")); err != nil || len(c) != 0 {
+		t.Errorf("LoadCache(missing) = %v, %v", c, err)
+	}
+}
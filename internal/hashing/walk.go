@@ -0,0 +1,129 @@
+package hashing
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// Reasons a subtree is left out of a walk.
+const (
+	SkipOtherFilesystem = "other_filesystem" // WalkOptions.OneFilesystem
+	SkipFSType          = "fstype"           // listed in WalkOptions.SkipFSTypes
+	SkipVisited         = "visited"          // reached again through a bind mount or loop
+)
+
+// WalkOptions controls Walk. The zero value walks everything once.
+type WalkOptions struct {
+	// Skip leaves out paths for which it returns true, and everything under a
+	// skipped directory.
+	Skip func(path string, dir bool) bool
+	// OneFilesystem keeps each root's walk on the filesystem the root is on.
+	OneFilesystem bool
+	// SkipFSTypes lists filesystem types, as the OS names them (nfs, smbfs,
+	// fuse.sshfs), that are not descended into.
+	SkipFSTypes []string
+	// OnErr is told about unreadable directories, which are skipped.
+	OnErr func(path string, err error)
+	// OnSkip is told about each subtree left out for one of the Skip* reasons,
+	// so totals that come up short can be explained.
+	OnSkip func(Skipped)
+}
+
+// Skipped is a subtree Walk did not descend into.
+type Skipped struct {
+	Path   string
+	Reason string // SkipOtherFilesystem, SkipFSType, or SkipVisited
+	FSType string // empty when the OS does not say
+}
+
+// fileKey identifies a directory across paths: its device and inode.
+type fileKey struct{ dev, ino uint64 }
+
+// Walk returns the regular files under roots, sorted. Symlinks are not
+// followed, and a directory reached again by another path, e.g. through a
+// bind mount, is walked only once. Device and inode tracking needs a Unix;
+// elsewhere only overlapping roots are collapsed.
+func Walk(roots []string, opts WalkOptions) []string {
+	var files []string
+	visited := map[fileKey]bool{}
+	fsTypes := map[uint64]string{}
+	typeOf := func(path string, dev uint64) string {
+		t, ok := fsTypes[dev]
+		if !ok {
+			t = fsType(path)
+			fsTypes[dev] = t
+		}
+		return t
+	}
+	skip := func(path, reason, fstype string) error {
+		if opts.OnSkip != nil {
+			opts.OnSkip(Skipped{Path: path, Reason: reason, FSType: fstype})
+		}
+		return fs.SkipDir
+	}
+	for _, root := range roots {
+		var rootDev uint64
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && opts.Skip != nil && opts.Skip(path, d.IsDir()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if err != nil {
+				if opts.OnErr != nil {
+					opts.OnErr(path, err)
+				}
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				info, err := d.Info()
+				if err != nil {
+					return nil
+				}
+				key, ok := fileID(info)
+				if !ok {
+					return nil
+				}
+				if path == root {
+					rootDev = key.dev
+				}
+				if visited[key] {
+					if slices.Contains(roots, path) {
+						return fs.SkipDir // overlapping roots
+					}
+					return skip(path, SkipVisited, "")
+				}
+				visited[key] = true
+				if path != root && key.dev == rootDev {
+					return nil
+				}
+				t := typeOf(path, key.dev)
+				if slices.Contains(opts.SkipFSTypes, t) {
+					return skip(path, SkipFSType, t)
+				}
+				if opts.OneFilesystem && path != root {
+					return skip(path, SkipOtherFilesystem, t)
+				}
+				return nil
+			}
+			if d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	sort.Strings(files)
+	out := files[:0]
+	for _, f := range files {
+		if len(out) == 0 || f != out[len(out)-1] { // overlapping roots
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,199 @@
+// Package i18n holds the message catalogs for user-facing report and
+// notification strings. The embedded "en" catalog is the base; other locales
+// come from pluggable loaders (embedded locales/*.json, then
+// ~/.osaudit/locales/<lang>.json registered by the CLI). Missing keys fall back
+// to en, then to the key itself, so a partial translation never hides output.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// BaseLocale is the locale every other catalog falls back to.
+const BaseLocale = "en"
+
+// Loader returns the catalog for lang, or nil when it has none. Catalogs map
+// message keys to fmt format strings.
+type Loader func(lang string) (map[string]string, error)
+
+var (
+	mu      sync.RWMutex
+	loaders = []Loader{embeddedLoader}
+	base    map[string]string
+	active  map[string]string
+	locale  = BaseLocale
+	inited  bool
+)
+
+// RegisterLoader adds a loader consulted after those already registered; its
+// messages override earlier ones. Call SetLocale afterwards to reload.
+func RegisterLoader(l Loader) {
+	mu.Lock()
+	defer mu.Unlock()
+	loaders = append(loaders, l)
+}
+
+// DirLoader loads <dir>/<lang>.json. Missing files are not an error.
+func DirLoader(dir string) Loader {
+	return func(lang string) (map[string]string, error) {
+		return readCatalog(os.ReadFile, filepath.Join(dir, lang+".json"))
+	}
+}
+
+func embeddedLoader(lang string) (map[string]string, error) {
+	return readCatalog(func(name string) ([]byte, error) { return localeFS.ReadFile(name) }, "locales/"+lang+".json")
+}
+
+func readCatalog(read func(string) ([]byte, error), name string) (map[string]string, error) {
+	data, err := read(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cat map[string]string
+	if err := json.Unmarshal(data, &cat); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return cat, nil
+}
+
+// DetectLocale returns the locale requested by OSAUDIT_LANG, LC_ALL,
+// LC_MESSAGES, or LANG, normalized to e.g. "de" or "pt-BR". "C" and "POSIX"
+// mean the base locale.
+func DetectLocale() string {
+	for _, env := range []string{"OSAUDIT_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+			return Normalize(v)
+		}
+	}
+	return BaseLocale
+}
+
+// Normalize turns POSIX locale names ("pt_BR.UTF-8@euro") into tags ("pt-BR").
+func Normalize(v string) string {
+	if i := strings.IndexAny(v, ".@"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" || v == "C" || v == "POSIX" {
+		return BaseLocale
+	}
+	lang, region, _ := strings.Cut(strings.ReplaceAll(v, "_", "-"), "-")
+	if region == "" {
+		return strings.ToLower(lang)
+	}
+	return strings.ToLower(lang) + "-" + strings.ToUpper(region)
+}
+
+// SetLocale loads the catalogs for lang ("de-AT" also loads "de"). It returns
+// an error only when a loader fails; an unknown locale quietly uses en.
+func SetLocale(lang string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return setLocaleLocked(Normalize(lang))
+}
+
+func setLocaleLocked(lang string) error {
+	newBase, err := loadLocked(BaseLocale)
+	if err != nil {
+		return err
+	}
+	chain := []string{lang}
+	if l, _, ok := strings.Cut(lang, "-"); ok {
+		chain = []string{l, lang}
+	}
+	merged := make(map[string]string)
+	for _, l := range chain {
+		if l == BaseLocale {
+			continue
+		}
+		cat, err := loadLocked(l)
+		if err != nil {
+			return err
+		}
+		for k, v := range cat {
+			merged[k] = v
+		}
+	}
+	base, active, locale, inited = newBase, merged, lang, true
+	return nil
+}
+
+func loadLocked(lang string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, l := range loaders {
+		cat, err := l(lang)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range cat {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// Locale returns the active locale.
+func Locale() string {
+	ensureInit()
+	mu.RLock()
+	defer mu.RUnlock()
+	return locale
+}
+
+// Lookup returns the message for key in the active locale (falling back to en)
+// and whether any catalog defines it.
+func Lookup(key string) (string, bool) {
+	ensureInit()
+	mu.RLock()
+	defer mu.RUnlock()
+	if msg, ok := active[key]; ok {
+		return msg, true
+	}
+	msg, ok := base[key]
+	return msg, ok
+}
+
+// T formats the message for key in the active locale.
+func T(key string, args ...any) string {
+	msg, ok := Lookup(key)
+	if !ok {
+		msg = key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// ensureInit loads the environment's locale on first use. Loader errors fall
+// back to the embedded base catalog so output is never lost.
+func ensureInit() {
+	mu.RLock()
+	done := inited
+	mu.RUnlock()
+	if done {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if inited {
+		return
+	}
+	if err := setLocaleLocked(DetectLocale()); err != nil {
+		base, _ = embeddedLoader(BaseLocale)
+		active, locale, inited = nil, BaseLocale, true
+	}
+}
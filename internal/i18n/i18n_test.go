@@ -0,0 +1,64 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"de_DE.UTF-8": "de-DE",
+		"pt_br@euro":  "pt-BR",
+		"fr":          "fr",
+		"C":           "en",
+		"POSIX":       "en",
+	} {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSetLocale_DirLoaderWithFallback(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("de.json", `{"diff.no_changes": "Keine Änderungen.", "notify.changes.body": "Audit %s hat Änderungen gefunden."}`)
+	write("de-AT.json", `{"diff.no_changes": "Nix geändert."}`)
+
+	saved := loaders
+	t.Cleanup(func() {
+		mu.Lock()
+		loaders = saved
+		mu.Unlock()
+		SetLocale(BaseLocale)
+	})
+	RegisterLoader(DirLoader(dir))
+
+	if err := SetLocale("de_AT.UTF-8"); err != nil {
+		t.Fatal(err)
+	}
+	if got := T("diff.no_changes"); got != "Nix geändert." {
+		t.Errorf("region override: got %q", got)
+	}
+	if got := T("notify.changes.body", "full"); got != "Audit full hat Änderungen gefunden." {
+		t.Errorf("language fallback: got %q", got)
+	}
+	if got := T("diff.section.storage"); got != "## Storage delta" {
+		t.Errorf("en fallback: got %q", got)
+	}
+	if got := T("no.such.key"); got != "no.such.key" {
+		t.Errorf("missing key: got %q", got)
+	}
+
+	if err := SetLocale("ja"); err != nil {
+		t.Fatal(err)
+	}
+	if got := T("diff.no_changes"); got != "No changes detected between baseline and current." {
+		t.Errorf("unknown locale must use en, got %q", got)
+	}
+}
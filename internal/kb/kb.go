@@ -0,0 +1,122 @@
+// Package kb is the built-in knowledge base: one article per probe (or probe
+// family) with a stable documentation anchor. Findings, benchmark controls, and
+// policy rules link to articles so reports can deep-link into docs/findings.md
+// or, with OSAUDIT_DOCS_URL, into an organization's own runbooks.
+package kb
+
+import (
+	_ "embed"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+)
+
+//go:embed articles.json
+var articlesJSON []byte
+
+// DefaultBaseURL is the published location of docs/findings.md.
+const DefaultBaseURL = "https://github.com/kareemsasa/operating-system-audit/blob/main/docs/findings.md"
+
+// Article documents one probe or probe family.
+type Article struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Summary     string   `json:"summary"`
+	Remediation string   `json:"remediation"`
+	Aliases     []string `json:"aliases,omitempty"`
+}
+
+var (
+	articles []Article
+	byID     map[string]*Article
+)
+
+func init() {
+	if err := json.Unmarshal(articlesJSON, &articles); err != nil {
+		panic("kb: parse articles.json: " + err.Error())
+	}
+	byID = make(map[string]*Article, len(articles))
+	for i := range articles {
+		a := &articles[i]
+		byID[a.ID] = a
+		for _, alias := range a.Aliases {
+			byID[alias] = a
+		}
+	}
+}
+
+// Articles returns every article, sorted by ID.
+func Articles() []Article {
+	out := append([]Article(nil), articles...)
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Lookup finds the article for a probe name, row field ("security_config.sip"),
+// or finding key ("probe_failure:config.spctl_status"). Unknown probes fall
+// back to the closest documented family, e.g. "config.foo_bar" → "config".
+func Lookup(id string) (Article, bool) {
+	id = normalize(id)
+	for id != "" {
+		if a, ok := byID[id]; ok {
+			return *a, true
+		}
+		cut := strings.LastIndexAny(id, "._")
+		if cut < 0 {
+			break
+		}
+		id = id[:cut]
+	}
+	return Article{}, false
+}
+
+// Anchor returns the stable markdown anchor for an article ID.
+func Anchor(id string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(id) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// BaseURL returns $OSAUDIT_DOCS_URL when set, otherwise DefaultBaseURL.
+func BaseURL() string {
+	if u := strings.TrimSpace(os.Getenv("OSAUDIT_DOCS_URL")); u != "" {
+		return u
+	}
+	return DefaultBaseURL
+}
+
+// URL returns the documentation link for an article. A base URL containing
+// "{anchor}" or "{id}" is used as a template; otherwise "#<anchor>" is appended.
+func URL(a Article) string {
+	base := BaseURL()
+	if strings.Contains(base, "{anchor}") || strings.Contains(base, "{id}") {
+		return strings.NewReplacer("{anchor}", Anchor(a.ID), "{id}", a.ID).Replace(base)
+	}
+	return base + "#" + Anchor(a.ID)
+}
+
+// DocURL returns the documentation link for id, or "" when nothing documents it.
+func DocURL(id string) string {
+	a, ok := Lookup(id)
+	if !ok {
+		return ""
+	}
+	return URL(a)
+}
+
+// normalize maps finding keys onto article IDs: the "probe_failure:" and
+// "new_warnings:" kinds name probes directly, other kinds use "type.field".
+func normalize(id string) string {
+	id = strings.TrimSpace(id)
+	for _, kind := range []string{"probe_failure:", "new_warnings:"} {
+		id = strings.TrimPrefix(id, kind)
+	}
+	return strings.Replace(id, ":", ".", 1)
+}
@@ -0,0 +1,53 @@
+package kb
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestArticles_AnchorsDocumented(t *testing.T) {
+	doc, err := os.ReadFile("../../docs/findings.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range Articles() {
+		if a.Title == "" || a.Summary == "" || a.Remediation == "" {
+			t.Errorf("article %s is incomplete", a.ID)
+		}
+		if !strings.Contains(string(doc), `<a id="`+Anchor(a.ID)+`"></a>`) {
+			t.Errorf("docs/findings.md lacks anchor for %s (%s)", a.ID, Anchor(a.ID))
+		}
+	}
+}
+
+func TestLookup_AliasesAndFallback(t *testing.T) {
+	cases := map[string]string{
+		"config.spctl_status":                 "config.spctl_status",
+		"probe_failure:config.csrutil_status": "config.csrutil_status",
+		"security_config:filevault":           "config.fdesetup_status",
+		"inventory:listening_ports":           "network.lsof_listen",
+		"config.brew_list_cask":               "config",
+	}
+	for id, want := range cases {
+		a, ok := Lookup(id)
+		if !ok || a.ID != want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q", id, a.ID, ok, want)
+		}
+	}
+	if _, ok := Lookup("run_context:user"); ok {
+		t.Error("Lookup(run_context:user) found an article, want none")
+	}
+}
+
+func TestURL_BaseOverride(t *testing.T) {
+	a, _ := Lookup("config.spctl_status")
+	t.Setenv("OSAUDIT_DOCS_URL", "")
+	if got, want := URL(a), DefaultBaseURL+"#config-spctl-status"; got != want {
+		t.Errorf("URL() = %q, want %q", got, want)
+	}
+	t.Setenv("OSAUDIT_DOCS_URL", "https://wiki.example.com/runbooks/{anchor}")
+	if got, want := URL(a), "https://wiki.example.com/runbooks/config-spctl-status"; got != want {
+		t.Errorf("URL() = %q, want %q", got, want)
+	}
+}
@@ -2,8 +2,9 @@ package latest
 
 import (
 	"encoding/json"
-	"os"
 	"path/filepath"
+
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
 )
 
 // RunMeta holds metadata for a single audit run, emitted by scripts and consumed by the scheduler.
@@ -20,24 +21,33 @@ type RunMeta struct {
 // WriteLatestManifest writes a "latest" manifest for the given audit ID.
 // The manifest is written atomically (tmp write + rename) to the audit root,
 // e.g. output/<audit-id>/.latest.json, not inside the timestamped run dir.
-// repoRoot is the repository root; meta.Dir is repo-relative (e.g. output/execution-audit/20260302-100907).
+// repoRoot is the repository root; meta.Dir is repo-relative (e.g. output/execution-audit/20260302-100907),
+// or absolute when the output directory is outside the tree.
 func WriteLatestManifest(repoRoot, auditID string, meta RunMeta) error {
 	if auditID == "" || meta.Dir == "" {
 		return nil
 	}
 	auditRoot := filepath.Dir(meta.Dir)
-	manifestPath := filepath.Join(repoRoot, auditRoot, ".latest.json")
-	dir := filepath.Dir(manifestPath)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	if !filepath.IsAbs(auditRoot) {
+		auditRoot = filepath.Join(repoRoot, auditRoot)
+	}
+	return WriteManifest(filepath.Join(auditRoot, ".latest.json"), meta)
+}
+
+// WriteManifest writes meta to path atomically, creating its directory. It
+// is for manifests beside .latest.json, such as the baseline of each network
+// `run-scheduled --per-network` has seen.
+func WriteManifest(path string, meta RunMeta) error {
+	if err := writeguard.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
 	data, err := json.Marshal(meta)
 	if err != nil {
 		return err
 	}
-	tmpPath := manifestPath + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+	tmpPath := path + ".tmp"
+	if err := writeguard.WriteFile(tmpPath, data, 0o644); err != nil {
 		return err
 	}
-	return os.Rename(tmpPath, manifestPath)
+	return writeguard.Rename(tmpPath, path)
 }
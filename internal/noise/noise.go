@@ -0,0 +1,199 @@
+// Package noise learns which finding types a user keeps acknowledging without
+// acting on them, and turns them into ignore rules so diffs stay relevant.
+//
+// State lives in the osaudit config directory (see internal/config):
+//   - acks.json:   per finding key, how often and when it was acknowledged
+//   - ignore.json: ignore rules applied to diff input before comparison
+package noise
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+const (
+	acksFile   = "acks.json"
+	ignoreFile = "ignore.json"
+
+	// DefaultMinAcks is how many separate acknowledgements make a finding type a
+	// suppression candidate.
+	DefaultMinAcks = 3
+
+	// maxAckRuns caps the run IDs remembered per finding key.
+	maxAckRuns = 20
+)
+
+// Ack records how a finding key has been acknowledged over time.
+type Ack struct {
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Runs      []string  `json:"runs,omitempty"`
+}
+
+// AckStore maps finding keys (see diff.FindingKeysFromDiffRow) to their acks.
+type AckStore map[string]*Ack
+
+// Record acknowledges keys for runID. Acknowledging the same run twice is a no-op,
+// so re-running `osaudit ack record` does not inflate counts.
+func (s AckStore) Record(keys []string, runID string, now time.Time) {
+	for _, k := range keys {
+		a, ok := s[k]
+		if !ok {
+			a = &Ack{FirstSeen: now}
+			s[k] = a
+		}
+		if runID != "" && containsString(a.Runs, runID) {
+			continue
+		}
+		a.Count++
+		a.LastSeen = now
+		if runID != "" {
+			a.Runs = append(a.Runs, runID)
+			if len(a.Runs) > maxAckRuns {
+				a.Runs = a.Runs[len(a.Runs)-maxAckRuns:]
+			}
+		}
+	}
+}
+
+// Rule suppresses every finding whose key matches Pattern (path.Match syntax,
+// e.g. "inventory:listening_ports" or "probe_failure:config.*").
+type Rule struct {
+	Pattern string    `json:"pattern"`
+	Reason  string    `json:"reason,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Rules is an ordered ignore rule set.
+type Rules []Rule
+
+// Match reports whether any rule matches key. Malformed patterns never match.
+func (rs Rules) Match(key string) bool {
+	for _, r := range rs {
+		if ok, err := path.Match(r.Pattern, key); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Add appends a rule for pattern unless one already exists. Returns true if added.
+func (rs *Rules) Add(pattern, reason string, now time.Time) bool {
+	for _, r := range *rs {
+		if r.Pattern == pattern {
+			return false
+		}
+	}
+	*rs = append(*rs, Rule{Pattern: pattern, Reason: reason, Created: now})
+	return true
+}
+
+// Suggestion is a finding key acknowledged often enough to be worth ignoring.
+type Suggestion struct {
+	Key      string
+	Count    int
+	LastSeen time.Time
+}
+
+// Reason is the rule reason recorded when a suggestion is applied.
+func (s Suggestion) Reason() string {
+	return fmt.Sprintf("acknowledged %d times without action", s.Count)
+}
+
+// Suggest returns keys acknowledged at least minAcks times that no rule already
+// suppresses, most-acknowledged first.
+func Suggest(acks AckStore, rules Rules, minAcks int) []Suggestion {
+	if minAcks < 1 {
+		minAcks = DefaultMinAcks
+	}
+	var out []Suggestion
+	for k, a := range acks {
+		if a.Count < minAcks || rules.Match(k) {
+			continue
+		}
+		out = append(out, Suggestion{Key: k, Count: a.Count, LastSeen: a.LastSeen})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+// LoadAcks reads acks.json from dir. A missing file yields an empty store.
+func LoadAcks(dir string) (AckStore, error) {
+	acks := AckStore{}
+	if err := readJSON(filepath.Join(dir, acksFile), &acks); err != nil {
+		return nil, err
+	}
+	return acks, nil
+}
+
+// SaveAcks writes acks.json to dir atomically.
+func SaveAcks(dir string, acks AckStore) error {
+	return writeJSON(filepath.Join(dir, acksFile), acks)
+}
+
+// LoadRules reads ignore.json from dir. A missing file yields no rules.
+func LoadRules(dir string) (Rules, error) {
+	var rules Rules
+	if err := readJSON(filepath.Join(dir, ignoreFile), &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SaveRules writes ignore.json to dir atomically.
+func SaveRules(dir string, rules Rules) error {
+	return writeJSON(filepath.Join(dir, ignoreFile), rules)
+}
+
+func readJSON(p string, v any) error {
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", p, err)
+	}
+	return nil
+}
+
+func writeJSON(p string, v any) error {
+	if err := writeguard.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	tmpPath := p + ".tmp"
+	if err := writeguard.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return writeguard.Rename(tmpPath, p)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package noise
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuggest_RepeatedAcksBecomeRules(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	acks := AckStore{}
+	for _, run := range []string{"r1", "r2", "r2", "r3"} {
+		acks.Record([]string{"inventory:listening_ports"}, run, now)
+	}
+	acks.Record([]string{"security_config:firewall"}, "r3", now)
+
+	if got := acks["inventory:listening_ports"].Count; got != 3 {
+		t.Fatalf("Count = %d, want 3 (duplicate run ignored)", got)
+	}
+	suggestions := Suggest(acks, nil, DefaultMinAcks)
+	if len(suggestions) != 1 || suggestions[0].Key != "inventory:listening_ports" {
+		t.Fatalf("Suggest() = %+v, want only listening_ports", suggestions)
+	}
+
+	var rules Rules
+	if !rules.Add(suggestions[0].Key, suggestions[0].Reason(), now) || rules.Add(suggestions[0].Key, "dup", now) {
+		t.Fatal("Add must add once and reject duplicates")
+	}
+	if got := Suggest(acks, rules, DefaultMinAcks); len(got) != 0 {
+		t.Errorf("Suggest() after rule = %+v, want none", got)
+	}
+}
+
+func TestRules_GlobMatchAndRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	rules := Rules{{Pattern: "probe_failure:config.*"}, {Pattern: "[bad"}}
+	if err := SaveRules(dir, rules); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Match("probe_failure:config.spctl_status") {
+		t.Error("glob rule must match probe_failure:config.spctl_status")
+	}
+	if loaded.Match("probe_failure:network.lsof") {
+		t.Error("glob rule must not match other probes")
+	}
+	acks, err := LoadAcks(t.TempDir())
+	if err != nil || len(acks) != 0 {
+		t.Errorf("LoadAcks(empty dir) = %v, %v; want empty store", acks, err)
+	}
+}
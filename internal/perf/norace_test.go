@@ -0,0 +1,6 @@
+//go:build !race
+
+package perf
+
+// raceEnabled skips the time budget under -race, which slows diff several-fold.
+const raceEnabled = false
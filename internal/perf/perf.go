@@ -0,0 +1,288 @@
+// Package perf measures diff throughput on synthetic snapshots and holds the
+// performance budget that `osaudit bench` and the Go benchmarks enforce. Fleets
+// push per-file data (large_file and, later, file-integrity rows) through diff,
+// so snapshots of 100k+ rows must stay cheap to compare.
+package perf
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// Stages measured by Measure, in order.
+const (
+	StageRead       = "read"        // ReadNDJSON of both snapshots
+	StageDiff       = "diff"        // human-readable diff
+	StageDiffNDJSON = "diff_ndjson" // NDJSON diff
+)
+
+var stages = []string{StageRead, StageDiff, StageDiffNDJSON}
+
+// DefaultRows is the snapshot size `osaudit bench` uses by default.
+const DefaultRows = 100_000
+
+// Options controls snapshot generation.
+type Options struct {
+	Rows  int     // approximate rows per snapshot
+	Seed  int64   // generator seed; equal seeds give equal snapshots
+	Churn float64 // fraction of per-file rows added, removed, or changed in current
+}
+
+// Synthesize builds a baseline and a current snapshot shaped like a full audit:
+// the scalar summary rows, inventories with a few hundred items each, warnings,
+// and probe failures, filled up to opts.Rows with per-file large_file rows.
+func Synthesize(opts Options) (baseline, current []diff.Row) {
+	if opts.Churn <= 0 {
+		opts.Churn = 0.01
+	}
+	r := rand.New(rand.NewSource(opts.Seed))
+
+	scalar := func(bump int) []diff.Row {
+		return []diff.Row{
+			{"type": "meta", "run_id": "synthetic", "schema_version": diff.SchemaVersion},
+			{"type": "summary", "home_bytes": 500_000_000_000 + bump*1_000_000, "downloads_bytes": 2_000_000_000, "desktop_bytes": 100_000_000, "trash_bytes": 50_000_000 + bump},
+			{"type": "counts", "large_files": opts.Rows, "node_modules": 40 + bump, "broken_symlinks": 12, "git_repos": 80, "venv_cache": 3},
+			{"type": "security_config", "filevault": true, "sip": true, "gatekeeper": bump == 0, "firewall": true},
+			{"type": "run_context", "is_root": false, "is_interactive": true, "sandboxed": false},
+		}
+	}
+	inventory := func(rowType, key string, n int, extra func(i int) diff.Row) diff.Row {
+		items := make([]any, n)
+		for i := range items {
+			item := map[string]any{key: fmt.Sprintf("%s-%05d", rowType, i)}
+			for k, v := range extra(i) {
+				item[k] = v
+			}
+			items[i] = item
+		}
+		return diff.Row{"type": rowType, "items": items}
+	}
+	inventories := func(shift int) []diff.Row {
+		return []diff.Row{
+			inventory("launch_daemons", "label", 300, func(i int) diff.Row {
+				if i%25 == 0 {
+					i += shift
+				}
+				return diff.Row{"program": fmt.Sprintf("/usr/libexec/d%d", i)}
+			}),
+			inventory("kernel_modules", "module", 200, func(int) diff.Row { return nil }),
+			inventory("local_users", "username", 50, func(i int) diff.Row { return diff.Row{"uid": 500 + i, "admin": i == 0} }),
+			{"type": "listening_ports", "items": listeningPorts(400, shift)},
+		}
+	}
+	failures := func(n int) diff.Row {
+		items := make([]any, n)
+		for i := range items {
+			items[i] = map[string]any{"probe": fmt.Sprintf("config.synthetic_%03d", i), "count": 1 + i%4, "exit_codes": map[string]any{"1": 1 + i%4}}
+		}
+		return diff.Row{"type": "probe_failures_summary", "items": items}
+	}
+
+	baseline = append(scalar(0), inventories(0)...)
+	current = append(scalar(1), inventories(1)...)
+	baseline = append(baseline, failures(40))
+	current = append(current, failures(45))
+	for i := 0; i < 200; i++ {
+		baseline = append(baseline, diff.Row{"type": "warning", "code": fmt.Sprintf("W%04d", i)})
+		current = append(current, diff.Row{"type": "warning", "code": fmt.Sprintf("W%04d", i+5)})
+	}
+
+	for i := 0; len(baseline) < opts.Rows; i++ {
+		row := diff.Row{"type": "large_file", "path": fmt.Sprintf("/Users/me/data/%03d/file-%07d.bin", i%997, i), "size_bytes": 100_000_000 + r.Intn(1_000_000)}
+		baseline = append(baseline, row)
+		switch x := r.Float64(); {
+		case x < opts.Churn/3: // removed
+		case x < 2*opts.Churn/3: // grown, plus a new sibling
+			current = append(current, diff.Row{"type": "large_file", "path": row["path"], "size_bytes": row["size_bytes"].(int) * 2})
+			current = append(current, diff.Row{"type": "large_file", "path": fmt.Sprintf("/Users/me/new/file-%07d.bin", i), "size_bytes": 200_000_000})
+		default:
+			current = append(current, row)
+		}
+	}
+	return baseline, current
+}
+
+func listeningPorts(n, shift int) []any {
+	items := make([]any, n)
+	for i := range items {
+		port := 1024 + i
+		if i%50 == 49 {
+			port += shift * 10_000 // a few services move ports between snapshots
+		}
+		items[i] = map[string]any{"process": fmt.Sprintf("svc%d", i), "port": port}
+	}
+	return items
+}
+
+// StageResult is the cost of one stage.
+type StageResult struct {
+	Stage       string        `json:"stage"`
+	Rows        int           `json:"rows"`
+	Duration    time.Duration `json:"duration_ns"`
+	NsPerRow    float64       `json:"ns_per_row"`
+	RowsPerSec  float64       `json:"rows_per_sec"`
+	Allocs      uint64        `json:"allocs"`
+	Bytes       uint64        `json:"bytes"`
+	BytesPerRow float64       `json:"bytes_per_row"`
+	OutputBytes int           `json:"output_bytes,omitempty"`
+}
+
+// Measure writes both snapshots to dir and times each stage, keeping the
+// fastest of iterations runs.
+func Measure(dir string, baseline, current []diff.Row, iterations int) ([]StageResult, error) {
+	if iterations < 1 {
+		iterations = 1
+	}
+	basePath := filepath.Join(dir, "baseline.ndjson")
+	currPath := filepath.Join(dir, "current.ndjson")
+	if err := writeNDJSON(basePath, baseline); err != nil {
+		return nil, err
+	}
+	if err := writeNDJSON(currPath, current); err != nil {
+		return nil, err
+	}
+	rows := len(baseline) + len(current)
+
+	var out []StageResult
+	for _, stage := range stages {
+		var best StageResult
+		for i := 0; i < iterations; i++ {
+			var outputBytes int
+			var stageErr error
+			res := measure(stage, rows, func() {
+				switch stage {
+				case StageRead:
+					if _, stageErr = diff.ReadNDJSON(basePath); stageErr == nil {
+						_, stageErr = diff.ReadNDJSON(currPath)
+					}
+				case StageDiff:
+					_, captured := diff.Run(baseline, current, false, true)
+					outputBytes = len(captured)
+				case StageDiffNDJSON:
+					_, captured := diff.Run(baseline, current, true, true)
+					outputBytes = len(captured)
+				}
+			})
+			if stageErr != nil {
+				return nil, stageErr
+			}
+			res.OutputBytes = outputBytes
+			if i == 0 || res.Duration < best.Duration {
+				best = res
+			}
+		}
+		out = append(out, best)
+	}
+	return out, nil
+}
+
+func measure(stage string, rows int, fn func()) StageResult {
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	fn()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	res := StageResult{
+		Stage:    stage,
+		Rows:     rows,
+		Duration: elapsed,
+		Allocs:   after.Mallocs - before.Mallocs,
+		Bytes:    after.TotalAlloc - before.TotalAlloc,
+	}
+	if rows > 0 {
+		res.NsPerRow = float64(elapsed.Nanoseconds()) / float64(rows)
+		res.BytesPerRow = float64(res.Bytes) / float64(rows)
+	}
+	if elapsed > 0 {
+		res.RowsPerSec = float64(rows) / elapsed.Seconds()
+	}
+	return res
+}
+
+func writeNDJSON(path string, rows []diff.Row) error {
+	f, err := writeguard.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Limit caps the per-row cost of one stage. Zero fields are not checked.
+type Limit struct {
+	MaxNsPerRow    float64 `json:"max_ns_per_row,omitempty"`
+	MaxBytesPerRow float64 `json:"max_bytes_per_row,omitempty"`
+}
+
+// Budget maps stages to their limits.
+type Budget map[string]Limit
+
+// DefaultBudget leaves several times the headroom measured on a laptop, so it
+// catches complexity regressions (e.g. a quadratic inventory join) rather than
+// noise.
+var DefaultBudget = Budget{
+	StageRead:       {MaxNsPerRow: 15_000, MaxBytesPerRow: 4_096},
+	StageDiff:       {MaxNsPerRow: 6_000, MaxBytesPerRow: 1_024},
+	StageDiffNDJSON: {MaxNsPerRow: 6_000, MaxBytesPerRow: 1_024},
+}
+
+// LoadBudget reads a budget file ({"diff": {"max_ns_per_row": 1500}, ...}) and
+// merges it over DefaultBudget.
+func LoadBudget(path string) (Budget, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var override Budget
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	b := make(Budget, len(DefaultBudget))
+	for k, v := range DefaultBudget {
+		b[k] = v
+	}
+	for k, v := range override {
+		if _, ok := b[k]; !ok {
+			return nil, fmt.Errorf("%s: unknown stage %q", path, k)
+		}
+		b[k] = v
+	}
+	return b, nil
+}
+
+// Check returns one message per stage that exceeds its limit.
+func (b Budget) Check(results []StageResult) []string {
+	var out []string
+	for _, r := range results {
+		l, ok := b[r.Stage]
+		if !ok {
+			continue
+		}
+		if l.MaxNsPerRow > 0 && r.NsPerRow > l.MaxNsPerRow {
+			out = append(out, fmt.Sprintf("%s: %.0f ns/row exceeds budget of %.0f", r.Stage, r.NsPerRow, l.MaxNsPerRow))
+		}
+		if l.MaxBytesPerRow > 0 && r.BytesPerRow > l.MaxBytesPerRow {
+			out = append(out, fmt.Sprintf("%s: %.0f B/row exceeds budget of %.0f", r.Stage, r.BytesPerRow, l.MaxBytesPerRow))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
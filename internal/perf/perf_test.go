@@ -0,0 +1,86 @@
+package perf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+func TestSynthesize(t *testing.T) {
+	base, curr := Synthesize(Options{Rows: 5000, Seed: 1, Churn: 0.05})
+	if len(base) != 5000 {
+		t.Errorf("len(baseline) = %d, want 5000", len(base))
+	}
+	again, _ := Synthesize(Options{Rows: 5000, Seed: 1, Churn: 0.05})
+	if again[len(again)-1]["size_bytes"] != base[len(base)-1]["size_bytes"] {
+		t.Error("Synthesize is not deterministic for a fixed seed")
+	}
+
+	changes := diff.BuildInventoryChanges(base, curr)
+	counts := map[string]int{}
+	for _, ch := range changes {
+		counts[ch.RowType+"/"+ch.Status]++
+	}
+	for _, want := range []string{"large_file/added", "large_file/removed", "listening_ports/added", "launch_daemons/changed"} {
+		if counts[want] == 0 {
+			t.Errorf("no %s changes in synthetic snapshots: %v", want, counts)
+		}
+	}
+}
+
+func TestMeasureWithinBudget(t *testing.T) {
+	base, curr := Synthesize(Options{Rows: 20_000, Seed: 1})
+	results, err := Measure(t.TempDir(), base, curr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(stages) {
+		t.Fatalf("Measure() = %d stages, want %d", len(results), len(stages))
+	}
+	for _, r := range results {
+		if r.Rows == 0 || r.NsPerRow <= 0 || r.Allocs == 0 {
+			t.Errorf("stage %s not measured: %+v", r.Stage, r)
+		}
+	}
+	if testing.Short() || raceEnabled {
+		return
+	}
+	if over := DefaultBudget.Check(results); len(over) > 0 {
+		t.Errorf("over budget:\n%s", strings.Join(over, "\n"))
+	}
+}
+
+func TestBudgetCheck(t *testing.T) {
+	b := Budget{StageDiff: {MaxNsPerRow: 100, MaxBytesPerRow: 10}}
+	over := b.Check([]StageResult{
+		{Stage: StageDiff, NsPerRow: 150, BytesPerRow: 5},
+		{Stage: StageRead, NsPerRow: 1e9},
+	})
+	if len(over) != 1 || !strings.Contains(over[0], "diff: 150 ns/row") {
+		t.Errorf("Check() = %v", over)
+	}
+}
+
+func benchmarkDiff(b *testing.B, rows int, ndjson bool) {
+	base, curr := Synthesize(Options{Rows: rows, Seed: 1})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diff.Run(base, curr, ndjson, true)
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*(len(base)+len(curr))), "ns/row")
+}
+
+func BenchmarkDiff10k(b *testing.B)        { benchmarkDiff(b, 10_000, false) }
+func BenchmarkDiff100k(b *testing.B)       { benchmarkDiff(b, 100_000, false) }
+func BenchmarkDiffNDJSON100k(b *testing.B) { benchmarkDiff(b, 100_000, true) }
+
+func BenchmarkBuildInventoryChanges100k(b *testing.B) {
+	base, curr := Synthesize(Options{Rows: 100_000, Seed: 1})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diff.BuildInventoryChanges(base, curr)
+	}
+}
@@ -0,0 +1,88 @@
+package pkghook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Hook is a file `osaudit pkghook install` writes.
+type Hook struct {
+	Path    string
+	Content string
+	Mode    os.FileMode
+}
+
+// Logs are the logs a hook run reads the transaction from, per manager. brew
+// keeps none; its hook passes the command line instead.
+var Logs = map[string]string{
+	"apt": "/var/log/dpkg.log",
+	"dnf": "/var/log/dnf.rpm.log",
+}
+
+// Hooks returns the files that make manager run `<exe> pkghook run` after
+// each transaction, with OSAUDIT_ROOT set to root. For brew, which has no
+// hooks, it is a wrapper script in binDir to put ahead of Homebrew on PATH;
+// realBrew is the brew it runs.
+func Hooks(manager, exe, root, binDir, realBrew string) ([]Hook, error) {
+	run := fmt.Sprintf("/usr/bin/env OSAUDIT_ROOT=%s %s pkghook run %s --detach", shellQuote(root), shellQuote(exe), manager)
+	switch manager {
+	case "apt":
+		// dpkg runs once per apt transaction; Post-Invoke runs after it.
+		return []Hook{{
+			Path:    "/etc/apt/apt.conf.d/80osaudit",
+			Content: "// Installed by `osaudit pkghook install apt`: capture a snapshot after\n// each transaction, tagged with the packages it changed.\nDPkg::Post-Invoke { \"" + run + " || true\"; };\n",
+			Mode:    0o644,
+		}}, nil
+	case "dnf":
+		// dnf 4 runs post-transaction actions per package; runs after the
+		// first find the transaction already read and do nothing. dnf 5 runs
+		// post_transaction actions once, splitting the command on spaces.
+		return []Hook{
+			{
+				Path:    "/etc/dnf/plugins/post-transaction-actions.d/osaudit.action",
+				Content: "# Installed by `osaudit pkghook install dnf`: capture a snapshot after each\n# transaction, tagged with the packages it changed.\n*:any:" + run + "\n",
+				Mode:    0o644,
+			},
+			{
+				Path:    "/etc/dnf/libdnf5-plugins/actions.d/osaudit.actions",
+				Content: "# Installed by `osaudit pkghook install dnf`: capture a snapshot after each\n# transaction, tagged with the packages it changed.\npost_transaction::::" + run + "\n",
+				Mode:    0o644,
+			},
+		}, nil
+	case "brew":
+		if realBrew == "" {
+			return nil, fmt.Errorf("brew not found on PATH")
+		}
+		subcommands := make([]string, 0, len(brewActions))
+		for sub := range brewActions {
+			subcommands = append(subcommands, sub)
+		}
+		sort.Strings(subcommands)
+		script := `#!/bin/sh
+# Installed by ` + "`osaudit pkghook install brew`" + `: runs Homebrew, then captures a
+# snapshot tagged with the formulae and casks it changed.
+` + shellQuote(realBrew) + ` "$@"
+status=$?
+if [ "$status" -eq 0 ]; then
+    case "${1:-}" in
+        ` + strings.Join(subcommands, "|") + `)
+            OSAUDIT_ROOT=` + shellQuote(root) + ` ` + shellQuote(exe) + ` pkghook run brew --detach -- "$@" </dev/null >/dev/null 2>&1 ;;
+    esac
+fi
+exit "$status"
+`
+		return []Hook{{Path: filepath.Join(binDir, "brew"), Content: script, Mode: 0o755}}, nil
+	}
+	return nil, fmt.Errorf("unknown package manager %q (want %s)", manager, strings.Join(Managers, ", "))
+}
+
+// shellQuote quotes s for sh when it needs it.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789/._-+=:,@") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
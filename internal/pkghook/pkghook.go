@@ -0,0 +1,240 @@
+// Package pkghook reads package manager transactions for `osaudit pkghook`:
+// the hooks apt, dnf, and brew run after installing or removing packages,
+// which capture a snapshot tagged with what was installed so that diff can
+// attribute the drift to it.
+package pkghook
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// RowType is the row a hook run appends to its snapshot.
+const RowType = "package_transaction"
+
+// Managers are the package managers with hooks.
+var Managers = []string{"apt", "dnf", "brew"}
+
+// Package is one package a transaction changed. Previous is the version it
+// replaced, for upgrades and downgrades.
+type Package struct {
+	Action   string `json:"action"` // install, upgrade, downgrade, reinstall, remove
+	Name     string `json:"name"`
+	Arch     string `json:"arch,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Previous string `json:"previous_version,omitempty"`
+}
+
+// Transaction is what one package manager run did.
+type Transaction struct {
+	Manager  string
+	Command  string // the command line, when the hook knows it (brew)
+	Started  time.Time
+	Packages []Package
+}
+
+// Row returns the transaction as a package_transaction row.
+func (t Transaction) Row(runID string) map[string]any {
+	row := map[string]any{
+		"type":     RowType,
+		"run_id":   runID,
+		"manager":  t.Manager,
+		"packages": t.Packages,
+	}
+	if t.Command != "" {
+		row["command"] = t.Command
+	}
+	if !t.Started.IsZero() {
+		row["started"] = t.Started.UTC().Format(time.RFC3339)
+	}
+	return row
+}
+
+// ReadLogSince returns what was appended to the log at path after offset,
+// and the offset to read from next time. A log shorter than offset was
+// rotated, so it is read from the start.
+func ReadLogSince(path string, offset int64) ([]byte, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, offset, err
+	}
+	return data, offset + int64(len(data)), nil
+}
+
+// ParseDpkgLog reads the package actions in dpkg.log lines, e.g.
+// "2026-03-01 10:00:01 upgrade openssl:amd64 3.0.2-0ubuntu1 3.0.13-0ubuntu3".
+// Started is the time of the first action.
+func ParseDpkgLog(r io.Reader) Transaction {
+	t := Transaction{Manager: "apt"}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) != 6 {
+			continue
+		}
+		action := f[2]
+		switch action {
+		case "install", "upgrade", "remove", "purge":
+		default:
+			continue
+		}
+		p := Package{Action: action, Version: f[5], Previous: f[4]}
+		p.Name, p.Arch, _ = strings.Cut(f[3], ":")
+		switch {
+		case action == "purge" || action == "remove":
+			// A purge after a remove is the same package leaving.
+			p.Action, p.Version = "remove", p.Previous
+			p.Previous = ""
+		case p.Previous == "<none>":
+			p.Action, p.Previous = "install", ""
+		case p.Previous == p.Version:
+			p.Action, p.Previous = "reinstall", ""
+		}
+		if p.Version == "<none>" {
+			p.Version = ""
+		}
+		if t.Started.IsZero() {
+			t.Started, _ = time.ParseInLocation("2006-01-02 15:04:05", f[0]+" "+f[1], time.Local)
+		}
+		t.Packages = addPackage(t.Packages, p)
+	}
+	return t
+}
+
+// dnfActions maps dnf.rpm.log verbs to package actions. The past-tense
+// forms name the version an upgrade or downgrade replaced.
+var dnfActions = map[string]string{
+	"Installed:": "install", "Install:": "install",
+	"Upgrade:": "upgrade", "Upgraded:": "upgraded",
+	"Downgrade:": "downgrade", "Downgraded:": "downgraded",
+	"Reinstall:": "reinstall", "Reinstalled:": "reinstall",
+	"Erase:": "remove", "Erased:": "remove", "Obsoleted:": "remove",
+}
+
+// ParseDNFLog reads the package actions in dnf.rpm.log lines, e.g.
+// "2026-03-01T10:00:01+0000 SUBDEBUG Upgrade: openssl-1:3.1.4-2.fc40.x86_64".
+func ParseDNFLog(r io.Reader) Transaction {
+	t := Transaction{Manager: "dnf"}
+	replaced := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) != 4 {
+			continue
+		}
+		action, ok := dnfActions[f[2]]
+		if !ok {
+			continue
+		}
+		name, version, arch := splitNEVRA(f[3])
+		if action == "upgraded" || action == "downgraded" {
+			replaced[name+"."+arch] = version
+			continue
+		}
+		if t.Started.IsZero() {
+			t.Started, _ = time.Parse("2006-01-02T15:04:05-0700", f[0])
+		}
+		t.Packages = addPackage(t.Packages, Package{Action: action, Name: name, Arch: arch, Version: version})
+	}
+	for i, p := range t.Packages {
+		if prev, ok := replaced[p.Name+"."+p.Arch]; ok && (p.Action == "upgrade" || p.Action == "downgrade") {
+			t.Packages[i].Previous = prev
+		}
+	}
+	return t
+}
+
+// splitNEVRA splits an RPM name-[epoch:]version-release.arch.
+func splitNEVRA(s string) (name, version, arch string) {
+	if i := strings.LastIndexByte(s, '.'); i > 0 {
+		s, arch = s[:i], s[i+1:]
+	}
+	rel := strings.LastIndexByte(s, '-')
+	if rel <= 0 {
+		return s, "", arch
+	}
+	ver := strings.LastIndexByte(s[:rel], '-')
+	if ver <= 0 {
+		return s[:rel], s[rel+1:], arch
+	}
+	return s[:ver], s[ver+1:], arch
+}
+
+// addPackage adds p, replacing an earlier entry for the same package: when a
+// transaction touches a package twice, the last action is what stuck.
+func addPackage(pkgs []Package, p Package) []Package {
+	for i, q := range pkgs {
+		if q.Name == p.Name && q.Arch == p.Arch {
+			if q.Action == "install" && p.Action != "remove" {
+				p.Action, p.Previous = "install", ""
+			}
+			if p.Version == "" {
+				p.Version = q.Version
+			}
+			pkgs[i] = p
+			return pkgs
+		}
+	}
+	return append(pkgs, p)
+}
+
+// brewActions maps the brew subcommands the wrapper reports on to package
+// actions.
+var brewActions = map[string]string{
+	"install": "install", "reinstall": "reinstall", "upgrade": "upgrade",
+	"uninstall": "remove", "remove": "remove", "rm": "remove",
+}
+
+// BrewAction returns the package action of a brew subcommand, or "" when it
+// changes no packages.
+func BrewAction(subcommand string) string {
+	return brewActions[subcommand]
+}
+
+// ParseBrew builds the transaction of a brew command from its arguments.
+// versions looks up the installed version of the formulae and casks named,
+// e.g. from `brew list --versions`; it is not called for removals. `brew
+// upgrade` without names lists no packages; the command says what ran.
+func ParseBrew(args []string, versions func(names []string) map[string]string, started time.Time) Transaction {
+	t := Transaction{Manager: "brew", Command: "brew " + strings.Join(args, " "), Started: started}
+	if len(args) == 0 {
+		return t
+	}
+	action := BrewAction(args[0])
+	if action == "" {
+		return t
+	}
+	var names []string
+	for _, a := range args[1:] {
+		if !strings.HasPrefix(a, "-") {
+			// A tap-qualified name (user/tap/formula) is listed by its last part.
+			names = append(names, a[strings.LastIndexByte(a, '/')+1:])
+		}
+	}
+	var installed map[string]string
+	if action != "remove" && len(names) > 0 && versions != nil {
+		installed = versions(names)
+	}
+	for _, name := range names {
+		t.Packages = append(t.Packages, Package{Action: action, Name: name, Version: installed[name]})
+	}
+	return t
+}
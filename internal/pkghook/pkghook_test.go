@@ -0,0 +1,124 @@
+package pkghook
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDpkgLog(t *testing.T) {
+	log := `2026-03-01 10:00:01 startup archives unpack
+2026-03-01 10:00:01 install nginx-common:all <none> 1.24.0-2ubuntu7
+2026-03-01 10:00:02 status half-installed nginx-common:all 1.24.0-2ubuntu7
+2026-03-01 10:00:02 install nginx:amd64 <none> 1.24.0-2ubuntu7
+2026-03-01 10:00:03 upgrade openssl:amd64 3.0.2-0ubuntu1 3.0.13-0ubuntu3
+2026-03-01 10:00:04 remove telnet:amd64 0.17-44 <none>
+2026-03-01 10:00:05 purge telnet:amd64 0.17-44 <none>
+`
+	tx := ParseDpkgLog(strings.NewReader(log))
+	want := []Package{
+		{Action: "install", Name: "nginx-common", Arch: "all", Version: "1.24.0-2ubuntu7"},
+		{Action: "install", Name: "nginx", Arch: "amd64", Version: "1.24.0-2ubuntu7"},
+		{Action: "upgrade", Name: "openssl", Arch: "amd64", Version: "3.0.13-0ubuntu3", Previous: "3.0.2-0ubuntu1"},
+		{Action: "remove", Name: "telnet", Arch: "amd64", Version: "0.17-44"},
+	}
+	if !reflect.DeepEqual(tx.Packages, want) {
+		t.Errorf("Packages = %+v, want %+v", tx.Packages, want)
+	}
+	if tx.Manager != "apt" || tx.Started.Format("15:04:05") != "10:00:01" {
+		t.Errorf("Manager, Started = %q, %v", tx.Manager, tx.Started)
+	}
+}
+
+func TestParseDNFLog(t *testing.T) {
+	log := `2026-03-01T10:00:01+0000 SUBDEBUG Upgrade: openssl-1:3.1.4-2.fc40.x86_64
+2026-03-01T10:00:01+0000 SUBDEBUG Installed: nginx-1.26.0-1.fc40.x86_64
+2026-03-01T10:00:02+0000 SUBDEBUG Upgraded: openssl-1:3.1.1-4.fc40.x86_64
+2026-03-01T10:00:02+0000 SUBDEBUG Erase: telnet-0.17-92.fc40.x86_64
+2026-03-01T10:00:02+0000 INFO --- logging initialized ---
+`
+	tx := ParseDNFLog(strings.NewReader(log))
+	want := []Package{
+		{Action: "upgrade", Name: "openssl", Arch: "x86_64", Version: "1:3.1.4-2.fc40", Previous: "1:3.1.1-4.fc40"},
+		{Action: "install", Name: "nginx", Arch: "x86_64", Version: "1.26.0-1.fc40"},
+		{Action: "remove", Name: "telnet", Arch: "x86_64", Version: "0.17-92.fc40"},
+	}
+	if !reflect.DeepEqual(tx.Packages, want) {
+		t.Errorf("Packages = %+v, want %+v", tx.Packages, want)
+	}
+}
+
+func TestParseBrew(t *testing.T) {
+	versions := func(names []string) map[string]string {
+		return map[string]string{"wget": "1.24.5", "visual-studio-code": "1.98.0"}
+	}
+	started := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	tx := ParseBrew([]string{"install", "--cask", "visual-studio-code", "homebrew/core/wget"}, versions, started)
+	want := []Package{
+		{Action: "install", Name: "visual-studio-code", Version: "1.98.0"},
+		{Action: "install", Name: "wget", Version: "1.24.5"},
+	}
+	if !reflect.DeepEqual(tx.Packages, want) {
+		t.Errorf("Packages = %+v, want %+v", tx.Packages, want)
+	}
+	if tx.Command != "brew install --cask visual-studio-code homebrew/core/wget" {
+		t.Errorf("Command = %q", tx.Command)
+	}
+
+	if tx := ParseBrew([]string{"upgrade"}, versions, started); len(tx.Packages) != 0 || tx.Command != "brew upgrade" {
+		t.Errorf("brew upgrade = %+v, want the command and no packages", tx)
+	}
+	if tx := ParseBrew([]string{"list"}, versions, started); len(tx.Packages) != 0 {
+		t.Errorf("brew list = %+v, want no packages", tx.Packages)
+	}
+}
+
+func TestReadLogSince(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dpkg.log")
+	os.WriteFile(path, []byte("one\ntwo\n"), 0o644)
+	data, offset, err := ReadLogSince(path, 4)
+	if err != nil || string(data) != "two\n" || offset != 8 {
+		t.Fatalf("ReadLogSince(4) = %q, %d, %v", data, offset, err)
+	}
+	// A rotated log is shorter than the saved offset.
+	os.WriteFile(path, []byte("new\n"), 0o644)
+	data, offset, err = ReadLogSince(path, 8)
+	if err != nil || string(data) != "new\n" || offset != 4 {
+		t.Errorf("ReadLogSince after rotation = %q, %d, %v", data, offset, err)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	hooks, err := Hooks("apt", "/usr/local/bin/osaudit", "/opt/os audit", "", "")
+	if err != nil || len(hooks) != 1 {
+		t.Fatalf("Hooks(apt) = %+v, %v", hooks, err)
+	}
+	if want := `DPkg::Post-Invoke { "/usr/bin/env OSAUDIT_ROOT='/opt/os audit' /usr/local/bin/osaudit pkghook run apt --detach || true"; };`; !strings.Contains(hooks[0].Content, want) {
+		t.Errorf("apt hook = %q, want it to contain %q", hooks[0].Content, want)
+	}
+
+	if hooks, err := Hooks("dnf", "/usr/local/bin/osaudit", "/opt/osaudit", "", ""); err != nil || len(hooks) != 2 {
+		t.Errorf("Hooks(dnf) = %+v, %v, want the dnf 4 and dnf 5 actions", hooks, err)
+	}
+
+	hooks, err = Hooks("brew", "/usr/local/bin/osaudit", "/opt/osaudit", "/home/me/.osaudit/bin", "/opt/homebrew/bin/brew")
+	if err != nil || len(hooks) != 1 {
+		t.Fatalf("Hooks(brew) = %+v, %v", hooks, err)
+	}
+	if hooks[0].Path != "/home/me/.osaudit/bin/brew" || hooks[0].Mode != 0o755 {
+		t.Errorf("brew wrapper = %s %v", hooks[0].Path, hooks[0].Mode)
+	}
+	for _, want := range []string{`/opt/homebrew/bin/brew "$@"`, "install|reinstall|remove|rm|uninstall|upgrade)", `pkghook run brew --detach -- "$@"`} {
+		if !strings.Contains(hooks[0].Content, want) {
+			t.Errorf("brew wrapper missing %q:\n%s", want, hooks[0].Content)
+		}
+	}
+
+	if _, err := Hooks("brew", "/usr/local/bin/osaudit", "/opt/osaudit", "/tmp/bin", ""); err == nil {
+		t.Error("Hooks(brew) without brew on PATH succeeded")
+	}
+}
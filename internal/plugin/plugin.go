@@ -0,0 +1,331 @@
+// Package plugin runs collector plugins: executables in ~/.osaudit/plugins/
+// that add org-specific probes without touching the command manifest.
+//
+// The contract:
+//   - `<plugin> --osaudit-describe` prints one JSON Description and exits 0.
+//   - `<plugin>` with no arguments prints NDJSON rows on stdout, one object per
+//     line, each with a "type" declared in the description's row_types.
+//
+// Plugins whose description sets "protocol": "rpc" are resident instead: they
+// are built with pluginsdk, started once, and serve Collect calls over a local
+// socket across runs (see rpc.go).
+//
+// Plugins receive OSAUDIT_RUN_ID, OSAUDIT_PLATFORM (mac or linux), and
+// OSAUDIT_ROOT in their environment. Their rows are stamped with "plugin" and
+// "run_id" and merged into the audit's NDJSON, preceded by a "plugin" row that
+// records the outcome and the declared row types so diff can compare them.
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/rowbuf"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+	"github.com/kareemsasa/operating-system-audit/pluginsdk"
+)
+
+const (
+	// DescribeFlag asks a plugin for its Description.
+	DescribeFlag = pluginsdk.DescribeFlag
+
+	// RowType is the type of the summary row written for each plugin run.
+	RowType = "plugin"
+
+	describeTimeout = 10 * time.Second
+
+	// DefaultTimeout bounds a plugin run unless its description sets
+	// timeout_seconds.
+	DefaultTimeout = 60 * time.Second
+
+	maxLineSize = 1024 * 1024
+)
+
+var typeRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// reservedTypes are row types written by the built-in collectors. Plugins may
+// not emit them, so a plugin cannot overwrite core findings.
+var reservedTypes = map[string]bool{
+	RowType: true, "meta": true, "warning": true, "note": true, "scan": true, "timing": true,
+	"summary": true, "counts": true, "security_config": true, "run_context": true, "scope": true,
+	"probe_failed": true, "probe_failures_summary": true, "homebrew_summary": true,
+	"large_file": true, "file_hash": true, "watched_dotfiles": true, "local_users": true, "ssh_keys": true, "listening_ports": true,
+	"launch_daemons": true, "launch_agents": true, "background_items": true, "background_tasks": true, "kernel_extensions": true, "kernel_modules": true,
+	"enabled_services": true, "user_services": true, "xdg_autostart": true, "cron_jobs": true, "shell_startup_files": true, "firewall_status": true,
+	"scheduled_tasks": true, "local_groups": true, "security_events_summary": true, "systemd_units": true, "firewall_open_ports": true, "dns_resolvers": true, "hosts_file": true, "vpn_connections": true, "routing_table": true, "arp_neighbors": true, "default_gateways": true, "proxy_settings": true, "network_context": true, "disk_volumes": true, "disk_health": true, "download_quarantine": true, "system_packages": true, "system_packages_summary": true, "system_extensions": true, "containers": true, "ai_agents": true, "mcp_servers": true, "fd_pressure": true, "k8s_node": true, "applications": true, "app_store_apps": true, "dev_toolchains": true, "language_packages": true, "runtimes": true, "ide_extensions": true, "authorized_keys": true, "ssh_config_hosts": true, "sshd_config": true, "ssh_certificates": true, "secrets_agents": true, "env_secrets": true, "path_hijack": true, "sensitive_dirs": true, "usb_devices": true, "removable_volumes": true, "certificates": true, "config_profiles": true, "malware_protection": true, "capability": true, "collector_crash": true, "run_summary": true, "classification": true, "package_transaction": true, "recent_logins": true, "recent_changes": true,
+}
+
+// RowSpec declares a row type a plugin emits. Types with a key are diffed item
+// by item like the built-in inventories.
+type RowSpec = pluginsdk.RowSpec
+
+// Description is what a plugin prints for --osaudit-describe, e.g.
+//
+//	{"name": "corp-vpn", "version": "1.2.0", "platforms": ["mac"],
+//	 "row_types": [{"type": "corp_vpn_profiles", "topic": "Network",
+//	                "key": ["name"], "compare": ["state"], "items": true}]}
+type Description = pluginsdk.Description
+
+// Plugin is a discovered, described plugin executable.
+type Plugin struct {
+	Path string
+	Description
+}
+
+// SupportsPlatform reports whether the plugin runs on platform (mac or linux).
+// A plugin without platforms runs everywhere.
+func (p Plugin) SupportsPlatform(platform string) bool {
+	if len(p.Platforms) == 0 {
+		return true
+	}
+	for _, pl := range p.Platforms {
+		if pl == platform {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Plugin) timeout() time.Duration {
+	if p.TimeoutSeconds > 0 {
+		return time.Duration(p.TimeoutSeconds) * time.Second
+	}
+	return DefaultTimeout
+}
+
+// Discover describes every executable file in dir, in name order. A missing
+// directory yields no plugins. Plugins that fail to describe themselves are
+// skipped and reported in errs.
+func Discover(dir string) (plugins []Plugin, errs []error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, []error{err}
+	}
+	seen := make(map[string]string)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") || e.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		p, err := Describe(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if prev, dup := seen[p.Name]; dup {
+			errs = append(errs, fmt.Errorf("plugin %s: name %q already used by %s", path, p.Name, prev))
+			continue
+		}
+		seen[p.Name] = path
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, errs
+}
+
+// Describe runs `path --osaudit-describe` and validates the result. The name
+// defaults to the file name.
+func Describe(path string) (Plugin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, DescribeFlag).Output()
+	if err != nil {
+		return Plugin{}, fmt.Errorf("plugin %s: %s: %w", path, DescribeFlag, err)
+	}
+	var d Description
+	if err := json.Unmarshal(out, &d); err != nil {
+		return Plugin{}, fmt.Errorf("plugin %s: invalid description: %w", path, err)
+	}
+	if d.Name == "" {
+		d.Name = filepath.Base(path)
+	}
+	if err := validate(d); err != nil {
+		return Plugin{}, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	return Plugin{Path: path, Description: d}, nil
+}
+
+func validate(d Description) error {
+	if len(d.RowTypes) == 0 {
+		return fmt.Errorf("row_types is required")
+	}
+	if d.Protocol != "" && d.Protocol != pluginsdk.ProtocolExec && d.Protocol != pluginsdk.ProtocolRPC {
+		return fmt.Errorf("protocol must be %s or %s", pluginsdk.ProtocolExec, pluginsdk.ProtocolRPC)
+	}
+	seen := make(map[string]bool)
+	for i, rs := range d.RowTypes {
+		switch {
+		case !typeRe.MatchString(rs.Type):
+			return fmt.Errorf("row_types[%d]: type %q must be lower_snake_case", i, rs.Type)
+		case reservedTypes[rs.Type]:
+			return fmt.Errorf("row_types[%d]: type %q is reserved for built-in collectors", i, rs.Type)
+		case seen[rs.Type]:
+			return fmt.Errorf("row_types[%d]: duplicate type %q", i, rs.Type)
+		case len(rs.Key) == 0 && (len(rs.Compare) > 0 || rs.Items):
+			return fmt.Errorf("row_types[%d]: compare and items need a key", i)
+		}
+		seen[rs.Type] = true
+	}
+	return nil
+}
+
+// Env is the context a plugin runs in.
+type Env struct {
+	RunID      string
+	Platform   string
+	Root       string
+	Privileged bool // osaudit runs as root
+
+	// StateDir records resident plugins between runs so they can be reused.
+	// Without it, resident plugins are stopped after collecting.
+	StateDir string
+}
+
+// Run collects p's rows, stamped with the plugin name and run ID. The first
+// row is always the plugin's summary row (type "plugin"), which records status
+// "ok", "failed", or "skipped" (a resident plugin declined the host) and the
+// declared row types. A failed or skipped plugin contributes no other rows.
+// Canceling ctx stops the plugin's collection.
+func Run(ctx context.Context, p Plugin, env Env) ([]diff.Row, error) {
+	var rows []diff.Row
+	var declined string
+	var err error
+	if p.Protocol == pluginsdk.ProtocolRPC {
+		rows, declined, err = collectResident(ctx, p, env)
+	} else {
+		rows, err = run(ctx, p, env)
+	}
+	if err == nil {
+		err = stampRows(p, env, rows)
+	}
+	summary := diff.Row{
+		"type":      RowType,
+		"run_id":    env.RunID,
+		"plugin":    p.Name,
+		"version":   p.Version,
+		"status":    "ok",
+		"rows":      len(rows),
+		"row_types": p.RowTypes,
+	}
+	if err != nil {
+		summary["status"] = "failed"
+		summary["error"] = err.Error()
+		summary["rows"] = 0
+		return []diff.Row{summary}, err
+	}
+	if declined != "" {
+		summary["status"] = "skipped"
+		summary["reason"] = declined
+		return []diff.Row{summary}, nil
+	}
+	return append([]diff.Row{summary}, rows...), nil
+}
+
+func run(ctx context.Context, p Plugin, env Env) ([]diff.Row, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Env = append(os.Environ(),
+		"OSAUDIT_RUN_ID="+env.RunID,
+		"OSAUDIT_PLATFORM="+env.Platform,
+		"OSAUDIT_ROOT="+env.Root,
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("plugin %s: timed out after %s", p.Name, p.timeout())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("plugin %s: %w: %s", p.Name, err, firstLine(msg))
+		}
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	return parseRows(p, &stdout)
+}
+
+func parseRows(p Plugin, r *bytes.Buffer) ([]diff.Row, error) {
+	var rows []diff.Row
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var row diff.Row
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			return nil, fmt.Errorf("plugin %s: invalid JSON at line %d", p.Name, lineNo)
+		}
+		rows = append(rows, row)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	return rows, nil
+}
+
+// stampRows checks that every row has a declared type and stamps it with the
+// plugin name and run ID.
+func stampRows(p Plugin, env Env, rows []diff.Row) error {
+	declared := make(map[string]bool, len(p.RowTypes))
+	for _, rs := range p.RowTypes {
+		declared[rs.Type] = true
+	}
+	for i, row := range rows {
+		t, _ := row["type"].(string)
+		if !declared[t] {
+			return fmt.Errorf("plugin %s: row %d: row type %q is not declared in row_types", p.Name, i+1, t)
+		}
+		row["plugin"] = p.Name
+		if _, ok := row["run_id"]; !ok {
+			row["run_id"] = env.RunID
+		}
+	}
+	return nil
+}
+
+// AppendNDJSON appends rows to an NDJSON file.
+func AppendNDJSON(path string, rows *rowbuf.Buffer) error {
+	f, err := writeguard.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	err = rows.Each(func(row rowbuf.Row) error { return enc.Encode(row) })
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
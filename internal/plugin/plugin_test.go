@@ -0,0 +1,201 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/pluginsdk"
+)
+
+const describeVPN = `{"name":"corp-vpn","version":"1.0","platforms":["mac","linux"],"row_types":[{"type":"corp_vpn_profiles","key":["name"],"compare":["state"],"items":true}]}`
+
+func writePlugin(t *testing.T, dir, name, describe, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell plugins need a POSIX shell")
+	}
+	script := "#!/bin/sh\nif [ \"$1\" = \"--osaudit-describe\" ]; then\n  echo '" + describe + "'\n  exit 0\nfi\n" + body + "\n"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "vpn", describeVPN, "true")
+	writePlugin(t, dir, "bad", `{"row_types":[{"type":"security_config"}]}`, "true")
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, errs := Discover(dir)
+	if len(plugins) != 1 || plugins[0].Name != "corp-vpn" || plugins[0].Version != "1.0" {
+		t.Fatalf("Discover() plugins = %+v", plugins)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "reserved") {
+		t.Errorf("Discover() errs = %v, want one reserved-type error", errs)
+	}
+	if !plugins[0].SupportsPlatform("linux") || plugins[0].SupportsPlatform("windows") {
+		t.Error("SupportsPlatform mismatch")
+	}
+
+	if plugins, errs := Discover(filepath.Join(dir, "missing")); plugins != nil || errs != nil {
+		t.Errorf("Discover(missing) = %v, %v; want nothing", plugins, errs)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	p, err := Describe(writePlugin(t, dir, "vpn", describeVPN,
+		`echo "{\"type\":\"corp_vpn_profiles\",\"items\":[{\"name\":\"hq\",\"state\":\"$OSAUDIT_PLATFORM\"}]}"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := Run(context.Background(), p, Env{RunID: "r1", Platform: "linux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Run() = %v, want summary + 1 row", rows)
+	}
+	if rows[0]["type"] != RowType || rows[0]["status"] != "ok" || rows[0]["rows"] != 1 {
+		t.Errorf("summary row = %v", rows[0])
+	}
+	row := rows[1]
+	if row["plugin"] != "corp-vpn" || row["run_id"] != "r1" {
+		t.Errorf("row not stamped: %v", row)
+	}
+	item := row["items"].([]any)[0].(map[string]any)
+	if item["state"] != "linux" {
+		t.Errorf("plugin did not see OSAUDIT_PLATFORM: %v", item)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"exit", "echo boom >&2; exit 3", "boom"},
+		{"undeclared", `echo '{"type":"other"}'`, "not declared"},
+		{"json", "echo '{nope'", "invalid JSON"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := Describe(writePlugin(t, t.TempDir(), "vpn", describeVPN, tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := Run(context.Background(), p, Env{RunID: "r1"})
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("Run() err = %v, want %q", err, tc.want)
+			}
+			if len(rows) != 1 || rows[0]["status"] != "failed" {
+				t.Errorf("Run() rows = %v, want only a failed summary row", rows)
+			}
+		})
+	}
+}
+
+// residentCollector is served by the test binary itself when
+// OSAUDIT_TEST_RESIDENT is set (see TestMain). It counts Collect calls, so a
+// reused process is visible in its rows.
+type residentCollector struct{ calls int }
+
+func (c *residentCollector) Describe() pluginsdk.Description {
+	return pluginsdk.Description{
+		Name:     "resident",
+		Version:  "1.0",
+		RowTypes: []pluginsdk.RowSpec{{Type: "resident_cache", Key: []string{"name"}}},
+	}
+}
+
+func (c *residentCollector) Negotiate(host pluginsdk.Capabilities) (bool, string) {
+	if host.Platform != "linux" && host.Platform != "mac" {
+		return false, "unsupported platform " + host.Platform
+	}
+	return true, ""
+}
+
+func (c *residentCollector) Collect(args pluginsdk.CollectArgs) ([]map[string]any, error) {
+	c.calls++
+	return []map[string]any{{"type": "resident_cache", "name": "warm", "calls": c.calls, "pid": os.Getpid()}}, nil
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv("OSAUDIT_TEST_RESIDENT") == "1" {
+		pluginsdk.Serve(&residentCollector{})
+	}
+	os.Exit(m.Run())
+}
+
+func TestResidentPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("resident plugins use unix sockets")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resident")
+	script := "#!/bin/sh\nOSAUDIT_TEST_RESIDENT=1 exec '" + os.Args[0] + "' \"$@\"\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Describe(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Protocol != pluginsdk.ProtocolRPC {
+		t.Fatalf("Protocol = %q, want rpc", p.Protocol)
+	}
+
+	env := Env{RunID: "r1", Platform: "linux", StateDir: filepath.Join(dir, ".run")}
+	t.Cleanup(func() { StopResident(env.StateDir) })
+
+	first, err := Run(context.Background(), p, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.RunID = "r2"
+	second, err := Run(context.Background(), p, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || len(second) != 2 {
+		t.Fatalf("Run() rows = %v / %v", first, second)
+	}
+	if second[1]["calls"] != float64(2) || second[1]["pid"] != first[1]["pid"] {
+		t.Errorf("second run did not reuse the resident process: %v then %v", first[1], second[1])
+	}
+	if second[1]["plugin"] != "resident" || second[1]["run_id"] != "r2" {
+		t.Errorf("row not stamped: %v", second[1])
+	}
+
+	env.Platform = "plan9"
+	declined, err := Run(context.Background(), p, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(declined) != 1 || declined[0]["status"] != "skipped" {
+		t.Errorf("Run() on an unsupported host = %v, want a skipped summary", declined)
+	}
+
+	stopped, err := StopResident(env.StateDir)
+	if err != nil || len(stopped) != 1 || stopped[0] != "resident" {
+		t.Errorf("StopResident() = %v, %v", stopped, err)
+	}
+}
+
+func TestParseHandshake(t *testing.T) {
+	st, err := parseHandshake("1|1|unix|/tmp/x.sock|jsonrpc\n")
+	if err != nil || st.Addr != "/tmp/x.sock" || st.ProtocolVersion != 1 {
+		t.Errorf("parseHandshake() = %+v, %v", st, err)
+	}
+	for _, bad := range []string{"", "1|1|unix|/tmp/x.sock", "2|1|unix|/x|jsonrpc", "1|9|unix|/x|jsonrpc", "1|1|tcp|x:1|grpc"} {
+		if _, err := parseHandshake(bad); err == nil {
+			t.Errorf("parseHandshake(%q) succeeded", bad)
+		}
+	}
+}
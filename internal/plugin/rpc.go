@@ -0,0 +1,271 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+	"github.com/kareemsasa/operating-system-audit/pluginsdk"
+)
+
+// Resident plugins speak the pluginsdk handshake: osaudit starts the binary
+// with the magic cookie and its protocol versions in the environment, reads one
+// "core|proto|unix|addr|jsonrpc" line from stdout, and dials addr. The process
+// is left running and recorded in Env.StateDir, so the next run reattaches
+// instead of paying the start-up cost again. A plugin whose binary changed
+// since it was started is replaced.
+
+const dialTimeout = 2 * time.Second
+
+// supportedVersions are the Plugin service versions this host speaks.
+var supportedVersions = []int{pluginsdk.ProtocolVersion}
+
+// residentState is what StateDir/<name>.json records about a running plugin.
+type residentState struct {
+	PID             int       `json:"pid"`
+	Addr            string    `json:"addr"`
+	Path            string    `json:"path"`
+	ModTime         time.Time `json:"mod_time"`
+	ProtocolVersion int       `json:"protocol_version"`
+}
+
+func collectResident(ctx context.Context, p Plugin, env Env) (rows []diff.Row, declined string, err error) {
+	client, version, release, err := connect(p, env)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+
+	var nr pluginsdk.NegotiateReply
+	host := pluginsdk.Capabilities{Platform: env.Platform, Root: env.Privileged}
+	if err := call(ctx, client, "Negotiate", pluginsdk.NegotiateArgs{ProtocolVersion: version, Host: host}, &nr, describeTimeout); err != nil {
+		return nil, "", fmt.Errorf("plugin %s: negotiate: %w", p.Name, err)
+	}
+	if !nr.Accepted {
+		if nr.Reason == "" {
+			nr.Reason = "declined by plugin"
+		}
+		return nil, nr.Reason, nil
+	}
+
+	var cr pluginsdk.CollectReply
+	args := pluginsdk.CollectArgs{RunID: env.RunID, Platform: env.Platform, Root: env.Root}
+	if err := call(ctx, client, "Collect", args, &cr, p.timeout()); err != nil {
+		return nil, "", fmt.Errorf("plugin %s: collect: %w", p.Name, err)
+	}
+	for _, r := range cr.Rows {
+		rows = append(rows, diff.Row(r))
+	}
+	return rows, "", nil
+}
+
+// connect reattaches to p's resident process or starts a new one. release
+// closes the connection, and stops the plugin when there is no StateDir to
+// remember it in.
+func connect(p Plugin, env Env) (client *rpc.Client, version int, release func(), err error) {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	statePath := ""
+	if env.StateDir != "" {
+		statePath = filepath.Join(env.StateDir, p.Name+".json")
+		if st, ok := loadState(statePath); ok {
+			if c, err := dial(st.Addr); err == nil {
+				if st.Path == p.Path && st.ModTime.Equal(info.ModTime()) {
+					return c, st.ProtocolVersion, func() { c.Close() }, nil
+				}
+				// The binary changed: replace the old process.
+				call(context.Background(), c, "Shutdown", struct{}{}, &struct{}{}, dialTimeout)
+				c.Close()
+			}
+			writeguard.Remove(statePath)
+		}
+	}
+
+	st, err := start(p, env)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	st.ModTime = info.ModTime()
+	c, err := dial(st.Addr)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("plugin %s: dial %s: %w", p.Name, st.Addr, err)
+	}
+	if statePath == "" {
+		return c, st.ProtocolVersion, func() {
+			call(context.Background(), c, "Shutdown", struct{}{}, &struct{}{}, dialTimeout)
+			c.Close()
+		}, nil
+	}
+	if err := saveState(statePath, st); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: plugin %s: %v\n", p.Name, err)
+	}
+	return c, st.ProtocolVersion, func() { c.Close() }, nil
+}
+
+// start launches p and completes the handshake.
+func start(p Plugin, env Env) (residentState, error) {
+	versions := make([]string, len(supportedVersions))
+	for i, v := range supportedVersions {
+		versions[i] = strconv.Itoa(v)
+	}
+	cmd := exec.Command(p.Path)
+	cmd.Env = append(os.Environ(),
+		pluginsdk.MagicCookieKey+"="+pluginsdk.MagicCookieValue,
+		pluginsdk.ProtocolVersionsKey+"="+strings.Join(versions, ","),
+		"OSAUDIT_PLATFORM="+env.Platform,
+		"OSAUDIT_ROOT="+env.Root,
+	)
+	if env.StateDir != "" {
+		if err := writeguard.MkdirAll(env.StateDir, 0o700); err == nil {
+			if f, err := writeguard.OpenFile(filepath.Join(env.StateDir, p.Name+".log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600); err == nil {
+				cmd.Stderr = f
+				defer f.Close()
+			}
+		}
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return residentState{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return residentState{}, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	go cmd.Wait() // reap the plugin if it exits while osaudit is running
+
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(stdout).ReadString('\n')
+		lines <- line
+	}()
+	var line string
+	select {
+	case line = <-lines:
+	case <-time.After(describeTimeout):
+		cmd.Process.Kill()
+		return residentState{}, fmt.Errorf("plugin %s: no handshake within %s", p.Name, describeTimeout)
+	}
+	st, err := parseHandshake(line)
+	if err != nil {
+		cmd.Process.Kill()
+		return residentState{}, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	st.PID = cmd.Process.Pid
+	st.Path = p.Path
+	return st, nil
+}
+
+// parseHandshake parses "core|proto|network|addr|codec".
+func parseHandshake(line string) (residentState, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return residentState{}, errors.New("plugin exited before the handshake")
+	}
+	parts := strings.Split(line, "|")
+	if len(parts) != 5 {
+		return residentState{}, fmt.Errorf("invalid handshake %q", line)
+	}
+	if core, err := strconv.Atoi(parts[0]); err != nil || core != pluginsdk.CoreProtocolVersion {
+		return residentState{}, fmt.Errorf("unsupported core protocol version %q (want %d)", parts[0], pluginsdk.CoreProtocolVersion)
+	}
+	version, err := strconv.Atoi(parts[1])
+	if err != nil || !supportsVersion(version) {
+		return residentState{}, fmt.Errorf("unsupported protocol version %q", parts[1])
+	}
+	if parts[2] != "unix" || parts[4] != "jsonrpc" {
+		return residentState{}, fmt.Errorf("unsupported transport %s/%s (want unix/jsonrpc)", parts[2], parts[4])
+	}
+	return residentState{Addr: parts[3], ProtocolVersion: version}, nil
+}
+
+func supportsVersion(v int) bool {
+	for _, s := range supportedVersions {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func dial(addr string) (*rpc.Client, error) {
+	conn, err := net.DialTimeout("unix", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.NewClient(conn), nil
+}
+
+// call invokes Plugin.<method>, giving up after timeout or when ctx is done.
+func call(ctx context.Context, c *rpc.Client, method string, args, reply any, timeout time.Duration) error {
+	done := c.Go(pluginsdk.ServiceName+"."+method, args, reply, make(chan *rpc.Call, 1)).Done
+	select {
+	case res := <-done:
+		return res.Error
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %s", timeout)
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+func loadState(path string) (residentState, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return residentState{}, false
+	}
+	var st residentState
+	if json.Unmarshal(data, &st) != nil || st.Addr == "" {
+		return residentState{}, false
+	}
+	return st, true
+}
+
+func saveState(path string, st residentState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := writeguard.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return writeguard.Rename(tmp, path)
+}
+
+// StopResident asks every resident plugin recorded in stateDir to exit and
+// returns the names of those that were running.
+func StopResident(stateDir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(stateDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var stopped []string
+	for _, path := range paths {
+		st, ok := loadState(path)
+		if !ok {
+			continue
+		}
+		if c, err := dial(st.Addr); err == nil {
+			if call(context.Background(), c, "Shutdown", struct{}{}, &struct{}{}, dialTimeout) == nil {
+				stopped = append(stopped, strings.TrimSuffix(filepath.Base(path), ".json"))
+			}
+			c.Close()
+		}
+		writeguard.Remove(path)
+	}
+	return stopped, nil
+}
@@ -0,0 +1,1080 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file implements the subset of CEL (https://github.com/google/cel-spec)
+// that policy expressions need, evaluated over decoded NDJSON values:
+//
+//	literals     null, true, false, 1, 2.5, "str", 'str', [a, b], {"k": v}
+//	access       a.b, a["b"], list[0], has(a.b)
+//	operators    ! - * / % + - < <= > >= == != in && || ?:
+//	functions    size(x), x.size(), int(x), double(x), string(x),
+//	             s.contains(t), s.startsWith(t), s.endsWith(t), s.matches(re),
+//	             s.lowerAscii(), s.upperAscii()
+//	macros       l.exists(x, p), l.all(x, p), l.exists_one(x, p),
+//	             l.filter(x, p), l.map(x, e)
+//
+// Numbers are float64 (JSON has no int/double split). Missing keys raise
+// "no such key" errors, and && / || absorb errors the way CEL does, so
+// `has(a.b) && a.b > 1` and `false && a.missing` both evaluate cleanly.
+// String literals take CEL's escapes; an unknown one is a compile error.
+
+// Program is a compiled expression.
+type Program struct {
+	src  string
+	root node
+}
+
+// Compile parses expr and checks function names.
+func Compile(expr string) (*Program, error) {
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.peek().text, p.peek().pos)
+	}
+	return &Program{src: expr, root: root}, nil
+}
+
+// Eval evaluates the program with the given top-level variables.
+func (p *Program) Eval(vars map[string]any) (any, error) {
+	return p.root.eval(&scope{vars: vars})
+}
+
+// String returns the source expression.
+func (p *Program) String() string { return p.src }
+
+// RowTypes returns the row types the expression reads, as top-level variables
+// or through rows.<type>, sorted. A rule over several types is a composite
+// check, and its violations link to each of them.
+func (p *Program) RowTypes() []string {
+	seen := make(map[string]bool)
+	collectRowTypes(p.root, nil, seen)
+	out := make([]string, 0, len(seen))
+	for t := range seen {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// collectRowTypes walks n, skipping the variables macros bind.
+func collectRowTypes(n node, bound []string, seen map[string]bool) {
+	switch n := n.(type) {
+	case *identNode:
+		if n.name != "rows" && !slices.Contains(bound, n.name) {
+			seen[n.name] = true
+		}
+	case *memberNode:
+		if id, ok := n.x.(*identNode); ok && id.name == "rows" && !slices.Contains(bound, "rows") {
+			seen[n.name] = true
+			return
+		}
+		collectRowTypes(n.x, bound, seen)
+	case *indexNode:
+		collectRowTypes(n.x, bound, seen)
+		collectRowTypes(n.idx, bound, seen)
+	case *hasNode:
+		collectRowTypes(n.m, bound, seen)
+	case *unaryNode:
+		collectRowTypes(n.x, bound, seen)
+	case *binaryNode:
+		collectRowTypes(n.l, bound, seen)
+		collectRowTypes(n.r, bound, seen)
+	case *condNode:
+		collectRowTypes(n.c, bound, seen)
+		collectRowTypes(n.t, bound, seen)
+		collectRowTypes(n.f, bound, seen)
+	case *listNode:
+		for _, e := range n.elems {
+			collectRowTypes(e, bound, seen)
+		}
+	case *mapNode:
+		for i := range n.keys {
+			collectRowTypes(n.keys[i], bound, seen)
+			collectRowTypes(n.vals[i], bound, seen)
+		}
+	case *callNode:
+		if n.target != nil {
+			collectRowTypes(n.target, bound, seen)
+		}
+		for _, a := range n.args {
+			collectRowTypes(a, bound, seen)
+		}
+	case *macroNode:
+		collectRowTypes(n.target, bound, seen)
+		collectRowTypes(n.body, append(slices.Clip(bound), n.v), seen)
+	}
+}
+
+// --- lexer ---
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNum
+	tokStr
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+	num  float64
+}
+
+var twoCharOps = map[string]bool{"==": true, "!=": true, "<=": true, ">=": true, "&&": true, "||": true}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' || src[j] == 'e' || src[j] == 'E' ||
+				(src[j] == '-' || src[j] == '+') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+				j++
+			}
+			text := src[i:j]
+			// Allow CEL's unsigned suffix.
+			if j < len(src) && (src[j] == 'u' || src[j] == 'U') {
+				j++
+			}
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad number %q at offset %d", text, i)
+			}
+			toks = append(toks, token{kind: tokNum, text: text, pos: i, num: f})
+			i = j
+		case c == '"' || c == '\'':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					r, n, err := lexEscape(src[j+1:])
+					if err != nil {
+						return nil, fmt.Errorf("%v at offset %d", err, j)
+					}
+					sb.WriteRune(r)
+					j += n
+					continue
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			toks = append(toks, token{kind: tokStr, text: sb.String(), pos: i})
+			i = j + 1
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			if i+1 < len(src) && twoCharOps[src[i:i+2]] {
+				toks = append(toks, token{kind: tokOp, text: src[i : i+2], pos: i})
+				i += 2
+				continue
+			}
+			if !strings.ContainsRune("()[]{}.,?:!-+*/%<>", rune(c)) {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			toks = append(toks, token{kind: tokOp, text: string(c), pos: i})
+			i++
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// simpleEscapes maps the character after a backslash to what it stands for,
+// for CEL's single-character escapes.
+var simpleEscapes = map[byte]rune{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+	'\\': '\\', '\'': '\'', '"': '"', '`': '`', '?': '?',
+}
+
+// lexEscape decodes the escape sequence at the start of s, which follows a
+// backslash in a string literal, and returns the code point and how many bytes
+// of s it took. Besides the single-character escapes, CEL has \xHH, \uHHHH,
+// \UHHHHHHHH, and three-digit octal \ooo; anything else is an error, so a
+// regex written as '\d+' is not silently read as 'd+'.
+func lexEscape(s string) (rune, int, error) {
+	if r, ok := simpleEscapes[s[0]]; ok {
+		return r, 1, nil
+	}
+	digits, base := 0, 16
+	switch {
+	case s[0] == 'x' || s[0] == 'X':
+		digits = 2
+	case s[0] == 'u':
+		digits = 4
+	case s[0] == 'U':
+		digits = 8
+	case s[0] >= '0' && s[0] <= '3':
+		digits, base = 3, 8
+	default:
+		return 0, 0, fmt.Errorf("invalid escape \\%c", s[0])
+	}
+	start := 1
+	if base == 8 {
+		start = 0
+	}
+	if len(s) < start+digits {
+		return 0, 0, fmt.Errorf("short escape \\%c", s[0])
+	}
+	v, err := strconv.ParseUint(s[start:start+digits], base, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid escape \\%s", s[:start+digits])
+	}
+	r := rune(v)
+	if !utf8.ValidRune(r) {
+		return 0, 0, fmt.Errorf("escape \\%s is not a valid code point", s[:start+digits])
+	}
+	return r, start + digits, nil
+}
+
+// --- parser ---
+
+type exprParser struct {
+	toks []token
+	pos  int
+}
+
+func (p *exprParser) peek() token { return p.toks[p.pos] }
+
+func (p *exprParser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("expected %q at offset %d, got %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *exprParser) expr() (node, error) {
+	c, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("?") {
+		return c, nil
+	}
+	t, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	f, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &condNode{c, t, f}, nil
+}
+
+var binaryPrec = []map[string]bool{
+	{"||": true},
+	{"&&": true},
+	{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "in": true},
+	{"+": true, "-": true},
+	{"*": true, "/": true, "%": true},
+}
+
+func (p *exprParser) binary(level int) (node, error) {
+	if level == len(binaryPrec) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if (t.kind != tokOp && !(t.kind == tokIdent && t.text == "in")) || !binaryPrec[level][t.text] {
+			return left, nil
+		}
+		p.next()
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: t.text, l: left, r: right}
+	}
+}
+
+func (p *exprParser) unary() (node, error) {
+	if p.accept("!") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "!", x: x}, nil
+	}
+	if p.accept("-") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "-", x: x}, nil
+	}
+	return p.postfix()
+}
+
+func (p *exprParser) postfix() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, fmt.Errorf("expected field name at offset %d", t.pos)
+			}
+			if p.accept("(") {
+				args, err := p.args(")")
+				if err != nil {
+					return nil, err
+				}
+				if x, err = newCall(x, t.text, args); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			x = &memberNode{x: x, name: t.text}
+		case p.accept("["):
+			idx, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexNode{x: x, idx: idx}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *exprParser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNum:
+		return &litNode{t.num}, nil
+	case tokStr:
+		return &litNode{t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &litNode{true}, nil
+		case "false":
+			return &litNode{false}, nil
+		case "null":
+			return &litNode{nil}, nil
+		}
+		if p.accept("(") {
+			args, err := p.args(")")
+			if err != nil {
+				return nil, err
+			}
+			return newCall(nil, t.text, args)
+		}
+		return &identNode{t.text}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			elems, err := p.args("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{elems}, nil
+		case "{":
+			m := &mapNode{}
+			for !p.accept("}") {
+				k, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				v, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				m.keys, m.vals = append(m.keys, k), append(m.vals, v)
+				if !p.accept(",") {
+					if err := p.expect("}"); err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+			return m, nil
+		}
+	}
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+func (p *exprParser) args(closer string) ([]node, error) {
+	var out []node
+	if p.accept(closer) {
+		return out, nil
+	}
+	for {
+		a, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+		if p.accept(closer) {
+			return out, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+var (
+	macros       = map[string]bool{"exists": true, "all": true, "exists_one": true, "filter": true, "map": true}
+	globalFuncs  = map[string]int{"size": 1, "int": 1, "double": 1, "string": 1, "has": 1}
+	receiverFunc = map[string]int{"size": 0, "contains": 1, "startsWith": 1, "endsWith": 1, "matches": 1, "lowerAscii": 0, "upperAscii": 0}
+)
+
+func newCall(target node, name string, args []node) (node, error) {
+	if target != nil && macros[name] {
+		v, ok := firstIdent(args)
+		if len(args) != 2 || !ok {
+			return nil, fmt.Errorf("%s() expects (var, expr)", name)
+		}
+		return &macroNode{name: name, target: target, v: v, body: args[1]}, nil
+	}
+	arity, ok := receiverFunc[name]
+	if target == nil {
+		arity, ok = globalFuncs[name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("undeclared function %s()", name)
+	}
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s() expects %d argument(s), got %d", name, arity, len(args))
+	}
+	if name == "has" {
+		m, ok := args[0].(*memberNode)
+		if !ok {
+			return nil, fmt.Errorf("has() argument must be a field selection like a.b")
+		}
+		return &hasNode{m}, nil
+	}
+	if name == "matches" {
+		if lit, ok := args[0].(*litNode); ok {
+			s, _ := lit.v.(string)
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("matches(): %w", err)
+			}
+			return &callNode{target: target, name: name, args: args, re: re}, nil
+		}
+	}
+	return &callNode{target: target, name: name, args: args}, nil
+}
+
+func firstIdent(args []node) (string, bool) {
+	if len(args) == 0 {
+		return "", false
+	}
+	id, ok := args[0].(*identNode)
+	if !ok {
+		return "", false
+	}
+	return id.name, true
+}
+
+// --- evaluation ---
+
+type scope struct {
+	vars   map[string]any
+	parent *scope
+}
+
+func (s *scope) lookup(name string) (any, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+type node interface {
+	eval(s *scope) (any, error)
+}
+
+type (
+	litNode    struct{ v any }
+	identNode  struct{ name string }
+	memberNode struct {
+		x    node
+		name string
+	}
+	indexNode struct{ x, idx node }
+	hasNode   struct{ m *memberNode }
+	unaryNode struct {
+		op string
+		x  node
+	}
+	binaryNode struct {
+		op   string
+		l, r node
+	}
+	condNode struct{ c, t, f node }
+	listNode struct{ elems []node }
+	mapNode  struct{ keys, vals []node }
+	callNode struct {
+		target node
+		name   string
+		args   []node
+		re     *regexp.Regexp
+	}
+	macroNode struct {
+		name   string
+		target node
+		v      string
+		body   node
+	}
+)
+
+func (n *litNode) eval(*scope) (any, error) { return n.v, nil }
+
+func (n *identNode) eval(s *scope) (any, error) {
+	if v, ok := s.lookup(n.name); ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("undeclared reference to '%s'", n.name)
+}
+
+func (n *memberNode) eval(s *scope) (any, error) {
+	x, err := n.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := x.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("cannot select field '%s' from %s", n.name, typeName(x))
+	}
+	v, ok := m[n.name]
+	if !ok {
+		return nil, fmt.Errorf("no such key: %s", n.name)
+	}
+	return v, nil
+}
+
+func (n *indexNode) eval(s *scope) (any, error) {
+	x, err := n.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := n.idx.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	switch c := x.(type) {
+	case map[string]any:
+		k, ok := idx.(string)
+		if !ok {
+			return nil, fmt.Errorf("map index must be a string, got %s", typeName(idx))
+		}
+		v, ok := c[k]
+		if !ok {
+			return nil, fmt.Errorf("no such key: %s", k)
+		}
+		return v, nil
+	case []any:
+		f, ok := idx.(float64)
+		if !ok || f != float64(int(f)) {
+			return nil, fmt.Errorf("list index must be an integer, got %v", idx)
+		}
+		if int(f) < 0 || int(f) >= len(c) {
+			return nil, fmt.Errorf("index out of range: %d", int(f))
+		}
+		return c[int(f)], nil
+	}
+	return nil, fmt.Errorf("cannot index %s", typeName(x))
+}
+
+func (n *hasNode) eval(s *scope) (any, error) {
+	x, err := n.m.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := x.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("has(): cannot select field '%s' from %s", n.m.name, typeName(x))
+	}
+	_, present := m[n.m.name]
+	return present, nil
+}
+
+func (n *unaryNode) eval(s *scope) (any, error) {
+	x, err := n.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("no such overload: !%s", typeName(x))
+		}
+		return !b, nil
+	}
+	f, ok := x.(float64)
+	if !ok {
+		return nil, fmt.Errorf("no such overload: -%s", typeName(x))
+	}
+	return -f, nil
+}
+
+func (n *binaryNode) eval(s *scope) (any, error) {
+	if n.op == "&&" || n.op == "||" {
+		return n.logical(s)
+	}
+	l, err := n.l.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.r.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return valuesEqual(l, r), nil
+	case "!=":
+		return !valuesEqual(l, r), nil
+	case "in":
+		switch c := r.(type) {
+		case []any:
+			for _, e := range c {
+				if valuesEqual(l, e) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]any:
+			k, ok := l.(string)
+			if !ok {
+				return false, nil
+			}
+			_, found := c[k]
+			return found, nil
+		}
+		return nil, fmt.Errorf("no such overload: %s in %s", typeName(l), typeName(r))
+	case "<", "<=", ">", ">=":
+		cmp, err := compareValues(l, r)
+		if err != nil {
+			return nil, fmt.Errorf("no such overload: %s %s %s", typeName(l), n.op, typeName(r))
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	}
+	return arithmetic(n.op, l, r)
+}
+
+// logical implements CEL's commutative && / ||: a definite result on either side
+// wins over an error on the other.
+func (n *binaryNode) logical(s *scope) (any, error) {
+	short := n.op == "||" // value that decides the result
+	l, lerr := n.l.eval(s)
+	lb, lok := l.(bool)
+	if lerr == nil && !lok {
+		lerr = fmt.Errorf("no such overload: %s %s _", typeName(l), n.op)
+	}
+	if lerr == nil && lb == short {
+		return short, nil
+	}
+	r, rerr := n.r.eval(s)
+	rb, rok := r.(bool)
+	if rerr == nil && !rok {
+		rerr = fmt.Errorf("no such overload: _ %s %s", n.op, typeName(r))
+	}
+	if rerr == nil && rb == short {
+		return short, nil
+	}
+	if lerr != nil {
+		return nil, lerr
+	}
+	if rerr != nil {
+		return nil, rerr
+	}
+	return !short, nil
+}
+
+func (n *condNode) eval(s *scope) (any, error) {
+	c, err := n.c.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := c.(bool)
+	if !ok {
+		return nil, fmt.Errorf("ternary condition must be bool, got %s", typeName(c))
+	}
+	if b {
+		return n.t.eval(s)
+	}
+	return n.f.eval(s)
+}
+
+func (n *listNode) eval(s *scope) (any, error) {
+	out := make([]any, 0, len(n.elems))
+	for _, e := range n.elems {
+		v, err := e.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (n *mapNode) eval(s *scope) (any, error) {
+	out := make(map[string]any, len(n.keys))
+	for i := range n.keys {
+		k, err := n.keys[i].eval(s)
+		if err != nil {
+			return nil, err
+		}
+		ks, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("map keys must be strings, got %s", typeName(k))
+		}
+		v, err := n.vals[i].eval(s)
+		if err != nil {
+			return nil, err
+		}
+		out[ks] = v
+	}
+	return out, nil
+}
+
+func (n *callNode) eval(s *scope) (any, error) {
+	var args []any
+	if n.target != nil {
+		t, err := n.target.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, t)
+	}
+	for _, a := range n.args {
+		v, err := a.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	switch n.name {
+	case "size":
+		switch v := args[0].(type) {
+		case string:
+			return float64(len([]rune(v))), nil
+		case []any:
+			return float64(len(v)), nil
+		case map[string]any:
+			return float64(len(v)), nil
+		}
+	case "int":
+		switch v := args[0].(type) {
+		case float64:
+			// int64 covers [-2^63, 2^63); NaN fails both comparisons.
+			if !(v >= math.MinInt64 && v < -math.MinInt64) {
+				return nil, fmt.Errorf("int(): %v overflows int", v)
+			}
+			return float64(int64(v)), nil
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("int(): %w", err)
+			}
+			return float64(i), nil
+		case bool:
+			if v {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		}
+	case "double":
+		switch v := args[0].(type) {
+		case float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("double(): %w", err)
+			}
+			return f, nil
+		}
+	case "string":
+		switch v := args[0].(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+	default:
+		str, ok := args[0].(string)
+		if !ok {
+			break
+		}
+		if n.name == "lowerAscii" {
+			return strings.ToLower(str), nil
+		}
+		if n.name == "upperAscii" {
+			return strings.ToUpper(str), nil
+		}
+		arg, ok := args[1].(string)
+		if !ok {
+			break
+		}
+		switch n.name {
+		case "contains":
+			return strings.Contains(str, arg), nil
+		case "startsWith":
+			return strings.HasPrefix(str, arg), nil
+		case "endsWith":
+			return strings.HasSuffix(str, arg), nil
+		case "matches":
+			re := n.re
+			if re == nil {
+				var err error
+				if re, err = regexp.Compile(arg); err != nil {
+					return nil, fmt.Errorf("matches(): %w", err)
+				}
+			}
+			return re.MatchString(str), nil
+		}
+	}
+	types := make([]string, len(args))
+	for i, a := range args {
+		types[i] = typeName(a)
+	}
+	return nil, fmt.Errorf("no such overload: %s(%s)", n.name, strings.Join(types, ", "))
+}
+
+func (n *macroNode) eval(s *scope) (any, error) {
+	t, err := n.target.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	var elems []any
+	switch c := t.(type) {
+	case []any:
+		elems = c
+	case map[string]any:
+		for k := range c {
+			elems = append(elems, k)
+		}
+	default:
+		return nil, fmt.Errorf("%s() requires a list or map, got %s", n.name, typeName(t))
+	}
+	var (
+		out     []any
+		matches int
+		lastErr error
+	)
+	for _, e := range elems {
+		v, err := n.body.eval(&scope{vars: map[string]any{n.v: e}, parent: s})
+		if n.name == "map" {
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		b, ok := v.(bool)
+		if err == nil && !ok {
+			err = fmt.Errorf("%s() predicate must be bool, got %s", n.name, typeName(v))
+		}
+		if err != nil {
+			if n.name == "filter" || n.name == "exists_one" {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+		switch {
+		case n.name == "exists" && b:
+			return true, nil
+		case n.name == "all" && !b:
+			return false, nil
+		case b:
+			matches++
+			if n.name == "filter" {
+				out = append(out, e)
+			}
+		}
+	}
+	switch n.name {
+	case "exists", "all":
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return n.name == "all", nil
+	case "exists_one":
+		return matches == 1, nil
+	}
+	if out == nil {
+		out = []any{}
+	}
+	return out, nil
+}
+
+func valuesEqual(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func compareValues(a, b any) (int, error) {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1, nil
+			case av > bv:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), nil
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0, nil
+			case !av:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, fmt.Errorf("incomparable")
+}
+
+func arithmetic(op string, l, r any) (any, error) {
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			break
+		}
+		switch op {
+		case "+":
+			return lv + rv, nil
+		case "-":
+			return lv - rv, nil
+		case "*":
+			return lv * rv, nil
+		case "/", "%":
+			if rv == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if op == "/" {
+				return lv / rv, nil
+			}
+			return float64(int64(lv) % int64(rv)), nil
+		}
+	case string:
+		if rv, ok := r.(string); ok && op == "+" {
+			return lv + rv, nil
+		}
+	case []any:
+		if rv, ok := r.([]any); ok && op == "+" {
+			return append(append([]any{}, lv...), rv...), nil
+		}
+	}
+	return nil, fmt.Errorf("no such overload: %s %s %s", typeName(l), op, typeName(r))
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,90 @@
+package policy
+
+import "sort"
+
+// HostTally counts one rule's outcomes over the snapshots of one host.
+type HostTally struct {
+	Host       string
+	Snapshots  int
+	Violations int
+	Unknown    int
+}
+
+// RuleHistory is how a rule fared across stored snapshots: the violations it
+// would have reported in total and per host.
+type RuleHistory struct {
+	Rule       Rule
+	Violations int
+	Hosts      []HostTally // sorted by violations, most first, then host
+}
+
+// History tallies a policy's results over many snapshots, so a new policy can
+// be tried against stored history before it raises any alert.
+type History struct {
+	Policy    Policy
+	Snapshots int
+	hosts     map[string]bool
+	tallies   []map[string]*HostTally // per rule, by host
+}
+
+// NewHistory returns an empty history of p.
+func NewHistory(p Policy) *History {
+	h := &History{Policy: p, hosts: make(map[string]bool), tallies: make([]map[string]*HostTally, len(p.Rules))}
+	for i := range h.tallies {
+		h.tallies[i] = make(map[string]*HostTally)
+	}
+	return h
+}
+
+// Add records res, the policy evaluated against one snapshot of host.
+func (h *History) Add(host string, res Result) {
+	h.Snapshots++
+	h.hosts[host] = true
+	for i, rr := range res.Rules {
+		if i >= len(h.tallies) {
+			break
+		}
+		t := h.tallies[i][host]
+		if t == nil {
+			t = &HostTally{Host: host}
+			h.tallies[i][host] = t
+		}
+		t.Snapshots++
+		switch rr.Status {
+		case StatusViolation:
+			t.Violations++
+		case StatusUnknown:
+			t.Unknown++
+		}
+	}
+}
+
+// Hosts returns the hosts seen, sorted.
+func (h *History) Hosts() []string {
+	out := make([]string, 0, len(h.hosts))
+	for host := range h.hosts {
+		out = append(out, host)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Rules returns each rule's history, in policy order.
+func (h *History) Rules() []RuleHistory {
+	out := make([]RuleHistory, len(h.Policy.Rules))
+	for i, r := range h.Policy.Rules {
+		rh := RuleHistory{Rule: r}
+		for _, t := range h.tallies[i] {
+			rh.Violations += t.Violations
+			rh.Hosts = append(rh.Hosts, *t)
+		}
+		sort.Slice(rh.Hosts, func(a, b int) bool {
+			if rh.Hosts[a].Violations != rh.Hosts[b].Violations {
+				return rh.Hosts[a].Violations > rh.Hosts[b].Violations
+			}
+			return rh.Hosts[a].Host < rh.Hosts[b].Host
+		})
+		out[i] = rh
+	}
+	return out
+}
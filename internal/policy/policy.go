@@ -0,0 +1,197 @@
+// Package policy evaluates user-supplied policy files: named rules whose
+// conditions are CEL expressions over snapshot rows, e.g.
+//
+//	security_config.filevault == true && counts.broken_symlinks < 10
+//
+// Each row type is a top-level variable holding the last row of that type (the
+// same row diff and benchmark see). `rows.<type>` lists every row of a type,
+// for multi-instance types such as large_file.
+package policy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/kb"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
+)
+
+const (
+	StatusPass      = "pass"
+	StatusViolation = "violation"
+	StatusUnknown   = "unknown"
+)
+
+// Policy is a named set of rules loaded from a policy file.
+type Policy struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Rules       []Rule `json:"rules"`
+	// Scoring grades results; without it a policy reports verdicts only.
+	Scoring *Scoring `json:"scoring,omitempty"`
+}
+
+// Rule is one policy condition. Expr must evaluate to true for the snapshot to
+// comply; Message explains a violation. Doc is a runbook URL or a knowledge base
+// article ID (see `osaudit explain`).
+type Rule struct {
+	ID       string `json:"id"`
+	Expr     string `json:"expr"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Doc      string `json:"doc,omitempty"`
+	// Topic groups rules for Scoring.Weights, e.g. encryption or network.
+	Topic string `json:"topic,omitempty"`
+
+	program *Program
+}
+
+// DocURL resolves Doc to a link: URLs are returned as-is, article IDs through the
+// knowledge base.
+func (r Rule) DocURL() string {
+	if r.Doc == "" || strings.Contains(r.Doc, "://") {
+		return r.Doc
+	}
+	return kb.DocURL(r.Doc)
+}
+
+// RuleResult is the outcome of evaluating one rule. Rows lists the row types
+// the rule's expression reads, so a violation of a rule that correlates several
+// types links to each of them.
+type RuleResult struct {
+	Rule   Rule
+	Status string
+	Reason string
+	Rows   []string
+}
+
+// Result is the outcome of evaluating a policy against a snapshot.
+type Result struct {
+	Policy     Policy
+	Rules      []RuleResult
+	Pass       int
+	Violations int
+	Unknown    int
+}
+
+// Load reads and compiles a policy file (YAML or JSON).
+func Load(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return Policy{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse decodes and compiles a policy document. Every rule needs an id and a
+// compilable expr; ids must be unique.
+func Parse(data []byte) (Policy, error) {
+	var p Policy
+	if err := yamlite.Unmarshal(data, &p); err != nil {
+		return Policy{}, err
+	}
+	if len(p.Rules) == 0 {
+		return Policy{}, fmt.Errorf("policy has no rules")
+	}
+	seen := make(map[string]bool, len(p.Rules))
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.ID == "" || r.Expr == "" {
+			return Policy{}, fmt.Errorf("rules[%d]: id and expr are required", i)
+		}
+		if seen[r.ID] {
+			return Policy{}, fmt.Errorf("rules[%d]: duplicate id %q", i, r.ID)
+		}
+		seen[r.ID] = true
+		prog, err := Compile(r.Expr)
+		if err != nil {
+			return Policy{}, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		r.program = prog
+	}
+	if p.Scoring != nil {
+		if err := p.Scoring.validate(p.Rules); err != nil {
+			return Policy{}, err
+		}
+	}
+	return p, nil
+}
+
+// Evaluate runs every rule in p against the snapshot rows. A rule whose
+// expression errors (e.g. the field is missing from the snapshot) is unknown,
+// not a violation.
+func Evaluate(p Policy, rows []diff.Row) Result {
+	vars := Vars(rows)
+	res := Result{Policy: p}
+	for _, r := range p.Rules {
+		rr := evaluateRule(r, vars)
+		switch rr.Status {
+		case StatusPass:
+			res.Pass++
+		case StatusViolation:
+			res.Violations++
+		default:
+			res.Unknown++
+		}
+		res.Rules = append(res.Rules, rr)
+	}
+	return res
+}
+
+func evaluateRule(r Rule, vars map[string]any) RuleResult {
+	rr := RuleResult{Rule: r, Status: StatusUnknown}
+	prog := r.program
+	if prog == nil {
+		var err error
+		if prog, err = Compile(r.Expr); err != nil {
+			rr.Reason = err.Error()
+			return rr
+		}
+	}
+	rr.Rows = prog.RowTypes()
+	out, err := prog.Eval(vars)
+	if err != nil {
+		rr.Reason = err.Error()
+		return rr
+	}
+	ok, isBool := out.(bool)
+	switch {
+	case !isBool:
+		rr.Reason = fmt.Sprintf("expression returned %s, want bool", typeName(out))
+	case ok:
+		rr.Status = StatusPass
+	default:
+		rr.Status = StatusViolation
+		rr.Reason = r.Message
+		if rr.Reason == "" {
+			rr.Reason = "expression is false: " + r.Expr
+		}
+	}
+	return rr
+}
+
+// Vars builds the expression variables for a snapshot.
+func Vars(rows []diff.Row) map[string]any {
+	vars := make(map[string]any)
+	all := make(map[string]any)
+	for _, row := range rows {
+		t, ok := row["type"].(string)
+		if !ok {
+			continue
+		}
+		m := map[string]any(row)
+		vars[t] = m
+		list, _ := all[t].([]any)
+		all[t] = append(list, m)
+	}
+	if _, clash := vars["rows"]; !clash {
+		vars["rows"] = all
+	}
+	return vars
+}
@@ -0,0 +1,283 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+func TestCompileEval_Expressions(t *testing.T) {
+	vars := map[string]any{
+		"security_config": map[string]any{"filevault": true, "firewall": false},
+		"counts":          map[string]any{"broken_symlinks": 3.0},
+		"listening_ports": map[string]any{"items": []any{
+			map[string]any{"process": "sshd", "port": 22.0, "bind": "0.0.0.0"},
+			map[string]any{"process": "cupsd", "port": 631.0, "bind": "127.0.0.1"},
+		}},
+	}
+	cases := []struct {
+		expr string
+		want any
+	}{
+		{`security_config.filevault == true && counts.broken_symlinks < 10`, true},
+		{`!security_config.firewall || counts.broken_symlinks > 100`, true},
+		{`listening_ports.items.filter(p, p.bind == "0.0.0.0").size() <= 1`, true},
+		{`listening_ports.items.exists(p, p.port == 22 && p.process.startsWith("ssh"))`, true},
+		{`listening_ports.items.all(p, p.port in [22, 631])`, true},
+		{`listening_ports.items.map(p, p.process)`, []any{"sshd", "cupsd"}},
+		{`has(counts.missing) ? counts.missing : -1`, -1.0},
+		{`false && counts.missing > 1`, false},
+		{`"cups" + "d" == listening_ports.items[1].process`, true},
+		{`listening_ports.items[0].bind.matches("^0\\.0")`, true},
+		{`"\u0041" == 'A'`, true},
+		{`'\x41\101\U00000041\'' == "AAA'"`, true},
+		{`"a\tb\\".size()`, 4.0},
+		{`"22".matches('\\d+')`, true},
+		{`int(-9.2e18) < 0`, true},
+	}
+	for _, tc := range cases {
+		prog, err := Compile(tc.expr)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tc.expr, err)
+		}
+		got, err := prog.Eval(vars)
+		if err != nil {
+			t.Fatalf("Eval(%q): %v", tc.expr, err)
+		}
+		if !valuesEqual(got, tc.want) {
+			t.Errorf("Eval(%q) = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{`a ==`, `foo(1)`, `x.exists(1, true)`, `"open`, `"22".matches('\d+')`, `'\x4'`, `"\uD800"`, `'\400'`} {
+		if _, err := Compile(bad); err == nil {
+			t.Errorf("Compile(%q) = nil error, want parse error", bad)
+		}
+	}
+	for _, expr := range []string{`int(1e300)`, `int(-1e19)`, `int(9223372036854775808.0)`} {
+		prog, err := Compile(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := prog.Eval(vars); err == nil || !strings.Contains(err.Error(), "overflows") {
+			t.Errorf("Eval(%q) err = %v, want overflow", expr, err)
+		}
+	}
+	prog, _ := Compile(`counts.missing > 1`)
+	if _, err := prog.Eval(vars); err == nil || !strings.Contains(err.Error(), "no such key") {
+		t.Errorf("Eval(missing key) err = %v, want no such key", err)
+	}
+}
+
+func TestProgramRowTypes(t *testing.T) {
+	cases := map[string][]string{
+		`counts.broken_symlinks < 10`: {"counts"},
+		`launch_daemons.items.exists(d, d.signed == false && listening_ports.items.exists(p, p.process == d.label))`: {"launch_daemons", "listening_ports"},
+		`rows.large_file.size() < 5 || has(security_config.sip)`:                                                     {"large_file", "security_config"},
+		`[1, 2].map(counts, counts * 2).size() == 2`:                                                                 {},
+	}
+	for expr, want := range cases {
+		prog, err := Compile(expr)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", expr, err)
+		}
+		if got := prog.RowTypes(); !reflect.DeepEqual(got, want) {
+			t.Errorf("RowTypes(%q) = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestEvaluate_PolicyFile(t *testing.T) {
+	p, err := Parse([]byte(`
+name: baseline
+rules:
+  - id: filevault
+    expr: security_config.filevault == true
+    severity: high
+  - id: symlinks
+    expr: counts.broken_symlinks < 10
+    message: too many broken symlinks
+  - id: sip  # missing from snapshot
+    expr: security_config.sip == true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []diff.Row{
+		{"type": "security_config", "filevault": true},
+		{"type": "counts", "broken_symlinks": 42.0},
+	}
+	res := Evaluate(p, rows)
+	want := []string{StatusPass, StatusViolation, StatusUnknown}
+	for i, rr := range res.Rules {
+		if rr.Status != want[i] {
+			t.Errorf("rule %s = %s (%s), want %s", rr.Rule.ID, rr.Status, rr.Reason, want[i])
+		}
+	}
+	if res.Rules[1].Reason != "too many broken symlinks" {
+		t.Errorf("violation reason = %q, want rule message", res.Rules[1].Reason)
+	}
+	if res.Pass != 1 || res.Violations != 1 || res.Unknown != 1 {
+		t.Errorf("counts = %d/%d/%d, want 1/1/1", res.Pass, res.Violations, res.Unknown)
+	}
+
+	if _, err := Parse([]byte("name: x\nrules:\n  - id: a\n    expr: a ==\n")); err == nil {
+		t.Error("Parse with invalid expr = nil error")
+	}
+}
+
+func TestHistoryTalliesPerHost(t *testing.T) {
+	p, err := Parse([]byte(`{"name": "new", "rules": [` +
+		`{"id": "firewall-on", "expr": "security_config.firewall == true", "severity": "high"},` +
+		`{"id": "few-ports", "expr": "listening_ports.count < 5"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := func(firewall bool) []diff.Row {
+		return []diff.Row{{"type": "security_config", "firewall": firewall}}
+	}
+	h := NewHistory(p)
+	h.Add("web1", Evaluate(p, snap(false)))
+	h.Add("web1", Evaluate(p, snap(true)))
+	h.Add("db1", Evaluate(p, snap(false)))
+	h.Add("db1", Evaluate(p, snap(false)))
+
+	if h.Snapshots != 4 || strings.Join(h.Hosts(), ",") != "db1,web1" {
+		t.Fatalf("history saw %d snapshots from %v", h.Snapshots, h.Hosts())
+	}
+	rules := h.Rules()
+	fw := rules[0]
+	if fw.Violations != 3 || len(fw.Hosts) != 2 || fw.Hosts[0] != (HostTally{Host: "db1", Snapshots: 2, Violations: 2}) {
+		t.Errorf("firewall-on history = %+v", fw)
+	}
+	// listening_ports is missing from every snapshot, so the rule never fires.
+	ports := rules[1]
+	if ports.Violations != 0 || ports.Hosts[0].Unknown != 2 || ports.Hosts[1].Unknown != 2 {
+		t.Errorf("few-ports history = %+v", ports)
+	}
+}
+
+func TestSuiteRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	write("baseline.yaml", "name: baseline\nrules:\n"+
+		"  - id: firewall-on\n    expr: security_config.firewall == true\n"+
+		"  - id: few-symlinks\n    expr: counts.broken_symlinks < 10\n")
+	write("fixtures/server.ndjson", `{"type":"security_config","firewall":true}`+"\n")
+	suitePath := write("baseline_test.yaml", "cases:\n"+
+		"  - name: firewall off\n    rows:\n      - type: security_config\n        firewall: false\n"+
+		"    expect:\n      firewall-on: violation\n      few-symlinks: unknown\n"+
+		"  - name: server\n    fixture: fixtures/server.ndjson\n    rows:\n      - {type: counts, broken_symlinks: 40}\n"+
+		"    expect:\n      firewall-on: pass\n      few-symlinks: pass\n")
+
+	paths, err := FindSuites([]string{dir})
+	if err != nil || len(paths) != 1 || paths[0] != suitePath {
+		t.Fatalf("FindSuites = %v, %v; want [%s]", paths, err, suitePath)
+	}
+	s, err := LoadSuite(suitePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := s.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || len(results[0].Mismatches) != 0 {
+		t.Fatalf("results = %+v, want 2 cases, the first passing", results)
+	}
+	want := []Mismatch{{Rule: "few-symlinks", Want: StatusPass, Got: StatusViolation, Reason: "expression is false: counts.broken_symlinks < 10"}}
+	if got := results[1].Mismatches; !reflect.DeepEqual(got, want) {
+		t.Errorf("server mismatches = %+v, want %+v", got, want)
+	}
+
+	for name, body := range map[string]string{
+		"unknown_rule_test.yaml": "policy: baseline.yaml\ncases:\n  - name: a\n    rows:\n      - type: counts\n    expect:\n      no-such-rule: pass\n",
+		"bad_status_test.yaml":   "policy: baseline.yaml\ncases:\n  - name: a\n    rows:\n      - type: counts\n    expect:\n      firewall-on: fail\n",
+		"orphan_test.yaml":       "cases:\n  - name: a\n    rows:\n      - type: counts\n    expect:\n      firewall-on: pass\n",
+	} {
+		if _, err := LoadSuite(write(name, body)); err == nil {
+			t.Errorf("LoadSuite(%s) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	p, err := Parse([]byte(`
+name: graded
+rules:
+  - id: disk-encryption
+    expr: security_config.filevault == true
+    topic: encryption
+  - id: firewall
+    expr: security_config.firewall == true
+    topic: network
+  - id: symlinks
+    expr: counts.broken_symlinks < 10
+  - id: sip  # missing from snapshot
+    expr: security_config.sip == true
+scoring:
+  weights: {encryption: 6, network: 3}
+  caps:
+    - rule: disk-encryption
+      grade: D
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	score := func(rows ...diff.Row) Score {
+		t.Helper()
+		sc, ok := p.Score(Evaluate(p, rows))
+		if !ok {
+			t.Fatal("Score() ok = false for a policy with scoring")
+		}
+		return sc
+	}
+
+	// 9 of 10 weighted points, with the unknown rule left out.
+	sc := score(diff.Row{"type": "security_config", "filevault": true, "firewall": true}, diff.Row{"type": "counts", "broken_symlinks": 42.0})
+	if sc.Score != 90 || sc.Grade != "A" || sc.CappedBy != nil {
+		t.Errorf("Score() = %+v, want 90, A, uncapped", sc)
+	}
+	// The firewall and symlinks pass for 4 of 10, an F the cap cannot lower.
+	sc = score(diff.Row{"type": "security_config", "filevault": false, "firewall": true}, diff.Row{"type": "counts", "broken_symlinks": 1.0})
+	if sc.Score != 40 || sc.Grade != FailGrade || sc.CappedBy != nil {
+		t.Errorf("Score() = %+v, want 40, F, uncapped", sc)
+	}
+
+	p.Scoring.Weights = map[string]float64{"encryption": 1, "network": 1}
+	// 3 of 4 earns a C, but FileVault off caps it at D.
+	sc = score(diff.Row{"type": "security_config", "filevault": false, "firewall": true, "sip": true}, diff.Row{"type": "counts", "broken_symlinks": 1.0})
+	if sc.Score != 75 || sc.Grade != "D" || !reflect.DeepEqual(sc.CappedBy, []string{"disk-encryption"}) {
+		t.Errorf("Score() = %+v, want capped at D by disk-encryption", sc)
+	}
+
+	if _, ok := (Policy{}).Score(Result{}); ok {
+		t.Error("Score() ok = true for a policy without scoring")
+	}
+
+	for _, bad := range []string{
+		"scoring:\n  caps:\n    - rule: nope\n      grade: D\n",
+		"scoring:\n  caps:\n    - rule: a\n      grade: E\n",
+		"scoring:\n  weights: {encryption: -1}\n",
+		"scoring:\n  grades: {A: 90, F: 10}\n",
+		"scoring:\n  grades: {A: 190}\n",
+	} {
+		if _, err := Parse([]byte("name: x\nrules:\n  - id: a\n    expr: counts.n < 1\n" + bad)); err == nil {
+			t.Errorf("Parse with %q = nil error", bad)
+		}
+	}
+}
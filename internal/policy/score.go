@@ -0,0 +1,150 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Scoring turns a policy's verdicts into a headline score and grade, so an
+// organization can weigh what matters to it:
+//
+//	scoring:
+//	  weights: {encryption: 5, network: 2}   # by rule topic; others weigh 1
+//	  grades: {A: 90, B: 80, C: 70, D: 60}   # lowest score for each; below is F
+//	  caps:
+//	    - rule: disk-encryption              # FileVault off caps the grade at D
+//	      grade: D
+type Scoring struct {
+	Weights map[string]float64 `json:"weights,omitempty"`
+	Grades  map[string]float64 `json:"grades,omitempty"`
+	Caps    []Cap              `json:"caps,omitempty"`
+}
+
+// Cap limits the grade to Grade while Rule is violated.
+type Cap struct {
+	Rule  string `json:"rule"`
+	Grade string `json:"grade"`
+}
+
+// DefaultGrades are the grade thresholds when scoring sets none.
+var DefaultGrades = map[string]float64{"A": 90, "B": 80, "C": 70, "D": 60}
+
+// FailGrade is the grade of a score below every threshold.
+const FailGrade = "F"
+
+// Score is a policy result's weighted score and grade.
+type Score struct {
+	// Score is the weighted share of passing rules among those that passed
+	// or were violated, 0 to 100. Unknown rules do not count, as in
+	// benchmark compliance, and with none scored the score is 0.
+	Score float64
+	Grade string
+	// CappedBy lists the violated rules whose caps are below the grade the
+	// score alone earns; the worst of them is Grade.
+	CappedBy []string
+}
+
+// grades returns the grade names from best to worst, FailGrade last.
+func (s *Scoring) grades() (names []string, min map[string]float64) {
+	min = s.Grades
+	if len(min) == 0 {
+		min = DefaultGrades
+	}
+	for g := range min {
+		names = append(names, g)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if min[names[i]] != min[names[j]] {
+			return min[names[i]] > min[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return append(names, FailGrade), min
+}
+
+// validate checks s against the rules of the policy it belongs to.
+func (s *Scoring) validate(rules []Rule) error {
+	for topic, w := range s.Weights {
+		if w < 0 {
+			return fmt.Errorf("scoring.weights.%s: %v must not be negative", topic, w)
+		}
+	}
+	for g, min := range s.Grades {
+		if g == FailGrade {
+			return fmt.Errorf("scoring.grades: %s is the grade below every threshold and takes none", FailGrade)
+		}
+		if min < 0 || min > 100 {
+			return fmt.Errorf("scoring.grades.%s: %v must be between 0 and 100", g, min)
+		}
+	}
+	names, _ := s.grades()
+	ids := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		ids[r.ID] = true
+	}
+	for i, c := range s.Caps {
+		if !ids[c.Rule] {
+			return fmt.Errorf("scoring.caps[%d]: no rule %q", i, c.Rule)
+		}
+		if rank(names, c.Grade) < 0 {
+			return fmt.Errorf("scoring.caps[%d]: unknown grade %q", i, c.Grade)
+		}
+	}
+	return nil
+}
+
+func rank(names []string, grade string) int {
+	for i, g := range names {
+		if g == grade {
+			return i
+		}
+	}
+	return -1
+}
+
+// Score grades res by the policy's scoring. ok is false when the policy has
+// none.
+func (p Policy) Score(res Result) (sc Score, ok bool) {
+	s := p.Scoring
+	if s == nil {
+		return Score{}, false
+	}
+	var total, passed float64
+	violated := make(map[string]bool)
+	for _, rr := range res.Rules {
+		w := 1.0
+		if tw, ok := s.Weights[rr.Rule.Topic]; ok && rr.Rule.Topic != "" {
+			w = tw
+		}
+		switch rr.Status {
+		case StatusPass:
+			passed += w
+			total += w
+		case StatusViolation:
+			total += w
+			violated[rr.Rule.ID] = true
+		}
+	}
+	if total > 0 {
+		sc.Score = passed / total * 100
+	}
+
+	names, min := s.grades()
+	sc.Grade = FailGrade
+	for _, g := range names[:len(names)-1] {
+		if sc.Score >= min[g] {
+			sc.Grade = g
+			break
+		}
+	}
+	scored := rank(names, sc.Grade)
+	for _, c := range s.Caps {
+		if r := rank(names, c.Grade); violated[c.Rule] && r > scored {
+			sc.CappedBy = append(sc.CappedBy, c.Rule)
+			if r > rank(names, sc.Grade) {
+				sc.Grade = c.Grade
+			}
+		}
+	}
+	return sc, true
+}
@@ -0,0 +1,220 @@
+package policy
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
+)
+
+// suiteSuffixes are the file name endings of policy test files. A test file
+// foo_test.yaml tests the policy foo.yaml (or foo.yml or foo.json) next to it
+// unless it names another with policy:.
+var suiteSuffixes = []string{"_test.yaml", "_test.yml", "_test.json"}
+
+// Suite is a policy test file: example snapshots, each with the verdicts the
+// policy's rules must reach on it, e.g.
+//
+//	policy: baseline.yaml
+//	cases:
+//	  - name: laptop without FileVault
+//	    rows:
+//	      - type: security_config
+//	        filevault: false
+//	    expect:
+//	      filevault-on: violation
+//	  - name: stored server snapshot
+//	    fixture: fixtures/server.ndjson
+//	    expect:
+//	      filevault-on: unknown
+//	      few-broken-symlinks: pass
+type Suite struct {
+	Path   string `json:"-"`
+	Policy Policy `json:"-"`
+
+	PolicyPath string `json:"policy,omitempty"`
+	Cases      []Case `json:"cases"`
+}
+
+// Case is one example snapshot of a suite: inline rows, the rows of an NDJSON
+// fixture file (relative to the test file), or both, and the expected status
+// of each rule it checks. Rules a case does not name are not checked.
+type Case struct {
+	Name    string            `json:"name"`
+	Fixture string            `json:"fixture,omitempty"`
+	Rows    []diff.Row        `json:"rows,omitempty"`
+	Expect  map[string]string `json:"expect"`
+}
+
+// Mismatch is a rule that did not reach its expected status.
+type Mismatch struct {
+	Rule   string
+	Want   string
+	Got    string
+	Reason string
+}
+
+// CaseResult is the outcome of one case; it passed when Mismatches is empty.
+type CaseResult struct {
+	Case       Case
+	Mismatches []Mismatch
+}
+
+// IsSuiteFile reports whether name is a policy test file name.
+func IsSuiteFile(name string) bool {
+	for _, s := range suiteSuffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindSuites returns the test files among paths: files are taken as given,
+// and directories are walked for files named like IsSuiteFile. The result is
+// sorted.
+func FindSuites(paths []string) ([]string, error) {
+	var out []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			out = append(out, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && IsSuiteFile(d.Name()) {
+				out = append(out, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// LoadSuite reads the test file at path and the policy it tests. Every case
+// needs a name, rows or a fixture, and at least one expectation; expected
+// statuses must be pass, violation, or unknown, and name rules of the policy.
+func LoadSuite(path string) (Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Suite{}, err
+	}
+	var s Suite
+	if err := yamlite.Unmarshal(data, &s); err != nil {
+		return Suite{}, fmt.Errorf("%s: %w", path, err)
+	}
+	s.Path = path
+	policyPath, err := s.policyFile()
+	if err != nil {
+		return Suite{}, err
+	}
+	if s.Policy, err = Load(policyPath); err != nil {
+		return Suite{}, err
+	}
+	if len(s.Cases) == 0 {
+		return Suite{}, fmt.Errorf("%s: no cases", path)
+	}
+	ids := make(map[string]bool, len(s.Policy.Rules))
+	for _, r := range s.Policy.Rules {
+		ids[r.ID] = true
+	}
+	for i, c := range s.Cases {
+		switch {
+		case c.Name == "":
+			return Suite{}, fmt.Errorf("%s: cases[%d]: name is required", path, i)
+		case c.Fixture == "" && len(c.Rows) == 0:
+			return Suite{}, fmt.Errorf("%s: case %q: rows or fixture is required", path, c.Name)
+		case len(c.Expect) == 0:
+			return Suite{}, fmt.Errorf("%s: case %q: expect is required", path, c.Name)
+		}
+		for id, want := range c.Expect {
+			if !ids[id] {
+				return Suite{}, fmt.Errorf("%s: case %q: policy %s has no rule %q", path, c.Name, policyPath, id)
+			}
+			if want != StatusPass && want != StatusViolation && want != StatusUnknown {
+				return Suite{}, fmt.Errorf("%s: case %q: rule %s: expected status %q (want pass, violation, or unknown)", path, c.Name, id, want)
+			}
+		}
+	}
+	return s, nil
+}
+
+// policyFile resolves the policy the suite tests.
+func (s Suite) policyFile() (string, error) {
+	dir := filepath.Dir(s.Path)
+	if s.PolicyPath != "" {
+		if filepath.IsAbs(s.PolicyPath) {
+			return s.PolicyPath, nil
+		}
+		return filepath.Join(dir, s.PolicyPath), nil
+	}
+	base := filepath.Base(s.Path)
+	for _, suffix := range suiteSuffixes {
+		if !strings.HasSuffix(base, suffix) {
+			continue
+		}
+		stem := strings.TrimSuffix(base, suffix)
+		for _, ext := range []string{".yaml", ".yml", ".json"} {
+			p := filepath.Join(dir, stem+ext)
+			if _, err := os.Stat(p); err == nil {
+				return p, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%s: no policy: found no policy file next to it and it sets no policy:", s.Path)
+}
+
+// Run evaluates the policy against every case. It fails only when a fixture
+// cannot be read.
+func (s Suite) Run() ([]CaseResult, error) {
+	out := make([]CaseResult, 0, len(s.Cases))
+	for _, c := range s.Cases {
+		rows, err := s.rows(c)
+		if err != nil {
+			return nil, err
+		}
+		res := Evaluate(s.Policy, rows)
+		cr := CaseResult{Case: c}
+		for _, rr := range res.Rules {
+			want, ok := c.Expect[rr.Rule.ID]
+			if !ok || want == rr.Status {
+				continue
+			}
+			cr.Mismatches = append(cr.Mismatches, Mismatch{Rule: rr.Rule.ID, Want: want, Got: rr.Status, Reason: rr.Reason})
+		}
+		out = append(out, cr)
+	}
+	return out, nil
+}
+
+// rows returns the snapshot rows of c: its fixture's, upgraded to the current
+// schema like any stored snapshot, followed by its inline rows.
+func (s Suite) rows(c Case) ([]diff.Row, error) {
+	var rows []diff.Row
+	if c.Fixture != "" {
+		p := c.Fixture
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(s.Path), p)
+		}
+		var err error
+		if rows, err = diff.ReadNDJSON(p); err != nil {
+			return nil, fmt.Errorf("%s: case %q: %w", s.Path, c.Name, err)
+		}
+	}
+	return append(rows, c.Rows...), nil
+}
@@ -0,0 +1,319 @@
+// Package rawjson moves strings that are not valid UTF-8, chiefly file names,
+// through JSON without loss. encoding/json replaces each invalid byte with
+// U+FFFD, so two different non-UTF-8 paths would decode, diff, and re-encode
+// as the same string.
+//
+// The NDJSON the audit scripts write already has an encoding for such bytes:
+// json_escape (Python's json.dumps of a surrogateescape-decoded argument)
+// writes byte 0x80–0xff as the lone low surrogate escape \udc80–\udcff, as in
+// PEP 383. Marshal and Encoder write invalid bytes the same way, and Unmarshal
+// turns those escapes, and any raw invalid UTF-8, back into the original
+// bytes. Everything else is plain encoding/json.
+//
+// In memory a byte in transit is carried as the private-use code point
+// U+10FF00 plus the byte, so U+10FF80–U+10FFFF cannot appear in rows
+// themselves; no collector emits them.
+package rawjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// markerBase + b stands for the raw byte b (0x80–0xff) while encoding/json
+// handles a value.
+const markerBase = 0x10FF00
+
+// Marshal is json.Marshal, except that bytes of strings that are not valid
+// UTF-8 are written as \udc80–\udcff escapes.
+func Marshal(v any) ([]byte, error) {
+	m, changed := walk(reflect.ValueOf(v), toMarkers)
+	if changed {
+		v = m.Interface()
+	}
+	data, err := json.Marshal(v)
+	if err != nil || !changed {
+		return data, err
+	}
+	return escapeMarkers(data), nil
+}
+
+// Unmarshal is json.Unmarshal, except that \udc80–\udcff escapes that are not
+// the second half of a surrogate pair, and raw bytes that are not valid UTF-8,
+// decode to the bytes they stand for.
+func Unmarshal(data []byte, v any) error {
+	marked, changed := markRawBytes(data)
+	if err := json.Unmarshal(marked, v); err != nil || !changed {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil
+	}
+	if e, ok := walk(rv.Elem(), fromMarkers); ok {
+		rv.Elem().Set(e)
+	}
+	return nil
+}
+
+// Encoder is a json.Encoder that writes strings like Marshal.
+type Encoder struct {
+	w   io.Writer
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	e := &Encoder{w: w}
+	e.enc = json.NewEncoder(&e.buf)
+	return e
+}
+
+// SetEscapeHTML is json.Encoder.SetEscapeHTML.
+func (e *Encoder) SetEscapeHTML(on bool) { e.enc.SetEscapeHTML(on) }
+
+// Encode writes v and a newline, like json.Encoder.Encode.
+func (e *Encoder) Encode(v any) error {
+	m, changed := walk(reflect.ValueOf(v), toMarkers)
+	if changed {
+		v = m.Interface()
+	}
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		return err
+	}
+	data := e.buf.Bytes()
+	if changed {
+		data = escapeMarkers(data)
+	}
+	_, err := e.w.Write(data)
+	return err
+}
+
+// Display returns s for showing in text output: s itself when it is valid
+// UTF-8 without control characters, otherwise a Go-quoted string, so a file
+// name with a newline stays on one line and distinct names stay distinct.
+func Display(s string) string {
+	if !utf8.ValidString(s) || strings.ContainsFunc(s, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// toMarkers replaces the bytes of s that are not valid UTF-8 with markers.
+func toMarkers(s string) (string, bool) {
+	if utf8.ValidString(s) {
+		return s, false
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.WriteRune(markerBase + rune(s[i]))
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String(), true
+}
+
+// fromMarkers replaces the markers in s with the bytes they stand for.
+func fromMarkers(s string) (string, bool) {
+	if !strings.Contains(s, "\xf4\x8f") { // the UTF-8 prefix of U+10FFxx
+		return s, false
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r >= markerBase+0x80 && r <= markerBase+0xff {
+			b.WriteByte(byte(r - markerBase))
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String(), true
+}
+
+// escapeMarkers rewrites the markers in encoded JSON as \udcXX escapes.
+// encoding/json writes non-ASCII runes as UTF-8, so every marker appears raw.
+func escapeMarkers(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		i := bytes.Index(data, []byte("\xf4\x8f"))
+		if i < 0 {
+			break
+		}
+		out = append(out, data[:i]...)
+		r, size := utf8.DecodeRune(data[i:])
+		if r >= markerBase+0x80 && r <= markerBase+0xff {
+			out = fmt.Appendf(out, `\udc%02x`, r-markerBase)
+		} else {
+			out = append(out, data[i:i+size]...)
+		}
+		data = data[i+size:]
+	}
+	return append(out, data...)
+}
+
+// markRawBytes rewrites lone \udc80–\udcff escapes and invalid UTF-8 bytes in
+// JSON text as markers, which encoding/json keeps.
+func markRawBytes(data []byte) ([]byte, bool) {
+	if utf8.Valid(data) && !bytes.Contains(data, []byte(`\u`)) {
+		return data, false
+	}
+	var out []byte
+	last := 0
+	mark := func(at, end int, b byte) {
+		if out == nil {
+			out = make([]byte, 0, len(data)+16)
+		}
+		out = append(out, data[last:at]...)
+		out = utf8.AppendRune(out, markerBase+rune(b))
+		last = end
+	}
+	afterHigh := false // the previous character was a \ud800–\udbff escape
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size == 1 {
+				mark(i, i+1, c)
+			}
+			afterHigh = false
+			i += size
+		case c == '\\' && i+6 <= len(data) && data[i+1] == 'u':
+			v, err := strconv.ParseUint(string(data[i+2:i+6]), 16, 16)
+			switch {
+			case err != nil:
+				afterHigh = false
+			case v >= 0xdc80 && v <= 0xdcff && !afterHigh:
+				mark(i, i+6, byte(v))
+				afterHigh = false
+			default:
+				afterHigh = v >= 0xd800 && v <= 0xdbff
+			}
+			i += 6
+		case c == '\\':
+			afterHigh = false
+			i += 2 // an escaped character, such as \\ before a literal "u"
+		default:
+			afterHigh = false
+			i++
+		}
+	}
+	if out == nil {
+		return data, false
+	}
+	return append(out, data[last:]...), true
+}
+
+// walk returns v with f applied to every string in it, map keys included,
+// and whether anything changed. Containers are copied only when something in
+// them changed, so the caller's value is never modified.
+func walk(v reflect.Value, f func(string) (string, bool)) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		s, ok := f(v.String())
+		if !ok {
+			return v, false
+		}
+		return reflect.ValueOf(s).Convert(v.Type()), true
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return v, false
+		}
+		e, ok := walk(v.Elem(), f)
+		if !ok {
+			return v, false
+		}
+		if v.Kind() == reflect.Pointer {
+			out := reflect.New(v.Type().Elem())
+			out.Elem().Set(e)
+			return out, true
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(e)
+		return out, true
+	case reflect.Map:
+		var out reflect.Value
+		iter := v.MapRange()
+		for iter.Next() {
+			k, kc := walk(iter.Key(), f)
+			e, ec := walk(iter.Value(), f)
+			if !kc && !ec {
+				continue
+			}
+			if !out.IsValid() {
+				out = reflect.MakeMapWithSize(v.Type(), v.Len())
+				copyIter := v.MapRange()
+				for copyIter.Next() {
+					out.SetMapIndex(copyIter.Key(), copyIter.Value())
+				}
+			}
+			if kc {
+				out.SetMapIndex(iter.Key(), reflect.Value{})
+			}
+			out.SetMapIndex(k, e)
+		}
+		if !out.IsValid() {
+			return v, false
+		}
+		return out, true
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return v, false // []byte encodes as base64
+		}
+		var out reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			e, ok := walk(v.Index(i), f)
+			if !ok {
+				continue
+			}
+			if !out.IsValid() {
+				if v.Kind() == reflect.Slice {
+					out = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+					reflect.Copy(out, v)
+				} else {
+					out = reflect.New(v.Type()).Elem()
+					out.Set(v)
+				}
+			}
+			out.Index(i).Set(e)
+		}
+		if !out.IsValid() {
+			return v, false
+		}
+		return out, true
+	case reflect.Struct:
+		var out reflect.Value
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			e, ok := walk(v.Field(i), f)
+			if !ok {
+				continue
+			}
+			if !out.IsValid() {
+				out = reflect.New(v.Type()).Elem()
+				out.Set(v)
+			}
+			out.Field(i).Set(e)
+		}
+		if !out.IsValid() {
+			return v, false
+		}
+		return out, true
+	}
+	return v, false
+}
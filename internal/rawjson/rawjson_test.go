@@ -0,0 +1,119 @@
+package rawjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// adversarialPaths are file names collectors have to carry through NDJSON.
+var adversarialPaths = []string{
+	"/home/u/plain.txt",
+	"/home/u/new\nline",
+	"/home/u/tab\tand\rcr",
+	"/home/u/ctl\x01\x1b[31m\x7f",
+	"/home/u/latin1-\xe9t\xe9",          // ISO-8859-1 "été"
+	"/home/u/latin1-\xe8t\xe9",          // differs from the above in one invalid byte
+	"/home/u/truncated-\xe2\x82",        // cut-off UTF-8 sequence
+	"/home/u/\xff\xfe",                  // invalid everywhere
+	"/home/u/\uFFFD-real-replacement",   // a genuine U+FFFD stays one
+	"/home/u/emoji-\U0001F480",          // encodes as a surrogate pair
+	`/home/u/back\slash-\udc80-literal`, // backslash text, not an escape
+	"/" + strings.Repeat("deep/", 900) + "x",
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, p := range adversarialPaths {
+		row := map[string]any{"type": "large_file", "path": p, "items": []any{map[string]any{p: p}}}
+		data, err := Marshal(row)
+		if err != nil {
+			t.Fatalf("Marshal(%q): %v", p, err)
+		}
+		if bytes.ContainsAny(data, "\n\r") || !json.Valid(data) {
+			t.Errorf("Marshal(%q) = %s, want one valid JSON line", p, data)
+		}
+		var got map[string]any
+		if err := Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if !reflect.DeepEqual(got, row) {
+			t.Errorf("round trip of %q = %q", p, got["path"])
+		}
+	}
+}
+
+func TestDistinctPathsStayDistinct(t *testing.T) {
+	a, _ := Marshal(adversarialPaths[4])
+	b, _ := Marshal(adversarialPaths[5])
+	if bytes.Equal(a, b) {
+		t.Errorf("Marshal encoded %q and %q alike: %s", adversarialPaths[4], adversarialPaths[5], a)
+	}
+}
+
+func TestUnmarshalScriptOutput(t *testing.T) {
+	// What json_escape (Python's json.dumps) writes for the non-UTF-8 name
+	// b"caf\xe9\n", next to a surrogate pair and an escaped backslash.
+	line := `{"path":"/tmp/caf\udce9\n","emoji":"💀","text":"\\udce9"}`
+	var got map[string]any
+	if err := Unmarshal([]byte(line), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"path": "/tmp/caf\xe9\n", "emoji": "\U0001F480", "text": `\udce9`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal = %q, want %q", got, want)
+	}
+	// Raw invalid bytes, as a careless writer would leave them, survive too.
+	if err := Unmarshal([]byte("{\"path\":\"/tmp/caf\xe9\"}"), &got); err != nil || got["path"] != "/tmp/caf\xe9" {
+		t.Errorf("Unmarshal of raw bytes = %q, %v", got["path"], err)
+	}
+	// And re-encoding gives what the script wrote.
+	data, _ := Marshal("/tmp/caf\xe9\n")
+	if string(data) != `"/tmp/caf\udce9\n"` {
+		t.Errorf("Marshal = %s", data)
+	}
+}
+
+func TestEncoderAndStructs(t *testing.T) {
+	type entry struct {
+		Path  string
+		Tags  []string
+		inner string
+	}
+	in := map[string]entry{"k\xff": {Path: "/x/\xff", Tags: []string{"\xfe"}, inner: "\xfd"}}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	if in["k\xff"].Path != "/x/\xff" {
+		t.Error("Encode modified its argument")
+	}
+	if !strings.HasSuffix(buf.String(), "\n") || strings.Contains(buf.String(), "\uFFFD") {
+		t.Errorf("Encode wrote %q", buf.String())
+	}
+	var out map[string]entry
+	if err := Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	in["k\xff"] = entry{Path: "/x/\xff", Tags: []string{"\xfe"}}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip = %q, want %q", out, in)
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	for in, want := range map[string]string{
+		"/home/u/plain.txt":   "/home/u/plain.txt",
+		"/home/u/été":         "/home/u/été",
+		"/home/u/new\nline":   `"/home/u/new\nline"`,
+		"/home/u/\xe9t\xe9":   `"/home/u/\xe9t\xe9"`,
+		"/home/u/\x1b[31mred": `"/home/u/\x1b[31mred"`,
+	} {
+		if got := Display(in); got != want {
+			t.Errorf("Display(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package redact applies the audit scripts' NDJSON redaction rules
+// (redact_path_for_ndjson and redact_all_text in audit/<os>/lib/common.sh) to
+// stored rows, so snapshots written before a rule existed, or without
+// redaction, can be brought up to the current rules by `osaudit snapshot
+// compact`.
+//
+// Unlike redact_all_text, which only runs on report text, the rules here never
+// touch hex digests or base64: in NDJSON they are hashes and keys that diffs
+// depend on.
+package redact
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+// Rules are the redactions for one snapshot.
+type Rules struct {
+	// User is the audited account. Its home directory becomes ~ and other
+	// path segments naming it become <user>.
+	User string
+	// Hostname becomes <hostname> when All is set.
+	Hostname string
+	// All matches the scripts' --redact-all: hostnames, SSH fingerprints, and
+	// process arguments are redacted too.
+	All bool
+}
+
+// ForRows returns the rules for a snapshot, taking the user and hostname from
+// its meta row.
+func ForRows(rows []diff.Row, all bool) Rules {
+	r := Rules{All: all}
+	for _, row := range rows {
+		if row["type"] == "meta" {
+			r.User, _ = row["user"].(string)
+			r.Hostname, _ = row["hostname"].(string)
+			break
+		}
+	}
+	if strings.HasPrefix(r.User, "<") { // already redacted
+		r.User = ""
+	}
+	if strings.HasPrefix(r.Hostname, "<") {
+		r.Hostname = ""
+	}
+	return r
+}
+
+var (
+	secretRe      = regexp.MustCompile(`(?i)\b(key|token|secret)=[^\s"']+`)
+	fingerprintRe = regexp.MustCompile(`SHA256:[A-Za-z0-9+/=]+`)
+)
+
+// String redacts one value.
+func (r Rules) String(s string) string {
+	if r.User != "" {
+		for _, home := range r.homes() {
+			s = replacePath(s, home, "~")
+		}
+		s = strings.ReplaceAll(s, "/"+r.User+"/", "/<user>/")
+		if strings.HasSuffix(s, "/"+r.User) {
+			s = strings.TrimSuffix(s, r.User) + "<user>"
+		}
+	}
+	s = secretRe.ReplaceAllString(s, "$1=<redacted>")
+	if r.All {
+		if r.Hostname != "" {
+			s = strings.ReplaceAll(s, r.Hostname, "<hostname>")
+		}
+		s = fingerprintRe.ReplaceAllString(s, "SHA256:<redacted>")
+	}
+	return s
+}
+
+// homes lists the home directories the user may have on mac, linux, and
+// Windows.
+func (r Rules) homes() []string {
+	if r.User == "root" {
+		return []string{"/var/root", "/root"}
+	}
+	return []string{"/Users/" + r.User, "/home/" + r.User, `C:\Users\` + r.User}
+}
+
+// replacePath replaces dir where it appears as a whole path prefix: "/home/al"
+// in "/home/al/x" or "/home/al", but not in "/home/alice".
+func replacePath(s, dir, with string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, dir)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := i + len(dir)
+		if end == len(s) || s[end] == '/' || s[end] == '\\' || s[end] == ':' || s[end] == ' ' {
+			b.WriteString(s[:i])
+			b.WriteString(with)
+		} else {
+			b.WriteString(s[:end])
+		}
+		s = s[end:]
+	}
+}
+
+// command keeps the program of a command line and drops its arguments, like
+// redact_command.
+func command(s string) string {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i] + " <args>"
+	}
+	return s
+}
+
+// Apply redacts rows in place and returns the number of values it changed.
+// The type and run_id fields are never touched.
+func (r Rules) Apply(rows []diff.Row) int {
+	n := 0
+	for _, row := range rows {
+		n += r.walkMap(row)
+	}
+	return n
+}
+
+func (r Rules) walkMap(m map[string]any) int {
+	n := 0
+	for k, v := range m {
+		if k == "type" || k == "run_id" {
+			continue
+		}
+		var out any
+		var changed int
+		switch {
+		case r.All && k == "fingerprint":
+			if s, ok := v.(string); ok && s != "" && s != "unknown" && s != "<fingerprint>" {
+				out, changed = "<fingerprint>", 1
+			}
+		case r.All && k == "command":
+			if s, ok := v.(string); ok {
+				if c := command(r.String(s)); c != s {
+					out, changed = c, 1
+				}
+			}
+		default:
+			out, changed = r.walk(v)
+		}
+		if changed > 0 {
+			m[k] = out
+			n += changed
+		}
+	}
+	return n
+}
+
+// walk returns v redacted and the number of values changed in it.
+func (r Rules) walk(v any) (any, int) {
+	switch x := v.(type) {
+	case string:
+		if s := r.String(x); s != x {
+			return s, 1
+		}
+	case map[string]any:
+		return x, r.walkMap(x)
+	case []any:
+		n := 0
+		for i, e := range x {
+			if out, c := r.walk(e); c > 0 {
+				x[i] = out
+				n += c
+			}
+		}
+		return x, n
+	}
+	return v, 0
+}
@@ -0,0 +1,55 @@
+package redact
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+func TestString(t *testing.T) {
+	r := Rules{User: "al", Hostname: "al-laptop"}
+	cases := map[string]string{
+		"/Users/al/.ssh/config":    "~/.ssh/config",
+		"/home/al":                 "~",
+		"/home/alice/x":            "/home/alice/x",
+		"/var/mail/al":             "/var/mail/<user>",
+		"/srv/al/data":             "/srv/<user>/data",
+		"curl -H token=abc123 x":   "curl -H token=<redacted> x",
+		"al-laptop.local":          "al-laptop.local",
+		"e3b0c44298fc1c149afbf4c8": "e3b0c44298fc1c149afbf4c8",
+	}
+	for in, want := range cases {
+		if got := r.String(in); got != want {
+			t.Errorf("String(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	r.All = true
+	if got := r.String("al-laptop.local SHA256:abc+/="); got != "<hostname>.local SHA256:<redacted>" {
+		t.Errorf("String with All = %q", got)
+	}
+}
+
+func TestApply(t *testing.T) {
+	rows := []diff.Row{
+		{"type": "meta", "run_id": "/home/al", "user": "al", "hostname": "box"},
+		{"type": "ssh_keys", "items": []any{map[string]any{"path": "/home/al/.ssh/id_ed25519.pub", "fingerprint": "SHA256:xyz"}}},
+		{"type": "top_processes_cpu", "items": []any{map[string]any{"pid": 1.0, "command": "/usr/bin/python3 /home/al/job.py --key=k"}}},
+	}
+	n := ForRows(rows, true).Apply(rows)
+	want := []diff.Row{
+		{"type": "meta", "run_id": "/home/al", "user": "al", "hostname": "<hostname>"},
+		{"type": "ssh_keys", "items": []any{map[string]any{"path": "~/.ssh/id_ed25519.pub", "fingerprint": "<fingerprint>"}}},
+		{"type": "top_processes_cpu", "items": []any{map[string]any{"pid": 1.0, "command": "/usr/bin/python3 <args>"}}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Apply =\n%v\nwant\n%v", rows, want)
+	}
+	if n != 4 {
+		t.Errorf("Apply changed %d values, want 4", n)
+	}
+	if n := ForRows(rows, true).Apply(rows); n != 0 {
+		t.Errorf("second Apply changed %d values, want 0", n)
+	}
+}
@@ -0,0 +1,468 @@
+// Package render re-renders osaudit's Markdown output (audit reports, diff and
+// check summaries) in alternative themes:
+//
+//   - markdown: the output as written (default)
+//   - plain: strictly ASCII-punctuated plain text with no Markdown syntax,
+//     emoji, box drawing, or color, for screen readers and old ticketing systems
+//   - high-contrast: a standalone HTML page with semantic structure (headings,
+//     lists, tables), high-contrast colors, and no meaning carried by color
+//
+// Only the Markdown subset the reports use is understood: ATX headings, bullet
+// and numbered lists, the diff markers "+ - ~", pipe tables, fenced code, and
+// inline `code`, **bold**, _italic_, links, and images.
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/kareemsasa/operating-system-audit/internal/i18n"
+)
+
+const (
+	ThemeMarkdown     = "markdown"
+	ThemePlain        = "plain"
+	ThemeHighContrast = "high-contrast"
+)
+
+// Themes lists the accepted --theme values.
+var Themes = []string{ThemeMarkdown, ThemePlain, ThemeHighContrast}
+
+// ValidTheme reports whether theme is a known theme name.
+func ValidTheme(theme string) bool {
+	for _, t := range Themes {
+		if t == theme {
+			return true
+		}
+	}
+	return false
+}
+
+// Options carries page metadata for HTML themes.
+type Options struct {
+	Title string // defaults to the first heading
+	Lang  string // BCP 47 language tag; defaults to "en"
+	// Diff marks md as diff output, whose "-" items are removals rather
+	// than plain bullets.
+	Diff bool
+	// DocURL, when set, returns the documentation link for the finding a
+	// marked diff item describes, or "". The high-contrast theme links it.
+	DocURL func(item string) string
+}
+
+// Render writes md to w in the given theme.
+func Render(w io.Writer, theme, md string, opts Options) error {
+	switch theme {
+	case "", ThemeMarkdown:
+		_, err := io.WriteString(w, md)
+		return err
+	case ThemePlain:
+		return renderPlain(w, parse(md), opts)
+	case ThemeHighContrast:
+		return renderHTML(w, parse(md), opts)
+	}
+	return fmt.Errorf("unknown theme %q (want %s)", theme, strings.Join(Themes, ", "))
+}
+
+// --- block parsing ---
+
+type blockKind int
+
+const (
+	blockHeading blockKind = iota
+	blockPara
+	blockItem
+	blockTable
+	blockCode
+	blockBlank
+)
+
+type block struct {
+	kind   blockKind
+	level  int    // heading level, or list nesting depth
+	marker string // list marker: "-", "+", "~", "1." ...
+	text   string
+	rows   [][]string // table rows (header first)
+	lines  []string   // code lines
+}
+
+var (
+	headingRe = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	itemRe    = regexp.MustCompile(`^(\s*)([-*+~]|\d+\.)\s+(.*)$`)
+	tableSep  = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+)
+
+func parse(md string) []block {
+	var out []block
+	sc := bufio.NewScanner(strings.NewReader(md))
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	var code *block
+	listBase := 0 // indent of the first item in the current list
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if code != nil {
+			if strings.HasPrefix(strings.TrimSpace(line), "```") {
+				out = append(out, *code)
+				code = nil
+			} else {
+				code.lines = append(code.lines, line)
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			out = append(out, block{kind: blockBlank})
+		case strings.HasPrefix(trimmed, "```"):
+			code = &block{kind: blockCode}
+		case headingRe.MatchString(trimmed):
+			m := headingRe.FindStringSubmatch(trimmed)
+			out = append(out, block{kind: blockHeading, level: len(m[1]), text: m[2]})
+		case strings.HasPrefix(trimmed, "|"):
+			if tableSep.MatchString(trimmed) {
+				continue
+			}
+			cells := splitRow(trimmed)
+			if n := len(out); n > 0 && out[n-1].kind == blockTable {
+				out[n-1].rows = append(out[n-1].rows, cells)
+			} else {
+				out = append(out, block{kind: blockTable, rows: [][]string{cells}})
+			}
+		case itemRe.MatchString(line):
+			m := itemRe.FindStringSubmatch(line)
+			if n := len(out); n == 0 || out[n-1].kind != blockItem {
+				listBase = len(m[1])
+			}
+			level := (len(m[1]) - listBase) / 2
+			if level < 0 {
+				level = 0
+			}
+			out = append(out, block{kind: blockItem, level: level, marker: m[2], text: m[3]})
+		case stripDecorations(trimmed) == "":
+			// Rules and other purely decorative lines.
+			out = append(out, block{kind: blockBlank})
+		default:
+			out = append(out, block{kind: blockPara, text: trimmed})
+		}
+	}
+	if code != nil {
+		out = append(out, *code)
+	}
+	return out
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// --- inline handling ---
+
+var (
+	imageRe  = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	linkRe   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldRe   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	italicRe = regexp.MustCompile(`(^|[\s(])_([^_]+)_([\s).,:;]|$)`)
+	codeRe   = regexp.MustCompile("`([^`]+)`")
+	urlRe    = regexp.MustCompile(`https?://[^\s<>"']+[^\s<>"'.,;:)]`)
+)
+
+// asciiReplacements spell out the typographic symbols the reports use.
+var asciiReplacements = strings.NewReplacer(
+	"→", "->", "←", "<-", "×", "x", "—", "-", "–", "-", "…", "...",
+	"≥", ">=", "≤", "<=", "✓", "OK", "✔", "OK", "✗", "FAIL", "✘", "FAIL",
+	"“", `"`, "”", `"`, "‘", "'", "’", "'", "•", "-",
+)
+
+func isBoxDrawing(r rune) bool {
+	return r >= 0x2500 && r <= 0x259f // box drawing, block elements
+}
+
+func stripBoxDrawing(s string) string {
+	return strings.Map(func(r rune) rune {
+		if isBoxDrawing(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// stripDecorations removes emoji, pictographs, box drawing, and their
+// modifiers while keeping letters and digits in every script, so translated
+// text survives.
+func stripDecorations(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '‍' || r == '️' || r == '︎':
+			continue
+		case isBoxDrawing(r):
+			continue
+		case unicode.Is(unicode.So, r) || unicode.Is(unicode.Sk, r) && r > 0x7f:
+			continue
+		case r >= 0x1f3fb && r <= 0x1f3ff: // skin tone modifiers
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func plainInline(s string) string {
+	s = asciiReplacements.Replace(s)
+	s = imageRe.ReplaceAllString(s, "[image: $1]")
+	s = linkRe.ReplaceAllString(s, "$1 ($2)")
+	s = boldRe.ReplaceAllString(s, "$1")
+	s = italicRe.ReplaceAllString(s, "$1$2$3")
+	s = codeRe.ReplaceAllString(s, "$1")
+	return stripDecorations(s)
+}
+
+func htmlInline(s string) string {
+	s = stripDecorations(s)
+	// Escape first, then re-insert markup; the patterns only match characters
+	// that escaping leaves alone.
+	var codes []string
+	s = codeRe.ReplaceAllStringFunc(s, func(m string) string {
+		codes = append(codes, "<code>"+html.EscapeString(m[1:len(m)-1])+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(codes)-1)
+	})
+	s = html.EscapeString(s)
+	s = imageRe.ReplaceAllString(s, `<img src="$2" alt="$1">`)
+	s = linkRe.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	if !strings.Contains(s, "<a ") {
+		s = urlRe.ReplaceAllString(s, `<a href="$0">$0</a>`)
+	}
+	s = boldRe.ReplaceAllString(s, "<strong>$1</strong>")
+	s = italicRe.ReplaceAllString(s, "$1<em>$2</em>$3")
+	for i, c := range codes {
+		s = strings.Replace(s, fmt.Sprintf("\x00%d\x00", i), c, 1)
+	}
+	return s
+}
+
+// markerLabel spells out diff markers so meaning never depends on a symbol.
+// "-" is a removal only in diff output; elsewhere it is a plain bullet.
+func markerLabel(marker string, diff bool) string {
+	switch {
+	case marker == "+":
+		return i18n.T("render.marker.added")
+	case marker == "~":
+		return i18n.T("render.marker.changed")
+	case marker == "-" && diff:
+		return i18n.T("render.marker.removed")
+	}
+	return ""
+}
+
+// --- plain ---
+
+func renderPlain(w io.Writer, blocks []block, opts Options) error {
+	bw := bufio.NewWriter(w)
+	lastBlank := true
+	blank := func() {
+		if !lastBlank {
+			bw.WriteString("\n")
+			lastBlank = true
+		}
+	}
+	for _, b := range blocks {
+		switch b.kind {
+		case blockBlank:
+			blank()
+			continue
+		case blockHeading:
+			blank()
+			fmt.Fprintf(bw, "%s\n", plainInline(b.text))
+			if b.level <= 2 {
+				bw.WriteString("\n")
+				lastBlank = true
+				continue
+			}
+		case blockItem:
+			text := plainInline(b.text)
+			switch label := markerLabel(b.marker, opts.Diff); {
+			case label != "":
+				text = "- " + label + ": " + text
+			case strings.HasSuffix(b.marker, "."):
+				text = b.marker + " " + text
+			default:
+				text = "- " + text
+			}
+			fmt.Fprintf(bw, "%s%s\n", strings.Repeat("  ", b.level+1), text)
+		case blockPara:
+			fmt.Fprintf(bw, "%s\n", plainInline(b.text))
+		case blockTable:
+			for i, row := range b.rows {
+				if i == 0 {
+					continue
+				}
+				var parts []string
+				for j, cell := range row {
+					head := ""
+					if j < len(b.rows[0]) {
+						head = plainInline(b.rows[0][j])
+					}
+					if head != "" {
+						parts = append(parts, head+": "+plainInline(cell))
+					} else {
+						parts = append(parts, plainInline(cell))
+					}
+				}
+				fmt.Fprintf(bw, "  - %s\n", strings.Join(parts, "; "))
+			}
+		case blockCode:
+			for _, l := range b.lines {
+				l = stripBoxDrawing(asciiReplacements.Replace(l))
+				fmt.Fprintf(bw, "    %s\n", strings.TrimRight(l, " "))
+			}
+		}
+		lastBlank = false
+	}
+	return bw.Flush()
+}
+
+// --- high-contrast HTML ---
+
+const highContrastCSS = `
+:root { color-scheme: dark light; }
+body { margin: 0 auto; max-width: 60rem; padding: 1rem 1.5rem; background: #000; color: #fff;
+  font: 1.125rem/1.6 system-ui, -apple-system, "Segoe UI", sans-serif; }
+h1, h2, h3, h4 { line-height: 1.25; border-bottom: 2px solid currentColor; padding-bottom: .2rem; }
+a { color: #ffeb3b; text-decoration: underline; text-underline-offset: .15em; }
+a:focus, a:hover { outline: 3px solid #ffeb3b; outline-offset: 2px; }
+code, pre { font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 1rem; }
+code { border: 1px solid currentColor; padding: 0 .25rem; }
+pre { border: 2px solid currentColor; padding: .75rem; overflow-x: auto; white-space: pre-wrap; }
+table { border-collapse: collapse; margin: 1rem 0; }
+th, td { border: 1px solid currentColor; padding: .3rem .6rem; text-align: left; }
+li { margin: .2rem 0; }
+.marker { font-weight: 700; }
+@media (prefers-color-scheme: light) {
+  body { background: #fff; color: #000; }
+  a { color: #0000c8; }
+  a:focus, a:hover { outline-color: #0000c8; }
+}
+@media (forced-colors: active) { h1, h2, h3, h4, pre, th, td { border-color: CanvasText; } }
+`
+
+func listTag(marker string) string {
+	if strings.HasSuffix(marker, ".") {
+		return "ol"
+	}
+	return "ul"
+}
+
+func renderHTML(w io.Writer, blocks []block, opts Options) error {
+	title := opts.Title
+	if title == "" {
+		for _, b := range blocks {
+			if b.kind == blockHeading {
+				title = stripDecorations(codeRe.ReplaceAllString(b.text, "$1"))
+				break
+			}
+		}
+	}
+	if title == "" {
+		title = "osaudit report"
+	}
+	lang := opts.Lang
+	if lang == "" {
+		lang = "en"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<!DOCTYPE html>\n<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\">\n", html.EscapeString(lang))
+	bw.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(bw, "<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n<main>\n", html.EscapeString(title), highContrastCSS)
+
+	depth := -1 // open <ul>/<ol> nesting depth
+	var listTags []string
+	closeLists := func(to int) {
+		for depth > to {
+			fmt.Fprintf(bw, "</li>\n</%s>\n", listTags[len(listTags)-1])
+			listTags = listTags[:len(listTags)-1]
+			depth--
+		}
+	}
+	for _, b := range blocks {
+		if b.kind != blockItem {
+			closeLists(-1)
+		}
+		switch b.kind {
+		case blockHeading:
+			fmt.Fprintf(bw, "<h%d>%s</h%d>\n", b.level, htmlInline(b.text), b.level)
+		case blockPara:
+			fmt.Fprintf(bw, "<p>%s</p>\n", htmlInline(b.text))
+		case blockItem:
+			level := b.level
+			if level > depth+1 {
+				level = depth + 1
+			}
+			switch {
+			case level > depth:
+				tag := listTag(b.marker)
+				fmt.Fprintf(bw, "<%s>\n", tag)
+				listTags = append(listTags, tag)
+				depth = level
+			case level < depth:
+				closeLists(level)
+				bw.WriteString("</li>\n")
+			default:
+				bw.WriteString("</li>\n")
+			}
+			if tag := listTag(b.marker); tag != listTags[len(listTags)-1] {
+				fmt.Fprintf(bw, "</%s>\n<%s>\n", listTags[len(listTags)-1], tag)
+				listTags[len(listTags)-1] = tag
+			}
+			bw.WriteString("<li>")
+			label := markerLabel(b.marker, opts.Diff)
+			if label != "" {
+				fmt.Fprintf(bw, "<span class=\"marker\">%s:</span> ", label)
+			}
+			bw.WriteString(htmlInline(b.text))
+			if label != "" && opts.DocURL != nil {
+				if u := opts.DocURL(b.text); u != "" && !strings.Contains(b.text, u) {
+					fmt.Fprintf(bw, " <a class=\"doc\" href=\"%s\">%s</a>", html.EscapeString(u), html.EscapeString(i18n.T("render.doc_link")))
+				}
+			}
+		case blockTable:
+			bw.WriteString("<table>\n")
+			for i, row := range b.rows {
+				cell := "td"
+				if i == 0 {
+					cell = "th scope=\"col\""
+					bw.WriteString("<thead>\n")
+				} else if i == 1 {
+					bw.WriteString("<tbody>\n")
+				}
+				bw.WriteString("<tr>")
+				for _, c := range row {
+					fmt.Fprintf(bw, "<%s>%s</%s>", cell, htmlInline(c), strings.Fields(cell)[0])
+				}
+				bw.WriteString("</tr>\n")
+				if i == 0 {
+					bw.WriteString("</thead>\n")
+				}
+			}
+			if len(b.rows) > 1 {
+				bw.WriteString("</tbody>\n")
+			}
+			bw.WriteString("</table>\n")
+		case blockCode:
+			fmt.Fprintf(bw, "<pre><code>%s</code></pre>\n", html.EscapeString(stripBoxDrawing(strings.Join(b.lines, "\n"))))
+		}
+	}
+	closeLists(-1)
+	bw.WriteString("</main>\n</body>\n</html>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,151 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+const sample = "# 🖥️ System Audit — host\n" +
+	"━━━━━━━━━━━━━━━━━━━━\n" +
+	"## 🔐 Security\n" +
+	"| Setting | Value |\n" +
+	"|---------|-------|\n" +
+	"| **FileVault** | ✅ On |\n" +
+	"\n" +
+	"- **Disk**: 10 GB → 12 GB\n" +
+	"  - see [docs](https://example.com/a?b=1&c=2)\n" +
+	"1. first\n" +
+	"\n" +
+	"## Probe failures delta\n" +
+	"  + config.spctl_status failed 2× (high)\n" +
+	"  ~ config.x 1×→2×\n" +
+	"```\n" +
+	"╔═╗ <raw>\n" +
+	"```\n"
+
+func renderString(t *testing.T, theme string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Render(&buf, theme, sample, Options{Lang: "de"}); err != nil {
+		t.Fatalf("Render(%s): %v", theme, err)
+	}
+	return buf.String()
+}
+
+func TestMarkdownPassesThrough(t *testing.T) {
+	if got := renderString(t, ThemeMarkdown); got != sample {
+		t.Errorf("markdown theme changed the input:\n%s", got)
+	}
+}
+
+func TestPlainHasNoDecorations(t *testing.T) {
+	got := renderString(t, ThemePlain)
+	for _, r := range got {
+		if unicode.Is(unicode.So, r) || isBoxDrawing(r) || r > unicode.MaxASCII {
+			t.Fatalf("plain output contains %q:\n%s", r, got)
+		}
+	}
+	for _, s := range []string{"**", "`", "|", "#", "](", "━"} {
+		if strings.Contains(got, s) {
+			t.Errorf("plain output contains markdown %q:\n%s", s, got)
+		}
+	}
+	for _, want := range []string{
+		"System Audit - host\n",
+		"  - Setting: FileVault; Value: On\n",
+		"  - Disk: 10 GB -> 12 GB\n",
+		"    - see docs (https://example.com/a?b=1&c=2)\n",
+		"  1. first\n",
+		"  - Added: config.spctl_status failed 2x (high)\n",
+		"  - Changed: config.x 1x->2x\n",
+		"    <raw>\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("plain output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestPlainLabelsRemovalsInDiffs(t *testing.T) {
+	md := "## Inventory changes\n  - file_hash: /etc/hosts\n  + file_hash: /etc/motd\n"
+	for _, tc := range []struct {
+		diff bool
+		want string
+	}{
+		{true, "  - Removed: file_hash: /etc/hosts\n"},
+		{false, "  - file_hash: /etc/hosts\n"},
+	} {
+		var buf bytes.Buffer
+		if err := Render(&buf, ThemePlain, md, Options{Diff: tc.diff}); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); !strings.Contains(got, tc.want) || !strings.Contains(got, "  - Added: file_hash: /etc/motd\n") {
+			t.Errorf("Diff=%v: plain output missing %q:\n%s", tc.diff, tc.want, got)
+		}
+	}
+}
+
+func TestHighContrastLinksDiffItemsToDocs(t *testing.T) {
+	md := "## Inventory changes\n  - sensitive_dirs: /etc/ssh\n"
+	opts := Options{Diff: true, DocURL: func(item string) string {
+		if strings.HasPrefix(item, "sensitive_dirs:") {
+			return "https://example.com/findings.md#security-sensitive-dirs"
+		}
+		return ""
+	}}
+	var buf bytes.Buffer
+	if err := Render(&buf, ThemeHighContrast, md, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := `<a class="doc" href="https://example.com/findings.md#security-sensitive-dirs">Documentation</a>`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("high-contrast output missing doc link %q:\n%s", want, buf.String())
+	}
+}
+
+func TestPlainKeepsTranslatedText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, ThemePlain, "## Änderungen ✅\n", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "Änderungen\n\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestHighContrastHTML(t *testing.T) {
+	got := renderString(t, ThemeHighContrast)
+	for _, want := range []string{
+		`<html lang="de">`,
+		"<title>System Audit — host</title>",
+		"<h1>System Audit — host</h1>",
+		`<th scope="col">Setting</th>`,
+		"<td><strong>FileVault</strong></td><td>On</td>",
+		`<a href="https://example.com/a?b=1&amp;c=2">docs</a>`,
+		"<ol>\n<li>first</li>\n</ol>",
+		`<li><span class="marker">Added:</span> config.spctl_status`,
+		`<li><span class="marker">Changed:</span> config.x`,
+		"<pre><code> &lt;raw&gt;</code></pre>",
+		"prefers-color-scheme",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("html output missing %q:\n%s", want, got)
+		}
+	}
+	for _, bad := range []string{"🖥", "🔐", "✅", "━"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("html output contains decoration %q", bad)
+		}
+	}
+}
+
+func TestUnknownTheme(t *testing.T) {
+	if err := Render(&bytes.Buffer{}, "neon", sample, Options{}); err == nil {
+		t.Error("want error for unknown theme")
+	}
+	if ValidTheme("neon") || !ValidTheme(ThemePlain) {
+		t.Error("ValidTheme mismatch")
+	}
+}
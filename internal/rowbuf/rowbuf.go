@@ -0,0 +1,296 @@
+// Package rowbuf holds large sets of NDJSON rows within a memory ceiling.
+//
+// A Buffer keeps rows in memory until their estimated size passes its limit,
+// then moves them to a gzip-compressed temporary file and appends every later
+// row there. Rows are read back in the order they were added. Merge, diff,
+// and compare use buffers for the row sets that grow with the host (file_hash
+// and large_file rows), so osaudit stays usable on small servers that process
+// many or very large snapshots.
+package rowbuf
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// Row is one NDJSON row. diff.Row converts to and from it.
+type Row = map[string]any
+
+// DefaultLimit is the memory ceiling when OSAUDIT_MEMORY_LIMIT is unset.
+const DefaultLimit = 256 << 20
+
+const maxLineSize = 16 << 20
+
+// EnvLimit returns the memory ceiling set by OSAUDIT_MEMORY_LIMIT, a byte
+// count with an optional K, M, or G suffix (powers of 1024), or DefaultLimit
+// when it is unset.
+func EnvLimit() (int64, error) {
+	s, ok := os.LookupEnv(config.MemoryLimitEnv)
+	if !ok || strings.TrimSpace(s) == "" {
+		return DefaultLimit, nil
+	}
+	n, err := ParseSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", config.MemoryLimitEnv, err)
+	}
+	return n, nil
+}
+
+// ParseSize parses a byte count such as "4096", "512K", "256M", or "2G".
+// A trailing "B" or "iB" is accepted, so "256MiB" and "256MB" mean 256M.
+func ParseSize(s string) (int64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	t = strings.TrimSuffix(strings.TrimSuffix(t, "B"), "I")
+	shift := 0
+	if t != "" {
+		switch t[len(t)-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		}
+	}
+	if shift > 0 {
+		t = t[:len(t)-1]
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+	if err != nil || n <= 0 || n > (1<<62)>>shift {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 512M or 2G)", s)
+	}
+	return n << shift, nil
+}
+
+// Buffer is an append-only sequence of rows that spills to disk past its
+// limit. The zero value is not usable; call New. Close removes the spill file.
+type Buffer struct {
+	limit int64
+	used  int64
+	mem   []Row
+	n     int
+
+	file   *os.File
+	bw     *bufio.Writer
+	zw     *gzip.Writer
+	enc    *rawjson.Encoder
+	sealed bool // the spill file is complete; no more rows can be added
+}
+
+// New returns an empty buffer that keeps up to limit bytes of rows in memory.
+func New(limit int64) *Buffer {
+	return &Buffer{limit: limit}
+}
+
+// Add appends r. The buffer keeps r itself until it spills; callers must not
+// modify r afterwards.
+func (b *Buffer) Add(r Row) error {
+	if b.sealed {
+		return errors.New("rowbuf: add after read")
+	}
+	b.n++
+	if b.file == nil {
+		size := Estimate(r)
+		if b.used+size <= b.limit {
+			b.mem = append(b.mem, r)
+			b.used += size
+			return nil
+		}
+		if err := b.spill(); err != nil {
+			return err
+		}
+	}
+	return b.enc.Encode(r)
+}
+
+// spill moves the rows held in memory to a new temporary file.
+func (b *Buffer) spill() error {
+	f, err := writeguard.CreateTemp("", "osaudit-rows-*.ndjson.gz")
+	if err != nil {
+		return fmt.Errorf("rowbuf: spill: %w", err)
+	}
+	b.file = f
+	b.bw = bufio.NewWriter(f)
+	b.zw, _ = gzip.NewWriterLevel(b.bw, gzip.BestSpeed)
+	b.enc = rawjson.NewEncoder(b.zw)
+	b.enc.SetEscapeHTML(false)
+	for _, r := range b.mem {
+		if err := b.enc.Encode(r); err != nil {
+			return fmt.Errorf("rowbuf: spill: %w", err)
+		}
+	}
+	b.mem, b.used = nil, 0
+	return nil
+}
+
+// Len returns the number of rows added.
+func (b *Buffer) Len() int { return b.n }
+
+// Spilled reports whether the rows were moved to disk.
+func (b *Buffer) Spilled() bool { return b.file != nil }
+
+// Each calls fn with every row in the order they were added, stopping at the
+// first error. Rows read back from disk are fresh copies. Once Each has been
+// called, Add fails.
+func (b *Buffer) Each(fn func(Row) error) error {
+	b.sealed = true
+	if b.file == nil {
+		for _, r := range b.mem {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if b.zw != nil {
+		if err := b.zw.Close(); err != nil {
+			return fmt.Errorf("rowbuf: spill: %w", err)
+		}
+		if err := b.bw.Flush(); err != nil {
+			return fmt.Errorf("rowbuf: spill: %w", err)
+		}
+		b.zw, b.bw, b.enc = nil, nil, nil
+	}
+	zr, err := gzip.NewReader(io.NewSectionReader(b.file, 0, 1<<62))
+	if err != nil {
+		return fmt.Errorf("rowbuf: read spill: %w", err)
+	}
+	defer zr.Close()
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var r Row
+		if err := rawjson.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("rowbuf: read spill: %w", err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("rowbuf: read spill: %w", err)
+	}
+	return nil
+}
+
+// Close releases the buffer's rows and removes its spill file, if any.
+func (b *Buffer) Close() error {
+	b.mem, b.used, b.sealed = nil, 0, true
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	err := b.file.Close()
+	if rmErr := writeguard.Remove(name); err == nil {
+		err = rmErr
+	}
+	b.file, b.bw, b.zw, b.enc = nil, nil, nil, nil
+	return err
+}
+
+// Partitions spreads keyed rows over buffers by a hash of the key, each with
+// an equal share of the memory ceiling. Two row sets partitioned into the
+// same number of buffers hold a key in the same bucket, so they can be
+// compared one bucket at a time with only that bucket in memory.
+type Partitions struct {
+	parts []*Buffer
+}
+
+// NewPartitions returns n empty buckets sharing limit bytes of memory.
+func NewPartitions(n int, limit int64) *Partitions {
+	p := &Partitions{parts: make([]*Buffer, n)}
+	for i := range p.parts {
+		p.parts[i] = New(limit / int64(n))
+	}
+	return p
+}
+
+// Add appends r to the bucket of key.
+func (p *Partitions) Add(key string, r Row) error {
+	return p.parts[Bucket(key, len(p.parts))].Add(r)
+}
+
+// Count returns the number of buckets.
+func (p *Partitions) Count() int { return len(p.parts) }
+
+// Bucket returns bucket i.
+func (p *Partitions) Bucket(i int) *Buffer { return p.parts[i] }
+
+// Len returns the number of rows across all buckets.
+func (p *Partitions) Len() int {
+	n := 0
+	for _, b := range p.parts {
+		n += b.Len()
+	}
+	return n
+}
+
+// Spilled reports whether any bucket was moved to disk.
+func (p *Partitions) Spilled() bool {
+	for _, b := range p.parts {
+		if b.Spilled() {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes every bucket.
+func (p *Partitions) Close() error {
+	var errs []error
+	for _, b := range p.parts {
+		errs = append(errs, b.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Bucket returns the bucket, of n, that key belongs to.
+func Bucket(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// Estimate returns roughly how many bytes r occupies in memory: its keys and
+// string values plus a fixed overhead per map entry and value.
+func Estimate(r Row) int64 {
+	n := int64(48)
+	for k, v := range r {
+		n += 16 + int64(len(k)) + estimateValue(v)
+	}
+	return n
+}
+
+func estimateValue(v any) int64 {
+	switch x := v.(type) {
+	case string:
+		return 16 + int64(len(x))
+	case map[string]any:
+		return Estimate(x)
+	case []any:
+		n := int64(24)
+		for _, e := range x {
+			n += estimateValue(e)
+		}
+		return n
+	case []string:
+		n := int64(24)
+		for _, s := range x {
+			n += 16 + int64(len(s))
+		}
+		return n
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,107 @@
+package rowbuf
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func collect(t *testing.T, b *Buffer) []Row {
+	t.Helper()
+	var out []Row
+	if err := b.Each(func(r Row) error {
+		out = append(out, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestBufferSpillsPastLimit(t *testing.T) {
+	b := New(1024)
+	var want []Row
+	for i := 0; i < 200; i++ {
+		r := Row{"type": "file_hash", "path": fmt.Sprintf("/etc/f%03d", i), "size_bytes": float64(i)}
+		want = append(want, r)
+		if err := b.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !b.Spilled() {
+		t.Fatal("buffer did not spill past its limit")
+	}
+	if b.Len() != 200 {
+		t.Fatalf("Len = %d, want 200", b.Len())
+	}
+	name := b.file.Name()
+	// Rows come back in order, and can be read more than once.
+	for range 2 {
+		if got := collect(t, b); !reflect.DeepEqual(got, want) {
+			t.Fatalf("rows read back differ:\n got %v\nwant %v", got[:3], want[:3])
+		}
+	}
+	if err := b.Add(Row{"type": "x"}); err == nil {
+		t.Fatal("Add after Each succeeded")
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("spill file %s not removed: %v", name, err)
+	}
+}
+
+func TestBufferStaysInMemoryUnderLimit(t *testing.T) {
+	b := New(DefaultLimit)
+	defer b.Close()
+	r := Row{"type": "large_file", "path": "/var/log/big"}
+	if err := b.Add(r); err != nil {
+		t.Fatal(err)
+	}
+	if b.Spilled() {
+		t.Fatal("buffer spilled under its limit")
+	}
+	if got := collect(t, b); len(got) != 1 || !reflect.DeepEqual(got[0], r) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestPartitionsKeepKeysTogether(t *testing.T) {
+	a, b := NewPartitions(8, 512), NewPartitions(8, 1<<20)
+	defer a.Close()
+	defer b.Close()
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("/k%d", i)
+		a.Add(key, Row{"path": key})
+		b.Add(key, Row{"path": key})
+	}
+	if !a.Spilled() || b.Spilled() {
+		t.Fatalf("spilled: a=%v b=%v", a.Spilled(), b.Spilled())
+	}
+	if a.Len() != 100 {
+		t.Fatalf("Len = %d, want 100", a.Len())
+	}
+	for i := 0; i < a.Count(); i++ {
+		got, want := collect(t, a.Bucket(i)), collect(t, b.Bucket(i))
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("bucket %d differs: %v vs %v", i, got, want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"4096": 4096, "512K": 512 << 10, "256m": 256 << 20, "2G": 2 << 30, "64MiB": 64 << 20, "1gb": 1 << 30,
+	} {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0", "-1M", "lots", "1T", "99999999999G"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package rules loads declarative custom checks from ~/.osaudit/rules/*.yaml:
+// a field path, operator, expected value, severity, and message per rule. It is
+// the simple alternative to CEL policies (internal/policy) and is evaluated with
+// the same field lookup and operators as the built-in benchmarks. A rule whose
+// condition outgrows field/op/value can give a CEL expr instead, evaluated the
+// way policy rules are.
+package rules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/kareemsasa/operating-system-audit/internal/benchmark"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/policy"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
+)
+
+var validOps = map[string]bool{
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true, "in": true, "contains": true,
+}
+
+var validSeverities = map[string]bool{"high": true, "medium": true, "low": true}
+
+// Rule is one custom check, e.g.
+//
+//	rules:
+//	  - id: few-broken-symlinks
+//	    field: counts.broken_symlinks
+//	    op: lt
+//	    value: 10
+//	    severity: low
+//	    message: Clean up broken symlinks
+//	  - id: few-wildcard-listeners
+//	    expr: listening_ports.items.filter(p, p.bind == "0.0.0.0").size() <= 3
+//
+// A rule has either Field, Op, and Value or Expr.
+type Rule struct {
+	ID       string `json:"id"`
+	Field    string `json:"field,omitempty"`
+	Expr     string `json:"expr,omitempty"`
+	Op       string `json:"op"`
+	Value    any    `json:"value"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Probe    string `json:"probe,omitempty"`
+
+	// Source is the file the rule was loaded from.
+	Source string `json:"-"`
+}
+
+// RuleResult is the outcome of evaluating one rule.
+type RuleResult struct {
+	Rule   Rule
+	Status string // benchmark.StatusPass | StatusFail | StatusUnknown
+	Actual any
+	Reason string
+}
+
+// Result is the outcome of evaluating all custom rules against a snapshot.
+type Result struct {
+	Rules   []RuleResult
+	Pass    int
+	Fail    int
+	Unknown int
+}
+
+type ruleFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadDir loads every *.yaml / *.yml file in dir, in name order. A missing
+// directory yields no rules. Rule IDs must be unique across files.
+func LoadDir(dir string) ([]Rule, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		m, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, m...)
+	}
+	sort.Strings(paths)
+
+	var out []Rule
+	seen := make(map[string]string)
+	for _, p := range paths {
+		rs, err := LoadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rs {
+			if prev, dup := seen[r.ID]; dup {
+				return nil, fmt.Errorf("%s: rule %q already defined in %s", p, r.ID, prev)
+			}
+			seen[r.ID] = p
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// LoadFile parses one rule file: either a top-level list of rules or a mapping
+// with a "rules" list.
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := yamlite.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var rs []Rule
+	if _, isList := doc.([]any); isList {
+		err = yamlite.Unmarshal(data, &rs)
+	} else {
+		var f ruleFile
+		err = yamlite.Unmarshal(data, &f)
+		rs = f.Rules
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i := range rs {
+		rs[i].Source = path
+		if err := validate(rs[i]); err != nil {
+			return nil, fmt.Errorf("%s: rules[%d]: %w", path, i, err)
+		}
+	}
+	return rs, nil
+}
+
+func validate(r Rule) error {
+	switch {
+	case r.ID == "":
+		return fmt.Errorf("id is required")
+	case r.Expr != "" && (r.Field != "" || r.Op != "" || r.Value != nil):
+		return fmt.Errorf("rule %s: expr cannot be combined with field, op, or value", r.ID)
+	case r.Expr != "":
+		if _, err := policy.Compile(r.Expr); err != nil {
+			return fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+	case r.Field == "":
+		return fmt.Errorf("rule %s: field is required", r.ID)
+	case !validOps[r.Op]:
+		return fmt.Errorf("rule %s: unsupported op %q (want eq, ne, lt, le, gt, ge, in, contains)", r.ID, r.Op)
+	case r.Severity != "" && !validSeverities[r.Severity]:
+		return fmt.Errorf("rule %s: severity must be high, medium, or low", r.ID)
+	}
+	return nil
+}
+
+// Evaluate runs rules against the snapshot rows. A rule whose field is missing,
+// or whose expr errors, is unknown; a failing rule reports its message when it
+// has one.
+func Evaluate(rs []Rule, rows []diff.Row) Result {
+	b := benchmark.Benchmark{ID: "custom"}
+	p := policy.Policy{Name: "custom"}
+	for _, r := range rs {
+		if r.Expr != "" {
+			p.Rules = append(p.Rules, policy.Rule{ID: r.ID, Expr: r.Expr, Message: r.Message})
+			continue
+		}
+		b.Controls = append(b.Controls, benchmark.Control{
+			ID:    r.ID,
+			Check: benchmark.Check{Field: r.Field, Op: r.Op, Value: r.Value, Probe: r.Probe},
+		})
+	}
+	controls := benchmark.Evaluate(b, rows).Controls
+	exprs := policy.Evaluate(p, rows).Rules
+
+	var res Result
+	for _, r := range rs {
+		rr := RuleResult{Rule: r}
+		if r.Expr != "" {
+			pr := exprs[0]
+			exprs = exprs[1:]
+			rr.Status, rr.Reason = policyStatus[pr.Status], pr.Reason
+		} else {
+			cr := controls[0]
+			controls = controls[1:]
+			rr.Status, rr.Actual, rr.Reason = cr.Status, cr.Actual, cr.Reason
+			if cr.Status == benchmark.StatusFail && r.Message != "" {
+				rr.Reason = r.Message
+			}
+		}
+		switch rr.Status {
+		case benchmark.StatusPass:
+			res.Pass++
+		case benchmark.StatusFail:
+			res.Fail++
+		default:
+			res.Unknown++
+		}
+		res.Rules = append(res.Rules, rr)
+	}
+	return res
+}
+
+// policyStatus maps policy verdicts to the benchmark statuses custom rules
+// report.
+var policyStatus = map[string]string{
+	policy.StatusPass:      benchmark.StatusPass,
+	policy.StatusViolation: benchmark.StatusFail,
+	policy.StatusUnknown:   benchmark.StatusUnknown,
+}
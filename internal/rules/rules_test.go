@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/benchmark"
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+func writeRuleFile(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDirAndEvaluate(t *testing.T) {
+	dir := t.TempDir()
+	writeRuleFile(t, dir, "a.yaml", `rules:
+  - id: symlinks
+    field: counts.broken_symlinks
+    op: lt
+    value: 10
+    severity: low
+    message: Clean up broken symlinks
+`)
+	writeRuleFile(t, dir, "b.yml", `- id: mac
+  field: security_config.mac_framework
+  op: in
+  value: [selinux, apparmor]
+- id: missing
+  field: security_config.sip
+  op: eq
+  value: true
+- id: wildcard-listeners
+  expr: listening_ports.items.filter(p, p.bind == "0.0.0.0").size() <= 1
+  message: Too many wildcard listeners
+`)
+	writeRuleFile(t, dir, "notes.txt", "ignored")
+
+	rs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 4 || rs[0].ID != "symlinks" || !strings.HasSuffix(rs[2].Source, "b.yml") {
+		t.Fatalf("LoadDir() = %+v", rs)
+	}
+
+	res := Evaluate(rs, []diff.Row{
+		{"type": "counts", "broken_symlinks": 42.0},
+		{"type": "security_config", "mac_framework": "apparmor"},
+		{"type": "listening_ports", "items": []any{
+			map[string]any{"port": 22.0, "bind": "0.0.0.0"},
+			map[string]any{"port": 631.0, "bind": "127.0.0.1"},
+			map[string]any{"port": 8080.0, "bind": "0.0.0.0"},
+		}},
+	})
+	want := []string{benchmark.StatusFail, benchmark.StatusPass, benchmark.StatusUnknown, benchmark.StatusFail}
+	for i, rr := range res.Rules {
+		if rr.Status != want[i] {
+			t.Errorf("rule %s = %s (%s), want %s", rr.Rule.ID, rr.Status, rr.Reason, want[i])
+		}
+	}
+	if res.Rules[0].Reason != "Clean up broken symlinks" {
+		t.Errorf("fail reason = %q, want rule message", res.Rules[0].Reason)
+	}
+	if res.Pass != 1 || res.Fail != 2 || res.Unknown != 1 || res.Rules[3].Reason != "Too many wildcard listeners" {
+		t.Errorf("Evaluate() = %d pass, %d fail, %d unknown; expr reason %q", res.Pass, res.Fail, res.Unknown, res.Rules[3].Reason)
+	}
+
+	if rs, err := LoadDir(filepath.Join(dir, "absent")); err != nil || len(rs) != 0 {
+		t.Errorf("LoadDir(missing) = %v, %v; want no rules", rs, err)
+	}
+}
+
+func TestLoadDir_RejectsInvalidRules(t *testing.T) {
+	for name, body := range map[string]string{
+		"bad-op":   "- id: x\n  field: counts.a\n  op: approx\n  value: 1\n",
+		"no-field": "- id: x\n  op: eq\n  value: 1\n",
+		"severity": "- id: x\n  field: counts.a\n  op: eq\n  value: 1\n  severity: critical\n",
+		"bad-expr": "- id: x\n  expr: counts.a <\n",
+		"both":     "- id: x\n  expr: counts.a < 1\n  field: counts.a\n  op: lt\n  value: 1\n",
+	} {
+		dir := t.TempDir()
+		writeRuleFile(t, dir, "r.yaml", body)
+		if _, err := LoadDir(dir); err == nil {
+			t.Errorf("%s: LoadDir() = nil error", name)
+		}
+	}
+	dir := t.TempDir()
+	writeRuleFile(t, dir, "a.yaml", "- id: dup\n  field: counts.a\n  op: eq\n  value: 1\n")
+	writeRuleFile(t, dir, "b.yaml", "- id: dup\n  field: counts.b\n  op: eq\n  value: 1\n")
+	if _, err := LoadDir(dir); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("duplicate ids: err = %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package settings reads config.yaml, the defaults for flags that would
+// otherwise be repeated on every run:
+//
+//	output_dir: ~/audits            # where audits write (default <tree>/output)
+//	redact: all                     # all, paths, or none
+//	concurrency: 4                  # hash workers (default one per CPU)
+//	collectors:
+//	  disable: [execution]
+//	notify:
+//	  urls: [https://hooks.example.com/osaudit]
+//	ignore: ["inventory:listening_ports"]
+//
+// For any one setting a flag wins, then the environment (see
+// config.ApplyEnv), then the file, then the built-in default.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+	"github.com/kareemsasa/operating-system-audit/internal/config"
+	"github.com/kareemsasa/operating-system-audit/internal/deprecation"
+	"github.com/kareemsasa/operating-system-audit/internal/yamlite"
+)
+
+// File is the settings file's name in the config directory.
+const File = "config.yaml"
+
+// Redaction levels for Settings.Redact.
+const (
+	RedactAll   = "all"   // as --redact-all
+	RedactPaths = "paths" // as --redact-paths
+	RedactNone  = "none"  // as --no-redact
+)
+
+// Settings are the defaults config.yaml sets. Zero values leave the built-in
+// default in place.
+type Settings struct {
+	// OutputDir is where audits write their run directories, in place of
+	// the tree's output/. It must be absolute or start with ~/.
+	OutputDir string `json:"output_dir"`
+	// Redact is the redaction audit runs apply unless a flag says otherwise.
+	Redact string `json:"redact"`
+	// Concurrency is how many files `osaudit hash` reads in parallel.
+	Concurrency int `json:"concurrency"`
+	// Collectors are enabled and disabled like collectors.yaml, which
+	// overrides them: its enable list replaces this one, and disables add up.
+	Collectors collector.Selection `json:"collectors"`
+	// Notify lists webhook URLs scheduled runs POST their notifications to.
+	Notify Notify `json:"notify"`
+	// Ignore holds finding-key patterns diffs suppress, in addition to the
+	// rules in ignore.json.
+	Ignore []string `json:"ignore"`
+}
+
+// Notify is where notifications go besides the desktop.
+type Notify struct {
+	URLs []string `json:"urls"`
+}
+
+// Validate reports the first invalid setting.
+func (s Settings) Validate() error {
+	if s.OutputDir != "" && !filepath.IsAbs(s.OutputDir) && s.OutputDir != "~" && !strings.HasPrefix(s.OutputDir, "~/") {
+		return fmt.Errorf("output_dir %q must be absolute or start with ~/", s.OutputDir)
+	}
+	switch s.Redact {
+	case "", RedactAll, RedactPaths, RedactNone:
+	default:
+		return fmt.Errorf("redact %q must be %s, %s, or %s", s.Redact, RedactAll, RedactPaths, RedactNone)
+	}
+	if s.Concurrency < 0 {
+		return fmt.Errorf("concurrency %d must not be negative", s.Concurrency)
+	}
+	if err := s.Collectors.Validate(); err != nil {
+		return fmt.Errorf("collectors: %w", err)
+	}
+	for _, u := range s.Notify.URLs {
+		if p, err := url.Parse(u); err != nil || (p.Scheme != "https" && p.Scheme != "http") || p.Host == "" {
+			return fmt.Errorf("notify.urls: %q is not an http or https URL", u)
+		}
+	}
+	for _, p := range s.Ignore {
+		if strings.TrimSpace(p) == "" {
+			return errors.New("ignore: empty pattern")
+		}
+	}
+	return nil
+}
+
+// Parse decodes and validates a settings document as parsed by yamlite.Parse,
+// with the environment's settings over it; name prefixes errors.
+func Parse(name string, doc any) (Settings, error) {
+	var s Settings
+	doc, err := config.ApplyEnv("config", doc)
+	if err != nil {
+		return s, err
+	}
+	if err := yamlite.Decode(doc, &s); err != nil {
+		return s, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := s.Validate(); err != nil {
+		return s, fmt.Errorf("%s: %w", name, err)
+	}
+	return s, nil
+}
+
+// Load returns the settings in the config directory's config.yaml, with the
+// environment's over them. A missing file sets nothing.
+func Load() (Settings, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return Parse(File, nil)
+	}
+	p := filepath.Join(dir, File)
+	var doc any
+	data, err := os.ReadFile(p)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return Settings{}, err
+	default:
+		if doc, err = yamlite.Parse(data); err != nil {
+			return Settings{}, fmt.Errorf("%s: %w", p, err)
+		}
+		if err := deprecation.Check(deprecation.Settings, p, doc); err != nil {
+			return Settings{}, err
+		}
+	}
+	return Parse(p, doc)
+}
+
+// Output returns the directory audits write under: OutputDir with ~
+// expanded, or output/ in the tree at repoRoot.
+func (s Settings) Output(repoRoot string) string {
+	switch {
+	case s.OutputDir == "":
+		return filepath.Join(repoRoot, "output")
+	case s.OutputDir == "~" || strings.HasPrefix(s.OutputDir, "~/"):
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(s.OutputDir, "~"))
+		}
+	}
+	return filepath.Clean(s.OutputDir)
+}
@@ -0,0 +1,71 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kareemsasa/operating-system-audit/internal/collector"
+)
+
+const file = `output_dir: ~/audits
+redact: all
+concurrency: 4
+collectors:
+  disable: [execution]
+notify:
+  urls: [https://hooks.example.com/osaudit]
+ignore: ["inventory:listening_ports"]
+`
+
+func TestLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("OSAUDIT_HOME", home)
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, File), []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Redact != RedactAll || s.Concurrency != 4 || !reflect.DeepEqual(s.Collectors.Disable, []string{"execution"}) ||
+		!reflect.DeepEqual(s.Notify.URLs, []string{"https://hooks.example.com/osaudit"}) || !reflect.DeepEqual(s.Ignore, []string{"inventory:listening_ports"}) {
+		t.Errorf("Load() = %+v", s)
+	}
+	if got, want := s.Output("/opt/osaudit"), filepath.Join(home, "audits"); got != want {
+		t.Errorf("Output() = %q, want %q", got, want)
+	}
+	if got := (Settings{}).Output("/opt/osaudit"); got != filepath.Join("/opt/osaudit", "output") {
+		t.Errorf("Output() without output_dir = %q", got)
+	}
+
+	// The environment overrides the file, key by key.
+	t.Setenv("OSAUDIT_CONFIG_REDACT", "none")
+	t.Setenv("OSAUDIT_CONFIG_IGNORE", "probe_failure:*,inventory:usb_devices")
+	if s, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Redact != RedactNone || s.Concurrency != 4 || len(s.Ignore) != 2 {
+		t.Errorf("Load() with environment = %+v", s)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, bad := range []Settings{
+		{OutputDir: "audits"},
+		{Redact: "some"},
+		{Concurrency: -1},
+		{Collectors: collector.Selection{Disable: []string{"bogus"}}},
+		{Notify: Notify{URLs: []string{"ftp://example.com/x"}}},
+		{Ignore: []string{" "}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", bad)
+		}
+	}
+	if err := (Settings{OutputDir: "/var/lib/osaudit", Redact: RedactPaths}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
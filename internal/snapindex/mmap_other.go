@@ -0,0 +1,35 @@
+//go:build !unix
+
+package snapindex
+
+import (
+	"io"
+	"os"
+)
+
+// mapping reads spans with ReadAt where memory maps are not available.
+type mapping struct {
+	f *os.File
+}
+
+func openMapping(path string, _ int64) (*mapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &mapping{f: f}, nil
+}
+
+// Slice returns n bytes at off.
+func (m *mapping) Slice(off, n int64) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := m.f.ReadAt(buf, off); err != nil {
+		if err == io.EOF {
+			return nil, errShortRead
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (m *mapping) Close() error { return m.f.Close() }
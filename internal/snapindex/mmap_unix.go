@@ -0,0 +1,44 @@
+//go:build unix
+
+package snapindex
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapping is a read-only memory map of a snapshot.
+type mapping struct {
+	data []byte
+}
+
+func openMapping(path string, size int64) (*mapping, error) {
+	if size == 0 {
+		return &mapping{}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // the mapping outlives the descriptor
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	return &mapping{data: data}, nil
+}
+
+// Slice returns n bytes at off. The bytes are only valid until Close.
+func (m *mapping) Slice(off, n int64) ([]byte, error) {
+	if off < 0 || off+n > int64(len(m.data)) {
+		return nil, errShortRead
+	}
+	return m.data[off : off+n], nil
+}
+
+func (m *mapping) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	return syscall.Munmap(m.data)
+}
@@ -0,0 +1,229 @@
+// Package snapindex keeps a sidecar index next to stored NDJSON snapshots so
+// readers that only need some row types can seek to them instead of parsing
+// the whole file. Snapshots with per-file rows run to hundreds of MB, while a
+// targeted diff of, say, listening_ports needs a few KB of them.
+//
+// The index for run.ndjson is run.ndjson.idx: the byte spans each row type
+// occupies, plus the snapshot's size and modification time. An index that no
+// longer matches its snapshot is ignored, and readers fall back to a full scan.
+package snapindex
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/rawjson"
+	"github.com/kareemsasa/operating-system-audit/internal/writeguard"
+)
+
+// Version is the index format version.
+const Version = 1
+
+// Suffix is appended to a snapshot path to name its index.
+const Suffix = ".idx"
+
+var errShortRead = errors.New("snapshot is shorter than its index")
+
+// ErrCompressed is returned by Build for gzip-compressed snapshots, whose
+// byte offsets cannot be seeked to. Readers scan them in full.
+var ErrCompressed = errors.New("snapshot is compressed")
+
+// Span is a byte range [Offset, Offset+Length) holding consecutive rows of one
+// type, newlines included.
+type Span struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// Index maps row types to the spans that hold them.
+type Index struct {
+	Version int               `json:"version"`
+	Size    int64             `json:"size"`
+	ModTime time.Time         `json:"mod_time"`
+	Rows    int               `json:"rows"`
+	Types   map[string][]Span `json:"types"`
+}
+
+// Path returns the index path for a snapshot.
+func Path(snapshot string) string { return snapshot + Suffix }
+
+// Build scans snapshot and returns its index. Rows without a type are indexed
+// under "".
+func Build(snapshot string) (*Index, error) {
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &Index{Version: Version, Size: info.Size(), ModTime: info.ModTime(), Types: map[string][]Span{}}
+	r := bufio.NewReaderSize(f, 1<<20)
+	if diff.IsGzip(r) {
+		return nil, ErrCompressed
+	}
+	var offset int64
+	var prevType string
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			if t, ok, perr := rowType(line); perr != nil {
+				return nil, fmt.Errorf("%s: invalid JSON at line %d", snapshot, lineNo)
+			} else if ok {
+				spans := idx.Types[t]
+				if n := len(spans); n > 0 && prevType == t && spans[n-1].Offset+spans[n-1].Length == offset {
+					spans[n-1].Length += int64(len(line))
+				} else {
+					idx.Types[t] = append(spans, Span{Offset: offset, Length: int64(len(line))})
+				}
+				prevType = t
+				idx.Rows++
+			} else {
+				prevType = "" // a blank line ends the span
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// rowType extracts the "type" of one NDJSON line. ok is false for blank lines.
+func rowType(line []byte) (t string, ok bool, err error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return "", false, nil
+	}
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &head); err != nil {
+		return "", false, err
+	}
+	return head.Type, true, nil
+}
+
+// Write builds the index for snapshot and saves it next to it.
+func Write(snapshot string) (*Index, error) {
+	idx, err := Build(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return nil, err
+	}
+	tmp := Path(snapshot) + ".tmp"
+	if err := writeguard.WriteFile(tmp, data, 0o644); err != nil {
+		return nil, err
+	}
+	return idx, writeguard.Rename(tmp, Path(snapshot))
+}
+
+// Load reads snapshot's index. ok is false when there is none or it is stale.
+func Load(snapshot string) (idx *Index, ok bool) {
+	info, err := os.Stat(snapshot)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(Path(snapshot))
+	if err != nil {
+		return nil, false
+	}
+	if json.Unmarshal(data, &idx) != nil || idx.Version != Version || idx.Size != info.Size() || !idx.ModTime.Equal(info.ModTime()) {
+		return nil, false
+	}
+	return idx, true
+}
+
+// ReadTypes returns the rows of snapshot whose type is in types, in file
+// order. It seeks through a memory-mapped file when the snapshot has a fresh
+// index and scans the whole file otherwise, as it does for delta-encoded
+// file_hash rows.
+func ReadTypes(snapshot string, types []string) ([]diff.Row, error) {
+	want := make(map[string]bool, len(types))
+	for _, t := range types {
+		want[t] = true
+	}
+	idx, ok := Load(snapshot)
+	if ok && want["file_hash"] && len(idx.Types[diff.FIMDeltaType]) > 0 {
+		ok = false // delta-encoded file rows need their base chain
+	}
+	if !ok {
+		rows, err := diff.ReadNDJSON(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		out := rows[:0]
+		for _, row := range rows {
+			if t, _ := row["type"].(string); want[t] {
+				out = append(out, row)
+			}
+		}
+		return out, nil
+	}
+
+	// Read what older schema versions store the wanted types as, and the
+	// meta row that says which version this is.
+	var spans []Span
+	for _, t := range diff.UpgradeSourceTypes(types) {
+		spans = append(spans, idx.Types[t]...)
+	}
+	if len(spans) == 0 {
+		return nil, nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Offset < spans[j].Offset })
+
+	m, err := openMapping(snapshot, idx.Size)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	var rows []diff.Row
+	for _, s := range spans {
+		data, err := m.Slice(s.Offset, s.Length)
+		if err != nil {
+			return nil, err
+		}
+		for len(data) > 0 {
+			line := data
+			if i := bytes.IndexByte(data, '\n'); i >= 0 {
+				line, data = data[:i], data[i+1:]
+			} else {
+				data = nil
+			}
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var row diff.Row
+			if err := rawjson.Unmarshal(line, &row); err != nil {
+				return nil, fmt.Errorf("%s: invalid JSON at offset %d (stale index? run `osaudit index`)", snapshot, s.Offset)
+			}
+			rows = append(rows, row)
+		}
+	}
+	rows = diff.UpgradeRows(rows)
+	out := rows[:0]
+	for _, row := range rows {
+		if t, _ := row["type"].(string); want[t] {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,160 @@
+package snapindex
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+	"github.com/kareemsasa/operating-system-audit/internal/perf"
+)
+
+const snapshot = `{"type":"meta","run_id":"r1"}
+{"type":"large_file","path":"/a","size_bytes":1}
+{"type":"large_file","path":"/b","size_bytes":2}
+
+{"type":"large_file","path":"/c","size_bytes":3}
+{"type":"listening_ports","items":[{"process":"sshd","port":22}]}
+{"type":"large_file","path":"/d","size_bytes":4}
+`
+
+func writeSnapshot(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "run.ndjson")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuild(t *testing.T) {
+	path := writeSnapshot(t, snapshot)
+	idx, err := Write(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Rows != 6 {
+		t.Errorf("Rows = %d, want 6", idx.Rows)
+	}
+	// The blank line and the listening_ports row each split the large_file rows.
+	if n := len(idx.Types["large_file"]); n != 3 {
+		t.Errorf("large_file spans = %v, want 3", idx.Types["large_file"])
+	}
+	if _, ok := Load(path); !ok {
+		t.Error("Load() did not accept a fresh index")
+	}
+
+	if _, err := Build(writeSnapshot(t, "{\"type\":\"meta\"}\n{nope\n")); err == nil {
+		t.Error("Build() accepted invalid JSON")
+	}
+}
+
+func TestReadTypes(t *testing.T) {
+	path := writeSnapshot(t, snapshot)
+	types := []string{"large_file", "listening_ports"}
+	scanned, err := ReadTypes(path, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scanned) != 5 {
+		t.Fatalf("ReadTypes() without index = %d rows, want 5", len(scanned))
+	}
+
+	if _, err := Write(path); err != nil {
+		t.Fatal(err)
+	}
+	indexed, err := ReadTypes(path, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(indexed, scanned) {
+		t.Errorf("indexed read differs from scan:\n%v\n%v", indexed, scanned)
+	}
+	if rows, err := ReadTypes(path, []string{"kernel_modules"}); err != nil || len(rows) != 0 {
+		t.Errorf("ReadTypes(absent type) = %v, %v", rows, err)
+	}
+}
+
+func TestReadTypesLeavesOutSchemaMeta(t *testing.T) {
+	path := writeSnapshot(t, `{"type":"meta","run_id":"r1","schema_version":"0.1"}
+{"type":"security_config","firewall":true}
+`)
+	if _, err := Write(path); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadTypes(path, []string{"security_config"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// meta is read for its schema_version but was not asked for.
+	if len(rows) != 1 || rows[0]["type"] != "security_config" {
+		t.Errorf("ReadTypes(security_config) = %v, want only the security_config row", rows)
+	}
+}
+
+func TestStaleIndexIsIgnored(t *testing.T) {
+	path := writeSnapshot(t, snapshot)
+	if _, err := Write(path); err != nil {
+		t.Fatal(err)
+	}
+	// Appending rows (as plugin merges do) invalidates the index.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type":"large_file","path":"/e","size_bytes":5}` + "\n")
+	f.Close()
+	later := time.Now().Add(time.Second)
+	os.Chtimes(path, later, later)
+
+	if _, ok := Load(path); ok {
+		t.Fatal("Load() accepted a stale index")
+	}
+	rows, err := ReadTypes(path, []string{"large_file"})
+	if err != nil || len(rows) != 5 {
+		t.Errorf("ReadTypes() with stale index = %d rows, %v; want 5", len(rows), err)
+	}
+}
+
+func writeSynthetic(b *testing.B) string {
+	base, _ := perf.Synthesize(perf.Options{Rows: perf.DefaultRows, Seed: 1})
+	path := filepath.Join(b.TempDir(), "run.ndjson")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	enc := json.NewEncoder(f)
+	for _, row := range base {
+		enc.Encode(row)
+	}
+	f.Close()
+	return path
+}
+
+func BenchmarkReadTypesIndexed(b *testing.B) {
+	path := writeSynthetic(b)
+	if _, err := Write(path); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadTypes(path, []string{"listening_ports", "launch_daemons"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadFull(b *testing.B) {
+	path := writeSynthetic(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := diff.ReadNDJSON(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
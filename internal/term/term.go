@@ -0,0 +1,116 @@
+// Package term puts a terminal into raw mode and decodes the keys read from
+// it, for osaudit's interactive menu. It covers the few termios calls and
+// escape sequences the menu needs; the module has no third-party
+// dependencies, so this replaces a full terminal UI library. Raw mode is
+// supported on Linux and macOS. Elsewhere MakeRaw returns ErrUnsupported and
+// the menu falls back to numbered prompts.
+package term
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// ErrUnsupported is returned by MakeRaw and Size where raw terminal mode is
+// not implemented.
+var ErrUnsupported = errors.New("raw terminal mode is not supported on this platform")
+
+// KeyCode names a key. Printable characters are KeyRune, with the character
+// in Key.Rune.
+type KeyCode int
+
+// Keys the menu handles.
+const (
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyPgUp
+	KeyPgDn
+	KeyHome
+	KeyEnd
+	KeyEnter
+	KeySpace
+	KeyTab
+	KeyBackspace
+	KeyEsc
+	KeyCtrlC
+)
+
+// Key is one key press.
+type Key struct {
+	Code KeyCode
+	Rune rune // KeyRune only
+}
+
+// csiKeys are the keys sent as ESC [ <final> or ESC O <final>.
+var csiKeys = map[byte]KeyCode{'A': KeyUp, 'B': KeyDown, 'C': KeyRight, 'D': KeyLeft, 'H': KeyHome, 'F': KeyEnd}
+
+// tildeKeys are the keys sent as ESC [ <n> ~.
+var tildeKeys = map[string]KeyCode{"1": KeyHome, "7": KeyHome, "4": KeyEnd, "8": KeyEnd, "5": KeyPgUp, "6": KeyPgDn}
+
+// ParseKeys decodes the keys in b, the bytes of one read from a raw terminal.
+// A terminal sends an escape sequence in a single write, so an ESC that ends
+// b is the Esc key itself. Sequences for keys the menu does not handle, and
+// other control characters, are dropped.
+func ParseKeys(b []byte) []Key {
+	var keys []Key
+	for len(b) > 0 {
+		c := b[0]
+		switch {
+		case c == 0x1b:
+			k, n := parseEscape(b)
+			if k != nil {
+				keys = append(keys, *k)
+			}
+			b = b[n:]
+			continue
+		case c == '\r' || c == '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+		case c == ' ':
+			keys = append(keys, Key{Code: KeySpace})
+		case c == '\t':
+			keys = append(keys, Key{Code: KeyTab})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+		case c == 0x03:
+			keys = append(keys, Key{Code: KeyCtrlC})
+		case c < 0x20:
+		default:
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, Key{Code: KeyRune, Rune: r})
+			b = b[n:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// parseEscape decodes the escape sequence at the start of b, which begins with
+// ESC, and returns its key (nil for one the menu does not handle) and length.
+func parseEscape(b []byte) (*Key, int) {
+	if len(b) == 1 || (b[1] != '[' && b[1] != 'O') {
+		return &Key{Code: KeyEsc}, 1
+	}
+	// Parameters and intermediates run up to the final byte, 0x40–0x7e.
+	i := 2
+	for i < len(b) && (b[i] < 0x40 || b[i] > 0x7e) {
+		i++
+	}
+	if i == len(b) {
+		return nil, len(b)
+	}
+	params, final := string(b[2:i]), b[i]
+	if final == '~' {
+		if code, ok := tildeKeys[params]; ok {
+			return &Key{Code: code}, i + 1
+		}
+		return nil, i + 1
+	}
+	if code, ok := csiKeys[final]; ok {
+		return &Key{Code: code}, i + 1
+	}
+	return nil, i + 1
+}
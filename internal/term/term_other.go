@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package term
+
+// MakeRaw returns ErrUnsupported.
+func MakeRaw(fd int) (restore func() error, err error) {
+	return nil, ErrUnsupported
+}
+
+// Size returns ErrUnsupported.
+func Size(fd int) (cols, rows int, err error) {
+	return 0, 0, ErrUnsupported
+}
@@ -0,0 +1,27 @@
+package term
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Key
+	}{
+		{"\x1b[A\x1b[B\x1bOC", []Key{{Code: KeyUp}, {Code: KeyDown}, {Code: KeyRight}}},
+		{"\x1b[5~\x1b[6~\x1b[1~\x1b[F", []Key{{Code: KeyPgUp}, {Code: KeyPgDn}, {Code: KeyHome}, {Code: KeyEnd}}},
+		{" \r\x03", []Key{{Code: KeySpace}, {Code: KeyEnter}, {Code: KeyCtrlC}}},
+		{"qé", []Key{{Code: KeyRune, Rune: 'q'}, {Code: KeyRune, Rune: 'é'}}},
+		{"\x1b", []Key{{Code: KeyEsc}}},
+		{"\x1bq", []Key{{Code: KeyEsc}, {Code: KeyRune, Rune: 'q'}}},
+		// Modifiers are ignored; unhandled (F5) and cut-off sequences are dropped.
+		{"\x1b[15~\x1b[1;2Aj\x1b[", []Key{{Code: KeyUp}, {Code: KeyRune, Rune: 'j'}}},
+	}
+	for _, tt := range tests {
+		if got := ParseKeys([]byte(tt.in)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseKeys(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package timeline
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+// Transition is one value a field took in a host's snapshots: the snapshot
+// that first recorded it, and what that snapshot noted about its run. The
+// value before it is the previous Transition's.
+type Transition struct {
+	Snapshot
+	Value any
+	// Missing is set when the snapshot has the field's row but not the
+	// field, e.g. after a probe failed or the schema changed.
+	Missing bool
+	// Notes are the messages of the snapshot's note rows, such as a
+	// collector disabled for the run.
+	Notes []string
+}
+
+// ParseField splits a blame field, <row type>.<path>, such as
+// security_config.firewall or run_context.user. Path segments are object
+// keys or array indexes.
+func ParseField(field string) (rowType string, path []string, err error) {
+	rowType, rest, ok := strings.Cut(field, ".")
+	if !ok || rowType == "" || rest == "" {
+		return "", nil, fmt.Errorf("invalid field %q (want <row type>.<field>, e.g. security_config.firewall)", field)
+	}
+	path = strings.Split(rest, ".")
+	for _, seg := range path {
+		if seg == "" {
+			return "", nil, fmt.Errorf("invalid field %q: empty path segment", field)
+		}
+	}
+	return rowType, path, nil
+}
+
+// lookup returns the value at path in the first row of rowType. observed is
+// false when rows have no such row, so the snapshot says nothing about it.
+func lookup(rows []diff.Row, rowType string, path []string) (v any, found, observed bool) {
+	for _, r := range rows {
+		if t, _ := r["type"].(string); t != rowType {
+			continue
+		}
+		v = map[string]any(r)
+		for _, seg := range path {
+			switch c := v.(type) {
+			case map[string]any:
+				if v, found = c[seg]; !found {
+					return nil, false, true
+				}
+			case []any:
+				i, err := strconv.Atoi(seg)
+				if err != nil || i < 0 || i >= len(c) {
+					return nil, false, true
+				}
+				v = c[i]
+			default:
+				return nil, false, true
+			}
+		}
+		return v, true, true
+	}
+	return nil, false, false
+}
+
+// notes returns the messages of the note rows in rows.
+func notes(rows []diff.Row) []string {
+	var out []string
+	for _, r := range rows {
+		if t, _ := r["type"].(string); t != "note" {
+			continue
+		}
+		if m, _ := r["message"].(string); m != "" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Blame returns each value field took in the snapshots of one host, oldest
+// first, like git blame for machine state. Snapshots of every audit that
+// writes the field's row are compared, in time order; those without the row
+// are skipped, and observed counts the rest. read loads a snapshot's rows.
+func Blame(snaps []Snapshot, field string, read func(path string) ([]diff.Row, error)) (transitions []Transition, observed int, err error) {
+	rowType, path, err := ParseField(field)
+	if err != nil {
+		return nil, 0, err
+	}
+	ordered := append([]Snapshot(nil), snaps...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Time.Before(ordered[j].Time) })
+	for _, s := range ordered {
+		rows, err := read(s.Path)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, found, ok := lookup(rows, rowType, path)
+		if !ok {
+			continue
+		}
+		observed++
+		if n := len(transitions); n > 0 {
+			if last := transitions[n-1]; last.Missing == !found && reflect.DeepEqual(last.Value, v) {
+				continue
+			}
+		}
+		transitions = append(transitions, Transition{Snapshot: s, Value: v, Missing: !found, Notes: notes(rows)})
+	}
+	return transitions, observed, nil
+}
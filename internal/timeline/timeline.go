@@ -0,0 +1,197 @@
+// Package timeline reconstructs what changed on a host, and when, from its
+// stored snapshots. Each change is bounded by the two snapshots it fell
+// between: the item was not there at the earlier one and was at the later
+// one. Snapshots of different audits (network-audit, persistence-audit, ...)
+// hold different row types, so each audit's snapshots are compared only with
+// each other.
+package timeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+// Snapshot is a stored snapshot's identity, read from its meta row.
+type Snapshot struct {
+	Path      string
+	RunID     string
+	Host      string
+	Component string // the audit that wrote it, e.g. network-audit
+	Time      time.Time
+}
+
+// Event is one inventory change between two consecutive snapshots of an
+// audit. First is set for an added item that no earlier snapshot of the
+// audit, back to the baseline, had: its first appearance rather than its
+// return.
+type Event struct {
+	Host      string
+	Component string
+	After     time.Time // the last snapshot without the change
+	Before    time.Time // the first snapshot with it
+	First     bool
+	diff.InventoryChange
+}
+
+// ReadMeta reads the meta row at the top of the snapshot at path. modTime
+// dates snapshots whose meta row has no timestamp.
+func ReadMeta(path string, modTime func(string) time.Time) (Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+	s := Snapshot{Path: path, Host: "unknown", Component: "unknown"}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var meta struct {
+			Type      string `json:"type"`
+			RunID     string `json:"run_id"`
+			Hostname  string `json:"hostname"`
+			Component string `json:"tool_component"`
+			Timestamp string `json:"timestamp"`
+		}
+		if json.Unmarshal(sc.Bytes(), &meta) != nil || meta.Type != "meta" {
+			continue
+		}
+		s.RunID = meta.RunID
+		if meta.Hostname != "" {
+			s.Host = meta.Hostname
+		}
+		if meta.Component != "" {
+			s.Component = meta.Component
+		}
+		s.Time, _ = time.Parse(time.RFC3339, meta.Timestamp)
+		break
+	}
+	if err := sc.Err(); err != nil {
+		return Snapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Time.IsZero() && modTime != nil {
+		s.Time = modTime(path)
+	}
+	return s, nil
+}
+
+// FindingRowType is the RowType of events for findings: a warning code that
+// a snapshot raised and the one before it did not ("added"), or the reverse
+// ("removed").
+const FindingRowType = "warning"
+
+// changes returns the inventory changes from base to curr followed by the
+// findings raised and cleared.
+func changes(base, curr []diff.Row) []diff.InventoryChange {
+	out := diff.BuildInventoryChanges(base, curr)
+	before, after := warnings(base), warnings(curr)
+	codes := make([]string, 0, len(after))
+	for code := range after {
+		codes = append(codes, code)
+	}
+	for code := range before {
+		if _, ok := after[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		ch := diff.InventoryChange{RowType: FindingRowType, Topic: "Findings", Key: code}
+		switch b, a := before[code], after[code]; {
+		case b == nil:
+			ch.Status, ch.Item = "added", a
+		case a == nil:
+			ch.Status, ch.Item = "removed", b
+		default:
+			continue
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
+// warnings returns the last warning row of each code in rows.
+func warnings(rows []diff.Row) map[string]diff.Row {
+	out := make(map[string]diff.Row)
+	for _, r := range rows {
+		if t, _ := r["type"].(string); t != "warning" {
+			continue
+		}
+		if code, _ := r["code"].(string); code != "" {
+			out[code] = r
+		}
+	}
+	return out
+}
+
+// Build returns the changes between from and to (zero for unbounded) in the
+// snapshots of one host, oldest first: inventory changes and findings raised
+// or cleared. Each audit's baseline is its last snapshot at or before from,
+// or its first one after from when it has none earlier; changes are reported
+// from there on. read loads a snapshot's rows; only two of an audit's
+// snapshots are held at a time.
+func Build(snaps []Snapshot, from, to time.Time, read func(path string) ([]diff.Row, error)) ([]Event, error) {
+	streams := make(map[string][]Snapshot)
+	var components []string
+	for _, s := range snaps {
+		if !to.IsZero() && s.Time.After(to) {
+			continue
+		}
+		if _, ok := streams[s.Component]; !ok {
+			components = append(components, s.Component)
+		}
+		streams[s.Component] = append(streams[s.Component], s)
+	}
+	sort.Strings(components)
+
+	var events []Event
+	for _, c := range components {
+		stream := streams[c]
+		sort.SliceStable(stream, func(i, j int) bool { return stream[i].Time.Before(stream[j].Time) })
+		base := 0
+		for i, s := range stream {
+			if !s.Time.After(from) {
+				base = i
+			}
+		}
+		prev, err := read(stream[base].Path)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, ch := range changes(nil, prev) {
+			seen[ch.RowType+"\x00"+ch.Key] = true
+		}
+		for i := base + 1; i < len(stream); i++ {
+			curr, err := read(stream[i].Path)
+			if err != nil {
+				return nil, err
+			}
+			for _, ch := range changes(prev, curr) {
+				id := ch.RowType + "\x00" + ch.Key
+				events = append(events, Event{
+					Host:            stream[i].Host,
+					Component:       c,
+					After:           stream[i-1].Time,
+					Before:          stream[i].Time,
+					First:           ch.Status == "added" && !seen[id],
+					InventoryChange: ch,
+				})
+				seen[id] = true
+			}
+			prev = curr
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Before.Equal(events[j].Before) {
+			return events[i].Before.Before(events[j].Before)
+		}
+		return events[i].After.Before(events[j].After)
+	})
+	return events, nil
+}
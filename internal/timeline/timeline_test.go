@@ -0,0 +1,199 @@
+package timeline
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+func writeSnapshot(t *testing.T, dir, name, stamp, users string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	body := `{"type":"meta","schema_version":"0.1","tool_component":"users-audit","hostname":"web-01","timestamp":"` + stamp + `"}` + "\n" +
+		`{"type":"local_users","items":[` + users + `]}` + "\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	var snaps []Snapshot
+	for _, s := range []struct{ name, stamp, users string }{
+		{"a.ndjson", "2026-03-01T10:00:00Z", `{"username":"alice","uid":501}`},
+		{"b.ndjson", "2026-03-02T10:00:00Z", `{"username":"alice","uid":501},{"username":"backup","uid":0}`},
+		{"c.ndjson", "2026-03-03T10:00:00Z", `{"username":"alice","uid":501}`},
+		{"d.ndjson", "2026-03-04T10:00:00Z", `{"username":"alice","uid":501},{"username":"backup","uid":0}`},
+	} {
+		snap, err := ReadMeta(writeSnapshot(t, dir, s.name, s.stamp, s.users), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snaps = append(snaps, snap)
+	}
+	if snaps[0].Host != "web-01" || snaps[0].Component != "users-audit" {
+		t.Fatalf("meta = %+v", snaps[0])
+	}
+
+	events, err := Build(snaps, time.Time{}, time.Time{}, diff.ReadNDJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		status string
+		first  bool
+		after  string
+	}{
+		{"added", true, "2026-03-01T10:00:00Z"},
+		{"removed", false, "2026-03-02T10:00:00Z"},
+		{"added", false, "2026-03-03T10:00:00Z"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v, want %d", events, len(want))
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Key != "backup" || e.Status != w.status || e.First != w.first || e.After.Format(time.RFC3339) != w.after {
+			t.Errorf("event %d = %s %s first=%v after %s, want backup %s first=%v after %s",
+				i, e.Key, e.Status, e.First, e.After.Format(time.RFC3339), w.status, w.first, w.after)
+		}
+		if !e.Before.After(e.After) {
+			t.Errorf("event %d window %s..%s is empty", i, e.After, e.Before)
+		}
+	}
+
+	// From the second snapshot on, backup is in the baseline, and up to the
+	// third only its removal falls in the window.
+	from := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	events, err = Build(snaps, from, to, diff.ReadNDJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Status != "removed" {
+		t.Fatalf("events from %s to %s = %+v, want the removal only", from, to, events)
+	}
+}
+
+func TestWriteTimesketch(t *testing.T) {
+	base := []diff.Row{{"type": "local_users", "items": []any{map[string]any{"username": "alice"}}}}
+	curr := []diff.Row{
+		{"type": "local_users", "items": []any{map[string]any{"username": "alice"}, map[string]any{"username": "backup"}}},
+		{"type": "warning", "code": "path_world_writable", "path": "/tmp/ww"},
+	}
+	after := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	var events []Event
+	for _, ch := range changes(base, curr) {
+		events = append(events, Event{Host: "web-01", Component: "users-audit", After: after, Before: after.Add(24 * time.Hour), First: ch.Status == "added", InventoryChange: ch})
+	}
+	if len(events) != 2 || events[1].RowType != FindingRowType || events[1].Key != "path_world_writable" {
+		t.Fatalf("events = %+v, want a user and a finding", events)
+	}
+
+	var jsonl bytes.Buffer
+	if err := WriteTimesketch(&jsonl, TimesketchJSONL, events, nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("jsonl = %q", jsonl.String())
+	}
+	var ev map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev["datetime"] != "2026-03-02T10:00:00Z" || ev["timestamp"] != float64(1772445600000000) ||
+		ev["timestamp_desc"] != "First Seen" || ev["window_start"] != "2026-03-01T10:00:00Z" || ev["message"] == "" {
+		t.Errorf("jsonl event = %v", ev)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev["data_type"] != "osaudit:finding" || ev["timestamp_desc"] != "Finding Raised" {
+		t.Errorf("jsonl finding = %v", ev)
+	}
+
+	var out bytes.Buffer
+	if err := WriteTimesketch(&out, TimesketchCSV, events, func(Event) string { return "https://kb" }); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][0] != "message" || records[0][1] != "datetime" {
+		t.Fatalf("csv = %q", records)
+	}
+	if got := records[1][len(records[1])-1]; got != "https://kb" {
+		t.Errorf("kb_url = %q", got)
+	}
+	if err := WriteTimesketch(&out, "plaso", events, nil); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestBlame(t *testing.T) {
+	dir := t.TempDir()
+	var snaps []Snapshot
+	for _, s := range []struct{ name, body string }{
+		{"a.ndjson", `{"type":"meta","run_id":"run-a","tool_component":"security-audit","hostname":"web-01","timestamp":"2026-03-01T10:00:00Z"}
+{"type":"security_config","firewall":{"enabled":true}}`},
+		{"b.ndjson", `{"type":"meta","run_id":"run-b","tool_component":"network-audit","hostname":"web-01","timestamp":"2026-03-02T10:00:00Z"}
+{"type":"listening_ports","items":[]}`},
+		{"d.ndjson", `{"type":"meta","run_id":"run-d","tool_component":"full-audit","hostname":"web-01","timestamp":"2026-03-04T10:00:00Z"}
+{"type":"note","message":"collector network disabled by configuration"}
+{"type":"security_config","firewall":{"enabled":false}}`},
+		{"c.ndjson", `{"type":"meta","run_id":"run-c","tool_component":"security-audit","hostname":"web-01","timestamp":"2026-03-03T10:00:00Z"}
+{"type":"security_config","firewall":{"enabled":true}}`},
+		{"e.ndjson", `{"type":"meta","run_id":"run-e","tool_component":"security-audit","hostname":"web-01","timestamp":"2026-03-05T10:00:00Z"}
+{"type":"security_config"}`},
+	} {
+		path := filepath.Join(dir, s.name)
+		if err := os.WriteFile(path, []byte(s.body+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		snap, err := ReadMeta(path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snaps = append(snaps, snap)
+	}
+
+	transitions, observed, err := Blame(snaps, "security_config.firewall.enabled", diff.ReadNDJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if observed != 4 {
+		t.Errorf("observed = %d, want 4 (the network audit has no security_config)", observed)
+	}
+	want := []struct {
+		run     string
+		value   any
+		missing bool
+	}{{"run-a", true, false}, {"run-d", false, false}, {"run-e", nil, true}}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %+v, want %d", transitions, len(want))
+	}
+	for i, w := range want {
+		if tr := transitions[i]; tr.RunID != w.run || tr.Value != w.value || tr.Missing != w.missing {
+			t.Errorf("transitions[%d] = %s %v missing=%v, want %s %v missing=%v", i, tr.RunID, tr.Value, tr.Missing, w.run, w.value, w.missing)
+		}
+	}
+	if got := transitions[1].Notes; len(got) != 1 || !strings.Contains(got[0], "network disabled") {
+		t.Errorf("notes = %v, want the full audit's note", got)
+	}
+
+	for _, bad := range []string{"security_config", "security_config.", ".firewall", "a..b"} {
+		if _, _, err := Blame(snaps, bad, diff.ReadNDJSON); err == nil {
+			t.Errorf("Blame(%q) = nil error", bad)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package timeline
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Timesketch export formats. Both carry the fields Timesketch requires of an
+// imported event (message, datetime, timestamp, timestamp_desc) plus
+// plaso-style data_type and the event's own attributes, so they import as is
+// and sort alongside plaso output.
+const (
+	TimesketchJSONL = "timesketch-jsonl"
+	TimesketchCSV   = "timesketch-csv"
+)
+
+// TimesketchFormats lists the export formats, for usage text.
+var TimesketchFormats = []string{TimesketchJSONL, TimesketchCSV}
+
+// timesketchColumns is the CSV header, in order. JSONL events carry the same
+// fields, plus the changed item.
+var timesketchColumns = []string{
+	"message", "datetime", "timestamp", "timestamp_desc", "data_type",
+	"hostname", "audit", "topic", "row_type", "key", "status", "first", "severity",
+	"window_start", "window_end", "kb_url",
+}
+
+// Description says when an event happened, as Timesketch's timestamp_desc:
+// an item was first seen, seen again, changed, or gone, or a finding was
+// raised or cleared.
+func (e Event) Description() string {
+	if e.RowType == FindingRowType {
+		if e.Status == "removed" {
+			return "Finding Cleared"
+		}
+		return "Finding Raised"
+	}
+	switch e.Status {
+	case "added":
+		if e.First {
+			return "First Seen"
+		}
+		return "Seen Again"
+	case "removed":
+		return "Last Seen"
+	default:
+		return "Changed"
+	}
+}
+
+// DataType is the plaso-style data_type of the event.
+func (e Event) DataType() string {
+	if e.RowType == FindingRowType {
+		return "osaudit:finding"
+	}
+	return "osaudit:inventory:" + e.RowType
+}
+
+// Message is a one-line description of the event.
+func (e Event) Message() string {
+	if e.RowType == FindingRowType {
+		return fmt.Sprintf("[%s] %s: %s on %s", e.Component, e.Description(), e.Key, e.Host)
+	}
+	return fmt.Sprintf("[%s] %s %s %s: %s on %s", e.Component, e.Topic, e.RowType, e.Description(), e.Key, e.Host)
+}
+
+// timesketchFields returns the event's export fields. An event is dated at
+// the end of its window, the first snapshot that showed it; the window start
+// stays in window_start, since the change may have come any time after it.
+func timesketchFields(e Event, kbURL func(Event) string) map[string]any {
+	at := e.Before.UTC()
+	f := map[string]any{
+		"message":        e.Message(),
+		"datetime":       at.Format(time.RFC3339),
+		"timestamp":      at.UnixMicro(),
+		"timestamp_desc": e.Description(),
+		"data_type":      e.DataType(),
+		"hostname":       e.Host,
+		"audit":          e.Component,
+		"topic":          e.Topic,
+		"row_type":       e.RowType,
+		"key":            e.Key,
+		"status":         e.Status,
+		"first":          e.First,
+		"severity":       e.Severity,
+		"window_start":   e.After.UTC().Format(time.RFC3339),
+		"window_end":     at.Format(time.RFC3339),
+		"kb_url":         "",
+	}
+	if kbURL != nil {
+		f["kb_url"] = kbURL(e)
+	}
+	return f
+}
+
+// WriteTimesketch writes events to w in format, one per line (JSONL) or row
+// (CSV, after a header). kbURL, if set, links each event to its knowledge
+// base article.
+func WriteTimesketch(w io.Writer, format string, events []Event, kbURL func(Event) string) error {
+	switch format {
+	case TimesketchJSONL:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, e := range events {
+			f := timesketchFields(e, kbURL)
+			if e.Item != nil {
+				f["item"] = e.Item
+			}
+			if err := enc.Encode(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TimesketchCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(timesketchColumns); err != nil {
+			return err
+		}
+		record := make([]string, len(timesketchColumns))
+		for _, e := range events {
+			f := timesketchFields(e, kbURL)
+			for i, col := range timesketchColumns {
+				record[i] = fmt.Sprint(f[col])
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
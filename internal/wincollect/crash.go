@@ -0,0 +1,71 @@
+package wincollect
+
+import (
+	"fmt"
+	"path"
+	"runtime/debug"
+	"strings"
+)
+
+// maxStackFrames bounds the stack a collector_crash row carries.
+const maxStackFrames = 20
+
+// runIsolated runs c on a goroutine of its own and waits for it. A panic in
+// c is recovered and recorded as a collector_crash row, so the collectors
+// after it still run.
+func (a *audit) runIsolated(c Collector) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				a.crash(c, r, debug.Stack())
+			}
+		}()
+		c.run(a)
+	}()
+	<-done
+}
+
+// crash records that collector c panicked with value r.
+func (a *audit) crash(c Collector, r any, stack []byte) {
+	a.crashes++
+	msg := a.redact(fmt.Sprint(r))
+	if len(msg) > 200 {
+		msg = msg[:200]
+	}
+	a.emit("collector_crash", map[string]any{"collector": c.ID, "error": msg, "stack": a.redact(sanitizeStack(stack))})
+	fmt.Fprintf(&a.report, "\n_Collector %s crashed: %s. The remaining collectors still ran._\n", c.ID, msg)
+}
+
+// sanitizeStack trims a debug.Stack trace to what identifies the bug: the
+// frames from the panic site down, as "function file.go:line" lines. Argument
+// values, goroutine headers, and source directories (which name the build
+// machine's user) are dropped.
+func sanitizeStack(stack []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	var frames []string
+	for i := 1; i+1 < len(lines); i += 2 {
+		fn := lines[i]
+		if strings.HasPrefix(fn, "created by ") {
+			break
+		}
+		if j := strings.LastIndexByte(fn, '('); j > 0 {
+			fn = fn[:j]
+		}
+		file := strings.TrimSpace(lines[i+1])
+		if j := strings.LastIndex(file, " +0x"); j > 0 {
+			file = file[:j]
+		}
+		if fn == "panic" {
+			// Everything above is the recover machinery.
+			frames = frames[:0]
+			continue
+		}
+		frames = append(frames, fn+" "+path.Base(file))
+	}
+	if len(frames) > maxStackFrames {
+		frames = frames[:maxStackFrames]
+	}
+	return strings.Join(frames, "\n")
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package wincollect
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// System returns a source whose reads fail with ErrUnsupported.
+func System(context.Context) Source { return system{} }
+
+type system struct{}
+
+func (system) Host() HostInfo {
+	h, _ := os.Hostname()
+	return HostInfo{Hostname: h}
+}
+
+func (system) Services() ([]Service, error)    { return nil, ErrUnsupported }
+func (system) ScheduledTasks() ([]Task, error) { return nil, ErrUnsupported }
+func (system) Users() ([]User, error)          { return nil, ErrUnsupported }
+func (system) Groups() ([]Group, error)        { return nil, ErrUnsupported }
+func (system) Applications() ([]App, error)    { return nil, ErrUnsupported }
+
+func (system) Certificates() ([]Certificate, error) { return nil, ErrUnsupported }
+
+func (system) FirewallProfiles() ([]FirewallProfile, error) { return nil, ErrUnsupported }
+func (system) Defender() (DefenderStatus, error)            { return DefenderStatus{}, ErrUnsupported }
+func (system) BitLocker() (Volume, bool, error)             { return Volume{}, false, ErrUnsupported }
+
+func (system) RegistryDWORD(path, name string) (uint32, bool, error) {
+	return 0, false, ErrUnsupported
+}
+
+func (system) Events(log string, ids []int, window time.Duration, max int) ([]EventCount, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,187 @@
+//go:build windows
+
+package wincollect
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// System returns the live Windows source. The registry is read directly;
+// everything else comes from CIM and the Defender and firewall cmdlets through
+// the in-box PowerShell, which every supported Windows version ships.
+// Canceling ctx kills the PowerShell query in flight.
+func System(ctx context.Context) Source { return system{ctx: ctx} }
+
+type system struct {
+	ctx context.Context
+}
+
+const psTimeout = 2 * time.Minute
+
+const (
+	servicesScript  = `Get-CimInstance Win32_Service | Select-Object Name,DisplayName,State,StartMode,PathName,StartName`
+	tasksScript     = `Get-ScheduledTask | ForEach-Object { [pscustomobject]@{ Path = $_.TaskPath + $_.TaskName; State = [string]$_.State; Execute = (@($_.Actions | ForEach-Object { $_.Execute }) -join ';'); Author = $_.Author } }`
+	usersScript     = `Get-CimInstance Win32_UserAccount -Filter 'LocalAccount=True' | Select-Object Name,SID,Disabled`
+	firewallScript  = `Get-NetFirewallProfile -PolicyStore ActiveStore | Select-Object Name,@{n='Enabled';e={[string]$_.Enabled -eq 'True'}}`
+	defenderScript  = `Get-MpComputerStatus | Select-Object AMServiceEnabled,RealTimeProtectionEnabled,IsTamperProtected,AntivirusSignatureAge`
+	bitlockerScript = `Get-CimInstance -Namespace root/cimv2/Security/MicrosoftVolumeEncryption -ClassName Win32_EncryptableVolume -Filter "DriveLetter='$env:SystemDrive'" | Select-Object DriveLetter,ProtectionStatus`
+	// eventsScript counts events by ID. Get-WinEvent fails when nothing
+	// matches, which is not an error here.
+	eventsScript = `$f = @{ LogName = '%s'; Id = %s; StartTime = (Get-Date).AddSeconds(-%d) }; try { Get-WinEvent -FilterHashtable $f -MaxEvents %d -ErrorAction Stop | Group-Object Id | ForEach-Object { [pscustomobject]@{ Id = [int]$_.Name; Count = $_.Count } } } catch { if ($_.FullyQualifiedErrorId -notlike 'NoMatchingEventsFound*') { throw } }`
+	// appsScript lists Apps & features entries from the 64- and 32-bit
+	// Uninstall keys, skipping system components and updates.
+	appsScript = `Get-ItemProperty 'HKLM:\Software\Microsoft\Windows\CurrentVersion\Uninstall\*','HKLM:\Software\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall\*' -ErrorAction SilentlyContinue | Where-Object { $_.DisplayName -and $_.SystemComponent -ne 1 -and -not $_.ParentKeyName } | Select-Object DisplayName,@{n='DisplayVersion';e={[string]$_.DisplayVersion}},Publisher`
+	// certsScript lists the trusted root stores with each certificate's
+	// SHA-256, which Windows PowerShell's X509Certificate2 does not expose.
+	certsScript  = `$sha = [Security.Cryptography.SHA256]::Create(); foreach ($s in 'LocalMachine\Root','CurrentUser\Root') { Get-ChildItem "Cert:\$s" | ForEach-Object { [pscustomobject]@{ Store = $s; Subject = $_.Subject; Issuer = $_.Issuer; NotAfter = $_.NotAfter.ToUniversalTime().ToString('o'); SHA256 = [BitConverter]::ToString($sha.ComputeHash($_.RawData)) -replace '-' } } }`
+	groupsScript = `Get-CimInstance Win32_Group -Filter 'LocalAccount=True' | ForEach-Object { [pscustomobject]@{ Name = $_.Name; SID = $_.SID; Members = @(Get-CimAssociatedInstance -InputObject $_ -Association Win32_GroupUser | ForEach-Object { $_.Domain + '\' + $_.Name }) } }`
+)
+
+// powershell runs script and returns its results as JSON.
+func powershell(ctx context.Context, script string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, psTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command",
+		"$ProgressPreference = 'SilentlyContinue'; ConvertTo-Json -Compress -Depth 3 -InputObject @("+script+")")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, strings.SplitN(msg, "\n", 2)[0])
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func query[T any](ctx context.Context, script string) ([]T, error) {
+	out, err := powershell(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeList[T](out)
+}
+
+func (s system) Services() ([]Service, error)    { return query[Service](s.ctx, servicesScript) }
+func (s system) ScheduledTasks() ([]Task, error) { return query[Task](s.ctx, tasksScript) }
+func (s system) Users() ([]User, error)          { return query[User](s.ctx, usersScript) }
+func (s system) Groups() ([]Group, error)        { return query[Group](s.ctx, groupsScript) }
+func (s system) Applications() ([]App, error)    { return query[App](s.ctx, appsScript) }
+
+func (s system) Certificates() ([]Certificate, error) { return query[Certificate](s.ctx, certsScript) }
+
+func (s system) FirewallProfiles() ([]FirewallProfile, error) {
+	return query[FirewallProfile](s.ctx, firewallScript)
+}
+
+func (s system) Defender() (DefenderStatus, error) {
+	st, err := query[DefenderStatus](s.ctx, defenderScript)
+	if err != nil {
+		return DefenderStatus{}, err
+	}
+	if len(st) == 0 {
+		return DefenderStatus{}, errors.New("Get-MpComputerStatus returned nothing")
+	}
+	return st[0], nil
+}
+
+func (s system) BitLocker() (Volume, bool, error) {
+	v, err := query[Volume](s.ctx, bitlockerScript)
+	if err != nil || len(v) == 0 {
+		return Volume{}, false, err
+	}
+	return v[0], true, nil
+}
+
+func (s system) Events(log string, ids []int, window time.Duration, max int) ([]EventCount, error) {
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = strconv.Itoa(id)
+	}
+	return query[EventCount](s.ctx, fmt.Sprintf(eventsScript, log, strings.Join(list, ","), int(window.Seconds()), max))
+}
+
+func (system) Host() HostInfo {
+	h := HostInfo{User: os.Getenv("USERNAME"), Home: os.Getenv("USERPROFILE")}
+	h.Hostname, _ = os.Hostname()
+	const cv = `SOFTWARE\Microsoft\Windows NT\CurrentVersion`
+	product, _ := registryString(cv, "ProductName")
+	display, _ := registryString(cv, "DisplayVersion")
+	build, _ := registryString(cv, "CurrentBuildNumber")
+	h.OSVersion = strings.TrimSpace(product + " " + display)
+	h.Kernel = "Windows NT build " + build
+	return h
+}
+
+func (system) RegistryDWORD(path, name string) (uint32, bool, error) {
+	data, typ, ok, err := registryValue(path, name)
+	if err != nil || !ok {
+		return 0, ok, err
+	}
+	if typ != syscall.REG_DWORD || len(data) < 4 {
+		return 0, false, fmt.Errorf(`HKLM\%s\%s: not a DWORD`, path, name)
+	}
+	return binary.LittleEndian.Uint32(data), true, nil
+}
+
+func registryString(path, name string) (string, error) {
+	data, typ, ok, err := registryValue(path, name)
+	if err != nil || !ok {
+		return "", err
+	}
+	if typ != syscall.REG_SZ && typ != syscall.REG_EXPAND_SZ {
+		return "", fmt.Errorf(`HKLM\%s\%s: not a string`, path, name)
+	}
+	u := unsafe.Slice((*uint16)(unsafe.Pointer(&data[0])), len(data)/2)
+	return syscall.UTF16ToString(u), nil
+}
+
+// registryValue reads a value under HKEY_LOCAL_MACHINE from the 64-bit view.
+func registryValue(path, name string) (data []byte, typ uint32, ok bool, err error) {
+	pathp, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	namep, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	var key syscall.Handle
+	if err := syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, pathp, 0, syscall.KEY_READ|syscall.KEY_WOW64_64KEY, &key); err != nil {
+		if err == syscall.ERROR_FILE_NOT_FOUND || err == syscall.ERROR_PATH_NOT_FOUND {
+			return nil, 0, false, nil
+		}
+		return nil, 0, false, fmt.Errorf(`open HKLM\%s: %w`, path, err)
+	}
+	defer syscall.RegCloseKey(key)
+
+	n := uint32(64)
+	for {
+		buf := make([]byte, n)
+		err := syscall.RegQueryValueEx(key, namep, nil, &typ, &buf[0], &n)
+		switch err {
+		case nil:
+			if n == 0 {
+				return nil, typ, true, nil
+			}
+			return buf[:n], typ, true, nil
+		case syscall.ERROR_MORE_DATA:
+			continue // n now holds the required size
+		case syscall.ERROR_FILE_NOT_FOUND:
+			return nil, 0, false, nil
+		default:
+			return nil, 0, false, fmt.Errorf(`read HKLM\%s\%s: %w`, path, name, err)
+		}
+	}
+}
@@ -0,0 +1,766 @@
+// Package wincollect is the native Windows audit: the collectors that the
+// audit scripts implement for mac and linux, written in Go because Windows has
+// no bash. They read services, scheduled tasks, local users and groups, and
+// registry hardening settings, and write the same NDJSON row types and
+// Markdown report layout as the scripts, so diff, policy, and explain work on
+// Windows snapshots unchanged.
+//
+// The system is read through a Source. System returns the live one on
+// Windows; tests substitute their own.
+package wincollect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kareemsasa/operating-system-audit/internal/diff"
+)
+
+// ErrUnsupported is returned by the System source on platforms other than
+// Windows.
+var ErrUnsupported = errors.New("native Windows collectors are only available on Windows")
+
+// Service is an installed Windows service.
+type Service struct {
+	Name        string `json:"Name"`
+	DisplayName string `json:"DisplayName"`
+	State       string `json:"State"`     // Running, Stopped, ...
+	StartMode   string `json:"StartMode"` // Auto, Manual, Disabled, Boot, System
+	PathName    string `json:"PathName"`
+	StartName   string `json:"StartName"` // account the service runs as
+}
+
+// Task is a registered scheduled task.
+type Task struct {
+	Path    string `json:"Path"` // task folder and name, e.g. \Microsoft\Windows\Defrag\ScheduledDefrag
+	State   string `json:"State"`
+	Execute string `json:"Execute"` // programs of the exec actions, ';'-separated
+	Author  string `json:"Author"`
+}
+
+// User is a local user account.
+type User struct {
+	Name     string `json:"Name"`
+	SID      string `json:"SID"`
+	Disabled bool   `json:"Disabled"`
+}
+
+// Group is a local group and its members as DOMAIN\name.
+type Group struct {
+	Name    string   `json:"Name"`
+	SID     string   `json:"SID"`
+	Members []string `json:"Members"`
+}
+
+// FirewallProfile is the effective state of a Windows Firewall profile
+// (Domain, Private, or Public).
+type FirewallProfile struct {
+	Name    string `json:"Name"`
+	Enabled bool   `json:"Enabled"`
+}
+
+// DefenderStatus is the part of Get-MpComputerStatus the audit reports.
+type DefenderStatus struct {
+	AMServiceEnabled          bool  `json:"AMServiceEnabled"`
+	RealTimeProtectionEnabled bool  `json:"RealTimeProtectionEnabled"`
+	IsTamperProtected         bool  `json:"IsTamperProtected"`
+	AntivirusSignatureAge     int64 `json:"AntivirusSignatureAge"` // days
+}
+
+// Volume is the BitLocker state of a volume (Win32_EncryptableVolume).
+type Volume struct {
+	DriveLetter string `json:"DriveLetter"`
+	// ProtectionStatus is 0 (off), 1 (on), or 2 (unknown, e.g. locked).
+	ProtectionStatus int `json:"ProtectionStatus"`
+}
+
+// App is an installed program from the Uninstall registry keys, as listed
+// under Apps & features.
+type App struct {
+	Name      string `json:"DisplayName"`
+	Version   string `json:"DisplayVersion"`
+	Publisher string `json:"Publisher"`
+}
+
+// Certificate is one certificate in a certificate store. NotAfter is
+// ISO-8601; SHA256 is the hex hash of the DER encoding.
+type Certificate struct {
+	Store    string `json:"Store"`
+	Subject  string `json:"Subject"`
+	Issuer   string `json:"Issuer"`
+	NotAfter string `json:"NotAfter"`
+	SHA256   string `json:"SHA256"`
+}
+
+// EventCount is how many events with one ID a log holds.
+type EventCount struct {
+	ID    int `json:"Id"`
+	Count int `json:"Count"`
+}
+
+// HostInfo fills the meta row and report header.
+type HostInfo struct {
+	Hostname  string
+	User      string
+	Home      string
+	OSVersion string
+	Kernel    string
+}
+
+// Source reads the system. Registry paths are relative to HKEY_LOCAL_MACHINE;
+// a missing key or value is ok == false, not an error.
+type Source interface {
+	Host() HostInfo
+	Services() ([]Service, error)
+	ScheduledTasks() ([]Task, error)
+	Users() ([]User, error)
+	Groups() ([]Group, error)
+	Applications() ([]App, error)
+	// Certificates lists the machine's trusted root store, then the current
+	// user's, which also shows the machine's roots.
+	Certificates() ([]Certificate, error)
+	FirewallProfiles() ([]FirewallProfile, error)
+	Defender() (DefenderStatus, error)
+	// BitLocker returns the system drive's volume; ok is false when it is
+	// not an encryptable volume.
+	BitLocker() (v Volume, ok bool, err error)
+	RegistryDWORD(path, name string) (v uint32, ok bool, err error)
+	// Events counts the events with the given IDs that log recorded in the
+	// last window, reading at most max of them. Reading the Security log
+	// needs administrator rights.
+	Events(log string, ids []int, window time.Duration, max int) ([]EventCount, error)
+}
+
+// Collector is one native collector. IDs match internal/collector.
+type Collector struct {
+	ID      string
+	Display string
+	run     func(*audit)
+}
+
+var collectors = []Collector{
+	{ID: "identity", Display: "Identity & Access", run: (*audit).identity},
+	{ID: "config", Display: "System Configuration", run: (*audit).config},
+	{ID: "execution", Display: "Execution & Processes", run: (*audit).execution},
+	{ID: "persistence", Display: "Persistence Surfaces", run: (*audit).persistence},
+}
+
+// FullID runs every native collector that is not disabled.
+const FullID = "full"
+
+// Lookup reports whether id names a native collector or the full audit.
+func Lookup(id string) bool {
+	if id == FullID {
+		return true
+	}
+	for _, c := range collectors {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configures a Run.
+type Options struct {
+	RunID string
+	// Disabled lists collector IDs the full audit skips (see internal/collector).
+	Disabled []string
+	// RedactPaths replaces the home directory with ~ in row and report values.
+	RedactPaths bool
+	// CertExpiryDays is how soon a certificate must expire to be reported as
+	// expiring (default 30).
+	CertExpiryDays int
+	Now            func() time.Time
+}
+
+// Result is a finished run.
+type Result struct {
+	Rows   []map[string]any
+	Report string
+	// ProbeFailures counts the reads that failed; their probe_failed rows are
+	// in Rows.
+	ProbeFailures int
+	// Interrupted is set when ctx was canceled before every collector ran;
+	// Rows and Report hold what the finished ones found.
+	Interrupted bool
+	// Crashes counts the collectors that panicked; their collector_crash rows
+	// are in Rows.
+	Crashes int
+}
+
+type audit struct {
+	src     Source
+	opts    Options
+	host    HostInfo
+	rows    []map[string]any
+	report  strings.Builder
+	failed  int
+	crashes int
+}
+
+// Run runs collector id ("full" for all) against src. When ctx is canceled
+// it stops before the next collector and returns a partial result. A
+// collector that panics is recorded as a collector_crash row and the run goes
+// on; a run that finishes ends with a run_summary row counting the crashes.
+func Run(ctx context.Context, src Source, id string, opts Options) (*Result, error) {
+	if !Lookup(id) {
+		return nil, fmt.Errorf("unknown native collector %q", id)
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	a := &audit{src: src, opts: opts, host: src.Host()}
+	now := opts.Now()
+
+	title, component := "Full System Audit", "full-audit"
+	var run []Collector
+	if id == FullID {
+		off := make(map[string]bool, len(opts.Disabled))
+		for _, d := range opts.Disabled {
+			off[d] = true
+		}
+		for _, c := range collectors {
+			if !off[c.ID] {
+				run = append(run, c)
+			}
+		}
+	} else {
+		for _, c := range collectors {
+			if c.ID == id {
+				run = append(run, c)
+				title, component = c.Display+" Audit", c.ID+"-audit"
+			}
+		}
+	}
+
+	a.emit("meta", map[string]any{
+		"schema_version": diff.SchemaVersion, "tool_name": "operating-system-audit", "tool_component": component,
+		"timestamp": now.UTC().Format(time.RFC3339), "hostname": a.host.Hostname, "user": a.host.User,
+		"os_version": a.host.OSVersion, "kernel": a.host.Kernel, "platform": "windows",
+	})
+	fmt.Fprintf(&a.report, "# Windows %s\n**Generated:** %s\n**Home Directory:** %s\n**Mode:** Conservative (report only — no system changes)\n\n",
+		title, now.Format("January 02, 2006 at 03:04 PM"), a.redact(a.host.Home))
+	fmt.Fprintf(&a.report, "## Metadata\n- **Timestamp (ISO-8601):** %s\n- **Run ID:** %s\n- **Hostname:** %s\n- **Current user:** %s\n- **Windows Version:** %s\n- **Kernel:** `%s`\n",
+		now.UTC().Format(time.RFC3339), opts.RunID, a.host.Hostname, a.host.User, a.host.OSVersion, a.host.Kernel)
+	if id == FullID {
+		for _, d := range opts.Disabled {
+			if Lookup(d) {
+				fmt.Fprintf(&a.report, "- Collector %s disabled by configuration\n", d)
+				a.emit("note", map[string]any{"message": "Collector " + d + " disabled by configuration"})
+			}
+		}
+	}
+	a.report.WriteString("\n---\n")
+
+	interrupted := false
+	for _, c := range run {
+		if ctx.Err() != nil {
+			interrupted = true
+			a.report.WriteString("\n_Run interrupted; the remaining collectors did not run._\n")
+			break
+		}
+		start := time.Now()
+		a.runIsolated(c)
+		a.emit("timing", map[string]any{"section": c.ID, "elapsed_ms": time.Since(start).Milliseconds()})
+	}
+	if !interrupted {
+		status := "ok"
+		if a.crashes > 0 {
+			status = "partial"
+		}
+		a.emit("run_summary", map[string]any{"status": status, "collectors": len(run), "collector_crashes": a.crashes, "probe_failures": a.failed})
+	}
+	return &Result{Rows: a.rows, Report: a.report.String(), ProbeFailures: a.failed, Interrupted: interrupted, Crashes: a.crashes}, nil
+}
+
+func (a *audit) emit(rowType string, fields map[string]any) {
+	row := map[string]any{"type": rowType, "run_id": a.opts.RunID}
+	for k, v := range fields {
+		row[k] = v
+	}
+	a.rows = append(a.rows, row)
+}
+
+func (a *audit) section(title string) {
+	fmt.Fprintf(&a.report, "\n## %s\n\n", title)
+}
+
+// probeFailed records a failed read, like emit_probe_failed in the scripts.
+func (a *audit) probeFailed(probe string, err error) {
+	a.failed++
+	msg := err.Error()
+	if len(msg) > 200 {
+		msg = msg[:200]
+	}
+	a.emit("probe_failed", map[string]any{
+		"probe": probe, "argv0": "", "exit_code": 1, "ts_ms": time.Now().UnixMilli(), "message": a.redact(msg),
+	})
+	fmt.Fprintf(&a.report, "_Could not read (%s): %s_\n", probe, a.redact(msg))
+}
+
+func (a *audit) redact(s string) string {
+	if !a.opts.RedactPaths || a.host.Home == "" {
+		return s
+	}
+	if i := strings.Index(strings.ToLower(s), strings.ToLower(a.host.Home)); i >= 0 {
+		return s[:i] + "~" + s[i+len(a.host.Home):]
+	}
+	return s
+}
+
+// persistence lists auto-start services as enabled_services.
+func (a *audit) persistence() {
+	a.section("🔧 Auto-Start Services")
+	services, err := a.src.Services()
+	if err != nil {
+		a.probeFailed("persistence.services", err)
+	}
+	items := []any{}
+	a.report.WriteString("| Service | Start mode | State | Runs as |\n|---------|------------|-------|---------|\n")
+	var running int
+	for _, s := range services {
+		mode := strings.ToLower(s.StartMode)
+		if mode != "auto" && mode != "boot" && mode != "system" {
+			continue
+		}
+		if s.State == "Running" {
+			running++
+		}
+		fmt.Fprintf(&a.report, "| `%s` | %s | %s | %s |\n", s.Name, mode, s.State, s.StartName)
+		items = append(items, map[string]any{
+			"unit": s.Name, "state": mode, "display": s.DisplayName, "running": s.State == "Running",
+			"path": a.redact(s.PathName), "account": s.StartName,
+		})
+	}
+	if len(items) == 0 {
+		a.report.WriteString("_No auto-start services found._\n")
+	}
+	a.emit("enabled_services", map[string]any{"count": len(items), "items": items})
+	a.emit("persistence_summary", map[string]any{
+		"enabled_services": len(items), "running_services": running, "installed_services": len(services),
+	})
+}
+
+// execution lists scheduled tasks, keyed by task path.
+func (a *audit) execution() {
+	a.section("⏰ Scheduled Tasks")
+	tasks, err := a.src.ScheduledTasks()
+	if err != nil {
+		a.probeFailed("execution.scheduled_tasks", err)
+	}
+	items := []any{}
+	var enabled, microsoft int
+	for _, t := range tasks {
+		if t.State != "Disabled" {
+			enabled++
+		}
+		if strings.HasPrefix(t.Path, `\Microsoft\`) {
+			microsoft++
+			continue // OS housekeeping; hundreds per host
+		}
+		items = append(items, map[string]any{"path": t.Path, "state": t.State, "program": a.redact(t.Execute), "author": t.Author})
+	}
+	a.report.WriteString("| Task | State | Program |\n|------|-------|---------|\n")
+	for _, it := range items {
+		m := it.(map[string]any)
+		fmt.Fprintf(&a.report, "| `%s` | %s | `%s` |\n", m["path"], m["state"], m["program"])
+	}
+	if len(items) == 0 {
+		a.report.WriteString("_No scheduled tasks outside \\Microsoft\\ found._\n")
+	}
+	fmt.Fprintf(&a.report, "\n- Tasks under `\\Microsoft\\` (not listed): **%d**\n", microsoft)
+	a.emit("scheduled_tasks", map[string]any{"count": len(items), "total": len(tasks), "enabled": enabled, "microsoft": microsoft, "items": items})
+	a.emit("execution_summary", map[string]any{"scheduled_tasks": len(tasks), "enabled_tasks": enabled})
+}
+
+// administratorsSID is the well-known SID of BUILTIN\Administrators.
+const administratorsSID = "S-1-5-32-544"
+
+// identity lists local users (uid is the SID's relative ID) and groups.
+func (a *audit) identity() {
+	a.section("👤 Local Users")
+	users, err := a.src.Users()
+	if err != nil {
+		a.probeFailed("identity.local_users", err)
+	}
+	groups, gerr := a.src.Groups()
+	if gerr != nil {
+		a.probeFailed("identity.local_groups", gerr)
+	}
+
+	admins := map[string]bool{}
+	for _, g := range groups {
+		if g.SID == administratorsSID {
+			for _, m := range g.Members {
+				admins[strings.ToLower(memberName(m))] = true
+			}
+		}
+	}
+	a.report.WriteString("| Username | RID | Admin | Disabled |\n|----------|-----|-------|----------|\n")
+	items := []any{}
+	var adminCount int
+	for _, u := range users {
+		admin := admins[strings.ToLower(u.Name)]
+		if admin {
+			adminCount++
+		}
+		rid := relativeID(u.SID)
+		fmt.Fprintf(&a.report, "| `%s` | %d | %t | %t |\n", u.Name, rid, admin, u.Disabled)
+		items = append(items, map[string]any{"username": u.Name, "uid": rid, "admin": admin, "disabled": u.Disabled, "sid": u.SID})
+	}
+	if len(items) == 0 {
+		a.report.WriteString("_No local users found._\n")
+	}
+	a.emit("local_users", map[string]any{"count": len(items), "items": items})
+
+	a.section("👥 Local Groups")
+	a.report.WriteString("| Group | Members |\n|-------|---------|\n")
+	groupItems := []any{}
+	for _, g := range groups {
+		members := append([]string{}, g.Members...)
+		sort.Strings(members)
+		fmt.Fprintf(&a.report, "| `%s` | %s |\n", g.Name, strings.Join(members, ", "))
+		groupItems = append(groupItems, map[string]any{"group": g.Name, "sid": g.SID, "members": members})
+	}
+	a.emit("local_groups", map[string]any{"count": len(groupItems), "items": groupItems})
+	a.emit("identity_summary", map[string]any{"local_users": len(items), "local_groups": len(groupItems), "admins": adminCount})
+}
+
+// memberName strips the DOMAIN\ prefix; only local accounts are users here.
+func memberName(m string) string {
+	if i := strings.LastIndexByte(m, '\\'); i >= 0 {
+		return m[i+1:]
+	}
+	return m
+}
+
+// relativeID returns the last sub-authority of a SID (500 for the built-in
+// Administrator), the closest thing Windows has to a uid.
+func relativeID(sid string) int {
+	rid, _ := strconv.Atoi(sid[strings.LastIndexByte(sid, '-')+1:])
+	return rid
+}
+
+// registryCheck is one hardening setting. on maps the DWORD to the field's
+// value; missing is the value when the setting is absent (nil = unknown).
+type registryCheck struct {
+	field   string
+	path    string
+	name    string
+	on      func(uint32) bool
+	missing any
+}
+
+func nonZero(v uint32) bool { return v != 0 }
+func isZero(v uint32) bool  { return v == 0 }
+
+var registryChecks = []registryCheck{
+	{field: "secure_boot", path: `SYSTEM\CurrentControlSet\Control\SecureBoot\State`, name: "UEFISecureBootEnabled", on: nonZero, missing: false},
+	{field: "uac", path: `SOFTWARE\Microsoft\Windows\CurrentVersion\Policies\System`, name: "EnableLUA", on: nonZero, missing: true},
+	{field: "smb1", path: `SYSTEM\CurrentControlSet\Services\LanmanServer\Parameters`, name: "SMB1", on: nonZero},
+	{field: "rdp", path: `SYSTEM\CurrentControlSet\Control\Terminal Server`, name: "fDenyTSConnections", on: isZero, missing: false},
+	{field: "lsa_protection", path: `SYSTEM\CurrentControlSet\Control\Lsa`, name: "RunAsPPL", on: nonZero, missing: false},
+}
+
+// config reads Defender, BitLocker, firewall, and registry hardening settings
+// into security_config, and counts recent security events.
+func (a *audit) config() {
+	a.section("🔐 Security Settings")
+	row := map[string]any{}
+
+	a.firewall(row)
+	a.defender(row)
+	a.bitlocker(row)
+	for _, c := range registryChecks {
+		v, ok, err := a.src.RegistryDWORD(c.path, c.name)
+		switch {
+		case err != nil:
+			a.probeFailed("config."+c.field, err)
+			row[c.field] = nil
+		case !ok:
+			row[c.field] = c.missing
+		default:
+			row[c.field] = c.on(v)
+		}
+	}
+
+	a.report.WriteString("| Setting | Value |\n|---------|-------|\n")
+	fields := make([]string, 0, len(row))
+	for f := range row {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		v := "unknown"
+		if row[f] != nil {
+			v = fmt.Sprint(row[f])
+		}
+		fmt.Fprintf(&a.report, "| %s | %s |\n", f, v)
+	}
+	a.emit("security_config", row)
+	a.securityEvents()
+	a.applications()
+	a.certificates()
+}
+
+// applications lists installed programs, which MDM inventory reconciliation
+// (osaudit crosscheck --intune) compares with Intune's discovered apps.
+func (a *audit) applications() {
+	a.section("📱 Applications")
+	apps, err := a.src.Applications()
+	if err != nil {
+		a.probeFailed("config.applications", err)
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	items := []any{}
+	a.report.WriteString("| Application | Version | Publisher |\n|-------------|---------|-----------|\n")
+	for _, app := range apps {
+		fmt.Fprintf(&a.report, "| %s | %s | %s |\n", app.Name, app.Version, app.Publisher)
+		items = append(items, map[string]any{"name": app.Name, "version": app.Version, "publisher": app.Publisher})
+	}
+	if len(items) == 0 {
+		a.report.WriteString("_No installed applications found._\n")
+	}
+	a.emit("applications", map[string]any{"count": len(items), "items": items})
+}
+
+// certificates lists trusted root certificates like the scripts' certificates
+// row. Roots only in the current user's store were added by the user; the
+// machine store cannot tell roots an admin or a policy added from Windows'
+// own.
+func (a *audit) certificates() {
+	a.section("📜 Certificates")
+	certs, err := a.src.Certificates()
+	if err != nil {
+		a.probeFailed("config.certificates", err)
+	}
+	window := a.opts.CertExpiryDays
+	if window <= 0 {
+		window = 30
+	}
+	now := a.opts.Now()
+	seen := map[string]bool{}
+	items := []any{}
+	var added, addedRoots, expiring, expired int
+	var flagged []string
+	for _, c := range certs {
+		sha := strings.ToLower(c.SHA256)
+		if sha == "" || seen[sha] {
+			continue
+		}
+		seen[sha] = true
+		userAdded := strings.HasPrefix(c.Store, `CurrentUser\`)
+		root := c.Subject == c.Issuer
+		notAfter, _ := time.Parse(time.RFC3339Nano, c.NotAfter)
+		days := int(math.Floor(notAfter.Sub(now).Hours() / 24))
+		var flags []string
+		if userAdded {
+			added++
+			if root {
+				addedRoots++
+				flags = append(flags, "⚠️ user-added root CA")
+			} else {
+				flags = append(flags, "user-added")
+			}
+		}
+		switch {
+		case days < 0:
+			expired++
+			flags = append(flags, "expired")
+		case days <= window:
+			expiring++
+			flags = append(flags, "expiring")
+		}
+		if len(flags) > 0 {
+			flagged = append(flagged, fmt.Sprintf("| %s | %s | %s (%d days) | %s |", c.Store, c.Subject, notAfter.UTC().Format(time.RFC3339), days, strings.Join(flags, ", ")))
+		}
+		items = append(items, map[string]any{
+			"store": c.Store, "subject": c.Subject, "issuer": c.Issuer, "not_after": notAfter.UTC().Format(time.RFC3339),
+			"days_left": days, "sha256": sha, "root": root, "user_added": userAdded,
+		})
+	}
+	fmt.Fprintf(&a.report, "- Certificates: **%d**, expiry window: **%d days**\n\n", len(items), window)
+	if len(flagged) == 0 {
+		a.report.WriteString("_No user-added, expiring, or expired certificates._\n")
+	} else {
+		a.report.WriteString("| Store | Subject | Expires | Flags |\n|-------|---------|---------|-------|\n")
+		a.report.WriteString(strings.Join(flagged, "\n") + "\n")
+	}
+	a.emit("certificates", map[string]any{
+		"count": len(items), "user_added": added, "user_added_roots": addedRoots, "expiring": expiring, "expired": expired,
+		"expiry_days": window, "items": items,
+	})
+}
+
+// eventWindow and eventLimit bound the event log read: the summary covers the
+// last day, and at most eventLimit events per log are read. A log that
+// returns eventLimit events marks the summary truncated, and its counts are
+// lower bounds.
+const (
+	eventWindow = 24 * time.Hour
+	eventLimit  = 5000
+)
+
+// securityEvents are the summary's fields and the event IDs each counts, per
+// log.
+var securityEvents = []struct {
+	field string
+	ids   map[string][]int
+}{
+	{field: "logon_failures", ids: map[string][]int{"Security": {4625}}},
+	{field: "account_lockouts", ids: map[string][]int{"Security": {4740}}},
+	{field: "service_installs", ids: map[string][]int{"Security": {4697}, "System": {7045}}},
+	{field: "audit_policy_changes", ids: map[string][]int{"Security": {4719}}},
+	{field: "log_cleared", ids: map[string][]int{"Security": {1102}, "System": {104}}},
+}
+
+// securityEvents writes security_events_summary: counts of logon failures,
+// lockouts, service installs, audit policy changes, and cleared logs over
+// eventWindow, which diff compares between runs to flag spikes. A log that
+// cannot be read leaves its fields null.
+func (a *audit) securityEvents() {
+	a.section("🛡️ Security Events (last 24 hours)")
+	logs := map[string][]int{}
+	for _, e := range securityEvents {
+		for log, ids := range e.ids {
+			logs[log] = append(logs[log], ids...)
+		}
+	}
+	names := make([]string, 0, len(logs))
+	for log := range logs {
+		names = append(names, log)
+	}
+	sort.Strings(names)
+
+	counts := map[string]map[int]int{}
+	truncated := false
+	for _, log := range names {
+		events, err := a.src.Events(log, logs[log], eventWindow, eventLimit)
+		if err != nil {
+			a.probeFailed("config.security_events."+strings.ToLower(log), err)
+			continue
+		}
+		byID, total := map[int]int{}, 0
+		for _, e := range events {
+			byID[e.ID] += e.Count
+			total += e.Count
+		}
+		counts[log] = byID
+		truncated = truncated || total >= eventLimit
+	}
+
+	row := map[string]any{"window_hours": int(eventWindow.Hours()), "max_events": eventLimit, "truncated": truncated}
+	a.report.WriteString("| Event | Count |\n|-------|-------|\n")
+	for _, e := range securityEvents {
+		var n any = 0
+		for log, ids := range e.ids {
+			byID, ok := counts[log]
+			if !ok {
+				n = nil
+				break
+			}
+			for _, id := range ids {
+				n = n.(int) + byID[id]
+			}
+		}
+		row[e.field] = n
+		v := "unknown"
+		if n != nil {
+			v = fmt.Sprint(n)
+		}
+		fmt.Fprintf(&a.report, "| %s | %s |\n", e.field, v)
+	}
+	if truncated {
+		fmt.Fprintf(&a.report, "\n_A log held more than %d matching events; counts are lower bounds._\n", eventLimit)
+	}
+	a.emit("security_events_summary", row)
+}
+
+// firewall reports each Windows Firewall profile's effective state, and
+// firewall when all of them are on.
+func (a *audit) firewall(row map[string]any) {
+	profiles, err := a.src.FirewallProfiles()
+	if err != nil {
+		a.probeFailed("config.firewall_profiles", err)
+		row["firewall"] = nil
+		return
+	}
+	all := len(profiles) > 0
+	for _, p := range profiles {
+		row["firewall_"+strings.ToLower(p.Name)] = p.Enabled
+		all = all && p.Enabled
+	}
+	row["firewall"] = all
+}
+
+// signatureAgeUnknown is what Defender reports when signatures were never
+// updated.
+const signatureAgeUnknown = 4294967295
+
+// defender reports Microsoft Defender Antivirus protection. With a third-party
+// antivirus registered Defender is passive, and these read as off.
+func (a *audit) defender(row map[string]any) {
+	d, err := a.src.Defender()
+	if err != nil {
+		a.probeFailed("config.defender", err)
+		row["defender_realtime"], row["tamper_protection"], row["defender_signature_age_days"] = nil, nil, nil
+		return
+	}
+	row["defender_realtime"] = d.AMServiceEnabled && d.RealTimeProtectionEnabled
+	row["tamper_protection"] = d.IsTamperProtected
+	row["defender_signature_age_days"] = nil
+	if d.AntivirusSignatureAge != signatureAgeUnknown {
+		row["defender_signature_age_days"] = d.AntivirusSignatureAge
+	}
+}
+
+// bitlocker reports whether BitLocker protects the system drive. Reading it
+// needs administrator rights.
+func (a *audit) bitlocker(row map[string]any) {
+	v, ok, err := a.src.BitLocker()
+	switch {
+	case err != nil:
+		a.probeFailed("config.bitlocker", err)
+		row["bitlocker"] = nil
+	case !ok:
+		row["bitlocker"] = false // no encryptable system volume
+	default:
+		switch v.ProtectionStatus {
+		case 0:
+			row["bitlocker"] = false
+		case 1:
+			row["bitlocker"] = true
+		default:
+			row["bitlocker"] = nil // locked or unknown
+		}
+	}
+}
+
+// DecodeList decodes PowerShell ConvertTo-Json output, which is a bare object
+// for one result and empty for none, into a slice.
+func DecodeList[T any](data []byte) ([]T, error) {
+	data = []byte(strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff")))
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] == '{' {
+		var one T
+		if err := json.Unmarshal(data, &one); err != nil {
+			return nil, err
+		}
+		return []T{one}, nil
+	}
+	var out []T
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
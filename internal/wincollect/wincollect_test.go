@@ -0,0 +1,336 @@
+package wincollect
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	services []Service
+	tasks    []Task
+	users    []User
+	groups   []Group
+	apps     []App
+	certs    []Certificate
+	reg      map[string]uint32
+	firewall []FirewallProfile
+	defender *DefenderStatus
+	volume   *Volume
+	events   map[string][]EventCount // by log; a missing log fails to read
+	err      error                   // returned by Services
+}
+
+func (f fakeSource) Host() HostInfo {
+	return HostInfo{Hostname: "WS01", User: "alice", Home: `C:\Users\alice`, OSVersion: "Windows 11 Pro 23H2", Kernel: "Windows NT build 22631"}
+}
+func (f fakeSource) Services() ([]Service, error)                 { return f.services, f.err }
+func (f fakeSource) ScheduledTasks() ([]Task, error)              { return f.tasks, nil }
+func (f fakeSource) Users() ([]User, error)                       { return f.users, nil }
+func (f fakeSource) Groups() ([]Group, error)                     { return f.groups, nil }
+func (f fakeSource) Applications() ([]App, error)                 { return f.apps, nil }
+func (f fakeSource) Certificates() ([]Certificate, error)         { return f.certs, nil }
+func (f fakeSource) FirewallProfiles() ([]FirewallProfile, error) { return f.firewall, nil }
+func (f fakeSource) Defender() (DefenderStatus, error) {
+	if f.defender == nil {
+		return DefenderStatus{}, errors.New("Get-MpComputerStatus: not recognized")
+	}
+	return *f.defender, nil
+}
+func (f fakeSource) BitLocker() (Volume, bool, error) {
+	if f.volume == nil {
+		return Volume{}, false, nil
+	}
+	return *f.volume, true, nil
+}
+func (f fakeSource) Events(log string, ids []int, window time.Duration, max int) ([]EventCount, error) {
+	e, ok := f.events[log]
+	if !ok {
+		return nil, errors.New("Get-WinEvent: Attempted to perform an unauthorized operation")
+	}
+	return e, nil
+}
+func (f fakeSource) RegistryDWORD(path, name string) (uint32, bool, error) {
+	v, ok := f.reg[path+`\`+name]
+	return v, ok, nil
+}
+
+func rowsOfType(rows []map[string]any, t string) []map[string]any {
+	var out []map[string]any
+	for _, r := range rows {
+		if r["type"] == t {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func run(t *testing.T, src Source, id string, opts Options) *Result {
+	t.Helper()
+	opts.RunID = "run-1"
+	opts.Now = func() time.Time { return time.Date(2026, 3, 2, 10, 9, 7, 0, time.UTC) }
+	res, err := Run(context.Background(), src, id, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestPersistenceListsAutoStartServices(t *testing.T) {
+	src := fakeSource{services: []Service{
+		{Name: "Spooler", StartMode: "Auto", State: "Running", PathName: `C:\Windows\System32\spoolsv.exe`},
+		{Name: "Fax", StartMode: "Manual", State: "Stopped"},
+		{Name: "Agent", StartMode: "Auto", State: "Stopped", PathName: `C:\Users\alice\agent.exe`},
+	}}
+	res := run(t, src, "persistence", Options{RedactPaths: true})
+	svc := rowsOfType(res.Rows, "enabled_services")
+	if len(svc) != 1 || svc[0]["count"] != 2 {
+		t.Fatalf("enabled_services = %v", svc)
+	}
+	items := svc[0]["items"].([]any)
+	first := items[0].(map[string]any)
+	if first["unit"] != "Spooler" || first["state"] != "auto" || first["running"] != true {
+		t.Errorf("first item = %v", first)
+	}
+	if got := items[1].(map[string]any)["path"]; got != `~\agent.exe` {
+		t.Errorf("redacted path = %v", got)
+	}
+	if meta := rowsOfType(res.Rows, "meta"); len(meta) != 1 || meta[0]["tool_component"] != "persistence-audit" {
+		t.Errorf("meta = %v", meta)
+	}
+	if !strings.Contains(res.Report, "# Windows Persistence Surfaces Audit") {
+		t.Errorf("report header missing:\n%s", res.Report)
+	}
+}
+
+func TestIdentityMarksAdministrators(t *testing.T) {
+	src := fakeSource{
+		users: []User{{Name: "Administrator", SID: "S-1-5-21-1-2-3-500", Disabled: true}, {Name: "alice", SID: "S-1-5-21-1-2-3-1001"}, {Name: "bob", SID: "S-1-5-21-1-2-3-1002"}},
+		groups: []Group{
+			{Name: "Administrators", SID: administratorsSID, Members: []string{`WS01\Administrator`, `WS01\alice`, `CORP\Domain Admins`}},
+			{Name: "Users", SID: "S-1-5-32-545", Members: []string{`WS01\bob`}},
+		},
+	}
+	res := run(t, src, "identity", Options{})
+	users := rowsOfType(res.Rows, "local_users")[0]["items"].([]any)
+	want := map[string]bool{"Administrator": true, "alice": true, "bob": false}
+	for _, it := range users {
+		u := it.(map[string]any)
+		if u["admin"] != want[u["username"].(string)] {
+			t.Errorf("%v admin = %v", u["username"], u["admin"])
+		}
+	}
+	if rid := users[0].(map[string]any)["uid"]; rid != 500 {
+		t.Errorf("uid = %v, want RID 500", rid)
+	}
+	if s := rowsOfType(res.Rows, "identity_summary")[0]; s["admins"] != 2 || s["local_groups"] != 2 {
+		t.Errorf("identity_summary = %v", s)
+	}
+}
+
+func TestConfigReadsSecuritySettings(t *testing.T) {
+	src := fakeSource{
+		reg: map[string]uint32{
+			`SYSTEM\CurrentControlSet\Control\Terminal Server\fDenyTSConnections`:     0,
+			`SYSTEM\CurrentControlSet\Control\SecureBoot\State\UEFISecureBootEnabled`: 1,
+		},
+		firewall: []FirewallProfile{{"Domain", true}, {"Private", true}, {"Public", false}},
+		defender: &DefenderStatus{AMServiceEnabled: true, RealTimeProtectionEnabled: true, AntivirusSignatureAge: 3},
+		volume:   &Volume{DriveLetter: "C:", ProtectionStatus: 1},
+		events:   map[string][]EventCount{"Security": {}, "System": {}},
+		apps:     []App{{"Zoom", "5.17.1", "Zoom Video Communications, Inc."}, {"7-Zip 23.01 (x64)", "23.01", "Igor Pavlov"}},
+	}
+	res := run(t, src, "config", Options{})
+	apps := rowsOfType(res.Rows, "applications")[0]["items"].([]any)
+	if len(apps) != 2 || apps[0].(map[string]any)["name"] != "7-Zip 23.01 (x64)" {
+		t.Errorf("applications = %v, want both apps sorted by name", apps)
+	}
+	sec := rowsOfType(res.Rows, "security_config")[0]
+	want := map[string]any{
+		"firewall": false, "firewall_public": false, "firewall_private": true, "firewall_domain": true,
+		"defender_realtime": true, "tamper_protection": false, "defender_signature_age_days": int64(3), "bitlocker": true,
+		"rdp": true, "secure_boot": true, "uac": true, "lsa_protection": false, "smb1": nil,
+	}
+	for k, v := range want {
+		if sec[k] != v {
+			t.Errorf("%s = %v (%T), want %v", k, sec[k], sec[k], v)
+		}
+	}
+	if res.ProbeFailures != 0 {
+		t.Errorf("probe failures = %d", res.ProbeFailures)
+	}
+}
+
+func TestConfigWithoutDefender(t *testing.T) {
+	res := run(t, fakeSource{volume: &Volume{ProtectionStatus: 2}, events: map[string][]EventCount{"Security": {}, "System": {}}}, "config", Options{})
+	sec := rowsOfType(res.Rows, "security_config")[0]
+	for _, f := range []string{"defender_realtime", "tamper_protection", "defender_signature_age_days", "bitlocker"} {
+		if v, ok := sec[f]; !ok || v != nil {
+			t.Errorf("%s = %v, want null", f, v)
+		}
+	}
+	if sec["firewall"] != false {
+		t.Errorf("firewall with no profiles = %v, want false", sec["firewall"])
+	}
+	if p := rowsOfType(res.Rows, "probe_failed"); len(p) != 1 || p[0]["probe"] != "config.defender" {
+		t.Errorf("probe_failed = %v", p)
+	}
+}
+
+func TestConfigFlagsUserAddedAndExpiringCertificates(t *testing.T) {
+	src := fakeSource{
+		defender: &DefenderStatus{},
+		events:   map[string][]EventCount{"Security": {}, "System": {}},
+		certs: []Certificate{
+			{Store: `LocalMachine\Root`, Subject: "CN=Microsoft Root", Issuer: "CN=Microsoft Root", NotAfter: "2036-01-01T00:00:00.0000000Z", SHA256: "AA01"},
+			{Store: `LocalMachine\Root`, Subject: "CN=Old Root", Issuer: "CN=Old Root", NotAfter: "2026-03-20T00:00:00Z", SHA256: "BB02"},
+			// The user's store also shows the machine's roots.
+			{Store: `CurrentUser\Root`, Subject: "CN=Microsoft Root", Issuer: "CN=Microsoft Root", NotAfter: "2036-01-01T00:00:00.0000000Z", SHA256: "AA01"},
+			{Store: `CurrentUser\Root`, Subject: "CN=Proxy CA", Issuer: "CN=Proxy CA", NotAfter: "2025-01-01T00:00:00Z", SHA256: "CC03"},
+		},
+	}
+	res := run(t, src, "config", Options{})
+	row := rowsOfType(res.Rows, "certificates")[0]
+	want := map[string]any{"count": 3, "user_added": 1, "user_added_roots": 1, "expiring": 1, "expired": 1, "expiry_days": 30}
+	for k, v := range want {
+		if row[k] != v {
+			t.Errorf("%s = %v, want %v", k, row[k], v)
+		}
+	}
+	proxy := row["items"].([]any)[2].(map[string]any)
+	if proxy["sha256"] != "cc03" || proxy["user_added"] != true || proxy["days_left"] != -426 {
+		t.Errorf("user-added item = %v", proxy)
+	}
+	if !strings.Contains(res.Report, "| CN=Proxy CA | 2025-01-01T00:00:00Z (-426 days) | ⚠️ user-added root CA, expired |") {
+		t.Errorf("report does not flag the user-added root:\n%s", res.Report)
+	}
+}
+
+func TestSecurityEventsSummary(t *testing.T) {
+	src := fakeSource{defender: &DefenderStatus{}, events: map[string][]EventCount{
+		"Security": {{ID: 4625, Count: 4990}, {ID: 4697, Count: 1}, {ID: 1102, Count: 9}},
+		"System":   {{ID: 7045, Count: 2}},
+	}}
+	res := run(t, src, "config", Options{})
+	ev := rowsOfType(res.Rows, "security_events_summary")
+	if len(ev) != 1 {
+		t.Fatalf("security_events_summary rows = %v", ev)
+	}
+	want := map[string]any{
+		"logon_failures": 4990, "account_lockouts": 0, "service_installs": 3, "audit_policy_changes": 0, "log_cleared": 9,
+		"window_hours": 24, "max_events": eventLimit, "truncated": true,
+	}
+	for k, v := range want {
+		if ev[0][k] != v {
+			t.Errorf("%s = %v, want %v", k, ev[0][k], v)
+		}
+	}
+
+	delete(src.events, "Security")
+	res = run(t, src, "config", Options{})
+	ev = rowsOfType(res.Rows, "security_events_summary")
+	for _, f := range []string{"logon_failures", "service_installs", "log_cleared"} {
+		if v, ok := ev[0][f]; !ok || v != nil {
+			t.Errorf("%s without the Security log = %v, want null", f, v)
+		}
+	}
+	if p := rowsOfType(res.Rows, "probe_failed"); len(p) != 1 || p[0]["probe"] != "config.security_events.security" {
+		t.Errorf("probe_failed = %v", p)
+	}
+}
+
+func TestFullSkipsDisabledAndRecordsProbeFailures(t *testing.T) {
+	src := fakeSource{err: errors.New("powershell.exe: exit status 1"), defender: &DefenderStatus{}, events: map[string][]EventCount{"Security": {}, "System": {}}}
+	res := run(t, src, FullID, Options{Disabled: []string{"identity", "network"}})
+	if len(rowsOfType(res.Rows, "local_users")) != 0 {
+		t.Error("disabled identity collector ran")
+	}
+	notes := rowsOfType(res.Rows, "note")
+	if len(notes) != 1 || notes[0]["message"] != "Collector identity disabled by configuration" {
+		t.Errorf("notes = %v", notes)
+	}
+	if res.ProbeFailures != 1 || len(rowsOfType(res.Rows, "probe_failed")) != 1 {
+		t.Errorf("probe failures = %d", res.ProbeFailures)
+	}
+	if svc := rowsOfType(res.Rows, "enabled_services"); len(svc) != 1 || svc[0]["count"] != 0 {
+		t.Errorf("enabled_services after failure = %v", svc)
+	}
+}
+
+func TestDecodeList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"\ufeff[]\r\n", 0},
+		{`{"Name":"a"}`, 1},
+		{`[{"Name":"a"},{"Name":"b"}]`, 2},
+	}
+	for _, tt := range tests {
+		got, err := DecodeList[User]([]byte(tt.in))
+		if err != nil || len(got) != tt.want {
+			t.Errorf("DecodeList(%q) = %v, %v; want %d items", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := DecodeList[User]([]byte("not json")); err == nil {
+		t.Error("DecodeList accepted invalid JSON")
+	}
+}
+
+func TestRunStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := Run(ctx, fakeSource{}, "full", Options{RunID: "run-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Interrupted {
+		t.Error("Interrupted = false, want true")
+	}
+	if got := rowsOfType(res.Rows, "timing"); len(got) != 0 {
+		t.Errorf("collectors ran after cancel: %v", got)
+	}
+	if !strings.Contains(res.Report, "Run interrupted") {
+		t.Errorf("report does not note the interruption:\n%s", res.Report)
+	}
+}
+
+// panicSource panics reading services, as a collector bug would.
+type panicSource struct{ fakeSource }
+
+func (panicSource) Services() ([]Service, error) {
+	var services map[string]*Service
+	return []Service{*services["Spooler"]}, nil
+}
+
+func TestRunRecoversCollectorCrash(t *testing.T) {
+	res := run(t, panicSource{fakeSource{users: []User{{Name: "alice", SID: "S-1-5-21-1-2-3-1001"}}}}, "full", Options{})
+	if res.Crashes != 1 {
+		t.Fatalf("Crashes = %d, want 1", res.Crashes)
+	}
+	crash := rowsOfType(res.Rows, "collector_crash")
+	if len(crash) != 1 || crash[0]["collector"] != "persistence" {
+		t.Fatalf("collector_crash = %v", crash)
+	}
+	if msg, _ := crash[0]["error"].(string); !strings.Contains(msg, "nil pointer dereference") {
+		t.Errorf("error = %q", msg)
+	}
+	stack, _ := crash[0]["stack"].(string)
+	if first, _, _ := strings.Cut(stack, "\n"); !strings.Contains(first, "panicSource.Services wincollect_test.go:") {
+		t.Errorf("stack does not start at the panic site:\n%s", stack)
+	}
+	if wd, _ := os.Getwd(); strings.Contains(stack, "0x") || strings.Contains(stack, wd) || strings.Contains(stack, "created by") {
+		t.Errorf("stack keeps arguments, directories, or goroutine origins:\n%s", stack)
+	}
+	if len(rowsOfType(res.Rows, "local_users")) != 1 {
+		t.Error("identity collector did not run")
+	}
+	summary := rowsOfType(res.Rows, "run_summary")
+	if len(summary) != 1 || summary[0]["status"] != "partial" || summary[0]["collector_crashes"] != 1 {
+		t.Errorf("run_summary = %v", summary)
+	}
+}
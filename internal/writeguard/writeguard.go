@@ -0,0 +1,226 @@
+// Package writeguard is the one place osaudit writes files through, so
+// forensic mode can promise that a run leaves the audited system as it found
+// it.
+//
+// Once Enable is called, every write, rename, and removal outside the output
+// directory fails with ErrForensic, and Refuse turns away whole operations
+// that change the system by other means (installing schedules, running
+// plugins, committing baselines). The helpers mirror the os functions they
+// wrap and behave exactly like them while the guard is off.
+package writeguard
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Env is set to the output directory for the processes osaudit starts in
+// forensic mode, so a re-executed osaudit enables the guard too.
+const Env = "OSAUDIT_FORENSIC"
+
+// ErrForensic is the error every refused write wraps.
+var ErrForensic = errors.New("forensic mode")
+
+var (
+	mu  sync.RWMutex
+	dir string // resolved output directory; "" when the guard is off
+)
+
+// Enable confines writes to outputDir, creating it if needed, and points
+// TMPDIR at a directory inside it so temporary files (osaudit's own and the
+// audit scripts') stay there too. Symlinks in outputDir are resolved first.
+func Enable(outputDir string) error {
+	abs, err := filepath.Abs(outputDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(resolved, ".tmp")
+	if err := os.MkdirAll(tmp, 0o700); err != nil {
+		return err
+	}
+	mu.Lock()
+	dir = resolved
+	mu.Unlock()
+	os.Setenv("TMPDIR", tmp)
+	os.Setenv(Env, resolved)
+	return nil
+}
+
+// Disable turns the guard off. Tests use it to restore the default.
+func Disable() {
+	mu.Lock()
+	dir = ""
+	mu.Unlock()
+}
+
+// Enabled reports whether forensic mode is on.
+func Enabled() bool {
+	return Dir() != ""
+}
+
+// Dir returns the directory writes are confined to, or "" when the guard is
+// off.
+func Dir() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return dir
+}
+
+// Check returns nil when path may be written: the guard is off or path is
+// inside the output directory once symlinks in its existing ancestors are
+// resolved. Otherwise it returns an error wrapping ErrForensic.
+func Check(path string) error {
+	root := Dir()
+	if root == "" {
+		return nil
+	}
+	resolved, err := resolve(path)
+	if err != nil {
+		return fmt.Errorf("%w: refusing to write %s: %v", ErrForensic, path, err)
+	}
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return fmt.Errorf("%w: refusing to write %s outside %s", ErrForensic, path, root)
+	}
+	return nil
+}
+
+// Refuse returns an error wrapping ErrForensic naming what is disabled when
+// forensic mode is on, and nil otherwise.
+func Refuse(what string) error {
+	if !Enabled() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is disabled", ErrForensic, what)
+}
+
+// resolve makes path absolute and resolves symlinks in the longest prefix of
+// it that exists, so a link inside the output directory cannot point a write
+// elsewhere.
+func resolve(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var rest []string
+	for p := abs; ; p = filepath.Dir(p) {
+		if _, err := os.Lstat(p); err == nil {
+			r, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return "", err
+			}
+			for i := len(rest) - 1; i >= 0; i-- {
+				r = filepath.Join(r, rest[i])
+			}
+			return r, nil
+		}
+		if parent := filepath.Dir(p); parent == p {
+			return abs, nil
+		}
+		rest = append(rest, filepath.Base(p))
+	}
+}
+
+// WriteFile is os.WriteFile behind Check.
+func WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if err := Check(name); err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, perm)
+}
+
+// Create is os.Create behind Check.
+func Create(name string) (*os.File, error) {
+	if err := Check(name); err != nil {
+		return nil, err
+	}
+	return os.Create(name)
+}
+
+// OpenFile is os.OpenFile behind Check when flag opens name for writing.
+func OpenFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) != 0 {
+		if err := Check(name); err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(name, flag, perm)
+}
+
+// CreateTemp is os.CreateTemp behind Check. An empty dir is os.TempDir(),
+// which Enable points inside the output directory.
+func CreateTemp(dir, pattern string) (*os.File, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := Check(dir); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, pattern)
+}
+
+// MkdirTemp is os.MkdirTemp behind Check, with CreateTemp's default dir.
+func MkdirTemp(dir, pattern string) (string, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := Check(dir); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, pattern)
+}
+
+// MkdirAll is os.MkdirAll behind Check.
+func MkdirAll(path string, perm fs.FileMode) error {
+	if err := Check(path); err != nil {
+		return err
+	}
+	return os.MkdirAll(path, perm)
+}
+
+// Rename is os.Rename with both paths behind Check.
+func Rename(oldpath, newpath string) error {
+	if err := Check(oldpath); err != nil {
+		return err
+	}
+	if err := Check(newpath); err != nil {
+		return err
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+// Remove is os.Remove behind Check.
+func Remove(name string) error {
+	if err := Check(name); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// RemoveAll is os.RemoveAll behind Check.
+func RemoveAll(path string) error {
+	if err := Check(path); err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+// Chtimes is os.Chtimes behind Check.
+func Chtimes(name string, atime, mtime time.Time) error {
+	if err := Check(name); err != nil {
+		return err
+	}
+	return os.Chtimes(name, atime, mtime)
+}
@@ -0,0 +1,98 @@
+package writeguard
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	t.Setenv("TMPDIR", os.TempDir())
+	t.Setenv(Env, "")
+	defer Disable()
+	out, elsewhere := t.TempDir(), t.TempDir()
+	if err := Check(filepath.Join(elsewhere, "x")); err != nil {
+		t.Fatalf("Check with the guard off = %v", err)
+	}
+	if err := Enable(out); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(elsewhere, filepath.Join(out, "link")); err != nil {
+		t.Fatal(err)
+	}
+	for path, allowed := range map[string]bool{
+		out:                                     true,
+		filepath.Join(out, "a", "b.ndjson"):     true,
+		filepath.Join(out, "..", "escape"):      false,
+		filepath.Join(out, "link", "x"):         false,
+		filepath.Join(elsewhere, "x"):           false,
+		out + "-sibling":                        false,
+		filepath.Join(os.TempDir(), "spill.gz"): true,
+	} {
+		err := Check(path)
+		if allowed && err != nil {
+			t.Errorf("Check(%s) = %v, want nil", path, err)
+		}
+		if !allowed && !errors.Is(err, ErrForensic) {
+			t.Errorf("Check(%s) = %v, want ErrForensic", path, err)
+		}
+	}
+	if err := WriteFile(filepath.Join(elsewhere, "x"), nil, 0o644); !errors.Is(err, ErrForensic) {
+		t.Errorf("WriteFile outside = %v, want ErrForensic", err)
+	}
+	if _, err := os.Stat(filepath.Join(elsewhere, "x")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("WriteFile outside created the file: %v", err)
+	}
+	if err := Refuse("schedule install"); !errors.Is(err, ErrForensic) {
+		t.Errorf("Refuse = %v, want ErrForensic", err)
+	}
+}
+
+// TestNoDirectWrites keeps every write osaudit makes behind the guard: no
+// package but this one calls the os functions that write files.
+func TestNoDirectWrites(t *testing.T) {
+	writers := map[string]bool{
+		"WriteFile": true, "Create": true, "CreateTemp": true, "OpenFile": true,
+		"Mkdir": true, "MkdirAll": true, "MkdirTemp": true, "Rename": true,
+		"Remove": true, "RemoveAll": true, "Symlink": true, "Link": true,
+		"Chmod": true, "Chtimes": true, "Truncate": true,
+	}
+	fset := token.NewFileSet()
+	for _, dir := range []string{"../../cmd", "../../internal"} {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && d.Name() == "writeguard" {
+				return filepath.SkipDir
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return nil
+			}
+			f, err := parser.ParseFile(fset, path, nil, 0)
+			if err != nil {
+				return err
+			}
+			ast.Inspect(f, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "os" && writers[sel.Sel.Name] {
+					t.Errorf("%s: os.%s bypasses writeguard", fset.Position(sel.Pos()), sel.Sel.Name)
+				}
+				return true
+			})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package yamlite
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Marshal encodes a document of the shape Parse returns (map[string]any,
+// []any, and scalars) as block YAML, with mapping keys sorted. Comments and
+// the original layout of a parsed file are not kept.
+func Marshal(doc any) ([]byte, error) {
+	var b strings.Builder
+	if err := encode(&b, doc, 0); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+// ParseValue parses one scalar or flow collection, as it would appear after
+// "key: " in a file.
+func ParseValue(text string) (any, error) {
+	return scalar(text, 1)
+}
+
+func encode(b *strings.Builder, v any, indent int) error {
+	pad := strings.Repeat("  ", indent)
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) == 0 {
+			b.WriteString(pad + "{}\n")
+			return nil
+		}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString(pad + quote(k) + ":")
+			if err := encodeValue(b, x[k], indent); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+		}
+	case []any:
+		if len(x) == 0 {
+			b.WriteString(pad + "[]\n")
+			return nil
+		}
+		for i, e := range x {
+			b.WriteString(pad + "-")
+			if err := encodeValue(b, e, indent); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+	default:
+		s, err := scalarText(v)
+		if err != nil {
+			return err
+		}
+		b.WriteString(pad + s + "\n")
+	}
+	return nil
+}
+
+// encodeValue writes v after a "key:" or "-" already on the line: scalars and
+// empty collections inline, others as an indented block.
+func encodeValue(b *strings.Builder, v any, indent int) error {
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) > 0 {
+			b.WriteString("\n")
+			return encode(b, x, indent+1)
+		}
+		b.WriteString(" {}\n")
+	case []any:
+		if len(x) > 0 {
+			b.WriteString("\n")
+			return encode(b, x, indent+1)
+		}
+		b.WriteString(" []\n")
+	default:
+		s, err := scalarText(v)
+		if err != nil {
+			return err
+		}
+		b.WriteString(" " + s + "\n")
+	}
+	return nil
+}
+
+func scalarText(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(x), nil
+	case string:
+		return quote(x), nil
+	}
+	return "", fmt.Errorf("cannot encode %T", v)
+}
+
+// quote returns s as a plain scalar when Parse reads it back as the same
+// string, and double-quoted otherwise.
+func quote(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, "\n\t\"'#:[]{},&*!|>%@`") || s[0] == '-' {
+		return strconv.Quote(s)
+	}
+	if v, err := scalar(s, 0); err != nil || v != s {
+		return strconv.Quote(s)
+	}
+	return s
+}
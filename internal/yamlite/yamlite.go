@@ -0,0 +1,409 @@
+// Package yamlite parses the block-YAML subset osaudit uses for policy, rule, and
+// config files: nested mappings and sequences, plain/quoted scalars, flow
+// sequences and mappings ([a, b], {k: v}), literal/folded block scalars (| and >),
+// and # comments. Anchors, tags, and multi-document streams are not supported.
+//
+// The module has no third-party dependencies, so this replaces a full YAML
+// library for the small, hand-written files the tool reads.
+package yamlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type line struct {
+	num    int
+	indent int
+	text   string
+}
+
+// Unmarshal parses data and stores the result in v using encoding/json
+// semantics, so targets use `json:"..."` struct tags.
+func Unmarshal(data []byte, v any) error {
+	doc, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	return Decode(doc, v)
+}
+
+// Decode stores a document returned by Parse in v, like Unmarshal.
+func Decode(doc any, v any) error {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// Parse parses data into map[string]any, []any, and scalar values (string,
+// float64, bool, nil). An empty document yields nil.
+func Parse(data []byte) (any, error) {
+	p := &parser{}
+	p.raw = strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i, raw := range p.raw {
+		if strings.TrimSpace(raw) == "---" && len(p.lines) == 0 {
+			continue
+		}
+		if strings.Contains(leadingSpace(raw), "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := strings.TrimRight(stripComment(raw), " ")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		p.lines = append(p.lines, line{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	v, err := p.node(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected content %q", p.lines[p.pos].num, p.lines[p.pos].text)
+	}
+	return v, nil
+}
+
+type parser struct {
+	raw   []string
+	lines []line
+	pos   int
+}
+
+func (p *parser) node(indent int) (any, error) {
+	l := p.lines[p.pos]
+	if isSeqItem(l.text) {
+		return p.sequence(indent)
+	}
+	if _, _, ok := splitKey(l.text); ok {
+		return p.mapping(indent)
+	}
+	p.pos++
+	return scalar(l.text, l.num)
+}
+
+func (p *parser) sequence(indent int) ([]any, error) {
+	out := []any{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		// A non-item line at the same indentation ends a sequence nested
+		// directly under a mapping key.
+		if l.indent < indent || l.indent == indent && !isSeqItem(l.text) {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: bad indentation in sequence", l.num)
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				v, err := p.node(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			} else {
+				out = append(out, nil)
+			}
+			continue
+		}
+		// "- key: value" or "- - x": reinterpret the remainder as a nested node
+		// indented at the column where it starts.
+		nested := l.indent + len(l.text) - len(rest)
+		_, _, isKey := splitKey(rest)
+		if isKey || isSeqItem(rest) {
+			p.lines[p.pos] = line{num: l.num, indent: nested, text: rest}
+			v, err := p.node(nested)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		p.pos++
+		v, err := scalar(rest, l.num)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (p *parser) mapping(indent int) (map[string]any, error) {
+	out := map[string]any{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: bad indentation in mapping", l.num)
+		}
+		key, value, ok := splitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\", got %q", l.num, l.text)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, key)
+		}
+		p.pos++
+		switch {
+		case value == "|" || value == ">" || value == "|-" || value == ">-":
+			out[key] = p.blockScalar(indent, value)
+		case value != "":
+			v, err := scalar(value, l.num)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			v, err := p.node(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text):
+			// Sequences may sit at the same indentation as their parent key.
+			v, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		default:
+			out[key] = nil
+		}
+	}
+	return out, nil
+}
+
+// blockScalar collects the raw (comment-preserving) lines indented deeper than
+// the owning key.
+func (p *parser) blockScalar(indent int, style string) string {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+		return ""
+	}
+	first, last := p.lines[p.pos].num, p.lines[p.pos].num
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		last = p.lines[p.pos].num
+		p.pos++
+	}
+	block := p.raw[first-1 : last]
+	minIndent := -1
+	for _, b := range block {
+		if strings.TrimSpace(b) == "" {
+			continue
+		}
+		if n := len(b) - len(strings.TrimLeft(b, " ")); minIndent < 0 || n < minIndent {
+			minIndent = n
+		}
+	}
+	parts := make([]string, len(block))
+	for i, b := range block {
+		if len(b) >= minIndent {
+			parts[i] = strings.TrimRight(b[minIndent:], " ")
+		}
+	}
+	sep := "\n"
+	if strings.HasPrefix(style, ">") {
+		sep = " "
+	}
+	s := strings.Join(parts, sep)
+	if !strings.HasSuffix(style, "-") {
+		s += "\n"
+	}
+	return s
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitKey splits "key: value". Keys may be quoted; a colon must be followed by a
+// space or end the line, so URLs and times in plain values are left intact.
+func splitKey(text string) (key, value string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' || isSeqItem(text) {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		k, err := scalar(text[:end+1], 0)
+		if err != nil {
+			return "", "", false
+		}
+		return fmt.Sprint(k), strings.TrimSpace(text[end+2:]), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func scalar(text string, num int) (any, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return nil, nil
+	case text[0] == '"' || text[0] == '\'':
+		end := closingQuote(text)
+		if end != len(text)-1 {
+			return nil, fmt.Errorf("line %d: unterminated or trailing text after quoted string %s", num, text)
+		}
+		if text[0] == '\'' {
+			return strings.ReplaceAll(text[1:end], "''", "'"), nil
+		}
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v in %s", num, err, text)
+		}
+		return s, nil
+	case text[0] == '[':
+		return flowSeq(text, num)
+	case text[0] == '{':
+		return flowMap(text, num)
+	}
+	switch text {
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	}
+	if strings.Contains(text, ": ") {
+		return nil, fmt.Errorf("line %d: plain value %q contains \": \"; quote it", num, text)
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil && !strings.ContainsAny(text, "xXnN_") {
+		return f, nil
+	}
+	return text, nil
+}
+
+func flowSeq(text string, num int) ([]any, error) {
+	if !strings.HasSuffix(text, "]") {
+		return nil, fmt.Errorf("line %d: unterminated flow sequence %s", num, text)
+	}
+	out := []any{}
+	for _, part := range splitFlow(text[1 : len(text)-1]) {
+		v, err := scalar(part, num)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func flowMap(text string, num int) (map[string]any, error) {
+	if !strings.HasSuffix(text, "}") {
+		return nil, fmt.Errorf("line %d: unterminated flow mapping %s", num, text)
+	}
+	out := map[string]any{}
+	for _, part := range splitFlow(text[1 : len(text)-1]) {
+		k, v, ok := splitKey(part)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\" in %s", num, text)
+		}
+		val, err := scalar(v, num)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = val
+	}
+	return out, nil
+}
+
+// splitFlow splits a flow collection body on top-level commas.
+func splitFlow(body string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if escapedQuote(body, i, quote) {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(body[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(body[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// closingQuote returns the index of the quote closing text[0], or -1.
+func closingQuote(text string) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case escapedQuote(text, i, q):
+			i++
+		case text[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// escapedQuote reports whether text[i] starts an escape inside a string quoted
+// with q: a backslash in a double-quoted string, or the first of two quotes,
+// which stand for one, in a single-quoted string.
+func escapedQuote(text string, i int, q byte) bool {
+	if q == '"' {
+		return text[i] == '\\'
+	}
+	return text[i] == '\'' && i+1 < len(text) && text[i+1] == '\''
+}
+
+// stripComment removes a trailing "# ..." comment that is not inside quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if escapedQuote(s, i, quote) {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '[' || s[i-1] == '{' || s[i-1] == ',' || s[i-1] == ':' || s[i-1] == '-' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
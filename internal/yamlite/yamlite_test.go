@@ -0,0 +1,135 @@
+package yamlite
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse_Subset(t *testing.T) {
+	doc, err := Parse([]byte(`---
+# comment
+name: "quoted: value"
+count: 3
+enabled: true
+empty:
+url: https://example.com/a#frag
+tags: [a, 'b c', 2]
+inline: {k: v, n: 1}
+items:
+- id: one
+  nested:
+    - x
+    - y
+- id: two   # trailing comment
+  expr: a == "#not-a-comment"
+script: |
+  line one
+    indented
+  line three
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"name":    "quoted: value",
+		"count":   3.0,
+		"enabled": true,
+		"empty":   nil,
+		"url":     "https://example.com/a#frag",
+		"tags":    []any{"a", "b c", 2.0},
+		"inline":  map[string]any{"k": "v", "n": 1.0},
+		"items": []any{
+			map[string]any{"id": "one", "nested": []any{"x", "y"}},
+			map[string]any{"id": "two", "expr": `a == "#not-a-comment"`},
+		},
+		"script": "line one\n  indented\nline three\n",
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("Parse() =\n%#v\nwant\n%#v", doc, want)
+	}
+
+	for _, bad := range []string{"a: 1\n  b: 2\n", "a: 1\na: 2\n", "a: \"open\n", "\ta: 1\n"} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%q) = nil error", bad)
+		}
+	}
+}
+
+func TestParse_SingleQuotes(t *testing.T) {
+	doc, err := Parse([]byte(`a: 'it''s'
+b: 'it''s # not a comment'
+c: ['x'', y', z]
+'d''s': {k: 'it''s, ok'}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"a":   "it's",
+		"b":   "it's # not a comment",
+		"c":   []any{"x', y", "z"},
+		"d's": map[string]any{"k": "it's, ok"},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("Parse() =\n%#v\nwant\n%#v", doc, want)
+	}
+}
+
+func TestParse_PlainValueWithColon(t *testing.T) {
+	for _, bad := range []string{"a: b: c\n", "x: 1\nitems:\n- a: b: c\n", "a: [b: c]\n"} {
+		_, err := Parse([]byte(bad))
+		if err == nil || !strings.HasPrefix(err.Error(), "line ") {
+			t.Errorf("Parse(%q) error = %v, want a line-numbered error", bad, err)
+		}
+	}
+	if _, err := Parse([]byte("x: 1\nitems:\n- a: b: c\n")); err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Errorf("error = %v, want it on line 3", err)
+	}
+	doc, err := Parse([]byte("a: 'b: c'\nurl: http://x:80/\ntime: 10:30\n"))
+	if err != nil || !reflect.DeepEqual(doc, map[string]any{"a": "b: c", "url": "http://x:80/", "time": "10:30"}) {
+		t.Errorf("Parse() = %#v, %v", doc, err)
+	}
+}
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	doc := map[string]any{
+		"disable": []any{"execution", "network"},
+		"severity": map[string]any{
+			"exact":    map[string]any{"config.fdesetup_status": "low", "a: b": "true"},
+			"prefixes": []any{map[string]any{"prefix": "network.", "severity": "medium"}},
+		},
+		"count":   3.0,
+		"on":      true,
+		"none":    nil,
+		"text":    "#not a comment",
+		"numeric": "42",
+		"empty":   []any{},
+		"blank":   "",
+	}
+	data, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse(Marshal) = %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("round trip = %#v\nwant %#v\n%s", got, doc, data)
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	for text, want := range map[string]any{
+		"high":                 "high",
+		"3":                    3.0,
+		"false":                false,
+		"[execution, network]": []any{"execution", "network"},
+	} {
+		got, err := ParseValue(text)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseValue(%q) = %#v, %v; want %#v", text, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Package pluginsdk is the Go SDK for resident osaudit collector plugins.
+//
+// Exec plugins (see `osaudit plugins`) start once per audit run. Collectors
+// that are expensive to start, e.g. ones that keep a cache warm, can instead
+// declare "protocol": "rpc" in their description and call Serve from main:
+//
+//	func main() {
+//		pluginsdk.Serve(&myCollector{})
+//	}
+//
+// osaudit starts the plugin once, completes a handshake over its stdout, and
+// then calls Collect over a local socket for every run. The plugin stays
+// resident between runs and exits on its own after IdleTimeout without calls.
+//
+// The handshake follows hashicorp/go-plugin: osaudit sets MagicCookieKey and
+// the protocol versions it speaks in the environment, and the plugin answers
+// with a single stdout line
+//
+//	<core version>|<protocol version>|unix|<socket path>|jsonrpc
+//
+// Calls use net/rpc with the JSON codec, service name "Plugin".
+package pluginsdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DescribeFlag asks a plugin for its Description.
+	DescribeFlag = "--osaudit-describe"
+
+	// MagicCookieKey and MagicCookieValue guard against running a plugin
+	// binary directly; they are not a security measure.
+	MagicCookieKey   = "OSAUDIT_PLUGIN_MAGIC_COOKIE"
+	MagicCookieValue = "b6d3f1e0-osaudit-collector"
+
+	// ProtocolVersionsKey lists the protocol versions the host speaks,
+	// comma-separated.
+	ProtocolVersionsKey = "OSAUDIT_PLUGIN_PROTOCOL_VERSIONS"
+
+	// IdleTimeoutKey overrides IdleTimeout, as a Go duration.
+	IdleTimeoutKey = "OSAUDIT_PLUGIN_IDLE_TIMEOUT"
+
+	// CoreProtocolVersion is the version of the handshake line itself.
+	CoreProtocolVersion = 1
+
+	// ProtocolVersion is the version of the Plugin RPC service.
+	ProtocolVersion = 1
+
+	// IdleTimeout is how long a resident plugin waits for a call before exiting.
+	IdleTimeout = 10 * time.Minute
+
+	// ServiceName is the net/rpc service resident plugins register.
+	ServiceName = "Plugin"
+
+	ProtocolExec = "exec"
+	ProtocolRPC  = "rpc"
+)
+
+// RowSpec declares a row type a plugin emits. Types with a key are diffed item
+// by item like the built-in inventories.
+type RowSpec struct {
+	Type    string   `json:"type"`
+	Topic   string   `json:"topic,omitempty"`
+	Key     []string `json:"key,omitempty"`
+	Compare []string `json:"compare,omitempty"`
+	Items   bool     `json:"items,omitempty"`
+}
+
+// Description is what a plugin prints for --osaudit-describe.
+type Description struct {
+	Name           string    `json:"name"`
+	Version        string    `json:"version,omitempty"`
+	Description    string    `json:"description,omitempty"`
+	Platforms      []string  `json:"platforms,omitempty"`
+	Protocol       string    `json:"protocol,omitempty"` // ProtocolExec (default) or ProtocolRPC
+	RowTypes       []RowSpec `json:"row_types"`
+	TimeoutSeconds int       `json:"timeout_seconds,omitempty"`
+}
+
+// Capabilities describes the host a plugin runs on.
+type Capabilities struct {
+	Platform string   `json:"platform"` // mac or linux
+	Root     bool     `json:"root"`     // osaudit runs as root
+	Features []string `json:"features,omitempty"`
+}
+
+// NegotiateArgs is sent once per connection, before any Collect.
+type NegotiateArgs struct {
+	ProtocolVersion int          `json:"protocol_version"`
+	Host            Capabilities `json:"host"`
+}
+
+// NegotiateReply accepts or declines the host. A plugin declines when it cannot
+// collect anything useful there, e.g. it needs root or another platform.
+type NegotiateReply struct {
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// CollectArgs identifies the audit run a Collect call belongs to.
+type CollectArgs struct {
+	RunID    string `json:"run_id"`
+	Platform string `json:"platform"`
+	Root     string `json:"root"`
+}
+
+// CollectReply carries the rows of one collection.
+type CollectReply struct {
+	Rows []map[string]any `json:"rows"`
+}
+
+// Collector is implemented by resident plugins.
+type Collector interface {
+	Describe() Description
+	// Negotiate decides whether the plugin can run on host.
+	Negotiate(host Capabilities) (accepted bool, reason string)
+	// Collect returns the rows for one audit run. Each row needs a "type"
+	// declared in the description's row_types.
+	Collect(args CollectArgs) ([]map[string]any, error)
+}
+
+// Serve runs c as a resident plugin and does not return. It answers
+// --osaudit-describe, performs the handshake, and serves calls until the idle
+// timeout or a Shutdown call.
+func Serve(c Collector) {
+	if len(os.Args) > 1 && os.Args[1] == DescribeFlag {
+		d := c.Describe()
+		d.Protocol = ProtocolRPC
+		if err := json.NewEncoder(os.Stdout).Encode(d); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if err := serve(c); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Base(os.Args[0]), err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func serve(c Collector) error {
+	if os.Getenv(MagicCookieKey) != MagicCookieValue {
+		return fmt.Errorf("this binary is an osaudit plugin; install it in ~/.osaudit/plugins and run `osaudit run --all`")
+	}
+	if !speaks(os.Getenv(ProtocolVersionsKey), ProtocolVersion) {
+		return fmt.Errorf("host protocol versions %q do not include %d", os.Getenv(ProtocolVersionsKey), ProtocolVersion)
+	}
+	idle := IdleTimeout
+	if v := os.Getenv(IdleTimeoutKey); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", IdleTimeoutKey, err)
+		}
+		idle = d
+	}
+
+	addr := filepath.Join(os.TempDir(), fmt.Sprintf("osaudit-%s-%d.sock", sanitize(c.Describe().Name), os.Getpid()))
+	_ = os.Remove(addr)
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(addr)
+
+	s := &server{c: c, done: make(chan struct{}), timer: time.NewTimer(idle), idle: idle}
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(ServiceName, s); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d|%d|unix|%s|jsonrpc\n", CoreProtocolVersion, ProtocolVersion, addr)
+	// The host stops reading stdout after the handshake; keep stray prints
+	// from failing the plugin.
+	if null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout = null
+	}
+
+	go func() {
+		select {
+		case <-s.timer.C:
+		case <-s.done:
+		}
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return nil // listener closed: idle timeout or shutdown
+		}
+		go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// server is the RPC receiver; its exported methods form the Plugin service.
+type server struct {
+	c     Collector
+	mu    sync.Mutex
+	timer *time.Timer
+	idle  time.Duration
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (s *server) touch() {
+	s.mu.Lock()
+	s.timer.Reset(s.idle)
+	s.mu.Unlock()
+}
+
+// Negotiate implements the Plugin.Negotiate call.
+func (s *server) Negotiate(args NegotiateArgs, reply *NegotiateReply) error {
+	s.touch()
+	if args.ProtocolVersion != ProtocolVersion {
+		reply.Reason = fmt.Sprintf("protocol version %d not supported", args.ProtocolVersion)
+		return nil
+	}
+	reply.Accepted, reply.Reason = s.c.Negotiate(args.Host)
+	return nil
+}
+
+// Collect implements the Plugin.Collect call.
+func (s *server) Collect(args CollectArgs, reply *CollectReply) error {
+	s.touch()
+	rows, err := s.c.Collect(args)
+	if err != nil {
+		return err
+	}
+	reply.Rows = rows
+	s.touch()
+	return nil
+}
+
+// Shutdown implements the Plugin.Shutdown call: the plugin exits shortly after
+// replying.
+func (s *server) Shutdown(_ struct{}, _ *struct{}) error {
+	time.AfterFunc(100*time.Millisecond, func() {
+		s.once.Do(func() { close(s.done) })
+	})
+	return nil
+}
+
+func speaks(versions string, v int) bool {
+	for _, f := range strings.Split(versions, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(f)); err == nil && n == v {
+			return true
+		}
+	}
+	return false
+}
+
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}